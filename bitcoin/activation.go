@@ -0,0 +1,222 @@
+// Copyright 2020 Coinbase, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package bitcoin
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/btcsuite/btcd/chaincfg"
+	"github.com/btcsuite/btcd/chaincfg/chainhash"
+	"github.com/btcsuite/btcd/wire"
+)
+
+// Names of the consensus rule changes of a network (as
+// returned by the node in the softforks of the
+// `getblockchaininfo` response).
+const (
+	BIP0034Activation   = "bip34"
+	BIP0065Activation   = "bip65"
+	BIP0066Activation   = "bip66"
+	TestDummyActivation = "testdummy"
+	CSVActivation       = "csv"
+	SegwitActivation    = SegwitDeployment
+	TaprootActivation   = "taproot"
+)
+
+// UnknownActivationHeight is the Height of an
+// *Activation whose deployment has not been
+// resolved as active.
+const UnknownActivationHeight = int32(-1)
+
+var (
+	// ErrConsensusMismatch is returned when the block at the
+	// activation height of a rule change is not its activation
+	// block (i.e. the node follows a chain with different
+	// consensus rules).
+	ErrConsensusMismatch = errors.New("consensus mismatch")
+)
+
+// genesisActivationHashes returns the activation hashes of
+// a network whose fixed-height upgrades (like those of
+// mainnet and testnet) are active from its genesis block.
+func genesisActivationHashes(params *chaincfg.Params) map[string]*chainhash.Hash {
+	if params.GenesisHash == nil {
+		return nil
+	}
+
+	hashes := map[string]*chainhash.Hash{}
+	for _, name := range upgradeActivations {
+		hashes[name] = params.GenesisHash
+	}
+
+	return hashes
+}
+
+// upgradeActivations are the names of the
+// activations of the upgrade IDs.
+var upgradeActivations = []string{
+	BIP0034Upgrade: BIP0034Activation,
+	BIP0065Upgrade: BIP0065Activation,
+	BIP0066Upgrade: BIP0066Activation,
+}
+
+// deploymentActivations are the names of the activations
+// of the deployments in *chaincfg.Params (indexed by
+// the deployment IDs of chaincfg).
+var deploymentActivations = [chaincfg.DefinedDeployments]string{
+	chaincfg.DeploymentTestDummy: TestDummyActivation,
+	chaincfg.DeploymentCSV:       CSVActivation,
+	chaincfg.DeploymentSegwit:    SegwitActivation,
+	chaincfg.DeploymentTaproot:   TaprootActivation,
+}
+
+// Activation is how a consensus rule change activates:
+// at a fixed height or with a BIP9 (version bits)
+// deployment.
+type Activation struct {
+	Name string
+
+	// Height is the first block the rule change is active in.
+	// The height of a deployment depends on the signaling of
+	// past blocks, so it is UnknownActivationHeight until
+	// resolved (see Resolve).
+	Height int32
+
+	// Deployment is the deployment of the rule change
+	// (nil if the rule change activates at Height).
+	Deployment *Deployment
+
+	// Hash is the hash of the activation block (the block
+	// at Height), nil if it is not registered. Only rule
+	// changes activating at a fixed height have one.
+	Hash *chainhash.Hash
+}
+
+// fixedActivationHeights returns the heights of the rule
+// changes of the network with params that activate at a
+// fixed height, keyed by name.
+func fixedActivationHeights(params *chaincfg.Params) map[string]int32 {
+	return map[string]int32{
+		BIP0034Activation: params.BIP0034Height,
+		BIP0065Activation: params.BIP0065Height,
+		BIP0066Activation: params.BIP0066Height,
+	}
+}
+
+// isFixedActivation returns true if the rule
+// change with name activates at a fixed height.
+func isFixedActivation(name string) bool {
+	for _, fixed := range upgradeActivations {
+		if fixed == name {
+			return true
+		}
+	}
+
+	return false
+}
+
+// Activations returns the *Activation of each
+// consensus rule change of the network with
+// params, keyed by name.
+func Activations(params *chaincfg.Params) map[string]*Activation {
+	hashes := chainSettings(params).ActivationHashes
+	activations := map[string]*Activation{}
+	for name, height := range fixedActivationHeights(params) {
+		activations[name] = &Activation{Name: name, Height: height, Hash: hashes[name]}
+	}
+
+	for id, name := range deploymentActivations {
+		deployment, _ := NewDeployment(params, id)
+		activations[name] = &Activation{
+			Name:       name,
+			Height:     UnknownActivationHeight,
+			Deployment: deployment,
+		}
+	}
+
+	return activations
+}
+
+// LookupActivation returns the *Activation of the consensus
+// rule change with name on the network with params.
+func LookupActivation(params *chaincfg.Params, name string) (*Activation, error) {
+	activation, ok := Activations(params)[name]
+	if !ok {
+		return nil, fmt.Errorf("%w: %s", ErrUnknownUpgrade, name)
+	}
+
+	return activation, nil
+}
+
+// IsActive returns true if the rule change is active in the
+// block at height, whose median time past is medianTime. A
+// deployment is never active before it starts (or before its
+// MinActivationHeight).
+func (a *Activation) IsActive(height int32, medianTime uint64) bool {
+	if a.Deployment != nil &&
+		(medianTime < a.Deployment.StartTime || height < a.Deployment.MinActivationHeight) {
+		return false
+	}
+
+	return a.Height != UnknownActivationHeight && height >= a.Height
+}
+
+// Resolve sets the Height of a deployment from headers (the
+// header history of the chain from genesis, see ThresholdState).
+// The Height remains UnknownActivationHeight if the deployment
+// is not active at the block following headers.
+func (a *Activation) Resolve(params *chaincfg.Params, headers []*wire.BlockHeader) error {
+	if a.Deployment == nil {
+		return nil
+	}
+
+	state, since, err := a.Deployment.evaluate(params, headers)
+	if err != nil {
+		return err
+	}
+
+	a.Height = UnknownActivationHeight
+	if state == ThresholdActive {
+		a.Height = since
+	}
+
+	return nil
+}
+
+// VerifyActivationBlock returns ErrConsensusMismatch if the
+// block at height (with hash) is at the activation height of
+// a rule change of the network with params but is not its
+// registered activation block.
+func VerifyActivationBlock(params *chaincfg.Params, height int64, hash string) error {
+	hashes := chainSettings(params).ActivationHashes
+	heights := fixedActivationHeights(params)
+	for name, expected := range hashes {
+		if int64(heights[name]) != height || expected.String() == hash {
+			continue
+		}
+
+		return fmt.Errorf(
+			"%w: block %d has hash %s but %s activation block is %s",
+			ErrConsensusMismatch,
+			height,
+			hash,
+			name,
+			expected.String(),
+		)
+	}
+
+	return nil
+}
@@ -0,0 +1,135 @@
+// Copyright 2020 Coinbase, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package bitcoin
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/btcsuite/btcd/chaincfg"
+	"github.com/btcsuite/btcd/chaincfg/chainhash"
+	"github.com/btcsuite/btcd/wire"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestActivations(t *testing.T) {
+	params := chaincfg.RegressionNetParams
+	params.BIP0065Height = 200
+	params.MinerConfirmationWindow = 10
+	params.RuleChangeActivationThreshold = 8
+	params.Deployments[chaincfg.DeploymentSegwit] = chaincfg.ConsensusDeployment{
+		BitNumber:  1,
+		StartTime:  1010,
+		ExpireTime: 1100,
+	}
+
+	activations := Activations(&params)
+	assert.Len(t, activations, 3+int(chaincfg.DefinedDeployments))
+
+	// Height-based activations
+	bip65, err := LookupActivation(&params, BIP0065Activation)
+	assert.NoError(t, err)
+	assert.Nil(t, bip65.Deployment)
+	assert.False(t, bip65.IsActive(199, 0))
+	assert.True(t, bip65.IsActive(200, 0))
+
+	// BIP9 activations are only active once resolved
+	segwit, err := LookupActivation(&params, SegwitActivation)
+	assert.NoError(t, err)
+	assert.Equal(t, uint8(1), segwit.Deployment.BitNumber)
+	assert.Equal(t, UnknownActivationHeight, segwit.Height)
+	assert.False(t, segwit.IsActive(1000, 2000))
+
+	// 8 of the first blocks of each window of
+	// 10 signal for bit 1 (locking in at 30).
+	headers := make([]*wire.BlockHeader, 40)
+	for i := range headers {
+		version := int32(0x20000000)
+		if i%10 < 8 {
+			version |= 1 << 1
+		}
+
+		headers[i] = &wire.BlockHeader{
+			Version:   version,
+			Timestamp: time.Unix(int64(1000+i), 0),
+		}
+	}
+
+	assert.NoError(t, segwit.Resolve(&params, headers[:39]))
+	assert.Equal(t, UnknownActivationHeight, segwit.Height)
+
+	assert.NoError(t, segwit.Resolve(&params, headers))
+	assert.Equal(t, int32(40), segwit.Height)
+	assert.False(t, segwit.IsActive(39, 1034))
+	assert.True(t, segwit.IsActive(40, 1035))
+
+	// Deployments are never active before they start
+	assert.False(t, segwit.IsActive(40, 1000))
+
+	// Resolving a height-based activation
+	// doesn't change its height.
+	assert.NoError(t, bip65.Resolve(&params, headers))
+	assert.Equal(t, int32(200), bip65.Height)
+
+	_, err = LookupActivation(&params, "blah")
+	assert.True(t, errors.Is(err, ErrUnknownUpgrade))
+}
+
+func TestVerifyActivationBlock(t *testing.T) {
+	params := chaincfg.RegressionNetParams
+	params.Net = wire.BitcoinNet(0xa1b2c3f5)
+	params.BIP0065Height = 200
+
+	// Nothing is verified without registered hashes
+	assert.NoError(t, VerifyActivationBlock(&params, 200, "00"))
+
+	expected := &chainhash.Hash{0x01}
+	assert.NoError(t, RegisterChainParams(&ChainParams{
+		Params:           &params,
+		ActivationHashes: map[string]*chainhash.Hash{BIP0065Activation: expected},
+	}))
+	defer ResetRegistry()
+
+	bip65, err := LookupActivation(&params, BIP0065Activation)
+	assert.NoError(t, err)
+	assert.Equal(t, expected, bip65.Hash)
+
+	assert.NoError(t, VerifyActivationBlock(&params, 200, expected.String()))
+	assert.NoError(t, VerifyActivationBlock(&params, 201, "00"))
+	err = VerifyActivationBlock(&params, 200, (&chainhash.Hash{0x02}).String())
+	assert.True(t, errors.Is(err, ErrConsensusMismatch))
+
+	// Deployments have no fixed activation block
+	err = RegisterChainParams(&ChainParams{
+		Params:           &params,
+		ActivationHashes: map[string]*chainhash.Hash{SegwitActivation: expected},
+	})
+	assert.True(t, errors.Is(err, ErrUnknownUpgrade))
+
+	// The upgrades of mainnet and testnet
+	// activate in their genesis blocks.
+	genesisHash, err := chainhash.NewHashFromStr(testnetGenesisHash)
+	assert.NoError(t, err)
+	testnet := CreateTestNetChainParams(genesisHash)
+	assert.NoError(t, RegisterChainParams(testnet))
+	defer ResetRegistry()
+
+	for _, network := range []*chaincfg.Params{MainnetParams, testnet.Params} {
+		assert.NoError(t, VerifyActivationBlock(network, 0, network.GenesisHash.String()))
+		err = VerifyActivationBlock(network, 0, expected.String())
+		assert.True(t, errors.Is(err, ErrConsensusMismatch))
+	}
+}
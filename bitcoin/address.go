@@ -0,0 +1,177 @@
+// Copyright 2020 Coinbase, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package bitcoin
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/btcsuite/btcutil/base58"
+)
+
+const (
+	// CurrentAddressEra is the name of the era
+	// using the network's address prefixes.
+	CurrentAddressEra = "current"
+
+	// PubKeyHashAddressType is the type of an address
+	// encoding a public key hash (P2PKH).
+	PubKeyHashAddressType = "pubkeyhash"
+
+	// ScriptHashAddressType is the type of an address
+	// encoding a script hash (P2SH).
+	ScriptHashAddressType = "scripthash"
+)
+
+var (
+	// ErrAddressEraNotFound is returned when an address
+	// is not valid in any known era (or the requested
+	// era does not exist).
+	ErrAddressEraNotFound = errors.New("address era not found")
+
+	// MainnetAddressEras are the address eras of mainnet
+	// (from oldest to newest). Mainnet has used the same
+	// prefixes since genesis.
+	MainnetAddressEras = []*AddressEra{
+		{
+			Name:             CurrentAddressEra,
+			PubKeyHashAddrID: 0x21, // nolint:gomnd
+			ScriptHashAddrID: 0x11, // nolint:gomnd
+		},
+	}
+
+	// TestnetAddressEras are the address eras of testnet
+	// (from oldest to newest). Testnet has used the same
+	// prefixes since genesis.
+	TestnetAddressEras = []*AddressEra{
+		{
+			Name:             CurrentAddressEra,
+			PubKeyHashAddrID: 0x8B, // nolint:gomnd
+			ScriptHashAddrID: 0x13, // nolint:gomnd
+		},
+	}
+
+	// SignetAddressEras are the address eras of signet
+	// (which has only used the upstream test prefixes).
+	SignetAddressEras = []*AddressEra{
+		{
+			Name:             CurrentAddressEra,
+			PubKeyHashAddrID: 0x6f, // nolint:gomnd
+			ScriptHashAddrID: 0xc4, // nolint:gomnd
+		},
+	}
+)
+
+// AddressEra is a set of base58 address prefixes
+// (version bytes) used by a network during a
+// period of its history.
+type AddressEra struct {
+	Name             string `json:"name"`
+	PubKeyHashAddrID byte   `json:"pubkeyhash_addr_id"`
+	ScriptHashAddrID byte   `json:"scripthash_addr_id"`
+}
+
+// addressType returns the type of address encoded
+// with version in an era (if any).
+func (e *AddressEra) addressType(version byte) (string, bool) {
+	switch version {
+	case e.PubKeyHashAddrID:
+		return PubKeyHashAddressType, true
+	case e.ScriptHashAddrID:
+		return ScriptHashAddressType, true
+	default:
+		return "", false
+	}
+}
+
+// version returns the version byte of
+// addressType in an era.
+func (e *AddressEra) version(addressType string) byte {
+	if addressType == ScriptHashAddressType {
+		return e.ScriptHashAddrID
+	}
+
+	return e.PubKeyHashAddrID
+}
+
+// AddressEras returns the names of all eras an address
+// is valid in (and the type of the address). Only base58
+// addresses have era-specific prefixes.
+func AddressEras(address string, eras []*AddressEra) ([]string, string, error) {
+	payload, version, err := base58.CheckDecode(address)
+	if err != nil {
+		return nil, "", fmt.Errorf("%w: unable to decode address %s", err, address)
+	}
+
+	if len(payload) != 20 { // nolint:gomnd
+		return nil, "", fmt.Errorf("address %s has invalid length", address)
+	}
+
+	valid := []string{}
+	var addressType string
+	for _, era := range eras {
+		eraAddressType, ok := era.addressType(version)
+		if !ok {
+			continue
+		}
+
+		valid = append(valid, era.Name)
+		addressType = eraAddressType
+	}
+
+	if len(valid) == 0 {
+		return nil, "", fmt.Errorf("%w: %s", ErrAddressEraNotFound, address)
+	}
+
+	return valid, addressType, nil
+}
+
+// MigrateAddress re-encodes an address (valid in any
+// of eras) with the prefixes of the era named to.
+func MigrateAddress(address string, eras []*AddressEra, to string) (string, error) {
+	var target *AddressEra
+	for _, era := range eras {
+		if era.Name == to {
+			target = era
+			break
+		}
+	}
+
+	if target == nil {
+		if _, _, err := AddressEras(address, eras); err != nil {
+			return "", err
+		}
+
+		return "", fmt.Errorf("%w: %s", ErrAddressEraNotFound, to)
+	}
+
+	return encodeAddress(address, eras, target)
+}
+
+// encodeAddress re-encodes an address (valid in any
+// of eras) with the prefixes of target.
+func encodeAddress(address string, eras []*AddressEra, target *AddressEra) (string, error) {
+	_, addressType, err := AddressEras(address, eras)
+	if err != nil {
+		return "", err
+	}
+
+	payload, _, err := base58.CheckDecode(address)
+	if err != nil {
+		return "", fmt.Errorf("%w: unable to decode address %s", err, address)
+	}
+
+	return base58.CheckEncode(payload, target.version(addressType)), nil
+}
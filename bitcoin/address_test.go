@@ -0,0 +1,109 @@
+// Copyright 2020 Coinbase, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package bitcoin
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMigrateAddress(t *testing.T) {
+	tests := map[string]struct {
+		address string
+		eras    []*AddressEra
+		to      string
+
+		validEras   []string
+		addressType string
+		migrated    string
+		err         error
+	}{
+		"current to current (mainnet pubkeyhash)": {
+			address:     "EH9uVaqWRxHuzJbroqzX18yxmeW8XVJyV9",
+			eras:        MainnetAddressEras,
+			to:          CurrentAddressEra,
+			validEras:   []string{CurrentAddressEra},
+			addressType: PubKeyHashAddressType,
+			migrated:    "EH9uVaqWRxHuzJbroqzX18yxmeW8XVJyV9",
+		},
+		"current to current (testnet)": {
+			address:     "xvyqs6S3h5QngFP3QKJJQRqMV7p7sd48cU",
+			eras:        TestnetAddressEras,
+			to:          CurrentAddressEra,
+			validEras:   []string{CurrentAddressEra},
+			addressType: PubKeyHashAddressType,
+			migrated:    "xvyqs6S3h5QngFP3QKJJQRqMV7p7sd48cU",
+		},
+		"current to next (mainnet scripthash)": {
+			address: "7qkFjr4u54stuNNUR8fRF8dNhaP37bg4x7",
+			eras: []*AddressEra{
+				MainnetAddressEras[0],
+				{Name: "next", PubKeyHashAddrID: 0x00, ScriptHashAddrID: 0x05},
+			},
+			to:          "next",
+			validEras:   []string{CurrentAddressEra},
+			addressType: ScriptHashAddressType,
+			migrated:    "31h1vYVSYuKP6AhS86fbRdMw9XHieotbST",
+		},
+		"upstream bitcoin prefixes": {
+			address: "1111111111111111111114oLvT2",
+			eras:    MainnetAddressEras,
+			to:      CurrentAddressEra,
+			err:     ErrAddressEraNotFound,
+		},
+		"wrong network": {
+			address: "xvyqs6S3h5QngFP3QKJJQRqMV7p7sd48cU",
+			eras:    MainnetAddressEras,
+			to:      CurrentAddressEra,
+			err:     ErrAddressEraNotFound,
+		},
+		"unknown era": {
+			address:     "EH9uVaqWRxHuzJbroqzX18yxmeW8XVJyV9",
+			eras:        MainnetAddressEras,
+			to:          "future",
+			validEras:   []string{CurrentAddressEra},
+			addressType: PubKeyHashAddressType,
+			err:         ErrAddressEraNotFound,
+		},
+		"invalid checksum": {
+			address: "EH9uVaqWRxHuzJbroqzX18yxmeW8XVJyV8",
+			eras:    MainnetAddressEras,
+			to:      CurrentAddressEra,
+			err:     errors.New("unable to decode address"),
+		},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			eras, addressType, err := AddressEras(test.address, test.eras)
+			if test.validEras != nil {
+				assert.NoError(t, err)
+				assert.Equal(t, test.validEras, eras)
+				assert.Equal(t, test.addressType, addressType)
+			}
+
+			migrated, err := MigrateAddress(test.address, test.eras, test.to)
+			if test.err != nil {
+				assert.Contains(t, err.Error(), test.err.Error())
+				return
+			}
+
+			assert.NoError(t, err)
+			assert.Equal(t, test.migrated, migrated)
+		})
+	}
+}
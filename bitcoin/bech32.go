@@ -0,0 +1,115 @@
+// Copyright 2020 Coinbase, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package bitcoin
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/btcsuite/btcd/chaincfg"
+	"github.com/btcsuite/btcutil"
+	"github.com/btcsuite/btcutil/bech32"
+)
+
+const (
+	// witnessPubKeyHashSize and witnessScriptHashSize are
+	// the sizes of version 0 witness programs (P2WPKH
+	// and P2WSH).
+	witnessPubKeyHashSize = 20
+	witnessScriptHashSize = 32
+)
+
+var (
+	// ErrUnsupportedWitnessProgram is returned when a
+	// native segwit address does not encode a version 0
+	// P2WPKH or P2WSH program.
+	ErrUnsupportedWitnessProgram = errors.New("unsupported witness program")
+
+	// ErrAddressWrongNetwork is returned when an
+	// address belongs to a different network.
+	ErrAddressWrongNetwork = errors.New("address is for a different network")
+)
+
+// EncodeSegwitAddress returns the native segwit (bech32)
+// address of a version 0 witness program (P2WPKH or P2WSH)
+// with the human-readable part of params.
+func EncodeSegwitAddress(program []byte, params *chaincfg.Params) (string, error) {
+	address, err := newSegwitAddress(program, params)
+	if err != nil {
+		return "", err
+	}
+
+	return address.EncodeAddress(), nil
+}
+
+// newSegwitAddress returns the btcutil.Address of a
+// version 0 witness program.
+func newSegwitAddress(program []byte, params *chaincfg.Params) (btcutil.Address, error) {
+	switch len(program) {
+	case witnessPubKeyHashSize:
+		return btcutil.NewAddressWitnessPubKeyHash(program, params)
+	case witnessScriptHashSize:
+		return btcutil.NewAddressWitnessScriptHash(program, params)
+	default:
+		return nil, fmt.Errorf(
+			"%w: program has invalid length %d",
+			ErrUnsupportedWitnessProgram,
+			len(program),
+		)
+	}
+}
+
+// DecodeAddress decodes an address of the network with
+// params. Unlike btcutil.DecodeAddress, native segwit
+// addresses are decoded with the human-readable part of
+// params (which chaincfg does not know for networks that
+// share a message start with an upstream network), and
+// addresses of other networks are rejected.
+func DecodeAddress(address string, params *chaincfg.Params) (btcutil.Address, error) {
+	prefix := params.Bech32HRPSegwit + "1"
+	if len(address) > len(prefix) && strings.EqualFold(address[:len(prefix)], prefix) {
+		_, data, err := bech32.Decode(address)
+		if err != nil {
+			return nil, fmt.Errorf("%w: unable to decode address %s", err, address)
+		}
+
+		if len(data) == 0 || data[0] != 0 {
+			return nil, fmt.Errorf(
+				"%w: %s is not a version 0 address",
+				ErrUnsupportedWitnessProgram,
+				address,
+			)
+		}
+
+		program, err := bech32.ConvertBits(data[1:], 5, 8, false) // nolint:gomnd
+		if err != nil {
+			return nil, fmt.Errorf("%w: unable to decode address %s", err, address)
+		}
+
+		return newSegwitAddress(program, params)
+	}
+
+	decoded, err := btcutil.DecodeAddress(address, params)
+	if err != nil {
+		return nil, fmt.Errorf("%w: unable to decode address %s", err, address)
+	}
+
+	if !decoded.IsForNet(params) {
+		return nil, fmt.Errorf("%w: %s", ErrAddressWrongNetwork, address)
+	}
+
+	return decoded, nil
+}
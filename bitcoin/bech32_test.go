@@ -0,0 +1,129 @@
+// Copyright 2020 Coinbase, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package bitcoin
+
+import (
+	"encoding/hex"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/btcsuite/btcd/chaincfg"
+	"github.com/btcsuite/btcd/txscript"
+	"github.com/btcsuite/btcutil"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSegwitAddresses(t *testing.T) {
+	pubKeyHash, err := hex.DecodeString("c005b00ad075d30b89a7b65b7dad8899ba6a9c55")
+	assert.NoError(t, err)
+	scriptHash := make([]byte, 32)
+	for i := range scriptHash {
+		scriptHash[i] = byte(i)
+	}
+
+	tests := map[string]struct {
+		program []byte
+		params  *chaincfg.Params
+
+		address string
+	}{
+		"P2WPKH (mainnet)": {
+			program: pubKeyHash,
+			params:  MainnetParams,
+			address: "euno1qcqzmqzkswhfshzd8kedhmtvgnxax48z4eg7n8t",
+		},
+		"P2WPKH (testnet)": {
+			program: pubKeyHash,
+			params:  TestnetParams,
+			address: "teuno1qcqzmqzkswhfshzd8kedhmtvgnxax48z4pnvvd3",
+		},
+		"P2WSH (mainnet)": {
+			program: scriptHash,
+			params:  MainnetParams,
+			address: "euno1qqqqsyqcyq5rqwzqfpg9scrgwpugpzysnzs23v9ccrydpk8qarc0sydt6lt",
+		},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			address, err := EncodeSegwitAddress(test.program, test.params)
+			assert.NoError(t, err)
+			assert.Equal(t, test.address, address)
+
+			for _, encoded := range []string{address, strings.ToUpper(address)} {
+				decoded, err := DecodeAddress(encoded, test.params)
+				assert.NoError(t, err)
+				assert.Equal(t, address, decoded.EncodeAddress())
+				assert.Equal(t, test.program, decoded.ScriptAddress())
+
+				script, err := txscript.PayToAddrScript(decoded)
+				assert.NoError(t, err)
+				_, parsed, err := ParseSingleAddress(test.params, script)
+				assert.NoError(t, err)
+				assert.Equal(t, address, parsed.EncodeAddress())
+			}
+		})
+	}
+
+	_, err = EncodeSegwitAddress(pubKeyHash[:10], MainnetParams)
+	assert.True(t, errors.Is(err, ErrUnsupportedWitnessProgram))
+
+	// Base58 addresses are decoded with the prefixes of the network
+	decoded, err := DecodeAddress("EH9uVaqWRxHuzJbroqzX18yxmeW8XVJyV9", MainnetParams)
+	assert.NoError(t, err)
+	assert.IsType(t, &btcutil.AddressPubKeyHash{}, decoded)
+
+	invalid := map[string]struct {
+		address string
+		params  *chaincfg.Params
+
+		err error
+	}{
+		"other network (bech32)": {
+			address: "bc1qw508d6qejxtdg4y5r3zarvary0c5xw7kv8f3t4",
+			params:  MainnetParams,
+			err:     ErrAddressWrongNetwork,
+		},
+		"other network (testnet)": {
+			address: "teuno1qcqzmqzkswhfshzd8kedhmtvgnxax48z4pnvvd3",
+			params:  MainnetParams,
+		},
+		"invalid checksum": {
+			address: "euno1qcqzmqzkswhfshzd8kedhmtvgnxax48z4eg7n8q",
+			params:  MainnetParams,
+		},
+		"version 1": {
+			address: "euno1pqqqsyqcyq5rqwzqfpg9scrgwpugpzysny2zdgu",
+			params:  MainnetParams,
+			err:     ErrUnsupportedWitnessProgram,
+		},
+		"other network (base58)": {
+			address: "xvyqs6S3h5QngFP3QKJJQRqMV7p7sd48cU",
+			params:  MainnetParams,
+		},
+	}
+
+	for name, test := range invalid {
+		t.Run(name, func(t *testing.T) {
+			decoded, err := DecodeAddress(test.address, test.params)
+			assert.Nil(t, decoded)
+			assert.Error(t, err)
+			if test.err != nil {
+				assert.True(t, errors.Is(err, test.err))
+			}
+		})
+	}
+}
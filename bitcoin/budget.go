@@ -0,0 +1,122 @@
+// Copyright 2020 Coinbase, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package bitcoin
+
+import (
+	"github.com/btcsuite/btcd/chaincfg"
+)
+
+const (
+	// MainnetBudgetCycleBlocks and TestnetBudgetCycleBlocks
+	// are the number of blocks of a budget cycle (the treasury
+	// is paid out in the last block of each cycle, the
+	// superblock) on mainnet and testnet.
+	MainnetBudgetCycleBlocks = int64(43200)
+	TestnetBudgetCycleBlocks = int64(144)
+
+	// minRewardOutputs is the number of paying outputs the
+	// reward transaction of a block has (at least) when it
+	// pays the treasury or a masternode (the producer and
+	// the payout).
+	minRewardOutputs = 2
+)
+
+// IsSuperblock returns true if the block at height is a
+// superblock (the last block of a budget cycle, which pays
+// out the treasury) on the network with params. Networks
+// without a budget cycle have no superblocks.
+func IsSuperblock(params *chaincfg.Params, height int64) bool {
+	if params == nil {
+		return false
+	}
+
+	blocks := chainSettings(params).BudgetCycleBlocks
+	if blocks <= 0 || height <= 0 {
+		return false
+	}
+
+	return height%blocks == 0
+}
+
+// NextSuperblock returns the height of the first superblock
+// after height on the network with params (false if the
+// network has no superblocks).
+func NextSuperblock(params *chaincfg.Params, height int64) (int64, bool) {
+	if params == nil {
+		return 0, false
+	}
+
+	blocks := chainSettings(params).BudgetCycleBlocks
+	if blocks <= 0 {
+		return 0, false
+	}
+
+	if height < 0 {
+		return blocks, true
+	}
+
+	return (height/blocks + 1) * blocks, true
+}
+
+// isCoinStake returns true if tx is a coinstake (its
+// first output is empty, marking the transaction that
+// pays the reward of a staked block).
+func isCoinStake(tx *Transaction) bool {
+	if len(tx.Inputs) == 0 || len(tx.Outputs) < minRewardOutputs {
+		return false
+	}
+
+	first := tx.Outputs[0]
+	return first.Value == 0 && (first.ScriptPubKey == nil || len(first.ScriptPubKey.Hex) == 0)
+}
+
+// TreasuryPayout returns the index of the transaction of
+// block (and of its output) that pays out the treasury, and
+// false if block does not pay the treasury. The treasury is
+// paid by the reward transaction of a superblock (the coinstake
+// of staked blocks, the coinbase otherwise) in its last output
+// (in place of the masternode payment).
+func TreasuryPayout(block *Block, params *chaincfg.Params) (int, int64, bool) {
+	if !IsSuperblock(params, block.Height) || len(block.Txs) == 0 {
+		return 0, 0, false
+	}
+
+	rewardIndex, paying := rewardTransaction(block)
+	if paying < minRewardOutputs {
+		return 0, 0, false
+	}
+
+	reward := block.Txs[rewardIndex]
+	return rewardIndex, reward.Outputs[len(reward.Outputs)-1].Index, true
+}
+
+// rewardTransaction returns the index of the reward transaction
+// of block (the coinstake of staked blocks, the coinbase
+// otherwise) and its number of paying outputs. block must
+// have at least one transaction.
+func rewardTransaction(block *Block) (int, int) {
+	rewardIndex := 0
+	if len(block.Txs) > 1 && isCoinStake(block.Txs[1]) {
+		rewardIndex = 1
+	}
+
+	// The coinstake marker is not a paying output.
+	paying := len(block.Txs[rewardIndex].Outputs)
+	if rewardIndex == 1 {
+		paying--
+	}
+
+	return rewardIndex, paying
+}
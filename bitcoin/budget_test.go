@@ -0,0 +1,143 @@
+// Copyright 2020 Coinbase, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package bitcoin
+
+import (
+	"testing"
+
+	"github.com/btcsuite/btcd/chaincfg"
+	"github.com/btcsuite/btcd/wire"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSuperblocks(t *testing.T) {
+	assert.False(t, IsSuperblock(MainnetParams, 0))
+	assert.False(t, IsSuperblock(MainnetParams, 43199))
+	assert.True(t, IsSuperblock(MainnetParams, 43200))
+	assert.True(t, IsSuperblock(TestnetParams, 288))
+	assert.False(t, IsSuperblock(nil, 43200))
+
+	next, ok := NextSuperblock(MainnetParams, 0)
+	assert.True(t, ok)
+	assert.Equal(t, int64(43200), next)
+
+	// The next superblock of a
+	// superblock is the next cycle.
+	next, ok = NextSuperblock(MainnetParams, 43200)
+	assert.True(t, ok)
+	assert.Equal(t, int64(86400), next)
+
+	next, ok = NextSuperblock(TestnetParams, 145)
+	assert.True(t, ok)
+	assert.Equal(t, int64(288), next)
+
+	// Networks without a budget cycle
+	// have no superblocks.
+	params := &chaincfg.Params{Net: wire.BitcoinNet(0xa1b2c3d8)}
+	assert.False(t, IsSuperblock(params, 43200))
+	_, ok = NextSuperblock(params, 0)
+	assert.False(t, ok)
+
+	defer ResetRegistry()
+	assert.NoError(t, RegisterChainParams(&ChainParams{Params: params, BudgetCycleBlocks: 10}))
+	assert.True(t, IsSuperblock(params, 20))
+	assert.NoError(t, Unregister(params.Net))
+	assert.False(t, IsSuperblock(params, 20))
+}
+
+func TestTreasuryPayout(t *testing.T) {
+	output := func(value float64, index int64) *Output {
+		return &Output{
+			Value:        value,
+			Index:        index,
+			ScriptPubKey: &ScriptPubKey{Hex: "76a914", Type: "pubkeyhash"},
+		}
+	}
+	marker := &Output{Index: 0, ScriptPubKey: &ScriptPubKey{Type: "nonstandard"}}
+	coinbase := &Transaction{
+		Inputs:  []*Input{{Coinbase: "03"}},
+		Outputs: []*Output{output(0, 0)},
+	}
+
+	tests := map[string]struct {
+		block *Block
+
+		expectedTx     int
+		expectedOutput int64
+		expectedOk     bool
+	}{
+		"mined superblock": {
+			block: &Block{
+				Height: 144,
+				Txs: []*Transaction{
+					{
+						Inputs:  []*Input{{Coinbase: "03"}},
+						Outputs: []*Output{output(5, 0), output(100, 1)},
+					},
+				},
+			},
+			expectedTx:     0,
+			expectedOutput: 1,
+			expectedOk:     true,
+		},
+		"staked superblock": {
+			block: &Block{
+				Height: 288,
+				Txs: []*Transaction{
+					coinbase,
+					{
+						Inputs:  []*Input{{TxHash: "a1", Vout: 0}},
+						Outputs: []*Output{marker, output(10, 1), output(100, 2)},
+					},
+				},
+			},
+			expectedTx:     1,
+			expectedOutput: 2,
+			expectedOk:     true,
+		},
+		"superblock without payout": {
+			block: &Block{
+				Height: 288,
+				Txs: []*Transaction{
+					coinbase,
+					{
+						Inputs:  []*Input{{TxHash: "a1", Vout: 0}},
+						Outputs: []*Output{marker, output(10, 1)},
+					},
+				},
+			},
+		},
+		"other block": {
+			block: &Block{
+				Height: 145,
+				Txs: []*Transaction{
+					{
+						Inputs:  []*Input{{Coinbase: "03"}},
+						Outputs: []*Output{output(5, 0), output(100, 1)},
+					},
+				},
+			},
+		},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			txIndex, outputIndex, ok := TreasuryPayout(test.block, TestnetParams)
+			assert.Equal(t, test.expectedOk, ok)
+			assert.Equal(t, test.expectedTx, txIndex)
+			assert.Equal(t, test.expectedOutput, outputIndex)
+		})
+	}
+}
@@ -0,0 +1,93 @@
+// Copyright 2020 Coinbase, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package bitcoin
+
+import (
+	"errors"
+	"fmt"
+	"math/big"
+
+	"github.com/btcsuite/btcd/chaincfg/chainhash"
+)
+
+var (
+	// ErrInsufficientChainWork is returned when the chain
+	// of the node has less work than the MinimumChainWork
+	// of the network.
+	ErrInsufficientChainWork = errors.New("insufficient chain work")
+
+	// ErrMissingAssumeValid is returned when the chain
+	// of the node does not include the AssumeValidHash
+	// of the network.
+	ErrMissingAssumeValid = errors.New("chain does not include assume valid block")
+)
+
+// ChainRequirements are the requirements the chain served
+// by the node must meet before it is indexed (so that the
+// indexer refuses to follow a low-work chain served by a
+// malicious or broken node).
+type ChainRequirements struct {
+	// MinimumChainWork is the least total work
+	// of the best chain of the node (if nil,
+	// the work is not checked).
+	MinimumChainWork *big.Int
+
+	// AssumeValidHash is the hash of a block the
+	// best chain of the node must include (if nil,
+	// no block is required).
+	AssumeValidHash *chainhash.Hash
+}
+
+// ParseChainWork parses the hex-encoded total
+// work of a chain (as returned by the node).
+func ParseChainWork(chainWork string) (*big.Int, error) {
+	work, ok := new(big.Int).SetString(chainWork, 16) // nolint:gomnd
+	if !ok || work.Sign() < 0 {
+		return nil, fmt.Errorf("%s is not a valid chain work", chainWork)
+	}
+
+	return work, nil
+}
+
+// CheckChainWork returns ErrInsufficientChainWork if tip
+// (the best block of the node) has less total work than
+// MinimumChainWork.
+func (r *ChainRequirements) CheckChainWork(tip *Block) error {
+	if r.MinimumChainWork == nil {
+		return nil
+	}
+
+	work, err := ParseChainWork(tip.ChainWork)
+	if err != nil {
+		return fmt.Errorf(
+			"%w: block %d: %s",
+			ErrInsufficientChainWork,
+			tip.Height,
+			err.Error(),
+		)
+	}
+
+	if work.Cmp(r.MinimumChainWork) < 0 {
+		return fmt.Errorf(
+			"%w: block %d has chain work %x but %x is required",
+			ErrInsufficientChainWork,
+			tip.Height,
+			work,
+			r.MinimumChainWork,
+		)
+	}
+
+	return nil
+}
@@ -0,0 +1,62 @@
+// Copyright 2020 Coinbase, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package bitcoin
+
+import (
+	"errors"
+	"math/big"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestChainRequirements(t *testing.T) {
+	work, err := ParseChainWork("0000000000000000000000000000000000000000000000000000000100010001")
+	assert.NoError(t, err)
+	assert.Equal(t, int64(0x100010001), work.Int64())
+
+	_, err = ParseChainWork("")
+	assert.Error(t, err)
+	_, err = ParseChainWork("-10")
+	assert.Error(t, err)
+
+	requirements := &ChainRequirements{MinimumChainWork: big.NewInt(0x100)}
+	assert.NoError(t, requirements.CheckChainWork(&Block{Height: 10, ChainWork: "0100"}))
+	assert.NoError(t, requirements.CheckChainWork(&Block{Height: 11, ChainWork: "0101"}))
+
+	err = requirements.CheckChainWork(&Block{Height: 9, ChainWork: "ff"})
+	assert.True(t, errors.Is(err, ErrInsufficientChainWork))
+
+	// Nodes that don't report chain work
+	// don't meet the requirements.
+	err = requirements.CheckChainWork(&Block{Height: 9})
+	assert.True(t, errors.Is(err, ErrInsufficientChainWork))
+
+	// Without a minimum, the work is not checked
+	assert.NoError(t, (&ChainRequirements{}).CheckChainWork(&Block{Height: 9}))
+
+	defer ResetRegistry()
+	assert.Nil(t, MainnetChainParams.ChainRequirements)
+	settings := *MainnetChainParams
+	settings.ChainRequirements = requirements
+	assert.NoError(t, RegisterChainParams(&settings))
+	registered, err := ChainParamsForNet(MainnetParams.Net)
+	assert.NoError(t, err)
+	assert.Equal(t, requirements, registered.ChainRequirements)
+	ResetRegistry()
+	registered, err = ChainParamsForNet(MainnetParams.Net)
+	assert.NoError(t, err)
+	assert.Nil(t, registered.ChainRequirements)
+}
@@ -0,0 +1,93 @@
+// Copyright 2020 Coinbase, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package bitcoin
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/btcsuite/btcd/chaincfg"
+	"github.com/btcsuite/btcd/chaincfg/chainhash"
+)
+
+var (
+	// ErrCheckpointMismatch is returned when a block
+	// conflicts with a checkpoint (i.e. the node is
+	// following a fork).
+	ErrCheckpointMismatch = errors.New("block does not match checkpoint")
+)
+
+// LatestCheckpoint returns the highest checkpoint of
+// the network with params (or nil if it has none).
+func LatestCheckpoint(params *chaincfg.Params) *chaincfg.Checkpoint {
+	var latest *chaincfg.Checkpoint
+	for i := range params.Checkpoints {
+		checkpoint := &params.Checkpoints[i]
+		if latest == nil || checkpoint.Height > latest.Height {
+			latest = checkpoint
+		}
+	}
+
+	return latest
+}
+
+// CheckpointAtHeight returns the checkpoint of the network
+// with params at height (or nil if there is none).
+func CheckpointAtHeight(params *chaincfg.Params, height int64) *chaincfg.Checkpoint {
+	for i := range params.Checkpoints {
+		if int64(params.Checkpoints[i].Height) == height {
+			return &params.Checkpoints[i]
+		}
+	}
+
+	return nil
+}
+
+// VerifyAgainstCheckpoints returns ErrCheckpointMismatch if
+// the network with params has a checkpoint at height with
+// a hash other than hash.
+func VerifyAgainstCheckpoints(params *chaincfg.Params, height int64, hash string) error {
+	checkpoint := CheckpointAtHeight(params, height)
+	if checkpoint == nil || checkpoint.Hash.String() == hash {
+		return nil
+	}
+
+	return fmt.Errorf(
+		"%w: block %d has hash %s but checkpoint has %s",
+		ErrCheckpointMismatch,
+		height,
+		hash,
+		checkpoint.Hash.String(),
+	)
+}
+
+// parseCheckpoint returns the chaincfg.Checkpoint
+// of a checkpoint of a params file.
+func parseCheckpoint(checkpoint *ParamsCheckpoint) (chaincfg.Checkpoint, error) {
+	hash, err := chainhash.NewHashFromStr(checkpoint.Hash)
+	if err != nil || len(checkpoint.Hash) != chainhash.MaxHashStringSize || checkpoint.Height < 0 {
+		return chaincfg.Checkpoint{}, fmt.Errorf(
+			"%d:%s is not a valid checkpoint",
+			checkpoint.Height,
+			checkpoint.Hash,
+		)
+	}
+
+	return chaincfg.Checkpoint{
+		Height: checkpoint.Height,
+		Hash:   hash,
+	}, nil
+}
+
@@ -0,0 +1,65 @@
+// Copyright 2020 Coinbase, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package bitcoin
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCheckpoints(t *testing.T) {
+	// The upstream checkpoints are
+	// not checkpoints of this chain.
+	assert.Nil(t, LatestCheckpoint(MainnetParams))
+	assert.Nil(t, LatestCheckpoint(TestnetParams))
+	assert.NoError(t, VerifyAgainstCheckpoints(MainnetParams, 11111, "hash"))
+
+	params, err := CreateParams(&ParamsFile{
+		Name:         "checkpoints",
+		GenesisHash:  MainnetGenesisBlockIdentifier.Hash,
+		MessageStart: "a1b2c3e2",
+		Checkpoints: []*ParamsCheckpoint{
+			{Height: 100, Hash: "0000000000000000000000000000000000000000000000000000000000000100"},
+			{Height: 200, Hash: "0000000000000000000000000000000000000000000000000000000000000200"},
+			{Height: 150, Hash: "0000000000000000000000000000000000000000000000000000000000000150"},
+		},
+	})
+	assert.NoError(t, err)
+
+	latest := LatestCheckpoint(params)
+	assert.Equal(t, int32(200), latest.Height)
+	assert.Equal(t, int32(150), CheckpointAtHeight(params, 150).Height)
+	assert.Nil(t, CheckpointAtHeight(params, 151))
+
+	assert.NoError(t, VerifyAgainstCheckpoints(
+		params,
+		100,
+		"0000000000000000000000000000000000000000000000000000000000000100",
+	))
+	assert.NoError(t, VerifyAgainstCheckpoints(params, 101, "fork"))
+	err = VerifyAgainstCheckpoints(params, 100, "fork")
+	assert.True(t, errors.Is(err, ErrCheckpointMismatch))
+
+	_, err = CreateParams(&ParamsFile{
+		Name:         "checkpoints",
+		GenesisHash:  MainnetGenesisBlockIdentifier.Hash,
+		MessageStart: "a1b2c3e2",
+		Checkpoints:  []*ParamsCheckpoint{{Height: 100, Hash: "1234"}},
+	})
+	assert.True(t, errors.Is(err, ErrInvalidParams))
+}
+
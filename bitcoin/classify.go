@@ -0,0 +1,119 @@
+// Copyright 2020 Coinbase, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package bitcoin
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/btcsuite/btcd/chaincfg"
+	"github.com/btcsuite/btcutil"
+)
+
+// Classes of the addresses returned by ClassifyAddress.
+const (
+	P2PKHAddressClass  = "p2pkh"
+	P2SHAddressClass   = "p2sh"
+	P2CSAddressClass   = "p2cs"
+	P2WPKHAddressClass = "p2wpkh"
+	P2WSHAddressClass  = "p2wsh"
+)
+
+var (
+	// ErrUnknownAddress is returned when an address
+	// can't be decoded for any registered network.
+	ErrUnknownAddress = errors.New("address of no registered network")
+
+	// ErrAmbiguousAddress is returned when an address
+	// is valid for several registered networks (i.e.
+	// networks sharing their address prefixes).
+	ErrAmbiguousAddress = errors.New("address of several registered networks")
+)
+
+// AddressClassification is the class of an address
+// and the registered network it belongs to.
+type AddressClassification struct {
+	Class  string
+	Params *chaincfg.Params
+}
+
+// ClassifyAddress returns the class of address (one of the
+// *AddressClass constants) and the network it belongs to,
+// trying the address prefixes (and staking address IDs) of
+// every registered network. ErrAmbiguousAddress is returned
+// if address is valid for several registered networks.
+func ClassifyAddress(address string) (*AddressClassification, error) {
+	// The registry is copied so that the staking address
+	// versions (which are looked up in the registry) can be
+	// read without holding registryMutex twice.
+	registryMutex.RLock()
+	networks := make([]*chaincfg.Params, 0, len(registry))
+	for _, params := range registry {
+		networks = append(networks, params.Params)
+	}
+	registryMutex.RUnlock()
+
+	var match *AddressClassification
+	for _, params := range networks {
+		class, ok := classifyForNet(address, params)
+		if !ok {
+			continue
+		}
+
+		if match != nil {
+			return nil, fmt.Errorf(
+				"%w: %s is valid for %s and %s",
+				ErrAmbiguousAddress,
+				address,
+				match.Params.Name,
+				params.Name,
+			)
+		}
+
+		match = &AddressClassification{Class: class, Params: params}
+	}
+
+	if match == nil {
+		return nil, fmt.Errorf("%w: %s", ErrUnknownAddress, address)
+	}
+
+	return match, nil
+}
+
+// classifyForNet returns the class of address if it is
+// an address of the network with params.
+func classifyForNet(address string, params *chaincfg.Params) (string, bool) {
+	if _, err := DecodeStakingAddress(address, params); err == nil {
+		return P2CSAddressClass, true
+	}
+
+	decoded, err := DecodeAddress(address, params)
+	if err != nil {
+		return "", false
+	}
+
+	switch decoded.(type) {
+	case *btcutil.AddressPubKeyHash:
+		return P2PKHAddressClass, true
+	case *btcutil.AddressScriptHash:
+		return P2SHAddressClass, true
+	case *btcutil.AddressWitnessPubKeyHash:
+		return P2WPKHAddressClass, true
+	case *btcutil.AddressWitnessScriptHash:
+		return P2WSHAddressClass, true
+	default:
+		return "", false
+	}
+}
@@ -0,0 +1,99 @@
+// Copyright 2020 Coinbase, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package bitcoin
+
+import (
+	"encoding/hex"
+	"errors"
+	"testing"
+
+	"github.com/btcsuite/btcd/chaincfg"
+	"github.com/btcsuite/btcd/wire"
+	"github.com/btcsuite/btcutil"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestClassifyAddress(t *testing.T) {
+	hash, err := hex.DecodeString("c398efa9c392ba6013c5e04ee729755ef7f58b32")
+	assert.NoError(t, err)
+	scriptHash, err := btcutil.NewAddressScriptHashFromHash(hash, MainnetParams)
+	assert.NoError(t, err)
+
+	tests := map[string]struct {
+		address string
+
+		class  string
+		params *chaincfg.Params
+	}{
+		"P2PKH": {
+			address: "ELJeB54eV9QQt6TvMiYx1b678jeHVeAtKr",
+			class:   P2PKHAddressClass,
+			params:  MainnetParams,
+		},
+		"P2SH": {
+			address: scriptHash.EncodeAddress(),
+			class:   P2SHAddressClass,
+			params:  MainnetParams,
+		},
+		"P2CS": {
+			address: "Sf8E1SYBWseRuA5NuQZk9Lg6YEyWHh7H6Y",
+			class:   P2CSAddressClass,
+			params:  MainnetParams,
+		},
+		"P2WPKH": {
+			address: "teuno1qcqzmqzkswhfshzd8kedhmtvgnxax48z4pnvvd3",
+			class:   P2WPKHAddressClass,
+			params:  TestnetParams,
+		},
+		"P2WSH": {
+			address: "euno1qqqqsyqcyq5rqwzqfpg9scrgwpugpzysnzs23v9ccrydpk8qarc0sydt6lt",
+			class:   P2WSHAddressClass,
+			params:  MainnetParams,
+		},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			classification, err := ClassifyAddress(test.address)
+			assert.NoError(t, err)
+			assert.Equal(t, test.class, classification.Class)
+			assert.Equal(t, test.params, classification.Params)
+		})
+	}
+
+	for _, address := range []string{
+		"",
+		"bc1qw508d6qejxtdg4y5r3zarvary0c5xw7kv8f3t4",
+		"EH9uVaqWRxHuzJbroqzX18yxmeW8XVJyV8",
+	} {
+		_, err := ClassifyAddress(address)
+		assert.True(t, errors.Is(err, ErrUnknownAddress))
+	}
+
+	// Networks sharing prefixes can't be told apart
+	defer ResetRegistry()
+	custom := *MainnetParams
+	custom.Name = "custom"
+	custom.Net = wire.BitcoinNet(0xa1b2c3f3)
+	custom.Bech32HRPSegwit = "cust"
+	assert.NoError(t, Register(&custom))
+
+	_, err = ClassifyAddress("ELJeB54eV9QQt6TvMiYx1b678jeHVeAtKr")
+	assert.True(t, errors.Is(err, ErrAmbiguousAddress))
+
+	classification, err := ClassifyAddress("euno1qqqqsyqcyq5rqwzqfpg9scrgwpugpzysnzs23v9ccrydpk8qarc0sydt6lt")
+	assert.NoError(t, err)
+	assert.Equal(t, MainnetParams, classification.Params)
+}
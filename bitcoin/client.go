@@ -22,14 +22,18 @@ import (
 	"errors"
 	"fmt"
 	"io/ioutil"
+	"math/big"
 	"net"
 	"net/http"
-	"strconv"
+	"sync"
+	"sync/atomic"
 	"time"
 
-	"github.com/btcsuite/btcutil"
+	"github.com/MNtank/rosetta-bitcoin/utils"
+
+	"github.com/btcsuite/btcd/chaincfg"
 	"github.com/coinbase/rosetta-sdk-go/types"
-	"github.com/coinbase/rosetta-sdk-go/utils"
+	sdkUtils "github.com/coinbase/rosetta-sdk-go/utils"
 )
 
 const (
@@ -58,6 +62,9 @@ const (
 	// https://bitcoin.org/en/developer-reference#getblockchaininfo
 	requestMethodGetBlockchainInfo requestMethod = "getblockchaininfo"
 
+	// https://developer.bitcoin.org/reference/rpc/getnetworkinfo.html
+	requestMethodGetNetworkInfo requestMethod = "getnetworkinfo"
+
 	// https://developer.bitcoin.org/reference/rpc/getpeerinfo.html
 	requestMethodGetPeerInfo requestMethod = "getpeerinfo"
 
@@ -70,8 +77,52 @@ const (
 	// https://developer.bitcoin.org/reference/rpc/getrawmempool.html
 	requestMethodRawMempool requestMethod = "getrawmempool"
 
+	// https://developer.bitcoin.org/reference/rpc/getmempoolentry.html
+	requestMethodGetMempoolEntry requestMethod = "getmempoolentry"
+
+	// https://developer.bitcoin.org/reference/rpc/getrawtransaction.html
+	requestMethodGetRawTransaction requestMethod = "getrawtransaction"
+
+	// https://developer.bitcoin.org/reference/rpc/invalidateblock.html
+	requestMethodInvalidateBlock requestMethod = "invalidateblock"
+
+	// https://developer.bitcoin.org/reference/rpc/reconsiderblock.html
+	requestMethodReconsiderBlock requestMethod = "reconsiderblock"
+
+	// https://developer.bitcoin.org/reference/rpc/generatetoaddress.html
+	requestMethodGenerateToAddress requestMethod = "generatetoaddress"
+
+	// https://developer.bitcoin.org/reference/rpc/listbanned.html
+	requestMethodListBanned requestMethod = "listbanned"
+
+	// https://developer.bitcoin.org/reference/rpc/setban.html
+	requestMethodSetBan requestMethod = "setban"
+
+	// https://developer.bitcoin.org/reference/rpc/disconnectnode.html
+	requestMethodDisconnectNode requestMethod = "disconnectnode"
+
 	// blockNotFoundErrCode is the RPC error code when a block cannot be found
 	blockNotFoundErrCode = -5
+
+	// transactionNotInMempoolErrCode is the RPC error code
+	// when a transaction is not in the mempool
+	transactionNotInMempoolErrCode = -5
+
+	// blockPrunedErrCode and blockPrunedErrMessage identify
+	// the RPC error returned when the node has pruned a block
+	// (it has the header but no longer has the body).
+	blockPrunedErrCode    = -1
+	blockPrunedErrMessage = "pruned data"
+
+	// prevoutVerbosity is the verbosity of getblock at
+	// which the node returns the output spent by each
+	// input (see Input.Prevout).
+	prevoutVerbosity = 3
+
+	// setBanAdd and setBanRemove are the
+	// commands of `setban` requests.
+	setBanAdd    = "add"
+	setBanRemove = "remove"
 )
 
 const (
@@ -95,10 +146,52 @@ var (
 	// cannot be found by the node
 	ErrBlockNotFound = errors.New("unable to find block")
 
+	// ErrBlockPruned is returned when the requested block
+	// has been pruned by the node (and by all archive nodes)
+	ErrBlockPruned = errors.New("block pruned")
+
+	// ErrTransactionNotInMempool is returned when the requested
+	// transaction is not in the mempool of the node
+	ErrTransactionNotInMempool = errors.New("transaction not in mempool")
+
 	// ErrJSONRPCError is returned when receiving an error from a JSON-RPC response
 	ErrJSONRPCError = errors.New("JSON-RPC error")
+
+	// ErrPreviousTransactionMissing is returned when the coin spent
+	// by an input was not provided to ParseBlock.
+	ErrPreviousTransactionMissing = errors.New("error finding previous tx")
+
+	// ErrPreviousTransactionUnparseable is returned when the coin spent
+	// by an input was created by an unparseable transaction.
+	ErrPreviousTransactionUnparseable = errors.New("previous tx is unparseable")
 )
 
+// init registers the types and statuses of the
+// operations returned by the parser (see
+// RegisterOperationType).
+func init() {
+	for _, opType := range []string{
+		InputOpType,
+		OutputOpType,
+		DelegationOpType,
+		StakeInputOpType,
+		StakeRewardOpType,
+		MasternodeRewardOpType,
+		ZerocoinMintOpType,
+		ZerocoinSpendOpType,
+		TreasuryPayoutOpType,
+		BurnOpType,
+		CoinbaseOpType,
+		GenesisAllocationOpType,
+		UnparseableOpType,
+	} {
+		RegisterOperationType(opType)
+	}
+
+	RegisterOperationStatus(SuccessStatus, true)
+	RegisterOperationStatus(SkippedStatus, false)
+}
+
 // Client is used to fetch blocks from bitcoind and
 // to parse Bitcoin block data into Rosetta types.
 //
@@ -109,9 +202,33 @@ type Client struct {
 	baseURL string
 
 	genesisBlockIdentifier *types.BlockIdentifier
+	genesisAllocations     []*GenesisAllocation
 	currency               *types.Currency
 
 	httpClient *http.Client
+
+	// archiveURLs are the RPC URLs of the nodes
+	// blocks pruned by the node are fetched from.
+	archiveURLs      []string
+	archiveURLsMutex sync.Mutex
+
+	// params are the params of the network, used to
+	// attribute masternode collateral outputs to the
+	// CollateralAccount of their owner (if set).
+	params *chaincfg.Params
+
+	// limits are the bounds on the blocks
+	// returned by the node (if set).
+	limits *Limits
+
+	// network is the network the metrics
+	// of the client are reported under.
+	network *types.NetworkIdentifier
+
+	// prevoutsUnsupported is set (to 1) once the node
+	// rejects getblock with prevoutVerbosity, so blocks
+	// are no longer requested with prevouts.
+	prevoutsUnsupported int32
 }
 
 // LocalhostURL returns the URL to use
@@ -124,16 +241,48 @@ func LocalhostURL(rpcPort int) string {
 func NewClient(
 	baseURL string,
 	genesisBlockIdentifier *types.BlockIdentifier,
+	genesisAllocations []*GenesisAllocation,
 	currency *types.Currency,
 ) *Client {
 	return &Client{
 		baseURL:                baseURL,
 		genesisBlockIdentifier: genesisBlockIdentifier,
+		genesisAllocations:     genesisAllocations,
 		currency:               currency,
 		httpClient:             newHTTPClient(defaultTimeout),
 	}
 }
 
+// UseParams attributes the masternode collateral outputs of
+// the network with params to the CollateralAccount of their
+// owner (see IsMasternodeCollateral). It must be called before
+// any block is parsed.
+func (b *Client) UseParams(params *chaincfg.Params) {
+	b.params = params
+}
+
+// UseLimits rejects blocks returned by the node (or read
+// from the block files or archive nodes) that exceed limits.
+func (b *Client) UseLimits(limits *Limits) {
+	b.limits = limits
+}
+
+// UseNetwork reports the metrics of the client
+// under network (so that the clients of each
+// network served by a process are told apart).
+func (b *Client) UseNetwork(network *types.NetworkIdentifier) {
+	b.network = network
+}
+
+// UseArchiveNodes fetches blocks the node has pruned
+// from the archive nodes at urls (in order).
+func (b *Client) UseArchiveNodes(urls []string) {
+	b.archiveURLsMutex.Lock()
+	defer b.archiveURLsMutex.Unlock()
+
+	b.archiveURLs = urls
+}
+
 // newHTTPClient returns a new HTTP client
 func newHTTPClient(timeout time.Duration) *http.Client {
 	var netTransport = &http.Transport{
@@ -221,9 +370,15 @@ func (b *Client) GetRawBlock(
 				continue
 			}
 
+			// If the node returned the output spent by the input,
+			// there is no need to backfill it from the coin index.
+			if input.Prevout != nil {
+				continue
+			}
+
 			// If any transactions spent in the same block they are created, don't include them
 			// in previousTxHashes to fetch.
-			if !utils.ContainsString(blockTxHashes, txHash) {
+			if !sdkUtils.ContainsString(blockTxHashes, txHash) {
 				coins = append(coins, CoinIdentifier(txHash, vout))
 			}
 		}
@@ -249,11 +404,42 @@ func (b *Client) ParseBlock(
 		return nil, err
 	}
 
+	if block.Height == genesisBlockIndex && len(b.genesisAllocations) > 0 {
+		txs = append(txs, b.genesisAllocationsTransaction())
+	}
+
 	rblock.Transactions = txs
 
 	return rblock, nil
 }
 
+// genesisAllocationsTransaction returns a synthetic transaction
+// that credits each configured *GenesisAllocation. Genesis outputs
+// can never be spent, so these operations do not create coins.
+func (b *Client) genesisAllocationsTransaction() *types.Transaction {
+	ops := make([]*types.Operation, len(b.genesisAllocations))
+	for i, allocation := range b.genesisAllocations {
+		ops[i] = &types.Operation{
+			OperationIdentifier: &types.OperationIdentifier{
+				Index: int64(i),
+			},
+			Type:   GenesisAllocationOpType,
+			Status: types.String(SuccessStatus),
+			Account: &types.AccountIdentifier{
+				Address: allocation.Address,
+			},
+			Amount: Amount(allocation.Value, b.currency),
+		}
+	}
+
+	return &types.Transaction{
+		TransactionIdentifier: &types.TransactionIdentifier{
+			Hash: GenesisAllocationsTransactionHash,
+		},
+		Operations: ops,
+	}
+}
+
 // SendRawTransaction submits a serialized transaction
 // to bitcoind.
 func (b *Client) SendRawTransaction(
@@ -308,6 +494,163 @@ func (b *Client) RawMempool(
 	return response.Result, nil
 }
 
+// MempoolEntry returns the mempool data (size, fees, and
+// ancestor/descendant stats) of a transaction in the mempool.
+func (b *Client) MempoolEntry(
+	ctx context.Context,
+	hash string,
+) (*MempoolEntry, error) {
+	// Parameters:
+	//   1. txid
+	params := []interface{}{hash}
+
+	response := &mempoolEntryResponse{}
+	if err := b.post(ctx, requestMethodGetMempoolEntry, params, response); err != nil {
+		return nil, fmt.Errorf("%w: error getting mempool entry", err)
+	}
+
+	return response.Result, nil
+}
+
+// MempoolTransaction returns the parsed transaction with hash
+// from the mempool (see ParseTransaction). It returns
+// ErrTransactionNotInMempool if the node does not have it.
+func (b *Client) MempoolTransaction(
+	ctx context.Context,
+	hash string,
+) (*types.Transaction, error) {
+	// Parameters:
+	//   1. txid
+	//   2. verbose
+	params := []interface{}{hash, true}
+
+	response := &rawTransactionResponse{}
+	if err := b.post(ctx, requestMethodGetRawTransaction, params, response); err != nil {
+		return nil, fmt.Errorf("%w: error getting raw transaction", err)
+	}
+
+	return b.ParseTransaction(response.Result)
+}
+
+// InvalidateBlock marks the block with hash (and its
+// descendants) as invalid, so the node reorgs to the
+// best chain without it.
+func (b *Client) InvalidateBlock(
+	ctx context.Context,
+	hash string,
+) error {
+	// Parameters:
+	//   1. blockhash
+	params := []interface{}{hash}
+
+	response := &invalidateBlockResponse{}
+	if err := b.post(ctx, requestMethodInvalidateBlock, params, response); err != nil {
+		return fmt.Errorf("%w: error invalidating block %s", err, hash)
+	}
+
+	return nil
+}
+
+// ReconsiderBlock removes the invalidity status of the
+// block with hash (and its descendants), undoing
+// InvalidateBlock, so the node reorgs back to it if it
+// is on the best chain.
+func (b *Client) ReconsiderBlock(
+	ctx context.Context,
+	hash string,
+) error {
+	// Parameters:
+	//   1. blockhash
+	params := []interface{}{hash}
+
+	response := &reconsiderBlockResponse{}
+	if err := b.post(ctx, requestMethodReconsiderBlock, params, response); err != nil {
+		return fmt.Errorf("%w: error reconsidering block %s", err, hash)
+	}
+
+	return nil
+}
+
+// GenerateToAddress mines blocks (immediately, so it is only
+// supported on networks where anyone can mine a block) paying
+// their coinbase to address and returns their hashes.
+func (b *Client) GenerateToAddress(
+	ctx context.Context,
+	blocks int64,
+	address string,
+) ([]string, error) {
+	// Parameters:
+	//   1. nblocks
+	//   2. address
+	params := []interface{}{blocks, address}
+
+	response := &generateToAddressResponse{}
+	if err := b.post(ctx, requestMethodGenerateToAddress, params, response); err != nil {
+		return nil, fmt.Errorf("%w: error generating blocks", err)
+	}
+
+	return response.Result, nil
+}
+
+// ListBanned returns the subnets banned by the node.
+func (b *Client) ListBanned(ctx context.Context) ([]*BannedSubnet, error) {
+	params := []interface{}{}
+	response := &listBannedResponse{}
+	if err := b.post(ctx, requestMethodListBanned, params, response); err != nil {
+		return nil, fmt.Errorf("%w: error listing banned subnets", err)
+	}
+
+	return response.Result, nil
+}
+
+// BanPeer bans subnet (an IP address, with an optional
+// netmask) for banTime seconds (or the default ban time
+// of the node if banTime is 0), disconnecting its peers.
+func (b *Client) BanPeer(ctx context.Context, subnet string, banTime int64) error {
+	// Parameters:
+	//   1. subnet
+	//   2. command
+	//   3. bantime
+	params := []interface{}{subnet, setBanAdd, banTime}
+
+	response := &setBanResponse{}
+	if err := b.post(ctx, requestMethodSetBan, params, response); err != nil {
+		return fmt.Errorf("%w: error banning %s", err, subnet)
+	}
+
+	return nil
+}
+
+// UnbanPeer removes the ban of subnet.
+func (b *Client) UnbanPeer(ctx context.Context, subnet string) error {
+	// Parameters:
+	//   1. subnet
+	//   2. command
+	params := []interface{}{subnet, setBanRemove}
+
+	response := &setBanResponse{}
+	if err := b.post(ctx, requestMethodSetBan, params, response); err != nil {
+		return fmt.Errorf("%w: error unbanning %s", err, subnet)
+	}
+
+	return nil
+}
+
+// DisconnectPeer disconnects the peer at address
+// (a "host:port" peer ID, as returned by GetPeers).
+func (b *Client) DisconnectPeer(ctx context.Context, address string) error {
+	// Parameters:
+	//   1. address
+	params := []interface{}{address}
+
+	response := &disconnectNodeResponse{}
+	if err := b.post(ctx, requestMethodDisconnectNode, params, response); err != nil {
+		return fmt.Errorf("%w: error disconnecting %s", err, address)
+	}
+
+	return nil
+}
+
 // getPeerInfo performs the `getpeerinfo` JSON-RPC request
 func (b *Client) getPeerInfo(
 	ctx context.Context,
@@ -321,10 +664,41 @@ func (b *Client) getPeerInfo(
 	return response.Result, nil
 }
 
-// getBlock returns a Block for the specified identifier
+// getBlock returns a Block for the specified identifier,
+// rejecting blocks that exceed the limits of the client.
 func (b *Client) getBlock(
 	ctx context.Context,
 	identifier *types.PartialBlockIdentifier,
+) (*Block, error) {
+	block, err := b.fetchBlock(ctx, identifier)
+	if err != nil {
+		return nil, err
+	}
+
+	if b.limits == nil {
+		return block, nil
+	}
+
+	if err := b.limits.CheckBlock(block); err != nil {
+		// A block exceeding the limits is most likely crafted
+		// by a compromised node, so it is reported loudly.
+		rejectedBlocksMetric.Add(utils.NetworkMetricsKey(b.network), 1)
+		utils.ExtractLogger(ctx, "client").Errorw(
+			"rejected block returned by node",
+			"hash", block.Hash,
+			"height", block.Height,
+			"error", err,
+		)
+		return nil, fmt.Errorf("%w: block %s", err, block.Hash)
+	}
+
+	return block, nil
+}
+
+// fetchBlock fetches a Block for the specified identifier
+func (b *Client) fetchBlock(
+	ctx context.Context,
+	identifier *types.PartialBlockIdentifier,
 ) (*Block, error) {
 	hash, err := b.getBlockHash(ctx, identifier)
 	if err != nil {
@@ -335,18 +709,119 @@ func (b *Client) getBlock(
 	//   1. Block hash (string, required)
 	//   2. Verbosity (integer, optional, default=1)
 	// https://bitcoin.org/en/developer-reference#getblock
+	//
+	// The block is requested with prevouts, so its inputs
+	// need not be backfilled from the coin index.
+	withPrevouts := atomic.LoadInt32(&b.prevoutsUnsupported) == 0
 	params := []interface{}{hash}
+	if withPrevouts {
+		params = append(params, prevoutVerbosity)
+	}
 
 	response := &blockResponse{}
-	if err := b.post(ctx, requestMethodGetBlock, params, response); err != nil {
+	err = b.post(ctx, requestMethodGetBlock, params, response)
+
+	// Nodes based on PIVX take a verbose flag instead of a
+	// verbosity (and reject prevoutVerbosity), in which case
+	// the block is requested again without prevouts (and
+	// prevouts are no longer requested from the node).
+	if withPrevouts && errors.Is(err, ErrJSONRPCError) {
+		response = &blockResponse{}
+		err = b.post(ctx, requestMethodGetBlock, []interface{}{hash}, response)
+		if err == nil {
+			atomic.StoreInt32(&b.prevoutsUnsupported, 1)
+		}
+	}
+
+	// The node may prune a block between returning its
+	// hash and its body, in which case it is fetched
+	// from an archive node.
+	if errors.Is(err, ErrBlockPruned) {
+		var height *int64
+		if identifier != nil {
+			height = identifier.Index
+		}
+
+		return b.getBlockFromArchive(ctx, hash, height, err)
+	}
+	if err != nil {
 		return nil, fmt.Errorf("%w: error fetching block by hash %s", err, hash)
 	}
 
 	return response.Result, nil
 }
 
-// getBlockchainInfo performs the `getblockchaininfo` JSON-RPC request
-func (b *Client) getBlockchainInfo(
+// getBlockFromArchive fetches the block with hash (pruned by
+// the node with prunedErr) from the first archive node that
+// has it. Archive nodes returning another block (or the block
+// at another height, if known) are skipped.
+func (b *Client) getBlockFromArchive(
+	ctx context.Context,
+	hash string,
+	height *int64,
+	prunedErr error,
+) (*Block, error) {
+	b.archiveURLsMutex.Lock()
+	archiveURLs := b.archiveURLs
+	b.archiveURLsMutex.Unlock()
+
+	logger := utils.ExtractLogger(ctx, "client")
+	err := prunedErr
+	for j, archiveURL := range archiveURLs {
+		response := &blockResponse{}
+		err = b.postTo(ctx, archiveURL, requestMethodGetBlock, []interface{}{hash}, response)
+		if err == nil {
+			err = checkArchiveBlock(response.Result, hash, height)
+		}
+		if err == nil {
+			logger.Infow("fetched pruned block from archive node", "hash", hash, "archive", j)
+			return response.Result, nil
+		}
+
+		// The URLs of archive nodes contain credentials,
+		// so they are identified by their position.
+		logger.Warnw(
+			"unable to fetch pruned block from archive node",
+			"hash", hash,
+			"archive", j,
+			"error", err,
+		)
+	}
+
+	// If every archive node pruned the block, report it as
+	// pruned (and not as the last archive error) so that
+	// the caller can tell pruning apart from failures.
+	if len(archiveURLs) == 0 || errors.Is(err, ErrBlockPruned) {
+		err = prunedErr
+	}
+
+	return nil, fmt.Errorf("%w: error fetching block by hash %s", err, hash)
+}
+
+// checkArchiveBlock returns ErrBlockPruned if block (returned
+// by an archive node) is not the block with hash (at height,
+// if known), so that a misconfigured archive node (i.e. of
+// another network) is treated as if it pruned the block.
+func checkArchiveBlock(block *Block, hash string, height *int64) error {
+	switch {
+	case block == nil:
+		return fmt.Errorf("%w: archive node returned no block", ErrBlockPruned)
+	case block.Hash != hash:
+		return fmt.Errorf("%w: archive node returned block %s", ErrBlockPruned, block.Hash)
+	case height != nil && block.Height != *height:
+		return fmt.Errorf(
+			"%w: archive node returned block at height %d instead of %d",
+			ErrBlockPruned,
+			block.Height,
+			*height,
+		)
+	}
+
+	return nil
+}
+
+// GetBlockchainInfo performs the `getblockchaininfo` JSON-RPC request
+func (b *Client) GetBlockchainInfo(
 	ctx context.Context,
 ) (*BlockchainInfo, error) {
 	params := []interface{}{}
@@ -358,6 +833,46 @@ func (b *Client) getBlockchainInfo(
 	return response.Result, nil
 }
 
+// GetNetworkInfo performs the `getnetworkinfo` JSON-RPC request
+func (b *Client) GetNetworkInfo(
+	ctx context.Context,
+) (*NetworkInfo, error) {
+	params := []interface{}{}
+	response := &networkInfoResponse{}
+	if err := b.post(ctx, requestMethodGetNetworkInfo, params, response); err != nil {
+		return nil, fmt.Errorf("%w: unable to get network info", err)
+	}
+
+	return response.Result, nil
+}
+
+// NodeNetwork returns the *NodeNetwork the node serves (from
+// `getblockchaininfo`, `getnetworkinfo`, and the hash of
+// its genesis block).
+func (b *Client) NodeNetwork(ctx context.Context) (*NodeNetwork, error) {
+	blockchainInfo, err := b.GetBlockchainInfo(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	networkInfo, err := b.GetNetworkInfo(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	genesisHash, err := b.getHashFromIndex(ctx, genesisBlockIndex)
+	if err != nil {
+		return nil, err
+	}
+
+	return &NodeNetwork{
+		Chain:           blockchainInfo.Chain,
+		GenesisHash:     genesisHash,
+		SubVersion:      networkInfo.SubVersion,
+		ProtocolVersion: networkInfo.ProtocolVersion,
+	}, nil
+}
+
 // getBlockHash returns the hash for a specified block identifier.
 // If the identifier includes a hash it will return that hash.
 // If the identifier only includes an index, if will fetch the hash that corresponds to
@@ -368,7 +883,7 @@ func (b *Client) getBlockHash(
 ) (string, error) {
 	// Lookup best block if no PartialBlockIdentifier provided.
 	if identifier == nil || (identifier.Hash == nil && identifier.Index == nil) {
-		info, err := b.getBlockchainInfo(ctx)
+		info, err := b.GetBlockchainInfo(ctx)
 		if err != nil {
 			return "", fmt.Errorf("%w: unable to get blockchain info", err)
 		}
@@ -454,31 +969,54 @@ func (b *Client) parseTransactions(
 	}
 
 	txs := make([]*types.Transaction, len(block.Txs))
+	payoutTx, payoutOutput, payout := TreasuryPayout(block, b.params)
+	masternodeTx, masternodeOutput, masternode := MasternodePayment(block, b.params)
 
 	for index, transaction := range block.Txs {
-		txOps, err := b.parseTxOperations(transaction, index, coins)
+		tx, err := b.parseTransaction(transaction, index, coins)
 		if err != nil {
-			return nil, fmt.Errorf("%w: error parsing transaction operations", err)
+			return nil, err
 		}
 
-		metadata, err := transaction.Metadata()
-		if err != nil {
-			return nil, fmt.Errorf("%w: unable to get metadata for transaction", err)
+		if payout && index == payoutTx {
+			markTreasuryPayout(tx, payoutOutput)
 		}
 
-		tx := &types.Transaction{
-			TransactionIdentifier: &types.TransactionIdentifier{
-				Hash: transaction.Hash,
-			},
-			Operations: txOps,
-			Metadata:   metadata,
+		// The coinstake is the second transaction
+		// of staked blocks.
+		if index == 1 && isCoinStake(transaction) {
+			markCoinStake(tx)
+		}
+
+		if masternode && index == masternodeTx {
+			markMasternodeReward(tx, masternodeOutput)
+		}
+
+		// The reward of the staker excludes the masternode
+		// payment (so it is added once that is marked).
+		if index == 1 && isCoinStake(transaction) {
+			if err := b.addStakeReward(tx); err != nil {
+				return nil, err
+			}
 		}
 
 		txs[index] = tx
 
+		// Without coins (when decoding offline), inputs
+		// are parsed without the outputs they spend.
+		if coins == nil {
+			continue
+		}
+
 		// In some cases, a transaction will spent an output
-		// from the same block.
+		// from the same block. Outputs behind a placeholder
+		// are mapped to nil (so spending them is unparseable).
 		for _, op := range tx.Operations {
+			if op.Type == UnparseableOpType && op.OperationIdentifier.NetworkIndex != nil {
+				coins[CoinIdentifier(transaction.Hash, *op.OperationIdentifier.NetworkIndex)] = nil
+				continue
+			}
+
 			if op.CoinChange == nil {
 				continue
 			}
@@ -500,9 +1038,226 @@ func (b *Client) parseTransactions(
 	return txs, nil
 }
 
-// parseTransactions returns the transaction operations for a specified transaction.
+// markCoinStake changes the type of the inputs of tx (a
+// coinstake) to StakeInputOpType and the type of its outputs
+// to StakeRewardOpType (except a treasury payout, see
+// markTreasuryPayout).
+func markCoinStake(tx *types.Transaction) {
+	for _, op := range tx.Operations {
+		switch op.Type {
+		case InputOpType:
+			op.Type = StakeInputOpType
+		case OutputOpType, DelegationOpType:
+			op.Type = StakeRewardOpType
+		}
+	}
+}
+
+// addStakeReward adds the net reward of the staker of tx (a
+// coinstake) to its metadata: the sum of its StakeRewardOpType
+// outputs minus the sum of its StakeInputOpType inputs. The
+// reward is unknown (and omitted) if an input has no amount
+// (when decoding offline) or if an operation is unparseable.
+func (b *Client) addStakeReward(tx *types.Transaction) error {
+	reward := new(big.Int)
+	for _, op := range tx.Operations {
+		switch op.Type {
+		case StakeInputOpType, StakeRewardOpType:
+		case UnparseableOpType:
+			return nil
+		default:
+			continue
+		}
+
+		if op.Amount == nil {
+			return nil
+		}
+
+		value, err := types.AmountValue(op.Amount)
+		if err != nil {
+			return fmt.Errorf("%w: unable to parse amount of %s", err, op.Type)
+		}
+
+		// Input amounts are negative.
+		reward.Add(reward, value)
+	}
+
+	var metadata TransactionMetadata
+	if err := types.UnmarshalMap(tx.Metadata, &metadata); err != nil {
+		return fmt.Errorf("%w: unable to parse transaction metadata", err)
+	}
+
+	metadata.StakeReward = &types.Amount{Value: reward.String(), Currency: b.currency}
+	updated, err := types.MarshalMap(&metadata)
+	if err != nil {
+		return fmt.Errorf("%w: unable to marshal transaction metadata", err)
+	}
+
+	tx.Metadata = updated
+	return nil
+}
+
+// markMasternodeReward changes the type of the operation
+// of the output at networkIndex of tx (paying the masternode
+// of the block) to MasternodeRewardOpType.
+func markMasternodeReward(tx *types.Transaction, networkIndex int64) {
+	for _, op := range tx.Operations {
+		switch op.Type {
+		case OutputOpType, StakeRewardOpType:
+		default:
+			continue
+		}
+
+		if op.OperationIdentifier.NetworkIndex != nil &&
+			*op.OperationIdentifier.NetworkIndex == networkIndex {
+			op.Type = MasternodeRewardOpType
+			return
+		}
+	}
+}
+
+// markTreasuryPayout changes the type of the operation
+// of the output at networkIndex of tx (paying out the
+// treasury) to TreasuryPayoutOpType.
+func markTreasuryPayout(tx *types.Transaction, networkIndex int64) {
+	for _, op := range tx.Operations {
+		if op.Type != OutputOpType || op.OperationIdentifier.NetworkIndex == nil {
+			continue
+		}
+
+		if *op.OperationIdentifier.NetworkIndex == networkIndex {
+			op.Type = TreasuryPayoutOpType
+			return
+		}
+	}
+}
+
+// ParseTransaction returns a parsed bitcoin transaction given
+// a raw bitcoin transaction that is not part of a block (i.e.
+// one decoded offline). Inputs are parsed without the account
+// and amount of the outputs they spend (unless the node
+// returned them as prevouts).
+func (b *Client) ParseTransaction(transaction *Transaction) (*types.Transaction, error) {
+	if transaction == nil {
+		return nil, errors.New("error parsing nil transaction")
+	}
+
+	return b.parseTransaction(transaction, 0, nil)
+}
+
+// parseTransaction returns the transaction at index of a
+// block, using coins to hydrate its input operations.
+func (b *Client) parseTransaction(
+	transaction *Transaction,
+	index int,
+	coins map[string]*types.AccountCoin,
+) (*types.Transaction, error) {
+	txOps, err := b.parseTxInputOperations(transaction, index, coins)
+	if errors.Is(err, ErrPreviousTransactionMissing) {
+		return nil, fmt.Errorf("%w: error parsing transaction operations", err)
+	}
+
+	// Instead of halting sync on a transaction we can't parse
+	// (i.e. an unknown script version), we replace its operations
+	// with a placeholder so that it can be re-processed once the
+	// parser is upgraded. If only outputs can't be parsed, the
+	// inputs are still spent (and each unparseable output is
+	// replaced by a placeholder, see parseTxOutputOperations).
+	if err != nil {
+		txOp, err := unparseableOperation(transaction, err, 0, nil)
+		if err != nil {
+			return nil, err
+		}
+
+		txOps = []*types.Operation{txOp}
+	} else {
+		txOps, err = b.parseTxOutputOperations(transaction, txOps)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	metadata, err := transaction.Metadata()
+	if err != nil {
+		return nil, fmt.Errorf("%w: unable to get metadata for transaction", err)
+	}
+
+	return &types.Transaction{
+		TransactionIdentifier: &types.TransactionIdentifier{
+			Hash: transaction.Hash,
+		},
+		Operations:          txOps,
+		RelatedTransactions: b.relatedTransactions(transaction, index),
+		Metadata:            metadata,
+	}, nil
+}
+
+// relatedTransactions returns the transactions that created
+// the outputs spent by the inputs of the transaction at index
+// of a block (in input order, without duplicates), so clients
+// can walk the transaction graph backward. It returns nil if
+// the transaction spends no previous outputs (i.e. a coinbase).
+func (b *Client) relatedTransactions(
+	transaction *Transaction,
+	index int,
+) []*types.RelatedTransaction {
+	var related []*types.RelatedTransaction
+	seen := map[string]struct{}{}
+	for inputIndex, input := range transaction.Inputs {
+		hash, _, ok := b.getInputTxHash(input, index, inputIndex)
+		if !ok {
+			continue
+		}
+
+		if _, ok := seen[hash]; ok {
+			continue
+		}
+
+		seen[hash] = struct{}{}
+		related = append(related, &types.RelatedTransaction{
+			TransactionIdentifier: &types.TransactionIdentifier{Hash: hash},
+			Direction:             types.Backward,
+		})
+	}
+
+	return related
+}
+
+// unparseableOperation returns the placeholder operation (at
+// index) of a transaction that could not be parsed (or of its
+// output at networkIndex, if any, that could not be parsed).
+func unparseableOperation(
+	tx *Transaction,
+	parseErr error,
+	index int64,
+	networkIndex *int64,
+) (*types.Operation, error) {
+	metadata, err := types.MarshalMap(&UnparseableMetadata{
+		Error: parseErr.Error(),
+		Hex:   tx.Hex,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("%w: unable to marshal unparseable metadata", err)
+	}
+
+	return &types.Operation{
+		OperationIdentifier: &types.OperationIdentifier{
+			Index:        index,
+			NetworkIndex: networkIndex,
+		},
+		Type:     UnparseableOpType,
+		Status:   types.String(SuccessStatus),
+		Metadata: metadata,
+	}, nil
+}
+
+// parseTxInputOperations returns the input operations for a specified transaction.
 // It uses a map of previous transactions to properly hydrate the input operations.
-func (b *Client) parseTxOperations(
+// An input that spends a coin mapped to nil (created by an
+// unparseable transaction) cannot be parsed. If coins is nil,
+// inputs without a prevout are parsed without an account
+// and amount.
+func (b *Client) parseTxInputOperations(
 	tx *Transaction,
 	txIndex int,
 	coins map[string]*types.AccountCoin,
@@ -520,11 +1275,44 @@ func (b *Client) parseTxOperations(
 			break
 		}
 
+		// Zerocoin spends have no prevout (the
+		// zerocoins are debited from the pool).
+		if IsZerocoinSpend(input) {
+			txOp, err := b.zerocoinSpendTxOperation(input, int64(len(txOps)), int64(networkIndex))
+			if err != nil {
+				return nil, fmt.Errorf("%w: error parsing zerocoin spend", err)
+			}
+
+			txOps = append(txOps, txOp)
+			continue
+		}
+
 		// Fetch the *storage.AccountCoin the input is associated with
+		// (backfilled from the coin index). If it was not backfilled,
+		// fallback to the output returned by the node (if any).
 		accountCoin, ok := coins[CoinIdentifier(input.TxHash, input.Vout)]
-		if !ok {
+		if ok && accountCoin == nil {
+			return nil, fmt.Errorf(
+				"%w: %s, for tx: %s, input index: %d",
+				ErrPreviousTransactionUnparseable,
+				input.TxHash,
+				tx.Hash,
+				networkIndex,
+			)
+		}
+		if !ok && input.Prevout != nil {
+			var err error
+			accountCoin, err = b.prevoutAccountCoin(input)
+			if err != nil {
+				return nil, fmt.Errorf("%w: unable to parse prevout", err)
+			}
+
+			ok = true
+		}
+		if !ok && coins != nil {
 			return nil, fmt.Errorf(
-				"error finding previous tx: %s, for tx: %s, input index: %d",
+				"%w: %s, for tx: %s, input index: %d",
+				ErrPreviousTransactionMissing,
 				input.TxHash,
 				tx.Hash,
 				networkIndex,
@@ -545,6 +1333,17 @@ func (b *Client) parseTxOperations(
 		txOps = append(txOps, txOp)
 	}
 
+	return txOps, nil
+}
+
+// parseTxOutputOperations appends the output operations of
+// tx to txOps (its input operations). Each output that can't
+// be parsed is replaced by a placeholder operation, so that
+// the other outputs still create their coins.
+func (b *Client) parseTxOutputOperations(
+	tx *Transaction,
+	txOps []*types.Operation,
+) ([]*types.Operation, error) {
 	for networkIndex, output := range tx.Outputs {
 		txOp, err := b.parseOutputTransactionOperation(
 			output,
@@ -553,12 +1352,20 @@ func (b *Client) parseTxOperations(
 			int64(networkIndex),
 		)
 		if err != nil {
-			return nil, fmt.Errorf(
-				"%w: error parsing tx output, hash: %s, index: %d",
-				err,
-				tx.Hash,
-				networkIndex,
+			txOp, err = unparseableOperation(
+				tx,
+				fmt.Errorf(
+					"%w: error parsing tx output, hash: %s, index: %d",
+					err,
+					tx.Hash,
+					networkIndex,
+				),
+				int64(len(txOps)),
+				types.Int64(int64(networkIndex)),
 			)
+			if err != nil {
+				return nil, err
+			}
 		}
 
 		txOps = append(txOps, txOp)
@@ -597,21 +1404,50 @@ func (b *Client) parseOutputTransactionOperation(
 		CoinAction: types.CoinCreated,
 	}
 
+	// Zerocoin mints credit the pool of zerocoins
+	// (and don't create a coin, as spends don't
+	// reference the mint).
+	if IsZerocoinMint(output.ScriptPubKey) {
+		return &types.Operation{
+			OperationIdentifier: &types.OperationIdentifier{
+				Index:        index,
+				NetworkIndex: &networkIndex,
+			},
+			Type:     ZerocoinMintOpType,
+			Status:   types.String(SuccessStatus),
+			Account:  ZerocoinAccount(),
+			Amount:   Amount(int64(amount), b.currency),
+			Metadata: metadata,
+		}, nil
+	}
+
 	// If we are unable to parse the output account (i.e. bitcoind
 	// returns a blank/nonstandard ScriptPubKey), we create an address as the
 	// concatenation of the tx hash and index.
 	//
 	// Example: 4852fe372ff7534c16713b3146bbc1e86379c70bea4d5c02fb1fa0112980a081:1
 	// on testnet
-	account := b.parseOutputAccount(output.ScriptPubKey)
+	account := b.parseOutputAccount(output)
 	if len(account.Address) == 0 {
 		account.Address = fmt.Sprintf("%s:%d", txHash, networkIndex)
 	}
 
-	// If this is an OP_RETURN locking script,
-	// we don't create a coin because it is provably unspendable.
+	// Outputs delegating coins to a staker are distinguished
+	// from other outputs (the staker and the owner are
+	// in the metadata).
+	opType := OutputOpType
+	if _, _, ok := ColdStakeParties(output.ScriptPubKey); ok {
+		opType = DelegationOpType
+	}
+
+	// If this is an OP_RETURN locking script, its value is
+	// burned: we don't create a coin because it is provably
+	// unspendable, and the operation has a zero value so the
+	// burned value isn't credited to the account of the script.
 	if output.ScriptPubKey.Type == NullData {
+		opType = BurnOpType
 		coinChange = nil
+		amount = 0
 	}
 
 	return &types.Operation{
@@ -619,27 +1455,24 @@ func (b *Client) parseOutputTransactionOperation(
 			Index:        index,
 			NetworkIndex: &networkIndex,
 		},
-		Type:    OutputOpType,
-		Status:  types.String(SuccessStatus),
-		Account: account,
-		Amount: &types.Amount{
-			Value:    strconv.FormatInt(int64(amount), 10),
-			Currency: b.currency,
-		},
+		Type:       opType,
+		Status:     types.String(SuccessStatus),
+		Account:    account,
+		Amount:     Amount(int64(amount), b.currency),
 		CoinChange: coinChange,
 		Metadata:   metadata,
 	}, nil
 }
 
 // getInputTxHash returns the transaction hash corresponding to an inputs previous
-// output. If the input is a coinbase input, then no previous transaction is associated
-// with the input.
+// output. If the input is a coinbase input (or a zerocoin spend), then no previous
+// transaction is associated with the input.
 func (b *Client) getInputTxHash(
 	input *Input,
 	txIndex int,
 	inputIndex int,
 ) (string, int64, bool) {
-	if bitcoinIsCoinbaseInput(input, txIndex, inputIndex) {
+	if bitcoinIsCoinbaseInput(input, txIndex, inputIndex) || IsZerocoinSpend(input) {
 		return "", -1, false
 	}
 
@@ -653,6 +1486,40 @@ func bitcoinIsCoinbaseInput(input *Input, txIndex int, inputIndex int) bool {
 	return txIndex == 0 && inputIndex == 0 && input.TxHash == "" && input.Coinbase != ""
 }
 
+// prevoutAccountCoin returns the *types.AccountCoin spent
+// by an input using the Prevout returned by the node.
+func (b *Client) prevoutAccountCoin(input *Input) (*types.AccountCoin, error) {
+	if input.Prevout.ScriptPubKey == nil {
+		return nil, errors.New("prevout scriptPubKey is nil")
+	}
+
+	amount, err := b.parseAmount(input.Prevout.Value)
+	if err != nil {
+		return nil, fmt.Errorf("%w: unable to parse prevout value", err)
+	}
+
+	// Mirror the account assigned to the output
+	// in parseOutputTransactionOperation.
+	coinIdentifier := CoinIdentifier(input.TxHash, input.Vout)
+	account := b.parseOutputAccount(&Output{
+		Value:        input.Prevout.Value,
+		ScriptPubKey: input.Prevout.ScriptPubKey,
+	})
+	if len(account.Address) == 0 {
+		account.Address = coinIdentifier
+	}
+
+	return &types.AccountCoin{
+		Account: account,
+		Coin: &types.Coin{
+			CoinIdentifier: &types.CoinIdentifier{
+				Identifier: coinIdentifier,
+			},
+			Amount: Amount(int64(amount), b.currency),
+		},
+	}, nil
+}
+
 // parseInputTransactionOperation returns the types.Operation for the specified
 // Input transaction input.
 func (b *Client) parseInputTransactionOperation(
@@ -666,23 +1533,13 @@ func (b *Client) parseInputTransactionOperation(
 		return nil, fmt.Errorf("%w: unable to get input metadata", err)
 	}
 
-	newValue, err := types.NegateValue(accountCoin.Coin.Amount.Value)
-	if err != nil {
-		return nil, fmt.Errorf("%w: unable to negate previous output", err)
-	}
-
-	return &types.Operation{
+	op := &types.Operation{
 		OperationIdentifier: &types.OperationIdentifier{
 			Index:        index,
 			NetworkIndex: &networkIndex,
 		},
-		Type:    InputOpType,
-		Status:  types.String(SuccessStatus),
-		Account: accountCoin.Account,
-		Amount: &types.Amount{
-			Value:    newValue,
-			Currency: b.currency,
-		},
+		Type:   InputOpType,
+		Status: types.String(SuccessStatus),
 		CoinChange: &types.CoinChange{
 			CoinIdentifier: &types.CoinIdentifier{
 				Identifier: fmt.Sprintf("%s:%d", input.TxHash, input.Vout),
@@ -690,30 +1547,60 @@ func (b *Client) parseInputTransactionOperation(
 			CoinAction: types.CoinSpent,
 		},
 		Metadata: metadata,
-	}, nil
+	}
+
+	// The spent output is unknown when decoding offline.
+	if accountCoin == nil {
+		return op, nil
+	}
+
+	newValue, err := types.NegateValue(accountCoin.Coin.Amount.Value)
+	if err != nil {
+		return nil, fmt.Errorf("%w: unable to negate previous output", err)
+	}
+
+	op.Account = accountCoin.Account
+	op.Amount = &types.Amount{
+		Value:    newValue,
+		Currency: b.currency,
+	}
+
+	return op, nil
 }
 
-// parseAmount returns the atomic value of the specified amount.
-// https://godoc.org/github.com/btcsuite/btcutil#NewAmount
+// parseAmount returns the atomic value of the specified amount
+// (see Satoshis), which must be in the money range of the
+// network (see MoneyRange).
 func (b *Client) parseAmount(amount float64) (uint64, error) {
-	atomicAmount, err := btcutil.NewAmount(amount)
+	atomicAmount, err := Satoshis(amount)
 	if err != nil {
 		return uint64(0), fmt.Errorf("%w: error parsing amount", err)
 	}
 
-	if atomicAmount < 0 {
-		return uint64(0), fmt.Errorf("error unexpected negative amount: %d", atomicAmount)
+	if err := MoneyRange(b.params, atomicAmount); err != nil {
+		return uint64(0), err
 	}
 
 	return uint64(atomicAmount), nil
 }
 
-// parseOutputAccount parses a bitcoinScriptPubKey and returns an account
-// identifier. The account identifier's address corresponds to the first
-// address encoded in the script.
+// parseOutputAccount parses the ScriptPubKey of an output and returns an
+// account identifier. The account identifier's address corresponds to the
+// first address encoded in the script (or, for cold staking scripts, the
+// owner the coins are delegated by, see ColdStakeAccount). Masternode
+// collateral is held in the CollateralAccount of its owner.
 func (b *Client) parseOutputAccount(
-	scriptPubKey *ScriptPubKey,
+	output *Output,
 ) *types.AccountIdentifier {
+	scriptPubKey := output.ScriptPubKey
+	if _, owner, ok := ColdStakeParties(scriptPubKey); ok {
+		return ColdStakeAccount(owner)
+	}
+
+	if IsMasternodeCollateral(output, b.params) {
+		return CollateralAccount(scriptPubKey.Addresses[0])
+	}
+
 	if len(scriptPubKey.Addresses) != 1 {
 		return &types.AccountIdentifier{Address: scriptPubKey.Hex}
 	}
@@ -744,12 +1631,55 @@ func (b *Client) coinbaseTxOperation(
 	}, nil
 }
 
+// zerocoinSpendTxOperation constructs a transaction operation for
+// a zerocoin spend, debiting its denomination from the ZerocoinAccount.
+func (b *Client) zerocoinSpendTxOperation(
+	input *Input,
+	index int64,
+	networkIndex int64,
+) (*types.Operation, error) {
+	amount, err := ZerocoinSpendAmount(input)
+	if err != nil {
+		return nil, err
+	}
+
+	metadata, err := input.Metadata()
+	if err != nil {
+		return nil, fmt.Errorf("%w: unable to get input metadata", err)
+	}
+
+	return &types.Operation{
+		OperationIdentifier: &types.OperationIdentifier{
+			Index:        index,
+			NetworkIndex: &networkIndex,
+		},
+		Type:     ZerocoinSpendOpType,
+		Status:   types.String(SuccessStatus),
+		Account:  ZerocoinAccount(),
+		Amount:   Amount(-amount, b.currency),
+		Metadata: metadata,
+	}, nil
+}
+
 // post makes a HTTP request to a Bitcoin node
 func (b *Client) post(
 	ctx context.Context,
 	method requestMethod,
 	params []interface{},
 	response jSONRPCResponse,
+) error {
+	return b.postTo(ctx, b.baseURL, method, params, response)
+}
+
+// postTo makes a HTTP request to the Bitcoin node at
+// url (authenticating with the credentials in url, if
+// any, instead of the fixed credentials of bitcoind)
+func (b *Client) postTo(
+	ctx context.Context,
+	url string,
+	method requestMethod,
+	params []interface{},
+	response jSONRPCResponse,
 ) error {
 	rpcRequest := &request{
 		JSONRPC: jSONRPCVersion,
@@ -763,13 +1693,15 @@ func (b *Client) post(
 		return fmt.Errorf("%w: error marshalling RPC request", err)
 	}
 
-	req, err := http.NewRequest(http.MethodPost, b.baseURL, bytes.NewReader(requestBody))
+	req, err := http.NewRequest(http.MethodPost, url, bytes.NewReader(requestBody))
 	if err != nil {
 		return fmt.Errorf("%w: error constructing request", err)
 	}
 
 	req.Header.Set("Content-Type", "application/json")
-	req.SetBasicAuth(rpcUsername, rpcPassword)
+	if req.URL.User == nil {
+		req.SetBasicAuth(rpcUsername, rpcPassword)
+	}
 
 	// Perform the post request
 	res, err := b.httpClient.Do(req.WithContext(ctx))
@@ -16,14 +16,18 @@ package bitcoin
 
 import (
 	"context"
+	"encoding/hex"
+	"encoding/json"
 	"errors"
 	"fmt"
 	"io/ioutil"
 	"log"
 	"net/http"
 	"net/http/httptest"
+	"strings"
 	"testing"
 
+	"github.com/btcsuite/btcd/txscript"
 	"github.com/coinbase/rosetta-sdk-go/types"
 	"github.com/stretchr/testify/assert"
 )
@@ -60,6 +64,7 @@ var (
 		Nonce:             2595206198,
 		Bits:              "1d00ffff",
 		Difficulty:        1,
+		ChainWork:         "000000000000000000000000000000000000000000000000000003e903e903e9",
 		Txs: []*Transaction{
 			{
 				Hex:      "01000000010000000000000000000000000000000000000000000000000000000000000000ffffffff0804ffff001d02fd04ffffffff0100f2052a01000000434104f5eeb2b10c944c6b9fbcfff94c35bdeecd93df977882babc7f3a2cf7f5c81d3b09a68db7f0e04f21de5d4230e75e6dbe7ad16eefe0d4325a62067dc6f369446aac00000000", // nolint
@@ -142,6 +147,7 @@ var (
 		Nonce:             274148111,
 		Bits:              "1b04864c",
 		Difficulty:        14484.1623612254,
+		ChainWork:         "0000000000000000000000000000000000000000000000000644cb7f5234089e",
 		Txs: []*Transaction{
 			{
 				Hex:      "01000000010000000000000000000000000000000000000000000000000000000000000000ffffffff08044c86041b020602ffffffff0100f2052a010000004341041b0e8c2567c12536aa13357b79a073dc4444acb83c4ec7a0e2f99dd7457516c5817242da796924ca4e99947d087fedf9ce467cb9f7c6287078f801df276fdf84ac00000000", // nolint
@@ -418,7 +424,7 @@ func TestNetworkStatus(t *testing.T) {
 				fmt.Fprintln(w, response.body)
 			}))
 
-			client := NewClient(ts.URL, MainnetGenesisBlockIdentifier, MainnetCurrency)
+			client := NewClient(ts.URL, MainnetGenesisBlockIdentifier, nil, MainnetCurrency)
 			status, err := client.NetworkStatus(context.Background())
 			if test.expectedError != nil {
 				assert.Contains(err.Error(), test.expectedError.Error())
@@ -430,6 +436,83 @@ func TestNetworkStatus(t *testing.T) {
 	}
 }
 
+func TestNodeNetwork(t *testing.T) {
+	tests := map[string]struct {
+		responses []responseFixture
+
+		expectedNetwork *NodeNetwork
+		expectedError   error
+	}{
+		"successful": {
+			responses: []responseFixture{
+				{
+					status: http.StatusOK,
+					body:   loadFixture("get_blockchain_info_response.json"),
+					url:    url,
+				},
+				{
+					status: http.StatusOK,
+					body:   loadFixture("get_network_info_response.json"),
+					url:    url,
+				},
+				{
+					status: http.StatusOK,
+					body:   loadFixture("get_block_hash_response.json"),
+					url:    url,
+				},
+			},
+			expectedNetwork: &NodeNetwork{
+				Chain:           "main",
+				GenesisHash:     "00000000c937983704a73af28acdec37b049d214adbda81d7e2a3dd146f6ed09",
+				SubVersion:      "/Euno Core:5.3.0/",
+				ProtocolVersion: 70925,
+			},
+		},
+		"rpc in warmup": {
+			responses: []responseFixture{
+				{
+					status: http.StatusOK,
+					body:   loadFixture("rpc_in_warmup_response.json"),
+					url:    url,
+				},
+			},
+			expectedError: ErrJSONRPCError,
+		},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			var (
+				assert = assert.New(t)
+			)
+
+			responses := make(chan responseFixture, len(test.responses))
+			for _, response := range test.responses {
+				responses <- response
+			}
+
+			ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				response := <-responses
+				assert.Equal("application/json", r.Header.Get("Content-Type"))
+				assert.Equal("POST", r.Method)
+				assert.Equal(response.url, r.URL.RequestURI())
+
+				w.WriteHeader(response.status)
+				fmt.Fprintln(w, response.body)
+			}))
+
+			client := NewClient(ts.URL, MainnetGenesisBlockIdentifier, nil, MainnetCurrency)
+			network, err := client.NodeNetwork(context.Background())
+			if test.expectedError != nil {
+				assert.True(errors.Is(err, test.expectedError))
+			} else {
+				assert.NoError(err)
+				assert.Equal(test.expectedNetwork, network)
+			}
+		})
+	}
+}
+
 func TestGetPeers(t *testing.T) {
 	tests := map[string]struct {
 		responses []responseFixture
@@ -521,7 +604,7 @@ func TestGetPeers(t *testing.T) {
 				fmt.Fprintln(w, response.body)
 			}))
 
-			client := NewClient(ts.URL, MainnetGenesisBlockIdentifier, MainnetCurrency)
+			client := NewClient(ts.URL, MainnetGenesisBlockIdentifier, nil, MainnetCurrency)
 			peers, err := client.GetPeers(context.Background())
 			if test.expectedError != nil {
 				assert.Contains(err.Error(), test.expectedError.Error())
@@ -682,7 +765,7 @@ func TestGetRawBlock(t *testing.T) {
 				fmt.Fprintln(w, response.body)
 			}))
 
-			client := NewClient(ts.URL, MainnetGenesisBlockIdentifier, MainnetCurrency)
+			client := NewClient(ts.URL, MainnetGenesisBlockIdentifier, nil, MainnetCurrency)
 			block, coins, err := client.GetRawBlock(context.Background(), test.blockIdentifier)
 			if test.expectedError != nil {
 				assert.Contains(err.Error(), test.expectedError.Error())
@@ -695,6 +778,175 @@ func TestGetRawBlock(t *testing.T) {
 	}
 }
 
+func TestGetRawBlock_Pruned(t *testing.T) {
+	// newNode returns the URL of a node that responds
+	// to getblock with fixture (and asserts the request
+	// is authenticated as username).
+	newNode := func(fixture string, username string) string {
+		ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			user, _, ok := r.BasicAuth()
+			assert.True(t, ok)
+			assert.Equal(t, username, user)
+
+			fmt.Fprintln(w, loadFixture(fixture))
+		}))
+		t.Cleanup(ts.Close)
+
+		return ts.URL
+	}
+
+	pruned := newNode("get_block_pruned_response.json", rpcUsername)
+	client := NewClient(pruned, MainnetGenesisBlockIdentifier, nil, MainnetCurrency)
+	identifier := &types.PartialBlockIdentifier{Hash: &blockIdentifier1000.Hash}
+
+	// Without archive nodes, the block is pruned.
+	block, _, err := client.GetRawBlock(context.Background(), identifier)
+	assert.Nil(t, block)
+	assert.True(t, errors.Is(err, ErrBlockPruned))
+
+	// Archive nodes are tried in order (with
+	// the credentials in their URLs).
+	archive := strings.Replace(
+		newNode("get_block_response.json", "archive"),
+		"http://",
+		"http://archive:secret@",
+		1,
+	)
+	client.UseArchiveNodes([]string{
+		newNode("get_block_pruned_response.json", rpcUsername),
+		archive,
+	})
+	block, coins, err := client.GetRawBlock(context.Background(), identifier)
+	assert.NoError(t, err)
+	assert.Equal(t, block1000, block)
+	assert.Equal(t, []string{}, coins)
+
+	// Archive nodes returning another block
+	// are skipped (as if they pruned it).
+	client.UseArchiveNodes([]string{newNode("get_block_response_2.json", rpcUsername)})
+	_, _, err = client.GetRawBlock(context.Background(), identifier)
+	assert.True(t, errors.Is(err, ErrBlockPruned))
+
+	client.UseArchiveNodes([]string{
+		newNode("get_block_response_2.json", rpcUsername),
+		newNode("get_block_response.json", rpcUsername),
+	})
+	block, _, err = client.GetRawBlock(context.Background(), identifier)
+	assert.NoError(t, err)
+	assert.Equal(t, block1000, block)
+
+	// The height of the block is
+	// checked if it is known.
+	height := blockIdentifier1000.Index + 1
+	_, _, err = client.GetRawBlock(context.Background(), &types.PartialBlockIdentifier{
+		Hash:  &blockIdentifier1000.Hash,
+		Index: &height,
+	})
+	assert.True(t, errors.Is(err, ErrBlockPruned))
+
+	// The block is still pruned if all
+	// archive nodes pruned it.
+	client.UseArchiveNodes([]string{pruned})
+	_, _, err = client.GetRawBlock(context.Background(), identifier)
+	assert.True(t, errors.Is(err, ErrBlockPruned))
+
+	// Other errors are returned as is.
+	client.UseArchiveNodes([]string{newNode("get_block_not_found_response.json", rpcUsername)})
+	_, _, err = client.GetRawBlock(context.Background(), identifier)
+	assert.True(t, errors.Is(err, ErrBlockNotFound))
+}
+
+func TestGetRawBlock_Prevouts(t *testing.T) {
+	// newNode returns the URL of a node that responds to
+	// getblock with a prevout for each input if it supports
+	// prevoutVerbosity (and an RPC error otherwise, like
+	// PIVX-based nodes), counting the requests with it.
+	newNode := func(supportsPrevouts bool, prevoutRequests *int) string {
+		ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			var rpcRequest request
+			assert.NoError(t, json.NewDecoder(r.Body).Decode(&rpcRequest))
+			if len(rpcRequest.Params) == 1 {
+				fmt.Fprintln(w, loadFixture("get_block_response_2.json"))
+				return
+			}
+
+			*prevoutRequests++
+			assert.Equal(
+				t,
+				[]interface{}{blockIdentifier100000.Hash, float64(prevoutVerbosity)},
+				rpcRequest.Params,
+			)
+			if !supportsPrevouts {
+				fmt.Fprintln(w, `{"result": null, "error": {"code": -1, "message": "JSON value `+
+					`is not a boolean as expected"}, "id": 1}`)
+				return
+			}
+
+			var response map[string]interface{}
+			assert.NoError(t, json.Unmarshal([]byte(loadFixture("get_block_response_2.json")), &response))
+			for _, tx := range response["result"].(map[string]interface{})["tx"].([]interface{}) {
+				for _, input := range tx.(map[string]interface{})["vin"].([]interface{}) {
+					input.(map[string]interface{})["prevout"] = map[string]interface{}{"value": 1}
+				}
+			}
+			assert.NoError(t, json.NewEncoder(w).Encode(response))
+		}))
+		t.Cleanup(ts.Close)
+
+		return ts.URL
+	}
+
+	identifier := &types.PartialBlockIdentifier{Hash: &blockIdentifier100000.Hash}
+
+	// Inputs with prevouts are not
+	// backfilled from the coin index.
+	prevoutRequests := 0
+	node := newNode(true, &prevoutRequests)
+	client := NewClient(node, MainnetGenesisBlockIdentifier, nil, MainnetCurrency)
+	block, coins, err := client.GetRawBlock(context.Background(), identifier)
+	assert.NoError(t, err)
+	assert.Equal(t, blockIdentifier100000.Hash, block.Hash)
+	assert.NotNil(t, block.Txs[1].Inputs[0].Prevout)
+	assert.Equal(t, []string{}, coins)
+	assert.Equal(t, 1, prevoutRequests)
+
+	// If the node rejects prevoutVerbosity, the block
+	// is requested without prevouts (and prevouts are
+	// not requested again).
+	prevoutRequests = 0
+	node = newNode(false, &prevoutRequests)
+	client = NewClient(node, MainnetGenesisBlockIdentifier, nil, MainnetCurrency)
+	for j := 0; j < 2; j++ {
+		block, coins, err = client.GetRawBlock(context.Background(), identifier)
+		assert.NoError(t, err)
+		assert.Equal(t, block100000, block)
+		assert.Len(t, coins, 3)
+	}
+	assert.Equal(t, 1, prevoutRequests)
+}
+
+func TestGetRawBlock_Limits(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprintln(w, loadFixture("get_block_response.json"))
+	}))
+	defer ts.Close()
+
+	client := NewClient(ts.URL, MainnetGenesisBlockIdentifier, nil, MainnetCurrency)
+	identifier := &types.PartialBlockIdentifier{Hash: &blockIdentifier1000.Hash}
+
+	client.UseLimits(&Limits{MaxBlockSize: 1543, MaxBlockTransactions: 2})
+	block, _, err := client.GetRawBlock(context.Background(), identifier)
+	assert.NoError(t, err)
+	assert.Equal(t, block1000, block)
+
+	client.UseNetwork(&types.NetworkIdentifier{Blockchain: Blockchain, Network: MainnetNetwork})
+	client.UseLimits(&Limits{MaxBlockSize: 1542})
+	block, _, err = client.GetRawBlock(context.Background(), identifier)
+	assert.Nil(t, block)
+	assert.True(t, errors.Is(err, ErrBlockExceedsLimits))
+	assert.Equal(t, "1", rejectedBlocksMetric.Get(MainnetNetwork).String())
+}
+
 func int64Pointer(v int64) *int64 {
 	return &v
 }
@@ -970,7 +1222,7 @@ func TestParseBlock(t *testing.T) {
 									Index:        2,
 									NetworkIndex: int64Pointer(1),
 								},
-								Type:   OutputOpType,
+								Type:   BurnOpType,
 								Status: types.String(SuccessStatus),
 								Account: &types.AccountIdentifier{
 									Address: "6a24aa21a9ed10109f4b82aa3ed7ec9d02a2a90246478b3308c8b85daf62fe501d58d05727a4",
@@ -993,6 +1245,12 @@ func TestParseBlock(t *testing.T) {
 							Version: 1,
 							Vsize:   135,
 							Weight:  540,
+							OpReturn: []*OpReturnPayload{
+								{
+									Index: 1,
+									Hex:   "aa21a9ed10109f4b82aa3ed7ec9d02a2a90246478b3308c8b85daf62fe501d58d05727a4",
+								},
+							},
 						}),
 					},
 					{
@@ -1093,6 +1351,14 @@ func TestParseBlock(t *testing.T) {
 								}),
 							},
 						},
+						RelatedTransactions: []*types.RelatedTransaction{
+							{
+								TransactionIdentifier: &types.TransactionIdentifier{
+									Hash: "87a157f3fd88ac7907c05fc55e271dc4acdc5605d187d646604ca8c0e9382e03",
+								},
+								Direction: types.Backward,
+							},
+						},
 						Metadata: mustMarshalMap(&TransactionMetadata{
 							Size:    259,
 							Version: 1,
@@ -1227,6 +1493,20 @@ func TestParseBlock(t *testing.T) {
 								}),
 							},
 						},
+						RelatedTransactions: []*types.RelatedTransaction{
+							{
+								TransactionIdentifier: &types.TransactionIdentifier{
+									Hash: "503e4e9824282eb06f1a328484e2b367b5f4f93a405d6e7b97261bafabfb53d5",
+								},
+								Direction: types.Backward,
+							},
+							{
+								TransactionIdentifier: &types.TransactionIdentifier{
+									Hash: "fff2525b8931402dd09222c50775608f75787bd2b87e56995a7bdd30f79702c4",
+								},
+								Direction: types.Backward,
+							},
+						},
 						Metadata: mustMarshalMap(&TransactionMetadata{
 							Size:     421,
 							Version:  2,
@@ -1261,13 +1541,14 @@ func TestParseBlock(t *testing.T) {
 				assert = assert.New(t)
 			)
 
-			client := NewClient("", MainnetGenesisBlockIdentifier, MainnetCurrency)
+			client := NewClient("", MainnetGenesisBlockIdentifier, nil, MainnetCurrency)
 			block, err := client.ParseBlock(context.Background(), test.block, test.coins)
 			if test.expectedError != nil {
 				assert.Contains(err.Error(), test.expectedError.Error())
 			} else {
 				assert.NoError(err)
 				assert.Equal(test.expectedBlock, block)
+				assertRegisteredOperations(t, block)
 			}
 		})
 	}
@@ -1333,7 +1614,7 @@ func TestSuggestedFeeRate(t *testing.T) {
 				fmt.Fprintln(w, response.body)
 			}))
 
-			client := NewClient(ts.URL, MainnetGenesisBlockIdentifier, MainnetCurrency)
+			client := NewClient(ts.URL, MainnetGenesisBlockIdentifier, nil, MainnetCurrency)
 			rate, err := client.SuggestedFeeRate(context.Background(), 1)
 			if test.expectedError != nil {
 				assert.Contains(err.Error(), test.expectedError.Error())
@@ -1399,7 +1680,7 @@ func TestRawMempool(t *testing.T) {
 				fmt.Fprintln(w, response.body)
 			}))
 
-			client := NewClient(ts.URL, MainnetGenesisBlockIdentifier, MainnetCurrency)
+			client := NewClient(ts.URL, MainnetGenesisBlockIdentifier, nil, MainnetCurrency)
 			txs, err := client.RawMempool(context.Background())
 			if test.expectedError != nil {
 				assert.Contains(err.Error(), test.expectedError.Error())
@@ -1411,6 +1692,192 @@ func TestRawMempool(t *testing.T) {
 	}
 }
 
+func TestMempoolEntry(t *testing.T) {
+	tests := map[string]struct {
+		responses []responseFixture
+
+		expectedEntry *MempoolEntry
+		expectedError error
+	}{
+		"successful": {
+			responses: []responseFixture{
+				{
+					status: http.StatusOK,
+					body:   loadFixture("get_mempool_entry_response.json"),
+					url:    url,
+				},
+			},
+			expectedEntry: &MempoolEntry{
+				VSize:           225,
+				Weight:          900,
+				Time:            1604939520,
+				Height:          655000,
+				DescendantCount: 1,
+				DescendantSize:  225,
+				AncestorCount:   2,
+				AncestorSize:    450,
+				Depends: []string{
+					"37b4fcc8e0b229412faeab8baad45d3eb8e4eec41840d6ac2103987163459e75",
+				},
+				Fees: &MempoolEntryFees{
+					Base:       0.0000225,
+					Modified:   0.0000225,
+					Ancestor:   0.000045,
+					Descendant: 0.0000225,
+				},
+				BIP125Replaceable: true,
+			},
+		},
+		"not in mempool": {
+			responses: []responseFixture{
+				{
+					status: http.StatusOK,
+					body:   loadFixture("get_mempool_entry_not_found_response.json"),
+					url:    url,
+				},
+			},
+			expectedError: ErrTransactionNotInMempool,
+		},
+		"500 error": {
+			responses: []responseFixture{
+				{
+					status: http.StatusInternalServerError,
+					body:   "{}",
+					url:    url,
+				},
+			},
+			expectedError: errors.New("invalid response: 500 Internal Server Error"),
+		},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			var (
+				assert = assert.New(t)
+			)
+
+			responses := make(chan responseFixture, len(test.responses))
+			for _, response := range test.responses {
+				responses <- response
+			}
+
+			ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				response := <-responses
+				assert.Equal("application/json", r.Header.Get("Content-Type"))
+				assert.Equal("POST", r.Method)
+				assert.Equal(response.url, r.URL.RequestURI())
+
+				w.WriteHeader(response.status)
+				fmt.Fprintln(w, response.body)
+			}))
+
+			client := NewClient(ts.URL, MainnetGenesisBlockIdentifier, nil, MainnetCurrency)
+			entry, err := client.MempoolEntry(
+				context.Background(),
+				"9cec12d170e97e21a876fa2789e6bfc25aa22b8a5e05f3f276650844da0c33ab",
+			)
+			if test.expectedError != nil {
+				assert.Contains(err.Error(), test.expectedError.Error())
+			} else {
+				assert.NoError(err)
+				assert.Equal(test.expectedEntry, entry)
+			}
+		})
+	}
+}
+
+func TestMempoolTransaction(t *testing.T) {
+	tests := map[string]struct {
+		responses []responseFixture
+
+		expectedOps   []string
+		expectedError error
+	}{
+		"successful": {
+			responses: []responseFixture{
+				{
+					status: http.StatusOK,
+					body:   loadFixture("get_raw_transaction_response.json"),
+					url:    url,
+				},
+			},
+			expectedOps: []string{InputOpType, OutputOpType},
+		},
+		"not in mempool": {
+			responses: []responseFixture{
+				{
+					status: http.StatusOK,
+					body:   loadFixture("get_mempool_entry_not_found_response.json"),
+					url:    url,
+				},
+			},
+			expectedError: ErrTransactionNotInMempool,
+		},
+		"500 error": {
+			responses: []responseFixture{
+				{
+					status: http.StatusInternalServerError,
+					body:   "{}",
+					url:    url,
+				},
+			},
+			expectedError: errors.New("invalid response: 500 Internal Server Error"),
+		},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			var (
+				assert = assert.New(t)
+			)
+
+			responses := make(chan responseFixture, len(test.responses))
+			for _, response := range test.responses {
+				responses <- response
+			}
+
+			ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				response := <-responses
+				assert.Equal("application/json", r.Header.Get("Content-Type"))
+				assert.Equal("POST", r.Method)
+				assert.Equal(response.url, r.URL.RequestURI())
+
+				w.WriteHeader(response.status)
+				fmt.Fprintln(w, response.body)
+			}))
+
+			client := NewClient(ts.URL, MainnetGenesisBlockIdentifier, nil, MainnetCurrency)
+			tx, err := client.MempoolTransaction(
+				context.Background(),
+				"9cec12d170e97e21a876fa2789e6bfc25aa22b8a5e05f3f276650844da0c33ab",
+			)
+			if test.expectedError != nil {
+				assert.Contains(err.Error(), test.expectedError.Error())
+				return
+			}
+
+			assert.NoError(err)
+			assert.Equal(
+				"9cec12d170e97e21a876fa2789e6bfc25aa22b8a5e05f3f276650844da0c33ab",
+				tx.TransactionIdentifier.Hash,
+			)
+
+			ops := []string{}
+			for _, op := range tx.Operations {
+				ops = append(ops, op.Type)
+			}
+			assert.Equal(test.expectedOps, ops)
+			assert.Equal(
+				"4852fe372ff7534c16713b3146bbc1e86379c70bea4d5c02fb1fa0112980a081:0",
+				tx.Operations[0].CoinChange.CoinIdentifier.Identifier,
+			)
+			assert.Equal(types.CoinSpent, tx.Operations[0].CoinChange.CoinAction)
+			assert.Equal(types.CoinCreated, tx.Operations[1].CoinChange.CoinAction)
+			assert.Equal("3800000", tx.Operations[1].Amount.Value)
+		})
+	}
+}
+
 // loadFixture takes a file name and returns the response fixture.
 func loadFixture(fileName string) string {
 	content, err := ioutil.ReadFile(fmt.Sprintf("client_fixtures/%s", fileName))
@@ -1425,3 +1892,1177 @@ type responseFixture struct {
 	body   string
 	url    string
 }
+
+func TestInvalidateBlock(t *testing.T) {
+	tests := map[string]struct {
+		responses []responseFixture
+
+		expectedError error
+	}{
+		"successful": {
+			responses: []responseFixture{
+				{
+					status: http.StatusOK,
+					body:   loadFixture("invalidate_block_response.json"),
+					url:    url,
+				},
+			},
+		},
+		"block not found": {
+			responses: []responseFixture{
+				{
+					status: http.StatusOK,
+					body:   loadFixture("invalidate_block_not_found_response.json"),
+					url:    url,
+				},
+			},
+			expectedError: ErrJSONRPCError,
+		},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			var (
+				assert = assert.New(t)
+			)
+
+			responses := make(chan responseFixture, len(test.responses))
+			for _, response := range test.responses {
+				responses <- response
+			}
+
+			ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				response := <-responses
+				assert.Equal("application/json", r.Header.Get("Content-Type"))
+				assert.Equal("POST", r.Method)
+				assert.Equal(response.url, r.URL.RequestURI())
+
+				w.WriteHeader(response.status)
+				fmt.Fprintln(w, response.body)
+			}))
+
+			client := NewClient(ts.URL, MainnetGenesisBlockIdentifier, nil, MainnetCurrency)
+			err := client.InvalidateBlock(
+				context.Background(),
+				"0000000000000000000a8c1e29a6cfa0bb2b1a5b8cbbd4f7e2b5c3b1e0a1d2c3",
+			)
+			if test.expectedError != nil {
+				assert.True(errors.Is(err, test.expectedError))
+			} else {
+				assert.NoError(err)
+			}
+		})
+	}
+}
+
+func TestReconsiderBlock(t *testing.T) {
+	tests := map[string]struct {
+		responses []responseFixture
+
+		expectedError error
+	}{
+		"successful": {
+			responses: []responseFixture{
+				{
+					status: http.StatusOK,
+					body:   loadFixture("reconsider_block_response.json"),
+					url:    url,
+				},
+			},
+		},
+		"block not found": {
+			responses: []responseFixture{
+				{
+					status: http.StatusOK,
+					body:   loadFixture("reconsider_block_not_found_response.json"),
+					url:    url,
+				},
+			},
+			expectedError: ErrJSONRPCError,
+		},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			var (
+				assert = assert.New(t)
+			)
+
+			responses := make(chan responseFixture, len(test.responses))
+			for _, response := range test.responses {
+				responses <- response
+			}
+
+			ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				response := <-responses
+				assert.Equal("application/json", r.Header.Get("Content-Type"))
+				assert.Equal("POST", r.Method)
+				assert.Equal(response.url, r.URL.RequestURI())
+
+				w.WriteHeader(response.status)
+				fmt.Fprintln(w, response.body)
+			}))
+
+			client := NewClient(ts.URL, MainnetGenesisBlockIdentifier, nil, MainnetCurrency)
+			err := client.ReconsiderBlock(
+				context.Background(),
+				"0000000000000000000a8c1e29a6cfa0bb2b1a5b8cbbd4f7e2b5c3b1e0a1d2c3",
+			)
+			if test.expectedError != nil {
+				assert.True(errors.Is(err, test.expectedError))
+			} else {
+				assert.NoError(err)
+			}
+		})
+	}
+}
+
+func TestListBanned(t *testing.T) {
+	tests := map[string]struct {
+		responses []responseFixture
+
+		expectedBanned []*BannedSubnet
+		expectedError  error
+	}{
+		"successful": {
+			responses: []responseFixture{
+				{
+					status: http.StatusOK,
+					body:   loadFixture("list_banned_response.json"),
+					url:    url,
+				},
+			},
+			expectedBanned: []*BannedSubnet{
+				{
+					Address:     "10.0.0.1/32",
+					BannedUntil: 1633456789,
+					BanCreated:  1633370389,
+					BanReason:   "node misbehaving",
+				},
+			},
+		},
+		"error": {
+			responses: []responseFixture{
+				{
+					status: http.StatusOK,
+					body:   loadFixture("rpc_in_warmup_response.json"),
+					url:    url,
+				},
+			},
+			expectedError: ErrJSONRPCError,
+		},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			var (
+				assert = assert.New(t)
+			)
+
+			responses := make(chan responseFixture, len(test.responses))
+			for _, response := range test.responses {
+				responses <- response
+			}
+
+			ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				response := <-responses
+				assert.Equal("application/json", r.Header.Get("Content-Type"))
+				assert.Equal("POST", r.Method)
+				assert.Equal(response.url, r.URL.RequestURI())
+
+				w.WriteHeader(response.status)
+				fmt.Fprintln(w, response.body)
+			}))
+
+			client := NewClient(ts.URL, MainnetGenesisBlockIdentifier, nil, MainnetCurrency)
+			banned, err := client.ListBanned(context.Background())
+			if test.expectedError != nil {
+				assert.True(errors.Is(err, test.expectedError))
+			} else {
+				assert.NoError(err)
+				assert.Equal(test.expectedBanned, banned)
+			}
+		})
+	}
+}
+
+func TestBanPeer(t *testing.T) {
+	tests := map[string]struct {
+		responses []responseFixture
+
+		expectedError error
+	}{
+		"successful": {
+			responses: []responseFixture{
+				{
+					status: http.StatusOK,
+					body:   loadFixture("set_ban_response.json"),
+					url:    url,
+				},
+			},
+		},
+		"already banned": {
+			responses: []responseFixture{
+				{
+					status: http.StatusOK,
+					body:   loadFixture("set_ban_already_banned_response.json"),
+					url:    url,
+				},
+			},
+			expectedError: ErrJSONRPCError,
+		},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			var (
+				assert = assert.New(t)
+			)
+
+			responses := make(chan responseFixture, len(test.responses))
+			for _, response := range test.responses {
+				responses <- response
+			}
+
+			ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				response := <-responses
+				assert.Equal("application/json", r.Header.Get("Content-Type"))
+				assert.Equal("POST", r.Method)
+				assert.Equal(response.url, r.URL.RequestURI())
+
+				w.WriteHeader(response.status)
+				fmt.Fprintln(w, response.body)
+			}))
+
+			client := NewClient(ts.URL, MainnetGenesisBlockIdentifier, nil, MainnetCurrency)
+			err := client.BanPeer(context.Background(), "10.0.0.1", 3600)
+			if test.expectedError != nil {
+				assert.True(errors.Is(err, test.expectedError))
+			} else {
+				assert.NoError(err)
+			}
+		})
+	}
+}
+
+func TestDisconnectPeer(t *testing.T) {
+	tests := map[string]struct {
+		responses []responseFixture
+
+		expectedError error
+	}{
+		"successful": {
+			responses: []responseFixture{
+				{
+					status: http.StatusOK,
+					body:   loadFixture("disconnect_node_response.json"),
+					url:    url,
+				},
+			},
+		},
+		"peer not found": {
+			responses: []responseFixture{
+				{
+					status: http.StatusOK,
+					body:   loadFixture("disconnect_node_not_found_response.json"),
+					url:    url,
+				},
+			},
+			expectedError: ErrJSONRPCError,
+		},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			var (
+				assert = assert.New(t)
+			)
+
+			responses := make(chan responseFixture, len(test.responses))
+			for _, response := range test.responses {
+				responses <- response
+			}
+
+			ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				response := <-responses
+				assert.Equal("application/json", r.Header.Get("Content-Type"))
+				assert.Equal("POST", r.Method)
+				assert.Equal(response.url, r.URL.RequestURI())
+
+				w.WriteHeader(response.status)
+				fmt.Fprintln(w, response.body)
+			}))
+
+			client := NewClient(ts.URL, MainnetGenesisBlockIdentifier, nil, MainnetCurrency)
+			err := client.DisconnectPeer(context.Background(), "10.0.0.1:46000")
+			if test.expectedError != nil {
+				assert.True(errors.Is(err, test.expectedError))
+			} else {
+				assert.NoError(err)
+			}
+		})
+	}
+}
+
+func TestGenerateToAddress(t *testing.T) {
+	tests := map[string]struct {
+		responses []responseFixture
+
+		expectedHashes []string
+		expectedError  error
+	}{
+		"successful": {
+			responses: []responseFixture{
+				{
+					status: http.StatusOK,
+					body:   loadFixture("generate_to_address_response.json"),
+					url:    url,
+				},
+			},
+			expectedHashes: []string{
+				"0000000000000000000a8c1e29a6cfa0bb2b1a5b8cbbd4f7e2b5c3b1e0a1d2c3",
+				"00000000000000000003e9f1c4d5b6a7f8e9d0c1b2a3f4e5d6c7b8a9f0e1d2c3",
+			},
+		},
+		"500 error": {
+			responses: []responseFixture{
+				{
+					status: http.StatusInternalServerError,
+					body:   "{}",
+					url:    url,
+				},
+			},
+			expectedError: errors.New("invalid response: 500 Internal Server Error"),
+		},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			var (
+				assert = assert.New(t)
+			)
+
+			responses := make(chan responseFixture, len(test.responses))
+			for _, response := range test.responses {
+				responses <- response
+			}
+
+			ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				response := <-responses
+				assert.Equal("application/json", r.Header.Get("Content-Type"))
+				assert.Equal("POST", r.Method)
+				assert.Equal(response.url, r.URL.RequestURI())
+
+				w.WriteHeader(response.status)
+				fmt.Fprintln(w, response.body)
+			}))
+
+			client := NewClient(ts.URL, MainnetGenesisBlockIdentifier, nil, MainnetCurrency)
+			hashes, err := client.GenerateToAddress(
+				context.Background(),
+				2,
+				"DQJXChtyuD6cR3KqXyS2ZyVasHj4kqZsHu",
+			)
+			if test.expectedError != nil {
+				assert.Contains(err.Error(), test.expectedError.Error())
+			} else {
+				assert.NoError(err)
+				assert.Equal(test.expectedHashes, hashes)
+			}
+		})
+	}
+}
+
+func TestParseBlock_GenesisAllocations(t *testing.T) {
+	allocations := []*GenesisAllocation{
+		{
+			Address: "address 1",
+			Value:   100,
+		},
+		{
+			Address: "address 2",
+			Value:   250,
+		},
+	}
+	client := NewClient("", MainnetGenesisBlockIdentifier, allocations, MainnetCurrency)
+
+	genesis := &Block{
+		Hash:   MainnetGenesisBlockIdentifier.Hash,
+		Height: 0,
+		Time:   1231006505,
+	}
+	block, err := client.ParseBlock(context.Background(), genesis, map[string]*types.AccountCoin{})
+	assert.NoError(t, err)
+	assert.Equal(t, []*types.Transaction{
+		{
+			TransactionIdentifier: &types.TransactionIdentifier{
+				Hash: GenesisAllocationsTransactionHash,
+			},
+			Operations: []*types.Operation{
+				{
+					OperationIdentifier: &types.OperationIdentifier{
+						Index: 0,
+					},
+					Type:    GenesisAllocationOpType,
+					Status:  types.String(SuccessStatus),
+					Account: &types.AccountIdentifier{Address: "address 1"},
+					Amount: &types.Amount{
+						Value:    "100",
+						Currency: MainnetCurrency,
+					},
+				},
+				{
+					OperationIdentifier: &types.OperationIdentifier{
+						Index: 1,
+					},
+					Type:    GenesisAllocationOpType,
+					Status:  types.String(SuccessStatus),
+					Account: &types.AccountIdentifier{Address: "address 2"},
+					Amount: &types.Amount{
+						Value:    "250",
+						Currency: MainnetCurrency,
+					},
+				},
+			},
+		},
+	}, block.Transactions)
+
+	// Allocations are only included in the genesis block
+	genesis.Height = 1
+	genesis.PreviousBlockHash = MainnetGenesisBlockIdentifier.Hash
+	block, err = client.ParseBlock(context.Background(), genesis, map[string]*types.AccountCoin{})
+	assert.NoError(t, err)
+	assert.Empty(t, block.Transactions)
+}
+
+func TestParseBlock_Prevout(t *testing.T) {
+	client := NewClient("", MainnetGenesisBlockIdentifier, nil, MainnetCurrency)
+
+	prevoutHash := "b1a1a1a1a1a1a1a1a1a1a1a1a1a1a1a1a1a1a1a1a1a1a1a1a1a1a1a1a1a1a1a1"
+	backfilledHash := "c1a1a1a1a1a1a1a1a1a1a1a1a1a1a1a1a1a1a1a1a1a1a1a1a1a1a1a1a1a1a1a1"
+	block := &Block{
+		Hash:              "0000000000000000000000000000000000000000000000000000000000000002",
+		Height:            2,
+		PreviousBlockHash: "0000000000000000000000000000000000000000000000000000000000000001",
+		Txs: []*Transaction{
+			{
+				Hash: "a1a1a1a1a1a1a1a1a1a1a1a1a1a1a1a1a1a1a1a1a1a1a1a1a1a1a1a1a1a1a1a1",
+				Inputs: []*Input{
+					{
+						TxHash: prevoutHash,
+						Vout:   1,
+						Prevout: &Prevout{
+							Value: 0.5,
+							ScriptPubKey: &ScriptPubKey{
+								Addresses: []string{"prevout address"},
+							},
+						},
+					},
+					{
+						TxHash: backfilledHash,
+						Vout:   0,
+					},
+				},
+			},
+		},
+	}
+
+	// Inputs with a prevout are not backfilled
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		response, err := json.Marshal(&blockResponse{Result: block})
+		assert.NoError(t, err)
+		_, _ = w.Write(response)
+	}))
+	defer ts.Close()
+	rawClient := NewClient(ts.URL, MainnetGenesisBlockIdentifier, nil, MainnetCurrency)
+	_, coins, err := rawClient.GetRawBlock(
+		context.Background(),
+		&types.PartialBlockIdentifier{Hash: types.String(block.Hash)},
+	)
+	assert.NoError(t, err)
+	assert.Equal(t, []string{CoinIdentifier(backfilledHash, 0)}, coins)
+
+	backfilledCoin := &types.AccountCoin{
+		Account: &types.AccountIdentifier{Address: "backfilled address"},
+		Coin: &types.Coin{
+			CoinIdentifier: &types.CoinIdentifier{
+				Identifier: CoinIdentifier(backfilledHash, 0),
+			},
+			Amount: &types.Amount{
+				Value:    "10",
+				Currency: MainnetCurrency,
+			},
+		},
+	}
+	parsed, err := client.ParseBlock(context.Background(), block, map[string]*types.AccountCoin{
+		CoinIdentifier(backfilledHash, 0): backfilledCoin,
+	})
+	assert.NoError(t, err)
+
+	ops := parsed.Transactions[0].Operations
+	assert.Len(t, ops, 2)
+	assert.Equal(t, "prevout address", ops[0].Account.Address)
+	assert.Equal(t, "-50000000", ops[0].Amount.Value)
+	assert.Equal(t, CoinIdentifier(prevoutHash, 1), ops[0].CoinChange.CoinIdentifier.Identifier)
+	assert.Equal(t, "backfilled address", ops[1].Account.Address)
+	assert.Equal(t, "-10", ops[1].Amount.Value)
+
+	// Inputs without a prevout must be backfilled
+	_, err = client.ParseBlock(context.Background(), block, map[string]*types.AccountCoin{})
+	assert.Contains(t, err.Error(), "error finding previous tx")
+}
+
+func TestParseBlock_Delegation(t *testing.T) {
+	client := NewClient("", MainnetGenesisBlockIdentifier, nil, MainnetCurrency)
+
+	script, err := hex.DecodeString(coldStakeScript)
+	assert.NoError(t, err)
+
+	txHash := "d1a1a1a1a1a1a1a1a1a1a1a1a1a1a1a1a1a1a1a1a1a1a1a1a1a1a1a1a1a1a1a1"
+	block := &Block{
+		Hash:              "0000000000000000000000000000000000000000000000000000000000000002",
+		Height:            2,
+		PreviousBlockHash: "0000000000000000000000000000000000000000000000000000000000000001",
+		Txs: []*Transaction{
+			{
+				Hash: txHash,
+				Outputs: []*Output{
+					{
+						Value:        1,
+						Index:        0,
+						ScriptPubKey: NewScriptPubKey(script, MainnetParams),
+					},
+					{
+						Value: 0.5,
+						Index: 1,
+						ScriptPubKey: &ScriptPubKey{
+							Type:      "pubkeyhash",
+							Addresses: []string{"ELJeB54eV9QQt6TvMiYx1b678jeHVeAtKr"},
+						},
+					},
+				},
+			},
+		},
+	}
+
+	parsed, err := client.ParseBlock(context.Background(), block, nil)
+	assert.NoError(t, err)
+
+	ops := parsed.Transactions[0].Operations
+	assert.Len(t, ops, 2)
+	assert.Equal(t, DelegationOpType, ops[0].Type)
+	assert.Equal(t, ColdStakeAccount("ELJeB54eV9QQt6TvMiYx1b678jeHVeAtKr"), ops[0].Account)
+	assert.Equal(t, "100000000", ops[0].Amount.Value)
+	assert.Equal(t, CoinIdentifier(txHash, 0), ops[0].CoinChange.CoinIdentifier.Identifier)
+	assert.Equal(t, "Sf8E1SYBWseRuA5NuQZk9Lg6YEyWHh7H6Y", ops[0].Metadata["staker"])
+	assert.Equal(t, "ELJeB54eV9QQt6TvMiYx1b678jeHVeAtKr", ops[0].Metadata["owner"])
+
+	// Other outputs are not delegations
+	assert.Equal(t, OutputOpType, ops[1].Type)
+	assert.Equal(t, "ELJeB54eV9QQt6TvMiYx1b678jeHVeAtKr", ops[1].Account.Address)
+	assert.NotContains(t, ops[1].Metadata, "staker")
+}
+
+func TestParseBlock_Burn(t *testing.T) {
+	client := NewClient("", MainnetGenesisBlockIdentifier, nil, MainnetCurrency)
+
+	script, err := txscript.NullDataScript([]byte("burn"))
+	assert.NoError(t, err)
+
+	block := &Block{
+		Hash:              "0000000000000000000000000000000000000000000000000000000000000002",
+		Height:            2,
+		PreviousBlockHash: "0000000000000000000000000000000000000000000000000000000000000001",
+		Txs: []*Transaction{
+			{
+				Hash: "d1a1a1a1a1a1a1a1a1a1a1a1a1a1a1a1a1a1a1a1a1a1a1a1a1a1a1a1a1a1a1a1",
+				Outputs: []*Output{
+					{
+						Value:        1.5,
+						Index:        0,
+						ScriptPubKey: NewScriptPubKey(script, MainnetParams),
+					},
+				},
+			},
+		},
+	}
+
+	parsed, err := client.ParseBlock(context.Background(), block, nil)
+	assert.NoError(t, err)
+
+	// The burned value is not credited to
+	// the account of the script.
+	ops := parsed.Transactions[0].Operations
+	assert.Len(t, ops, 1)
+	assert.Equal(t, BurnOpType, ops[0].Type)
+	assert.Equal(t, "0", ops[0].Amount.Value)
+	assert.Nil(t, ops[0].CoinChange)
+}
+
+func TestParseBlock_Collateral(t *testing.T) {
+	client := NewClient("", MainnetGenesisBlockIdentifier, nil, MainnetCurrency)
+	client.UseParams(MainnetParams)
+
+	txHash := "d1a1a1a1a1a1a1a1a1a1a1a1a1a1a1a1a1a1a1a1a1a1a1a1a1a1a1a1a1a1a1a1"
+	owner := "ELJeB54eV9QQt6TvMiYx1b678jeHVeAtKr"
+	output := func(value float64, index int64) *Output {
+		return &Output{
+			Value: value,
+			Index: index,
+			ScriptPubKey: &ScriptPubKey{
+				Type:      "pubkeyhash",
+				Addresses: []string{owner},
+			},
+		}
+	}
+	block := &Block{
+		Hash:              "0000000000000000000000000000000000000000000000000000000000000002",
+		Height:            2,
+		PreviousBlockHash: "0000000000000000000000000000000000000000000000000000000000000001",
+		Txs: []*Transaction{
+			{
+				Hash:    txHash,
+				Outputs: []*Output{output(10000, 0), output(9999, 1)},
+			},
+			{
+				Hash: "e1a1a1a1a1a1a1a1a1a1a1a1a1a1a1a1a1a1a1a1a1a1a1a1a1a1a1a1a1a1a1a1",
+				Inputs: []*Input{
+					{
+						TxHash:  "f1a1a1a1a1a1a1a1a1a1a1a1a1a1a1a1a1a1a1a1a1a1a1a1a1a1a1a1a1a1a1a1",
+						Vout:    0,
+						Prevout: &Prevout{Value: 10000, ScriptPubKey: output(10000, 0).ScriptPubKey},
+					},
+				},
+			},
+		},
+	}
+
+	parsed, err := client.ParseBlock(context.Background(), block, nil)
+	assert.NoError(t, err)
+
+	// Collateral is held in the collateral sub-account
+	ops := parsed.Transactions[0].Operations
+	assert.Len(t, ops, 2)
+	assert.Equal(t, CollateralAccount(owner), ops[0].Account)
+	assert.Equal(t, "1000000000000", ops[0].Amount.Value)
+	assert.Equal(t, &types.AccountIdentifier{Address: owner}, ops[1].Account)
+
+	// Spending collateral debits the sub-account
+	ops = parsed.Transactions[1].Operations
+	assert.Len(t, ops, 1)
+	assert.Equal(t, CollateralAccount(owner), ops[0].Account)
+	assert.Equal(t, "-1000000000000", ops[0].Amount.Value)
+
+	// Collateral is not attributed without params
+	client.UseParams(nil)
+	parsed, err = client.ParseBlock(context.Background(), block, nil)
+	assert.NoError(t, err)
+	assert.Equal(t, &types.AccountIdentifier{Address: owner}, parsed.Transactions[0].Operations[0].Account)
+}
+
+func TestParseBlock_TreasuryPayout(t *testing.T) {
+	client := NewClient("", MainnetGenesisBlockIdentifier, nil, MainnetCurrency)
+	client.UseParams(MainnetParams)
+
+	output := func(value float64, index int64, address string) *Output {
+		return &Output{
+			Value: value,
+			Index: index,
+			ScriptPubKey: &ScriptPubKey{
+				Type:      "pubkeyhash",
+				Addresses: []string{address},
+			},
+		}
+	}
+	block := &Block{
+		Hash:              "0000000000000000000000000000000000000000000000000000000000000002",
+		Height:            MainnetBudgetCycleBlocks,
+		PreviousBlockHash: "0000000000000000000000000000000000000000000000000000000000000001",
+		Txs: []*Transaction{
+			{
+				Hash:   "d1a1a1a1a1a1a1a1a1a1a1a1a1a1a1a1a1a1a1a1a1a1a1a1a1a1a1a1a1a1a1a1",
+				Inputs: []*Input{{Coinbase: "03"}},
+				Outputs: []*Output{
+					output(5, 0, "ELJeB54eV9QQt6TvMiYx1b678jeHVeAtKr"),
+					output(1000, 1, "EVN5Ah37ygq6Y3CsRpSJNDJtkUBFWkbFtT"),
+				},
+			},
+		},
+	}
+
+	parsed, err := client.ParseBlock(context.Background(), block, nil)
+	assert.NoError(t, err)
+
+	ops := parsed.Transactions[0].Operations
+	assert.Len(t, ops, 3)
+	assert.Equal(t, CoinbaseOpType, ops[0].Type)
+	assert.Equal(t, OutputOpType, ops[1].Type)
+	assert.Equal(t, TreasuryPayoutOpType, ops[2].Type)
+	assert.Equal(t, "EVN5Ah37ygq6Y3CsRpSJNDJtkUBFWkbFtT", ops[2].Account.Address)
+	assert.Equal(t, "100000000000", ops[2].Amount.Value)
+	assert.Equal(t, types.CoinCreated, ops[2].CoinChange.CoinAction)
+
+	// Other blocks don't pay the treasury
+	block.Height++
+	parsed, err = client.ParseBlock(context.Background(), block, nil)
+	assert.NoError(t, err)
+	assert.Equal(t, OutputOpType, parsed.Transactions[0].Operations[2].Type)
+}
+
+func TestParseBlock_CoinStake(t *testing.T) {
+	client := NewClient("", MainnetGenesisBlockIdentifier, nil, MainnetCurrency)
+	client.UseParams(MainnetParams)
+
+	output := func(value float64, index int64, address string) *Output {
+		return &Output{
+			Value: value,
+			Index: index,
+			ScriptPubKey: &ScriptPubKey{
+				Type:      "pubkeyhash",
+				Addresses: []string{address},
+			},
+		}
+	}
+	marker := &Output{Index: 0, ScriptPubKey: &ScriptPubKey{Type: "nonstandard"}}
+	input := &Input{
+		TxHash:    "4852fe372ff7534c16713b3146bbc1e86379c70bea4d5c02fb1fa0112980a081",
+		ScriptSig: &ScriptSig{},
+	}
+	block := &Block{
+		Hash:              "0000000000000000000000000000000000000000000000000000000000000002",
+		Height:            MainnetBudgetCycleBlocks,
+		PreviousBlockHash: "0000000000000000000000000000000000000000000000000000000000000001",
+		Txs: []*Transaction{
+			{
+				Hash:    "d1a1a1a1a1a1a1a1a1a1a1a1a1a1a1a1a1a1a1a1a1a1a1a1a1a1a1a1a1a1a1a1",
+				Inputs:  []*Input{{Coinbase: "03"}},
+				Outputs: []*Output{marker},
+			},
+			{
+				Hash:   "d2a2a2a2a2a2a2a2a2a2a2a2a2a2a2a2a2a2a2a2a2a2a2a2a2a2a2a2a2a2a2a2",
+				Inputs: []*Input{input},
+				Outputs: []*Output{
+					marker,
+					output(105, 1, "ELJeB54eV9QQt6TvMiYx1b678jeHVeAtKr"),
+					output(3, 2, "EVN5Ah37ygq6Y3CsRpSJNDJtkUBFWkbFtT"),
+				},
+			},
+			{
+				Hash:    "d3a3a3a3a3a3a3a3a3a3a3a3a3a3a3a3a3a3a3a3a3a3a3a3a3a3a3a3a3a3a3a3",
+				Inputs:  []*Input{input},
+				Outputs: []*Output{output(1, 0, "ELJeB54eV9QQt6TvMiYx1b678jeHVeAtKr")},
+			},
+		},
+	}
+	opTypes := func(tx *types.Transaction) []string {
+		opTypes := []string{}
+		for _, op := range tx.Operations {
+			opTypes = append(opTypes, op.Type)
+		}
+
+		return opTypes
+	}
+
+	// The coinstake of a superblock pays out
+	// the treasury in its last output
+	parsed, err := client.ParseBlock(context.Background(), block, nil)
+	assert.NoError(t, err)
+	assert.Equal(t, []string{CoinbaseOpType, OutputOpType}, opTypes(parsed.Transactions[0]))
+	assert.Equal(
+		t,
+		[]string{StakeInputOpType, StakeRewardOpType, StakeRewardOpType, TreasuryPayoutOpType},
+		opTypes(parsed.Transactions[1]),
+	)
+	assert.Equal(t, []string{InputOpType, OutputOpType}, opTypes(parsed.Transactions[2]))
+
+	// Other outputs of coinstakes are rewards
+	block.Height++
+	parsed, err = client.ParseBlock(context.Background(), block, nil)
+	assert.NoError(t, err)
+	assert.Equal(
+		t,
+		[]string{StakeInputOpType, StakeRewardOpType, StakeRewardOpType, StakeRewardOpType},
+		opTypes(parsed.Transactions[1]),
+	)
+	assert.Equal(t, "300000000", parsed.Transactions[1].Operations[3].Amount.Value)
+	assert.Equal(t, types.CoinCreated, parsed.Transactions[1].Operations[3].CoinChange.CoinAction)
+
+	// The reward is unknown without the staked coins
+	assert.NotContains(t, parsed.Transactions[1].Metadata, "stake_reward")
+
+	// The net reward of the staker is the
+	// outputs minus the staked coins
+	coins := map[string]*types.AccountCoin{
+		CoinIdentifier(input.TxHash, input.Vout): {
+			Account: &types.AccountIdentifier{Address: "ELJeB54eV9QQt6TvMiYx1b678jeHVeAtKr"},
+			Coin: &types.Coin{
+				CoinIdentifier: &types.CoinIdentifier{
+					Identifier: CoinIdentifier(input.TxHash, input.Vout),
+				},
+				Amount: Amount(100*SatoshisInBitcoin, MainnetCurrency),
+			},
+		},
+	}
+	parsed, err = client.ParseBlock(context.Background(), block, coins)
+	assert.NoError(t, err)
+	assert.Equal(
+		t,
+		Amount(8*SatoshisInBitcoin, MainnetCurrency),
+		parsed.Transactions[1].Metadata["stake_reward"],
+	)
+	assert.NotContains(t, parsed.Transactions[2].Metadata, "stake_reward")
+
+	// The treasury payout is not a reward
+	block.Height--
+	parsed, err = client.ParseBlock(context.Background(), block, coins)
+	assert.NoError(t, err)
+	assert.Equal(
+		t,
+		Amount(5*SatoshisInBitcoin, MainnetCurrency),
+		parsed.Transactions[1].Metadata["stake_reward"],
+	)
+	block.Height++
+
+	// Transactions with an empty first output
+	// are not coinstakes outside of index 1
+	block.Txs[1], block.Txs[2] = block.Txs[2], block.Txs[1]
+	parsed, err = client.ParseBlock(context.Background(), block, nil)
+	assert.NoError(t, err)
+	assert.Equal(
+		t,
+		[]string{InputOpType, OutputOpType, OutputOpType, OutputOpType},
+		opTypes(parsed.Transactions[2]),
+	)
+}
+
+func TestParseBlock_MasternodeReward(t *testing.T) {
+	settings := *MainnetChainParams
+	settings.SubsidySchedule = []*SubsidyPhase{
+		{Height: 0, ProofOfStake: true, Subsidy: 5 * SatoshisInBitcoin, MasternodeSubsidy: 3 * SatoshisInBitcoin},
+	}
+	assert.NoError(t, RegisterChainParams(&settings))
+	defer ResetRegistry()
+
+	client := NewClient("", MainnetGenesisBlockIdentifier, nil, MainnetCurrency)
+	client.UseParams(MainnetParams)
+
+	output := func(value float64, index int64, address string) *Output {
+		return &Output{
+			Value: value,
+			Index: index,
+			ScriptPubKey: &ScriptPubKey{
+				Type:      "pubkeyhash",
+				Addresses: []string{address},
+			},
+		}
+	}
+	block := &Block{
+		Hash:              "0000000000000000000000000000000000000000000000000000000000000002",
+		Height:            1000,
+		PreviousBlockHash: "0000000000000000000000000000000000000000000000000000000000000001",
+		Txs: []*Transaction{
+			{
+				Hash:    "d1a1a1a1a1a1a1a1a1a1a1a1a1a1a1a1a1a1a1a1a1a1a1a1a1a1a1a1a1a1a1a1",
+				Inputs:  []*Input{{Coinbase: "03"}},
+				Outputs: []*Output{{Index: 0, ScriptPubKey: &ScriptPubKey{Type: "nonstandard"}}},
+			},
+			{
+				Hash: "d2a2a2a2a2a2a2a2a2a2a2a2a2a2a2a2a2a2a2a2a2a2a2a2a2a2a2a2a2a2a2a2",
+				Inputs: []*Input{{
+					TxHash:    "4852fe372ff7534c16713b3146bbc1e86379c70bea4d5c02fb1fa0112980a081",
+					ScriptSig: &ScriptSig{},
+				}},
+				Outputs: []*Output{
+					{Index: 0, ScriptPubKey: &ScriptPubKey{Type: "nonstandard"}},
+					output(102, 1, "ELJeB54eV9QQt6TvMiYx1b678jeHVeAtKr"),
+					output(3, 2, "EVN5Ah37ygq6Y3CsRpSJNDJtkUBFWkbFtT"),
+				},
+			},
+		},
+	}
+
+	parsed, err := client.ParseBlock(context.Background(), block, nil)
+	assert.NoError(t, err)
+
+	ops := parsed.Transactions[1].Operations
+	assert.Len(t, ops, 4)
+	assert.Equal(t, StakeRewardOpType, ops[2].Type)
+	assert.Equal(t, MasternodeRewardOpType, ops[3].Type)
+	assert.Equal(t, "EVN5Ah37ygq6Y3CsRpSJNDJtkUBFWkbFtT", ops[3].Account.Address)
+	assert.Equal(t, "300000000", ops[3].Amount.Value)
+	assert.Equal(t, types.CoinCreated, ops[3].CoinChange.CoinAction)
+
+	// The masternode payment is not a reward of the staker
+	coin := CoinIdentifier(block.Txs[1].Inputs[0].TxHash, 0)
+	parsed, err = client.ParseBlock(context.Background(), block, map[string]*types.AccountCoin{
+		coin: {
+			Account: &types.AccountIdentifier{Address: "ELJeB54eV9QQt6TvMiYx1b678jeHVeAtKr"},
+			Coin: &types.Coin{
+				CoinIdentifier: &types.CoinIdentifier{Identifier: coin},
+				Amount:         Amount(100*SatoshisInBitcoin, MainnetCurrency),
+			},
+		},
+	})
+	assert.NoError(t, err)
+	assert.Equal(
+		t,
+		Amount(2*SatoshisInBitcoin, MainnetCurrency),
+		parsed.Transactions[1].Metadata["stake_reward"],
+	)
+
+	// Payments of other amounts are stake rewards
+	block.Txs[1].Outputs[2].Value = 4
+	parsed, err = client.ParseBlock(context.Background(), block, nil)
+	assert.NoError(t, err)
+	assert.Equal(t, StakeRewardOpType, parsed.Transactions[1].Operations[3].Type)
+}
+
+func TestParseBlock_Zerocoin(t *testing.T) {
+	client := NewClient("", MainnetGenesisBlockIdentifier, nil, MainnetCurrency)
+
+	mint, err := hex.DecodeString("c1050102030405")
+	assert.NoError(t, err)
+
+	fundingHash := "b1a1a1a1a1a1a1a1a1a1a1a1a1a1a1a1a1a1a1a1a1a1a1a1a1a1a1a1a1a1a1a1"
+	mintHash := "c1a1a1a1a1a1a1a1a1a1a1a1a1a1a1a1a1a1a1a1a1a1a1a1a1a1a1a1a1a1a1a1"
+	spendHash := "d1a1a1a1a1a1a1a1a1a1a1a1a1a1a1a1a1a1a1a1a1a1a1a1a1a1a1a1a1a1a1a1"
+	invalidHash := "e1a1a1a1a1a1a1a1a1a1a1a1a1a1a1a1a1a1a1a1a1a1a1a1a1a1a1a1a1a1a1a1"
+	spend := func(sequence int64) *Input {
+		return &Input{
+			TxHash:    "0000000000000000000000000000000000000000000000000000000000000000",
+			Vout:      4294967295,
+			ScriptSig: &ScriptSig{Hex: "c30102"},
+			Sequence:  sequence,
+		}
+	}
+	block := &Block{
+		Hash:              "0000000000000000000000000000000000000000000000000000000000000002",
+		Height:            2,
+		PreviousBlockHash: "0000000000000000000000000000000000000000000000000000000000000001",
+		Txs: []*Transaction{
+			{
+				Hash: mintHash,
+				Inputs: []*Input{
+					{TxHash: fundingHash, Vout: 0, ScriptSig: &ScriptSig{Hex: "00"}},
+				},
+				Outputs: []*Output{
+					{
+						Value:        10,
+						Index:        0,
+						ScriptPubKey: NewScriptPubKey(mint, MainnetParams),
+					},
+				},
+			},
+			{
+				Hash:   spendHash,
+				Inputs: []*Input{spend(10)},
+				Outputs: []*Output{
+					{
+						Value: 9.99,
+						Index: 0,
+						ScriptPubKey: &ScriptPubKey{
+							Type:      "pubkeyhash",
+							Addresses: []string{"ELJeB54eV9QQt6TvMiYx1b678jeHVeAtKr"},
+						},
+					},
+				},
+			},
+			{
+				Hash:   invalidHash,
+				Inputs: []*Input{spend(3)},
+			},
+		},
+	}
+
+	// Zerocoin spends are not backfilled
+	_, _, ok := client.getInputTxHash(spend(10), 1, 0)
+	assert.False(t, ok)
+
+	parsed, err := client.ParseBlock(context.Background(), block, map[string]*types.AccountCoin{
+		CoinIdentifier(fundingHash, 0): {
+			Account: &types.AccountIdentifier{Address: "ELJeB54eV9QQt6TvMiYx1b678jeHVeAtKr"},
+			Coin: &types.Coin{
+				CoinIdentifier: &types.CoinIdentifier{Identifier: CoinIdentifier(fundingHash, 0)},
+				Amount:         &types.Amount{Value: "1001000000", Currency: MainnetCurrency},
+			},
+		},
+	})
+	assert.NoError(t, err)
+	assert.Len(t, parsed.Transactions, 3)
+
+	// Mints credit the zerocoin account without creating a coin
+	ops := parsed.Transactions[0].Operations
+	assert.Len(t, ops, 2)
+	assert.Equal(t, InputOpType, ops[0].Type)
+	assert.Equal(t, ZerocoinMintOpType, ops[1].Type)
+	assert.Equal(t, ZerocoinAccount(), ops[1].Account)
+	assert.Equal(t, "1000000000", ops[1].Amount.Value)
+	assert.Nil(t, ops[1].CoinChange)
+
+	// Spends debit their denomination from the zerocoin account
+	ops = parsed.Transactions[1].Operations
+	assert.Len(t, ops, 2)
+	assert.Equal(t, ZerocoinSpendOpType, ops[0].Type)
+	assert.Equal(t, ZerocoinAccount(), ops[0].Account)
+	assert.Equal(t, "-1000000000", ops[0].Amount.Value)
+	assert.Nil(t, ops[0].CoinChange)
+	assert.Equal(t, OutputOpType, ops[1].Type)
+	assert.Equal(t, "999000000", ops[1].Amount.Value)
+
+	// Spends of unknown denominations are unparseable
+	ops = parsed.Transactions[2].Operations
+	assert.Len(t, ops, 1)
+	assert.Equal(t, UnparseableOpType, ops[0].Type)
+}
+
+func TestParseBlock_Unparseable(t *testing.T) {
+	client := NewClient("", MainnetGenesisBlockIdentifier, nil, MainnetCurrency)
+
+	unparseableHash := "d1a1a1a1a1a1a1a1a1a1a1a1a1a1a1a1a1a1a1a1a1a1a1a1a1a1a1a1a1a1a1a1"
+	spenderHash := "e1a1a1a1a1a1a1a1a1a1a1a1a1a1a1a1a1a1a1a1a1a1a1a1a1a1a1a1a1a1a1a1"
+	block := &Block{
+		Hash:              "0000000000000000000000000000000000000000000000000000000000000003",
+		Height:            3,
+		PreviousBlockHash: "0000000000000000000000000000000000000000000000000000000000000002",
+		Txs: []*Transaction{
+			{
+				Hex:  "deadbeef",
+				Hash: unparseableHash,
+				Outputs: []*Output{
+					{
+						Value: -1,
+						Index: 0,
+						ScriptPubKey: &ScriptPubKey{
+							Addresses: []string{"address"},
+						},
+					},
+				},
+			},
+			{
+				Hex:  "beefdead",
+				Hash: spenderHash,
+				Inputs: []*Input{
+					{
+						TxHash: "f1a1a1a1a1a1a1a1a1a1a1a1a1a1a1a1a1a1a1a1a1a1a1a1a1a1a1a1a1a1a1a1",
+						Vout:   0,
+					},
+				},
+			},
+		},
+	}
+
+	// Spending a coin created by an unparseable
+	// transaction is unparseable.
+	parsed, err := client.ParseBlock(context.Background(), block, map[string]*types.AccountCoin{
+		CoinIdentifier("f1a1a1a1a1a1a1a1a1a1a1a1a1a1a1a1a1a1a1a1a1a1a1a1a1a1a1a1a1a1a1a1", 0): nil,
+	})
+	assert.NoError(t, err)
+	assert.Len(t, parsed.Transactions, 2)
+
+	for j, tx := range parsed.Transactions {
+		assert.Equal(t, block.Txs[j].Hash, tx.TransactionIdentifier.Hash)
+		assert.Len(t, tx.Operations, 1)
+		assert.Equal(t, UnparseableOpType, tx.Operations[0].Type)
+		assert.Nil(t, tx.Operations[0].CoinChange)
+
+		var metadata UnparseableMetadata
+		assert.NoError(t, types.UnmarshalMap(tx.Operations[0].Metadata, &metadata))
+		assert.Equal(t, block.Txs[j].Hex, metadata.Hex)
+		assert.NotEmpty(t, metadata.Error)
+	}
+
+	// Spending an unknown coin still halts parsing
+	_, err = client.ParseBlock(context.Background(), block, map[string]*types.AccountCoin{})
+	assert.True(t, errors.Is(err, ErrPreviousTransactionMissing))
+}
+
+func TestParseBlock_UnparseableOutput(t *testing.T) {
+	client := NewClient("", MainnetGenesisBlockIdentifier, nil, MainnetCurrency)
+
+	spentHash := "f1a1a1a1a1a1a1a1a1a1a1a1a1a1a1a1a1a1a1a1a1a1a1a1a1a1a1a1a1a1a1a1"
+	partialHash := "d1a1a1a1a1a1a1a1a1a1a1a1a1a1a1a1a1a1a1a1a1a1a1a1a1a1a1a1a1a1a1a1"
+	spenderHash := "e1a1a1a1a1a1a1a1a1a1a1a1a1a1a1a1a1a1a1a1a1a1a1a1a1a1a1a1a1a1a1a1"
+	block := &Block{
+		Hash:              "0000000000000000000000000000000000000000000000000000000000000003",
+		Height:            3,
+		PreviousBlockHash: "0000000000000000000000000000000000000000000000000000000000000002",
+		Txs: []*Transaction{
+			{
+				Hex:  "deadbeef",
+				Hash: partialHash,
+				Inputs: []*Input{
+					{
+						TxHash: spentHash,
+						Vout:   0,
+					},
+				},
+				Outputs: []*Output{
+					{
+						Value: 1,
+						Index: 0,
+						ScriptPubKey: &ScriptPubKey{
+							Addresses: []string{"address"},
+						},
+					},
+					{
+						Value: -1,
+						Index: 1,
+						ScriptPubKey: &ScriptPubKey{
+							Addresses: []string{"address"},
+						},
+					},
+				},
+			},
+			{
+				Hex:  "beefdead",
+				Hash: spenderHash,
+				Inputs: []*Input{
+					{
+						TxHash: partialHash,
+						Vout:   1,
+					},
+				},
+			},
+		},
+	}
+
+	spentCoin := CoinIdentifier(spentHash, 0)
+	parsed, err := client.ParseBlock(context.Background(), block, map[string]*types.AccountCoin{
+		spentCoin: {
+			Account: &types.AccountIdentifier{Address: "address"},
+			Coin: &types.Coin{
+				CoinIdentifier: &types.CoinIdentifier{Identifier: spentCoin},
+				Amount:         Amount(200000000, MainnetCurrency),
+			},
+		},
+	})
+	assert.NoError(t, err)
+	assert.Len(t, parsed.Transactions, 2)
+
+	// The input is still spent and the parseable
+	// output is still created.
+	ops := parsed.Transactions[0].Operations
+	assert.Len(t, ops, 3)
+	assert.Equal(t, InputOpType, ops[0].Type)
+	assert.Equal(t, types.CoinSpent, ops[0].CoinChange.CoinAction)
+	assert.Equal(t, OutputOpType, ops[1].Type)
+	assert.Equal(t, CoinIdentifier(partialHash, 0), ops[1].CoinChange.CoinIdentifier.Identifier)
+
+	// Only the unparseable output is replaced.
+	assert.Equal(t, UnparseableOpType, ops[2].Type)
+	assert.Equal(t, int64(2), ops[2].OperationIdentifier.Index)
+	assert.Equal(t, int64(1), *ops[2].OperationIdentifier.NetworkIndex)
+	assert.Nil(t, ops[2].CoinChange)
+
+	var metadata UnparseableMetadata
+	assert.NoError(t, types.UnmarshalMap(ops[2].Metadata, &metadata))
+	assert.Equal(t, "deadbeef", metadata.Hex)
+	assert.NotEmpty(t, metadata.Error)
+
+	// Spending the unparseable output (in the same
+	// block) is unparseable.
+	ops = parsed.Transactions[1].Operations
+	assert.Len(t, ops, 1)
+	assert.Equal(t, UnparseableOpType, ops[0].Type)
+	assert.Nil(t, ops[0].OperationIdentifier.NetworkIndex)
+}
@@ -0,0 +1,175 @@
+// Copyright 2020 Coinbase, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package bitcoin
+
+import (
+	"errors"
+	"fmt"
+	"math/big"
+	"strconv"
+	"strings"
+
+	"github.com/btcsuite/btcutil"
+	"github.com/coinbase/rosetta-sdk-go/types"
+)
+
+const (
+	// DefaultLocale is the locale
+	// amounts are formatted in by
+	// default.
+	DefaultLocale = "en"
+
+	// digitsPerGroup is the number of integer
+	// digits between group separators.
+	digitsPerGroup = 3
+)
+
+var (
+	// ErrInvalidAmount is returned when an
+	// amount can't be converted.
+	ErrInvalidAmount = errors.New("invalid amount")
+
+	// ErrUnsupportedLocale is returned when amounts
+	// are formatted in a locale without a NumberFormat.
+	ErrUnsupportedLocale = errors.New("unsupported locale")
+
+	// NumberFormats are the NumberFormat
+	// of each supported locale.
+	NumberFormats = map[string]*NumberFormat{
+		"en": {GroupSeparator: ",", DecimalSeparator: "."},
+		"de": {GroupSeparator: ".", DecimalSeparator: ","},
+		"fr": {GroupSeparator: "\u202f", DecimalSeparator: ","},
+		"ch": {GroupSeparator: "'", DecimalSeparator: "."},
+	}
+)
+
+// NumberFormat are the separators
+// of numbers in a locale.
+type NumberFormat struct {
+	GroupSeparator   string
+	DecimalSeparator string
+}
+
+// Satoshis converts an amount in whole coins, as returned
+// by the node (i.e. 0.5), to satoshis (rounding to the
+// nearest satoshi).
+func Satoshis(coins float64) (int64, error) {
+	amount, err := btcutil.NewAmount(coins)
+	if err != nil {
+		return 0, fmt.Errorf("%w: %s", ErrInvalidAmount, err.Error())
+	}
+
+	return int64(amount), nil
+}
+
+// ParseDecimal converts a decimal amount in whole coins
+// (i.e. "0.5") to satoshis without loss of precision.
+// Amounts with more than Decimals decimal places are
+// rejected.
+func ParseDecimal(value string) (int64, error) {
+	negative := strings.HasPrefix(value, "-")
+	digits := strings.TrimPrefix(value, "-")
+
+	integer, fraction := digits, ""
+	if i := strings.Index(digits, "."); i >= 0 {
+		integer, fraction = digits[:i], digits[i+1:]
+	}
+
+	if len(integer) == 0 || len(fraction) > Decimals || strings.ContainsAny(integer+fraction, "+-") {
+		return 0, fmt.Errorf("%w: %s is not a decimal amount", ErrInvalidAmount, value)
+	}
+
+	fraction += strings.Repeat("0", Decimals-len(fraction))
+	satoshis, err := strconv.ParseInt(integer+fraction, 10, 64)
+	if err != nil {
+		return 0, fmt.Errorf("%w: %s is not a decimal amount", ErrInvalidAmount, value)
+	}
+
+	if negative {
+		satoshis = -satoshis
+	}
+
+	return satoshis, nil
+}
+
+// FormatDecimal formats satoshis in whole coins
+// with Decimals decimal places (i.e. "0.50000000").
+func FormatDecimal(satoshis int64) string {
+	return formatUnits(big.NewInt(satoshis), Decimals, NumberFormats[DefaultLocale], false)
+}
+
+// Amount returns the *types.Amount of satoshis in currency
+// (the canonical representation of amounts in the API).
+func Amount(satoshis int64, currency *types.Currency) *types.Amount {
+	return &types.Amount{
+		Value:    strconv.FormatInt(satoshis, 10),
+		Currency: currency,
+	}
+}
+
+// FormatAmount formats amount in whole units of its currency
+// with the separators of locale, followed by the symbol of
+// the currency (i.e. "1,234.50000000 EUNO").
+func FormatAmount(amount *types.Amount, locale string) (string, error) {
+	format, ok := NumberFormats[locale]
+	if !ok {
+		return "", fmt.Errorf("%w: %s", ErrUnsupportedLocale, locale)
+	}
+
+	value, err := types.AmountValue(amount)
+	if err != nil {
+		return "", fmt.Errorf("%w: %s", ErrInvalidAmount, err.Error())
+	}
+
+	return fmt.Sprintf(
+		"%s %s",
+		formatUnits(value, amount.Currency.Decimals, format, true),
+		amount.Currency.Symbol,
+	), nil
+}
+
+// formatUnits formats value (in atomic units) in whole
+// units with decimals decimal places, grouping the
+// integer digits if group is true.
+func formatUnits(value *big.Int, decimals int32, format *NumberFormat, group bool) string {
+	sign := ""
+	if value.Sign() < 0 {
+		sign = "-"
+	}
+
+	digits := new(big.Int).Abs(value).String()
+	if pad := int(decimals) + 1 - len(digits); pad > 0 {
+		digits = strings.Repeat("0", pad) + digits
+	}
+
+	integer := digits[:len(digits)-int(decimals)]
+	fraction := digits[len(digits)-int(decimals):]
+	if group {
+		var grouped strings.Builder
+		for i, digit := range integer {
+			if i > 0 && (len(integer)-i)%digitsPerGroup == 0 {
+				grouped.WriteString(format.GroupSeparator)
+			}
+			grouped.WriteRune(digit)
+		}
+		integer = grouped.String()
+	}
+
+	if len(fraction) == 0 {
+		return sign + integer
+	}
+
+	return sign + integer + format.DecimalSeparator + fraction
+}
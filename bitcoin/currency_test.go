@@ -0,0 +1,126 @@
+// Copyright 2020 Coinbase, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package bitcoin
+
+import (
+	"errors"
+	"math"
+	"testing"
+
+	"github.com/coinbase/rosetta-sdk-go/types"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSatoshis(t *testing.T) {
+	satoshis, err := Satoshis(0.1)
+	assert.NoError(t, err)
+	assert.Equal(t, int64(10000000), satoshis)
+
+	// Amounts are rounded to the nearest satoshi
+	satoshis, err = Satoshis(0.000000016)
+	assert.NoError(t, err)
+	assert.Equal(t, int64(2), satoshis)
+
+	_, err = Satoshis(math.NaN())
+	assert.True(t, errors.Is(err, ErrInvalidAmount))
+}
+
+func TestParseDecimal(t *testing.T) {
+	tests := map[string]struct {
+		value string
+
+		expectedSatoshis int64
+		expectedError    bool
+	}{
+		"whole":           {value: "12", expectedSatoshis: 1200000000},
+		"fraction":        {value: "0.5", expectedSatoshis: 50000000},
+		"all decimals":    {value: "1.23456789", expectedSatoshis: 123456789},
+		"negative":        {value: "-0.00000001", expectedSatoshis: -1},
+		"too precise":     {value: "0.123456789", expectedError: true},
+		"no integer":      {value: ".5", expectedError: true},
+		"double sign":     {value: "--1", expectedError: true},
+		"signed fraction": {value: "1.-5", expectedError: true},
+		"not a number":    {value: "1.5a", expectedError: true},
+		"overflow":        {value: "100000000000000000", expectedError: true},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			satoshis, err := ParseDecimal(test.value)
+			if test.expectedError {
+				assert.True(t, errors.Is(err, ErrInvalidAmount))
+				return
+			}
+
+			assert.NoError(t, err)
+			assert.Equal(t, test.expectedSatoshis, satoshis)
+		})
+	}
+}
+
+func TestFormatDecimal(t *testing.T) {
+	assert.Equal(t, "0.00000000", FormatDecimal(0))
+	assert.Equal(t, "0.00000001", FormatDecimal(1))
+	assert.Equal(t, "1234.50000000", FormatDecimal(123450000000))
+	assert.Equal(t, "-0.50000000", FormatDecimal(-50000000))
+
+	satoshis, err := ParseDecimal(FormatDecimal(123456789))
+	assert.NoError(t, err)
+	assert.Equal(t, int64(123456789), satoshis)
+}
+
+func TestAmount(t *testing.T) {
+	assert.Equal(t, &types.Amount{
+		Value:    "-100",
+		Currency: MainnetCurrency,
+	}, Amount(-100, MainnetCurrency))
+}
+
+func TestFormatAmount(t *testing.T) {
+	amount := Amount(123456789012345, MainnetCurrency)
+	tests := map[string]string{
+		"en": "1,234,567.89012345 " + MainnetCurrency.Symbol,
+		"de": "1.234.567,89012345 " + MainnetCurrency.Symbol,
+		"fr": "1\u202f234\u202f567,89012345 " + MainnetCurrency.Symbol,
+		"ch": "1'234'567.89012345 " + MainnetCurrency.Symbol,
+	}
+
+	for locale, expected := range tests {
+		t.Run(locale, func(t *testing.T) {
+			formatted, err := FormatAmount(amount, locale)
+			assert.NoError(t, err)
+			assert.Equal(t, expected, formatted)
+		})
+	}
+
+	formatted, err := FormatAmount(Amount(-123, MainnetCurrency), DefaultLocale)
+	assert.NoError(t, err)
+	assert.Equal(t, "-0.00000123 "+MainnetCurrency.Symbol, formatted)
+
+	// Currencies without decimals
+	// have no decimal separator.
+	formatted, err = FormatAmount(&types.Amount{
+		Value:    "1000",
+		Currency: &types.Currency{Symbol: "UNIT"},
+	}, DefaultLocale)
+	assert.NoError(t, err)
+	assert.Equal(t, "1,000 UNIT", formatted)
+
+	_, err = FormatAmount(amount, "xx")
+	assert.True(t, errors.Is(err, ErrUnsupportedLocale))
+
+	_, err = FormatAmount(&types.Amount{Value: "1.5", Currency: MainnetCurrency}, DefaultLocale)
+	assert.True(t, errors.Is(err, ErrInvalidAmount))
+}
@@ -0,0 +1,63 @@
+// Copyright 2020 Coinbase, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package bitcoin
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/btcsuite/btcd/chaincfg"
+	"github.com/btcsuite/btcutil/hdkeychain"
+)
+
+const (
+	// BIP44Purpose is the purpose (the first
+	// level) of BIP44 derivation paths.
+	BIP44Purpose = 44
+
+	// ExternalChain and InternalChain are the change
+	// levels of BIP44 derivation paths of receiving
+	// and change addresses.
+	ExternalChain = 0
+	InternalChain = 1
+)
+
+// ErrInvalidDerivationPath is returned when a
+// derivation path is not a valid BIP44 path.
+var ErrInvalidDerivationPath = errors.New("invalid derivation path")
+
+// DerivationPath returns the canonical BIP44 derivation path
+// (m/44'/coin_type'/account'/change/index) of the key at index
+// of account on the network with params, whose HDCoinType is
+// the coin type (so that wallets derive the same addresses).
+func DerivationPath(params *chaincfg.Params, account uint32, change uint32, index uint32) (string, error) {
+	switch {
+	case account >= hdkeychain.HardenedKeyStart:
+		return "", fmt.Errorf("%w: account %d is too large", ErrInvalidDerivationPath, account)
+	case change != ExternalChain && change != InternalChain:
+		return "", fmt.Errorf("%w: change %d is not 0 or 1", ErrInvalidDerivationPath, change)
+	case index >= hdkeychain.HardenedKeyStart:
+		return "", fmt.Errorf("%w: index %d is too large", ErrInvalidDerivationPath, index)
+	}
+
+	return fmt.Sprintf(
+		"m/%d'/%d'/%d'/%d/%d",
+		BIP44Purpose,
+		params.HDCoinType,
+		account,
+		change,
+		index,
+	), nil
+}
@@ -0,0 +1,53 @@
+// Copyright 2020 Coinbase, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package bitcoin
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/btcsuite/btcutil/hdkeychain"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDerivationPath(t *testing.T) {
+	path, err := DerivationPath(MainnetParams, 0, ExternalChain, 0)
+	assert.NoError(t, err)
+	assert.Equal(t, "m/44'/0'/0'/0/0", path)
+
+	path, err = DerivationPath(TestnetParams, 3, InternalChain, 12)
+	assert.NoError(t, err)
+	assert.Equal(t, "m/44'/1'/3'/1/12", path)
+
+	// The coin type is the HDCoinType of the network
+	params := CloneParams(MainnetParams)
+	params.HDCoinType = 119
+	path, err = DerivationPath(params, 0, ExternalChain, 5)
+	assert.NoError(t, err)
+	assert.Equal(t, "m/44'/119'/0'/0/5", path)
+
+	invalid := map[string][3]uint32{
+		"hardened account": {hdkeychain.HardenedKeyStart, ExternalChain, 0},
+		"invalid change":   {0, 2, 0},
+		"hardened index":   {0, ExternalChain, hdkeychain.HardenedKeyStart},
+	}
+
+	for name, test := range invalid {
+		t.Run(name, func(t *testing.T) {
+			_, err := DerivationPath(MainnetParams, test[0], test[1], test[2])
+			assert.True(t, errors.Is(err, ErrInvalidDerivationPath))
+		})
+	}
+}
@@ -0,0 +1,144 @@
+// Copyright 2020 Coinbase, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package bitcoin
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"math/big"
+	"time"
+
+	"github.com/btcsuite/btcd/blockchain"
+	"github.com/btcsuite/btcd/chaincfg"
+	"github.com/btcsuite/btcd/wire"
+)
+
+const (
+	// maxSpacingFactorV2 is the maximum spacing between
+	// two blocks (as a multiple of the target spacing)
+	// used to retarget once time protocol V2 is active.
+	maxSpacingFactorV2 = 10
+
+	// timeV2TargetShift is the number of bits the target
+	// is shifted left by (reducing the difficulty by a
+	// factor of 16) at the first block of time
+	// protocol V2.
+	timeV2TargetShift = 4
+)
+
+// ErrInvalidDifficultyParams is returned when the
+// next difficulty can't be computed with the
+// provided params.
+var ErrInvalidDifficultyParams = errors.New("invalid difficulty params")
+
+// DifficultyParams are the consensus params of the proof
+// of stake retarget, which are not part of *chaincfg.Params.
+type DifficultyParams struct {
+	// TargetSpacing is the desired time
+	// between blocks.
+	TargetSpacing time.Duration
+
+	// TargetTimespan and TargetTimespanV2 are the
+	// timespans the retarget is smoothed over before
+	// and after time protocol V2 is active.
+	TargetTimespan   time.Duration
+	TargetTimespanV2 time.Duration
+
+	// PowLimit and PowLimitV2 are the highest targets
+	// (lowest difficulty) before and after time protocol
+	// V2 is active.
+	PowLimit   *big.Int
+	PowLimitV2 *big.Int
+
+	// TimeProtocolV2Height is the first block time
+	// protocol V2 is active in (math.MaxInt32 if it
+	// never activates).
+	TimeProtocolV2Height int32
+}
+
+// NewDifficultyParams returns the *DifficultyParams of
+// a network with params on which time protocol V2 never
+// activates.
+func NewDifficultyParams(params *chaincfg.Params) *DifficultyParams {
+	return &DifficultyParams{
+		TargetSpacing:        params.TargetTimePerBlock,
+		TargetTimespan:       params.TargetTimespan,
+		TargetTimespanV2:     params.TargetTimespan,
+		PowLimit:             params.PowLimit,
+		PowLimitV2:           params.PowLimit,
+		TimeProtocolV2Height: math.MaxInt32,
+	}
+}
+
+// CalcNextRequiredDifficulty returns the compact target (bits)
+// required of the block following headers (the header history
+// of the chain from genesis, so headers[i] is the header at
+// height i) with the proof of stake retarget: the target moves
+// towards the target spacing after every block, based on the
+// time between the last two blocks.
+func CalcNextRequiredDifficulty(
+	params *DifficultyParams,
+	headers []*wire.BlockHeader,
+) (uint32, error) {
+	if len(headers) == 0 {
+		return 0, fmt.Errorf("%w: no headers provided", ErrInvalidDifficultyParams)
+	}
+
+	lastHeight := int32(len(headers) - 1)
+	timeV2 := lastHeight+1 >= params.TimeProtocolV2Height
+
+	limit, timespan := params.PowLimit, params.TargetTimespan
+	if timeV2 {
+		limit, timespan = params.PowLimitV2, params.TargetTimespanV2
+	}
+
+	spacing := int64(params.TargetSpacing / time.Second)
+	if spacing <= 0 || limit == nil || int64(timespan/time.Second) < spacing {
+		return 0, fmt.Errorf(
+			"%w: target spacing %s does not fit in timespan %s",
+			ErrInvalidDifficultyParams,
+			params.TargetSpacing,
+			timespan,
+		)
+	}
+	interval := int64(timespan/time.Second) / spacing
+
+	last := headers[lastHeight]
+	actualSpacing := int64(0)
+	if lastHeight > 0 {
+		actualSpacing = last.Timestamp.Unix() - headers[lastHeight-1].Timestamp.Unix()
+	}
+	if actualSpacing < 0 {
+		actualSpacing = 1
+	}
+	if timeV2 && actualSpacing > spacing*maxSpacingFactorV2 {
+		actualSpacing = spacing * maxSpacingFactorV2
+	}
+
+	target := blockchain.CompactToBig(last.Bits)
+	if timeV2 && lastHeight < params.TimeProtocolV2Height {
+		target.Lsh(target, timeV2TargetShift)
+	}
+
+	target.Mul(target, big.NewInt((interval-1)*spacing+2*actualSpacing))
+	target.Div(target, big.NewInt((interval+1)*spacing))
+
+	if target.Sign() <= 0 || target.Cmp(limit) > 0 {
+		target = limit
+	}
+
+	return blockchain.BigToCompact(target), nil
+}
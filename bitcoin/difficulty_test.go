@@ -0,0 +1,114 @@
+// Copyright 2020 Coinbase, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package bitcoin
+
+import (
+	"errors"
+	"math"
+	"math/big"
+	"testing"
+	"time"
+
+	"github.com/btcsuite/btcd/wire"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCalcNextRequiredDifficulty(t *testing.T) {
+	one := big.NewInt(1)
+	params := &DifficultyParams{
+		TargetSpacing:        time.Minute,
+		TargetTimespan:       40 * time.Minute,
+		TargetTimespanV2:     30 * time.Minute,
+		PowLimit:             new(big.Int).Sub(new(big.Int).Lsh(one, 232), one),
+		PowLimitV2:           new(big.Int).Sub(new(big.Int).Lsh(one, 236), one),
+		TimeProtocolV2Height: 10,
+	}
+
+	// newHeaders returns count headers with bits one minute
+	// apart (except the last, which is spacing after the
+	// previous one).
+	newHeaders := func(count int, bits uint32, spacing int64) []*wire.BlockHeader {
+		headers := make([]*wire.BlockHeader, count)
+		timestamp := int64(1000)
+		for i := range headers {
+			if i == count-1 {
+				timestamp += spacing - 60
+			}
+
+			headers[i] = &wire.BlockHeader{
+				Bits:      bits,
+				Timestamp: time.Unix(timestamp, 0),
+			}
+			timestamp += 60
+		}
+
+		return headers
+	}
+
+	tests := map[string]struct {
+		headers []*wire.BlockHeader
+		bits    uint32
+	}{
+		"on target": {
+			headers: newHeaders(5, 0x1d00ffff, 60),
+			bits:    0x1d00ffff,
+		},
+		"fast block": {
+			headers: newHeaders(5, 0x1d00ffff, 30),
+			bits:    0x1d00f9c0,
+		},
+		"slow block": {
+			headers: newHeaders(5, 0x1d00ffff, 120),
+			bits:    0x1d010c7b,
+		},
+		"spacing not clamped before V2": {
+			headers: newHeaders(5, 0x1d00ffff, 10000),
+			bits:    0x1d0914c7,
+		},
+		"pow limit": {
+			headers: newHeaders(5, 0x1e00ffff, 10000),
+			bits:    0x1e00ffff,
+		},
+		"first block of V2": {
+			headers: newHeaders(10, 0x1d00ffff, 60),
+			bits:    0x1d0ffff0,
+		},
+		"spacing clamped after V2": {
+			headers: newHeaders(20, 0x1d00ffff, 10000),
+			bits:    0x1d0194a3,
+		},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			bits, err := CalcNextRequiredDifficulty(params, test.headers)
+			assert.NoError(t, err)
+			assert.Equal(t, test.bits, bits)
+		})
+	}
+
+	_, err := CalcNextRequiredDifficulty(params, nil)
+	assert.True(t, errors.Is(err, ErrInvalidDifficultyParams))
+
+	invalid := *params
+	invalid.TargetSpacing = 0
+	_, err = CalcNextRequiredDifficulty(&invalid, newHeaders(5, 0x1d00ffff, 60))
+	assert.True(t, errors.Is(err, ErrInvalidDifficultyParams))
+
+	mainnet := NewDifficultyParams(MainnetParams)
+	assert.Equal(t, MainnetParams.TargetTimePerBlock, mainnet.TargetSpacing)
+	assert.Equal(t, MainnetParams.PowLimit, mainnet.PowLimitV2)
+	assert.Equal(t, int32(math.MaxInt32), mainnet.TimeProtocolV2Height)
+}
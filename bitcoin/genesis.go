@@ -0,0 +1,168 @@
+// Copyright 2020 Coinbase, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package bitcoin
+
+import (
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/btcsuite/btcd/chaincfg"
+	"github.com/btcsuite/btcd/chaincfg/chainhash"
+	"github.com/btcsuite/btcd/txscript"
+	"github.com/btcsuite/btcd/wire"
+)
+
+const (
+	// genesisCoinbaseBits and genesisCoinbaseExtraNonce are
+	// pushed before the message in the coinbase of a genesis
+	// block (as in CreateGenesisBlock of bitcoind, whatever
+	// the bits of the block).
+	genesisCoinbaseBits       = 486604799
+	genesisCoinbaseExtraNonce = 4
+
+	// defaultGenesisVersion is the version
+	// of a genesis block by default.
+	defaultGenesisVersion = 1
+
+	// defaultGenesisReward is the value (in satoshis) of
+	// the output of a genesis block by default.
+	defaultGenesisReward = 50 * SatoshisInBitcoin
+
+	// defaultGenesisOutputScript is the script of the output
+	// of a genesis block by default (the pay-to-pubkey script
+	// of the Bitcoin genesis block).
+	defaultGenesisOutputScript = "4104678afdb0fe5548271967f1a67130b7105cd6a828e03909a67962e0ea1f61" +
+		"deb649f6bc3f4cef38c4f35504e51ec112de5c384df7ba0b8d578a4c702b6bf11d5fac"
+)
+
+// ErrInvalidGenesis is returned when a
+// genesis block can't be built.
+var ErrInvalidGenesis = errors.New("invalid genesis")
+
+// GenesisBuilder describes the genesis block of a custom
+// chain (i.e. a private devnet), built as bitcoind's
+// CreateGenesisBlock does: a block with a single coinbase
+// transaction whose input pushes Message. Fields that are
+// omitted take the values of the Bitcoin genesis block.
+type GenesisBuilder struct {
+	// Timestamp is the time of the
+	// block (in seconds since epoch).
+	Timestamp int64  `json:"timestamp"`
+	Nonce     uint32 `json:"nonce"`
+
+	// Bits is the compact proof of
+	// work target of the block.
+	Bits    uint32 `json:"bits"`
+	Version int32  `json:"version,omitempty"`
+
+	// Message is the message of the coinbase
+	// (i.e. a newspaper headline).
+	Message string `json:"message"`
+
+	// Reward is the value (in satoshis) of the output of
+	// the coinbase and OutputScript its hex-encoded script.
+	Reward       *int64 `json:"reward,omitempty"`
+	OutputScript string `json:"output_script,omitempty"`
+}
+
+// Build returns the genesis block described by g.
+func (g *GenesisBuilder) Build() (*wire.MsgBlock, error) {
+	if len(g.Message) == 0 {
+		return nil, fmt.Errorf("%w: no coinbase message provided", ErrInvalidGenesis)
+	}
+
+	if g.Bits == 0 {
+		return nil, fmt.Errorf("%w: no bits provided", ErrInvalidGenesis)
+	}
+
+	reward := int64(defaultGenesisReward)
+	if g.Reward != nil {
+		reward = *g.Reward
+	}
+
+	if reward < 0 {
+		return nil, fmt.Errorf("%w: reward %d is negative", ErrInvalidGenesis, reward)
+	}
+
+	outputScriptHex := g.OutputScript
+	if len(outputScriptHex) == 0 {
+		outputScriptHex = defaultGenesisOutputScript
+	}
+
+	outputScript, err := hex.DecodeString(outputScriptHex)
+	if err != nil {
+		return nil, fmt.Errorf(
+			"%w: %s is not a valid output script",
+			ErrInvalidGenesis,
+			outputScriptHex,
+		)
+	}
+
+	signatureScript, err := txscript.NewScriptBuilder().
+		AddInt64(genesisCoinbaseBits).
+		// bitcoind pushes the extra nonce as data (and
+		// not as the small integer opcode AddData and
+		// AddInt64 would use).
+		AddOps([]byte{txscript.OP_DATA_1, genesisCoinbaseExtraNonce}).
+		AddData([]byte(g.Message)).
+		Script()
+	if err != nil {
+		return nil, fmt.Errorf("%w: %s", ErrInvalidGenesis, err.Error())
+	}
+
+	coinbase := wire.NewMsgTx(wire.TxVersion)
+	coinbase.AddTxIn(&wire.TxIn{
+		PreviousOutPoint: wire.OutPoint{Index: wire.MaxPrevOutIndex},
+		SignatureScript:  signatureScript,
+		Sequence:         wire.MaxTxInSequenceNum,
+	})
+	coinbase.AddTxOut(wire.NewTxOut(reward, outputScript))
+
+	version := g.Version
+	if version == 0 {
+		version = defaultGenesisVersion
+	}
+
+	return &wire.MsgBlock{
+		Header: wire.BlockHeader{
+			Version: version,
+			// The merkle root of a block with a single
+			// transaction is the hash of the transaction.
+			MerkleRoot: coinbase.TxHash(),
+			Timestamp:  time.Unix(g.Timestamp, 0),
+			Bits:       g.Bits,
+			Nonce:      g.Nonce,
+		},
+		Transactions: []*wire.MsgTx{coinbase},
+	}, nil
+}
+
+// Apply builds the genesis block described by g and
+// makes it the genesis block of params, returning
+// its hash.
+func (g *GenesisBuilder) Apply(params *chaincfg.Params) (*chainhash.Hash, error) {
+	block, err := g.Build()
+	if err != nil {
+		return nil, err
+	}
+
+	hash := block.BlockHash()
+	params.GenesisBlock = block
+	params.GenesisHash = &hash
+
+	return &hash, nil
+}
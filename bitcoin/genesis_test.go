@@ -0,0 +1,95 @@
+// Copyright 2020 Coinbase, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package bitcoin
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/btcsuite/btcd/chaincfg"
+	"github.com/stretchr/testify/assert"
+)
+
+const bitcoinGenesisMessage = "The Times 03/Jan/2009 Chancellor on brink of second bailout for banks"
+
+func TestGenesisBuilder(t *testing.T) {
+	negative := int64(-1)
+	tests := map[string]struct {
+		builder *GenesisBuilder
+
+		expectedParams *chaincfg.Params
+		expectedError  bool
+	}{
+		"bitcoin testnet3": {
+			builder: &GenesisBuilder{
+				Timestamp: 1296688602,
+				Nonce:     414098458,
+				Bits:      0x1d00ffff,
+				Message:   bitcoinGenesisMessage,
+			},
+			expectedParams: &chaincfg.TestNet3Params,
+		},
+		"bitcoin regtest": {
+			builder: &GenesisBuilder{
+				Timestamp: 1296688602,
+				Nonce:     2,
+				Bits:      0x207fffff,
+				Message:   bitcoinGenesisMessage,
+			},
+			expectedParams: &chaincfg.RegressionNetParams,
+		},
+		"no message": {
+			builder:       &GenesisBuilder{Bits: 0x207fffff},
+			expectedError: true,
+		},
+		"no bits": {
+			builder:       &GenesisBuilder{Message: bitcoinGenesisMessage},
+			expectedError: true,
+		},
+		"negative reward": {
+			builder: &GenesisBuilder{
+				Bits:    0x207fffff,
+				Message: bitcoinGenesisMessage,
+				Reward:  &negative,
+			},
+			expectedError: true,
+		},
+		"invalid output script": {
+			builder: &GenesisBuilder{
+				Bits:         0x207fffff,
+				Message:      bitcoinGenesisMessage,
+				OutputScript: "zz",
+			},
+			expectedError: true,
+		},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			var params chaincfg.Params
+			hash, err := test.builder.Apply(&params)
+			if test.expectedError {
+				assert.True(t, errors.Is(err, ErrInvalidGenesis))
+				assert.Nil(t, params.GenesisBlock)
+				return
+			}
+
+			assert.NoError(t, err)
+			assert.Equal(t, test.expectedParams.GenesisHash, hash)
+			assert.Equal(t, test.expectedParams.GenesisHash, params.GenesisHash)
+			assert.Equal(t, test.expectedParams.GenesisBlock, params.GenesisBlock)
+		})
+	}
+}
@@ -0,0 +1,147 @@
+// Copyright 2020 Coinbase, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package bitcoin
+
+import (
+	"errors"
+	"expvar"
+	"fmt"
+
+	"github.com/btcsuite/btcd/txscript"
+)
+
+const (
+	// DefaultMaxBlockSize is the largest block (in bytes)
+	// accepted from the node (the consensus limit on the
+	// serialized size of a block).
+	DefaultMaxBlockSize = 4000000
+
+	// minTransactionSize is the size (in bytes)
+	// of the smallest valid transaction.
+	minTransactionSize = 60
+
+	// DefaultMaxBlockTransactions is the largest number of
+	// transactions in a block accepted from the node (the
+	// number of the smallest transactions that fit in a
+	// block of DefaultMaxBlockSize).
+	DefaultMaxBlockTransactions = DefaultMaxBlockSize / minTransactionSize
+
+	// DefaultMaxScriptSize is the longest script (in bytes)
+	// accepted from the node (the consensus limit on the
+	// size of an executed script).
+	DefaultMaxScriptSize = txscript.MaxScriptSize
+)
+
+var (
+	// ErrBlockExceedsLimits is returned when the node
+	// returns a block exceeding the *Limits of the
+	// client.
+	ErrBlockExceedsLimits = errors.New("block exceeds limits")
+
+	// rejectedBlocksMetric counts the blocks returned
+	// by the node that exceeded the *Limits of the
+	// client (of each network).
+	rejectedBlocksMetric = expvar.NewMap("bitcoin_rejected_blocks")
+)
+
+// Limits are the bounds on the data returned by the node
+// (protecting the indexer from a compromised node feeding
+// it crafted payloads). A zero limit is not checked.
+type Limits struct {
+	MaxBlockSize         int64
+	MaxBlockTransactions int64
+	MaxScriptSize        int64
+}
+
+// hexSize returns the size (in
+// bytes) of hex-encoded data.
+func hexSize(data string) int64 {
+	return int64(len(data) / 2) // nolint:gomnd
+}
+
+// CheckBlock returns an error if block exceeds l. The size of
+// the block is the largest of the size reported by the node
+// and the size of its raw transactions.
+func (l *Limits) CheckBlock(block *Block) error {
+	if l.MaxBlockTransactions > 0 && int64(len(block.Txs)) > l.MaxBlockTransactions {
+		return fmt.Errorf(
+			"%w: %d transactions exceed the limit of %d",
+			ErrBlockExceedsLimits,
+			len(block.Txs),
+			l.MaxBlockTransactions,
+		)
+	}
+
+	size := block.Size
+	var txsSize int64
+	for _, tx := range block.Txs {
+		txsSize += hexSize(tx.Hex)
+		if err := l.checkScripts(tx); err != nil {
+			return err
+		}
+	}
+
+	if txsSize > size {
+		size = txsSize
+	}
+
+	if l.MaxBlockSize > 0 && size > l.MaxBlockSize {
+		return fmt.Errorf(
+			"%w: size %d exceeds the limit of %d",
+			ErrBlockExceedsLimits,
+			size,
+			l.MaxBlockSize,
+		)
+	}
+
+	return nil
+}
+
+// checkScripts returns an error if a script (or
+// witness item) of tx exceeds MaxScriptSize.
+func (l *Limits) checkScripts(tx *Transaction) error {
+	if l.MaxScriptSize == 0 {
+		return nil
+	}
+
+	scripts := []string{}
+	for _, input := range tx.Inputs {
+		scripts = append(scripts, input.Coinbase)
+		scripts = append(scripts, input.TxInWitness...)
+		if input.ScriptSig != nil {
+			scripts = append(scripts, input.ScriptSig.Hex)
+		}
+	}
+
+	for _, output := range tx.Outputs {
+		if output.ScriptPubKey != nil {
+			scripts = append(scripts, output.ScriptPubKey.Hex)
+		}
+	}
+
+	for _, script := range scripts {
+		if size := hexSize(script); size > l.MaxScriptSize {
+			return fmt.Errorf(
+				"%w: script of %d bytes in transaction %s exceeds the limit of %d",
+				ErrBlockExceedsLimits,
+				size,
+				tx.Hash,
+				l.MaxScriptSize,
+			)
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,110 @@
+// Copyright 2020 Coinbase, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package bitcoin
+
+import (
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCheckBlock(t *testing.T) {
+	script := func(size int) string {
+		return strings.Repeat("ab", size)
+	}
+	block := func(reportedSize int64, txHex string, scriptSig string, scriptPubKey string) *Block {
+		return &Block{
+			Size: reportedSize,
+			Txs: []*Transaction{
+				{
+					Hash: "tx",
+					Hex:  txHex,
+					Inputs: []*Input{
+						{TxHash: "prev", ScriptSig: &ScriptSig{Hex: scriptSig}},
+					},
+					Outputs: []*Output{
+						{ScriptPubKey: &ScriptPubKey{Hex: scriptPubKey}},
+					},
+				},
+			},
+		}
+	}
+	limits := &Limits{MaxBlockSize: 1000, MaxBlockTransactions: 1, MaxScriptSize: 100}
+
+	tests := map[string]struct {
+		limits *Limits
+		block  *Block
+
+		expectedError bool
+	}{
+		"within limits": {
+			limits: limits,
+			block:  block(1000, script(1000), script(100), script(100)),
+		},
+		"too many transactions": {
+			limits: limits,
+			block: &Block{
+				Txs: []*Transaction{{Hash: "tx 1"}, {Hash: "tx 2"}},
+			},
+			expectedError: true,
+		},
+		"reported size too large": {
+			limits:        limits,
+			block:         block(1001, script(100), script(10), script(10)),
+			expectedError: true,
+		},
+		"transactions too large": {
+			limits:        limits,
+			block:         block(100, script(1001), script(10), script(10)),
+			expectedError: true,
+		},
+		"script sig too large": {
+			limits:        limits,
+			block:         block(100, script(100), script(101), script(10)),
+			expectedError: true,
+		},
+		"script pub key too large": {
+			limits:        limits,
+			block:         block(100, script(100), script(10), script(101)),
+			expectedError: true,
+		},
+		"witness too large": {
+			limits: limits,
+			block: &Block{
+				Txs: []*Transaction{
+					{Inputs: []*Input{{TxInWitness: []string{script(10), script(101)}}}},
+				},
+			},
+			expectedError: true,
+		},
+		"limits disabled": {
+			limits: &Limits{},
+			block:  block(5000, script(5000), script(5000), script(5000)),
+		},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			err := test.limits.CheckBlock(test.block)
+			if test.expectedError {
+				assert.True(t, errors.Is(err, ErrBlockExceedsLimits))
+			} else {
+				assert.NoError(t, err)
+			}
+		})
+	}
+}
@@ -0,0 +1,99 @@
+// Copyright 2020 Coinbase, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package bitcoin
+
+import (
+	"github.com/btcsuite/btcd/chaincfg"
+	"github.com/btcsuite/btcd/txscript"
+	"github.com/coinbase/rosetta-sdk-go/types"
+)
+
+const (
+	// MainnetCollateralAmount and TestnetCollateralAmount
+	// are the amounts (in satoshis) locked as the collateral
+	// of a masternode on mainnet and testnet.
+	MainnetCollateralAmount = int64(10000 * SatoshisInBitcoin)
+	TestnetCollateralAmount = int64(10000 * SatoshisInBitcoin)
+
+	// CollateralSubAccount is the sub-account of an address
+	// that holds its masternode collateral (which is locked
+	// while the masternode is running).
+	CollateralSubAccount = "collateral"
+)
+
+// IsMasternodeCollateral returns true if output could be
+// the collateral of a masternode on the network with params
+// (it pays exactly the collateral amount to a single P2PKH
+// address). Networks without a collateral amount have no
+// collateral outputs.
+func IsMasternodeCollateral(output *Output, params *chaincfg.Params) bool {
+	if params == nil || output.ScriptPubKey == nil {
+		return false
+	}
+
+	collateral := chainSettings(params).CollateralAmount
+	if collateral <= 0 {
+		return false
+	}
+
+	if output.ScriptPubKey.Type != txscript.PubKeyHashTy.String() ||
+		len(output.ScriptPubKey.Addresses) != 1 {
+		return false
+	}
+
+	amount, err := Satoshis(output.Value)
+	return err == nil && amount == collateral
+}
+
+// MasternodePayment returns the index of the transaction of
+// block (and of its output) that pays its masternode, and false
+// if block does not pay one. The masternode is paid the
+// masternode subsidy of the block (see BlockSubsidy) in the last
+// output of the reward transaction (see TreasuryPayout), except
+// in superblocks (which pay the treasury in its place). Blocks
+// of networks whose subsidy is unknown pay no masternode.
+func MasternodePayment(block *Block, params *chaincfg.Params) (int, int64, bool) {
+	if params == nil || len(block.Txs) == 0 || IsSuperblock(params, block.Height) {
+		return 0, 0, false
+	}
+
+	subsidy, err := BlockSubsidy(params, int32(block.Height))
+	if err != nil || subsidy.Masternode <= 0 {
+		return 0, 0, false
+	}
+
+	rewardIndex, paying := rewardTransaction(block)
+	if paying < minRewardOutputs {
+		return 0, 0, false
+	}
+
+	reward := block.Txs[rewardIndex]
+	payment := reward.Outputs[len(reward.Outputs)-1]
+	amount, err := Satoshis(payment.Value)
+	if err != nil || amount != subsidy.Masternode {
+		return 0, 0, false
+	}
+
+	return rewardIndex, payment.Index, true
+}
+
+// CollateralAccount returns the account that holds the
+// masternode collateral of owner (its CollateralSubAccount).
+func CollateralAccount(owner string) *types.AccountIdentifier {
+	return &types.AccountIdentifier{
+		Address:    owner,
+		SubAccount: &types.SubAccountIdentifier{Address: CollateralSubAccount},
+	}
+}
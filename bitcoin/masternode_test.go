@@ -0,0 +1,181 @@
+// Copyright 2020 Coinbase, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package bitcoin
+
+import (
+	"testing"
+
+	"github.com/btcsuite/btcd/chaincfg"
+	"github.com/btcsuite/btcd/wire"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestIsMasternodeCollateral(t *testing.T) {
+	pubKeyHash := &ScriptPubKey{
+		Type:      "pubkeyhash",
+		Addresses: []string{"ELJeB54eV9QQt6TvMiYx1b678jeHVeAtKr"},
+	}
+
+	tests := map[string]struct {
+		output   *Output
+		params   *chaincfg.Params
+		expected bool
+	}{
+		"collateral": {
+			output:   &Output{Value: 10000, ScriptPubKey: pubKeyHash},
+			params:   MainnetParams,
+			expected: true,
+		},
+		"other amount": {
+			output: &Output{Value: 10000.00000001, ScriptPubKey: pubKeyHash},
+			params: MainnetParams,
+		},
+		"script hash": {
+			output: &Output{Value: 10000, ScriptPubKey: &ScriptPubKey{
+				Type:      "scripthash",
+				Addresses: []string{"ELJeB54eV9QQt6TvMiYx1b678jeHVeAtKr"},
+			}},
+			params: MainnetParams,
+		},
+		"no params": {
+			output: &Output{Value: 10000, ScriptPubKey: pubKeyHash},
+		},
+		"network without masternodes": {
+			output: &Output{Value: 10000, ScriptPubKey: pubKeyHash},
+			params: &chaincfg.Params{Net: wire.BitcoinNet(0xa1b2c3d6)},
+		},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			assert.Equal(t, test.expected, IsMasternodeCollateral(test.output, test.params))
+		})
+	}
+}
+
+func TestMasternodePayment(t *testing.T) {
+	settings := *TestnetChainParams
+	settings.SubsidySchedule = []*SubsidyPhase{
+		{Height: 0, ProofOfStake: true, Subsidy: 5 * SatoshisInBitcoin, MasternodeSubsidy: 3 * SatoshisInBitcoin},
+	}
+	assert.NoError(t, RegisterChainParams(&settings))
+	defer ResetRegistry()
+
+	output := func(value float64, index int64) *Output {
+		return &Output{
+			Value:        value,
+			Index:        index,
+			ScriptPubKey: &ScriptPubKey{Hex: "76a914", Type: "pubkeyhash"},
+		}
+	}
+	marker := &Output{Index: 0, ScriptPubKey: &ScriptPubKey{Type: "nonstandard"}}
+	coinbase := &Transaction{
+		Inputs:  []*Input{{Coinbase: "03"}},
+		Outputs: []*Output{output(0, 0)},
+	}
+	staked := func(height int64, outputs ...*Output) *Block {
+		return &Block{
+			Height: height,
+			Txs: []*Transaction{
+				coinbase,
+				{
+					Inputs:  []*Input{{TxHash: "a1", Vout: 0}},
+					Outputs: append([]*Output{marker}, outputs...),
+				},
+			},
+		}
+	}
+
+	tests := map[string]struct {
+		block  *Block
+		params *chaincfg.Params
+
+		expectedTx     int
+		expectedOutput int64
+		expectedOk     bool
+	}{
+		"mined block": {
+			block: &Block{
+				Height: 145,
+				Txs: []*Transaction{
+					{
+						Inputs:  []*Input{{Coinbase: "03"}},
+						Outputs: []*Output{output(2, 0), output(3, 1)},
+					},
+				},
+			},
+			params:         TestnetParams,
+			expectedTx:     0,
+			expectedOutput: 1,
+			expectedOk:     true,
+		},
+		"staked block": {
+			block:          staked(145, output(10, 1), output(3, 2)),
+			params:         TestnetParams,
+			expectedTx:     1,
+			expectedOutput: 2,
+			expectedOk:     true,
+		},
+		"superblock": {
+			block:  staked(288, output(10, 1), output(3, 2)),
+			params: TestnetParams,
+		},
+		"other amount": {
+			block:  staked(145, output(10, 1), output(4, 2)),
+			params: TestnetParams,
+		},
+		"single paying output": {
+			block:  staked(145, output(3, 1)),
+			params: TestnetParams,
+		},
+		"network without masternode subsidy": {
+			block:  staked(145, output(10, 1), output(3, 2)),
+			params: MainnetParams,
+		},
+		"no params": {
+			block: staked(145, output(10, 1), output(3, 2)),
+		},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			txIndex, outputIndex, ok := MasternodePayment(test.block, test.params)
+			assert.Equal(t, test.expectedOk, ok)
+			assert.Equal(t, test.expectedTx, txIndex)
+			assert.Equal(t, test.expectedOutput, outputIndex)
+		})
+	}
+}
+
+func TestChainParamsCollateralAmount(t *testing.T) {
+	defer ResetRegistry()
+
+	params := CloneParams(MainnetParams)
+	params.Net = wire.BitcoinNet(0xa1b2c3d7)
+	output := &Output{
+		Value: 1000,
+		ScriptPubKey: &ScriptPubKey{
+			Type:      "pubkeyhash",
+			Addresses: []string{"EeBFHGTbJPUEDcJwHrCPsSYpdSZ3zEdpuG"},
+		},
+	}
+	assert.False(t, IsMasternodeCollateral(output, params))
+
+	assert.NoError(t, RegisterChainParams(&ChainParams{
+		Params:           params,
+		CollateralAmount: 1000 * SatoshisInBitcoin,
+	}))
+	assert.True(t, IsMasternodeCollateral(output, params))
+}
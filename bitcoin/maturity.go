@@ -0,0 +1,41 @@
+// Copyright 2020 Coinbase, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package bitcoin
+
+import (
+	"github.com/btcsuite/btcd/chaincfg"
+	"github.com/coinbase/rosetta-sdk-go/types"
+)
+
+// IsRewardTransaction returns true if tx (as parsed by the
+// Client) is a coinbase or a coinstake, whose outputs can't
+// be spent until they mature (see MatureHeight).
+func IsRewardTransaction(tx *types.Transaction) bool {
+	for _, op := range tx.Operations {
+		switch op.Type {
+		case CoinbaseOpType, StakeInputOpType, StakeRewardOpType:
+			return true
+		}
+	}
+
+	return false
+}
+
+// MatureHeight returns the height of the first block that
+// can spend the outputs of a reward transaction of the
+// block at height (CoinbaseMaturity blocks later).
+func MatureHeight(params *chaincfg.Params, height int64) int64 {
+	return height + int64(params.CoinbaseMaturity)
+}
@@ -0,0 +1,78 @@
+// Copyright 2020 Coinbase, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package bitcoin
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestIsRewardTransaction(t *testing.T) {
+	output := func(value float64, index int64) *Output {
+		return &Output{
+			Value: value,
+			Index: index,
+			ScriptPubKey: &ScriptPubKey{
+				Hex:       "76a91445db0b779c0b9fa207f12a8218c94fc77aff504588ac",
+				Type:      "pubkeyhash",
+				Addresses: []string{"mmtKKnjqTPdkBnBMbNt5Yu2SCwpMaEshEL"},
+			},
+		}
+	}
+	input := &Input{
+		TxHash:    "4852fe372ff7534c16713b3146bbc1e86379c70bea4d5c02fb1fa0112980a081",
+		ScriptSig: &ScriptSig{},
+	}
+	block := &Block{
+		Hash:              "0000000000000000000000000000000000000000000000000000000000000002",
+		Height:            1000,
+		PreviousBlockHash: "0000000000000000000000000000000000000000000000000000000000000001",
+		Txs: []*Transaction{
+			{
+				Hash:    "d1a1a1a1a1a1a1a1a1a1a1a1a1a1a1a1a1a1a1a1a1a1a1a1a1a1a1a1a1a1a1a1",
+				Inputs:  []*Input{{Coinbase: "03"}},
+				Outputs: []*Output{{Index: 0, ScriptPubKey: &ScriptPubKey{Type: "nonstandard"}}},
+			},
+			{
+				Hash:   "d2a2a2a2a2a2a2a2a2a2a2a2a2a2a2a2a2a2a2a2a2a2a2a2a2a2a2a2a2a2a2a2",
+				Inputs: []*Input{input},
+				Outputs: []*Output{
+					{Index: 0, ScriptPubKey: &ScriptPubKey{Type: "nonstandard"}},
+					output(105, 1),
+				},
+			},
+			{
+				Hash:    "d3a3a3a3a3a3a3a3a3a3a3a3a3a3a3a3a3a3a3a3a3a3a3a3a3a3a3a3a3a3a3a3",
+				Inputs:  []*Input{input},
+				Outputs: []*Output{output(1, 0), output(2, 1)},
+			},
+		},
+	}
+
+	client := NewClient("", MainnetGenesisBlockIdentifier, nil, MainnetCurrency)
+	parsed, err := client.ParseBlock(context.Background(), block, nil)
+	assert.NoError(t, err)
+
+	// The coinbase and the coinstake are rewards
+	assert.True(t, IsRewardTransaction(parsed.Transactions[0]))
+	assert.True(t, IsRewardTransaction(parsed.Transactions[1]))
+	assert.False(t, IsRewardTransaction(parsed.Transactions[2]))
+}
+
+func TestMatureHeight(t *testing.T) {
+	assert.Equal(t, int64(1100), MatureHeight(MainnetParams, 1000))
+}
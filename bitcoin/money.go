@@ -0,0 +1,75 @@
+// Copyright 2020 Coinbase, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package bitcoin
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/btcsuite/btcd/chaincfg"
+	"github.com/btcsuite/btcutil"
+)
+
+const (
+	// DefaultMaxMoney is the MaxMoney (in satoshis) of
+	// networks without a registered one (the upstream
+	// limit).
+	DefaultMaxMoney = int64(btcutil.MaxSatoshi)
+
+	// MainnetMaxMoney and TestnetMaxMoney are the MaxMoney
+	// (in satoshis) of mainnet and testnet (the nMaxMoneyOut
+	// of their nodes).
+	MainnetMaxMoney = int64(21000000 * SatoshisInBitcoin)
+	TestnetMaxMoney = int64(21000000 * SatoshisInBitcoin)
+)
+
+var (
+	// ErrMoneyOutOfRange is returned when an amount is
+	// negative or exceeds the MaxMoney of the network.
+	ErrMoneyOutOfRange = errors.New("amount out of money range")
+)
+
+// MaxMoney returns the largest amount (in satoshis) of an
+// output on the network with params (DefaultMaxMoney if none
+// is registered, or if params is nil).
+func MaxMoney(params *chaincfg.Params) int64 {
+	if params == nil {
+		return DefaultMaxMoney
+	}
+
+	if amount := chainSettings(params).MaxMoney; amount > 0 {
+		return amount
+	}
+
+	return DefaultMaxMoney
+}
+
+// MoneyRange returns ErrMoneyOutOfRange if amount (in
+// satoshis) is negative or exceeds the MaxMoney of the
+// network with params (i.e. an overflowed amount returned
+// by a misbehaving node).
+func MoneyRange(params *chaincfg.Params, amount int64) error {
+	maxMoney := MaxMoney(params)
+	if amount < 0 || amount > maxMoney {
+		return fmt.Errorf(
+			"%w: %d is not between 0 and %d",
+			ErrMoneyOutOfRange,
+			amount,
+			maxMoney,
+		)
+	}
+
+	return nil
+}
@@ -0,0 +1,53 @@
+// Copyright 2020 Coinbase, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package bitcoin
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/btcsuite/btcd/wire"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMoneyRange(t *testing.T) {
+	assert.Equal(t, DefaultMaxMoney, MaxMoney(nil))
+	assert.Equal(t, MainnetMaxMoney, MaxMoney(MainnetParams))
+	assert.Equal(t, TestnetMaxMoney, MaxMoney(TestnetParams))
+	assert.NoError(t, MoneyRange(nil, 0))
+	assert.NoError(t, MoneyRange(MainnetParams, MainnetMaxMoney))
+	assert.True(t, errors.Is(MoneyRange(MainnetParams, -1), ErrMoneyOutOfRange))
+	assert.True(t, errors.Is(MoneyRange(MainnetParams, MainnetMaxMoney+1), ErrMoneyOutOfRange))
+
+	params := CloneParams(MainnetParams)
+	params.Net = wire.BitcoinNet(0xa1b2c3f7)
+	assert.NoError(t, RegisterChainParams(&ChainParams{Params: params, MaxMoney: 1000}))
+	defer ResetRegistry()
+
+	assert.Equal(t, int64(1000), MaxMoney(params))
+	assert.NoError(t, MoneyRange(params, 1000))
+	assert.True(t, errors.Is(MoneyRange(params, 1001), ErrMoneyOutOfRange))
+
+	// Overflowed amounts returned by the node are not parsed
+	client := NewClient("", MainnetGenesisBlockIdentifier, nil, MainnetCurrency)
+	client.UseParams(params)
+	_, err := client.parseAmount(0.00001001)
+	assert.True(t, errors.Is(err, ErrMoneyOutOfRange))
+	_, err = client.parseAmount(-0.00000001)
+	assert.True(t, errors.Is(err, ErrMoneyOutOfRange))
+	amount, err := client.parseAmount(0.00001)
+	assert.NoError(t, err)
+	assert.Equal(t, uint64(1000), amount)
+}
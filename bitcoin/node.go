@@ -62,12 +62,18 @@ func logPipe(ctx context.Context, pipe io.ReadCloser, identifier string) error {
 }
 
 // StartBitcoind starts a bitcoind daemon in another goroutine
-// and logs the results to the console.
-func StartBitcoind(ctx context.Context, configPath string, g *errgroup.Group) error {
+// and logs the results to the console. args are provided to
+// bitcoind in addition to the configuration file.
+func StartBitcoind(
+	ctx context.Context,
+	configPath string,
+	g *errgroup.Group,
+	args ...string,
+) error {
 	logger := utils.ExtractLogger(ctx, "bitcoind")
 	cmd := exec.Command(
 		"/app/eunod",
-		fmt.Sprintf("-conf=%s", configPath),
+		append([]string{fmt.Sprintf("-conf=%s", configPath)}, args...)...,
 	) // #nosec G204
 
 	stdout, err := cmd.StdoutPipe()
@@ -0,0 +1,103 @@
+// Copyright 2020 Coinbase, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package bitcoin
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/btcsuite/btcd/chaincfg"
+)
+
+// ErrNetworkMismatch is returned when the node serves
+// a different network than the configured one.
+var ErrNetworkMismatch = errors.New("node serves a different network")
+
+// NodeNetwork identifies the network a node serves. The
+// message start of a network is not reported over RPC,
+// so networks are identified by their genesis hash.
+type NodeNetwork struct {
+	// Chain is the name of the chain reported by the
+	// node (i.e. "main" or "test").
+	Chain       string `json:"chain"`
+	GenesisHash string `json:"genesis_hash"`
+	SubVersion  string `json:"subversion"`
+
+	// ProtocolVersion is the P2P protocol version
+	// of the node (see CheckProtocolVersion).
+	ProtocolVersion int64 `json:"protocol_version"`
+}
+
+// DetectNetwork returns the params of the registered networks
+// whose genesis block is the genesis block of node (sorted by
+// name). Networks that share a genesis block (i.e. a sibling
+// chain registered with another message start) can't be told
+// apart, so several params may be returned.
+func DetectNetwork(node *NodeNetwork) ([]*chaincfg.Params, error) {
+	registryMutex.RLock()
+	defer registryMutex.RUnlock()
+
+	matches := []*chaincfg.Params{}
+	for _, params := range registry {
+		if params.GenesisHash != nil && params.GenesisHash.String() == node.GenesisHash {
+			matches = append(matches, params.Params)
+		}
+	}
+
+	if len(matches) == 0 {
+		return nil, fmt.Errorf(
+			"%w: genesis %s of %s chain",
+			ErrNetworkNotRegistered,
+			node.GenesisHash,
+			node.Chain,
+		)
+	}
+
+	sort.Slice(matches, func(i, j int) bool {
+		return matches[i].Name < matches[j].Name
+	})
+
+	return matches, nil
+}
+
+// CheckNodeNetwork returns ErrNetworkMismatch if node does
+// not serve the network with params (naming the registered
+// networks node serves, if any).
+func CheckNodeNetwork(params *chaincfg.Params, node *NodeNetwork) error {
+	if params.GenesisHash != nil && params.GenesisHash.String() == node.GenesisHash {
+		return nil
+	}
+
+	served := fmt.Sprintf("an unregistered network (%s chain)", node.Chain)
+	if matches, err := DetectNetwork(node); err == nil {
+		names := make([]string, len(matches))
+		for i, match := range matches {
+			names[i] = match.Name
+		}
+
+		served = strings.Join(names, " or ")
+	}
+
+	return fmt.Errorf(
+		"%w: node %s serves %s with genesis %s but %s is configured",
+		ErrNetworkMismatch,
+		node.SubVersion,
+		served,
+		node.GenesisHash,
+		params.Name,
+	)
+}
@@ -0,0 +1,66 @@
+// Copyright 2020 Coinbase, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package bitcoin
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/btcsuite/btcd/chaincfg"
+	"github.com/btcsuite/btcd/wire"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCheckNodeNetwork(t *testing.T) {
+	defer ResetRegistry()
+
+	mainnet := &NodeNetwork{
+		Chain:       "main",
+		GenesisHash: "0000009ea234b1ab29f0172e4d85884a45c0c638192c9c0f781bda67908d56dd",
+		SubVersion:  "/Euno Core:5.3.0/",
+	}
+	matches, err := DetectNetwork(mainnet)
+	assert.NoError(t, err)
+	assert.Equal(t, []*chaincfg.Params{MainnetParams}, matches)
+	assert.NoError(t, CheckNodeNetwork(MainnetParams, mainnet))
+
+	// The node serves mainnet but testnet is configured
+	err = CheckNodeNetwork(TestnetParams, mainnet)
+	assert.True(t, errors.Is(err, ErrNetworkMismatch))
+	assert.Contains(t, err.Error(), "serves mainnet with genesis")
+
+	// Networks sharing a genesis block are all detected
+	sibling := CloneParams(MainnetParams)
+	sibling.Name = "sibling"
+	sibling.Net = wire.BitcoinNet(0xa1b2c3f4)
+	sibling.Bech32HRPSegwit = "sib"
+	assert.NoError(t, Register(sibling))
+	matches, err = DetectNetwork(mainnet)
+	assert.NoError(t, err)
+	assert.Equal(t, []*chaincfg.Params{MainnetParams, sibling}, matches)
+	err = CheckNodeNetwork(TestnetParams, mainnet)
+	assert.Contains(t, err.Error(), "serves mainnet or sibling")
+
+	// Unregistered networks can't be detected
+	unknown := &NodeNetwork{
+		Chain:       "regtest",
+		GenesisHash: "0f9188f13cb7b2c71f2a335e3a4fc328bf5beb436012afca590b1a11466e2206",
+	}
+	_, err = DetectNetwork(unknown)
+	assert.True(t, errors.Is(err, ErrNetworkNotRegistered))
+	err = CheckNodeNetwork(MainnetParams, unknown)
+	assert.True(t, errors.Is(err, ErrNetworkMismatch))
+	assert.Contains(t, err.Error(), "an unregistered network (regtest chain)")
+}
@@ -0,0 +1,89 @@
+// Copyright 2020 Coinbase, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package bitcoin
+
+import (
+	"sort"
+	"sync"
+
+	"github.com/coinbase/rosetta-sdk-go/types"
+)
+
+var (
+	// operationTypes and operationStatuses store the
+	// operation types and statuses registered by parsers.
+	// They are guarded by operationMutex.
+	operationTypes    = map[string]struct{}{}
+	operationStatuses = map[string]bool{}
+	operationMutex    sync.RWMutex
+)
+
+// RegisterOperationType registers opType as a type of the
+// operations returned by the parser, so that it is allowed
+// by /network/options (see OperationTypes). Registering a
+// type more than once has no effect. It is safe to call
+// concurrently.
+func RegisterOperationType(opType string) {
+	operationMutex.Lock()
+	defer operationMutex.Unlock()
+
+	operationTypes[opType] = struct{}{}
+}
+
+// RegisterOperationStatus registers status (replacing any
+// registration of it) as a status of the operations returned
+// by the parser, and whether operations with it are successful
+// (see OperationStatuses).
+func RegisterOperationStatus(status string, successful bool) {
+	operationMutex.Lock()
+	defer operationMutex.Unlock()
+
+	operationStatuses[status] = successful
+}
+
+// OperationTypes returns all registered
+// operation types (sorted).
+func OperationTypes() []string {
+	operationMutex.RLock()
+	defer operationMutex.RUnlock()
+
+	opTypes := make([]string, 0, len(operationTypes))
+	for opType := range operationTypes {
+		opTypes = append(opTypes, opType)
+	}
+
+	sort.Strings(opTypes)
+	return opTypes
+}
+
+// OperationStatuses returns all registered
+// operation statuses (sorted by status).
+func OperationStatuses() []*types.OperationStatus {
+	operationMutex.RLock()
+	defer operationMutex.RUnlock()
+
+	statuses := make([]*types.OperationStatus, 0, len(operationStatuses))
+	for status, successful := range operationStatuses {
+		statuses = append(statuses, &types.OperationStatus{
+			Status:     status,
+			Successful: successful,
+		})
+	}
+
+	sort.Slice(statuses, func(i, j int) bool {
+		return statuses[i].Status < statuses[j].Status
+	})
+	return statuses
+}
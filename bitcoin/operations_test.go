@@ -0,0 +1,82 @@
+// Copyright 2020 Coinbase, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package bitcoin
+
+import (
+	"testing"
+
+	"github.com/coinbase/rosetta-sdk-go/types"
+	"github.com/stretchr/testify/assert"
+)
+
+// assertRegisteredOperations asserts that the type and
+// status of each operation of block are registered.
+func assertRegisteredOperations(t *testing.T, block *types.Block) {
+	statuses := map[string]struct{}{}
+	for _, status := range OperationStatuses() {
+		statuses[status.Status] = struct{}{}
+	}
+
+	opTypes := OperationTypes()
+	for _, tx := range block.Transactions {
+		for _, op := range tx.Operations {
+			assert.Contains(t, opTypes, op.Type)
+			assert.Contains(t, statuses, *op.Status)
+		}
+	}
+}
+
+func TestOperationTypes(t *testing.T) {
+	assert.Equal(t, []string{
+		BurnOpType,
+		CoinbaseOpType,
+		DelegationOpType,
+		GenesisAllocationOpType,
+		InputOpType,
+		MasternodeRewardOpType,
+		OutputOpType,
+		StakeInputOpType,
+		StakeRewardOpType,
+		TreasuryPayoutOpType,
+		UnparseableOpType,
+		ZerocoinMintOpType,
+		ZerocoinSpendOpType,
+	}, OperationTypes())
+
+	// Registering a registered type has no effect
+	RegisterOperationType(InputOpType)
+	assert.Len(t, OperationTypes(), 13)
+
+	RegisterOperationType("TEST")
+	defer func() {
+		operationMutex.Lock()
+		delete(operationTypes, "TEST")
+		operationMutex.Unlock()
+	}()
+	assert.Contains(t, OperationTypes(), "TEST")
+}
+
+func TestOperationStatuses(t *testing.T) {
+	assert.Equal(t, []*types.OperationStatus{
+		{
+			Status:     SkippedStatus,
+			Successful: false,
+		},
+		{
+			Status:     SuccessStatus,
+			Successful: true,
+		},
+	}, OperationStatuses())
+}
@@ -0,0 +1,737 @@
+// Copyright 2020 Coinbase, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package bitcoin
+
+import (
+	"crypto/sha256"
+	"encoding/binary"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"math/big"
+	"time"
+
+	"github.com/btcsuite/btcd/blockchain"
+	"github.com/btcsuite/btcd/chaincfg"
+	"github.com/btcsuite/btcd/chaincfg/chainhash"
+	"github.com/btcsuite/btcd/wire"
+)
+
+const (
+	// mainnetParamsBase and testnetParamsBase are the
+	// params a params file can be based on.
+	mainnetParamsBase = "mainnet"
+	testnetParamsBase = "testnet"
+
+	// messageStartLength is the length of the
+	// message start (magic) of a network.
+	messageStartLength = 4
+
+	// hdKeyIDLength is the length of the
+	// version bytes of extended keys.
+	hdKeyIDLength = 4
+)
+
+// ErrInvalidParams is returned when a
+// params file is not valid.
+var ErrInvalidParams = errors.New("invalid params")
+
+// ParamsFile are the settings of a network
+// loaded by LoadParamsFromFile. Settings that
+// are omitted are copied from Base.
+type ParamsFile struct {
+	Name string `json:"name"`
+
+	// Base is the network the params are copied from
+	// (mainnet or testnet, defaults to mainnet).
+	Base string `json:"base"`
+
+	// GenesisHash is the hash of the
+	// genesis block of the network.
+	GenesisHash string `json:"genesis_hash"`
+
+	// Genesis describes the genesis block of the network
+	// (i.e. of a private devnet), in which case GenesisHash
+	// may be omitted (or must be the hash of the block).
+	Genesis *GenesisBuilder `json:"genesis,omitempty"`
+
+	// MessageStart is the hex-encoded magic that
+	// starts each message on the network (as in
+	// the node's pchMessageStart).
+	MessageStart string `json:"message_start"`
+
+	DefaultPort      string  `json:"default_port,omitempty"`
+	CoinbaseMaturity *uint16 `json:"coinbase_maturity,omitempty"`
+
+	// PowLimit is the highest proof of work target
+	// (lowest difficulty) of the network.
+	PowLimit *BigInt `json:"pow_limit,omitempty"`
+
+	// TargetTimespan, TargetTimePerBlock, RetargetAdjustmentFactor,
+	// ReduceMinDifficulty, and MinDiffReductionTime are the
+	// difficulty retarget settings of the network.
+	TargetTimespan           *Duration `json:"target_timespan,omitempty"`
+	TargetTimePerBlock       *Duration `json:"target_time_per_block,omitempty"`
+	RetargetAdjustmentFactor *int64    `json:"retarget_adjustment_factor,omitempty"`
+	ReduceMinDifficulty      *bool     `json:"reduce_min_difficulty,omitempty"`
+	MinDiffReductionTime     *Duration `json:"min_diff_reduction_time,omitempty"`
+
+	// SubsidyReductionInterval is the number of blocks
+	// the subsidy is halved after (if the network has no
+	// SubsidySchedule).
+	SubsidyReductionInterval *int32 `json:"subsidy_reduction_interval,omitempty"`
+
+	// BIP0034Height, BIP0065Height, and BIP0066Height
+	// are the heights the upgrades activated at.
+	BIP0034Height *int32 `json:"bip0034_height,omitempty"`
+	BIP0065Height *int32 `json:"bip0065_height,omitempty"`
+	BIP0066Height *int32 `json:"bip0066_height,omitempty"`
+
+	PubKeyHashAddrID *byte  `json:"pubkeyhash_addr_id,omitempty"`
+	ScriptHashAddrID *byte  `json:"scripthash_addr_id,omitempty"`
+	PrivateKeyID     *byte  `json:"private_key_id,omitempty"`
+	Bech32HRPSegwit  string `json:"bech32_hrp_segwit,omitempty"`
+
+	// StakingAddrID is the version byte of staking
+	// addresses (see StakingAddress). Networks without
+	// it have no staking addresses.
+	StakingAddrID *byte `json:"staking_addr_id,omitempty"`
+
+	// CollateralAmount is the amount (in satoshis) locked as
+	// the collateral of a masternode (see IsMasternodeCollateral).
+	// If omitted, the collateral amount of Base is used.
+	CollateralAmount *int64 `json:"collateral_amount,omitempty"`
+
+	// MaxMoney is the largest amount (in satoshis) of an
+	// output of the chain (see MoneyRange). If omitted, the
+	// MaxMoney of Base is used.
+	MaxMoney *int64 `json:"max_money,omitempty"`
+
+	// MinRelayTxFee is the minimum relay fee (in satoshis per
+	// kB) and DustLimit the smallest non-dust output value (in
+	// satoshis) of nodes of the network (see RelayPolicy). If
+	// omitted, those of Base are used.
+	MinRelayTxFee *int64 `json:"min_relay_tx_fee,omitempty"`
+	DustLimit     *int64 `json:"dust_limit,omitempty"`
+
+	// MinProtocolVersion is the lowest protocol version of a
+	// node the indexer uses, and ProtocolVersion the latest
+	// one known to be served correctly (see ProtocolVersions).
+	// They are not copied from Base.
+	MinProtocolVersion *int64 `json:"min_protocol_version,omitempty"`
+	ProtocolVersion    *int64 `json:"protocol_version,omitempty"`
+
+	// BudgetCycleBlocks is the number of blocks of a budget
+	// cycle (see IsSuperblock). If omitted, the budget cycle
+	// of Base is used.
+	BudgetCycleBlocks *int64 `json:"budget_cycle_blocks,omitempty"`
+
+	// RelayNonStdTxs is true if nodes of the network relay
+	// non-standard outputs (see RelayPolicy).
+	RelayNonStdTxs *bool `json:"relay_non_std_txs,omitempty"`
+
+	// HDPrivateKeyID and HDPublicKeyID are the
+	// hex-encoded version bytes of extended keys.
+	HDPrivateKeyID string  `json:"hd_private_key_id,omitempty"`
+	HDPublicKeyID  string  `json:"hd_public_key_id,omitempty"`
+	HDCoinType     *uint32 `json:"hd_coin_type,omitempty"`
+
+	// MinimumChainWork is the hex-encoded least total work
+	// of the chain of the node, and AssumeValid the hash of
+	// a block it must include (see ChainRequirements).
+	MinimumChainWork string `json:"minimum_chain_work,omitempty"`
+	AssumeValid      string `json:"assume_valid,omitempty"`
+
+	// ActivationHashes are the hashes of the activation blocks
+	// (the blocks at BIP0034Height, BIP0065Height, and
+	// BIP0066Height), keyed by activation name (i.e. "bip34").
+	// The indexer refuses blocks that don't match them.
+	ActivationHashes map[string]string `json:"activation_hashes,omitempty"`
+
+	// Checkpoints are blocks the chain must include
+	// (see VerifyAgainstCheckpoints).
+	Checkpoints []*ParamsCheckpoint `json:"checkpoints,omitempty"`
+
+	// DNSSeeds are the seeds that return the addresses
+	// of nodes of the network (see Seeder).
+	DNSSeeds []*ParamsDNSSeed `json:"dns_seeds,omitempty"`
+
+	// SubsidySchedule is the emission schedule of the
+	// network (see BlockSubsidy). If omitted, the subsidy
+	// is halved every SubsidyReductionInterval blocks (and
+	// unknown without one).
+	SubsidySchedule []*SubsidyPhase `json:"subsidy_schedule,omitempty"`
+
+	// SporkKey and SporkKeyOld are the hex-encoded public keys
+	// spork messages are signed with, and EnforceNewSporkKey
+	// and RejectOldSporkKey the unix times the old spork key
+	// is phased out at (see SporkKeys).
+	SporkKey           string `json:"spork_key,omitempty"`
+	SporkKeyOld        string `json:"spork_key_old,omitempty"`
+	EnforceNewSporkKey *int64 `json:"enforce_new_spork_key,omitempty"`
+	RejectOldSporkKey  *int64 `json:"reject_old_spork_key,omitempty"`
+}
+
+// ParamsCheckpoint is a checkpoint
+// of a params file.
+type ParamsCheckpoint struct {
+	Height int32  `json:"height"`
+	Hash   string `json:"hash"`
+}
+
+// ParamsDNSSeed is a DNS seed
+// of a params file.
+type ParamsDNSSeed struct {
+	Host string `json:"host"`
+
+	// HasFiltering is true if the seed only returns
+	// nodes with the services requested in a subdomain.
+	HasFiltering bool `json:"has_filtering"`
+}
+
+// LoadParamsFromFile loads the params of a network from
+// a JSON file (see ParamsFile) at path. The params (and the
+// settings of their chain, see CreateChainParams) are
+// registered (see RegisterChainParams, replacing any params
+// with the same message start) so that addresses can be
+// decoded. The built-in networks can't be replaced, so files
+// with the message start of mainnet or testnet are rejected.
+func LoadParamsFromFile(path string) (*chaincfg.Params, error) {
+	content, err := ioutil.ReadFile(path) // #nosec G304
+	if err != nil {
+		return nil, fmt.Errorf("%w: unable to read %s", err, path)
+	}
+
+	var file ParamsFile
+	if err := json.Unmarshal(content, &file); err != nil {
+		return nil, fmt.Errorf("%w: unable to parse %s", err, path)
+	}
+
+	params, err := CreateChainParams(&file)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %s", err, path)
+	}
+
+	for _, builtIn := range []*ChainParams{MainnetChainParams, TestnetChainParams} {
+		if params.Net == builtIn.Net {
+			return nil, fmt.Errorf(
+				"%w: message start %s of %s is the message start of %s",
+				ErrInvalidParams,
+				file.MessageStart,
+				path,
+				builtIn.Name,
+			)
+		}
+	}
+
+	if err := RegisterChainParams(params); err != nil {
+		return nil, fmt.Errorf("%w: %s", err, path)
+	}
+
+	return params.Params, nil
+}
+
+// CreateChainParams returns the params described by file
+// and the settings of their chain. The collateral amount,
+// MaxMoney, relay fees, and budget cycle of the base are
+// inherited unless they are provided (the other settings
+// are only those of file).
+func CreateChainParams(file *ParamsFile) (*ChainParams, error) {
+	params, err := CreateParams(file)
+	if err != nil {
+		return nil, err
+	}
+
+	base := MainnetChainParams
+	if file.Base == testnetParamsBase {
+		base = TestnetChainParams
+	}
+
+	chainParams := &ChainParams{
+		Params:            params,
+		CollateralAmount:  base.CollateralAmount,
+		MaxMoney:          base.MaxMoney,
+		BudgetCycleBlocks: base.BudgetCycleBlocks,
+		SubsidySchedule:   file.SubsidySchedule,
+	}
+
+	if file.StakingAddrID != nil {
+		id := *file.StakingAddrID
+		chainParams.StakingAddrID = &id
+	}
+	if file.CollateralAmount != nil {
+		chainParams.CollateralAmount = *file.CollateralAmount
+	}
+	if file.MaxMoney != nil {
+		chainParams.MaxMoney = *file.MaxMoney
+	}
+	if file.BudgetCycleBlocks != nil {
+		chainParams.BudgetCycleBlocks = *file.BudgetCycleBlocks
+	}
+
+	if file.MinRelayTxFee != nil || file.DustLimit != nil || base.RelayFees != nil {
+		fees := &RelayFees{MinRelayTxFee: MinRelayFee}
+		if base.RelayFees != nil {
+			*fees = *base.RelayFees
+		}
+		if file.MinRelayTxFee != nil {
+			fees.MinRelayTxFee = *file.MinRelayTxFee
+		}
+		if file.DustLimit != nil {
+			fees.DustLimit = *file.DustLimit
+		}
+
+		chainParams.RelayFees = fees
+	}
+
+	if file.MinProtocolVersion != nil || file.ProtocolVersion != nil {
+		versions := &ProtocolVersions{}
+		if file.MinProtocolVersion != nil {
+			versions.MinProtocolVersion = *file.MinProtocolVersion
+		}
+		if file.ProtocolVersion != nil {
+			versions.ProtocolVersion = *file.ProtocolVersion
+		}
+
+		chainParams.ProtocolVersions = versions
+	}
+
+	chainParams.ChainRequirements, err = ParseChainRequirements(
+		file.MinimumChainWork,
+		file.AssumeValid,
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	hashes, err := parseActivationHashes(file)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(hashes) > 0 {
+		chainParams.ActivationHashes = hashes
+	}
+
+	if len(file.SporkKey) > 0 || len(file.SporkKeyOld) > 0 {
+		keys := &SporkKeys{
+			PubKey:    file.SporkKey,
+			PubKeyOld: file.SporkKeyOld,
+		}
+		if file.EnforceNewSporkKey != nil {
+			keys.EnforceNewSporkKey = time.Unix(*file.EnforceNewSporkKey, 0)
+		}
+		if file.RejectOldSporkKey != nil {
+			keys.RejectOldSporkKey = time.Unix(*file.RejectOldSporkKey, 0)
+		}
+
+		if err := ValidateSporkKeys(keys); err != nil {
+			return nil, err
+		}
+
+		chainParams.SporkKeys = keys
+	}
+
+	return chainParams, nil
+}
+
+// CreateParams returns the params described by file.
+func CreateParams(file *ParamsFile) (*chaincfg.Params, error) {
+	if len(file.Name) == 0 {
+		return nil, fmt.Errorf("%w: no name provided", ErrInvalidParams)
+	}
+
+	var params chaincfg.Params
+	switch file.Base {
+	case mainnetParamsBase, "":
+		params = *CloneParams(CreateMainNetParams())
+	case testnetParamsBase:
+		params = *CreateTestNetParams(nil)
+	default:
+		return nil, fmt.Errorf("%w: %s is not a valid base", ErrInvalidParams, file.Base)
+	}
+
+	params.Name = file.Name
+	params.DNSSeeds = []chaincfg.DNSSeed{}
+	params.Checkpoints = []chaincfg.Checkpoint{}
+
+	// Unless it is described, the genesis block is not
+	// known (only its hash), so it is not copied from
+	// the base.
+	params.GenesisBlock = nil
+	if file.Genesis != nil {
+		genesisHash, err := file.Genesis.Apply(&params)
+		if err != nil {
+			return nil, fmt.Errorf("%w: %s", ErrInvalidParams, err.Error())
+		}
+
+		if len(file.GenesisHash) > 0 && file.GenesisHash != genesisHash.String() {
+			return nil, fmt.Errorf(
+				"%w: genesis hash %s is not the hash %s of the genesis block",
+				ErrInvalidParams,
+				file.GenesisHash,
+				genesisHash.String(),
+			)
+		}
+	} else {
+		genesisHash, err := chainhash.NewHashFromStr(file.GenesisHash)
+		if err != nil || len(file.GenesisHash) != chainhash.MaxHashStringSize {
+			return nil, fmt.Errorf(
+				"%w: %s is not a valid genesis hash",
+				ErrInvalidParams,
+				file.GenesisHash,
+			)
+		}
+		params.GenesisHash = genesisHash
+	}
+
+	for _, checkpoint := range file.Checkpoints {
+		parsed, err := parseCheckpoint(checkpoint)
+		if err != nil {
+			return nil, fmt.Errorf("%w: %s", ErrInvalidParams, err.Error())
+		}
+
+		params.Checkpoints = append(params.Checkpoints, parsed)
+	}
+
+	for _, seed := range file.DNSSeeds {
+		if len(seed.Host) == 0 {
+			return nil, fmt.Errorf("%w: DNS seed has no host", ErrInvalidParams)
+		}
+
+		params.DNSSeeds = append(params.DNSSeeds, chaincfg.DNSSeed{
+			Host:         seed.Host,
+			HasFiltering: seed.HasFiltering,
+		})
+	}
+
+	messageStart, err := hex.DecodeString(file.MessageStart)
+	if err != nil || len(messageStart) != messageStartLength {
+		return nil, fmt.Errorf(
+			"%w: %s is not a valid message start",
+			ErrInvalidParams,
+			file.MessageStart,
+		)
+	}
+	params.Net = wire.BitcoinNet(binary.LittleEndian.Uint32(messageStart))
+
+	if len(file.DefaultPort) > 0 {
+		params.DefaultPort = file.DefaultPort
+	}
+	if file.CoinbaseMaturity != nil {
+		params.CoinbaseMaturity = *file.CoinbaseMaturity
+	}
+	if file.PowLimit != nil {
+		if file.PowLimit.Sign() <= 0 {
+			return nil, fmt.Errorf("%w: pow limit must be positive", ErrInvalidParams)
+		}
+
+		params.PowLimit = file.PowLimit.Int()
+		params.PowLimitBits = blockchain.BigToCompact(params.PowLimit)
+	}
+	if file.TargetTimespan != nil {
+		params.TargetTimespan = time.Duration(*file.TargetTimespan)
+	}
+	if file.TargetTimePerBlock != nil {
+		params.TargetTimePerBlock = time.Duration(*file.TargetTimePerBlock)
+	}
+	if file.RetargetAdjustmentFactor != nil {
+		params.RetargetAdjustmentFactor = *file.RetargetAdjustmentFactor
+	}
+	if file.ReduceMinDifficulty != nil {
+		params.ReduceMinDifficulty = *file.ReduceMinDifficulty
+	}
+	if file.MinDiffReductionTime != nil {
+		params.MinDiffReductionTime = time.Duration(*file.MinDiffReductionTime)
+	}
+	if file.SubsidyReductionInterval != nil {
+		params.SubsidyReductionInterval = *file.SubsidyReductionInterval
+	}
+	if params.TargetTimePerBlock <= 0 || params.TargetTimespan < params.TargetTimePerBlock {
+		return nil, fmt.Errorf(
+			"%w: target time per block %s does not fit in target timespan %s",
+			ErrInvalidParams,
+			params.TargetTimePerBlock,
+			params.TargetTimespan,
+		)
+	}
+	if file.BIP0034Height != nil {
+		params.BIP0034Height = *file.BIP0034Height
+	}
+	if file.BIP0065Height != nil {
+		params.BIP0065Height = *file.BIP0065Height
+	}
+	if file.BIP0066Height != nil {
+		params.BIP0066Height = *file.BIP0066Height
+	}
+	if file.PubKeyHashAddrID != nil {
+		params.PubKeyHashAddrID = *file.PubKeyHashAddrID
+	}
+	if file.ScriptHashAddrID != nil {
+		params.ScriptHashAddrID = *file.ScriptHashAddrID
+	}
+	if file.PrivateKeyID != nil {
+		params.PrivateKeyID = *file.PrivateKeyID
+	}
+	if len(file.Bech32HRPSegwit) > 0 {
+		params.Bech32HRPSegwit = file.Bech32HRPSegwit
+	}
+	if file.RelayNonStdTxs != nil {
+		params.RelayNonStdTxs = *file.RelayNonStdTxs
+	}
+	if file.HDCoinType != nil {
+		params.HDCoinType = *file.HDCoinType
+	}
+
+	if params.PubKeyHashAddrID == params.ScriptHashAddrID {
+		return nil, fmt.Errorf(
+			"%w: pubkeyhash and scripthash address IDs are both %d",
+			ErrInvalidParams,
+			params.PubKeyHashAddrID,
+		)
+	}
+
+	if err := decodeHDKeyID(file.HDPrivateKeyID, &params.HDPrivateKeyID); err != nil {
+		return nil, err
+	}
+	if err := decodeHDKeyID(file.HDPublicKeyID, &params.HDPublicKeyID); err != nil {
+		return nil, err
+	}
+
+	if file.CollateralAmount != nil && *file.CollateralAmount <= 0 {
+		return nil, fmt.Errorf("%w: collateral amount must be positive", ErrInvalidParams)
+	}
+
+	if file.MaxMoney != nil && *file.MaxMoney <= 0 {
+		return nil, fmt.Errorf("%w: max money must be positive", ErrInvalidParams)
+	}
+
+	if file.MinRelayTxFee != nil && *file.MinRelayTxFee < 0 {
+		return nil, fmt.Errorf("%w: min relay tx fee must not be negative", ErrInvalidParams)
+	}
+
+	if file.DustLimit != nil && *file.DustLimit < 0 {
+		return nil, fmt.Errorf("%w: dust limit must not be negative", ErrInvalidParams)
+	}
+
+	if file.MinProtocolVersion != nil && *file.MinProtocolVersion <= 0 {
+		return nil, fmt.Errorf("%w: min protocol version must be positive", ErrInvalidParams)
+	}
+
+	if file.ProtocolVersion != nil && *file.ProtocolVersion <= 0 {
+		return nil, fmt.Errorf("%w: protocol version must be positive", ErrInvalidParams)
+	}
+
+	if file.MinProtocolVersion != nil && file.ProtocolVersion != nil &&
+		*file.MinProtocolVersion > *file.ProtocolVersion {
+		return nil, fmt.Errorf(
+			"%w: min protocol version %d exceeds protocol version %d",
+			ErrInvalidParams,
+			*file.MinProtocolVersion,
+			*file.ProtocolVersion,
+		)
+	}
+
+	if file.BudgetCycleBlocks != nil && *file.BudgetCycleBlocks <= 0 {
+		return nil, fmt.Errorf("%w: budget cycle blocks must be positive", ErrInvalidParams)
+	}
+
+	if len(file.SubsidySchedule) > 0 {
+		if err := ValidateSubsidySchedule(file.SubsidySchedule); err != nil {
+			return nil, err
+		}
+	}
+
+	if _, err := ParseChainRequirements(file.MinimumChainWork, file.AssumeValid); err != nil {
+		return nil, err
+	}
+
+	if _, err := parseActivationHashes(file); err != nil {
+		return nil, err
+	}
+
+	return &params, nil
+}
+
+// parseActivationHashes returns the activation
+// hashes described by file (keyed by name).
+func parseActivationHashes(file *ParamsFile) (map[string]*chainhash.Hash, error) {
+	hashes := make(map[string]*chainhash.Hash, len(file.ActivationHashes))
+	for name, value := range file.ActivationHashes {
+		if !isFixedActivation(name) {
+			return nil, fmt.Errorf(
+				"%w: %s does not activate at a fixed height",
+				ErrInvalidParams,
+				name,
+			)
+		}
+
+		hash, err := chainhash.NewHashFromStr(value)
+		if err != nil || len(value) != chainhash.MaxHashStringSize {
+			return nil, fmt.Errorf(
+				"%w: %s is not a valid activation hash of %s",
+				ErrInvalidParams,
+				value,
+				name,
+			)
+		}
+
+		hashes[name] = hash
+	}
+
+	return hashes, nil
+}
+
+// ParseChainRequirements returns the *ChainRequirements with
+// minimumChainWork (hex-encoded, as the chainwork returned by
+// the node) and assumeValid (a block hash), either of which
+// may be empty (nil if both are).
+func ParseChainRequirements(
+	minimumChainWork string,
+	assumeValid string,
+) (*ChainRequirements, error) {
+	if len(minimumChainWork) == 0 && len(assumeValid) == 0 {
+		return nil, nil
+	}
+
+	requirements := &ChainRequirements{}
+	if len(minimumChainWork) > 0 {
+		work, err := ParseChainWork(minimumChainWork)
+		if err != nil {
+			return nil, fmt.Errorf("%w: %s", ErrInvalidParams, err.Error())
+		}
+		requirements.MinimumChainWork = work
+	}
+
+	if len(assumeValid) > 0 {
+		hash, err := chainhash.NewHashFromStr(assumeValid)
+		if err != nil || len(assumeValid) != chainhash.MaxHashStringSize {
+			return nil, fmt.Errorf(
+				"%w: %s is not a valid assume valid hash",
+				ErrInvalidParams,
+				assumeValid,
+			)
+		}
+		requirements.AssumeValidHash = hash
+	}
+
+	return requirements, nil
+}
+
+// decodeHDKeyID decodes the hex-encoded version bytes
+// of extended keys into id (if value is populated).
+func decodeHDKeyID(value string, id *[hdKeyIDLength]byte) error {
+	if len(value) == 0 {
+		return nil
+	}
+
+	decoded, err := hex.DecodeString(value)
+	if err != nil || len(decoded) != hdKeyIDLength {
+		return fmt.Errorf("%w: %s is not a valid HD key ID", ErrInvalidParams, value)
+	}
+
+	copy(id[:], decoded)
+	return nil
+}
+
+// CloneParams returns a deep copy of params, so that
+// a network derived from params (i.e. a devnet based on
+// MainnetParams) can be modified and registered without
+// modifying params.
+func CloneParams(params *chaincfg.Params) *chaincfg.Params {
+	clone := *params
+
+	clone.DNSSeeds = append([]chaincfg.DNSSeed{}, params.DNSSeeds...)
+
+	if params.GenesisBlock != nil {
+		genesisBlock := wire.MsgBlock{Header: params.GenesisBlock.Header}
+		for _, tx := range params.GenesisBlock.Transactions {
+			genesisBlock.Transactions = append(genesisBlock.Transactions, tx.Copy())
+		}
+		clone.GenesisBlock = &genesisBlock
+	}
+
+	if params.GenesisHash != nil {
+		genesisHash := *params.GenesisHash
+		clone.GenesisHash = &genesisHash
+	}
+
+	if params.PowLimit != nil {
+		clone.PowLimit = new(big.Int).Set(params.PowLimit)
+	}
+
+	clone.Checkpoints = make([]chaincfg.Checkpoint, len(params.Checkpoints))
+	for i, checkpoint := range params.Checkpoints {
+		hash := *checkpoint.Hash
+		clone.Checkpoints[i] = chaincfg.Checkpoint{
+			Height: checkpoint.Height,
+			Hash:   &hash,
+		}
+	}
+
+	return &clone
+}
+
+// ParamsAddressEras returns the address eras of
+// a network loaded with LoadParamsFromFile (which
+// has only used the prefixes of params).
+func ParamsAddressEras(params *chaincfg.Params) []*AddressEra {
+	return []*AddressEra{
+		{
+			Name:             CurrentAddressEra,
+			PubKeyHashAddrID: params.PubKeyHashAddrID,
+			ScriptHashAddrID: params.ScriptHashAddrID,
+		},
+	}
+}
+
+// genesisHashString returns the genesis hash of params
+// as a string, which is empty if it is not known (like
+// that of testnet before its genesis is configured).
+func genesisHashString(params *chaincfg.Params) string {
+	if params.GenesisHash == nil {
+		return ""
+	}
+
+	return params.GenesisHash.String()
+}
+
+// ParamsFingerprint returns a fingerprint (hex encoded
+// SHA-256 hash) of the settings of params that data
+// indexed on the network depends on: the genesis hash,
+// the message start, the address and key prefixes, and
+// the activation heights of upgrades.
+func ParamsFingerprint(params *chaincfg.Params) string {
+	hash := sha256.New()
+	fmt.Fprintf(
+		hash,
+		"%s|%08x|%02x|%02x|%02x|%s|%x|%x|%d|%d|%d",
+		genesisHashString(params),
+		uint32(params.Net),
+		params.PubKeyHashAddrID,
+		params.ScriptHashAddrID,
+		params.PrivateKeyID,
+		params.Bech32HRPSegwit,
+		params.HDPrivateKeyID,
+		params.HDPublicKeyID,
+		params.BIP0034Height,
+		params.BIP0065Height,
+		params.BIP0066Height,
+	)
+
+	return hex.EncodeToString(hash.Sum(nil))
+}
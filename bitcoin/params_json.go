@@ -0,0 +1,261 @@
+// Copyright 2020 Coinbase, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package bitcoin
+
+import (
+	"encoding/binary"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"math/big"
+	"time"
+
+	"github.com/btcsuite/btcd/chaincfg"
+)
+
+// powLimitHexLength is the length of a
+// hex-encoded (256-bit) proof of work target.
+const powLimitHexLength = 64
+
+// Duration is a time.Duration that is encoded
+// in JSON as a string (i.e. "10m0s").
+type Duration time.Duration
+
+// MarshalJSON implements json.Marshaler.
+func (d Duration) MarshalJSON() ([]byte, error) {
+	return json.Marshal(time.Duration(d).String())
+}
+
+// UnmarshalJSON implements json.Unmarshaler.
+func (d *Duration) UnmarshalJSON(data []byte) error {
+	var value string
+	if err := json.Unmarshal(data, &value); err != nil {
+		return fmt.Errorf("%w: duration must be a string", err)
+	}
+
+	duration, err := time.ParseDuration(value)
+	if err != nil {
+		return fmt.Errorf("%w: %s is not a valid duration", err, value)
+	}
+
+	*d = Duration(duration)
+	return nil
+}
+
+// BigInt is a *big.Int that is encoded in JSON as a
+// zero-padded hex string (as the node prints targets).
+type BigInt big.Int
+
+// NewBigInt returns the *BigInt of value.
+func NewBigInt(value *big.Int) *BigInt {
+	return (*BigInt)(new(big.Int).Set(value))
+}
+
+// Int returns a copy of b as a *big.Int.
+func (b *BigInt) Int() *big.Int {
+	return new(big.Int).Set((*big.Int)(b))
+}
+
+// Sign returns the sign of b.
+func (b *BigInt) Sign() int {
+	return (*big.Int)(b).Sign()
+}
+
+// MarshalJSON implements json.Marshaler.
+func (b *BigInt) MarshalJSON() ([]byte, error) {
+	return json.Marshal(fmt.Sprintf("%0*x", powLimitHexLength, (*big.Int)(b)))
+}
+
+// UnmarshalJSON implements json.Unmarshaler.
+func (b *BigInt) UnmarshalJSON(data []byte) error {
+	var value string
+	if err := json.Unmarshal(data, &value); err != nil {
+		return fmt.Errorf("%w: big integer must be a hex string", err)
+	}
+
+	if _, ok := (*big.Int)(b).SetString(value, 16); !ok { // nolint:gomnd
+		return fmt.Errorf("%w: %s is not a valid hex integer", ErrInvalidParams, value)
+	}
+
+	return nil
+}
+
+// NewParamsFile returns the *ParamsFile that CreateParams
+// creates params from (the inverse of CreateParams), so the
+// active network definition can be dumped and loaded again.
+// Settings that are not part of a params file (like the
+// genesis block and deployments) are taken from the base
+// of the file (testnet for testnet params, mainnet otherwise).
+func NewParamsFile(params *chaincfg.Params) *ParamsFile {
+	base := mainnetParamsBase
+	if params.Net == TestnetParams.Net {
+		base = testnetParamsBase
+	}
+
+	messageStart := make([]byte, messageStartLength)
+	binary.LittleEndian.PutUint32(messageStart, uint32(params.Net))
+
+	coinbaseMaturity := params.CoinbaseMaturity
+	targetTimespan := Duration(params.TargetTimespan)
+	targetTimePerBlock := Duration(params.TargetTimePerBlock)
+	retargetAdjustmentFactor := params.RetargetAdjustmentFactor
+	reduceMinDifficulty := params.ReduceMinDifficulty
+	minDiffReductionTime := Duration(params.MinDiffReductionTime)
+	subsidyReductionInterval := params.SubsidyReductionInterval
+	bip0034Height := params.BIP0034Height
+	bip0065Height := params.BIP0065Height
+	bip0066Height := params.BIP0066Height
+	pubKeyHashAddrID := params.PubKeyHashAddrID
+	scriptHashAddrID := params.ScriptHashAddrID
+	privateKeyID := params.PrivateKeyID
+	relayNonStdTxs := params.RelayNonStdTxs
+	hdCoinType := params.HDCoinType
+
+	file := &ParamsFile{
+		Name:                     params.Name,
+		Base:                     base,
+		GenesisHash:              genesisHashString(params),
+		MessageStart:             hex.EncodeToString(messageStart),
+		DefaultPort:              params.DefaultPort,
+		CoinbaseMaturity:         &coinbaseMaturity,
+		TargetTimespan:           &targetTimespan,
+		TargetTimePerBlock:       &targetTimePerBlock,
+		RetargetAdjustmentFactor: &retargetAdjustmentFactor,
+		ReduceMinDifficulty:      &reduceMinDifficulty,
+		MinDiffReductionTime:     &minDiffReductionTime,
+		SubsidyReductionInterval: &subsidyReductionInterval,
+		BIP0034Height:            &bip0034Height,
+		BIP0065Height:            &bip0065Height,
+		BIP0066Height:            &bip0066Height,
+		PubKeyHashAddrID:         &pubKeyHashAddrID,
+		ScriptHashAddrID:         &scriptHashAddrID,
+		PrivateKeyID:             &privateKeyID,
+		Bech32HRPSegwit:          params.Bech32HRPSegwit,
+		RelayNonStdTxs:           &relayNonStdTxs,
+		HDPrivateKeyID:           hex.EncodeToString(params.HDPrivateKeyID[:]),
+		HDPublicKeyID:            hex.EncodeToString(params.HDPublicKeyID[:]),
+		HDCoinType:               &hdCoinType,
+	}
+
+	if params.PowLimit != nil {
+		file.PowLimit = NewBigInt(params.PowLimit)
+	}
+
+	for _, checkpoint := range params.Checkpoints {
+		file.Checkpoints = append(file.Checkpoints, &ParamsCheckpoint{
+			Height: checkpoint.Height,
+			Hash:   checkpoint.Hash.String(),
+		})
+	}
+
+	for _, seed := range params.DNSSeeds {
+		file.DNSSeeds = append(file.DNSSeeds, &ParamsDNSSeed{
+			Host:         seed.Host,
+			HasFiltering: seed.HasFiltering,
+		})
+	}
+
+	settings := chainSettings(params)
+	if len(settings.SubsidySchedule) > 0 {
+		file.SubsidySchedule = settings.SubsidySchedule
+	}
+
+	if settings.StakingAddrID != nil {
+		id := *settings.StakingAddrID
+		file.StakingAddrID = &id
+	}
+
+	if settings.CollateralAmount > 0 {
+		amount := settings.CollateralAmount
+		file.CollateralAmount = &amount
+	}
+
+	if settings.MaxMoney > 0 {
+		amount := settings.MaxMoney
+		file.MaxMoney = &amount
+	}
+
+	if fees := settings.RelayFees; fees != nil {
+		minRelayTxFee, dustLimit := fees.MinRelayTxFee, fees.DustLimit
+		file.MinRelayTxFee = &minRelayTxFee
+		file.DustLimit = &dustLimit
+	}
+
+	if versions := settings.ProtocolVersions; versions != nil {
+		if versions.MinProtocolVersion != 0 {
+			minProtocolVersion := versions.MinProtocolVersion
+			file.MinProtocolVersion = &minProtocolVersion
+		}
+		if versions.ProtocolVersion != 0 {
+			protocolVersion := versions.ProtocolVersion
+			file.ProtocolVersion = &protocolVersion
+		}
+	}
+
+	if settings.BudgetCycleBlocks > 0 {
+		blocks := settings.BudgetCycleBlocks
+		file.BudgetCycleBlocks = &blocks
+	}
+
+	if requirements := settings.ChainRequirements; requirements != nil {
+		if requirements.MinimumChainWork != nil {
+			file.MinimumChainWork = requirements.MinimumChainWork.Text(16) // nolint:gomnd
+		}
+		if requirements.AssumeValidHash != nil {
+			file.AssumeValid = requirements.AssumeValidHash.String()
+		}
+	}
+
+	if hashes := settings.ActivationHashes; len(hashes) > 0 {
+		file.ActivationHashes = make(map[string]string, len(hashes))
+		for name, hash := range hashes {
+			file.ActivationHashes[name] = hash.String()
+		}
+	}
+
+	if keys := settings.SporkKeys; keys != nil {
+		file.SporkKey = keys.PubKey
+		file.SporkKeyOld = keys.PubKeyOld
+		if !keys.EnforceNewSporkKey.IsZero() {
+			enforceNewSporkKey := keys.EnforceNewSporkKey.Unix()
+			file.EnforceNewSporkKey = &enforceNewSporkKey
+		}
+		if !keys.RejectOldSporkKey.IsZero() {
+			rejectOldSporkKey := keys.RejectOldSporkKey.Unix()
+			file.RejectOldSporkKey = &rejectOldSporkKey
+		}
+	}
+
+	return file
+}
+
+// MarshalParams returns the JSON encoding of params
+// (as a params file, see NewParamsFile).
+func MarshalParams(params *chaincfg.Params) ([]byte, error) {
+	return json.Marshal(NewParamsFile(params))
+}
+
+// UnmarshalParams returns the params encoded in data by
+// MarshalParams (or written as a params file). Unlike
+// LoadParamsFromFile, the params (and their subsidy
+// schedule) are not registered.
+func UnmarshalParams(data []byte) (*chaincfg.Params, error) {
+	var file ParamsFile
+	if err := json.Unmarshal(data, &file); err != nil {
+		return nil, fmt.Errorf("%w: unable to parse params", err)
+	}
+
+	return CreateParams(&file)
+}
@@ -0,0 +1,101 @@
+// Copyright 2020 Coinbase, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package bitcoin
+
+import (
+	"encoding/json"
+	"errors"
+	"math/big"
+	"testing"
+	"time"
+
+	"github.com/btcsuite/btcd/chaincfg"
+	"github.com/btcsuite/btcd/chaincfg/chainhash"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDurationJSON(t *testing.T) {
+	encoded, err := json.Marshal(Duration(90 * time.Second))
+	assert.NoError(t, err)
+	assert.Equal(t, `"1m30s"`, string(encoded))
+
+	var d Duration
+	assert.NoError(t, json.Unmarshal([]byte(`"2h"`), &d))
+	assert.Equal(t, Duration(2*time.Hour), d)
+
+	assert.Error(t, json.Unmarshal([]byte(`120`), &d))
+	assert.Error(t, json.Unmarshal([]byte(`"two hours"`), &d))
+}
+
+func TestBigIntJSON(t *testing.T) {
+	encoded, err := json.Marshal(NewBigInt(big.NewInt(0xffff)))
+	assert.NoError(t, err)
+	assert.Equal(
+		t,
+		`"000000000000000000000000000000000000000000000000000000000000ffff"`,
+		string(encoded),
+	)
+
+	var b BigInt
+	assert.NoError(t, json.Unmarshal(encoded, &b))
+	assert.Equal(t, big.NewInt(0xffff), b.Int())
+
+	err = json.Unmarshal([]byte(`"xyz"`), &b)
+	assert.True(t, errors.Is(err, ErrInvalidParams))
+	assert.Error(t, json.Unmarshal([]byte(`65535`), &b))
+}
+
+func TestMarshalParams(t *testing.T) {
+	genesisHash, err := chainhash.NewHashFromStr(testnetGenesisHash)
+	assert.NoError(t, err)
+
+	params := *CreateTestNetParams(genesisHash)
+	params.Name = "marshaled"
+	params.DNSSeeds = []chaincfg.DNSSeed{{Host: "seed.example.com", HasFiltering: true}}
+	params.Checkpoints = []chaincfg.Checkpoint{
+		{Height: 100, Hash: &chainhash.Hash{0x01}},
+	}
+	params.TargetTimePerBlock = time.Minute
+	params.TargetTimespan = 40 * time.Minute
+	params.PowLimit = new(big.Int).Sub(new(big.Int).Lsh(big.NewInt(1), 236), big.NewInt(1))
+
+	encoded, err := MarshalParams(&params)
+	assert.NoError(t, err)
+
+	decoded, err := UnmarshalParams(encoded)
+	assert.NoError(t, err)
+
+	// Everything but the genesis block
+	// (only its hash is encoded) is kept.
+	assert.Nil(t, decoded.GenesisBlock)
+	params.GenesisBlock = nil
+	params.PowLimitBits = decoded.PowLimitBits
+	assert.Equal(t, &params, decoded)
+	assert.Equal(t, uint32(0x1e0fffff), decoded.PowLimitBits)
+
+	// Mainnet params are based on mainnet.
+	assert.Equal(t, mainnetParamsBase, NewParamsFile(MainnetParams).Base)
+	assert.Equal(t, testnetParamsBase, NewParamsFile(&params).Base)
+
+	_, err = UnmarshalParams([]byte(`{"name": "invalid"}`))
+	assert.True(t, errors.Is(err, ErrInvalidParams))
+
+	// Blocks must be spaced within the timespan.
+	file := NewParamsFile(&params)
+	targetTimePerBlock := Duration(time.Hour)
+	file.TargetTimePerBlock = &targetTimePerBlock
+	_, err = CreateParams(file)
+	assert.True(t, errors.Is(err, ErrInvalidParams))
+}
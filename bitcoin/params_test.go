@@ -0,0 +1,364 @@
+// Copyright 2020 Coinbase, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package bitcoin
+
+import (
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"path"
+	"testing"
+
+	"github.com/btcsuite/btcd/chaincfg"
+	"github.com/btcsuite/btcd/wire"
+	"github.com/btcsuite/btcutil"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestLoadParamsFromFile(t *testing.T) {
+	defer ResetRegistry()
+
+	dir := t.TempDir()
+	paramsPath := path.Join(dir, "params.json")
+	assert.NoError(t, ioutil.WriteFile(paramsPath, []byte(`{
+		"name": "sibling",
+		"genesis_hash": "0000009ea234b1ab29f0172e4d85884a45c0c638192c9c0f781bda67908d56dd",
+		"message_start": "a1b2c3d4",
+		"default_port": "46000",
+		"bip0034_height": 10,
+		"pubkeyhash_addr_id": 63,
+		"scripthash_addr_id": 18,
+		"bech32_hrp_segwit": "sib",
+		"relay_non_std_txs": true,
+		"hd_private_key_id": "0488ade5",
+		"collateral_amount": 500000000000,
+		"max_money": 2100000000000000,
+		"dust_limit": 546,
+		"min_protocol_version": 70920,
+		"budget_cycle_blocks": 1000,
+		"minimum_chain_work": "0100",
+		"assume_valid": "00000c7c73d8ce604178dae13f0fc6ec0be3275614366d44b1b4b5c6e238c60c",
+		"activation_hashes": {
+			"bip34": "00000c7c73d8ce604178dae13f0fc6ec0be3275614366d44b1b4b5c6e238c60c"
+		}
+	}`), 0600))
+
+	params, err := LoadParamsFromFile(paramsPath)
+	assert.NoError(t, err)
+	assert.Equal(t, "sibling", params.Name)
+	assert.Equal(t, MainnetGenesisBlockIdentifier.Hash, params.GenesisHash.String())
+	assert.Equal(t, wire.BitcoinNet(0xd4c3b2a1), params.Net)
+	assert.Equal(t, "46000", params.DefaultPort)
+	assert.Equal(t, int32(10), params.BIP0034Height)
+	assert.Equal(t, MainnetParams.BIP0065Height, params.BIP0065Height)
+	assert.Equal(t, byte(63), params.PubKeyHashAddrID)
+	assert.Equal(t, byte(18), params.ScriptHashAddrID)
+	assert.Equal(t, MainnetParams.PrivateKeyID, params.PrivateKeyID)
+	assert.Equal(t, "sib", params.Bech32HRPSegwit)
+	assert.True(t, params.RelayNonStdTxs)
+	assert.Equal(t, [4]byte{0x04, 0x88, 0xad, 0xe5}, params.HDPrivateKeyID)
+	assert.Equal(t, MainnetParams.HDPublicKeyID, params.HDPublicKeyID)
+	assert.Len(t, params.DNSSeeds, 0)
+	assert.Nil(t, params.GenesisBlock)
+
+	settings, err := ChainParamsForNet(params.Net)
+	assert.NoError(t, err)
+	assert.Equal(t, int64(500000000000), settings.CollateralAmount)
+	assert.True(t, IsSuperblock(params, 2000))
+	assert.Equal(t, int64(2100000000000000), MaxMoney(params))
+	assert.Equal(t, &RelayPolicy{
+		RelayNonStdTxs: true,
+		MinRelayFee:    MainnetMinRelayTxFee,
+		DustLimit:      546,
+	}, NewRelayPolicy(params))
+	assert.Equal(t, &ProtocolVersions{MinProtocolVersion: 70920}, settings.ProtocolVersions)
+	assert.Nil(t, settings.StakingAddrID)
+
+	requirements := settings.ChainRequirements
+	assert.Equal(t, int64(0x100), requirements.MinimumChainWork.Int64())
+	assert.Equal(t, testnetGenesisHash, requirements.AssumeValidHash.String())
+
+	bip34, err := LookupActivation(params, BIP0034Activation)
+	assert.NoError(t, err)
+	assert.Equal(t, testnetGenesisHash, bip34.Hash.String())
+
+	// The mainnet params are not modified.
+	assert.Equal(t, "mainnet", MainnetParams.Name)
+	assert.Equal(t, byte(0x21), MainnetParams.PubKeyHashAddrID)
+
+	address, err := btcutil.NewAddressPubKeyHash(make([]byte, 20), params)
+	assert.NoError(t, err)
+	decoded, err := btcutil.DecodeAddress(address.EncodeAddress(), params)
+	assert.NoError(t, err)
+	assert.Equal(t, address.EncodeAddress(), decoded.EncodeAddress())
+	assert.Equal(t, []*AddressEra{
+		{Name: CurrentAddressEra, PubKeyHashAddrID: 63, ScriptHashAddrID: 18},
+	}, ParamsAddressEras(params))
+
+	// Loading the same params again succeeds
+	// (even though they are already registered).
+	_, err = LoadParamsFromFile(paramsPath)
+	assert.NoError(t, err)
+
+	_, err = LoadParamsFromFile(path.Join(dir, "missing.json"))
+	assert.Error(t, err)
+
+	// Files with the message start of a built-in
+	// network are rejected (so that they can't
+	// replace its params).
+	for _, builtIn := range []*ChainParams{MainnetChainParams, TestnetChainParams} {
+		copyPath := path.Join(dir, "copy.json")
+		assert.NoError(t, ioutil.WriteFile(copyPath, []byte(fmt.Sprintf(`{
+			"name": "copy",
+			"genesis_hash": "%s",
+			"message_start": "%s"
+		}`, builtIn.GenesisHash, NewParamsFile(builtIn.Params).MessageStart)), 0600))
+
+		_, err = LoadParamsFromFile(copyPath)
+		assert.True(t, errors.Is(err, ErrInvalidParams))
+		registered, err := ChainParamsForNet(builtIn.Net)
+		assert.NoError(t, err)
+		assert.Equal(t, builtIn, registered)
+	}
+
+	// Settings that are omitted are
+	// inherited from the base network.
+	inheritedPath := path.Join(dir, "inherited.json")
+	assert.NoError(t, ioutil.WriteFile(inheritedPath, []byte(`{
+		"name": "inherited",
+		"base": "testnet",
+		"genesis_hash": "00000c7c73d8ce604178dae13f0fc6ec0be3275614366d44b1b4b5c6e238c60c",
+		"message_start": "a1b2c3d6"
+	}`), 0600))
+
+	inherited, err := LoadParamsFromFile(inheritedPath)
+	assert.NoError(t, err)
+	settings, err = ChainParamsForNet(inherited.Net)
+	assert.NoError(t, err)
+	assert.Equal(t, TestnetCollateralAmount, settings.CollateralAmount)
+	assert.True(t, IsSuperblock(inherited, TestnetBudgetCycleBlocks))
+	assert.Equal(t, TestnetMaxMoney, MaxMoney(inherited))
+
+	testnet, err := CreateParams(&ParamsFile{
+		Name:         "sibling-testnet",
+		Base:         "testnet",
+		GenesisHash:  testnetGenesisHash,
+		MessageStart: "a1b2c3d5",
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, TestnetParams.PubKeyHashAddrID, testnet.PubKeyHashAddrID)
+	assert.Equal(t, TestnetParams.Bech32HRPSegwit, testnet.Bech32HRPSegwit)
+	assert.Equal(t, TestnetParams.RelayNonStdTxs, testnet.RelayNonStdTxs)
+
+	seeded, err := CreateParams(&ParamsFile{
+		Name:         "seeded",
+		GenesisHash:  testnetGenesisHash,
+		MessageStart: "a1b2c3d5",
+		DNSSeeds: []*ParamsDNSSeed{
+			{Host: "seed.example.com", HasFiltering: true},
+		},
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, []chaincfg.DNSSeed{
+		{Host: "seed.example.com", HasFiltering: true},
+	}, seeded.DNSSeeds)
+
+	// Devnets can describe their genesis
+	// block instead of its hash.
+	regtestGenesis := &GenesisBuilder{
+		Timestamp: 1296688602,
+		Nonce:     2,
+		Bits:      0x207fffff,
+		Message:   bitcoinGenesisMessage,
+	}
+	devnet, err := CreateParams(&ParamsFile{
+		Name:         "devnet",
+		Base:         testnetParamsBase,
+		MessageStart: "a1b2c3d5",
+		Genesis:      regtestGenesis,
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, chaincfg.RegressionNetParams.GenesisHash, devnet.GenesisHash)
+	assert.Equal(t, chaincfg.RegressionNetParams.GenesisBlock, devnet.GenesisBlock)
+
+	id := byte(5)
+	collateral := int64(0)
+	negative := int64(-1)
+	minProtocolVersion, protocolVersion := int64(70925), int64(70920)
+	invalid := map[string]*ParamsFile{
+		"no name": {
+			GenesisHash:  testnetGenesisHash,
+			MessageStart: "a1b2c3d5",
+		},
+		"invalid base": {
+			Name:         "sibling",
+			Base:         "regtest",
+			GenesisHash:  testnetGenesisHash,
+			MessageStart: "a1b2c3d5",
+		},
+		"invalid genesis hash": {
+			Name:         "sibling",
+			GenesisHash:  "1234",
+			MessageStart: "a1b2c3d5",
+		},
+		"invalid message start": {
+			Name:         "sibling",
+			GenesisHash:  testnetGenesisHash,
+			MessageStart: "a1b2c3",
+		},
+		"duplicate address IDs": {
+			Name:             "sibling",
+			GenesisHash:      testnetGenesisHash,
+			MessageStart:     "a1b2c3d5",
+			PubKeyHashAddrID: &id,
+			ScriptHashAddrID: &id,
+		},
+		"DNS seed without host": {
+			Name:         "sibling",
+			GenesisHash:  testnetGenesisHash,
+			MessageStart: "a1b2c3d5",
+			DNSSeeds:     []*ParamsDNSSeed{{HasFiltering: true}},
+		},
+		"invalid collateral amount": {
+			Name:             "sibling",
+			GenesisHash:      testnetGenesisHash,
+			MessageStart:     "a1b2c3d5",
+			CollateralAmount: &collateral,
+		},
+		"invalid max money": {
+			Name:         "sibling",
+			GenesisHash:  testnetGenesisHash,
+			MessageStart: "a1b2c3d5",
+			MaxMoney:     new(int64),
+		},
+		"invalid dust limit": {
+			Name:         "sibling",
+			GenesisHash:  testnetGenesisHash,
+			MessageStart: "a1b2c3d5",
+			DustLimit:    &negative,
+		},
+		"min protocol version exceeds protocol version": {
+			Name:               "sibling",
+			GenesisHash:        testnetGenesisHash,
+			MessageStart:       "a1b2c3d5",
+			MinProtocolVersion: &minProtocolVersion,
+			ProtocolVersion:    &protocolVersion,
+		},
+		"invalid budget cycle": {
+			Name:              "sibling",
+			GenesisHash:       testnetGenesisHash,
+			MessageStart:      "a1b2c3d5",
+			BudgetCycleBlocks: new(int64),
+		},
+		"invalid genesis": {
+			Name:         "devnet",
+			MessageStart: "a1b2c3d5",
+			Genesis:      &GenesisBuilder{Bits: 0x207fffff},
+		},
+		"genesis hash mismatch": {
+			Name:         "devnet",
+			GenesisHash:  testnetGenesisHash,
+			MessageStart: "a1b2c3d5",
+			Genesis:      regtestGenesis,
+		},
+		"invalid minimum chain work": {
+			Name:             "sibling",
+			GenesisHash:      testnetGenesisHash,
+			MessageStart:     "a1b2c3d5",
+			MinimumChainWork: "0x100",
+		},
+		"invalid assume valid": {
+			Name:         "sibling",
+			GenesisHash:  testnetGenesisHash,
+			MessageStart: "a1b2c3d5",
+			AssumeValid:  "1234",
+		},
+		"activation hash of deployment": {
+			Name:             "sibling",
+			GenesisHash:      testnetGenesisHash,
+			MessageStart:     "a1b2c3d5",
+			ActivationHashes: map[string]string{SegwitActivation: testnetGenesisHash},
+		},
+		"invalid activation hash": {
+			Name:             "sibling",
+			GenesisHash:      testnetGenesisHash,
+			MessageStart:     "a1b2c3d5",
+			ActivationHashes: map[string]string{BIP0034Activation: "1234"},
+		},
+		"invalid HD key ID": {
+			Name:           "sibling",
+			GenesisHash:    testnetGenesisHash,
+			MessageStart:   "a1b2c3d5",
+			HDPublicKeyID:  "0488b2",
+			HDPrivateKeyID: "0488ade5",
+		},
+	}
+
+	for name, test := range invalid {
+		t.Run(name, func(t *testing.T) {
+			_, err := CreateParams(test)
+			assert.True(t, errors.Is(err, ErrInvalidParams))
+		})
+	}
+}
+
+func TestParamsFingerprint(t *testing.T) {
+	assert.Equal(t, ParamsFingerprint(MainnetParams), ParamsFingerprint(MainnetParams))
+	assert.NotEqual(t, ParamsFingerprint(MainnetParams), ParamsFingerprint(TestnetParams))
+
+	// Settings indexed data depends on change the fingerprint
+	modified := *MainnetParams
+	modified.BIP0066Height++
+	assert.NotEqual(t, ParamsFingerprint(MainnetParams), ParamsFingerprint(&modified))
+
+	// Other settings don't
+	modified = *MainnetParams
+	modified.DefaultPort = "1"
+	assert.Equal(t, ParamsFingerprint(MainnetParams), ParamsFingerprint(&modified))
+}
+
+func TestCloneParams(t *testing.T) {
+	params := CloneParams(&chaincfg.TestNet3Params)
+	assert.Equal(t, &chaincfg.TestNet3Params, params)
+
+	params.GenesisBlock.Header.Nonce++
+	params.GenesisBlock.Transactions[0].TxOut[0].Value++
+	params.GenesisHash[0]++
+	params.PowLimit.SetInt64(1)
+	assert.NotEqual(t, chaincfg.TestNet3Params.GenesisBlock.Header.Nonce, params.GenesisBlock.Header.Nonce)
+	assert.NotEqual(
+		t,
+		chaincfg.TestNet3Params.GenesisBlock.Transactions[0].TxOut[0].Value,
+		params.GenesisBlock.Transactions[0].TxOut[0].Value,
+	)
+	assert.NotEqual(t, chaincfg.TestNet3Params.GenesisHash, params.GenesisHash)
+	assert.NotEqual(t, chaincfg.TestNet3Params.PowLimit, params.PowLimit)
+
+	// Params without a genesis block (like
+	// mainnet) are cloned.
+	mainnet := CloneParams(MainnetParams)
+	assert.Equal(t, MainnetParams, mainnet)
+	assert.Equal(t, MainnetGenesisBlockIdentifier.Hash, mainnet.GenesisHash.String())
+
+	// Checkpoints and DNS seeds of the
+	// clone don't alias the original.
+	base := CloneParams(&chaincfg.TestNet3Params)
+	clone := CloneParams(base)
+	clone.Checkpoints[0].Hash[0]++
+	clone.Checkpoints[1].Height++
+	clone.DNSSeeds[0].Host = "seed.example.com"
+	assert.Equal(t, chaincfg.TestNet3Params.Checkpoints, base.Checkpoints)
+	assert.Equal(t, chaincfg.TestNet3Params.DNSSeeds, base.DNSSeeds)
+}
@@ -0,0 +1,226 @@
+// Copyright 2020 Coinbase, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package bitcoin
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/btcsuite/btcd/blockchain"
+	"github.com/btcsuite/btcd/chaincfg"
+	"github.com/btcsuite/btcd/txscript"
+	"github.com/btcsuite/btcd/wire"
+)
+
+const (
+	// MinRelayFee is MinFeeRate in
+	// satoshis per kB.
+	MinRelayFee = int64(1000)
+
+	// MainnetMinRelayTxFee and TestnetMinRelayTxFee are
+	// the minimum relay fees (in satoshis per kB) of the
+	// nodes of mainnet and testnet (the relayfee they
+	// report in `getnetworkinfo`).
+	MainnetMinRelayTxFee = int64(10000)
+	TestnetMinRelayTxFee = int64(10000)
+
+	// maxStandardMultiSigKeys is the maximum number of
+	// keys of a standard multisig output.
+	maxStandardMultiSigKeys = 3
+
+	// outPointInputSize is the size of the outpoint,
+	// script length, and sequence of an input.
+	outPointInputSize = 41
+
+	// redeemScriptSize is the size of the script (or
+	// witness) that redeems a typical output.
+	redeemScriptSize = 107
+
+	// dustRelayFeeDivisor is the share of the minimum relay
+	// fee an output may cost to spend (as a divisor) before
+	// it is considered dust.
+	dustRelayFeeDivisor = 3
+
+	// bytesInKB is the number of bytes
+	// relay fees are priced per.
+	bytesInKB = 1000
+)
+
+var (
+	// ErrNonStandardOutput is returned when an output
+	// violates the relay policy of the node (and the
+	// transaction would not be relayed).
+	ErrNonStandardOutput = errors.New("non-standard output")
+
+	// ErrInsufficientFee is returned when a transaction pays
+	// less than the minimum relay fee of the node.
+	ErrInsufficientFee = errors.New("fee below minimum relay fee")
+)
+
+// RelayFees are the relay fee and dust threshold of
+// a network whose nodes don't use the defaults.
+type RelayFees struct {
+	// MinRelayTxFee is the minimum relay fee
+	// (in satoshis per kB).
+	MinRelayTxFee int64
+
+	// DustLimit is the smallest value (in satoshis) of an
+	// output that is not dust. Outputs above it are still
+	// dust if they cost too much to spend at MinRelayTxFee
+	// (see IsDust).
+	DustLimit int64
+}
+
+// RelayPolicy are the standardness rules outputs
+// must satisfy to be relayed by the node.
+type RelayPolicy struct {
+	// RelayNonStdTxs is true if the node relays
+	// non-standard (and dust) outputs.
+	RelayNonStdTxs bool
+
+	// MinRelayFee is the minimum relay fee (in satoshis
+	// per kB) of a transaction, which dust is priced at.
+	MinRelayFee int64
+
+	// DustLimit is the smallest value (in satoshis)
+	// of an output that is not dust (0 if dust is
+	// only priced at MinRelayFee).
+	DustLimit int64
+}
+
+// NewRelayPolicy returns the *RelayPolicy of the network
+// with params (at the RelayFees registered for it, or at
+// MinRelayFee without a dust limit).
+func NewRelayPolicy(params *chaincfg.Params) *RelayPolicy {
+	policy := &RelayPolicy{
+		RelayNonStdTxs: params.RelayNonStdTxs,
+		MinRelayFee:    MinRelayFee,
+	}
+
+	if fees := chainSettings(params).RelayFees; fees != nil {
+		policy.MinRelayFee = fees.MinRelayTxFee
+		policy.DustLimit = fees.DustLimit
+	}
+
+	return policy
+}
+
+// IsDust returns true if output is below the dust
+// limit of p or is dust at its minimum relay fee
+// (see IsDust).
+func (p *RelayPolicy) IsDust(output *wire.TxOut) bool {
+	if output.Value < p.DustLimit {
+		return true
+	}
+
+	return IsDust(output, p.MinRelayFee)
+}
+
+// MinFee returns the minimum relay fee (in satoshis) of
+// a transaction of size (in vbytes) under p.
+func (p *RelayPolicy) MinFee(size int64) int64 {
+	return p.MinRelayFee * size / bytesInKB
+}
+
+// CheckFee returns ErrInsufficientFee if fee (in satoshis)
+// is less than the minimum relay fee of a transaction of
+// size (in vbytes) under p.
+func (p *RelayPolicy) CheckFee(fee int64, size int64) error {
+	if minFee := p.MinFee(size); fee < minFee {
+		return fmt.Errorf(
+			"%w: fee %d is below %d for %d vbytes at %d per kB",
+			ErrInsufficientFee,
+			fee,
+			minFee,
+			size,
+			p.MinRelayFee,
+		)
+	}
+
+	return nil
+}
+
+// IsDust returns true if spending output would cost more
+// than a third of its value at minRelayFee (in satoshis
+// per kB). Unspendable outputs are always dust.
+func IsDust(output *wire.TxOut, minRelayFee int64) bool {
+	if txscript.IsUnspendable(output.PkScript) {
+		return true
+	}
+
+	// Witness data is discounted.
+	size := int64(output.SerializeSize() + outPointInputSize)
+	if txscript.IsWitnessProgram(output.PkScript) {
+		size += redeemScriptSize / blockchain.WitnessScaleFactor
+	} else {
+		size += redeemScriptSize
+	}
+
+	return output.Value*bytesInKB/(dustRelayFeeDivisor*size) < minRelayFee
+}
+
+// CheckOutput returns ErrNonStandardOutput if output
+// violates p (scripts over the maximum script size
+// are rejected even if non-standard outputs are
+// relayed, as they can't be spent).
+func (p *RelayPolicy) CheckOutput(output *wire.TxOut) error {
+	if len(output.PkScript) > txscript.MaxScriptSize {
+		return fmt.Errorf(
+			"%w: script size %d exceeds %d",
+			ErrNonStandardOutput,
+			len(output.PkScript),
+			txscript.MaxScriptSize,
+		)
+	}
+
+	if p.RelayNonStdTxs {
+		return nil
+	}
+
+	switch class := txscript.GetScriptClass(output.PkScript); {
+	case class == txscript.MultiSigTy:
+		keys, signatures, err := txscript.CalcMultiSigStats(output.PkScript)
+		if err != nil {
+			return fmt.Errorf("%w: %s", ErrNonStandardOutput, err.Error())
+		}
+
+		if keys < 1 || keys > maxStandardMultiSigKeys || signatures < 1 || signatures > keys {
+			return fmt.Errorf(
+				"%w: %d of %d multisig",
+				ErrNonStandardOutput,
+				signatures,
+				keys,
+			)
+		}
+	case class == txscript.NonStandardTy && !isColdStake(output.PkScript):
+		return fmt.Errorf("%w: script is not a standard form", ErrNonStandardOutput)
+	case class == txscript.NullDataTy:
+		// Data outputs are unspendable, so
+		// they are not dust.
+		return nil
+	}
+
+	if p.IsDust(output) {
+		return fmt.Errorf(
+			"%w: value %d is dust at relay fee %d and dust limit %d",
+			ErrNonStandardOutput,
+			output.Value,
+			p.MinRelayFee,
+			p.DustLimit,
+		)
+	}
+
+	return nil
+}
@@ -0,0 +1,141 @@
+// Copyright 2020 Coinbase, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package bitcoin
+
+import (
+	"bytes"
+	"encoding/hex"
+	"errors"
+	"testing"
+
+	"github.com/btcsuite/btcd/txscript"
+	"github.com/btcsuite/btcd/wire"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRelayPolicy(t *testing.T) {
+	p2pkh, err := hex.DecodeString("76a914c005b00ad075d30b89a7b65b7dad8899ba6a9c5588ac")
+	assert.NoError(t, err)
+	p2wpkh, err := hex.DecodeString("0014c005b00ad075d30b89a7b65b7dad8899ba6a9c55")
+	assert.NoError(t, err)
+	nullData, err := txscript.NullDataScript([]byte("data"))
+	assert.NoError(t, err)
+	coldStake, err := hex.DecodeString(
+		"76a97b63d214c398efa9c392ba6013c5e04ee729755ef7f58b326714228f554bbf766d6f9cc828de1126e3d35d15e5fe6888ac", // nolint
+	)
+	assert.NoError(t, err)
+
+	tests := map[string]struct {
+		output *wire.TxOut
+		err    bool
+	}{
+		"p2pkh": {
+			output: &wire.TxOut{Value: 546, PkScript: p2pkh},
+		},
+		"p2pkh dust": {
+			output: &wire.TxOut{Value: 545, PkScript: p2pkh},
+			err:    true,
+		},
+		"p2wpkh": {
+			output: &wire.TxOut{Value: 294, PkScript: p2wpkh},
+		},
+		"p2wpkh dust": {
+			output: &wire.TxOut{Value: 293, PkScript: p2wpkh},
+			err:    true,
+		},
+		"null data": {
+			output: &wire.TxOut{Value: 0, PkScript: nullData},
+		},
+		"non-standard": {
+			output: &wire.TxOut{Value: 100000, PkScript: []byte{txscript.OP_TRUE}},
+			err:    true,
+		},
+		"cold stake": {
+			output: &wire.TxOut{Value: 100000, PkScript: coldStake},
+		},
+	}
+
+	// Mainnet nodes relay at a higher fee
+	// (so more outputs are dust).
+	policy := NewRelayPolicy(MainnetParams)
+	assert.Equal(t, MainnetMinRelayTxFee, policy.MinRelayFee)
+	assert.True(t, errors.Is(policy.CheckOutput(tests["p2pkh"].output), ErrNonStandardOutput))
+	assert.NoError(t, policy.CheckOutput(&wire.TxOut{Value: 5460, PkScript: p2pkh}))
+
+	// Networks without relay fees use
+	// the upstream relay fee.
+	params := CloneParams(MainnetParams)
+	params.Net = wire.BitcoinNet(0xa1b2c3f8)
+	policy = NewRelayPolicy(params)
+	assert.False(t, policy.RelayNonStdTxs)
+	assert.Equal(t, MinRelayFee, policy.MinRelayFee)
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			err := policy.CheckOutput(test.output)
+			if test.err {
+				assert.True(t, errors.Is(err, ErrNonStandardOutput))
+			} else {
+				assert.NoError(t, err)
+			}
+		})
+	}
+
+	// Only the script size is checked when
+	// non-standard outputs are relayed
+	policy.RelayNonStdTxs = true
+	assert.NoError(t, policy.CheckOutput(tests["p2pkh dust"].output))
+	assert.NoError(t, policy.CheckOutput(tests["non-standard"].output))
+	oversized := &wire.TxOut{
+		Value:    100000,
+		PkScript: bytes.Repeat([]byte{txscript.OP_NOP}, txscript.MaxScriptSize+1),
+	}
+	assert.True(t, errors.Is(policy.CheckOutput(oversized), ErrNonStandardOutput))
+}
+
+func TestRelayPolicy_RelayFees(t *testing.T) {
+	p2pkh, err := hex.DecodeString("76a914c005b00ad075d30b89a7b65b7dad8899ba6a9c5588ac")
+	assert.NoError(t, err)
+
+	params := CloneParams(MainnetParams)
+	params.Net = wire.BitcoinNet(0xa1b2c3f7)
+
+	policy := NewRelayPolicy(params)
+	assert.Equal(t, MinRelayFee, policy.MinRelayFee)
+	assert.Equal(t, int64(0), policy.DustLimit)
+	assert.Equal(t, int64(142), policy.MinFee(142))
+	assert.NoError(t, policy.CheckFee(142, 142))
+	assert.True(t, errors.Is(policy.CheckFee(141, 142), ErrInsufficientFee))
+
+	assert.NoError(t, RegisterChainParams(&ChainParams{
+		Params:    params,
+		RelayFees: &RelayFees{MinRelayTxFee: 10000, DustLimit: 10000},
+	}))
+	defer ResetRegistry()
+
+	policy = NewRelayPolicy(params)
+	assert.Equal(t, int64(10000), policy.MinRelayFee)
+	assert.Equal(t, int64(10000), policy.DustLimit)
+	assert.True(t, errors.Is(policy.CheckFee(1000, 142), ErrInsufficientFee))
+	assert.NoError(t, policy.CheckFee(1420, 142))
+
+	// The dust limit is above the
+	// dust value at the relay fee
+	assert.True(t, policy.IsDust(&wire.TxOut{Value: 9999, PkScript: p2pkh}))
+	assert.False(t, policy.IsDust(&wire.TxOut{Value: 10000, PkScript: p2pkh}))
+	assert.True(t, errors.Is(
+		policy.CheckOutput(&wire.TxOut{Value: 9999, PkScript: p2pkh}),
+		ErrNonStandardOutput,
+	))
+}
@@ -0,0 +1,87 @@
+// Copyright 2020 Coinbase, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package bitcoin
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/btcsuite/btcd/chaincfg"
+)
+
+// MainnetProtocolVersion and TestnetProtocolVersion are
+// the protocol versions of the nodes of mainnet and testnet
+// the data indexed is parsed from (Euno Core 5.3), which is
+// both the lowest and the latest supported.
+const (
+	MainnetProtocolVersion = int64(70925)
+	TestnetProtocolVersion = int64(70925)
+)
+
+var (
+	// ErrNodeTooOld is returned when the protocol version
+	// of the node is below the MinProtocolVersion of the
+	// network.
+	ErrNodeTooOld = errors.New("node protocol version is too old")
+)
+
+// ProtocolVersions are the P2P protocol versions
+// (reported by `getnetworkinfo`) of a network the
+// data indexed depends on.
+type ProtocolVersions struct {
+	// MinProtocolVersion is the lowest protocol version
+	// of a node that serves the data indexed (0 if any
+	// version does).
+	MinProtocolVersion int64
+
+	// ProtocolVersion is the latest protocol version
+	// known to be served correctly (0 if unknown).
+	// Newer nodes are used, but may serve data that
+	// is not parsed.
+	ProtocolVersion int64
+}
+
+// CheckProtocolVersion returns ErrNodeTooOld if the protocol
+// version of node is below the MinProtocolVersion registered
+// for the network with params. Networks without registered
+// ProtocolVersions accept any node.
+func CheckProtocolVersion(params *chaincfg.Params, node *NodeNetwork) error {
+	versions := chainSettings(params).ProtocolVersions
+	if versions == nil || node.ProtocolVersion >= versions.MinProtocolVersion {
+		return nil
+	}
+
+	return fmt.Errorf(
+		"%w: node %s speaks protocol version %d but %s requires at least %d",
+		ErrNodeTooOld,
+		node.SubVersion,
+		node.ProtocolVersion,
+		params.Name,
+		versions.MinProtocolVersion,
+	)
+}
+
+// IsNewerProtocolVersion returns true if the protocol version
+// of node is above the ProtocolVersion registered for the
+// network with params (so it may serve data that is not
+// parsed).
+func IsNewerProtocolVersion(params *chaincfg.Params, node *NodeNetwork) bool {
+	versions := chainSettings(params).ProtocolVersions
+	if versions == nil || versions.ProtocolVersion == 0 {
+		return false
+	}
+
+	return node.ProtocolVersion > versions.ProtocolVersion
+}
@@ -0,0 +1,60 @@
+// Copyright 2020 Coinbase, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package bitcoin
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/btcsuite/btcd/wire"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCheckProtocolVersion(t *testing.T) {
+	params := CloneParams(MainnetParams)
+	params.Net = wire.BitcoinNet(0xa1b2c3f8)
+	node := &NodeNetwork{
+		SubVersion:      "/Euno Core:5.2.0/",
+		ProtocolVersion: 70924,
+	}
+
+	// Any node is accepted without
+	// registered versions
+	assert.NoError(t, CheckProtocolVersion(params, node))
+	assert.False(t, IsNewerProtocolVersion(params, node))
+
+	assert.NoError(t, RegisterChainParams(&ChainParams{
+		Params:           params,
+		ProtocolVersions: &ProtocolVersions{MinProtocolVersion: 70924},
+	}))
+	defer ResetRegistry()
+	assert.NoError(t, CheckProtocolVersion(params, node))
+	assert.False(t, IsNewerProtocolVersion(params, node))
+
+	assert.NoError(t, RegisterChainParams(&ChainParams{
+		Params: params,
+		ProtocolVersions: &ProtocolVersions{
+			MinProtocolVersion: 70925,
+			ProtocolVersion:    70926,
+		},
+	}))
+	err := CheckProtocolVersion(params, node)
+	assert.True(t, errors.Is(err, ErrNodeTooOld))
+	assert.Contains(t, err.Error(), "/Euno Core:5.2.0/")
+
+	node.ProtocolVersion = 70927
+	assert.NoError(t, CheckProtocolVersion(params, node))
+	assert.True(t, IsNewerProtocolVersion(params, node))
+}
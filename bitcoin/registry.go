@@ -0,0 +1,320 @@
+// Copyright 2020 Coinbase, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package bitcoin
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"sync"
+
+	"github.com/btcsuite/btcd/chaincfg"
+	"github.com/btcsuite/btcd/chaincfg/chainhash"
+	"github.com/btcsuite/btcd/wire"
+)
+
+// ChainParams are the params of a network: its
+// *chaincfg.Params and the settings of the chain that
+// have no counterpart in them (which are zero, or nil,
+// if the network doesn't have them).
+type ChainParams struct {
+	*chaincfg.Params
+
+	// StakingAddrID is the version byte of staking
+	// addresses (see StakingAddress).
+	StakingAddrID *byte
+
+	// CollateralAmount is the amount (in satoshis) locked as
+	// the collateral of a masternode (see IsMasternodeCollateral).
+	CollateralAmount int64
+
+	// MaxMoney is the largest amount (in satoshis)
+	// of an output of the chain (see MoneyRange).
+	MaxMoney int64
+
+	// RelayFees are the relay fees of
+	// nodes of the network (see RelayPolicy).
+	RelayFees *RelayFees
+
+	// ProtocolVersions are the protocol versions of the
+	// nodes the indexer uses (see CheckProtocolVersion).
+	ProtocolVersions *ProtocolVersions
+
+	// BudgetCycleBlocks is the number of blocks
+	// of a budget cycle (see IsSuperblock).
+	BudgetCycleBlocks int64
+
+	// ChainRequirements are the requirements of the
+	// chain of the node (see ChainRequirements).
+	ChainRequirements *ChainRequirements
+
+	// ActivationHashes are the hashes of the activation
+	// blocks of the rule changes activating at a fixed
+	// height, keyed by activation name (see Activations).
+	ActivationHashes map[string]*chainhash.Hash
+
+	// SubsidySchedule is the emission schedule
+	// of the network (see BlockSubsidy).
+	SubsidySchedule []*SubsidyPhase
+
+	// SporkKeys are the keys spork messages of the
+	// network are signed with (see SporkPubKey).
+	SporkKeys *SporkKeys
+}
+
+var (
+	// ErrNetworkNotRegistered is returned when no
+	// params are registered for a network.
+	ErrNetworkNotRegistered = errors.New("network not registered")
+
+	// ErrAmbiguousNetworkName is returned when params
+	// of several networks are registered with a name.
+	ErrAmbiguousNetworkName = errors.New("ambiguous network name")
+
+	// ErrAmbiguousHDKeyID is returned when an extended
+	// key version is used by several networks with
+	// different params.
+	ErrAmbiguousHDKeyID = errors.New("ambiguous HD key ID")
+
+	// registry stores the params registered for each
+	// network (by message start). It is guarded by
+	// registryMutex, as is chaincfgRegistered.
+	registry      = map[wire.BitcoinNet]*ChainParams{}
+	registryMutex sync.RWMutex
+
+	// chaincfgRegistered are the networks registered with
+	// chaincfg. chaincfg.Register mutates package-level maps
+	// without locking (and registrations can't be removed),
+	// so each network is only registered with it once (while
+	// holding registryMutex).
+	chaincfgRegistered = map[wire.BitcoinNet]struct{}{}
+)
+
+func init() {
+	ResetRegistry()
+}
+
+// registerLocked registers params (replacing any params
+// registered for the same network). registryMutex must
+// be held.
+func registerLocked(params *ChainParams) error {
+	if _, ok := chaincfgRegistered[params.Net]; !ok {
+		// The upstream networks are registered by
+		// chaincfg itself, so ErrDuplicateNet is
+		// expected.
+		err := chaincfg.Register(params.Params)
+		if err != nil && !errors.Is(err, chaincfg.ErrDuplicateNet) {
+			return fmt.Errorf("%w: unable to register %s", err, params.Name)
+		}
+
+		chaincfgRegistered[params.Net] = struct{}{}
+	}
+
+	registry[params.Net] = params
+	return nil
+}
+
+// Register registers params for its network (replacing
+// any params registered for the same network), so that
+// they can be looked up with ParamsForNet (or ParamsByName) and addresses
+// of the network can be decoded. The network has none of
+// the settings of ChainParams (see RegisterChainParams).
+// It is safe to call concurrently.
+func Register(params *chaincfg.Params) error {
+	return RegisterChainParams(&ChainParams{Params: params})
+}
+
+// RegisterChainParams registers params (and the settings
+// of its chain) for its network, replacing any params
+// registered for the same network. Registered params must
+// not be modified (register a modified copy instead). It
+// is safe to call concurrently.
+func RegisterChainParams(params *ChainParams) error {
+	if len(params.SubsidySchedule) > 0 {
+		if err := ValidateSubsidySchedule(params.SubsidySchedule); err != nil {
+			return err
+		}
+	}
+
+	if params.SporkKeys != nil {
+		if err := ValidateSporkKeys(params.SporkKeys); err != nil {
+			return err
+		}
+	}
+
+	for name, hash := range params.ActivationHashes {
+		if !isFixedActivation(name) || hash == nil {
+			return fmt.Errorf("%w: %s does not activate at a fixed height", ErrUnknownUpgrade, name)
+		}
+	}
+
+	registryMutex.Lock()
+	defer registryMutex.Unlock()
+
+	return registerLocked(params)
+}
+
+// Unregister removes the params registered for net. The
+// bech32 prefix of the network remains known to chaincfg
+// (which does not support removing registrations), but
+// the params can no longer be looked up.
+func Unregister(net wire.BitcoinNet) error {
+	registryMutex.Lock()
+	defer registryMutex.Unlock()
+
+	if _, ok := registry[net]; !ok {
+		return fmt.Errorf("%w: %s", ErrNetworkNotRegistered, net)
+	}
+
+	delete(registry, net)
+	return nil
+}
+
+// ResetRegistry removes all registered params
+// except those of mainnet and testnet.
+func ResetRegistry() {
+	registryMutex.Lock()
+	defer registryMutex.Unlock()
+
+	registry = map[wire.BitcoinNet]*ChainParams{}
+	for _, params := range []*ChainParams{MainnetChainParams, TestnetChainParams} {
+		// Registering the default params can't
+		// fail (they are registered at init).
+		_ = registerLocked(params)
+	}
+}
+
+// ParamsForNet returns the params registered for net.
+func ParamsForNet(net wire.BitcoinNet) (*chaincfg.Params, error) {
+	params, err := ChainParamsForNet(net)
+	if err != nil {
+		return nil, err
+	}
+
+	return params.Params, nil
+}
+
+// ChainParamsForNet returns the params (and the
+// settings of the chain) registered for net.
+func ChainParamsForNet(net wire.BitcoinNet) (*ChainParams, error) {
+	registryMutex.RLock()
+	defer registryMutex.RUnlock()
+
+	params, ok := registry[net]
+	if !ok {
+		return nil, fmt.Errorf("%w: %s", ErrNetworkNotRegistered, net)
+	}
+
+	return params, nil
+}
+
+// chainSettings returns the settings of the chain of the
+// network with params (which are all zero if the network
+// is not registered).
+func chainSettings(params *chaincfg.Params) *ChainParams {
+	registered, err := ChainParamsForNet(params.Net)
+	if err != nil {
+		return &ChainParams{Params: params}
+	}
+
+	return registered
+}
+
+// ParamsByName returns the params registered with name
+// (i.e. "mainnet"). Names aren't unique across networks,
+// so ErrAmbiguousNetworkName is returned if params of
+// several networks are registered with name.
+func ParamsByName(name string) (*chaincfg.Params, error) {
+	registryMutex.RLock()
+	defer registryMutex.RUnlock()
+
+	var match *chaincfg.Params
+	for _, params := range registry {
+		if params.Name != name {
+			continue
+		}
+
+		if match != nil {
+			return nil, fmt.Errorf("%w: %s", ErrAmbiguousNetworkName, name)
+		}
+
+		match = params.Params
+	}
+
+	if match == nil {
+		return nil, fmt.Errorf("%w: %s", ErrNetworkNotRegistered, name)
+	}
+
+	return match, nil
+}
+
+// HDPublicKeyToPrivateKeyID returns the version of the
+// extended private keys of the network whose extended
+// public keys have version id (the inverse of
+// chaincfg.HDPrivateKeyToPublicKeyID).
+func HDPublicKeyToPrivateKeyID(id []byte) ([]byte, error) {
+	registryMutex.RLock()
+	defer registryMutex.RUnlock()
+
+	var privateKeyID []byte
+	for _, params := range registry {
+		if !bytes.Equal(params.HDPublicKeyID[:], id) {
+			continue
+		}
+
+		if privateKeyID != nil && !bytes.Equal(privateKeyID, params.HDPrivateKeyID[:]) {
+			return nil, fmt.Errorf("%w: %x", ErrAmbiguousHDKeyID, id)
+		}
+
+		privateKeyID = append([]byte{}, params.HDPrivateKeyID[:]...)
+	}
+
+	if privateKeyID == nil {
+		return nil, fmt.Errorf("%w: %x", chaincfg.ErrUnknownHDKeyID, id)
+	}
+
+	return privateKeyID, nil
+}
+
+// ParamsForHDKeyID returns the params registered for the
+// network whose extended (private or public) keys have
+// version id. Networks that only differ in their consensus
+// rules (like testnet and signet) share versions, so
+// ErrAmbiguousHDKeyID is returned if id is used by
+// several registered networks.
+func ParamsForHDKeyID(id []byte) (*chaincfg.Params, error) {
+	registryMutex.RLock()
+	defer registryMutex.RUnlock()
+
+	var match *chaincfg.Params
+	for _, params := range registry {
+		if !bytes.Equal(params.HDPrivateKeyID[:], id) &&
+			!bytes.Equal(params.HDPublicKeyID[:], id) {
+			continue
+		}
+
+		if match != nil {
+			return nil, fmt.Errorf("%w: %x", ErrAmbiguousHDKeyID, id)
+		}
+
+		match = params.Params
+	}
+
+	if match == nil {
+		return nil, fmt.Errorf("%w: HD key ID %x", ErrNetworkNotRegistered, id)
+	}
+
+	return match, nil
+}
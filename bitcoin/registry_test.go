@@ -0,0 +1,163 @@
+// Copyright 2020 Coinbase, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package bitcoin
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+	"testing"
+
+	"github.com/btcsuite/btcd/chaincfg"
+	"github.com/btcsuite/btcd/wire"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRegistry(t *testing.T) {
+	defer ResetRegistry()
+
+	// The default networks are registered
+	for _, params := range []*chaincfg.Params{MainnetParams, TestnetParams} {
+		registered, err := ParamsForNet(params.Net)
+		assert.NoError(t, err)
+		assert.Equal(t, params, registered)
+	}
+
+	custom := *MainnetParams
+	custom.Name = "custom"
+	custom.Net = wire.BitcoinNet(0xa1b2c3f1)
+	custom.Bech32HRPSegwit = "cust"
+	_, err := ParamsForNet(custom.Net)
+	assert.True(t, errors.Is(err, ErrNetworkNotRegistered))
+
+	assert.NoError(t, Register(&custom))
+	registered, err := ParamsForNet(custom.Net)
+	assert.NoError(t, err)
+	assert.Equal(t, &custom, registered)
+	assert.True(t, chaincfg.IsBech32SegwitPrefix("cust1"))
+
+	// Params of the same network are replaced
+	replacement := custom
+	replacement.Name = "replacement"
+	assert.NoError(t, Register(&replacement))
+	registered, err = ParamsForNet(custom.Net)
+	assert.NoError(t, err)
+	assert.Equal(t, "replacement", registered.Name)
+
+	// Params can be looked up by name
+	registered, err = ParamsByName("replacement")
+	assert.NoError(t, err)
+	assert.Equal(t, custom.Net, registered.Net)
+	_, err = ParamsByName("custom")
+	assert.True(t, errors.Is(err, ErrNetworkNotRegistered))
+	registered, err = ParamsByName(TestnetParams.Name)
+	assert.NoError(t, err)
+	assert.Equal(t, TestnetParams, registered)
+
+	duplicate := replacement
+	duplicate.Net = wire.BitcoinNet(0xa1b2c3f2)
+	assert.NoError(t, Register(&duplicate))
+	_, err = ParamsByName("replacement")
+	assert.True(t, errors.Is(err, ErrAmbiguousNetworkName))
+	assert.NoError(t, Unregister(duplicate.Net))
+
+	assert.NoError(t, Unregister(custom.Net))
+	_, err = ParamsForNet(custom.Net)
+	assert.True(t, errors.Is(err, ErrNetworkNotRegistered))
+	assert.True(t, errors.Is(Unregister(custom.Net), ErrNetworkNotRegistered))
+
+	// Networks can be registered concurrently
+	var wg sync.WaitGroup
+	for j := 0; j < 10; j++ {
+		params := custom
+		params.Name = fmt.Sprintf("concurrent %d", j)
+		params.Net = wire.BitcoinNet(0xa1b2c400 + uint32(j))
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			assert.NoError(t, Register(&params))
+			_, err := ParamsForNet(params.Net)
+			assert.NoError(t, err)
+		}()
+	}
+	wg.Wait()
+
+	ResetRegistry()
+	_, err = ParamsForNet(wire.BitcoinNet(0xa1b2c400))
+	assert.True(t, errors.Is(err, ErrNetworkNotRegistered))
+	_, err = ParamsForNet(MainnetParams.Net)
+	assert.NoError(t, err)
+}
+
+func TestHDKeyIDLookup(t *testing.T) {
+	defer ResetRegistry()
+
+	privateKeyID, err := HDPublicKeyToPrivateKeyID(MainnetParams.HDPublicKeyID[:])
+	assert.NoError(t, err)
+	assert.Equal(t, MainnetParams.HDPrivateKeyID[:], privateKeyID)
+
+	// The lookups are the inverse of each other
+	publicKeyID, err := chaincfg.HDPrivateKeyToPublicKeyID(privateKeyID)
+	assert.NoError(t, err)
+	assert.Equal(t, MainnetParams.HDPublicKeyID[:], publicKeyID)
+
+	for _, id := range [][]byte{MainnetParams.HDPrivateKeyID[:], MainnetParams.HDPublicKeyID[:]} {
+		params, err := ParamsForHDKeyID(id)
+		assert.NoError(t, err)
+		assert.Equal(t, MainnetParams, params)
+	}
+
+	// Testnet and signet share versions
+	privateKeyID, err = HDPublicKeyToPrivateKeyID(TestnetParams.HDPublicKeyID[:])
+	assert.NoError(t, err)
+	assert.Equal(t, TestnetParams.HDPrivateKeyID[:], privateKeyID)
+	params, err := ParamsForHDKeyID(TestnetParams.HDPublicKeyID[:])
+	assert.NoError(t, err)
+	assert.Equal(t, TestnetParams, params)
+
+	assert.NoError(t, Register(CreateSignetParams(
+		chaincfg.DefaultSignetChallenge,
+		TestnetParams.GenesisHash,
+	)))
+	_, err = ParamsForHDKeyID(TestnetParams.HDPublicKeyID[:])
+	assert.True(t, errors.Is(err, ErrAmbiguousHDKeyID))
+
+	custom := *MainnetParams
+	custom.Name = "custom"
+	custom.Net = wire.BitcoinNet(0xa1b2c3f3)
+	custom.HDPrivateKeyID = [4]byte{0x01, 0x02, 0x03, 0x04}
+	custom.HDPublicKeyID = [4]byte{0x01, 0x02, 0x03, 0x05}
+	_, err = HDPublicKeyToPrivateKeyID(custom.HDPublicKeyID[:])
+	assert.True(t, errors.Is(err, chaincfg.ErrUnknownHDKeyID))
+	_, err = ParamsForHDKeyID(custom.HDPublicKeyID[:])
+	assert.True(t, errors.Is(err, ErrNetworkNotRegistered))
+
+	assert.NoError(t, Register(&custom))
+	privateKeyID, err = HDPublicKeyToPrivateKeyID(custom.HDPublicKeyID[:])
+	assert.NoError(t, err)
+	assert.Equal(t, custom.HDPrivateKeyID[:], privateKeyID)
+	params, err = ParamsForHDKeyID(custom.HDPrivateKeyID[:])
+	assert.NoError(t, err)
+	assert.Equal(t, &custom, params)
+
+	// Networks with different private versions for
+	// the same public version are ambiguous
+	conflicting := custom
+	conflicting.Net = wire.BitcoinNet(0xa1b2c3f4)
+	conflicting.HDPrivateKeyID = [4]byte{0x01, 0x02, 0x03, 0x06}
+	assert.NoError(t, Register(&conflicting))
+	_, err = HDPublicKeyToPrivateKeyID(custom.HDPublicKeyID[:])
+	assert.True(t, errors.Is(err, ErrAmbiguousHDKeyID))
+}
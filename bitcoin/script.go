@@ -0,0 +1,360 @@
+// Copyright 2020 Coinbase, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package bitcoin
+
+import (
+	"encoding/binary"
+	"encoding/hex"
+	"errors"
+	"strconv"
+	"strings"
+
+	"github.com/btcsuite/btcd/btcec"
+	"github.com/btcsuite/btcd/chaincfg"
+	"github.com/btcsuite/btcd/txscript"
+)
+
+// Opcodes of the chain that are undefined in bitcoin
+// (they have the same values as in PIVX).
+const (
+	// OpZerocoinMint starts the script of
+	// a zerocoin mint output.
+	OpZerocoinMint = 0xc1
+
+	// OpZerocoinSpend and OpZerocoinPublicSpend start
+	// the signature script of a zerocoin spend.
+	OpZerocoinSpend       = 0xc2
+	OpZerocoinPublicSpend = 0xc3
+
+	// OpCheckColdStakeVerifyLOF and OpCheckColdStakeVerify
+	// restrict the staker of a cold staking output to
+	// staking it (OpCheckColdStakeVerifyLOF also allows a
+	// last output paying a masternode or budget).
+	OpCheckColdStakeVerifyLOF = 0xd1
+	OpCheckColdStakeVerify    = 0xd2
+
+	// coldStakeScriptLength is the length of
+	// a cold staking script.
+	coldStakeScriptLength = 51
+)
+
+var (
+	errMalformedScript = errors.New("malformed script")
+
+	// opcodeNames are the names bitcoind uses
+	// for each (non-push) opcode.
+	opcodeNames = func() map[byte]string {
+		names := map[byte]string{}
+		for name, opcode := range txscript.OpcodeByName {
+			switch {
+			case opcode <= txscript.OP_PUSHDATA4:
+				// Pushes are printed as their data.
+				continue
+			case opcode > txscript.OP_NOP10 && opcode != txscript.OP_INVALIDOPCODE:
+				// All undefined opcodes are OP_UNKNOWN.
+				continue
+			case name == "OP_TRUE" || name == "OP_NOP2" || name == "OP_NOP3":
+				// Aliases of OP_1, OP_CHECKLOCKTIMEVERIFY,
+				// and OP_CHECKSEQUENCEVERIFY.
+				continue
+			}
+
+			names[opcode] = name
+		}
+
+		names[OpZerocoinMint] = "OP_ZEROCOINMINT"
+		names[OpZerocoinSpend] = "OP_ZEROCOINSPEND"
+		names[OpZerocoinPublicSpend] = "OP_ZEROCOINPUBLICSPEND"
+		names[OpCheckColdStakeVerifyLOF] = "OP_CHECKCOLDSTAKEVERIFY_LOF"
+		names[OpCheckColdStakeVerify] = "OP_CHECKCOLDSTAKEVERIFY"
+
+		names[txscript.OP_1NEGATE] = "-1"
+		for opcode := txscript.OP_1; opcode <= txscript.OP_16; opcode++ {
+			names[byte(opcode)] = strconv.Itoa(opcode - txscript.OP_1 + 1)
+		}
+
+		return names
+	}()
+
+	// sigHashTypes are the names bitcoind uses for
+	// each defined sighash type when decoding a
+	// signature in a scriptSig.
+	sigHashTypes = map[byte]string{
+		byte(txscript.SigHashAll):                                   "ALL",
+		byte(txscript.SigHashAll | txscript.SigHashAnyOneCanPay):    "ALL|ANYONECANPAY",
+		byte(txscript.SigHashNone):                                  "NONE",
+		byte(txscript.SigHashNone | txscript.SigHashAnyOneCanPay):   "NONE|ANYONECANPAY",
+		byte(txscript.SigHashSingle):                                "SINGLE",
+		byte(txscript.SigHashSingle | txscript.SigHashAnyOneCanPay): "SINGLE|ANYONECANPAY",
+	}
+)
+
+// scriptOp is a single opcode (and any
+// data it pushes) in a script.
+type scriptOp struct {
+	opcode byte
+	data   []byte
+}
+
+// parseScript splits a script into its opcodes. If the
+// script is malformed, the opcodes before the malformed
+// opcode are returned with errMalformedScript.
+func parseScript(script []byte) ([]*scriptOp, error) {
+	ops := []*scriptOp{}
+	for len(script) > 0 {
+		opcode := script[0]
+		script = script[1:]
+
+		size := 0
+		switch {
+		case opcode < txscript.OP_PUSHDATA1:
+			size = int(opcode)
+		case opcode == txscript.OP_PUSHDATA1:
+			if len(script) < 1 {
+				return ops, errMalformedScript
+			}
+			size = int(script[0])
+			script = script[1:]
+		case opcode == txscript.OP_PUSHDATA2:
+			if len(script) < 2 {
+				return ops, errMalformedScript
+			}
+			size = int(binary.LittleEndian.Uint16(script))
+			script = script[2:]
+		case opcode == txscript.OP_PUSHDATA4:
+			if len(script) < 4 {
+				return ops, errMalformedScript
+			}
+			size = int(binary.LittleEndian.Uint32(script))
+			script = script[4:]
+		}
+
+		if size < 0 || size > len(script) {
+			return ops, errMalformedScript
+		}
+
+		ops = append(ops, &scriptOp{opcode: opcode, data: script[:size]})
+		script = script[size:]
+	}
+
+	return ops, nil
+}
+
+// scriptNum decodes data as a little-endian
+// sign-magnitude number (at most 4 bytes).
+func scriptNum(data []byte) int64 {
+	if len(data) == 0 {
+		return 0
+	}
+
+	var result int64
+	for i, b := range data {
+		result |= int64(b) << (8 * uint(i))
+	}
+
+	if data[len(data)-1]&0x80 != 0 {
+		return -(result & ^(int64(0x80) << (8 * uint(len(data)-1))))
+	}
+
+	return result
+}
+
+// decodeSignature returns the hex of a DER signature followed
+// by its sighash type (i.e. <sig>[ALL]), if data is a strictly
+// encoded signature.
+func decodeSignature(data []byte) (string, bool) {
+	if len(data) < 2 {
+		return "", false
+	}
+
+	sigHashType, ok := sigHashTypes[data[len(data)-1]]
+	if !ok {
+		return "", false
+	}
+
+	signature := data[:len(data)-1]
+	if _, err := btcec.ParseDERSignature(signature, btcec.S256()); err != nil {
+		return "", false
+	}
+
+	return hex.EncodeToString(signature) + "[" + sigHashType + "]", true
+}
+
+// ScriptToAsm returns the human-readable representation of
+// a script (as returned by bitcoind in the `asm` field of a
+// ScriptSig or ScriptPubKey). Signatures are decoded (i.e.
+// <sig>[ALL]) when decodeSignatures is true.
+func ScriptToAsm(script []byte, decodeSignatures bool) string {
+	ops, parseErr := parseScript(script)
+	decodeSignatures = decodeSignatures &&
+		!(len(script) > 0 && script[0] == txscript.OP_RETURN)
+
+	tokens := make([]string, len(ops))
+	for i, op := range ops {
+		if op.opcode > txscript.OP_PUSHDATA4 {
+			name, ok := opcodeNames[op.opcode]
+			if !ok {
+				name = "OP_UNKNOWN"
+			}
+			tokens[i] = name
+			continue
+		}
+
+		if len(op.data) <= 4 {
+			tokens[i] = strconv.FormatInt(scriptNum(op.data), 10)
+			continue
+		}
+
+		if decodeSignatures {
+			if decoded, ok := decodeSignature(op.data); ok {
+				tokens[i] = decoded
+				continue
+			}
+		}
+
+		tokens[i] = hex.EncodeToString(op.data)
+	}
+
+	if parseErr != nil {
+		tokens = append(tokens, "[error]")
+	}
+
+	return strings.Join(tokens, " ")
+}
+
+// isNullData returns true if a script is provably
+// unspendable (OP_RETURN followed only by pushes).
+func isNullData(script []byte) bool {
+	if len(script) == 0 || script[0] != txscript.OP_RETURN {
+		return false
+	}
+
+	ops, err := parseScript(script[1:])
+	if err != nil {
+		return false
+	}
+
+	for _, op := range ops {
+		if op.opcode > txscript.OP_16 {
+			return false
+		}
+	}
+
+	return true
+}
+
+// NullDataPayload returns the data pushed after the
+// OP_RETURN of a null data script, and false if script
+// is not a null data script.
+func NullDataPayload(script []byte) ([]byte, bool) {
+	if !isNullData(script) {
+		return nil, false
+	}
+
+	// isNullData already checked
+	// that the pushes are valid.
+	ops, _ := parseScript(script[1:])
+	payload := []byte{}
+	for _, op := range ops {
+		payload = append(payload, op.data...)
+	}
+
+	return payload, true
+}
+
+// isZerocoinMint returns true if a script
+// is a zerocoin mint.
+func isZerocoinMint(script []byte) bool {
+	return len(script) > 0 && script[0] == OpZerocoinMint
+}
+
+// isColdStake returns true if a script is a cold
+// staking script (OP_DUP OP_HASH160 OP_ROT OP_IF
+// OP_CHECKCOLDSTAKEVERIFY[_LOF] <staker key hash>
+// OP_ELSE <owner key hash> OP_ENDIF OP_EQUALVERIFY
+// OP_CHECKSIG).
+func isColdStake(script []byte) bool {
+	if len(script) != coldStakeScriptLength {
+		return false
+	}
+
+	return script[0] == txscript.OP_DUP &&
+		script[1] == txscript.OP_HASH160 &&
+		script[2] == txscript.OP_ROT &&
+		script[3] == txscript.OP_IF &&
+		(script[4] == OpCheckColdStakeVerify || script[4] == OpCheckColdStakeVerifyLOF) &&
+		script[5] == txscript.OP_DATA_20 &&
+		script[26] == txscript.OP_ELSE &&
+		script[27] == txscript.OP_DATA_20 &&
+		script[48] == txscript.OP_ENDIF &&
+		script[49] == txscript.OP_EQUALVERIFY &&
+		script[50] == txscript.OP_CHECKSIG
+}
+
+// NewScriptPubKey returns the *ScriptPubKey of a script
+// (as returned by bitcoind).
+func NewScriptPubKey(script []byte, params *chaincfg.Params) *ScriptPubKey {
+	scriptPubKey := &ScriptPubKey{
+		ASM: ScriptToAsm(script, false),
+		Hex: hex.EncodeToString(script),
+	}
+
+	if isNullData(script) {
+		scriptPubKey.Type = NullData
+		return scriptPubKey
+	}
+
+	// Zerocoin mints have no addresses, so
+	// they are owned by their script hex.
+	if isZerocoinMint(script) {
+		scriptPubKey.Type = ZerocoinMint
+		return scriptPubKey
+	}
+
+	// The addresses of cold staking scripts are the staker
+	// and the owner (as returned by the node). They are only
+	// populated on networks with staking addresses.
+	if isColdStake(script) {
+		scriptPubKey.Type = ColdStake
+		staker, owner, err := ExtractColdStakeAddresses(script, params)
+		if err == nil {
+			scriptPubKey.RequiredSigs = 1
+			scriptPubKey.Addresses = []string{staker.EncodeAddress(), owner.EncodeAddress()}
+		}
+
+		return scriptPubKey
+	}
+
+	class, addresses, required, err := txscript.ExtractPkScriptAddrs(script, params)
+	if err != nil || class == txscript.NonStandardTy {
+		scriptPubKey.Type = txscript.NonStandardTy.String()
+		return scriptPubKey
+	}
+
+	scriptPubKey.Type = class.String()
+
+	// The node does not return an address for pay-to-pubkey
+	// outputs (so they are owned by their script hex).
+	if class == txscript.PubKeyTy || len(addresses) == 0 {
+		return scriptPubKey
+	}
+
+	scriptPubKey.RequiredSigs = int64(required)
+	for _, address := range addresses {
+		scriptPubKey.Addresses = append(scriptPubKey.Addresses, address.EncodeAddress())
+	}
+
+	return scriptPubKey
+}
@@ -0,0 +1,234 @@
+// Copyright 2020 Coinbase, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package bitcoin
+
+import (
+	"encoding/hex"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestScriptToAsm(t *testing.T) {
+	tests := map[string]struct {
+		script           string
+		decodeSignatures bool
+
+		asm string
+	}{
+		"signature and public key": {
+			script:           "493046022100c352d3dd993a981beba4a63ad15c209275ca9470abfcd57da93b58e4eb5dce82022100840792bc1f456062819f15d33ee7055cf7b5ee1af1ebcc6028d9cdb1c3af7748014104f46db5e9d61a9dc27b8d64ad23e7383a4e6ca164593c2527c038c0857eb67ee8e825dca65046b82c9331586c82e0fd1f633f25f87c161bc6f8a630121df2b3d3", // nolint
+			decodeSignatures: true,
+			asm:              "3046022100c352d3dd993a981beba4a63ad15c209275ca9470abfcd57da93b58e4eb5dce82022100840792bc1f456062819f15d33ee7055cf7b5ee1af1ebcc6028d9cdb1c3af7748[ALL] 04f46db5e9d61a9dc27b8d64ad23e7383a4e6ca164593c2527c038c0857eb67ee8e825dca65046b82c9331586c82e0fd1f633f25f87c161bc6f8a630121df2b3d3", // nolint
+		},
+		"signature not decoded": {
+			script: "493046022100c352d3dd993a981beba4a63ad15c209275ca9470abfcd57da93b58e4eb5dce82022100840792bc1f456062819f15d33ee7055cf7b5ee1af1ebcc6028d9cdb1c3af774801", // nolint
+			asm:    "3046022100c352d3dd993a981beba4a63ad15c209275ca9470abfcd57da93b58e4eb5dce82022100840792bc1f456062819f15d33ee7055cf7b5ee1af1ebcc6028d9cdb1c3af774801",   // nolint
+		},
+		"p2sh-wrapped witness": {
+			script:           "1600142b2296c588ec413cebd19c3cbc04ea830ead6e78",
+			decodeSignatures: true,
+			asm:              "00142b2296c588ec413cebd19c3cbc04ea830ead6e78",
+		},
+		"pay-to-pubkey-hash": {
+			script: "76a914c398efa9c392ba6013c5e04ee729755ef7f58b3288ac",
+			asm:    "OP_DUP OP_HASH160 c398efa9c392ba6013c5e04ee729755ef7f58b32 OP_EQUALVERIFY OP_CHECKSIG",
+		},
+		"null data": {
+			script:           "6a24aa21a9ed10109f4b82aa3ed7ec9d02a2a90246478b3308c8b85daf62fe501d58d05727a4",
+			decodeSignatures: true,
+			asm:              "OP_RETURN aa21a9ed10109f4b82aa3ed7ec9d02a2a90246478b3308c8b85daf62fe501d58d05727a4",
+		},
+		"small numbers": {
+			script: "004f51600101018102e803",
+			asm:    "0 -1 1 16 1 -1 1000",
+		},
+		"unknown opcode": {
+			script: "baff",
+			asm:    "OP_UNKNOWN OP_INVALIDOPCODE",
+		},
+		"chain opcodes": {
+			script: "c1c2c3d1d2",
+			asm:    "OP_ZEROCOINMINT OP_ZEROCOINSPEND OP_ZEROCOINPUBLICSPEND OP_CHECKCOLDSTAKEVERIFY_LOF OP_CHECKCOLDSTAKEVERIFY", // nolint
+		},
+		"malformed": {
+			script: "6a4c",
+			asm:    "OP_RETURN [error]",
+		},
+		"empty": {
+			script: "",
+			asm:    "",
+		},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			script, err := hex.DecodeString(test.script)
+			assert.NoError(t, err)
+			assert.Equal(t, test.asm, ScriptToAsm(script, test.decodeSignatures))
+		})
+	}
+}
+
+func TestNewScriptPubKey(t *testing.T) {
+	tests := map[string]struct {
+		script string
+
+		scriptPubKey *ScriptPubKey
+	}{
+		"pay-to-pubkey-hash": {
+			script: "76a914c398efa9c392ba6013c5e04ee729755ef7f58b3288ac",
+			scriptPubKey: &ScriptPubKey{
+				ASM:          "OP_DUP OP_HASH160 c398efa9c392ba6013c5e04ee729755ef7f58b32 OP_EQUALVERIFY OP_CHECKSIG",
+				Hex:          "76a914c398efa9c392ba6013c5e04ee729755ef7f58b3288ac",
+				RequiredSigs: 1,
+				Type:         "pubkeyhash",
+				Addresses: []string{
+					"Eaz8UBbYDTk9N9tnApaBbaWVf7FCyrB94j",
+				},
+			},
+		},
+		"pay-to-script-hash": {
+			script: "a914228f554bbf766d6f9cc828de1126e3d35d15e5fe87",
+			scriptPubKey: &ScriptPubKey{
+				ASM:          "OP_HASH160 228f554bbf766d6f9cc828de1126e3d35d15e5fe OP_EQUAL",
+				Hex:          "a914228f554bbf766d6f9cc828de1126e3d35d15e5fe87",
+				RequiredSigs: 1,
+				Type:         "scripthash",
+				Addresses: []string{
+					"7ttzRLJ38FzPoAEXy1DrFajX4fXC99Da3N",
+				},
+			},
+		},
+		"pay-to-pubkey": {
+			script: "4104f5eeb2b10c944c6b9fbcfff94c35bdeecd93df977882babc7f3a2cf7f5c81d3b09a68db7f0e04f21de5d4230e75e6dbe7ad16eefe0d4325a62067dc6f369446aac", // nolint
+			scriptPubKey: &ScriptPubKey{
+				ASM:  "04f5eeb2b10c944c6b9fbcfff94c35bdeecd93df977882babc7f3a2cf7f5c81d3b09a68db7f0e04f21de5d4230e75e6dbe7ad16eefe0d4325a62067dc6f369446a OP_CHECKSIG", // nolint
+				Hex:  "4104f5eeb2b10c944c6b9fbcfff94c35bdeecd93df977882babc7f3a2cf7f5c81d3b09a68db7f0e04f21de5d4230e75e6dbe7ad16eefe0d4325a62067dc6f369446aac",         // nolint
+				Type: "pubkey",
+			},
+		},
+		"null data": {
+			script: "6a24aa21a9ed10109f4b82aa3ed7ec9d02a2a90246478b3308c8b85daf62fe501d58d05727a4",
+			scriptPubKey: &ScriptPubKey{
+				ASM:  "OP_RETURN aa21a9ed10109f4b82aa3ed7ec9d02a2a90246478b3308c8b85daf62fe501d58d05727a4",
+				Hex:  "6a24aa21a9ed10109f4b82aa3ed7ec9d02a2a90246478b3308c8b85daf62fe501d58d05727a4",
+				Type: "nulldata",
+			},
+		},
+		"zerocoin mint": {
+			script: "c1050102030405",
+			scriptPubKey: &ScriptPubKey{
+				ASM:  "OP_ZEROCOINMINT 0102030405",
+				Hex:  "c1050102030405",
+				Type: "zerocoinmint",
+			},
+		},
+		"cold stake": {
+			script: "76a97b63d214c398efa9c392ba6013c5e04ee729755ef7f58b326714228f554bbf766d6f9cc828de1126e3d35d15e5fe6888ac",
+			scriptPubKey: &ScriptPubKey{
+				ASM:          "OP_DUP OP_HASH160 OP_ROT OP_IF OP_CHECKCOLDSTAKEVERIFY c398efa9c392ba6013c5e04ee729755ef7f58b32 OP_ELSE 228f554bbf766d6f9cc828de1126e3d35d15e5fe OP_ENDIF OP_EQUALVERIFY OP_CHECKSIG", // nolint
+				Hex:          "76a97b63d214c398efa9c392ba6013c5e04ee729755ef7f58b326714228f554bbf766d6f9cc828de1126e3d35d15e5fe6888ac",
+				RequiredSigs: 1,
+				Type:         "coldstake",
+				Addresses: []string{
+					"Sf8E1SYBWseRuA5NuQZk9Lg6YEyWHh7H6Y",
+					"ELJeB54eV9QQt6TvMiYx1b678jeHVeAtKr",
+				},
+			},
+		},
+		"cold stake last output free": {
+			script: "76a97b63d114c398efa9c392ba6013c5e04ee729755ef7f58b326714228f554bbf766d6f9cc828de1126e3d35d15e5fe6888ac",
+			scriptPubKey: &ScriptPubKey{
+				ASM:          "OP_DUP OP_HASH160 OP_ROT OP_IF OP_CHECKCOLDSTAKEVERIFY_LOF c398efa9c392ba6013c5e04ee729755ef7f58b32 OP_ELSE 228f554bbf766d6f9cc828de1126e3d35d15e5fe OP_ENDIF OP_EQUALVERIFY OP_CHECKSIG", // nolint
+				Hex:          "76a97b63d114c398efa9c392ba6013c5e04ee729755ef7f58b326714228f554bbf766d6f9cc828de1126e3d35d15e5fe6888ac",
+				RequiredSigs: 1,
+				Type:         "coldstake",
+				Addresses: []string{
+					"Sf8E1SYBWseRuA5NuQZk9Lg6YEyWHh7H6Y",
+					"ELJeB54eV9QQt6TvMiYx1b678jeHVeAtKr",
+				},
+			},
+		},
+		"truncated cold stake": {
+			script: "76a97b63d214c398efa9c392ba6013c5e04ee729755ef7f58b326714228f554bbf766d6f9cc828de1126e3d35d15e5fe6888",
+			scriptPubKey: &ScriptPubKey{
+				ASM:  "OP_DUP OP_HASH160 OP_ROT OP_IF OP_CHECKCOLDSTAKEVERIFY c398efa9c392ba6013c5e04ee729755ef7f58b32 OP_ELSE 228f554bbf766d6f9cc828de1126e3d35d15e5fe OP_ENDIF OP_EQUALVERIFY", // nolint
+				Hex:  "76a97b63d214c398efa9c392ba6013c5e04ee729755ef7f58b326714228f554bbf766d6f9cc828de1126e3d35d15e5fe6888",
+				Type: "nonstandard",
+			},
+		},
+		"nonstandard": {
+			script: "",
+			scriptPubKey: &ScriptPubKey{
+				ASM:  "",
+				Hex:  "",
+				Type: "nonstandard",
+			},
+		},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			script, err := hex.DecodeString(test.script)
+			assert.NoError(t, err)
+			assert.Equal(t, test.scriptPubKey, NewScriptPubKey(script, MainnetParams))
+		})
+	}
+}
+
+func TestNullDataPayload(t *testing.T) {
+	tests := map[string]struct {
+		script string
+
+		payload string
+		ok      bool
+	}{
+		"single push": {
+			script:  "6a0568656c6c6f",
+			payload: "68656c6c6f",
+			ok:      true,
+		},
+		"multiple pushes": {
+			script:  "6a02686903212121",
+			payload: "6869212121",
+			ok:      true,
+		},
+		"bare OP_RETURN": {
+			script:  "6a",
+			payload: "",
+			ok:      true,
+		},
+		"not null data": {
+			script: "76a91445db0b779c0b9fa207f12a8218c94fc77aff504588ac",
+		},
+		"truncated push": {
+			script: "6a0568656c",
+		},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			script, err := hex.DecodeString(test.script)
+			assert.NoError(t, err)
+
+			payload, ok := NullDataPayload(script)
+			assert.Equal(t, test.ok, ok)
+			if test.ok {
+				assert.Equal(t, test.payload, hex.EncodeToString(payload))
+			}
+		})
+	}
+}
@@ -0,0 +1,187 @@
+// Copyright 2020 Coinbase, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package bitcoin
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"sort"
+	"time"
+
+	"github.com/MNtank/rosetta-bitcoin/utils"
+
+	"github.com/btcsuite/btcd/chaincfg"
+	"github.com/btcsuite/btcd/wire"
+	"github.com/coinbase/rosetta-sdk-go/types"
+	sdkUtils "github.com/coinbase/rosetta-sdk-go/utils"
+)
+
+const (
+	// PeerCheckInterval is how often MonitorPeers
+	// compares the peers of the node with the
+	// seeded nodes.
+	PeerCheckInterval = 10 * time.Minute
+
+	// seederLogger is the logger of the seeder.
+	seederLogger = "seeder"
+)
+
+// ErrNoSeededNodes is returned when none of
+// the DNS seeds of a network can be resolved.
+var ErrNoSeededNodes = errors.New("no seeded nodes")
+
+// Seeder resolves the DNS seeds of a network
+// to the addresses of candidate peers.
+type Seeder struct {
+	params *chaincfg.Params
+
+	// lookupHost resolves a host to
+	// its addresses (overridden in tests).
+	lookupHost func(ctx context.Context, host string) ([]string, error)
+}
+
+// NewSeeder returns a *Seeder of the
+// network with params.
+func NewSeeder(params *chaincfg.Params) *Seeder {
+	return &Seeder{
+		params:     params,
+		lookupHost: net.DefaultResolver.LookupHost,
+	}
+}
+
+// seedHost returns the host queried on seed for nodes
+// with services. Seeds with filtering return only
+// nodes with the services encoded in the subdomain.
+func seedHost(seed chaincfg.DNSSeed, services wire.ServiceFlag) string {
+	if !seed.HasFiltering || services == wire.SFNodeNetwork {
+		return seed.Host
+	}
+
+	return fmt.Sprintf("x%x.%s", uint64(services), seed.Host)
+}
+
+// Seed returns the (sorted, deduplicated) addresses
+// ("host:port") of the nodes with services returned by
+// the DNS seeds of the network. Seeds that can't be
+// resolved are skipped unless none can be.
+func (s *Seeder) Seed(ctx context.Context, services wire.ServiceFlag) ([]string, error) {
+	logger := utils.ExtractLogger(ctx, seederLogger)
+
+	seen := map[string]struct{}{}
+	var lastErr error
+	for _, seed := range s.params.DNSSeeds {
+		host := seedHost(seed, services)
+		hosts, err := s.lookupHost(ctx, host)
+		if err != nil {
+			logger.Warnw("unable to resolve DNS seed", "seed", host, "error", err)
+			lastErr = err
+			continue
+		}
+
+		for _, h := range hosts {
+			seen[net.JoinHostPort(h, s.params.DefaultPort)] = struct{}{}
+		}
+	}
+
+	if len(seen) == 0 && lastErr != nil {
+		return nil, fmt.Errorf("%w: %s", ErrNoSeededNodes, lastErr.Error())
+	}
+
+	addresses := make([]string, 0, len(seen))
+	for address := range seen {
+		addresses = append(addresses, address)
+	}
+	sort.Strings(addresses)
+
+	return addresses, nil
+}
+
+// SeededPeers returns the IDs of peers (whose IDs are
+// "host:port" addresses, as returned by GetPeers) that
+// are seeded nodes. Peers are matched by host, as
+// inbound peers connect from arbitrary ports.
+func SeededPeers(peers []*types.Peer, seeded []string) []string {
+	seededHosts := map[string]struct{}{}
+	for _, address := range seeded {
+		host, _, err := net.SplitHostPort(address)
+		if err != nil {
+			continue
+		}
+
+		seededHosts[host] = struct{}{}
+	}
+
+	overlap := []string{}
+	for _, peer := range peers {
+		host, _, err := net.SplitHostPort(peer.PeerID)
+		if err != nil {
+			continue
+		}
+
+		if _, ok := seededHosts[host]; ok {
+			overlap = append(overlap, peer.PeerID)
+		}
+	}
+
+	return overlap
+}
+
+// MonitorPeers checks that the peers of the node (as
+// returned by client) include seeded nodes every
+// interval, warning when they don't (the node may be
+// isolated on a fork or eclipsed by its peers).
+func (s *Seeder) MonitorPeers(ctx context.Context, client *Client, interval time.Duration) error {
+	logger := utils.ExtractLogger(ctx, seederLogger)
+	for ctx.Err() == nil {
+		if err := sdkUtils.ContextSleep(ctx, interval); err != nil {
+			return err
+		}
+
+		// Failing to check the peers (i.e. because the
+		// node is starting) should never halt the server.
+		seeded, err := s.Seed(ctx, wire.SFNodeNetwork)
+		if err != nil {
+			logger.Warnw("unable to seed nodes", "error", err)
+			continue
+		}
+
+		peers, err := client.GetPeers(ctx)
+		if err != nil {
+			logger.Warnw("unable to get peers", "error", err)
+			continue
+		}
+
+		overlap := SeededPeers(peers, seeded)
+		if len(seeded) > 0 && len(overlap) == 0 {
+			logger.Warnw(
+				"node has no seeded peers",
+				"peers", len(peers),
+				"seeded nodes", len(seeded),
+			)
+			continue
+		}
+
+		logger.Infow(
+			"checked peers",
+			"peers", len(peers),
+			"seeded peers", len(overlap),
+			"seeded nodes", len(seeded),
+		)
+	}
+
+	return ctx.Err()
+}
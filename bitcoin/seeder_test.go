@@ -0,0 +1,89 @@
+// Copyright 2020 Coinbase, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package bitcoin
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/btcsuite/btcd/chaincfg"
+	"github.com/btcsuite/btcd/wire"
+	"github.com/coinbase/rosetta-sdk-go/types"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSeeder(t *testing.T) {
+	params := *MainnetParams
+	params.DefaultPort = "46462"
+	params.DNSSeeds = []chaincfg.DNSSeed{
+		{Host: "seed1.example.com", HasFiltering: true},
+		{Host: "seed2.example.com"},
+		{Host: "broken.example.com"},
+	}
+
+	seeder := NewSeeder(&params)
+	lookups := []string{}
+	seeder.lookupHost = func(ctx context.Context, host string) ([]string, error) {
+		lookups = append(lookups, host)
+		switch host {
+		case "seed1.example.com", "x9.seed1.example.com":
+			return []string{"10.0.0.2", "10.0.0.1"}, nil
+		case "seed2.example.com":
+			return []string{"10.0.0.1", "::1"}, nil
+		default:
+			return nil, errors.New("no such host")
+		}
+	}
+
+	addresses, err := seeder.Seed(context.Background(), wire.SFNodeNetwork)
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"10.0.0.1:46462", "10.0.0.2:46462", "[::1]:46462"}, addresses)
+	assert.Equal(t, []string{"seed1.example.com", "seed2.example.com", "broken.example.com"}, lookups)
+
+	// Only seeds with filtering are queried
+	// for the requested services.
+	lookups = []string{}
+	_, err = seeder.Seed(context.Background(), wire.SFNodeNetwork|wire.SFNodeWitness)
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"x9.seed1.example.com", "seed2.example.com", "broken.example.com"}, lookups)
+
+	params.DNSSeeds = params.DNSSeeds[2:]
+	addresses, err = seeder.Seed(context.Background(), wire.SFNodeNetwork)
+	assert.True(t, errors.Is(err, ErrNoSeededNodes))
+	assert.Nil(t, addresses)
+
+	params.DNSSeeds = nil
+	addresses, err = seeder.Seed(context.Background(), wire.SFNodeNetwork)
+	assert.NoError(t, err)
+	assert.Empty(t, addresses)
+}
+
+func TestSeededPeers(t *testing.T) {
+	peers := []*types.Peer{
+		{PeerID: "10.0.0.1:46462"},
+		{PeerID: "10.0.0.3:51234"},
+		{PeerID: "[::1]:60000"},
+		{PeerID: "invalid"},
+	}
+
+	assert.Equal(
+		t,
+		[]string{"10.0.0.1:46462", "[::1]:60000"},
+		SeededPeers(peers, []string{"10.0.0.1:46462", "10.0.0.2:46462", "[::1]:46462"}),
+	)
+	assert.Empty(t, SeededPeers(peers, []string{"10.0.0.2:46462"}))
+	assert.Empty(t, SeededPeers(nil, []string{"10.0.0.1:46462"}))
+}
@@ -0,0 +1,160 @@
+// Copyright 2020 Coinbase, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package bitcoin
+
+import (
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/btcsuite/btcd/btcec"
+	"github.com/btcsuite/btcd/chaincfg"
+)
+
+var (
+	// ErrSporkKeyMissing is returned when no spork key
+	// is registered for a network.
+	ErrSporkKeyMissing = errors.New("spork key missing")
+
+	// ErrInvalidSporkSignature is returned when a spork
+	// signature was not made with an accepted spork key.
+	ErrInvalidSporkSignature = errors.New("invalid spork signature")
+)
+
+// SporkKeys are the keys spork messages of a network
+// are signed with. The network moved from the old spork
+// key to the spork key: sporks signed at or after
+// EnforceNewSporkKey (Time_EnforceNewSporkKey) must be
+// signed with the spork key, and sporks signed with the
+// old spork key are rejected from RejectOldSporkKey
+// (Time_RejectOldSporkKey). The old spork key is never
+// accepted unless both times are set.
+type SporkKeys struct {
+	// PubKey and PubKeyOld are hex-encoded
+	// serialized public keys.
+	PubKey    string
+	PubKeyOld string
+
+	EnforceNewSporkKey time.Time
+	RejectOldSporkKey  time.Time
+}
+
+// ValidateSporkKeys returns an error if the spork
+// key is missing or any spork key can't be parsed.
+func ValidateSporkKeys(keys *SporkKeys) error {
+	if len(keys.PubKey) == 0 {
+		return ErrSporkKeyMissing
+	}
+
+	for _, key := range []string{keys.PubKey, keys.PubKeyOld} {
+		if len(key) == 0 {
+			continue
+		}
+
+		if _, err := parseSporkKey(key); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// parseSporkKey parses a hex-encoded public key.
+func parseSporkKey(key string) (*btcec.PublicKey, error) {
+	serialized, err := hex.DecodeString(key)
+	if err != nil {
+		return nil, fmt.Errorf("%w: unable to decode spork key %s", err, key)
+	}
+
+	pubKey, err := btcec.ParsePubKey(serialized, btcec.S256())
+	if err != nil {
+		return nil, fmt.Errorf("%w: unable to parse spork key %s", err, key)
+	}
+
+	return pubKey, nil
+}
+
+// SporkPubKey returns the public keys a spork message
+// signed at time at may be signed with on the network
+// with params: the spork key and, before both
+// EnforceNewSporkKey and RejectOldSporkKey, the old
+// spork key (if any). ErrSporkKeyMissing is returned
+// if the network has no spork key.
+func SporkPubKey(params *chaincfg.Params, at time.Time) ([]*btcec.PublicKey, error) {
+	if params == nil {
+		return nil, ErrSporkKeyMissing
+	}
+
+	keys := chainSettings(params).SporkKeys
+	if keys == nil || len(keys.PubKey) == 0 {
+		return nil, fmt.Errorf("%w: %s", ErrSporkKeyMissing, params.Name)
+	}
+
+	pubKey, err := parseSporkKey(keys.PubKey)
+	if err != nil {
+		return nil, err
+	}
+
+	pubKeys := []*btcec.PublicKey{pubKey}
+	if len(keys.PubKeyOld) == 0 ||
+		!at.Before(keys.EnforceNewSporkKey) ||
+		!at.Before(keys.RejectOldSporkKey) {
+		return pubKeys, nil
+	}
+
+	oldPubKey, err := parseSporkKey(keys.PubKeyOld)
+	if err != nil {
+		return nil, err
+	}
+
+	return append(pubKeys, oldPubKey), nil
+}
+
+// VerifySporkSignature verifies the compact signature of a
+// spork message (signed at signedAt) over hash (the signature
+// hash of the message) was made with one of the spork keys
+// the network with params accepts (see SporkPubKey). Sporks
+// signed with the old spork key are rejected once now is
+// at or after RejectOldSporkKey.
+func VerifySporkSignature(
+	params *chaincfg.Params,
+	hash []byte,
+	signature []byte,
+	signedAt time.Time,
+	now time.Time,
+) error {
+	pubKeys, err := SporkPubKey(params, signedAt)
+	if err != nil {
+		return err
+	}
+
+	if len(pubKeys) > 1 && !now.Before(chainSettings(params).SporkKeys.RejectOldSporkKey) {
+		pubKeys = pubKeys[:1]
+	}
+
+	signer, _, err := btcec.RecoverCompact(btcec.S256(), signature, hash)
+	if err != nil {
+		return fmt.Errorf("%w: %s", ErrInvalidSporkSignature, err.Error())
+	}
+
+	for _, pubKey := range pubKeys {
+		if signer.IsEqual(pubKey) {
+			return nil
+		}
+	}
+
+	return fmt.Errorf("%w: not signed with an accepted spork key", ErrInvalidSporkSignature)
+}
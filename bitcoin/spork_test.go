@@ -0,0 +1,155 @@
+// Copyright 2020 Coinbase, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package bitcoin
+
+import (
+	"encoding/hex"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/btcsuite/btcd/btcec"
+	"github.com/btcsuite/btcd/chaincfg/chainhash"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSporkPubKey(t *testing.T) {
+	defer ResetRegistry()
+
+	sporkKey, err := btcec.NewPrivateKey(btcec.S256())
+	assert.NoError(t, err)
+	oldSporkKey, err := btcec.NewPrivateKey(btcec.S256())
+	assert.NoError(t, err)
+	otherKey, err := btcec.NewPrivateKey(btcec.S256())
+	assert.NoError(t, err)
+
+	enforceNewSporkKey := time.Unix(1600000000, 0)
+	rejectOldSporkKey := time.Unix(1610000000, 0)
+	before := enforceNewSporkKey.Add(-time.Hour)
+	between := enforceNewSporkKey.Add(time.Hour)
+	after := rejectOldSporkKey.Add(time.Hour)
+
+	// Networks without a spork key
+	// can't verify sporks.
+	_, err = SporkPubKey(MainnetParams, before)
+	assert.True(t, errors.Is(err, ErrSporkKeyMissing))
+	_, err = SporkPubKey(nil, before)
+	assert.True(t, errors.Is(err, ErrSporkKeyMissing))
+
+	settings := *MainnetChainParams
+	settings.SporkKeys = &SporkKeys{
+		PubKey:             "0201",
+		EnforceNewSporkKey: enforceNewSporkKey,
+		RejectOldSporkKey:  rejectOldSporkKey,
+	}
+	assert.Error(t, RegisterChainParams(&settings))
+
+	settings.SporkKeys.PubKey = hex.EncodeToString(sporkKey.PubKey().SerializeCompressed())
+	settings.SporkKeys.PubKeyOld = hex.EncodeToString(oldSporkKey.PubKey().SerializeCompressed())
+	assert.NoError(t, RegisterChainParams(&settings))
+
+	// The old spork key is accepted for sporks
+	// signed before EnforceNewSporkKey.
+	keys, err := SporkPubKey(MainnetParams, before)
+	assert.NoError(t, err)
+	assert.Len(t, keys, 2)
+	assert.True(t, keys[0].IsEqual(sporkKey.PubKey()))
+	assert.True(t, keys[1].IsEqual(oldSporkKey.PubKey()))
+
+	keys, err = SporkPubKey(MainnetParams, between)
+	assert.NoError(t, err)
+	assert.Len(t, keys, 1)
+	assert.True(t, keys[0].IsEqual(sporkKey.PubKey()))
+
+	hash := chainhash.DoubleHashB([]byte("spork"))
+	sign := func(key *btcec.PrivateKey) []byte {
+		signature, err := btcec.SignCompact(btcec.S256(), key, hash, true)
+		assert.NoError(t, err)
+
+		return signature
+	}
+
+	tests := map[string]struct {
+		key      *btcec.PrivateKey
+		signedAt time.Time
+		now      time.Time
+
+		valid bool
+	}{
+		"spork key": {
+			key:      sporkKey,
+			signedAt: after,
+			now:      after,
+			valid:    true,
+		},
+		"old spork key before enforcement": {
+			key:      oldSporkKey,
+			signedAt: before,
+			now:      between,
+			valid:    true,
+		},
+		"old spork key after enforcement": {
+			key:      oldSporkKey,
+			signedAt: between,
+			now:      between,
+		},
+		"old spork key after rejection": {
+			key:      oldSporkKey,
+			signedAt: before,
+			now:      after,
+		},
+		"other key": {
+			key:      otherKey,
+			signedAt: before,
+			now:      before,
+		},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			err := VerifySporkSignature(
+				MainnetParams,
+				hash,
+				sign(test.key),
+				test.signedAt,
+				test.now,
+			)
+			if test.valid {
+				assert.NoError(t, err)
+			} else {
+				assert.True(t, errors.Is(err, ErrInvalidSporkSignature))
+			}
+		})
+	}
+
+	err = VerifySporkSignature(MainnetParams, hash, []byte{0x01}, before, before)
+	assert.True(t, errors.Is(err, ErrInvalidSporkSignature))
+
+	// Spork keys are kept in params files.
+	file := NewParamsFile(MainnetParams)
+	assert.Equal(t, settings.SporkKeys.PubKey, file.SporkKey)
+	assert.Equal(t, enforceNewSporkKey.Unix(), *file.EnforceNewSporkKey)
+	assert.Equal(t, rejectOldSporkKey.Unix(), *file.RejectOldSporkKey)
+
+	file.Name = "sibling"
+	file.MessageStart = "a1b2c3d5"
+	sibling, err := CreateChainParams(file)
+	assert.NoError(t, err)
+	assert.Equal(t, settings.SporkKeys, sibling.SporkKeys)
+
+	file.SporkKey = ""
+	_, err = CreateChainParams(file)
+	assert.True(t, errors.Is(err, ErrSporkKeyMissing))
+}
@@ -0,0 +1,214 @@
+// Copyright 2020 Coinbase, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package bitcoin
+
+import (
+	"encoding/hex"
+	"errors"
+	"fmt"
+
+	"github.com/btcsuite/btcd/chaincfg"
+	"github.com/btcsuite/btcutil"
+	"github.com/btcsuite/btcutil/base58"
+	"github.com/coinbase/rosetta-sdk-go/types"
+)
+
+const (
+	// MainnetStakingAddrID and TestnetStakingAddrID are
+	// the version bytes of staking addresses (the staker
+	// of cold staking scripts) on mainnet and testnet.
+	MainnetStakingAddrID = byte(0x3f) // nolint:gomnd
+	TestnetStakingAddrID = byte(0x49) // nolint:gomnd
+
+	// DelegatedSubAccount is the sub-account of the owner
+	// of a cold staking script that holds the coins it has
+	// delegated (which are spent with a different script
+	// than its other coins).
+	DelegatedSubAccount = "delegated"
+
+	// keyHashSize is the size of the key
+	// hashes of a cold staking script.
+	keyHashSize = 20
+
+	// stakerKeyHashOffset and ownerKeyHashOffset are the
+	// offsets of the key hashes in a cold staking script.
+	stakerKeyHashOffset = 6
+	ownerKeyHashOffset  = 28
+)
+
+var (
+	// ErrNoStakingAddrID is returned when no staking
+	// address version is registered for a network.
+	ErrNoStakingAddrID = errors.New("network has no staking addresses")
+
+	// ErrInvalidStakingAddress is returned when an
+	// address is not a staking address of a network.
+	ErrInvalidStakingAddress = errors.New("invalid staking address")
+
+	// mainnetStakingAddrID and testnetStakingAddrID are
+	// addressable copies of the staking address versions
+	// (for MainnetChainParams and TestnetChainParams).
+	mainnetStakingAddrID = MainnetStakingAddrID
+	testnetStakingAddrID = TestnetStakingAddrID
+)
+
+// IsStakingAddrID returns true if id is the version of
+// the staking addresses of the network with params.
+func IsStakingAddrID(params *chaincfg.Params, id byte) bool {
+	stakingID := chainSettings(params).StakingAddrID
+	return stakingID != nil && *stakingID == id
+}
+
+// StakingAddress is the address of the staker of cold
+// staking scripts (the key allowed to stake the coins
+// but not to spend them). It implements btcutil.Address.
+type StakingAddress struct {
+	hash  [keyHashSize]byte
+	netID byte
+}
+
+var _ btcutil.Address = (*StakingAddress)(nil)
+
+// NewStakingAddress returns the *StakingAddress of
+// the key with hash on the network with params.
+func NewStakingAddress(hash []byte, params *chaincfg.Params) (*StakingAddress, error) {
+	netID := chainSettings(params).StakingAddrID
+	if netID == nil {
+		return nil, fmt.Errorf("%w: %s", ErrNoStakingAddrID, params.Name)
+	}
+
+	if len(hash) != keyHashSize {
+		return nil, fmt.Errorf(
+			"%w: key hash has invalid length %d",
+			ErrInvalidStakingAddress,
+			len(hash),
+		)
+	}
+
+	address := &StakingAddress{netID: *netID}
+	copy(address.hash[:], hash)
+	return address, nil
+}
+
+// DecodeStakingAddress decodes a staking
+// address of the network with params.
+func DecodeStakingAddress(address string, params *chaincfg.Params) (*StakingAddress, error) {
+	hash, version, err := base58.CheckDecode(address)
+	if err != nil {
+		return nil, fmt.Errorf("%w: unable to decode address %s", err, address)
+	}
+
+	if !IsStakingAddrID(params, version) {
+		return nil, fmt.Errorf("%w: %s", ErrInvalidStakingAddress, address)
+	}
+
+	return NewStakingAddress(hash, params)
+}
+
+// EncodeAddress returns the base58 encoding of a.
+func (a *StakingAddress) EncodeAddress() string {
+	return base58.CheckEncode(a.hash[:], a.netID)
+}
+
+// String returns the base58 encoding of a.
+func (a *StakingAddress) String() string {
+	return a.EncodeAddress()
+}
+
+// ScriptAddress returns the key hash of a.
+func (a *StakingAddress) ScriptAddress() []byte {
+	return a.hash[:]
+}
+
+// IsForNet returns true if a is a staking address
+// of the network with params.
+func (a *StakingAddress) IsForNet(params *chaincfg.Params) bool {
+	return IsStakingAddrID(params, a.netID)
+}
+
+// ExtractColdStakeAddresses returns the staker and the
+// owner (a P2PKH address) of a cold staking script on
+// the network with params.
+func ExtractColdStakeAddresses(
+	script []byte,
+	params *chaincfg.Params,
+) (*StakingAddress, btcutil.Address, error) {
+	if !isColdStake(script) {
+		return nil, nil, fmt.Errorf("%x is not a cold staking script", script)
+	}
+
+	staker, err := NewStakingAddress(
+		script[stakerKeyHashOffset:stakerKeyHashOffset+keyHashSize],
+		params,
+	)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	owner, err := btcutil.NewAddressPubKeyHash(
+		script[ownerKeyHashOffset:ownerKeyHashOffset+keyHashSize],
+		params,
+	)
+	if err != nil {
+		return nil, nil, fmt.Errorf("%w: unable to encode owner", err)
+	}
+
+	return staker, owner, nil
+}
+
+// ColdStakeParties returns the staker and the owner of the
+// coins locked by scriptPubKey (as returned by the node) and
+// false if it is not a cold staking script.
+func ColdStakeParties(scriptPubKey *ScriptPubKey) (string, string, bool) {
+	if scriptPubKey == nil ||
+		scriptPubKey.Type != ColdStake ||
+		len(scriptPubKey.Addresses) != 2 { // nolint:gomnd
+		return "", "", false
+	}
+
+	return scriptPubKey.Addresses[0], scriptPubKey.Addresses[1], true
+}
+
+// ColdStakeAccount returns the account that owns the coins
+// delegated by a cold staking script (the DelegatedSubAccount
+// of its owner).
+func ColdStakeAccount(owner string) *types.AccountIdentifier {
+	return &types.AccountIdentifier{
+		Address:    owner,
+		SubAccount: &types.SubAccountIdentifier{Address: DelegatedSubAccount},
+	}
+}
+
+// ResolveColdStakeAccount returns the ColdStakeAccount of the
+// cold staking script whose hex is address (the identifier of
+// accounts indexed before delegated coins were attributed to
+// their owner), and false if address is not a cold staking
+// script.
+func ResolveColdStakeAccount(
+	address string,
+	params *chaincfg.Params,
+) (*types.AccountIdentifier, bool) {
+	script, err := hex.DecodeString(address)
+	if err != nil || !isColdStake(script) {
+		return nil, false
+	}
+
+	_, owner, err := ExtractColdStakeAddresses(script, params)
+	if err != nil {
+		return nil, false
+	}
+
+	return ColdStakeAccount(owner.EncodeAddress()), true
+}
@@ -0,0 +1,107 @@
+// Copyright 2020 Coinbase, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package bitcoin
+
+import (
+	"encoding/hex"
+	"errors"
+	"testing"
+
+	"github.com/btcsuite/btcd/wire"
+	"github.com/coinbase/rosetta-sdk-go/types"
+	"github.com/stretchr/testify/assert"
+)
+
+const coldStakeScript = "76a97b63d214c398efa9c392ba6013c5e04ee729755ef7f58b326714228f554bbf766d6f9cc828de1126e3d35d15e5fe6888ac" // nolint
+
+func TestStakingAddress(t *testing.T) {
+	hash, err := hex.DecodeString("c398efa9c392ba6013c5e04ee729755ef7f58b32")
+	assert.NoError(t, err)
+
+	assert.True(t, IsStakingAddrID(MainnetParams, MainnetStakingAddrID))
+	assert.False(t, IsStakingAddrID(MainnetParams, TestnetStakingAddrID))
+	assert.True(t, IsStakingAddrID(TestnetParams, TestnetStakingAddrID))
+
+	address, err := NewStakingAddress(hash, MainnetParams)
+	assert.NoError(t, err)
+	assert.Equal(t, "Sf8E1SYBWseRuA5NuQZk9Lg6YEyWHh7H6Y", address.EncodeAddress())
+	assert.Equal(t, hash, address.ScriptAddress())
+	assert.True(t, address.IsForNet(MainnetParams))
+	assert.False(t, address.IsForNet(TestnetParams))
+
+	decoded, err := DecodeStakingAddress(address.EncodeAddress(), MainnetParams)
+	assert.NoError(t, err)
+	assert.Equal(t, address, decoded)
+
+	// Staking addresses of other networks (and
+	// other addresses) are rejected
+	_, err = DecodeStakingAddress(address.EncodeAddress(), TestnetParams)
+	assert.True(t, errors.Is(err, ErrInvalidStakingAddress))
+	_, err = DecodeStakingAddress("ELJeB54eV9QQt6TvMiYx1b678jeHVeAtKr", MainnetParams)
+	assert.True(t, errors.Is(err, ErrInvalidStakingAddress))
+	_, err = NewStakingAddress(hash[1:], MainnetParams)
+	assert.True(t, errors.Is(err, ErrInvalidStakingAddress))
+
+	// Networks without a registered version
+	// have no staking addresses
+	net := wire.BitcoinNet(0xa1b2c3f5)
+	params := *MainnetParams
+	params.Net = net
+	_, err = NewStakingAddress(hash, &params)
+	assert.True(t, errors.Is(err, ErrNoStakingAddrID))
+
+	id := byte(0x50)
+	assert.NoError(t, RegisterChainParams(&ChainParams{Params: &params, StakingAddrID: &id}))
+	defer ResetRegistry()
+	address, err = NewStakingAddress(hash, &params)
+	assert.NoError(t, err)
+	assert.True(t, IsStakingAddrID(&params, 0x50))
+	assert.True(t, address.IsForNet(&params))
+}
+
+func TestColdStakeAccount(t *testing.T) {
+	script, err := hex.DecodeString(coldStakeScript)
+	assert.NoError(t, err)
+
+	staker, owner, err := ExtractColdStakeAddresses(script, MainnetParams)
+	assert.NoError(t, err)
+	assert.Equal(t, "Sf8E1SYBWseRuA5NuQZk9Lg6YEyWHh7H6Y", staker.EncodeAddress())
+	assert.Equal(t, "ELJeB54eV9QQt6TvMiYx1b678jeHVeAtKr", owner.EncodeAddress())
+
+	_, _, err = ExtractColdStakeAddresses(script[1:], MainnetParams)
+	assert.Error(t, err)
+
+	account, ok := ResolveColdStakeAccount(coldStakeScript, MainnetParams)
+	assert.True(t, ok)
+	assert.Equal(t, &types.AccountIdentifier{
+		Address:    "ELJeB54eV9QQt6TvMiYx1b678jeHVeAtKr",
+		SubAccount: &types.SubAccountIdentifier{Address: DelegatedSubAccount},
+	}, account)
+
+	// Outputs to cold staking scripts are owned by the
+	// delegated sub-account of their owner
+	client := &Client{}
+	assert.Equal(t, account, client.parseOutputAccount(&Output{
+		ScriptPubKey: NewScriptPubKey(script, MainnetParams),
+	}))
+	assert.Equal(t, &types.AccountIdentifier{Address: coldStakeScript}, client.parseOutputAccount(
+		&Output{ScriptPubKey: &ScriptPubKey{Hex: coldStakeScript, Type: ColdStake}},
+	))
+
+	for _, address := range []string{"ELJeB54eV9QQt6TvMiYx1b678jeHVeAtKr", "76a9", ""} {
+		_, ok = ResolveColdStakeAccount(address, MainnetParams)
+		assert.False(t, ok)
+	}
+}
@@ -0,0 +1,181 @@
+// Copyright 2020 Coinbase, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package bitcoin
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/btcsuite/btcd/chaincfg"
+)
+
+const (
+	// baseSubsidy is the subsidy of the first blocks of
+	// networks without a subsidy schedule but with a
+	// halving interval (which halve it every
+	// SubsidyReductionInterval blocks).
+	baseSubsidy = 50 * SatoshisInBitcoin
+
+	// maxSubsidyHalvings is the number of halvings
+	// after which the subsidy is always 0.
+	maxSubsidyHalvings = 64
+)
+
+var (
+	// ErrInvalidSubsidySchedule is returned when a
+	// subsidy schedule is not valid.
+	ErrInvalidSubsidySchedule = errors.New("invalid subsidy schedule")
+
+	// ErrUnknownSubsidy is returned when the subsidy of
+	// a network is not known (it has neither a subsidy
+	// schedule nor a halving interval).
+	ErrUnknownSubsidy = errors.New("unknown subsidy")
+)
+
+// SubsidyPhase is a range of blocks (from Height until the
+// Height of the next phase) with the same subsidy.
+type SubsidyPhase struct {
+	// Height is the first block
+	// of the phase.
+	Height int32 `json:"height"`
+
+	// ProofOfStake is true if blocks of the phase are
+	// staked (the subsidy is paid in the coinstake)
+	// instead of mined (paid in the coinbase).
+	ProofOfStake bool `json:"proof_of_stake"`
+
+	// Subsidy is the total subsidy (in satoshis)
+	// of each block of the phase.
+	Subsidy int64 `json:"subsidy"`
+
+	// MasternodeSubsidy is the part of Subsidy
+	// paid to the masternode of each block.
+	MasternodeSubsidy int64 `json:"masternode_subsidy"`
+}
+
+// Subsidy is the subsidy of a block.
+type Subsidy struct {
+	ProofOfStake bool
+
+	// Total is the subsidy (in satoshis) created
+	// by the block (excluding fees).
+	Total int64
+
+	// Producer and Masternode are the parts of Total
+	// paid to the miner (or staker) of the block and
+	// to its masternode.
+	Producer   int64
+	Masternode int64
+}
+
+// ValidateSubsidySchedule returns an error if
+// phases is not a valid subsidy schedule.
+func ValidateSubsidySchedule(phases []*SubsidyPhase) error {
+	if len(phases) == 0 {
+		return fmt.Errorf("%w: no phases provided", ErrInvalidSubsidySchedule)
+	}
+
+	for i, phase := range phases {
+		switch {
+		case phase == nil:
+			return fmt.Errorf("%w: phase %d is empty", ErrInvalidSubsidySchedule, i)
+		case i == 0 && phase.Height != 0:
+			return fmt.Errorf(
+				"%w: first phase starts at %d (not genesis)",
+				ErrInvalidSubsidySchedule,
+				phase.Height,
+			)
+		case i > 0 && phase.Height <= phases[i-1].Height:
+			return fmt.Errorf(
+				"%w: phase %d does not start after phase %d",
+				ErrInvalidSubsidySchedule,
+				i,
+				i-1,
+			)
+		case phase.Subsidy < 0 || phase.MasternodeSubsidy < 0:
+			return fmt.Errorf("%w: phase %d has negative subsidy", ErrInvalidSubsidySchedule, i)
+		case phase.MasternodeSubsidy > phase.Subsidy:
+			return fmt.Errorf(
+				"%w: masternode subsidy of phase %d exceeds its subsidy",
+				ErrInvalidSubsidySchedule,
+				i,
+			)
+		}
+	}
+
+	return nil
+}
+
+// BlockSubsidy returns the subsidy of the block at height on
+// the network with params. If no subsidy schedule is registered
+// for the network (see ChainParams), blocks are mined
+// and the subsidy is halved every params.SubsidyReductionInterval
+// blocks (as upstream). Networks with neither (such as mainnet
+// and testnet, whose schedules are not known) return
+// ErrUnknownSubsidy.
+func BlockSubsidy(params *chaincfg.Params, height int32) (*Subsidy, error) {
+	phases := chainSettings(params).SubsidySchedule
+	if len(phases) == 0 {
+		if params.SubsidyReductionInterval <= 0 {
+			return nil, fmt.Errorf(
+				"%w: %s has no subsidy schedule or halving interval",
+				ErrUnknownSubsidy,
+				params.Name,
+			)
+		}
+
+		total := halvingSubsidy(params, height)
+		return &Subsidy{Total: total, Producer: total}, nil
+	}
+
+	var phase *SubsidyPhase
+	for _, p := range phases {
+		if p.Height > height {
+			break
+		}
+
+		phase = p
+	}
+
+	// Heights before genesis
+	// have no subsidy.
+	if phase == nil {
+		return &Subsidy{}, nil
+	}
+
+	return &Subsidy{
+		ProofOfStake: phase.ProofOfStake,
+		Total:        phase.Subsidy,
+		Producer:     phase.Subsidy - phase.MasternodeSubsidy,
+		Masternode:   phase.MasternodeSubsidy,
+	}, nil
+}
+
+// halvingSubsidy returns the subsidy of the block at height
+// of a network whose subsidy halves every
+// params.SubsidyReductionInterval blocks (which
+// must be positive).
+func halvingSubsidy(params *chaincfg.Params, height int32) int64 {
+	if height < 0 {
+		return 0
+	}
+
+	halvings := height / params.SubsidyReductionInterval
+	if halvings >= maxSubsidyHalvings {
+		return 0
+	}
+
+	return baseSubsidy >> uint(halvings)
+}
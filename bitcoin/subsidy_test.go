@@ -0,0 +1,122 @@
+// Copyright 2020 Coinbase, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package bitcoin
+
+import (
+	"errors"
+	"io/ioutil"
+	"path"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestBlockSubsidy(t *testing.T) {
+	// The subsidy of networks with neither
+	// a schedule nor a halving interval is
+	// unknown.
+	subsidy, err := BlockSubsidy(MainnetParams, 0)
+	assert.Nil(t, subsidy)
+	assert.True(t, errors.Is(err, ErrUnknownSubsidy))
+
+	_, err = BlockSubsidy(TestnetParams, 0)
+	assert.True(t, errors.Is(err, ErrUnknownSubsidy))
+
+	// Networks without a schedule
+	// halve the subsidy.
+	halving := CloneParams(MainnetParams)
+	halving.SubsidyReductionInterval = 210000
+	subsidy, err = BlockSubsidy(halving, 0)
+	assert.NoError(t, err)
+	assert.Equal(t, &Subsidy{
+		Total:    50 * SatoshisInBitcoin,
+		Producer: 50 * SatoshisInBitcoin,
+	}, subsidy)
+
+	subsidy, err = BlockSubsidy(halving, halving.SubsidyReductionInterval)
+	assert.NoError(t, err)
+	assert.Equal(t, int64(25*SatoshisInBitcoin), subsidy.Total)
+
+	subsidy, err = BlockSubsidy(halving, halving.SubsidyReductionInterval*64)
+	assert.NoError(t, err)
+	assert.Equal(t, int64(0), subsidy.Total)
+
+	dir := t.TempDir()
+	paramsPath := path.Join(dir, "params.json")
+	assert.NoError(t, ioutil.WriteFile(paramsPath, []byte(`{
+		"name": "subsidy",
+		"genesis_hash": "0000009ea234b1ab29f0172e4d85884a45c0c638192c9c0f781bda67908d56dd",
+		"message_start": "a1b2c3e1",
+		"subsidy_schedule": [
+			{"height": 0, "subsidy": 25000000000},
+			{"height": 200, "subsidy": 500000000},
+			{"height": 500, "proof_of_stake": true, "subsidy": 500000000, "masternode_subsidy": 300000000}
+		]
+	}`), 0600))
+
+	params, err := LoadParamsFromFile(paramsPath)
+	assert.NoError(t, err)
+	defer ResetRegistry()
+
+	tests := map[string]struct {
+		height   int32
+		expected *Subsidy
+	}{
+		"genesis": {
+			height:   0,
+			expected: &Subsidy{Total: 25000000000, Producer: 25000000000},
+		},
+		"proof of work": {
+			height:   499,
+			expected: &Subsidy{Total: 500000000, Producer: 500000000},
+		},
+		"proof of stake": {
+			height: 500,
+			expected: &Subsidy{
+				ProofOfStake: true,
+				Total:        500000000,
+				Producer:     200000000,
+				Masternode:   300000000,
+			},
+		},
+		"before genesis": {
+			height:   -1,
+			expected: &Subsidy{},
+		},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			subsidy, err := BlockSubsidy(params, test.height)
+			assert.NoError(t, err)
+			assert.Equal(t, test.expected, subsidy)
+		})
+	}
+
+	invalid := map[string][]*SubsidyPhase{
+		"empty":         {},
+		"after genesis": {{Height: 1, Subsidy: 1}},
+		"unordered":     {{Height: 0, Subsidy: 1}, {Height: 0, Subsidy: 2}},
+		"negative":      {{Height: 0, Subsidy: -1}},
+		"masternode":    {{Height: 0, Subsidy: 1, MasternodeSubsidy: 2}},
+	}
+
+	for name, phases := range invalid {
+		t.Run(name, func(t *testing.T) {
+			err := ValidateSubsidySchedule(phases)
+			assert.True(t, errors.Is(err, ErrInvalidSubsidySchedule))
+		})
+	}
+}
@@ -0,0 +1,61 @@
+// Copyright 2020 Coinbase, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package bitcoin
+
+import (
+	"time"
+
+	"github.com/btcsuite/btcd/chaincfg"
+)
+
+const (
+	// TimeSlotLength is the length of the time slots of
+	// staked blocks (whose timestamps must be a multiple
+	// of it, so that stakers can't grind timestamps).
+	TimeSlotLength = 15 * time.Second
+
+	// FutureTimeDriftPoW and FutureTimeDriftPoS are how far
+	// ahead of the adjusted time of a node the timestamp of
+	// a mined (or staked) block may be.
+	FutureTimeDriftPoW = 2 * time.Hour
+	FutureTimeDriftPoS = 3 * time.Minute
+)
+
+// FutureDrift returns how far ahead of the adjusted time the
+// timestamp of the block at height on the network with params
+// may be (FutureTimeDriftPoS if the block is staked, see
+// BlockSubsidy, and FutureTimeDriftPoW otherwise). If the
+// subsidy of the network is unknown, the block may be mined,
+// so FutureTimeDriftPoW (the larger drift) is returned.
+func FutureDrift(params *chaincfg.Params, height int32) time.Duration {
+	subsidy, err := BlockSubsidy(params, height)
+	if err == nil && subsidy.ProofOfStake {
+		return FutureTimeDriftPoS
+	}
+
+	return FutureTimeDriftPoW
+}
+
+// IsValidBlockTimeSlot returns true if blockTime (the timestamp
+// of a staked block) is the start of a time slot and at most
+// FutureTimeDriftPoS ahead of adjustedTime (the network-adjusted
+// time of the node).
+func IsValidBlockTimeSlot(blockTime time.Time, adjustedTime time.Time) bool {
+	if blockTime.Unix()%int64(TimeSlotLength/time.Second) != 0 {
+		return false
+	}
+
+	return !blockTime.After(adjustedTime.Add(FutureTimeDriftPoS))
+}
@@ -0,0 +1,77 @@
+// Copyright 2020 Coinbase, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package bitcoin
+
+import (
+	"testing"
+	"time"
+
+	"github.com/btcsuite/btcd/wire"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestFutureDrift(t *testing.T) {
+	params := CloneParams(MainnetParams)
+	params.Net = wire.BitcoinNet(0xa1b2c3f6)
+
+	// Blocks are mined without a subsidy schedule
+	assert.Equal(t, FutureTimeDriftPoW, FutureDrift(params, 100))
+
+	assert.NoError(t, RegisterChainParams(&ChainParams{
+		Params: params,
+		SubsidySchedule: []*SubsidyPhase{
+			{Height: 0, Subsidy: 100},
+			{Height: 100, ProofOfStake: true, Subsidy: 50},
+		},
+	}))
+	defer ResetRegistry()
+
+	assert.Equal(t, FutureTimeDriftPoW, FutureDrift(params, 99))
+	assert.Equal(t, FutureTimeDriftPoS, FutureDrift(params, 100))
+}
+
+func TestIsValidBlockTimeSlot(t *testing.T) {
+	adjustedTime := time.Unix(1599999990, 0)
+
+	tests := map[string]struct {
+		blockTime time.Time
+		valid     bool
+	}{
+		"slot start": {
+			blockTime: adjustedTime,
+			valid:     true,
+		},
+		"past slot": {
+			blockTime: adjustedTime.Add(-10 * TimeSlotLength),
+			valid:     true,
+		},
+		"within slot": {
+			blockTime: adjustedTime.Add(7 * time.Second),
+		},
+		"last slot within drift": {
+			blockTime: adjustedTime.Add(FutureTimeDriftPoS),
+			valid:     true,
+		},
+		"slot beyond drift": {
+			blockTime: adjustedTime.Add(FutureTimeDriftPoS + TimeSlotLength),
+		},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			assert.Equal(t, test.valid, IsValidBlockTimeSlot(test.blockTime, adjustedTime))
+		})
+	}
+}
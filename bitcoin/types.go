@@ -15,10 +15,14 @@
 package bitcoin
 
 import (
+	"encoding/hex"
 	"fmt"
 	"strings"
+	"unicode/utf8"
 
 	"github.com/btcsuite/btcd/chaincfg"
+	"github.com/btcsuite/btcd/chaincfg/chainhash"
+	"github.com/btcsuite/btcd/wire"
 	"github.com/coinbase/rosetta-sdk-go/types"
 )
 
@@ -34,6 +38,10 @@ const (
 	// in TestnetNetworkIdentifier.
 	TestnetNetwork string = "Testnet3"
 
+	// SignetNetwork is the value of the network
+	// in SignetNetworkIdentifier.
+	SignetNetwork string = "Signet"
+
 	// Decimals is the decimals value
 	// used in Currency.
 	Decimals = 8
@@ -50,10 +58,66 @@ const (
 	// OUTPUT.
 	OutputOpType = "OUTPUT"
 
+	// DelegationOpType is used to describe an OUTPUT
+	// delegating coins to a staker with a cold staking
+	// script (see ColdStakeAccount).
+	DelegationOpType = "DELEGATION"
+
+	// StakeInputOpType is used to describe an INPUT of
+	// a coinstake (spending the staked coin).
+	StakeInputOpType = "STAKE_INPUT"
+
+	// StakeRewardOpType is used to describe an OUTPUT of a
+	// coinstake (returning the stake and the reward to the
+	// staker, or paying a masternode). The first one is the
+	// empty coinstake marker. The net reward is returned in
+	// the metadata of the coinstake (see TransactionMetadata).
+	StakeRewardOpType = "STAKE_REWARD"
+
+	// MasternodeRewardOpType is used to describe an OUTPUT
+	// of a reward transaction paying the masternode of the
+	// block (see MasternodePayment).
+	MasternodeRewardOpType = "MASTERNODE_REWARD"
+
+	// ZerocoinMintOpType is used to describe an OUTPUT
+	// minting zerocoins (crediting the ZerocoinAccount).
+	ZerocoinMintOpType = "ZC_MINT"
+
+	// ZerocoinSpendOpType is used to describe an INPUT
+	// spending zerocoins (debiting the ZerocoinAccount).
+	ZerocoinSpendOpType = "ZC_SPEND"
+
+	// TreasuryPayoutOpType is used to describe an OUTPUT
+	// of a superblock paying out the treasury (see
+	// TreasuryPayout).
+	TreasuryPayoutOpType = "TREASURY_PAYOUT"
+
 	// CoinbaseOpType is used to describe
 	// Coinbase.
 	CoinbaseOpType = "COINBASE"
 
+	// BurnOpType is used to describe an OP_RETURN output
+	// (which is provably unspendable, so its value, usually
+	// zero, is burned). It always has a zero amount.
+	BurnOpType = "BURN"
+
+	// GenesisAllocationOpType is used to describe
+	// a balance assigned in the genesis block.
+	GenesisAllocationOpType = "GENESIS_ALLOCATION"
+
+	// UnparseableOpType is used to describe a transaction
+	// that could not be parsed. It is the only operation of
+	// the transaction, unless only some of its outputs could
+	// not be parsed (then it replaces each of those outputs).
+	// The transaction is recorded in the dead-letter queue so
+	// it can be re-processed later.
+	UnparseableOpType = "UNPARSEABLE"
+
+	// GenesisAllocationsTransactionHash is the hash of the
+	// synthetic transaction in the genesis block that contains
+	// all GenesisAllocationOpType operations.
+	GenesisAllocationsTransactionHash = "genesis_allocations"
+
 	// SuccessStatus is the status of all
 	// Bitcoin operations because anything
 	// on-chain is considered successful.
@@ -73,6 +137,22 @@ const (
 	// as the ScriptPubKey.Type for OP_RETURN
 	// locking scripts.
 	NullData = "nulldata"
+
+	// ZerocoinMint and ColdStake are returned by
+	// the node as the ScriptPubKey.Type of zerocoin
+	// mints and cold staking scripts.
+	ZerocoinMint = "zerocoinmint"
+	ColdStake    = "coldstake"
+
+	// SegwitDeployment is the name of the segwit
+	// softfork in the `getblockchaininfo` response.
+	SegwitDeployment = "segwit"
+
+	// MainnetNet and TestnetNet are the message starts of
+	// mainnet and testnet (the pchMessageStart of the node,
+	// read little-endian).
+	MainnetNet = wire.BitcoinNet(0xe9fdc490)
+	TestnetNet = wire.BitcoinNet(0xba657645)
 )
 
 // Fee estimate constants
@@ -83,23 +163,106 @@ const (
 	InputSize             = 68               // 4 prev index, 32 prev hash, 4 sequence, 1 script size, ~27 script witness
 	OutputOverhead        = 9                // 8 value, 1 script size
 	P2PKHScriptPubkeySize = 25               // P2PKH size
+
+	// Sizes of transactions without witness data
+	// (on networks where segwit is not active).
+	LegacyTransactionOverhead = 10  // 4 version, 1 vin, 1 vout, 4 lock time
+	LegacyInputSize           = 148 // 4 prev index, 32 prev hash, 4 sequence, 1 script size, ~107 script sig
 )
 
-// CreateMainNetParams is a function to override default mainnet settings with address prefixes
+// CreateMainNetParams returns the params of mainnet. They are a copy
+// of the upstream mainnet params (so the upstream params, which btcd
+// uses for Bitcoin, are not modified) with the message start, genesis
+// hash, and address prefixes of mainnet and without the upstream genesis
+// block, DNS seeds, checkpoints, BIP activation heights, and halving
+// interval, which are the genesis, nodes, blocks, upgrades, and emission
+// of another chain.
 func CreateMainNetParams() *chaincfg.Params {
-	chaincfg.MainNetParams.PubKeyHashAddrID = 0x21
-	chaincfg.MainNetParams.ScriptHashAddrID = 0x11
-	chaincfg.MainNetParams.Bech32HRPSegwit = "euno"
+	// The hash is a constant, so it always parses.
+	genesisHash, _ := chainhash.NewHashFromStr(MainnetGenesisBlockIdentifier.Hash)
+
+	params := CloneParams(&chaincfg.MainNetParams)
+	params.Net = MainnetNet
+	params.GenesisHash = genesisHash
+	params.GenesisBlock = nil
+	params.PubKeyHashAddrID = 0x21
+	params.ScriptHashAddrID = 0x11
+	params.Bech32HRPSegwit = "euno"
+	params.DNSSeeds = []chaincfg.DNSSeed{}
+	params.Checkpoints = []chaincfg.Checkpoint{}
+	params.BIP0034Height = 0
+	params.BIP0065Height = 0
+	params.BIP0066Height = 0
+	params.SubsidyReductionInterval = 0
+
+	return params
+}
 
-	return &chaincfg.MainNetParams
+// CreateTestNetParams returns the params of the testnet whose genesis
+// block has genesisHash. They are a copy of the upstream testnet3 params
+// (so the upstream params are not modified) with the message start and
+// address prefixes of testnet and without the upstream genesis block, DNS
+// seeds, checkpoints, BIP activation heights (the upgrades are active from
+// genesis, as on mainnet), and halving interval, which are those of another
+// chain. The settings of the chain that have no counterpart in
+// chaincfg.Params (like the staking address version) are added by
+// CreateTestNetChainParams.
+func CreateTestNetParams(genesisHash *chainhash.Hash) *chaincfg.Params {
+	params := CloneParams(&chaincfg.TestNet3Params)
+	params.Name = "euno-testnet"
+	params.Net = TestnetNet
+	params.GenesisHash = genesisHash
+	params.GenesisBlock = nil
+	params.DNSSeeds = []chaincfg.DNSSeed{}
+	params.Checkpoints = []chaincfg.Checkpoint{}
+	params.BIP0034Height = 0
+	params.BIP0065Height = 0
+	params.BIP0066Height = 0
+	params.SubsidyReductionInterval = 0
+
+	params.PubKeyHashAddrID = 0x8B
+	params.ScriptHashAddrID = 0x13
+	params.PrivateKeyID = 0xEF
+	params.Bech32HRPSegwit = "teuno"
+
+	return params
 }
 
-func CreateTestNetParams() *chaincfg.Params {
-	chaincfg.MainNetParams.PubKeyHashAddrID = 0x8B
-	chaincfg.MainNetParams.ScriptHashAddrID = 0x13
-	chaincfg.MainNetParams.Bech32HRPSegwit = "teuno"
+// CreateTestNetChainParams returns the params of the testnet
+// whose genesis block has genesisHash (see CreateTestNetParams)
+// and the settings of the chain of testnet.
+func CreateTestNetChainParams(genesisHash *chainhash.Hash) *ChainParams {
+	params := CreateTestNetParams(genesisHash)
+
+	return &ChainParams{
+		Params:           params,
+		StakingAddrID:    &testnetStakingAddrID,
+		CollateralAmount: TestnetCollateralAmount,
+		MaxMoney:         TestnetMaxMoney,
+		RelayFees:        &RelayFees{MinRelayTxFee: TestnetMinRelayTxFee},
+		ProtocolVersions: &ProtocolVersions{
+			MinProtocolVersion: TestnetProtocolVersion,
+			ProtocolVersion:    TestnetProtocolVersion,
+		},
+		BudgetCycleBlocks: TestnetBudgetCycleBlocks,
+		ActivationHashes:  genesisActivationHashes(params),
+	}
+}
 
-	return &chaincfg.MainNetParams
+// CreateSignetParams returns the params of a signet network
+// with challenge (the script that must be satisfied to produce
+// a block) and the genesis block with genesisHash. Unlike the
+// upstream signets, which share the genesis block of Bitcoin,
+// a signet of this chain starts from the genesis block of its
+// node, so the genesis hash must be provided. The params must
+// be registered (see Register) before addresses of the network
+// can be decoded.
+func CreateSignetParams(challenge []byte, genesisHash *chainhash.Hash) *chaincfg.Params {
+	params := chaincfg.CustomSignetParams(challenge, nil)
+	params.GenesisHash = genesisHash
+	params.GenesisBlock = nil
+
+	return &params
 }
 
 var (
@@ -111,19 +274,37 @@ var (
 	// MainnetParams are the params for mainnet.
 	MainnetParams = CreateMainNetParams()
 
+	// MainnetChainParams are MainnetParams and
+	// the settings of the chain of mainnet.
+	MainnetChainParams = &ChainParams{
+		Params:           MainnetParams,
+		StakingAddrID:    &mainnetStakingAddrID,
+		CollateralAmount: MainnetCollateralAmount,
+		MaxMoney:         MainnetMaxMoney,
+		RelayFees:        &RelayFees{MinRelayTxFee: MainnetMinRelayTxFee},
+		ProtocolVersions: &ProtocolVersions{
+			MinProtocolVersion: MainnetProtocolVersion,
+			ProtocolVersion:    MainnetProtocolVersion,
+		},
+		BudgetCycleBlocks: MainnetBudgetCycleBlocks,
+		ActivationHashes:  genesisActivationHashes(MainnetParams),
+	}
+
 	// MainnetCurrency is the *types.Currency for mainnet.
 	MainnetCurrency = &types.Currency{
 		Symbol:   "EUNO",
 		Decimals: Decimals,
 	}
 
-	// TestnetGenesisBlockIdentifier is the genesis block for testnet.
-	TestnetGenesisBlockIdentifier = &types.BlockIdentifier{
-		Hash: "000000000933ea01ad0ee984209779baaec3ced90fa3f408719526f8d77f4943",
-	}
+	// TestnetChainParams are TestnetParams and the settings
+	// of the chain of testnet. The genesis block of testnet
+	// is not built in (it is configured, and the configured
+	// params registered in place of these), so their
+	// GenesisHash is nil.
+	TestnetChainParams = CreateTestNetChainParams(nil)
 
 	// TestnetParams are the params for testnet.
-	TestnetParams = CreateTestNetParams()
+	TestnetParams = TestnetChainParams.Params
 
 	// TestnetCurrency is the *types.Currency for testnet.
 	TestnetCurrency = &types.Currency{
@@ -131,23 +312,10 @@ var (
 		Decimals: Decimals,
 	}
 
-	// OperationTypes are all supported operation.Types.
-	OperationTypes = []string{
-		InputOpType,
-		OutputOpType,
-		CoinbaseOpType,
-	}
-
-	// OperationStatuses are all supported operation.Status.
-	OperationStatuses = []*types.OperationStatus{
-		{
-			Status:     SuccessStatus,
-			Successful: true,
-		},
-		{
-			Status:     SkippedStatus,
-			Successful: false,
-		},
+	// SignetCurrency is the *types.Currency for signet.
+	SignetCurrency = &types.Currency{
+		Symbol:   "tEUNO",
+		Decimals: Decimals,
 	}
 )
 
@@ -170,13 +338,84 @@ type ScriptSig struct {
 	Hex string `json:"hex"`
 }
 
+// GenesisAllocation is a balance assigned to
+// an address in the genesis block (i.e. a premine).
+type GenesisAllocation struct {
+	Address string `json:"address"`
+
+	// Value is denominated in Satoshis.
+	Value int64 `json:"value"`
+}
+
 // BlockchainInfo is information about the Bitcoin network.
 // This struct only contains the information necessary for
 // this implementation.
 type BlockchainInfo struct {
-	Chain         string `json:"chain"`
-	Blocks        int64  `json:"blocks"`
-	BestBlockHash string `json:"bestblockhash"`
+	Chain                string  `json:"chain"`
+	Blocks               int64   `json:"blocks"`
+	Headers              int64   `json:"headers"`
+	BestBlockHash        string  `json:"bestblockhash"`
+	VerificationProgress float64 `json:"verificationprogress"`
+	InitialBlockDownload bool    `json:"initialblockdownload"`
+
+	Softforks map[string]*Softfork `json:"softforks,omitempty"`
+}
+
+// NetworkInfo is information about the P2P networking of
+// the node (returned by `getnetworkinfo`). This struct only
+// contains the information necessary for this implementation.
+type NetworkInfo struct {
+	Version         int64  `json:"version"`
+	SubVersion      string `json:"subversion"`
+	ProtocolVersion int64  `json:"protocolversion"`
+}
+
+// Softfork is the deployment status of a softfork
+// reported by `getblockchaininfo`.
+type Softfork struct {
+	Type   string `json:"type"`
+	Active bool   `json:"active"`
+	Height int64  `json:"height,omitempty"`
+}
+
+// MempoolEntry is information about a transaction in the
+// mempool (returned by `getmempoolentry`). This struct only
+// contains the information necessary for this implementation.
+type MempoolEntry struct {
+	VSize  int64 `json:"vsize"`
+	Weight int64 `json:"weight"`
+
+	// Time is when the transaction entered
+	// the mempool (in seconds since epoch).
+	Time int64 `json:"time"`
+
+	// Height is the height of the chain when
+	// the transaction entered the mempool.
+	Height int64 `json:"height"`
+
+	// Sizes of ancestors and descendants are virtual
+	// sizes (and include the transaction itself).
+	DescendantCount int64 `json:"descendantcount"`
+	DescendantSize  int64 `json:"descendantsize"`
+	AncestorCount   int64 `json:"ancestorcount"`
+	AncestorSize    int64 `json:"ancestorsize"`
+
+	// Depends are the parents of the
+	// transaction in the mempool.
+	Depends []string `json:"depends"`
+
+	Fees *MempoolEntryFees `json:"fees"`
+
+	BIP125Replaceable bool `json:"bip125-replaceable"`
+}
+
+// MempoolEntryFees are the fees (in BTC) of
+// a transaction in the mempool.
+type MempoolEntryFees struct {
+	Base       float64 `json:"base"`
+	Modified   float64 `json:"modified"`
+	Ancestor   float64 `json:"ancestor"`
+	Descendant float64 `json:"descendant"`
 }
 
 // PeerInfo is a collection of relevant info about a particular peer.
@@ -193,6 +432,15 @@ type PeerInfo struct {
 	SyncedHeaders  int64  `json:"synced_headers"`
 }
 
+// BannedSubnet is a subnet banned by
+// the node (as returned by `listbanned`).
+type BannedSubnet struct {
+	Address     string `json:"address"`
+	BannedUntil int64  `json:"banned_until"`
+	BanCreated  int64  `json:"ban_created"`
+	BanReason   string `json:"ban_reason,omitempty"`
+}
+
 // Block is a raw Bitcoin block (with verbosity == 2).
 type Block struct {
 	Hash              string  `json:"hash"`
@@ -208,6 +456,10 @@ type Block struct {
 	Bits              string  `json:"bits"`
 	Difficulty        float64 `json:"difficulty"`
 
+	// ChainWork is the hex-encoded total work of
+	// the chain up to and including the block.
+	ChainWork string `json:"chainwork"`
+
 	Txs []*Transaction `json:"tx"`
 }
 
@@ -262,11 +514,49 @@ func (t Transaction) Metadata() (map[string]interface{}, error) {
 		Version:  t.Version,
 		Locktime: t.Locktime,
 		Weight:   t.Weight,
+		OpReturn: t.opReturnPayloads(),
 	}
 
 	return types.MarshalMap(m)
 }
 
+// opReturnPayloads returns the payload of each OP_RETURN
+// output of t (skipping outputs with a malformed script).
+func (t Transaction) opReturnPayloads() []*OpReturnPayload {
+	payloads := []*OpReturnPayload{}
+	for _, output := range t.Outputs {
+		if output.ScriptPubKey == nil || output.ScriptPubKey.Type != NullData {
+			continue
+		}
+
+		script, err := hex.DecodeString(output.ScriptPubKey.Hex)
+		if err != nil {
+			continue
+		}
+
+		data, ok := NullDataPayload(script)
+		if !ok {
+			continue
+		}
+
+		payload := &OpReturnPayload{
+			Index: output.Index,
+			Hex:   hex.EncodeToString(data),
+		}
+		if utf8.Valid(data) {
+			payload.Text = string(data)
+		}
+
+		payloads = append(payloads, payload)
+	}
+
+	if len(payloads) == 0 {
+		return nil
+	}
+
+	return payloads
+}
+
 // TransactionMetadata is a collection of useful
 // metadata in a transaction.
 type TransactionMetadata struct {
@@ -275,6 +565,26 @@ type TransactionMetadata struct {
 	Version  int32 `json:"version,omitempty"`
 	Locktime int64 `json:"locktime,omitempty"`
 	Weight   int64 `json:"weight,omitempty"`
+
+	// OpReturn is the payload of each
+	// OP_RETURN output (if any).
+	OpReturn []*OpReturnPayload `json:"op_return,omitempty"`
+
+	// StakeReward is the net reward of the staker of a
+	// coinstake (its STAKE_REWARD outputs minus its
+	// STAKE_INPUT inputs), when the staked coins are known.
+	StakeReward *types.Amount `json:"stake_reward,omitempty"`
+}
+
+// OpReturnPayload is the payload of an OP_RETURN
+// output (at Index) of a transaction.
+type OpReturnPayload struct {
+	Index int64  `json:"index"`
+	Hex   string `json:"hex"`
+
+	// Text is the payload decoded as UTF-8
+	// (omitted if it is not valid UTF-8).
+	Text string `json:"text,omitempty"`
 }
 
 // Input is a raw input in a Bitcoin transaction.
@@ -287,6 +597,18 @@ type Input struct {
 
 	// Relevant when the input is the coinbase input
 	Coinbase string `json:"coinbase"`
+
+	// Prevout is only populated by nodes that return
+	// the output spent by each input (i.e. getblock
+	// with verbosity 3).
+	Prevout *Prevout `json:"prevout,omitempty"`
+}
+
+// Prevout is the output spent by an input,
+// as returned by the node.
+type Prevout struct {
+	Value        float64       `json:"value"`
+	ScriptPubKey *ScriptPubKey `json:"scriptPubKey"`
 }
 
 // Metadata returns the metadata for an input.
@@ -314,9 +636,21 @@ func (o Output) Metadata() (map[string]interface{}, error) {
 		ScriptPubKey: o.ScriptPubKey,
 	}
 
+	if staker, owner, ok := ColdStakeParties(o.ScriptPubKey); ok {
+		m.Staker = staker
+		m.Owner = owner
+	}
+
 	return types.MarshalMap(m)
 }
 
+// UnparseableMetadata is the metadata of an
+// UnparseableOpType operation.
+type UnparseableMetadata struct {
+	Error string `json:"error"`
+	Hex   string `json:"hex"`
+}
+
 // OperationMetadata is a collection of useful
 // metadata from Bitcoin inputs and outputs.
 type OperationMetadata struct {
@@ -330,6 +664,10 @@ type OperationMetadata struct {
 
 	// Output Metadata
 	ScriptPubKey *ScriptPubKey `json:"scriptPubKey,omitempty"`
+
+	// Delegation Metadata
+	Staker string `json:"staker,omitempty"`
+	Owner  string `json:"owner,omitempty"`
 }
 
 // request represents the JSON-RPC request body
@@ -372,6 +710,10 @@ func (b blockResponse) Err() error {
 		return ErrBlockNotFound
 	}
 
+	if b.Error.Code == blockPrunedErrCode && strings.Contains(b.Error.Message, blockPrunedErrMessage) {
+		return fmt.Errorf("%w: %s", ErrBlockPruned, b.Error.Message)
+	}
+
 	return fmt.Errorf(
 		"%w: error JSON RPC response, code: %d, message: %s",
 		ErrJSONRPCError,
@@ -398,6 +740,24 @@ func (b blockchainInfoResponse) Err() error {
 	)
 }
 
+type networkInfoResponse struct {
+	Result *NetworkInfo   `json:"result"`
+	Error  *responseError `json:"error"`
+}
+
+func (n networkInfoResponse) Err() error {
+	if n.Error == nil {
+		return nil
+	}
+
+	return fmt.Errorf(
+		"%w: error JSON RPC response, code: %d, message: %s",
+		ErrJSONRPCError,
+		n.Error.Code,
+		n.Error.Message,
+	)
+}
+
 type peerInfoResponse struct {
 	Result []*PeerInfo    `json:"result"`
 	Error  *responseError `json:"error"`
@@ -496,6 +856,178 @@ func (r rawMempoolResponse) Err() error {
 	)
 }
 
+// mempoolEntryResponse is the response body for `getmempoolentry` requests.
+type mempoolEntryResponse struct {
+	Result *MempoolEntry  `json:"result"`
+	Error  *responseError `json:"error"`
+}
+
+func (m mempoolEntryResponse) Err() error {
+	if m.Error == nil {
+		return nil
+	}
+
+	if m.Error.Code == transactionNotInMempoolErrCode {
+		return ErrTransactionNotInMempool
+	}
+
+	return fmt.Errorf(
+		"%w: error JSON RPC response, code: %d, message: %s",
+		ErrJSONRPCError,
+		m.Error.Code,
+		m.Error.Message,
+	)
+}
+
+// rawTransactionResponse is the response body for `getrawtransaction` requests.
+type rawTransactionResponse struct {
+	Result *Transaction   `json:"result"`
+	Error  *responseError `json:"error"`
+}
+
+func (r rawTransactionResponse) Err() error {
+	if r.Error == nil {
+		return nil
+	}
+
+	if r.Error.Code == transactionNotInMempoolErrCode {
+		return ErrTransactionNotInMempool
+	}
+
+	return fmt.Errorf(
+		"%w: error JSON RPC response, code: %d, message: %s",
+		ErrJSONRPCError,
+		r.Error.Code,
+		r.Error.Message,
+	)
+}
+
+// invalidateBlockResponse is the response body for `invalidateblock` requests.
+type invalidateBlockResponse struct {
+	Error *responseError `json:"error"`
+}
+
+func (i invalidateBlockResponse) Err() error {
+	if i.Error == nil {
+		return nil
+	}
+
+	return fmt.Errorf(
+		"%w: error JSON RPC response, code: %d, message: %s",
+		ErrJSONRPCError,
+		i.Error.Code,
+		i.Error.Message,
+	)
+}
+
+// reconsiderBlockResponse is the response body for `reconsiderblock` requests.
+type reconsiderBlockResponse struct {
+	Error *responseError `json:"error"`
+}
+
+func (r reconsiderBlockResponse) Err() error {
+	if r.Error == nil {
+		return nil
+	}
+
+	return fmt.Errorf(
+		"%w: error JSON RPC response, code: %d, message: %s",
+		ErrJSONRPCError,
+		r.Error.Code,
+		r.Error.Message,
+	)
+}
+
+// generateToAddressResponse is the response body for `generatetoaddress` requests.
+type generateToAddressResponse struct {
+	Result []string       `json:"result"`
+	Error  *responseError `json:"error"`
+}
+
+func (g generateToAddressResponse) Err() error {
+	if g.Error == nil {
+		return nil
+	}
+
+	return fmt.Errorf(
+		"%w: error JSON RPC response, code: %d, message: %s",
+		ErrJSONRPCError,
+		g.Error.Code,
+		g.Error.Message,
+	)
+}
+
+// listBannedResponse is the response body for `listbanned` requests.
+type listBannedResponse struct {
+	Result []*BannedSubnet `json:"result"`
+	Error  *responseError  `json:"error"`
+}
+
+func (l listBannedResponse) Err() error {
+	if l.Error == nil {
+		return nil
+	}
+
+	return fmt.Errorf(
+		"%w: error JSON RPC response, code: %d, message: %s",
+		ErrJSONRPCError,
+		l.Error.Code,
+		l.Error.Message,
+	)
+}
+
+// setBanResponse is the response body for `setban` requests.
+type setBanResponse struct {
+	Error *responseError `json:"error"`
+}
+
+func (s setBanResponse) Err() error {
+	if s.Error == nil {
+		return nil
+	}
+
+	return fmt.Errorf(
+		"%w: error JSON RPC response, code: %d, message: %s",
+		ErrJSONRPCError,
+		s.Error.Code,
+		s.Error.Message,
+	)
+}
+
+// disconnectNodeResponse is the response body for `disconnectnode` requests.
+type disconnectNodeResponse struct {
+	Error *responseError `json:"error"`
+}
+
+func (d disconnectNodeResponse) Err() error {
+	if d.Error == nil {
+		return nil
+	}
+
+	return fmt.Errorf(
+		"%w: error JSON RPC response, code: %d, message: %s",
+		ErrJSONRPCError,
+		d.Error.Code,
+		d.Error.Message,
+	)
+}
+
+// TransactionHashes are the identifiers of a
+// serialized transaction (see HashTransaction).
+type TransactionHashes struct {
+	// TxID is the hash of the transaction without
+	// witness data. It is the transaction identifier
+	// used by the node (and returned by /construction/hash).
+	TxID string `json:"txid"`
+
+	// WTxID is the hash of the transaction including
+	// witness data (equal to TxID if the transaction
+	// has no witness data).
+	WTxID string `json:"wtxid"`
+
+	HasWitness bool `json:"has_witness"`
+}
+
 // CoinIdentifier converts a tx hash and vout into
 // the canonical CoinIdentifier.Identifier used in
 // rosetta-bitcoin.
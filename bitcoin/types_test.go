@@ -0,0 +1,148 @@
+// Copyright 2020 Coinbase, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package bitcoin
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/btcsuite/btcd/chaincfg"
+	"github.com/btcsuite/btcd/chaincfg/chainhash"
+	"github.com/btcsuite/btcd/wire"
+	"github.com/stretchr/testify/assert"
+)
+
+// testnetGenesisHash is the genesis
+// hash of testnet in the tests.
+const testnetGenesisHash = "00000c7c73d8ce604178dae13f0fc6ec0be3275614366d44b1b4b5c6e238c60c"
+
+func TestCreateMainNetParams(t *testing.T) {
+	assert.Equal(t, MainnetNet, MainnetParams.Net)
+	assert.Equal(t, MainnetGenesisBlockIdentifier.Hash, MainnetParams.GenesisHash.String())
+	assert.Nil(t, MainnetParams.GenesisBlock)
+	assert.Equal(t, byte(0x21), MainnetParams.PubKeyHashAddrID)
+
+	// The upstream params (which btcd uses
+	// for Bitcoin) are not modified.
+	assert.NotSame(t, &chaincfg.MainNetParams, MainnetParams)
+	assert.Equal(t, wire.MainNet, chaincfg.MainNetParams.Net)
+	assert.Equal(t, byte(0x00), chaincfg.MainNetParams.PubKeyHashAddrID)
+	assert.Equal(t, "bc", chaincfg.MainNetParams.Bech32HRPSegwit)
+	assert.NotNil(t, chaincfg.MainNetParams.GenesisBlock)
+	assert.NotEmpty(t, chaincfg.MainNetParams.Checkpoints)
+	assert.NotEqual(t, MainnetParams.GenesisHash, chaincfg.MainNetParams.GenesisHash)
+}
+
+func TestCreateTestNetParams(t *testing.T) {
+	// Creating the testnet params does not
+	// modify the mainnet params.
+	assert.NotSame(t, MainnetParams, TestnetParams)
+	assert.Equal(t, byte(0x21), MainnetParams.PubKeyHashAddrID)
+	assert.Equal(t, byte(0x11), MainnetParams.ScriptHashAddrID)
+	assert.Equal(t, "euno", MainnetParams.Bech32HRPSegwit)
+
+	assert.Equal(t, byte(0x8B), TestnetParams.PubKeyHashAddrID)
+	assert.Equal(t, byte(0x13), TestnetParams.ScriptHashAddrID)
+	assert.Equal(t, byte(0xEF), TestnetParams.PrivateKeyID)
+	assert.Equal(t, "teuno", TestnetParams.Bech32HRPSegwit)
+	assert.Equal(t, TestnetNet, TestnetParams.Net)
+	assert.Equal(t, wire.TestNet3, chaincfg.TestNet3Params.Net)
+
+	// The genesis block of testnet is configured.
+	assert.Nil(t, TestnetParams.GenesisHash)
+	assert.Nil(t, TestnetParams.GenesisBlock)
+	assert.Empty(t, TestnetChainParams.ActivationHashes)
+
+	genesisHash, err := chainhash.NewHashFromStr(testnetGenesisHash)
+	assert.NoError(t, err)
+	testnet := CreateTestNetChainParams(genesisHash)
+	assert.Equal(t, genesisHash, testnet.GenesisHash)
+	assert.Equal(t, TestnetNet, testnet.Net)
+	assert.Equal(t, TestnetStakingAddrID, *testnet.StakingAddrID)
+	assert.Equal(t, genesisHash, testnet.ActivationHashes[BIP0034Activation])
+
+	// Upstream seeds and checkpoints are not used.
+	assert.Empty(t, MainnetParams.DNSSeeds)
+	assert.Empty(t, TestnetParams.DNSSeeds)
+	assert.Empty(t, TestnetParams.Checkpoints)
+	assert.NotEmpty(t, chaincfg.TestNet3Params.DNSSeeds)
+}
+
+func TestCreateSignetParams(t *testing.T) {
+	genesisHash, err := chainhash.NewHashFromStr(
+		"00000b1a5b3e8b5d4ab1e7e7e3a7b6f2d6a5c3c1e9e1c5f0a6b1d1c0c9e8f7a6",
+	)
+	assert.NoError(t, err)
+
+	// The genesis block is not the
+	// genesis block of Bitcoin signets.
+	params := CreateSignetParams(chaincfg.DefaultSignetChallenge, genesisHash)
+	assert.Equal(t, genesisHash, params.GenesisHash)
+	assert.Nil(t, params.GenesisBlock)
+	assert.NotEqual(t, chaincfg.SigNetParams.GenesisHash, params.GenesisHash)
+	assert.Equal(t, chaincfg.SigNetParams.Net, params.Net)
+
+	// A custom challenge shares the genesis block
+	// but not the network magic.
+	custom := CreateSignetParams([]byte{0x51}, genesisHash)
+	assert.Equal(t, params.GenesisHash, custom.GenesisHash)
+	assert.NotEqual(t, params.Net, custom.Net)
+	assert.True(t, chaincfg.IsPubKeyHashAddrID(custom.PubKeyHashAddrID))
+
+	// Creating params does not register them.
+	_, err = ParamsForNet(custom.Net)
+	assert.True(t, errors.Is(err, ErrNetworkNotRegistered))
+
+	assert.Equal(t, custom, CreateSignetParams([]byte{0x51}, genesisHash))
+}
+
+func TestTransactionMetadata_OpReturn(t *testing.T) {
+	nullData := func(index int64, script string) *Output {
+		return &Output{
+			Index:        index,
+			ScriptPubKey: &ScriptPubKey{Hex: script, Type: NullData},
+		}
+	}
+	tx := Transaction{
+		Size: 100,
+		Outputs: []*Output{
+			{
+				Value:        1,
+				Index:        0,
+				ScriptPubKey: &ScriptPubKey{Hex: "76a914", Type: "pubkeyhash"},
+			},
+			nullData(1, "6a0568656c6c6f"),
+			nullData(2, "6a02ff00"),
+			nullData(3, "zz"),
+		},
+	}
+
+	metadata, err := tx.Metadata()
+	assert.NoError(t, err)
+	assert.Equal(t, mustMarshalMap(&TransactionMetadata{
+		Size: 100,
+		OpReturn: []*OpReturnPayload{
+			{Index: 1, Hex: "68656c6c6f", Text: "hello"},
+			{Index: 2, Hex: "ff00"},
+		},
+	}), metadata)
+
+	// Transactions without OP_RETURN
+	// outputs have no payloads
+	tx.Outputs = tx.Outputs[:1]
+	metadata, err = tx.Metadata()
+	assert.NoError(t, err)
+	assert.Equal(t, mustMarshalMap(&TransactionMetadata{Size: 100}), metadata)
+}
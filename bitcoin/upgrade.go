@@ -0,0 +1,208 @@
+// Copyright 2020 Coinbase, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package bitcoin
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+
+	"github.com/btcsuite/btcd/chaincfg"
+)
+
+// IDs of the consensus upgrades activated at a height
+// recorded in *chaincfg.Params (kept for callers of
+// ActivationHeight and IsUpgradeActive, see Activations
+// for all rule changes).
+const (
+	BIP0034Upgrade = iota
+	BIP0065Upgrade
+	BIP0066Upgrade
+)
+
+var (
+	// ErrInvalidUpgrades is returned when an
+	// upgrades table is not valid.
+	ErrInvalidUpgrades = errors.New("invalid upgrades")
+
+	// ErrUnknownUpgrade is returned when an upgrade
+	// ID or activation name is not known.
+	ErrUnknownUpgrade = errors.New("unknown upgrade")
+)
+
+// ActivationHeight returns the first block the
+// upgrade with id is active in on the network
+// with params.
+func ActivationHeight(params *chaincfg.Params, id int) (int32, error) {
+	if id < 0 || id >= len(upgradeActivations) {
+		return 0, fmt.Errorf("%w: %d", ErrUnknownUpgrade, id)
+	}
+
+	activation, err := LookupActivation(params, upgradeActivations[id])
+	if err != nil {
+		return 0, err
+	}
+
+	return activation.Height, nil
+}
+
+// IsUpgradeActive returns true if the upgrade with id
+// is active at height on the network with params
+// (false if the upgrade is unknown).
+func IsUpgradeActive(params *chaincfg.Params, id int, height int32) bool {
+	if id < 0 || id >= len(upgradeActivations) {
+		return false
+	}
+
+	activation, err := LookupActivation(params, upgradeActivations[id])
+	if err != nil {
+		return false
+	}
+
+	return activation.IsActive(height, 0)
+}
+
+// Upgrade is a (hypothetical) network upgrade that changes
+// how blocks are parsed from its activation height.
+type Upgrade struct {
+	Name string `json:"name"`
+
+	// Height is the first block
+	// the upgrade is active in.
+	Height int64 `json:"height"`
+
+	// AddressEra is the era whose prefixes the node
+	// uses to encode addresses once the upgrade is
+	// active.
+	AddressEra *AddressEra `json:"address_era"`
+}
+
+// LoadUpgrades loads an upgrades table (a JSON
+// array of *Upgrade ordered by height) from path.
+func LoadUpgrades(path string) ([]*Upgrade, error) {
+	content, err := ioutil.ReadFile(path) // #nosec G304
+	if err != nil {
+		return nil, fmt.Errorf("%w: unable to read %s", err, path)
+	}
+
+	var upgrades []*Upgrade
+	if err := json.Unmarshal(content, &upgrades); err != nil {
+		return nil, fmt.Errorf("%w: unable to parse %s", err, path)
+	}
+
+	if err := ValidateUpgrades(upgrades); err != nil {
+		return nil, err
+	}
+
+	return upgrades, nil
+}
+
+// ValidateUpgrades returns an error if upgrades
+// is not a valid upgrades table.
+func ValidateUpgrades(upgrades []*Upgrade) error {
+	if len(upgrades) == 0 {
+		return fmt.Errorf("%w: no upgrades provided", ErrInvalidUpgrades)
+	}
+
+	for i, upgrade := range upgrades {
+		switch {
+		case upgrade == nil || len(upgrade.Name) == 0:
+			return fmt.Errorf("%w: upgrade %d has no name", ErrInvalidUpgrades, i)
+		case upgrade.Height < 0:
+			return fmt.Errorf("%w: %s has negative height", ErrInvalidUpgrades, upgrade.Name)
+		case upgrade.AddressEra == nil:
+			return fmt.Errorf("%w: %s has no address era", ErrInvalidUpgrades, upgrade.Name)
+		case i > 0 && upgrade.Height <= upgrades[i-1].Height:
+			return fmt.Errorf(
+				"%w: %s does not activate after %s",
+				ErrInvalidUpgrades,
+				upgrade.Name,
+				upgrades[i-1].Name,
+			)
+		}
+	}
+
+	return nil
+}
+
+// ActiveUpgrade returns the upgrade active
+// at height (or nil if none is active).
+func ActiveUpgrade(upgrades []*Upgrade, height int64) *Upgrade {
+	var active *Upgrade
+	for _, upgrade := range upgrades {
+		if upgrade.Height > height {
+			break
+		}
+
+		active = upgrade
+	}
+
+	return active
+}
+
+// UpgradeAddress returns the address the node would return
+// for address (valid in any of eras) once upgrade is active.
+func UpgradeAddress(address string, eras []*AddressEra, upgrade *Upgrade) (string, error) {
+	return encodeAddress(address, eras, upgrade.AddressEra)
+}
+
+// UpgradeBlock returns a copy of block whose outputs have the
+// addresses the node would return once upgrade is active (block
+// is not modified). Addresses that are not base58 addresses of
+// any of eras (like segwit addresses) are kept.
+func UpgradeBlock(block *Block, eras []*AddressEra, upgrade *Upgrade) (*Block, error) {
+	upgraded := *block
+	upgraded.Txs = make([]*Transaction, len(block.Txs))
+	for j, tx := range block.Txs {
+		upgradedTx := *tx
+		upgradedTx.Outputs = make([]*Output, len(tx.Outputs))
+		for k, output := range tx.Outputs {
+			upgradedOutput := *output
+			if output.ScriptPubKey != nil {
+				scriptPubKey := *output.ScriptPubKey
+				scriptPubKey.Addresses = make([]string, len(output.ScriptPubKey.Addresses))
+				for l, address := range output.ScriptPubKey.Addresses {
+					upgradedAddress, err := UpgradeAccountAddress(address, eras, upgrade)
+					if err != nil {
+						return nil, err
+					}
+
+					scriptPubKey.Addresses[l] = upgradedAddress
+				}
+
+				upgradedOutput.ScriptPubKey = &scriptPubKey
+			}
+
+			upgradedTx.Outputs[k] = &upgradedOutput
+		}
+
+		upgraded.Txs[j] = &upgradedTx
+	}
+
+	return &upgraded, nil
+}
+
+// UpgradeAccountAddress returns UpgradeAddress of address if
+// it is a base58 address of any of eras (and address as is
+// otherwise, like segwit addresses and scripts, which do not
+// depend on the era).
+func UpgradeAccountAddress(address string, eras []*AddressEra, upgrade *Upgrade) (string, error) {
+	if _, _, err := AddressEras(address, eras); err != nil {
+		return address, nil
+	}
+
+	return UpgradeAddress(address, eras, upgrade)
+}
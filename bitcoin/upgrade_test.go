@@ -0,0 +1,93 @@
+// Copyright 2020 Coinbase, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package bitcoin
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/btcsuite/btcd/chaincfg"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestUpgrades(t *testing.T) {
+	v50 := &Upgrade{
+		Name:   "v50",
+		Height: 100,
+		AddressEra: &AddressEra{
+			Name:             "v50",
+			PubKeyHashAddrID: 0x00,
+			ScriptHashAddrID: 0x05,
+		},
+	}
+	v51 := &Upgrade{
+		Name:       "v51",
+		Height:     200,
+		AddressEra: MainnetAddressEras[0],
+	}
+	upgrades := []*Upgrade{v50, v51}
+
+	assert.NoError(t, ValidateUpgrades(upgrades))
+	assert.Nil(t, ActiveUpgrade(upgrades, 99))
+	assert.Equal(t, v50, ActiveUpgrade(upgrades, 100))
+	assert.Equal(t, v50, ActiveUpgrade(upgrades, 199))
+	assert.Equal(t, v51, ActiveUpgrade(upgrades, 200))
+
+	upgraded, err := UpgradeAddress("EH9uVaqWRxHuzJbroqzX18yxmeW8XVJyV9", MainnetAddressEras, v50)
+	assert.NoError(t, err)
+	assert.Equal(t, "1111111111111111111114oLvT2", upgraded)
+
+	upgraded, err = UpgradeAddress("7qkFjr4u54stuNNUR8fRF8dNhaP37bg4x7", MainnetAddressEras, v51)
+	assert.NoError(t, err)
+	assert.Equal(t, "7qkFjr4u54stuNNUR8fRF8dNhaP37bg4x7", upgraded)
+
+	invalid := map[string][]*Upgrade{
+		"empty":        {},
+		"no name":      {{Height: 1, AddressEra: v50.AddressEra}},
+		"negative":     {{Name: "v50", Height: -1, AddressEra: v50.AddressEra}},
+		"no era":       {{Name: "v50", Height: 1}},
+		"out of order": {v51, v50},
+	}
+
+	for name, test := range invalid {
+		t.Run(name, func(t *testing.T) {
+			assert.True(t, errors.Is(ValidateUpgrades(test), ErrInvalidUpgrades))
+		})
+	}
+}
+
+func TestActivationHeight(t *testing.T) {
+	params := chaincfg.MainNetParams
+	params.BIP0034Height = 100
+	params.BIP0065Height = 200
+	params.BIP0066Height = 150
+
+	height, err := ActivationHeight(&params, BIP0065Upgrade)
+	assert.NoError(t, err)
+	assert.Equal(t, int32(200), height)
+
+	assert.False(t, IsUpgradeActive(&params, BIP0034Upgrade, 99))
+	assert.True(t, IsUpgradeActive(&params, BIP0034Upgrade, 100))
+	assert.False(t, IsUpgradeActive(&params, BIP0065Upgrade, 199))
+	assert.True(t, IsUpgradeActive(&params, BIP0066Upgrade, 150))
+
+	// Testnet upgrades are active from genesis
+	assert.True(t, IsUpgradeActive(TestnetParams, BIP0065Upgrade, 0))
+
+	height, err = ActivationHeight(&params, 100)
+	assert.Equal(t, int32(0), height)
+	assert.True(t, errors.Is(err, ErrUnknownUpgrade))
+	assert.False(t, IsUpgradeActive(&params, 100, 1000))
+}
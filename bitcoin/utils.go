@@ -15,6 +15,9 @@
 package bitcoin
 
 import (
+	"bytes"
+	"encoding/hex"
+	"errors"
 	"fmt"
 	"strconv"
 	"strings"
@@ -22,10 +25,60 @@ import (
 	"github.com/btcsuite/btcd/chaincfg"
 	"github.com/btcsuite/btcd/chaincfg/chainhash"
 	"github.com/btcsuite/btcd/txscript"
+	"github.com/btcsuite/btcd/wire"
 	"github.com/btcsuite/btcutil"
 	"github.com/coinbase/rosetta-sdk-go/types"
 )
 
+const (
+	// witnessScaleFactor is the weight of each
+	// non-witness byte in a block or transaction.
+	witnessScaleFactor = 4
+)
+
+var (
+	// ErrInvalidHash is returned by NormalizeHash
+	// when a hash is malformed.
+	ErrInvalidHash = errors.New("invalid hash")
+)
+
+// NormalizeHash validates a block or transaction hash and returns
+// it in the form used by bitcoind: 64 lowercase hex characters
+// in the byte-reversed (big-endian) order displayed by block explorers
+// and returned by the node. Hashes may be provided in any case.
+//
+// It is not possible to detect a hash provided in little-endian
+// (internal) byte order, so it will simply not be found.
+func NormalizeHash(hash string) (string, error) {
+	if len(hash) != TransactionHashLength {
+		return "", fmt.Errorf(
+			"%w: %s is length %d, expected %d",
+			ErrInvalidHash,
+			hash,
+			len(hash),
+			TransactionHashLength,
+		)
+	}
+
+	normalized := strings.ToLower(hash)
+	if _, err := hex.DecodeString(normalized); err != nil {
+		return "", fmt.Errorf("%w: %s is not hex encoded", ErrInvalidHash, hash)
+	}
+
+	return normalized, nil
+}
+
+// NormalizeTransactionHash normalizes a transaction hash
+// (see NormalizeHash). GenesisAllocationsTransactionHash,
+// the hash of a synthetic transaction, is returned as-is.
+func NormalizeTransactionHash(hash string) (string, error) {
+	if hash == GenesisAllocationsTransactionHash {
+		return hash, nil
+	}
+
+	return NormalizeHash(hash)
+}
+
 // ParseCoinIdentifier returns the corresponding hash and index associated
 // with a *types.CoinIdentifier.
 func ParseCoinIdentifier(coinIdentifier *types.CoinIdentifier) (*chainhash.Hash, uint32, error) {
@@ -68,3 +121,103 @@ func ParseSingleAddress(
 
 	return class, address, nil
 }
+
+// HashTransaction returns the identifiers of a serialized
+// transaction. Signing a transaction that only spends segwit
+// outputs does not change its TxID (signatures are witness
+// data), so the TxID of an unsigned transaction is the TxID
+// it will have once signed (but its WTxID is not).
+func HashTransaction(rawTx []byte) (*TransactionHashes, error) {
+	var tx wire.MsgTx
+	if err := tx.Deserialize(bytes.NewReader(rawTx)); err != nil {
+		return nil, fmt.Errorf("%w unable to deserialize transaction", err)
+	}
+
+	return &TransactionHashes{
+		TxID:       tx.TxHash().String(),
+		WTxID:      tx.WitnessHash().String(),
+		HasWitness: tx.HasWitness(),
+	}, nil
+}
+
+// DecodeTransaction returns the *Transaction (as returned by
+// the node) of a serialized transaction of the network with
+// params.
+func DecodeTransaction(rawTx []byte, params *chaincfg.Params) (*Transaction, error) {
+	var tx wire.MsgTx
+	if err := tx.Deserialize(bytes.NewReader(rawTx)); err != nil {
+		return nil, fmt.Errorf("%w unable to deserialize transaction", err)
+	}
+
+	return NewTransaction(&tx, params)
+}
+
+// NewTransaction converts a *wire.MsgTx of the network
+// with params into a *Transaction (as returned by the
+// node).
+func NewTransaction(msgTx *wire.MsgTx, params *chaincfg.Params) (*Transaction, error) {
+	var buf bytes.Buffer
+	if err := msgTx.Serialize(&buf); err != nil {
+		return nil, fmt.Errorf("%w: unable to serialize transaction", err)
+	}
+
+	size := msgTx.SerializeSize()
+	weight := msgTx.SerializeSizeStripped()*(witnessScaleFactor-1) + size
+	tx := &Transaction{
+		Hex:      hex.EncodeToString(buf.Bytes()),
+		Hash:     msgTx.TxHash().String(),
+		Size:     int64(size),
+		Vsize:    int64((weight + witnessScaleFactor - 1) / witnessScaleFactor),
+		Version:  msgTx.Version,
+		Locktime: int64(msgTx.LockTime),
+		Weight:   int64(weight),
+		Inputs:   make([]*Input, len(msgTx.TxIn)),
+		Outputs:  make([]*Output, len(msgTx.TxOut)),
+	}
+
+	coinbase := isCoinbase(msgTx)
+	for i, txIn := range msgTx.TxIn {
+		input := &Input{
+			Sequence: int64(txIn.Sequence),
+		}
+
+		for _, item := range txIn.Witness {
+			input.TxInWitness = append(input.TxInWitness, hex.EncodeToString(item))
+		}
+
+		if coinbase {
+			input.Coinbase = hex.EncodeToString(txIn.SignatureScript)
+		} else {
+			input.TxHash = txIn.PreviousOutPoint.Hash.String()
+			input.Vout = int64(txIn.PreviousOutPoint.Index)
+			input.ScriptSig = &ScriptSig{
+				ASM: ScriptToAsm(txIn.SignatureScript, true),
+				Hex: hex.EncodeToString(txIn.SignatureScript),
+			}
+		}
+
+		tx.Inputs[i] = input
+	}
+
+	for i, txOut := range msgTx.TxOut {
+		tx.Outputs[i] = &Output{
+			Value:        btcutil.Amount(txOut.Value).ToBTC(),
+			Index:        int64(i),
+			ScriptPubKey: NewScriptPubKey(txOut.PkScript, params),
+		}
+	}
+
+	return tx, nil
+}
+
+// isCoinbase returns true if a transaction
+// is a coinbase (it has a single input that does not
+// spend a previous output).
+func isCoinbase(msgTx *wire.MsgTx) bool {
+	if len(msgTx.TxIn) != 1 {
+		return false
+	}
+
+	previous := msgTx.TxIn[0].PreviousOutPoint
+	return previous.Index == wire.MaxPrevOutIndex && previous.Hash == (chainhash.Hash{})
+}
@@ -0,0 +1,105 @@
+// Copyright 2020 Coinbase, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package bitcoin
+
+import (
+	"encoding/hex"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestHashTransaction(t *testing.T) {
+	txID := "6d87ad0e26025128f5a8357fa423b340cbcffb9703f79f432f5520fca59cd20b"
+	tests := map[string]struct {
+		rawTx string
+
+		hashes *TransactionHashes
+		err    bool
+	}{
+		"unsigned": {
+			rawTx: "01000000017f9cf50b02dd5258f80cd5c3437302e027dd1336172a20cdc80305c5a55741b10100000000ffffffff02db910e000000000016001488ce6925f8513a234c05c922ee933f221323052071ae000000000000160014940726595c41fca0b4810c62991ad9d289eeb82800000000", // nolint
+			hashes: &TransactionHashes{
+				TxID:  txID,
+				WTxID: txID,
+			},
+		},
+		"signed": {
+			rawTx: "010000000001017f9cf50b02dd5258f80cd5c3437302e027dd1336172a20cdc80305c5a55741b10100000000ffffffff02db910e000000000016001488ce6925f8513a234c05c922ee933f221323052071ae000000000000160014940726595c41fca0b4810c62991ad9d289eeb82802473044022025876ec8b9f51d343a5a56ac549c0c828005ef45ebe9da166db645c09157223f02204cd08b7278a8889a81135915bce10d1ef3bb92b217f81a0de7e79ffb3dfd6ac501210325c9a4252789b31dbb3454ec647e9516e7c596bcde2bd5da71a60fab8644e43800000000", // nolint
+			hashes: &TransactionHashes{
+				TxID:       txID,
+				WTxID:      "707230803d452f5449b3f5989c4df5040638a62831627c1fb447861e18170821",
+				HasWitness: true,
+			},
+		},
+		"truncated": {
+			rawTx: "01000000017f9cf50b",
+			err:   true,
+		},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			rawTx, err := hex.DecodeString(test.rawTx)
+			assert.NoError(t, err)
+
+			hashes, err := HashTransaction(rawTx)
+			if test.err {
+				assert.Nil(t, hashes)
+				assert.Error(t, err)
+				return
+			}
+
+			assert.NoError(t, err)
+			assert.Equal(t, test.hashes, hashes)
+		})
+	}
+}
+
+func TestDecodeTransaction(t *testing.T) {
+	rawTx, err := hex.DecodeString("010000000001017f9cf50b02dd5258f80cd5c3437302e027dd1336172a20cdc80305c5a55741b10100000000ffffffff02db910e000000000016001488ce6925f8513a234c05c922ee933f221323052071ae000000000000160014940726595c41fca0b4810c62991ad9d289eeb82802473044022025876ec8b9f51d343a5a56ac549c0c828005ef45ebe9da166db645c09157223f02204cd08b7278a8889a81135915bce10d1ef3bb92b217f81a0de7e79ffb3dfd6ac501210325c9a4252789b31dbb3454ec647e9516e7c596bcde2bd5da71a60fab8644e43800000000") // nolint
+	assert.NoError(t, err)
+
+	tx, err := DecodeTransaction(rawTx, MainnetParams)
+	assert.NoError(t, err)
+	assert.Equal(t, "6d87ad0e26025128f5a8357fa423b340cbcffb9703f79f432f5520fca59cd20b", tx.Hash)
+	assert.Equal(t, hex.EncodeToString(rawTx), tx.Hex)
+	assert.Len(t, tx.Inputs, 1)
+	assert.Len(t, tx.Outputs, 2)
+
+	client := NewClient("", MainnetGenesisBlockIdentifier, nil, MainnetCurrency)
+	transaction, err := client.ParseTransaction(tx)
+	assert.NoError(t, err)
+	assert.Equal(t, tx.Hash, transaction.TransactionIdentifier.Hash)
+	assert.Len(t, transaction.Operations, 3)
+
+	// The spent output is unknown offline
+	input := transaction.Operations[0]
+	assert.Equal(t, InputOpType, input.Type)
+	assert.Nil(t, input.Account)
+	assert.Nil(t, input.Amount)
+	assert.Equal(
+		t,
+		"b14157a5c50503c8cd202a173613dd27e0027343c3d50cf85852dd020bf59c7f:1",
+		input.CoinChange.CoinIdentifier.Identifier,
+	)
+
+	output := transaction.Operations[1]
+	assert.Equal(t, OutputOpType, output.Type)
+	assert.Equal(t, "954843", output.Amount.Value)
+
+	_, err = DecodeTransaction(rawTx[:10], MainnetParams)
+	assert.Error(t, err)
+}
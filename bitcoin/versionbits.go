@@ -0,0 +1,228 @@
+// Copyright 2020 Coinbase, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package bitcoin
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+
+	"github.com/btcsuite/btcd/chaincfg"
+	"github.com/btcsuite/btcd/wire"
+)
+
+const (
+	// versionBitsTopMask and versionBitsTopBits select
+	// the top 3 bits of a block version, which must be
+	// 001 for the block to signal for deployments.
+	versionBitsTopMask = 0xe0000000
+	versionBitsTopBits = 0x20000000
+
+	// medianTimeBlocks is the number of blocks used to
+	// compute the median time past of a block.
+	medianTimeBlocks = 11
+)
+
+// ErrInvalidDeployment is returned when the
+// threshold state of a deployment can't be
+// evaluated.
+var ErrInvalidDeployment = errors.New("invalid deployment")
+
+// ThresholdState is the state of a BIP9
+// (version bits) deployment at a block.
+type ThresholdState int
+
+// Threshold states in the order a
+// deployment moves through them.
+const (
+	ThresholdDefined ThresholdState = iota
+	ThresholdStarted
+	ThresholdLockedIn
+	ThresholdActive
+	ThresholdFailed
+)
+
+// String returns the name of the state (as
+// returned by the node in getblockchaininfo).
+func (s ThresholdState) String() string {
+	switch s {
+	case ThresholdDefined:
+		return "defined"
+	case ThresholdStarted:
+		return "started"
+	case ThresholdLockedIn:
+		return "locked_in"
+	case ThresholdActive:
+		return "active"
+	case ThresholdFailed:
+		return "failed"
+	default:
+		return fmt.Sprintf("unknown(%d)", int(s))
+	}
+}
+
+// Deployment is a BIP9 (version bits) deployment.
+type Deployment struct {
+	// BitNumber is the bit of the block
+	// version blocks signal with.
+	BitNumber uint8
+
+	// StartTime and ExpireTime are the median time
+	// past signaling starts at and times out at.
+	StartTime  uint64
+	ExpireTime uint64
+
+	// CustomActivationThreshold is the number of signaling
+	// blocks in a window required to lock in (if 0, the
+	// RuleChangeActivationThreshold of the params is used).
+	CustomActivationThreshold uint32
+
+	// MinActivationHeight is the first block the
+	// deployment can be active in once locked in
+	// (as in BIP341's speedy trial).
+	MinActivationHeight int32
+}
+
+// NewDeployment returns the *Deployment of
+// params.Deployments[id].
+func NewDeployment(params *chaincfg.Params, id int) (*Deployment, error) {
+	if id < 0 || id >= len(params.Deployments) {
+		return nil, fmt.Errorf("%w: %d is not a deployment", ErrInvalidDeployment, id)
+	}
+
+	deployment := params.Deployments[id]
+	return &Deployment{
+		BitNumber:  deployment.BitNumber,
+		StartTime:  deployment.StartTime,
+		ExpireTime: deployment.ExpireTime,
+	}, nil
+}
+
+// medianTimePast returns the median timestamp of the
+// medianTimeBlocks blocks ending at height.
+func medianTimePast(headers []*wire.BlockHeader, height int) uint64 {
+	start := height - medianTimeBlocks + 1
+	if start < 0 {
+		start = 0
+	}
+
+	timestamps := make([]int64, 0, height-start+1)
+	for _, header := range headers[start : height+1] {
+		timestamps = append(timestamps, header.Timestamp.Unix())
+	}
+	sort.Slice(timestamps, func(i, j int) bool { return timestamps[i] < timestamps[j] })
+
+	return uint64(timestamps[len(timestamps)/2])
+}
+
+// signals returns true if a block version
+// signals for the deployment.
+func (d *Deployment) signals(version int32) bool {
+	return uint32(version)&versionBitsTopMask == versionBitsTopBits &&
+		uint32(version)&(uint32(1)<<d.BitNumber) != 0
+}
+
+// ThresholdState returns the state of the deployment at the
+// block following headers (the header history of the chain
+// from genesis, so headers[i] is the header at height i).
+// States only change at the first block of each window
+// (params.MinerConfirmationWindow blocks), based on the
+// window ending at the previous block.
+func (d *Deployment) ThresholdState(
+	params *chaincfg.Params,
+	headers []*wire.BlockHeader,
+) (ThresholdState, error) {
+	state, _, err := d.evaluate(params, headers)
+	return state, err
+}
+
+// evaluate returns the state of the deployment at the block
+// following headers and the first block of the window the
+// deployment entered the state at.
+func (d *Deployment) evaluate(
+	params *chaincfg.Params,
+	headers []*wire.BlockHeader,
+) (ThresholdState, int32, error) {
+	window := int(params.MinerConfirmationWindow)
+	if window == 0 {
+		return ThresholdFailed, 0, fmt.Errorf(
+			"%w: %s has no confirmation window",
+			ErrInvalidDeployment,
+			params.Name,
+		)
+	}
+
+	threshold := d.CustomActivationThreshold
+	if threshold == 0 {
+		threshold = params.RuleChangeActivationThreshold
+	}
+	if threshold == 0 || threshold > uint32(window) {
+		return ThresholdFailed, 0, fmt.Errorf(
+			"%w: threshold %d is not in a window of %d",
+			ErrInvalidDeployment,
+			threshold,
+			window,
+		)
+	}
+
+	// The first window is always defined. Each following
+	// window is evaluated at the last block of the
+	// previous window.
+	state := ThresholdDefined
+	since := int32(0)
+	for end := window - 1; end < len(headers); end += window {
+		mtp := medianTimePast(headers, end)
+		previous := state
+
+		switch state {
+		case ThresholdDefined:
+			switch {
+			case mtp >= d.ExpireTime:
+				state = ThresholdFailed
+			case mtp >= d.StartTime:
+				state = ThresholdStarted
+			}
+		case ThresholdStarted:
+			count := uint32(0)
+			for _, header := range headers[end-window+1 : end+1] {
+				if d.signals(header.Version) {
+					count++
+				}
+			}
+
+			switch {
+			case count >= threshold:
+				state = ThresholdLockedIn
+			case mtp >= d.ExpireTime:
+				state = ThresholdFailed
+			}
+		case ThresholdLockedIn:
+			// The window being evaluated
+			// starts at end+1.
+			if int32(end+1) >= d.MinActivationHeight {
+				state = ThresholdActive
+			}
+		case ThresholdActive, ThresholdFailed:
+			// Terminal states
+			return state, since, nil
+		}
+
+		if state != previous {
+			since = int32(end + 1)
+		}
+	}
+
+	return state, since, nil
+}
@@ -0,0 +1,188 @@
+// Copyright 2020 Coinbase, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package bitcoin
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/btcsuite/btcd/chaincfg"
+	"github.com/btcsuite/btcd/wire"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestThresholdState(t *testing.T) {
+	params := chaincfg.RegressionNetParams
+	params.MinerConfirmationWindow = 10
+	params.RuleChangeActivationThreshold = 8
+
+	// newHeaders returns count headers (one per second from
+	// 1000) of which the first signaling blocks of each window of
+	// 10 signal for bit 1.
+	newHeaders := func(count int, signaling int) []*wire.BlockHeader {
+		headers := make([]*wire.BlockHeader, count)
+		for i := range headers {
+			version := int32(0x20000000)
+			if i%10 < signaling {
+				version |= 1 << 1
+			}
+
+			headers[i] = &wire.BlockHeader{
+				Version:   version,
+				Timestamp: time.Unix(int64(1000+i), 0),
+			}
+		}
+
+		return headers
+	}
+
+	deployment := &Deployment{
+		BitNumber:  1,
+		StartTime:  1010,
+		ExpireTime: 1100,
+	}
+
+	tests := map[string]struct {
+		deployment *Deployment
+		headers    []*wire.BlockHeader
+
+		state ThresholdState
+	}{
+		"genesis": {
+			deployment: deployment,
+			headers:    newHeaders(1, 10),
+			state:      ThresholdDefined,
+		},
+		"first window": {
+			deployment: deployment,
+			headers:    newHeaders(10, 10),
+			state:      ThresholdDefined,
+		},
+		"started": {
+			// The median time past at height 19 is 1014
+			deployment: deployment,
+			headers:    newHeaders(20, 10),
+			state:      ThresholdStarted,
+		},
+		"locked in": {
+			deployment: deployment,
+			headers:    newHeaders(30, 8),
+			state:      ThresholdLockedIn,
+		},
+		"not enough signaling": {
+			deployment: deployment,
+			headers:    newHeaders(30, 7),
+			state:      ThresholdStarted,
+		},
+		"active": {
+			deployment: deployment,
+			headers:    newHeaders(40, 8),
+			state:      ThresholdActive,
+		},
+		"active until the next window": {
+			deployment: deployment,
+			headers:    newHeaders(39, 8),
+			state:      ThresholdLockedIn,
+		},
+		"custom threshold": {
+			deployment: &Deployment{
+				BitNumber:                 1,
+				StartTime:                 1010,
+				ExpireTime:                1100,
+				CustomActivationThreshold: 5,
+			},
+			headers: newHeaders(30, 5),
+			state:   ThresholdLockedIn,
+		},
+		"min activation height": {
+			deployment: &Deployment{
+				BitNumber:           1,
+				StartTime:           1010,
+				ExpireTime:          1100,
+				MinActivationHeight: 50,
+			},
+			headers: newHeaders(40, 8),
+			state:   ThresholdLockedIn,
+		},
+		"active at min activation height": {
+			deployment: &Deployment{
+				BitNumber:           1,
+				StartTime:           1010,
+				ExpireTime:          1100,
+				MinActivationHeight: 50,
+			},
+			headers: newHeaders(50, 8),
+			state:   ThresholdActive,
+		},
+		"failed": {
+			deployment: &Deployment{
+				BitNumber:  1,
+				StartTime:  1010,
+				ExpireTime: 1020,
+			},
+			headers: newHeaders(40, 0),
+			state:   ThresholdFailed,
+		},
+		"expired before start": {
+			deployment: &Deployment{
+				BitNumber:  1,
+				StartTime:  1010,
+				ExpireTime: 1010,
+			},
+			headers: newHeaders(20, 10),
+			state:   ThresholdFailed,
+		},
+		"other bit": {
+			deployment: &Deployment{
+				BitNumber:  2,
+				StartTime:  1010,
+				ExpireTime: 1100,
+			},
+			headers: newHeaders(40, 10),
+			state:   ThresholdStarted,
+		},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			state, err := test.deployment.ThresholdState(&params, test.headers)
+			assert.NoError(t, err)
+			assert.Equal(t, test.state, state)
+		})
+	}
+
+	// Versions without the top bits don't signal
+	headers := newHeaders(30, 10)
+	for _, header := range headers {
+		header.Version &^= 0x20000000
+	}
+	state, err := deployment.ThresholdState(&params, headers)
+	assert.NoError(t, err)
+	assert.Equal(t, ThresholdStarted, state)
+	assert.Equal(t, "started", state.String())
+
+	invalid := params
+	invalid.RuleChangeActivationThreshold = 11
+	_, err = deployment.ThresholdState(&invalid, headers)
+	assert.True(t, errors.Is(err, ErrInvalidDeployment))
+
+	segwit, err := NewDeployment(MainnetParams, chaincfg.DeploymentSegwit)
+	assert.NoError(t, err)
+	assert.Equal(t, MainnetParams.Deployments[chaincfg.DeploymentSegwit].BitNumber, segwit.BitNumber)
+
+	_, err = NewDeployment(MainnetParams, chaincfg.DefinedDeployments)
+	assert.True(t, errors.Is(err, ErrInvalidDeployment))
+}
@@ -0,0 +1,63 @@
+// Copyright 2020 Coinbase, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package bitcoin
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/btcsuite/btcd/btcec"
+	"github.com/btcsuite/btcd/chaincfg"
+	"github.com/btcsuite/btcutil"
+)
+
+// ErrInvalidWIF is returned when a private key is
+// not a valid WIF private key of a network.
+var ErrInvalidWIF = errors.New("invalid WIF private key")
+
+// EncodeWIF returns the WIF (wallet import format) encoding of
+// privateKey on the network with params (prefixed with its
+// PrivateKeyID). If compressed is true, the key is marked as
+// the key of the compressed public key.
+func EncodeWIF(privateKey *btcec.PrivateKey, params *chaincfg.Params, compressed bool) (string, error) {
+	wif, err := btcutil.NewWIF(privateKey, params, compressed)
+	if err != nil {
+		return "", fmt.Errorf("%w: %s", ErrInvalidWIF, err.Error())
+	}
+
+	return wif.String(), nil
+}
+
+// DecodeWIF decodes a WIF private key of the network with
+// params, returning the private key and true if it is the
+// key of the compressed public key. Keys of networks with
+// another PrivateKeyID are rejected.
+func DecodeWIF(encoded string, params *chaincfg.Params) (*btcec.PrivateKey, bool, error) {
+	wif, err := btcutil.DecodeWIF(encoded)
+	if err != nil {
+		return nil, false, fmt.Errorf("%w: %s", ErrInvalidWIF, err.Error())
+	}
+
+	if !wif.IsForNet(params) {
+		return nil, false, fmt.Errorf(
+			"%w: %s is not a private key of %s",
+			ErrInvalidWIF,
+			encoded,
+			params.Name,
+		)
+	}
+
+	return wif.PrivKey, wif.CompressPubKey, nil
+}
@@ -0,0 +1,62 @@
+// Copyright 2020 Coinbase, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package bitcoin
+
+import (
+	"encoding/hex"
+	"errors"
+	"testing"
+
+	"github.com/btcsuite/btcd/btcec"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestWIF(t *testing.T) {
+	keyBytes, err := hex.DecodeString("0c28fca386c7a227600b2fe50b7cae11ec86d3bf1fbe471be89827e19d72aa1d")
+	assert.NoError(t, err)
+	privateKey, _ := btcec.PrivKeyFromBytes(btcec.S256(), keyBytes)
+
+	// The example key of the Bitcoin wiki
+	// (with the upstream mainnet prefix).
+	encoded, err := EncodeWIF(privateKey, MainnetParams, false)
+	assert.NoError(t, err)
+	assert.Equal(t, "5HueCGU8rMjxEXxiPuD5BDku4MkFqeZyd4dZ1jvhTVqvbTLvyTJ", encoded)
+
+	decoded, compressed, err := DecodeWIF(encoded, MainnetParams)
+	assert.NoError(t, err)
+	assert.False(t, compressed)
+	assert.Equal(t, privateKey.Serialize(), decoded.Serialize())
+
+	// Keys are encoded with the PrivateKeyID of the network
+	params := CloneParams(MainnetParams)
+	params.Name = "devnet"
+	params.PrivateKeyID = 0xd4
+	for _, compressed := range []bool{false, true} {
+		encoded, err := EncodeWIF(privateKey, params, compressed)
+		assert.NoError(t, err)
+
+		decoded, decodedCompressed, err := DecodeWIF(encoded, params)
+		assert.NoError(t, err)
+		assert.Equal(t, compressed, decodedCompressed)
+		assert.Equal(t, privateKey.Serialize(), decoded.Serialize())
+
+		// Keys of other networks are rejected
+		_, _, err = DecodeWIF(encoded, MainnetParams)
+		assert.True(t, errors.Is(err, ErrInvalidWIF))
+	}
+
+	_, _, err = DecodeWIF("5HueCGU8rMjxEXxiPuD5BDku4MkFqeZyd4dZ1jvhTVqvbTLvyTK", MainnetParams)
+	assert.True(t, errors.Is(err, ErrInvalidWIF))
+}
@@ -0,0 +1,95 @@
+// Copyright 2020 Coinbase, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package bitcoin
+
+import (
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/coinbase/rosetta-sdk-go/types"
+)
+
+// ZerocoinAddress is the address of the ZerocoinAccount.
+const ZerocoinAddress = "zerocoin"
+
+var (
+	// ErrInvalidZerocoinDenomination is returned when
+	// a zerocoin spend has an unknown denomination.
+	ErrInvalidZerocoinDenomination = errors.New("invalid zerocoin denomination")
+
+	// ZerocoinDenominations are the denominations (in
+	// whole coins) zerocoins are minted and spent in.
+	ZerocoinDenominations = []int64{1, 5, 10, 50, 100, 500, 1000, 5000}
+
+	// nullTxHash is the hash of the (null)
+	// prevout of zerocoin spends.
+	nullTxHash = strings.Repeat("0", TransactionHashLength)
+)
+
+// ZerocoinAccount returns the account that holds all
+// minted zerocoins. Mints and spends are not linked (the
+// prevout of a spend is null), so minted zerocoins are
+// pooled (and the balance of the account is the supply
+// of zerocoins). It holds no coins.
+func ZerocoinAccount() *types.AccountIdentifier {
+	return &types.AccountIdentifier{Address: ZerocoinAddress}
+}
+
+// IsZerocoinAccount returns true if account
+// is the ZerocoinAccount.
+func IsZerocoinAccount(account *types.AccountIdentifier) bool {
+	return account != nil && account.Address == ZerocoinAddress && account.SubAccount == nil
+}
+
+// IsZerocoinMint returns true if scriptPubKey
+// (as returned by the node) is a zerocoin mint.
+func IsZerocoinMint(scriptPubKey *ScriptPubKey) bool {
+	return scriptPubKey != nil && scriptPubKey.Type == ZerocoinMint
+}
+
+// IsZerocoinSpend returns true if input spends
+// zerocoins (its prevout is null and its signature
+// script is a private or public zerocoin spend).
+func IsZerocoinSpend(input *Input) bool {
+	if input.Coinbase != "" || input.ScriptSig == nil {
+		return false
+	}
+
+	if input.TxHash != "" && input.TxHash != nullTxHash {
+		return false
+	}
+
+	script, err := hex.DecodeString(input.ScriptSig.Hex)
+	if err != nil || len(script) == 0 {
+		return false
+	}
+
+	return script[0] == OpZerocoinSpend || script[0] == OpZerocoinPublicSpend
+}
+
+// ZerocoinSpendAmount returns the amount (in satoshis)
+// of a zerocoin spend. The node stores the denomination
+// (in whole coins) in the sequence of the input.
+func ZerocoinSpendAmount(input *Input) (int64, error) {
+	for _, denomination := range ZerocoinDenominations {
+		if input.Sequence == denomination {
+			return denomination * SatoshisInBitcoin, nil
+		}
+	}
+
+	return 0, fmt.Errorf("%w: %d", ErrInvalidZerocoinDenomination, input.Sequence)
+}
@@ -0,0 +1,73 @@
+// Copyright 2020 Coinbase, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package bitcoin
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/coinbase/rosetta-sdk-go/types"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestIsZerocoinSpend(t *testing.T) {
+	nullHash := "0000000000000000000000000000000000000000000000000000000000000000"
+	tests := map[string]struct {
+		input    *Input
+		expected bool
+	}{
+		"private spend": {
+			input:    &Input{TxHash: nullHash, ScriptSig: &ScriptSig{Hex: "c20102"}},
+			expected: true,
+		},
+		"public spend": {
+			input:    &Input{TxHash: nullHash, ScriptSig: &ScriptSig{Hex: "c30102"}},
+			expected: true,
+		},
+		"spend with prevout": {
+			input: &Input{
+				TxHash:    "b1a1a1a1a1a1a1a1a1a1a1a1a1a1a1a1a1a1a1a1a1a1a1a1a1a1a1a1a1a1a1a1",
+				ScriptSig: &ScriptSig{Hex: "c20102"},
+			},
+		},
+		"regular input": {
+			input: &Input{TxHash: nullHash, ScriptSig: &ScriptSig{Hex: "0102"}},
+		},
+		"coinbase": {
+			input: &Input{Coinbase: "c20102"},
+		},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			assert.Equal(t, test.expected, IsZerocoinSpend(test.input))
+		})
+	}
+}
+
+func TestZerocoinSpendAmount(t *testing.T) {
+	amount, err := ZerocoinSpendAmount(&Input{Sequence: 5000})
+	assert.NoError(t, err)
+	assert.Equal(t, int64(5000*SatoshisInBitcoin), amount)
+
+	_, err = ZerocoinSpendAmount(&Input{Sequence: 2})
+	assert.True(t, errors.Is(err, ErrInvalidZerocoinDenomination))
+
+	assert.True(t, IsZerocoinAccount(ZerocoinAccount()))
+	assert.False(t, IsZerocoinAccount(&types.AccountIdentifier{
+		Address:    ZerocoinAddress,
+		SubAccount: &types.SubAccountIdentifier{Address: DelegatedSubAccount},
+	}))
+}
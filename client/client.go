@@ -0,0 +1,316 @@
+// Copyright 2020 Coinbase, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package client calls the Rosetta servicers of this
+// implementation in-process, so Go applications that
+// embed it don't need to serve (and serialize) requests
+// over HTTP.
+package client
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/MNtank/rosetta-bitcoin/bitcoin"
+	"github.com/MNtank/rosetta-bitcoin/configuration"
+	"github.com/MNtank/rosetta-bitcoin/services"
+
+	"github.com/coinbase/rosetta-sdk-go/asserter"
+	"github.com/coinbase/rosetta-sdk-go/server"
+	"github.com/coinbase/rosetta-sdk-go/types"
+)
+
+var (
+	_ server.NetworkAPIServicer      = (*Client)(nil)
+	_ server.BlockAPIServicer        = (*Client)(nil)
+	_ server.AccountAPIServicer      = (*Client)(nil)
+	_ server.ConstructionAPIServicer = (*Client)(nil)
+	_ server.MempoolAPIServicer      = (*Client)(nil)
+	_ server.CallAPIServicer         = (*Client)(nil)
+)
+
+// Client implements the Rosetta servicers by validating
+// each request (as the HTTP server does) and calling the
+// servicers of the network directly.
+type Client struct {
+	asserter *asserter.Asserter
+
+	network      server.NetworkAPIServicer
+	block        server.BlockAPIServicer
+	account      server.AccountAPIServicer
+	construction server.ConstructionAPIServicer
+	mempool      server.MempoolAPIServicer
+	call         server.CallAPIServicer
+}
+
+// New returns a *Client of the network of config, using
+// client and i (which are nil in offline mode) as the
+// HTTP server does. The caller is responsible for starting
+// (and syncing) them.
+func New(
+	config *configuration.Configuration,
+	client services.Client,
+	i services.Indexer,
+) (*Client, error) {
+	asserter, err := asserter.NewServer(
+		bitcoin.OperationTypes(),
+		services.HistoricalBalanceLookup,
+		[]*types.NetworkIdentifier{config.Network},
+		services.CallMethods,
+		services.MempoolCoins,
+		"",
+	)
+	if err != nil {
+		return nil, fmt.Errorf("%w: unable to create asserter", err)
+	}
+
+	return &Client{
+		asserter:     asserter,
+		network:      services.NewNetworkAPIService(config, client, i),
+		block:        services.NewBlockAPIService(config, i),
+		account:      services.NewAccountAPIService(config, client, i),
+		construction: services.NewConstructionAPIService(config, client, i),
+		mempool:      services.NewMempoolAPIService(config, client, i),
+		call:         services.NewCallAPIService(config, client, i),
+	}, nil
+}
+
+// invalidRequest returns services.ErrInvalidRequest
+// with the reason a request was rejected.
+func invalidRequest(err error) *types.Error {
+	return &types.Error{
+		Code:      services.ErrInvalidRequest.Code,
+		Message:   services.ErrInvalidRequest.Message,
+		Retriable: services.ErrInvalidRequest.Retriable,
+		Details: map[string]interface{}{
+			"context": err.Error(),
+		},
+	}
+}
+
+// NetworkList implements the /network/list endpoint.
+func (c *Client) NetworkList(
+	ctx context.Context,
+	request *types.MetadataRequest,
+) (*types.NetworkListResponse, *types.Error) {
+	if err := c.asserter.MetadataRequest(request); err != nil {
+		return nil, invalidRequest(err)
+	}
+
+	return c.network.NetworkList(ctx, request)
+}
+
+// NetworkOptions implements the /network/options endpoint.
+func (c *Client) NetworkOptions(
+	ctx context.Context,
+	request *types.NetworkRequest,
+) (*types.NetworkOptionsResponse, *types.Error) {
+	if err := c.asserter.NetworkRequest(request); err != nil {
+		return nil, invalidRequest(err)
+	}
+
+	return c.network.NetworkOptions(ctx, request)
+}
+
+// NetworkStatus implements the /network/status endpoint.
+func (c *Client) NetworkStatus(
+	ctx context.Context,
+	request *types.NetworkRequest,
+) (*types.NetworkStatusResponse, *types.Error) {
+	if err := c.asserter.NetworkRequest(request); err != nil {
+		return nil, invalidRequest(err)
+	}
+
+	return c.network.NetworkStatus(ctx, request)
+}
+
+// Block implements the /block endpoint.
+func (c *Client) Block(
+	ctx context.Context,
+	request *types.BlockRequest,
+) (*types.BlockResponse, *types.Error) {
+	if err := c.asserter.BlockRequest(request); err != nil {
+		return nil, invalidRequest(err)
+	}
+
+	return c.block.Block(ctx, request)
+}
+
+// BlockTransaction implements the /block/transaction endpoint.
+func (c *Client) BlockTransaction(
+	ctx context.Context,
+	request *types.BlockTransactionRequest,
+) (*types.BlockTransactionResponse, *types.Error) {
+	if err := c.asserter.BlockTransactionRequest(request); err != nil {
+		return nil, invalidRequest(err)
+	}
+
+	return c.block.BlockTransaction(ctx, request)
+}
+
+// AccountBalance implements the /account/balance endpoint.
+func (c *Client) AccountBalance(
+	ctx context.Context,
+	request *types.AccountBalanceRequest,
+) (*types.AccountBalanceResponse, *types.Error) {
+	if err := c.asserter.AccountBalanceRequest(request); err != nil {
+		return nil, invalidRequest(err)
+	}
+
+	return c.account.AccountBalance(ctx, request)
+}
+
+// AccountCoins implements the /account/coins endpoint.
+func (c *Client) AccountCoins(
+	ctx context.Context,
+	request *types.AccountCoinsRequest,
+) (*types.AccountCoinsResponse, *types.Error) {
+	if err := c.asserter.AccountCoinsRequest(request); err != nil {
+		return nil, invalidRequest(err)
+	}
+
+	return c.account.AccountCoins(ctx, request)
+}
+
+// ConstructionCombine implements the /construction/combine endpoint.
+func (c *Client) ConstructionCombine(
+	ctx context.Context,
+	request *types.ConstructionCombineRequest,
+) (*types.ConstructionCombineResponse, *types.Error) {
+	if err := c.asserter.ConstructionCombineRequest(request); err != nil {
+		return nil, invalidRequest(err)
+	}
+
+	return c.construction.ConstructionCombine(ctx, request)
+}
+
+// ConstructionDerive implements the /construction/derive endpoint.
+func (c *Client) ConstructionDerive(
+	ctx context.Context,
+	request *types.ConstructionDeriveRequest,
+) (*types.ConstructionDeriveResponse, *types.Error) {
+	if err := c.asserter.ConstructionDeriveRequest(request); err != nil {
+		return nil, invalidRequest(err)
+	}
+
+	return c.construction.ConstructionDerive(ctx, request)
+}
+
+// ConstructionHash implements the /construction/hash endpoint.
+func (c *Client) ConstructionHash(
+	ctx context.Context,
+	request *types.ConstructionHashRequest,
+) (*types.TransactionIdentifierResponse, *types.Error) {
+	if err := c.asserter.ConstructionHashRequest(request); err != nil {
+		return nil, invalidRequest(err)
+	}
+
+	return c.construction.ConstructionHash(ctx, request)
+}
+
+// ConstructionMetadata implements the /construction/metadata endpoint.
+func (c *Client) ConstructionMetadata(
+	ctx context.Context,
+	request *types.ConstructionMetadataRequest,
+) (*types.ConstructionMetadataResponse, *types.Error) {
+	if err := c.asserter.ConstructionMetadataRequest(request); err != nil {
+		return nil, invalidRequest(err)
+	}
+
+	return c.construction.ConstructionMetadata(ctx, request)
+}
+
+// ConstructionParse implements the /construction/parse endpoint.
+func (c *Client) ConstructionParse(
+	ctx context.Context,
+	request *types.ConstructionParseRequest,
+) (*types.ConstructionParseResponse, *types.Error) {
+	if err := c.asserter.ConstructionParseRequest(request); err != nil {
+		return nil, invalidRequest(err)
+	}
+
+	return c.construction.ConstructionParse(ctx, request)
+}
+
+// ConstructionPayloads implements the /construction/payloads endpoint.
+func (c *Client) ConstructionPayloads(
+	ctx context.Context,
+	request *types.ConstructionPayloadsRequest,
+) (*types.ConstructionPayloadsResponse, *types.Error) {
+	if err := c.asserter.ConstructionPayloadsRequest(request); err != nil {
+		return nil, invalidRequest(err)
+	}
+
+	return c.construction.ConstructionPayloads(ctx, request)
+}
+
+// ConstructionPreprocess implements the /construction/preprocess endpoint.
+func (c *Client) ConstructionPreprocess(
+	ctx context.Context,
+	request *types.ConstructionPreprocessRequest,
+) (*types.ConstructionPreprocessResponse, *types.Error) {
+	if err := c.asserter.ConstructionPreprocessRequest(request); err != nil {
+		return nil, invalidRequest(err)
+	}
+
+	return c.construction.ConstructionPreprocess(ctx, request)
+}
+
+// ConstructionSubmit implements the /construction/submit endpoint.
+func (c *Client) ConstructionSubmit(
+	ctx context.Context,
+	request *types.ConstructionSubmitRequest,
+) (*types.TransactionIdentifierResponse, *types.Error) {
+	if err := c.asserter.ConstructionSubmitRequest(request); err != nil {
+		return nil, invalidRequest(err)
+	}
+
+	return c.construction.ConstructionSubmit(ctx, request)
+}
+
+// Mempool implements the /mempool endpoint.
+func (c *Client) Mempool(
+	ctx context.Context,
+	request *types.NetworkRequest,
+) (*types.MempoolResponse, *types.Error) {
+	if err := c.asserter.NetworkRequest(request); err != nil {
+		return nil, invalidRequest(err)
+	}
+
+	return c.mempool.Mempool(ctx, request)
+}
+
+// MempoolTransaction implements the /mempool/transaction endpoint.
+func (c *Client) MempoolTransaction(
+	ctx context.Context,
+	request *types.MempoolTransactionRequest,
+) (*types.MempoolTransactionResponse, *types.Error) {
+	if err := c.asserter.MempoolTransactionRequest(request); err != nil {
+		return nil, invalidRequest(err)
+	}
+
+	return c.mempool.MempoolTransaction(ctx, request)
+}
+
+// Call implements the /call endpoint.
+func (c *Client) Call(
+	ctx context.Context,
+	request *types.CallRequest,
+) (*types.CallResponse, *types.Error) {
+	if err := c.asserter.CallRequest(request); err != nil {
+		return nil, invalidRequest(err)
+	}
+
+	return c.call.Call(ctx, request)
+}
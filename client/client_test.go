@@ -0,0 +1,91 @@
+// Copyright 2020 Coinbase, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package client
+
+import (
+	"context"
+	"testing"
+
+	"github.com/MNtank/rosetta-bitcoin/bitcoin"
+	"github.com/MNtank/rosetta-bitcoin/configuration"
+	mocks "github.com/MNtank/rosetta-bitcoin/mocks/services"
+	"github.com/MNtank/rosetta-bitcoin/services"
+
+	"github.com/coinbase/rosetta-sdk-go/types"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestClient(t *testing.T) {
+	network := &types.NetworkIdentifier{
+		Network:    bitcoin.MainnetNetwork,
+		Blockchain: bitcoin.Blockchain,
+	}
+	cfg := &configuration.Configuration{
+		Mode:    configuration.Online,
+		Network: network,
+	}
+	mockIndexer := &mocks.Indexer{}
+	mockClient := &mocks.Client{}
+	c, err := New(cfg, mockClient, mockIndexer)
+	assert.NoError(t, err)
+	ctx := context.Background()
+
+	networks, rErr := c.NetworkList(ctx, &types.MetadataRequest{})
+	assert.Nil(t, rErr)
+	assert.Equal(t, []*types.NetworkIdentifier{network}, networks.NetworkIdentifiers)
+
+	block := &types.BlockResponse{
+		Block: &types.Block{
+			BlockIdentifier: &types.BlockIdentifier{
+				Index: 100,
+				Hash:  "0000000000000000000b1e4c2b2ad6f2e11a5c2ef9a8b9e1a2b3c4d5e6f70100",
+			},
+		},
+	}
+	mockIndexer.On(
+		"GetBlockLazy",
+		ctx,
+		&types.PartialBlockIdentifier{Index: types.Int64(100)},
+	).Return(block, nil).Once()
+	blockResponse, rErr := c.Block(ctx, &types.BlockRequest{
+		NetworkIdentifier: network,
+		BlockIdentifier:   &types.PartialBlockIdentifier{Index: types.Int64(100)},
+	})
+	assert.Nil(t, rErr)
+	assert.Equal(t, block, blockResponse)
+
+	// Requests are validated before
+	// reaching the servicers.
+	blockResponse, rErr = c.Block(ctx, &types.BlockRequest{
+		NetworkIdentifier: &types.NetworkIdentifier{
+			Network:    bitcoin.TestnetNetwork,
+			Blockchain: bitcoin.Blockchain,
+		},
+		BlockIdentifier: &types.PartialBlockIdentifier{Index: types.Int64(100)},
+	})
+	assert.Nil(t, blockResponse)
+	assert.Equal(t, services.ErrInvalidRequest.Code, rErr.Code)
+	assert.NotEmpty(t, rErr.Details["context"])
+
+	callResponse, rErr := c.Call(ctx, &types.CallRequest{
+		NetworkIdentifier: network,
+		Method:            "unknown",
+	})
+	assert.Nil(t, callResponse)
+	assert.Equal(t, services.ErrInvalidRequest.Code, rErr.Code)
+
+	mockIndexer.AssertExpectations(t)
+	mockClient.AssertExpectations(t)
+}
@@ -0,0 +1,489 @@
+// Copyright 2020 Coinbase, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package client
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"sort"
+
+	sdkClient "github.com/coinbase/rosetta-sdk-go/client"
+	"github.com/coinbase/rosetta-sdk-go/types"
+)
+
+const (
+	// remoteUserAgent is the user agent of
+	// requests made by a *Remote.
+	remoteUserAgent = "rosetta-bitcoin-compare"
+
+	// Reasons of a *Divergence.
+	blockReason       = "block"
+	transactionReason = "transaction"
+	balanceReason     = "balance"
+	coinsReason       = "coins"
+)
+
+var (
+	// ErrCompareFailed is returned when a block or
+	// account can't be fetched from an instance
+	// being compared.
+	ErrCompareFailed = errors.New("compare failed")
+
+	// ErrInvalidCompareRange is returned when the
+	// range of blocks to compare is empty.
+	ErrInvalidCompareRange = errors.New("invalid compare range")
+)
+
+// ComparedAPI is the Rosetta API of an instance
+// compared by Compare (either a *Client or a
+// *Remote).
+type ComparedAPI interface {
+	Block(context.Context, *types.BlockRequest) (*types.BlockResponse, *types.Error)
+	BlockTransaction(
+		context.Context,
+		*types.BlockTransactionRequest,
+	) (*types.BlockTransactionResponse, *types.Error)
+	AccountBalance(
+		context.Context,
+		*types.AccountBalanceRequest,
+	) (*types.AccountBalanceResponse, *types.Error)
+	AccountCoins(
+		context.Context,
+		*types.AccountCoinsRequest,
+	) (*types.AccountCoinsResponse, *types.Error)
+}
+
+// Remote calls the Rosetta API of another
+// instance over HTTP.
+type Remote struct {
+	client *sdkClient.APIClient
+}
+
+// NewRemote returns a *Remote of the instance
+// served at url.
+func NewRemote(url string, httpClient *http.Client) *Remote {
+	return &Remote{
+		client: sdkClient.NewAPIClient(
+			sdkClient.NewConfiguration(url, remoteUserAgent, httpClient),
+		),
+	}
+}
+
+// remoteErr returns the *types.Error returned by
+// a remote instance or err (if the request failed).
+func remoteErr(rErr *types.Error, err error) *types.Error {
+	if rErr != nil {
+		return rErr
+	}
+
+	if err != nil {
+		return &types.Error{Message: err.Error(), Retriable: true}
+	}
+
+	return nil
+}
+
+// Block implements the /block endpoint.
+func (r *Remote) Block(
+	ctx context.Context,
+	request *types.BlockRequest,
+) (*types.BlockResponse, *types.Error) {
+	response, rErr, err := r.client.BlockAPI.Block(ctx, request)
+	return response, remoteErr(rErr, err)
+}
+
+// BlockTransaction implements the /block/transaction endpoint.
+func (r *Remote) BlockTransaction(
+	ctx context.Context,
+	request *types.BlockTransactionRequest,
+) (*types.BlockTransactionResponse, *types.Error) {
+	response, rErr, err := r.client.BlockAPI.BlockTransaction(ctx, request)
+	return response, remoteErr(rErr, err)
+}
+
+// AccountBalance implements the /account/balance endpoint.
+func (r *Remote) AccountBalance(
+	ctx context.Context,
+	request *types.AccountBalanceRequest,
+) (*types.AccountBalanceResponse, *types.Error) {
+	response, rErr, err := r.client.AccountAPI.AccountBalance(ctx, request)
+	return response, remoteErr(rErr, err)
+}
+
+// AccountCoins implements the /account/coins endpoint.
+func (r *Remote) AccountCoins(
+	ctx context.Context,
+	request *types.AccountCoinsRequest,
+) (*types.AccountCoinsResponse, *types.Error) {
+	response, rErr, err := r.client.AccountAPI.AccountCoins(ctx, request)
+	return response, remoteErr(rErr, err)
+}
+
+// Divergence is the first difference
+// found by Compare.
+type Divergence struct {
+	// Reason is what diverged (block,
+	// transaction, balance or coins).
+	Reason string `json:"reason"`
+
+	// Index is the height of the diverging block (or,
+	// for balances and coins, the last block compared).
+	Index   int64                    `json:"index"`
+	Account *types.AccountIdentifier `json:"account,omitempty"`
+
+	// Local and Remote are what each instance
+	// returned (for coins, the coins only
+	// returned by that instance).
+	Local  interface{} `json:"local"`
+	Remote interface{} `json:"remote"`
+}
+
+// Comparison summarizes a range of blocks (and the
+// accounts they touched) compared by Compare.
+type Comparison struct {
+	StartIndex int64 `json:"start_index"`
+	EndIndex   int64 `json:"end_index"`
+
+	Blocks   int64 `json:"blocks"`
+	Accounts int64 `json:"accounts"`
+
+	// CoinsSkipped is the number of accounts whose coins
+	// were not compared because the instances returned
+	// them at different blocks (/account/coins has no
+	// historical lookup).
+	CoinsSkipped int64 `json:"coins_skipped"`
+
+	// Divergence is nil if the
+	// instances are consistent.
+	Divergence *Divergence `json:"divergence,omitempty"`
+}
+
+// Compare diffs the blocks between startIndex and endIndex
+// (inclusive) returned by local and remote, stopping at the
+// first block that diverges. If all of them match, it diffs
+// the balances (at endIndex) and coins of every account
+// touched by them.
+func Compare(
+	ctx context.Context,
+	local ComparedAPI,
+	remote ComparedAPI,
+	network *types.NetworkIdentifier,
+	startIndex int64,
+	endIndex int64,
+) (*Comparison, error) {
+	if startIndex < 0 || endIndex < startIndex {
+		return nil, fmt.Errorf(
+			"%w: %d to %d",
+			ErrInvalidCompareRange,
+			startIndex,
+			endIndex,
+		)
+	}
+
+	comparison := &Comparison{StartIndex: startIndex, EndIndex: endIndex}
+	accounts := map[string]*types.AccountIdentifier{}
+	for index := startIndex; index <= endIndex; index++ {
+		localBlock, err := compareBlock(ctx, local, network, index)
+		if err != nil {
+			return nil, fmt.Errorf("%w: local instance", err)
+		}
+
+		remoteBlock, err := compareBlock(ctx, remote, network, index)
+		if err != nil {
+			return nil, fmt.Errorf("%w: remote instance", err)
+		}
+
+		comparison.Blocks++
+		if divergence := blockDivergence(localBlock, remoteBlock); divergence != nil {
+			comparison.Divergence = divergence
+			return comparison, nil
+		}
+
+		for _, transaction := range localBlock.Transactions {
+			for _, op := range transaction.Operations {
+				if op.Account != nil {
+					accounts[types.Hash(op.Account)] = op.Account
+				}
+			}
+		}
+	}
+
+	keys := make([]string, 0, len(accounts))
+	for key := range accounts {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	for _, key := range keys {
+		account := accounts[key]
+		comparison.Accounts++
+
+		divergence, err := compareBalance(ctx, local, remote, network, account, endIndex)
+		if err != nil {
+			return nil, err
+		}
+
+		if divergence != nil {
+			comparison.Divergence = divergence
+			return comparison, nil
+		}
+
+		divergence, compared, err := compareCoins(ctx, local, remote, network, account)
+		if err != nil {
+			return nil, err
+		}
+
+		if !compared {
+			comparison.CoinsSkipped++
+		}
+
+		if divergence != nil {
+			comparison.Divergence = divergence
+			return comparison, nil
+		}
+	}
+
+	return comparison, nil
+}
+
+// compareBlock returns the block at index served by api
+// (including the transactions it only identifies).
+func compareBlock(
+	ctx context.Context,
+	api ComparedAPI,
+	network *types.NetworkIdentifier,
+	index int64,
+) (*types.Block, error) {
+	response, rErr := api.Block(ctx, &types.BlockRequest{
+		NetworkIdentifier: network,
+		BlockIdentifier:   &types.PartialBlockIdentifier{Index: &index},
+	})
+	if rErr != nil {
+		return nil, fmt.Errorf(
+			"%w: unable to get block %d: %s",
+			ErrCompareFailed,
+			index,
+			types.PrintStruct(rErr),
+		)
+	}
+
+	// The response is copied, as api
+	// may return a cached block.
+	block := *response.Block
+	block.Transactions = append([]*types.Transaction{}, block.Transactions...)
+	for _, identifier := range response.OtherTransactions {
+		transaction, rErr := api.BlockTransaction(ctx, &types.BlockTransactionRequest{
+			NetworkIdentifier:     network,
+			BlockIdentifier:       block.BlockIdentifier,
+			TransactionIdentifier: identifier,
+		})
+		if rErr != nil {
+			return nil, fmt.Errorf(
+				"%w: unable to get transaction %s: %s",
+				ErrCompareFailed,
+				identifier.Hash,
+				types.PrintStruct(rErr),
+			)
+		}
+
+		block.Transactions = append(block.Transactions, transaction.Transaction)
+	}
+
+	return &block, nil
+}
+
+// blockDivergence returns the first difference
+// between local and remote (or nil if they
+// are the same).
+func blockDivergence(local *types.Block, remote *types.Block) *Divergence {
+	if types.Hash(local) == types.Hash(remote) {
+		return nil
+	}
+
+	index := local.BlockIdentifier.Index
+	if types.Hash(local.BlockIdentifier) != types.Hash(remote.BlockIdentifier) ||
+		len(local.Transactions) != len(remote.Transactions) {
+		return &Divergence{
+			Reason: blockReason,
+			Index:  index,
+			Local:  blockSummary(local),
+			Remote: blockSummary(remote),
+		}
+	}
+
+	// Transactions are stored in the order of the block,
+	// so they are compared by position.
+	for i, transaction := range local.Transactions {
+		if types.Hash(transaction) != types.Hash(remote.Transactions[i]) {
+			return &Divergence{
+				Reason: transactionReason,
+				Index:  index,
+				Local:  transaction,
+				Remote: remote.Transactions[i],
+			}
+		}
+	}
+
+	// The header (i.e. the timestamp or
+	// metadata) diverged.
+	return &Divergence{
+		Reason: blockReason,
+		Index:  index,
+		Local:  blockSummary(local),
+		Remote: blockSummary(remote),
+	}
+}
+
+// blockSummary returns block without its transactions
+// (only their identifiers), so a divergence of a large
+// block can be read.
+func blockSummary(block *types.Block) map[string]interface{} {
+	identifiers := make([]*types.TransactionIdentifier, len(block.Transactions))
+	for i, transaction := range block.Transactions {
+		identifiers[i] = transaction.TransactionIdentifier
+	}
+
+	return map[string]interface{}{
+		"block_identifier":        block.BlockIdentifier,
+		"parent_block_identifier": block.ParentBlockIdentifier,
+		"timestamp":               block.Timestamp,
+		"transactions":            identifiers,
+		"metadata":                block.Metadata,
+	}
+}
+
+// compareBalance returns the difference between the balances
+// of account at index returned by local and remote (or nil
+// if they are the same).
+func compareBalance(
+	ctx context.Context,
+	local ComparedAPI,
+	remote ComparedAPI,
+	network *types.NetworkIdentifier,
+	account *types.AccountIdentifier,
+	index int64,
+) (*Divergence, error) {
+	request := &types.AccountBalanceRequest{
+		NetworkIdentifier: network,
+		AccountIdentifier: account,
+		BlockIdentifier:   &types.PartialBlockIdentifier{Index: &index},
+	}
+
+	localBalance, rErr := local.AccountBalance(ctx, request)
+	if rErr != nil {
+		return nil, accountErr("local", "balance", account, rErr)
+	}
+
+	remoteBalance, rErr := remote.AccountBalance(ctx, request)
+	if rErr != nil {
+		return nil, accountErr("remote", "balance", account, rErr)
+	}
+
+	if types.Hash(localBalance.Balances) == types.Hash(remoteBalance.Balances) {
+		return nil, nil
+	}
+
+	return &Divergence{
+		Reason:  balanceReason,
+		Index:   index,
+		Account: account,
+		Local:   localBalance.Balances,
+		Remote:  remoteBalance.Balances,
+	}, nil
+}
+
+// compareCoins returns the difference between the coins of
+// account returned by local and remote (or nil if they are
+// the same) and false if they were returned at different
+// blocks (in which case they are not compared).
+func compareCoins(
+	ctx context.Context,
+	local ComparedAPI,
+	remote ComparedAPI,
+	network *types.NetworkIdentifier,
+	account *types.AccountIdentifier,
+) (*Divergence, bool, error) {
+	request := &types.AccountCoinsRequest{
+		NetworkIdentifier: network,
+		AccountIdentifier: account,
+	}
+
+	localCoins, rErr := local.AccountCoins(ctx, request)
+	if rErr != nil {
+		return nil, false, accountErr("local", "coins", account, rErr)
+	}
+
+	remoteCoins, rErr := remote.AccountCoins(ctx, request)
+	if rErr != nil {
+		return nil, false, accountErr("remote", "coins", account, rErr)
+	}
+
+	if types.Hash(localCoins.BlockIdentifier) != types.Hash(remoteCoins.BlockIdentifier) {
+		return nil, false, nil
+	}
+
+	localOnly := coinsDifference(localCoins.Coins, remoteCoins.Coins)
+	remoteOnly := coinsDifference(remoteCoins.Coins, localCoins.Coins)
+	if len(localOnly) == 0 && len(remoteOnly) == 0 {
+		return nil, true, nil
+	}
+
+	return &Divergence{
+		Reason:  coinsReason,
+		Index:   localCoins.BlockIdentifier.Index,
+		Account: account,
+		Local:   localOnly,
+		Remote:  remoteOnly,
+	}, true, nil
+}
+
+// coinsDifference returns the coins in a that are
+// not in b (or have a different amount).
+func coinsDifference(a []*types.Coin, b []*types.Coin) []*types.Coin {
+	seen := map[string]struct{}{}
+	for _, coin := range b {
+		seen[types.Hash(coin)] = struct{}{}
+	}
+
+	difference := []*types.Coin{}
+	for _, coin := range a {
+		if _, ok := seen[types.Hash(coin)]; !ok {
+			difference = append(difference, coin)
+		}
+	}
+
+	return difference
+}
+
+// accountErr returns the error of an instance
+// that failed to return the balance or coins
+// of account.
+func accountErr(
+	instance string,
+	request string,
+	account *types.AccountIdentifier,
+	rErr *types.Error,
+) error {
+	return fmt.Errorf(
+		"%w: unable to get %s of %s from %s instance: %s",
+		ErrCompareFailed,
+		request,
+		types.PrintStruct(account),
+		instance,
+		types.PrintStruct(rErr),
+	)
+}
@@ -0,0 +1,250 @@
+// Copyright 2020 Coinbase, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package client
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/coinbase/rosetta-sdk-go/types"
+	"github.com/stretchr/testify/assert"
+)
+
+// compareAPI serves fixed blocks, balances
+// and coins of every account.
+type compareAPI struct {
+	blocks   map[int64]*types.BlockResponse
+	txs      map[string]*types.Transaction
+	balances map[string]string
+	coins    []*types.Coin
+	head     *types.BlockIdentifier
+}
+
+func (c *compareAPI) Block(
+	_ context.Context,
+	request *types.BlockRequest,
+) (*types.BlockResponse, *types.Error) {
+	block, ok := c.blocks[*request.BlockIdentifier.Index]
+	if !ok {
+		return nil, &types.Error{Code: 0, Message: "block not found"}
+	}
+
+	return block, nil
+}
+
+func (c *compareAPI) BlockTransaction(
+	_ context.Context,
+	request *types.BlockTransactionRequest,
+) (*types.BlockTransactionResponse, *types.Error) {
+	return &types.BlockTransactionResponse{
+		Transaction: c.txs[request.TransactionIdentifier.Hash],
+	}, nil
+}
+
+func (c *compareAPI) AccountBalance(
+	_ context.Context,
+	request *types.AccountBalanceRequest,
+) (*types.AccountBalanceResponse, *types.Error) {
+	return &types.AccountBalanceResponse{
+		BlockIdentifier: c.head,
+		Balances: []*types.Amount{
+			{Value: c.balances[request.AccountIdentifier.Address]},
+		},
+	}, nil
+}
+
+func (c *compareAPI) AccountCoins(
+	context.Context,
+	*types.AccountCoinsRequest,
+) (*types.AccountCoinsResponse, *types.Error) {
+	return &types.AccountCoinsResponse{
+		BlockIdentifier: c.head,
+		Coins:           c.coins,
+	}, nil
+}
+
+func newCompareAPI() *compareAPI {
+	transaction := func(hash string, address string, value string) *types.Transaction {
+		return &types.Transaction{
+			TransactionIdentifier: &types.TransactionIdentifier{Hash: hash},
+			Operations: []*types.Operation{
+				{
+					OperationIdentifier: &types.OperationIdentifier{Index: 0},
+					Type:                "OUTPUT",
+					Account:             &types.AccountIdentifier{Address: address},
+					Amount:              &types.Amount{Value: value},
+				},
+			},
+		}
+	}
+
+	blocks := map[int64]*types.BlockResponse{}
+	for index := int64(1); index <= 3; index++ {
+		blocks[index] = &types.BlockResponse{
+			Block: &types.Block{
+				BlockIdentifier: &types.BlockIdentifier{
+					Index: index,
+					Hash:  fmt.Sprintf("block %d", index),
+				},
+				Transactions: []*types.Transaction{
+					transaction(fmt.Sprintf("tx %d", index), "address 1", "100"),
+				},
+			},
+		}
+	}
+
+	// Block 3 is too large to inline
+	// all of its transactions.
+	blocks[3].OtherTransactions = []*types.TransactionIdentifier{{Hash: "tx 3b"}}
+
+	return &compareAPI{
+		blocks: blocks,
+		txs: map[string]*types.Transaction{
+			"tx 3b": transaction("tx 3b", "address 2", "50"),
+		},
+		balances: map[string]string{"address 1": "300", "address 2": "50"},
+		coins: []*types.Coin{
+			{
+				CoinIdentifier: &types.CoinIdentifier{Identifier: "tx 1:0"},
+				Amount:         &types.Amount{Value: "100"},
+			},
+		},
+		head: &types.BlockIdentifier{Index: 3, Hash: "block 3"},
+	}
+}
+
+func TestCompare(t *testing.T) {
+	ctx := context.Background()
+	network := &types.NetworkIdentifier{Blockchain: "Euno", Network: "Mainnet"}
+
+	t.Run("consistent", func(t *testing.T) {
+		comparison, err := Compare(ctx, newCompareAPI(), newCompareAPI(), network, 1, 3)
+		assert.NoError(t, err)
+		assert.Equal(t, &Comparison{
+			StartIndex: 1,
+			EndIndex:   3,
+			Blocks:     3,
+			Accounts:   2,
+		}, comparison)
+	})
+
+	t.Run("diverging block", func(t *testing.T) {
+		remote := newCompareAPI()
+		remote.blocks[2].Block.BlockIdentifier.Hash = "fork 2"
+		comparison, err := Compare(ctx, newCompareAPI(), remote, network, 1, 3)
+		assert.NoError(t, err)
+		assert.Equal(t, int64(2), comparison.Blocks)
+		assert.Equal(t, blockReason, comparison.Divergence.Reason)
+		assert.Equal(t, int64(2), comparison.Divergence.Index)
+	})
+
+	t.Run("diverging transaction", func(t *testing.T) {
+		remote := newCompareAPI()
+		remote.txs["tx 3b"].Operations[0].Amount.Value = "49"
+		comparison, err := Compare(ctx, newCompareAPI(), remote, network, 1, 3)
+		assert.NoError(t, err)
+		assert.Equal(t, transactionReason, comparison.Divergence.Reason)
+		assert.Equal(t, int64(3), comparison.Divergence.Index)
+		assert.Equal(t, remote.txs["tx 3b"], comparison.Divergence.Remote)
+	})
+
+	t.Run("diverging balance", func(t *testing.T) {
+		remote := newCompareAPI()
+		remote.balances["address 2"] = "0"
+		comparison, err := Compare(ctx, newCompareAPI(), remote, network, 1, 3)
+		assert.NoError(t, err)
+		assert.Equal(t, balanceReason, comparison.Divergence.Reason)
+		assert.Equal(t, "address 2", comparison.Divergence.Account.Address)
+	})
+
+	t.Run("diverging coins", func(t *testing.T) {
+		remote := newCompareAPI()
+		remote.coins = []*types.Coin{}
+		comparison, err := Compare(ctx, newCompareAPI(), remote, network, 1, 3)
+		assert.NoError(t, err)
+		assert.Equal(t, coinsReason, comparison.Divergence.Reason)
+		assert.Len(t, comparison.Divergence.Local, 1)
+		assert.Empty(t, comparison.Divergence.Remote)
+	})
+
+	t.Run("coins at different heads", func(t *testing.T) {
+		remote := newCompareAPI()
+		remote.coins = []*types.Coin{}
+		remote.head = &types.BlockIdentifier{Index: 4, Hash: "block 4"}
+		comparison, err := Compare(ctx, newCompareAPI(), remote, network, 1, 3)
+		assert.NoError(t, err)
+		assert.Nil(t, comparison.Divergence)
+		assert.Equal(t, int64(2), comparison.CoinsSkipped)
+	})
+
+	t.Run("missing block", func(t *testing.T) {
+		remote := newCompareAPI()
+		delete(remote.blocks, 3)
+		comparison, err := Compare(ctx, newCompareAPI(), remote, network, 1, 3)
+		assert.Nil(t, comparison)
+		assert.True(t, errors.Is(err, ErrCompareFailed))
+	})
+
+	t.Run("invalid range", func(t *testing.T) {
+		comparison, err := Compare(ctx, newCompareAPI(), newCompareAPI(), network, 3, 1)
+		assert.Nil(t, comparison)
+		assert.True(t, errors.Is(err, ErrInvalidCompareRange))
+	})
+}
+
+func TestRemote(t *testing.T) {
+	ctx := context.Background()
+	api := newCompareAPI()
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var request types.BlockRequest
+		assert.NoError(t, json.NewDecoder(r.Body).Decode(&request))
+
+		w.Header().Set("Content-Type", "application/json")
+		block, rErr := api.Block(ctx, &request)
+		if rErr != nil {
+			w.WriteHeader(http.StatusInternalServerError)
+			assert.NoError(t, json.NewEncoder(w).Encode(rErr))
+			return
+		}
+
+		assert.NoError(t, json.NewEncoder(w).Encode(block))
+	}))
+	defer ts.Close()
+
+	remote := NewRemote(ts.URL, nil)
+	block, rErr := remote.Block(ctx, &types.BlockRequest{
+		BlockIdentifier: &types.PartialBlockIdentifier{Index: types.Int64(1)},
+	})
+	assert.Nil(t, rErr)
+	assert.Equal(t, api.blocks[1], block)
+
+	block, rErr = remote.Block(ctx, &types.BlockRequest{
+		BlockIdentifier: &types.PartialBlockIdentifier{Index: types.Int64(4)},
+	})
+	assert.Nil(t, block)
+	assert.Equal(t, "block not found", rErr.Message)
+
+	// Failed requests are retriable errors.
+	ts.Close()
+	_, rErr = remote.Block(ctx, &types.BlockRequest{
+		BlockIdentifier: &types.PartialBlockIdentifier{Index: types.Int64(1)},
+	})
+	assert.True(t, rErr.Retriable)
+}
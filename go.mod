@@ -8,7 +8,10 @@ require (
 	github.com/coinbase/rosetta-sdk-go v0.7.2
 	github.com/dgraph-io/badger/v2 v2.2007.4
 	github.com/grpc-ecosystem/go-grpc-middleware v1.3.0
+	github.com/neilotoole/errgroup v0.1.6
 	github.com/stretchr/testify v1.7.0
 	go.uber.org/zap v1.19.1
+	golang.org/x/net v0.0.0-20210805182204-aaa1db679c0d
 	golang.org/x/sync v0.0.0-20210220032951-036812b2e83c
+	golang.org/x/time v0.0.0-20210220033141-f8bda1e9f3ba
 )
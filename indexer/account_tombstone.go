@@ -0,0 +1,261 @@
+// Copyright 2020 Coinbase, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package indexer
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/coinbase/rosetta-sdk-go/storage/database"
+	storageErrs "github.com/coinbase/rosetta-sdk-go/storage/errors"
+	"github.com/coinbase/rosetta-sdk-go/storage/modules"
+	"github.com/coinbase/rosetta-sdk-go/types"
+	"github.com/neilotoole/errgroup"
+)
+
+const (
+	// accountTombstoneNamespace is the namespace used
+	// to store the *AccountTombstone of each forgotten
+	// address.
+	accountTombstoneNamespace = "account-tombstone"
+)
+
+var (
+	// ErrAccountForgotten is returned when an
+	// address was already forgotten.
+	ErrAccountForgotten = errors.New("account already forgotten")
+
+	// ErrAccountNotEmpty is returned when forgetting an
+	// address that has unspent coins or a balance (its
+	// coins could still be spent in a later block).
+	ErrAccountNotEmpty = errors.New("account is not empty")
+)
+
+// AccountTombstone is recorded when the indexed
+// history of an address is purged by ForgetAccount.
+type AccountTombstone struct {
+	Address string `json:"address"`
+
+	// Head is the head block when
+	// the address was forgotten.
+	Head *types.BlockIdentifier `json:"head"`
+
+	// Forgotten is when the address was
+	// forgotten (in seconds since epoch).
+	Forgotten int64 `json:"forgotten"`
+}
+
+func getAccountTombstoneKey(address string) []byte {
+	return []byte(fmt.Sprintf("%s/%s", accountTombstoneNamespace, address))
+}
+
+// getAccountTombstone returns the *AccountTombstone
+// of address (or nil if none exists).
+func getAccountTombstone(
+	ctx context.Context,
+	dbTx database.Transaction,
+	address string,
+) (*AccountTombstone, error) {
+	exists, value, err := dbTx.Get(ctx, getAccountTombstoneKey(address))
+	if err != nil {
+		return nil, fmt.Errorf("%w: unable to get account tombstone %s", err, address)
+	}
+
+	if !exists {
+		return nil, nil
+	}
+
+	var tombstone AccountTombstone
+	if err := json.Unmarshal(value, &tombstone); err != nil {
+		return nil, fmt.Errorf("%w: unable to unmarshal account tombstone %s", err, address)
+	}
+
+	return &tombstone, nil
+}
+
+// AccountTombstone returns the *AccountTombstone of
+// address (or nil if it was not forgotten).
+func (i *Indexer) AccountTombstone(
+	ctx context.Context,
+	address string,
+) (*AccountTombstone, error) {
+	dbTx := i.database.ReadTransaction(ctx)
+	defer dbTx.Discard(ctx)
+
+	return getAccountTombstone(ctx, dbTx, address)
+}
+
+// ForgetAccount purges the historical balances (leaving a zero
+// balance at the head block) and the indexed transactions of an
+// empty account and records an *AccountTombstone, so that the
+// account is skipped by the reconciliation worker until it has
+// new activity. Accounts with unspent coins or a balance can't
+// be forgotten.
+func (i *Indexer) ForgetAccount(
+	ctx context.Context,
+	account *types.AccountIdentifier,
+	currency *types.Currency,
+) (*AccountTombstone, error) {
+	key := getAccountTombstoneKey(account.Address)
+	dbTx := i.database.WriteTransaction(ctx, string(key), true)
+	defer dbTx.Discard(ctx)
+
+	existing, err := getAccountTombstone(ctx, dbTx, account.Address)
+	if err != nil {
+		return nil, err
+	}
+
+	if existing != nil {
+		return nil, fmt.Errorf("%w: %s", ErrAccountForgotten, account.Address)
+	}
+
+	coins, head, err := i.coinStorage.GetCoinsTransactional(ctx, dbTx, account)
+	if err != nil {
+		return nil, fmt.Errorf("%w: unable to get coins of %s", err, account.Address)
+	}
+
+	if len(coins) > 0 {
+		return nil, fmt.Errorf(
+			"%w: %s has %d unspent coins",
+			ErrAccountNotEmpty,
+			account.Address,
+			len(coins),
+		)
+	}
+
+	balance, err := i.balanceStorage.GetBalanceTransactional(
+		ctx,
+		dbTx,
+		account,
+		currency,
+		head.Index,
+	)
+	if errors.Is(err, storageErrs.ErrAccountMissing) {
+		balance = &types.Amount{Value: zeroValue, Currency: currency}
+		err = nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("%w: unable to get balance of %s", err, account.Address)
+	}
+
+	if balance.Value != zeroValue {
+		return nil, fmt.Errorf(
+			"%w: %s has balance %s",
+			ErrAccountNotEmpty,
+			account.Address,
+			balance.Value,
+		)
+	}
+
+	// Setting the balance removes all
+	// historical balances of the account.
+	if err := i.balanceStorage.SetBalance(
+		ctx,
+		dbTx,
+		account,
+		&types.Amount{Value: zeroValue, Currency: currency},
+		head,
+	); err != nil {
+		return nil, fmt.Errorf("%w: unable to purge balances of %s", err, account.Address)
+	}
+
+	if _, err := i.addressIndex.PurgeAddress(ctx, dbTx, account.Address); err != nil {
+		return nil, fmt.Errorf("%w: unable to purge transactions of %s", err, account.Address)
+	}
+
+	tombstone := &AccountTombstone{
+		Address:   account.Address,
+		Head:      head,
+		Forgotten: time.Now().Unix(),
+	}
+	value, err := json.Marshal(tombstone)
+	if err != nil {
+		return nil, fmt.Errorf("%w: unable to marshal account tombstone", err)
+	}
+
+	if err := dbTx.Set(ctx, key, value, false); err != nil {
+		return nil, fmt.Errorf("%w: unable to set account tombstone", err)
+	}
+
+	if err := dbTx.Commit(ctx); err != nil {
+		return nil, fmt.Errorf("%w: unable to commit account tombstone", err)
+	}
+
+	return tombstone, nil
+}
+
+var _ modules.BlockWorker = (*accountTombstoneWorker)(nil)
+
+// accountTombstoneWorker implements modules.BlockWorker
+// to remove the *AccountTombstone of each address with an
+// operation in an added block, so that forgotten accounts
+// that receive new activity are reconciled again.
+type accountTombstoneWorker struct{}
+
+// AddingBlock is called by BlockStorage when adding a block.
+func (w *accountTombstoneWorker) AddingBlock(
+	ctx context.Context,
+	g *errgroup.Group,
+	block *types.Block,
+	dbTx database.Transaction,
+) (database.CommitWorker, error) {
+	seen := map[string]struct{}{}
+	for _, tx := range block.Transactions {
+		for _, op := range tx.Operations {
+			if op.Account == nil || len(op.Account.Address) == 0 {
+				continue
+			}
+
+			address := op.Account.Address
+			if _, ok := seen[address]; ok {
+				continue
+			}
+			seen[address] = struct{}{}
+
+			// Most addresses were never forgotten, so the
+			// tombstone is read before it is deleted.
+			key := getAccountTombstoneKey(address)
+			exists, _, err := dbTx.Get(ctx, key)
+			if err != nil {
+				return nil, fmt.Errorf("%w: unable to get account tombstone %s", err, address)
+			}
+
+			if !exists {
+				continue
+			}
+
+			if err := dbTx.Delete(ctx, key); err != nil {
+				return nil, fmt.Errorf("%w: unable to delete account tombstone %s", err, address)
+			}
+		}
+	}
+
+	return nil, nil
+}
+
+// RemovingBlock is called by BlockStorage when removing a block.
+// Tombstones removed when the block was added are not restored
+// (the account is reconciled like any other account).
+func (w *accountTombstoneWorker) RemovingBlock(
+	ctx context.Context,
+	g *errgroup.Group,
+	block *types.Block,
+	dbTx database.Transaction,
+) (database.CommitWorker, error) {
+	return nil, nil
+}
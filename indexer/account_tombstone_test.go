@@ -0,0 +1,244 @@
+// Copyright 2020 Coinbase, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package indexer
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+
+	"github.com/MNtank/rosetta-bitcoin/bitcoin"
+	"github.com/MNtank/rosetta-bitcoin/configuration"
+
+	"github.com/coinbase/rosetta-sdk-go/storage/database"
+	"github.com/coinbase/rosetta-sdk-go/types"
+	"github.com/coinbase/rosetta-sdk-go/utils"
+	"github.com/stretchr/testify/assert"
+	"golang.org/x/time/rate"
+)
+
+func TestForgetAccount(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	newDir, err := utils.CreateTempDir()
+	assert.NoError(t, err)
+	defer utils.RemoveTempDir(newDir)
+
+	cfg := &configuration.Configuration{
+		Network: &types.NetworkIdentifier{
+			Network:    bitcoin.MainnetNetwork,
+			Blockchain: bitcoin.Blockchain,
+		},
+		GenesisBlockIdentifier: bitcoin.MainnetGenesisBlockIdentifier,
+		IndexerPath:            newDir,
+	}
+
+	i, err := Initialize(ctx, cancel, cfg, nil)
+	assert.NoError(t, err)
+	defer i.CloseDatabase(ctx)
+
+	i.blockStorage.Initialize(i.workers)
+	account := &types.AccountIdentifier{Address: "deposit"}
+	other := &types.AccountIdentifier{Address: "other"}
+	coin := &types.CoinIdentifier{Identifier: fmt.Sprintf("%s:0", getBlockHash(0))}
+	genesis := &types.Block{
+		BlockIdentifier:       &types.BlockIdentifier{Index: 0, Hash: getBlockHash(0)},
+		ParentBlockIdentifier: &types.BlockIdentifier{Index: 0, Hash: getBlockHash(0)},
+		Transactions: []*types.Transaction{
+			{
+				TransactionIdentifier: &types.TransactionIdentifier{Hash: getBlockHash(0)},
+				Operations: []*types.Operation{
+					{
+						OperationIdentifier: &types.OperationIdentifier{Index: 0},
+						Type:                bitcoin.OutputOpType,
+						Status:              types.String(bitcoin.SuccessStatus),
+						Account:             account,
+						Amount: &types.Amount{
+							Value:    "10",
+							Currency: bitcoin.MainnetCurrency,
+						},
+						CoinChange: &types.CoinChange{
+							CoinIdentifier: coin,
+							CoinAction:     types.CoinCreated,
+						},
+					},
+				},
+			},
+		},
+	}
+	assert.NoError(t, i.BlockAdded(ctx, genesis))
+
+	// Accounts with unspent coins can't be forgotten
+	tombstone, err := i.ForgetAccount(ctx, account, bitcoin.MainnetCurrency)
+	assert.Nil(t, tombstone)
+	assert.True(t, errors.Is(err, ErrAccountNotEmpty))
+
+	block := &types.BlockIdentifier{Index: 1, Hash: getBlockHash(1)}
+	assert.NoError(t, i.BlockAdded(ctx, &types.Block{
+		BlockIdentifier:       block,
+		ParentBlockIdentifier: genesis.BlockIdentifier,
+		Transactions: []*types.Transaction{
+			{
+				TransactionIdentifier: &types.TransactionIdentifier{Hash: getBlockHash(1)},
+				Operations: []*types.Operation{
+					{
+						OperationIdentifier: &types.OperationIdentifier{Index: 0},
+						Type:                bitcoin.InputOpType,
+						Status:              types.String(bitcoin.SuccessStatus),
+						Account:             account,
+						Amount: &types.Amount{
+							Value:    "-10",
+							Currency: bitcoin.MainnetCurrency,
+						},
+						CoinChange: &types.CoinChange{
+							CoinIdentifier: coin,
+							CoinAction:     types.CoinSpent,
+						},
+					},
+					{
+						OperationIdentifier: &types.OperationIdentifier{Index: 1},
+						Type:                bitcoin.OutputOpType,
+						Status:              types.String(bitcoin.SuccessStatus),
+						Account:             other,
+						Amount: &types.Amount{
+							Value:    "10",
+							Currency: bitcoin.MainnetCurrency,
+						},
+						CoinChange: &types.CoinChange{
+							CoinIdentifier: &types.CoinIdentifier{
+								Identifier: fmt.Sprintf("%s:0", getBlockHash(1)),
+							},
+							CoinAction: types.CoinCreated,
+						},
+					},
+				},
+			},
+		},
+	}))
+
+	balance, err := i.balanceStorage.GetBalance(ctx, account, bitcoin.MainnetCurrency, 0)
+	assert.NoError(t, err)
+	assert.Equal(t, "10", balance.Value)
+
+	tombstone, err = i.ForgetAccount(ctx, account, bitcoin.MainnetCurrency)
+	assert.NoError(t, err)
+	assert.Equal(t, account.Address, tombstone.Address)
+	assert.Equal(t, block, tombstone.Head)
+
+	stored, err := i.AccountTombstone(ctx, account.Address)
+	assert.NoError(t, err)
+	assert.Equal(t, tombstone, stored)
+
+	stored, err = i.AccountTombstone(ctx, other.Address)
+	assert.NoError(t, err)
+	assert.Nil(t, stored)
+
+	// Historical balances are purged
+	balance, err = i.balanceStorage.GetBalance(ctx, account, bitcoin.MainnetCurrency, 0)
+	assert.NoError(t, err)
+	assert.Equal(t, "0", balance.Value)
+
+	// Accounts can't be forgotten twice
+	tombstone, err = i.ForgetAccount(ctx, account, bitcoin.MainnetCurrency)
+	assert.Nil(t, tombstone)
+	assert.True(t, errors.Is(err, ErrAccountForgotten))
+
+	// Forgotten accounts are skipped by reconciliation
+	accountCurrency := &types.AccountCurrency{
+		Account:  account,
+		Currency: bitcoin.MainnetCurrency,
+	}
+	err = i.reconcileAccount(ctx, accountCurrency)
+	assert.True(t, errors.Is(err, errAccountForgotten))
+
+	limiter := rate.NewLimiter(rate.Inf, 1)
+	reconciled, forgotten, err := i.reconcileBatch(
+		ctx,
+		[]*types.AccountCurrency{
+			accountCurrency,
+			{Account: other, Currency: bitcoin.MainnetCurrency},
+		},
+		limiter,
+		1,
+	)
+	assert.NoError(t, err)
+	assert.Equal(t, int64(1), reconciled)
+	assert.Equal(t, int64(1), forgotten)
+
+	// Indexed transactions are purged
+	dbTx := i.database.ReadTransaction(ctx)
+	assert.Equal(t, 0, countAddressTransactions(ctx, t, i, dbTx, account.Address))
+	assert.Equal(t, 1, countAddressTransactions(ctx, t, i, dbTx, other.Address))
+	dbTx.Discard(ctx)
+
+	// New activity removes the tombstone
+	assert.NoError(t, i.BlockAdded(ctx, &types.Block{
+		BlockIdentifier:       &types.BlockIdentifier{Index: 2, Hash: getBlockHash(2)},
+		ParentBlockIdentifier: block,
+		Transactions: []*types.Transaction{
+			{
+				TransactionIdentifier: &types.TransactionIdentifier{Hash: getBlockHash(2)},
+				Operations: []*types.Operation{
+					{
+						OperationIdentifier: &types.OperationIdentifier{Index: 0},
+						Type:                bitcoin.OutputOpType,
+						Status:              types.String(bitcoin.SuccessStatus),
+						Account:             account,
+						Amount: &types.Amount{
+							Value:    "5",
+							Currency: bitcoin.MainnetCurrency,
+						},
+						CoinChange: &types.CoinChange{
+							CoinIdentifier: &types.CoinIdentifier{
+								Identifier: fmt.Sprintf("%s:0", getBlockHash(2)),
+							},
+							CoinAction: types.CoinCreated,
+						},
+					},
+				},
+			},
+		},
+	}))
+
+	stored, err = i.AccountTombstone(ctx, account.Address)
+	assert.NoError(t, err)
+	assert.Nil(t, stored)
+
+	assert.NoError(t, i.reconcileAccount(ctx, accountCurrency))
+}
+
+func countAddressTransactions(
+	ctx context.Context,
+	t *testing.T,
+	i *Indexer,
+	dbTx database.Transaction,
+	address string,
+) int {
+	count := 0
+	assert.NoError(t, i.addressIndex.ScanTransactions(
+		ctx,
+		dbTx,
+		address,
+		nil,
+		func(*types.BlockIdentifier, *types.TransactionIdentifier) error {
+			count++
+			return nil
+		},
+	))
+
+	return count
+}
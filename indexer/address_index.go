@@ -0,0 +1,218 @@
+// Copyright 2020 Coinbase, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package indexer
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/coinbase/rosetta-sdk-go/storage/database"
+	"github.com/coinbase/rosetta-sdk-go/storage/modules"
+	"github.com/coinbase/rosetta-sdk-go/types"
+	"github.com/neilotoole/errgroup"
+)
+
+const (
+	// addressTransactionNamespace is the namespace used to
+	// store the transactions with an operation on each
+	// address (by block index and transaction hash).
+	addressTransactionNamespace = "address-tx"
+
+	// seekEnd is appended to a key to seek past all
+	// keys it prefixes when scanning in reverse.
+	seekEnd = "\xff"
+)
+
+var _ modules.BlockWorker = (*AddressIndexStorage)(nil)
+
+// AddressIndexStorage implements modules.BlockWorker to index
+// the transactions with an operation on each address, so that
+// the transactions of an address can be searched without
+// scanning every block. Entries are written in the same
+// database.Transaction as the block and removed if their block
+// is orphaned.
+type AddressIndexStorage struct {
+	db database.Database
+}
+
+// NewAddressIndexStorage returns a new *AddressIndexStorage.
+func NewAddressIndexStorage(db database.Database) *AddressIndexStorage {
+	return &AddressIndexStorage{
+		db: db,
+	}
+}
+
+func getAddressTransactionPrefix(address string) []byte {
+	return []byte(fmt.Sprintf("%s/%s/", addressTransactionNamespace, address))
+}
+
+func getAddressTransactionKey(address string, index int64, transactionHash string) []byte {
+	// Indexes are zero-padded so that scanning returns
+	// transactions in block order.
+	return []byte(fmt.Sprintf(
+		"%s/%s/%020d/%s",
+		addressTransactionNamespace,
+		address,
+		index,
+		transactionHash,
+	))
+}
+
+// blockAddressKeys returns the key of each (address,
+// transaction) pair with an operation in block.
+func blockAddressKeys(block *types.Block) [][]byte {
+	seen := map[string]struct{}{}
+	keys := [][]byte{}
+	for _, tx := range block.Transactions {
+		for _, op := range tx.Operations {
+			if op.Account == nil || len(op.Account.Address) == 0 {
+				continue
+			}
+
+			key := getAddressTransactionKey(
+				op.Account.Address,
+				block.BlockIdentifier.Index,
+				tx.TransactionIdentifier.Hash,
+			)
+			if _, ok := seen[string(key)]; ok {
+				continue
+			}
+
+			seen[string(key)] = struct{}{}
+			keys = append(keys, key)
+		}
+	}
+
+	return keys
+}
+
+// AddingBlock is called by BlockStorage when adding a block.
+func (a *AddressIndexStorage) AddingBlock(
+	ctx context.Context,
+	g *errgroup.Group,
+	block *types.Block,
+	dbTx database.Transaction,
+) (database.CommitWorker, error) {
+	for _, key := range blockAddressKeys(block) {
+		if err := dbTx.Set(ctx, key, []byte(block.BlockIdentifier.Hash), false); err != nil {
+			return nil, fmt.Errorf("%w: unable to store address index %s", err, string(key))
+		}
+	}
+
+	return nil, nil
+}
+
+// RemovingBlock is called by BlockStorage when removing a block.
+func (a *AddressIndexStorage) RemovingBlock(
+	ctx context.Context,
+	g *errgroup.Group,
+	block *types.Block,
+	dbTx database.Transaction,
+) (database.CommitWorker, error) {
+	for _, key := range blockAddressKeys(block) {
+		if err := dbTx.Delete(ctx, key); err != nil {
+			return nil, fmt.Errorf("%w: unable to delete address index %s", err, string(key))
+		}
+	}
+
+	return nil, nil
+}
+
+// AddressTransactionWorker is called with the block and
+// transaction identifier of each indexed transaction of
+// an address.
+type AddressTransactionWorker func(*types.BlockIdentifier, *types.TransactionIdentifier) error
+
+// ScanTransactions calls worker with each transaction with an
+// operation on address in a block at or below maxBlock (all
+// blocks if nil), from the most recent block to the oldest.
+func (a *AddressIndexStorage) ScanTransactions(
+	ctx context.Context,
+	dbTx database.Transaction,
+	address string,
+	maxBlock *int64,
+	worker AddressTransactionWorker,
+) error {
+	prefix := getAddressTransactionPrefix(address)
+	seekStart := append([]byte{}, prefix...)
+	if maxBlock != nil {
+		seekStart = append(seekStart, []byte(fmt.Sprintf("%020d/", *maxBlock))...)
+	}
+	seekStart = append(seekStart, []byte(seekEnd)...)
+
+	_, err := dbTx.Scan(
+		ctx,
+		prefix,
+		seekStart,
+		func(k []byte, v []byte) error {
+			components := strings.Split(strings.TrimPrefix(string(k), string(prefix)), namespaceSeparator)
+			if len(components) != 2 { // nolint:gomnd
+				return fmt.Errorf("malformed address index %s", string(k))
+			}
+
+			index, err := strconv.ParseInt(components[0], 10, 64)
+			if err != nil {
+				return fmt.Errorf("%w: malformed address index %s", err, string(k))
+			}
+
+			return worker(
+				&types.BlockIdentifier{Index: index, Hash: string(v)},
+				&types.TransactionIdentifier{Hash: components[1]},
+			)
+		},
+		false,
+		true,
+	)
+	if err != nil {
+		return fmt.Errorf("%w: unable to scan transactions of %s", err, address)
+	}
+
+	return nil
+}
+
+// PurgeAddress removes all indexed transactions of
+// address in dbTx and returns the number removed.
+func (a *AddressIndexStorage) PurgeAddress(
+	ctx context.Context,
+	dbTx database.Transaction,
+	address string,
+) (int, error) {
+	prefix := getAddressTransactionPrefix(address)
+	keys := [][]byte{}
+	_, err := dbTx.Scan(
+		ctx,
+		prefix,
+		prefix,
+		func(k []byte, v []byte) error {
+			keys = append(keys, append([]byte{}, k...))
+			return nil
+		},
+		false,
+		false,
+	)
+	if err != nil {
+		return -1, fmt.Errorf("%w: unable to scan transactions of %s", err, address)
+	}
+
+	for _, key := range keys {
+		if err := dbTx.Delete(ctx, key); err != nil {
+			return -1, fmt.Errorf("%w: unable to delete address index %s", err, string(key))
+		}
+	}
+
+	return len(keys), nil
+}
@@ -0,0 +1,140 @@
+// Copyright 2020 Coinbase, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package indexer
+
+import (
+	"context"
+	"fmt"
+	"testing"
+
+	"github.com/MNtank/rosetta-bitcoin/bitcoin"
+	"github.com/MNtank/rosetta-bitcoin/configuration"
+
+	"github.com/coinbase/rosetta-sdk-go/types"
+	"github.com/coinbase/rosetta-sdk-go/utils"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestIndexer_HistoricalBalance(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	newDir, err := utils.CreateTempDir()
+	assert.NoError(t, err)
+	defer utils.RemoveTempDir(newDir)
+
+	cfg := &configuration.Configuration{
+		Network: &types.NetworkIdentifier{
+			Network:    bitcoin.MainnetNetwork,
+			Blockchain: bitcoin.Blockchain,
+		},
+		GenesisBlockIdentifier: bitcoin.MainnetGenesisBlockIdentifier,
+		IndexerPath:            newDir,
+	}
+
+	i, err := Initialize(ctx, cancel, cfg, nil)
+	assert.NoError(t, err)
+	defer i.CloseDatabase(ctx)
+
+	// The balance of the account changes by
+	// deltas[j] in block j
+	account := &types.AccountIdentifier{Address: "addr1"}
+	deltas := []int64{0, 100, 0, -40, 15}
+	i.blockStorage.Initialize(i.workers)
+	for j, delta := range deltas {
+		index := int64(j)
+		block := &types.Block{
+			BlockIdentifier: &types.BlockIdentifier{
+				Index: index,
+				Hash:  getBlockHash(index),
+			},
+			ParentBlockIdentifier: &types.BlockIdentifier{
+				Index: index - 1,
+				Hash:  getBlockHash(index - 1),
+			},
+			Transactions: []*types.Transaction{},
+		}
+		if index == 0 {
+			block.ParentBlockIdentifier = block.BlockIdentifier
+		}
+
+		if delta != 0 {
+			block.Transactions = append(block.Transactions, &types.Transaction{
+				TransactionIdentifier: &types.TransactionIdentifier{
+					Hash: fmt.Sprintf("tx %d", index),
+				},
+				Operations: []*types.Operation{
+					{
+						OperationIdentifier: &types.OperationIdentifier{Index: 0},
+						Type:                bitcoin.OutputOpType,
+						Status:              types.String(bitcoin.SuccessStatus),
+						Account:             account,
+						Amount: &types.Amount{
+							Value:    fmt.Sprintf("%d", delta),
+							Currency: bitcoin.MainnetCurrency,
+						},
+					},
+				},
+			})
+		}
+
+		assert.NoError(t, i.blockStorage.SeeBlock(ctx, block))
+		assert.NoError(t, i.blockStorage.AddBlock(ctx, block))
+	}
+
+	tests := map[string]struct {
+		block   *types.PartialBlockIdentifier
+		index   int64
+		balance string
+	}{
+		"tip": {
+			index:   4,
+			balance: "75",
+		},
+		"before first change": {
+			block:   &types.PartialBlockIdentifier{Index: types.Int64(0)},
+			index:   0,
+			balance: "0",
+		},
+		"between changes": {
+			block:   &types.PartialBlockIdentifier{Index: types.Int64(2)},
+			index:   2,
+			balance: "100",
+		},
+		"by hash": {
+			block:   &types.PartialBlockIdentifier{Hash: types.String(getBlockHash(3))},
+			index:   3,
+			balance: "60",
+		},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			amount, block, err := i.GetBalance(ctx, account, bitcoin.MainnetCurrency, test.block)
+			assert.NoError(t, err)
+			assert.Equal(t, test.index, block.Index)
+			assert.Equal(t, test.balance, amount.Value)
+		})
+	}
+
+	// Blocks that are not indexed yet can't be queried
+	_, _, err = i.GetBalance(
+		ctx,
+		account,
+		bitcoin.MainnetCurrency,
+		&types.PartialBlockIdentifier{Index: types.Int64(5)},
+	)
+	assert.Error(t, err)
+}
@@ -0,0 +1,142 @@
+// Copyright 2020 Coinbase, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package indexer
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/MNtank/rosetta-bitcoin/bitcoin"
+	"github.com/MNtank/rosetta-bitcoin/utils"
+
+	"github.com/coinbase/rosetta-sdk-go/types"
+	sdkUtils "github.com/coinbase/rosetta-sdk-go/utils"
+)
+
+// chainRequirements returns the chain requirements
+// of the network (false if there are none).
+func (i *Indexer) chainRequirements() (*bitcoin.ChainRequirements, bool) {
+	if i.params == nil {
+		return nil, false
+	}
+
+	params, err := bitcoin.ChainParamsForNet(i.params.Net)
+	if err != nil || params.ChainRequirements == nil {
+		return nil, false
+	}
+
+	return params.ChainRequirements, true
+}
+
+// waitForChainRequirements blocks until the best chain of
+// the node meets the chain requirements of the network (i.e.
+// while the node is syncing). Once it includes the assume
+// valid block, the block is loaded as a checkpoint.
+func (i *Indexer) waitForChainRequirements(ctx context.Context) error {
+	requirements, ok := i.chainRequirements()
+	if !ok {
+		return nil
+	}
+
+	logger := utils.ExtractLogger(ctx, "indexer")
+	for {
+		err := i.checkChainRequirements(ctx, requirements)
+		if err == nil {
+			return nil
+		}
+
+		// A conflicting block was already indexed, so
+		// waiting for the node won't resolve it.
+		if errors.Is(err, ErrCheckpointMismatch) {
+			return err
+		}
+
+		logger.Warnw("waiting for node to meet chain requirements", "error", err)
+		if err := sdkUtils.ContextSleep(ctx, nodeWaitSleep); err != nil {
+			return err
+		}
+	}
+}
+
+// checkChainRequirements returns an error if the best
+// chain of the node does not meet requirements.
+func (i *Indexer) checkChainRequirements(
+	ctx context.Context,
+	requirements *bitcoin.ChainRequirements,
+) error {
+	status, err := i.client.NetworkStatus(ctx)
+	if err != nil {
+		return fmt.Errorf("%w: unable to get network status", err)
+	}
+
+	if err := i.checkTipWork(ctx, status.CurrentBlockIdentifier); err != nil {
+		return err
+	}
+
+	if requirements.AssumeValidHash == nil {
+		return nil
+	}
+
+	hash := requirements.AssumeValidHash.String()
+	block, _, err := i.client.GetRawBlock(ctx, &types.PartialBlockIdentifier{Hash: &hash})
+	if err != nil {
+		return fmt.Errorf("%w: block %s: %s", bitcoin.ErrMissingAssumeValid, hash, err.Error())
+	}
+
+	// The node returns blocks it knows of that are
+	// not in its best chain, so the block at the
+	// height of the assume valid block is compared.
+	canonical, _, err := i.client.GetRawBlock(
+		ctx,
+		&types.PartialBlockIdentifier{Index: &block.Height},
+	)
+	if err != nil {
+		return fmt.Errorf("%w: unable to get block %d", err, block.Height)
+	}
+
+	if canonical.Hash != hash {
+		return fmt.Errorf(
+			"%w: block %d has hash %s but assume valid block is %s",
+			bitcoin.ErrMissingAssumeValid,
+			block.Height,
+			canonical.Hash,
+			hash,
+		)
+	}
+
+	return i.LoadCheckpoint(ctx, &types.BlockIdentifier{Index: block.Height, Hash: hash})
+}
+
+// checkTipWork returns ErrInsufficientChainWork if tip has
+// less work than the minimum chain work of the network.
+func (i *Indexer) checkTipWork(ctx context.Context, tip *types.BlockIdentifier) error {
+	requirements, ok := i.chainRequirements()
+	if !ok || requirements.MinimumChainWork == nil || tip.Hash == i.workVerifiedTip {
+		return nil
+	}
+
+	block, _, err := i.client.GetRawBlock(ctx, &types.PartialBlockIdentifier{Hash: &tip.Hash})
+	if err != nil {
+		return fmt.Errorf("%w: unable to get block %s", err, tip.Hash)
+	}
+
+	if err := requirements.CheckChainWork(block); err != nil {
+		return err
+	}
+
+	i.workVerifiedTip = tip.Hash
+	return nil
+}
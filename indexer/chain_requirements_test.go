@@ -0,0 +1,142 @@
+// Copyright 2020 Coinbase, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package indexer
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math/big"
+	"testing"
+
+	"github.com/MNtank/rosetta-bitcoin/bitcoin"
+	"github.com/MNtank/rosetta-bitcoin/configuration"
+	mocks "github.com/MNtank/rosetta-bitcoin/mocks/indexer"
+
+	"github.com/btcsuite/btcd/chaincfg/chainhash"
+	"github.com/coinbase/rosetta-sdk-go/types"
+	"github.com/coinbase/rosetta-sdk-go/utils"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+func TestChainRequirements(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	newDir, err := utils.CreateTempDir()
+	assert.NoError(t, err)
+	defer utils.RemoveTempDir(newDir)
+
+	params := bitcoin.CloneParams(bitcoin.MainnetParams)
+	params.Net = 0xabcdef01
+
+	hash := func(index int64) string {
+		return fmt.Sprintf("%064x", index)
+	}
+
+	assumeValid, err := chainhash.NewHashFromStr(hash(2))
+	assert.NoError(t, err)
+	assert.NoError(t, bitcoin.RegisterChainParams(&bitcoin.ChainParams{
+		Params: params,
+		ChainRequirements: &bitcoin.ChainRequirements{
+			MinimumChainWork: big.NewInt(0x100),
+			AssumeValidHash:  assumeValid,
+		},
+	}))
+	defer bitcoin.ResetRegistry()
+
+	cfg := &configuration.Configuration{
+		Network: &types.NetworkIdentifier{
+			Network:    bitcoin.MainnetNetwork,
+			Blockchain: bitcoin.Blockchain,
+		},
+		GenesisBlockIdentifier: bitcoin.MainnetGenesisBlockIdentifier,
+		Params:                 params,
+		IndexerPath:            newDir,
+	}
+
+	mockClient := &mocks.Client{}
+	i, err := Initialize(ctx, cancel, cfg, mockClient)
+	assert.NoError(t, err)
+	defer i.CloseDatabase(ctx)
+	i.blockStorage.Initialize(i.workers)
+
+	// mockTip makes the block at index (with
+	// chainWork) the tip of the node.
+	mockTip := func(index int64, chainWork string) {
+		mockClient.On("NetworkStatus", mock.Anything).Return(&types.NetworkStatusResponse{
+			CurrentBlockIdentifier: &types.BlockIdentifier{Index: index, Hash: hash(index)},
+		}, nil).Once()
+		mockClient.On(
+			"GetRawBlock",
+			mock.Anything,
+			&types.PartialBlockIdentifier{Hash: types.String(hash(index))},
+		).Return(
+			&bitcoin.Block{Hash: hash(index), Height: index, ChainWork: chainWork},
+			[]string{},
+			nil,
+		).Once()
+	}
+
+	requirements, ok := i.chainRequirements()
+	assert.True(t, ok)
+
+	// The node is still syncing
+	mockTip(1, "ff")
+	err = i.checkChainRequirements(ctx, requirements)
+	assert.True(t, errors.Is(err, bitcoin.ErrInsufficientChainWork))
+
+	// The node is on a chain without the assume valid block
+	mockTip(3, "0200")
+	mockClient.On(
+		"GetRawBlock",
+		mock.Anything,
+		&types.PartialBlockIdentifier{Hash: types.String(hash(2))},
+	).Return(&bitcoin.Block{Hash: hash(2), Height: 2}, []string{}, nil)
+	mockClient.On(
+		"GetRawBlock",
+		mock.Anything,
+		&types.PartialBlockIdentifier{Index: types.Int64(2)},
+	).Return(&bitcoin.Block{Hash: "fork", Height: 2}, []string{}, nil).Once()
+	err = i.checkChainRequirements(ctx, requirements)
+	assert.True(t, errors.Is(err, bitcoin.ErrMissingAssumeValid))
+
+	// The node is on a chain with the assume valid block,
+	// which is loaded as a checkpoint.
+	mockTip(4, "0300")
+	mockClient.On(
+		"GetRawBlock",
+		mock.Anything,
+		&types.PartialBlockIdentifier{Index: types.Int64(2)},
+	).Return(&bitcoin.Block{Hash: hash(2), Height: 2}, []string{}, nil).Once()
+	assert.NoError(t, i.waitForChainRequirements(ctx))
+	assert.True(t, errors.Is(i.checkpoints.check(2, "fork"), ErrCheckpointMismatch))
+
+	// The tip is verified once
+	mockClient.On("NetworkStatus", mock.Anything).Return(&types.NetworkStatusResponse{
+		CurrentBlockIdentifier: &types.BlockIdentifier{Index: 4, Hash: hash(4)},
+	}, nil).Once()
+	status, err := i.NetworkStatus(ctx, cfg.Network)
+	assert.NoError(t, err)
+	assert.Equal(t, hash(4), status.CurrentBlockIdentifier.Hash)
+
+	// The syncer refuses to follow a low-work chain
+	mockTip(5, "10")
+	_, err = i.NetworkStatus(ctx, cfg.Network)
+	assert.True(t, errors.Is(err, bitcoin.ErrInsufficientChainWork))
+
+	mockClient.AssertExpectations(t)
+}
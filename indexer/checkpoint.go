@@ -0,0 +1,501 @@
+// Copyright 2020 Coinbase, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package indexer
+
+import (
+	"context"
+	"crypto/ed25519"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/MNtank/rosetta-bitcoin/bitcoin"
+	"github.com/MNtank/rosetta-bitcoin/utils"
+
+	storageErrs "github.com/coinbase/rosetta-sdk-go/storage/errors"
+	"github.com/coinbase/rosetta-sdk-go/types"
+	sdkUtils "github.com/coinbase/rosetta-sdk-go/utils"
+)
+
+const (
+	// checkpointPermissions are the permissions
+	// of a published checkpoint file.
+	checkpointPermissions = 0644
+
+	// checkpointFetchTimeout is the maximum amount of
+	// time to wait for a checkpoint feed URL to respond.
+	checkpointFetchTimeout = 30 * time.Second
+
+	// checkpointFetchMaxBytes is the largest response
+	// read from a checkpoint feed URL.
+	checkpointFetchMaxBytes = 1 << 20
+)
+
+var (
+	// ErrCheckpointSignatureInvalid is returned when a
+	// checkpoint was not signed by the trusted key.
+	ErrCheckpointSignatureInvalid = errors.New("checkpoint signature is invalid")
+
+	// ErrInvalidCheckpoint is returned when loaded
+	// checkpoints are not above the checkpoints of
+	// the network (or not ordered by index).
+	ErrInvalidCheckpoint = errors.New("invalid checkpoint")
+
+	// ErrCheckpointMismatch is returned when a block
+	// conflicts with a loaded checkpoint (i.e. the node
+	// is following a fork). It is the error returned
+	// for blocks that conflict with the hard-coded
+	// checkpoints of the network.
+	ErrCheckpointMismatch = bitcoin.ErrCheckpointMismatch
+)
+
+// Checkpoint is a block identifier of a network
+// attested to (signed) by a trusted instance.
+type Checkpoint struct {
+	NetworkIdentifier *types.NetworkIdentifier `json:"network_identifier"`
+	BlockIdentifier   *types.BlockIdentifier   `json:"block_identifier"`
+	Timestamp         int64                    `json:"timestamp"`
+	Signature         string                   `json:"signature"`
+}
+
+// checkpointMessage returns the bytes signed for a
+// *Checkpoint (including its network, so that a key
+// shared by several networks can't attest to a block
+// of one network on another).
+func checkpointMessage(checkpoint *Checkpoint) []byte {
+	return []byte(fmt.Sprintf(
+		"%s:%s:%d:%s:%d",
+		checkpoint.NetworkIdentifier.Blockchain,
+		checkpoint.NetworkIdentifier.Network,
+		checkpoint.BlockIdentifier.Index,
+		checkpoint.BlockIdentifier.Hash,
+		checkpoint.Timestamp,
+	))
+}
+
+// SignCheckpoint returns a *Checkpoint of blockIdentifier
+// on network signed with key.
+func SignCheckpoint(
+	key ed25519.PrivateKey,
+	network *types.NetworkIdentifier,
+	blockIdentifier *types.BlockIdentifier,
+	timestamp int64,
+) *Checkpoint {
+	checkpoint := &Checkpoint{
+		NetworkIdentifier: network,
+		BlockIdentifier:   blockIdentifier,
+		Timestamp:         timestamp,
+	}
+	checkpoint.Signature = hex.EncodeToString(
+		ed25519.Sign(key, checkpointMessage(checkpoint)),
+	)
+
+	return checkpoint
+}
+
+// VerifyCheckpoint returns an error if checkpoint was
+// not signed by the holder of publicKey for network.
+func VerifyCheckpoint(
+	publicKey ed25519.PublicKey,
+	network *types.NetworkIdentifier,
+	checkpoint *Checkpoint,
+) error {
+	if checkpoint.BlockIdentifier == nil {
+		return fmt.Errorf("%w: missing block identifier", ErrCheckpointSignatureInvalid)
+	}
+
+	if checkpoint.NetworkIdentifier == nil {
+		return fmt.Errorf("%w: missing network identifier", ErrCheckpointSignatureInvalid)
+	}
+
+	if types.Hash(checkpoint.NetworkIdentifier) != types.Hash(network) {
+		return fmt.Errorf(
+			"%w: checkpoint is for network %s",
+			ErrCheckpointSignatureInvalid,
+			types.PrintStruct(checkpoint.NetworkIdentifier),
+		)
+	}
+
+	signature, err := hex.DecodeString(checkpoint.Signature)
+	if err != nil {
+		return fmt.Errorf("%w: %v", ErrCheckpointSignatureInvalid, err)
+	}
+
+	if !ed25519.Verify(publicKey, checkpointMessage(checkpoint), signature) {
+		return ErrCheckpointSignatureInvalid
+	}
+
+	return nil
+}
+
+// checkpointTable stores the hash of each
+// loaded checkpoint (keyed by index).
+type checkpointTable struct {
+	mutex       sync.Mutex
+	checkpoints map[int64]string
+}
+
+func newCheckpointTable() *checkpointTable {
+	return &checkpointTable{
+		checkpoints: map[int64]string{},
+	}
+}
+
+// add stores a checkpoint and returns true
+// if it was not previously loaded.
+func (c *checkpointTable) add(blockIdentifier *types.BlockIdentifier) bool {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	if _, ok := c.checkpoints[blockIdentifier.Index]; ok {
+		return false
+	}
+
+	c.checkpoints[blockIdentifier.Index] = blockIdentifier.Hash
+	return true
+}
+
+// check returns ErrCheckpointMismatch if a checkpoint
+// exists at index with a different hash.
+func (c *checkpointTable) check(index int64, hash string) error {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	expected, ok := c.checkpoints[index]
+	if !ok || expected == hash {
+		return nil
+	}
+
+	return fmt.Errorf(
+		"%w: block %d has hash %s but checkpoint has %s",
+		ErrCheckpointMismatch,
+		index,
+		hash,
+		expected,
+	)
+}
+
+// LoadCheckpoint is the checkpoint-loading hook. Once a
+// checkpoint is loaded, the indexer refuses to process any
+// block at the checkpoint index with a different hash. An
+// error is returned if a conflicting block was already
+// added to storage.
+func (i *Indexer) LoadCheckpoint(
+	ctx context.Context,
+	blockIdentifier *types.BlockIdentifier,
+) error {
+	if !i.checkpoints.add(blockIdentifier) {
+		return i.checkpoints.check(blockIdentifier.Index, blockIdentifier.Hash)
+	}
+
+	utils.ExtractLogger(ctx, "checkpoint").Infow(
+		"loaded checkpoint",
+		"index", blockIdentifier.Index,
+		"hash", blockIdentifier.Hash,
+	)
+
+	block, err := i.blockStorage.GetBlockLazy(
+		ctx,
+		&types.PartialBlockIdentifier{Index: &blockIdentifier.Index},
+	)
+	if errors.Is(err, storageErrs.ErrBlockNotFound) {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("%w: unable to get block %d", err, blockIdentifier.Index)
+	}
+
+	return i.checkpoints.check(
+		blockIdentifier.Index,
+		block.Block.BlockIdentifier.Hash,
+	)
+}
+
+// PublishCheckpoints periodically signs the block depth
+// blocks below the current head (so that it is unlikely to
+// be orphaned) and atomically writes it to path (which can
+// be served to other instances over HTTP).
+func (i *Indexer) PublishCheckpoints(
+	ctx context.Context,
+	path string,
+	key ed25519.PrivateKey,
+	depth int64,
+	interval time.Duration,
+) error {
+	logger := utils.ExtractLogger(ctx, "checkpoint")
+	for ctx.Err() == nil {
+		checkpoint, err := i.latestCheckpoint(ctx, key, depth)
+		if err != nil {
+			return err
+		}
+
+		if checkpoint != nil {
+			if err := writeCheckpoint(path, checkpoint); err != nil {
+				return err
+			}
+
+			logger.Debugw(
+				"published checkpoint",
+				"path", path,
+				"index", checkpoint.BlockIdentifier.Index,
+				"hash", checkpoint.BlockIdentifier.Hash,
+			)
+		}
+
+		if err := sdkUtils.ContextSleep(ctx, interval); err != nil {
+			return err
+		}
+	}
+
+	return ctx.Err()
+}
+
+// latestCheckpoint returns a signed *Checkpoint of the
+// block depth blocks below the head (or nil if there
+// is no such block yet).
+func (i *Indexer) latestCheckpoint(
+	ctx context.Context,
+	key ed25519.PrivateKey,
+	depth int64,
+) (*Checkpoint, error) {
+	head, err := i.blockStorage.GetHeadBlockIdentifier(ctx)
+	if errors.Is(err, storageErrs.ErrHeadBlockNotFound) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("%w: unable to get head block", err)
+	}
+
+	index := head.Index - depth
+	if index < 0 {
+		return nil, nil
+	}
+
+	block, err := i.blockStorage.GetBlockLazy(
+		ctx,
+		&types.PartialBlockIdentifier{Index: &index},
+	)
+	if err != nil {
+		return nil, fmt.Errorf("%w: unable to get block %d", err, index)
+	}
+
+	return SignCheckpoint(key, i.network, block.Block.BlockIdentifier, time.Now().Unix()), nil
+}
+
+// writeCheckpoint atomically replaces the
+// checkpoint at path.
+func writeCheckpoint(path string, checkpoint *Checkpoint) error {
+	contents, err := json.Marshal(checkpoint)
+	if err != nil {
+		return fmt.Errorf("%w: unable to marshal checkpoint", err)
+	}
+
+	f, err := ioutil.TempFile(filepath.Dir(path), filepath.Base(path))
+	if err != nil {
+		return fmt.Errorf("%w: unable to create checkpoint file", err)
+	}
+	defer os.Remove(f.Name())
+
+	if _, err := f.Write(contents); err != nil {
+		_ = f.Close()
+		return fmt.Errorf("%w: unable to write checkpoint", err)
+	}
+
+	if err := f.Close(); err != nil {
+		return fmt.Errorf("%w: unable to close checkpoint file", err)
+	}
+
+	if err := os.Chmod(f.Name(), checkpointPermissions); err != nil {
+		return fmt.Errorf("%w: unable to set checkpoint permissions", err)
+	}
+
+	if err := os.Rename(f.Name(), path); err != nil {
+		return fmt.Errorf("%w: unable to publish checkpoint to %s", err, path)
+	}
+
+	return nil
+}
+
+// FetchCheckpoint reads a *Checkpoint of network from feed
+// (a file path or an http(s) URL) and verifies its signature.
+func FetchCheckpoint(
+	ctx context.Context,
+	feed string,
+	network *types.NetworkIdentifier,
+	publicKey ed25519.PublicKey,
+) (*Checkpoint, error) {
+	contents, err := readFeed(ctx, feed)
+	if err != nil {
+		return nil, fmt.Errorf("%w: unable to read checkpoint feed %s", err, feed)
+	}
+
+	var checkpoint Checkpoint
+	if err := json.Unmarshal(contents, &checkpoint); err != nil {
+		return nil, fmt.Errorf("%w: unable to parse checkpoint from %s", err, feed)
+	}
+
+	if err := VerifyCheckpoint(publicKey, network, &checkpoint); err != nil {
+		return nil, err
+	}
+
+	return &checkpoint, nil
+}
+
+// readFeed returns the contents of feed
+// (a file path or an http(s) URL).
+func readFeed(ctx context.Context, feed string) ([]byte, error) {
+	if strings.HasPrefix(feed, "http://") || strings.HasPrefix(feed, "https://") {
+		return fetchURL(ctx, feed)
+	}
+
+	return ioutil.ReadFile(feed) // #nosec G304
+}
+
+// FetchCheckpoints reads checkpoints of network signed by
+// the trusted instance (a JSON array of *Checkpoint, as
+// published by PublishCheckpoints) from source (a file path
+// or an http(s) URL) and verifies their signatures.
+func FetchCheckpoints(
+	ctx context.Context,
+	source string,
+	network *types.NetworkIdentifier,
+	publicKey ed25519.PublicKey,
+) ([]*Checkpoint, error) {
+	contents, err := readFeed(ctx, source)
+	if err != nil {
+		return nil, fmt.Errorf("%w: unable to read checkpoints from %s", err, source)
+	}
+
+	var checkpoints []*Checkpoint
+	if err := json.Unmarshal(contents, &checkpoints); err != nil {
+		return nil, fmt.Errorf("%w: unable to parse checkpoints from %s", err, source)
+	}
+
+	for _, checkpoint := range checkpoints {
+		if err := VerifyCheckpoint(publicKey, network, checkpoint); err != nil {
+			return nil, fmt.Errorf("%w: %s", err, source)
+		}
+	}
+
+	return checkpoints, nil
+}
+
+// LoadCheckpoints loads checkpoints (ordered by index, see
+// LoadCheckpoint), hardening a long-running instance against
+// deep reorgs without rebuilding it. Checkpoints must be above
+// the checkpoints of the network, and none are loaded if any
+// is not. An error is returned if a conflicting block was
+// already added to storage.
+func (i *Indexer) LoadCheckpoints(ctx context.Context, checkpoints []*Checkpoint) error {
+	index := int64(-1)
+	if latest := bitcoin.LatestCheckpoint(i.params); latest != nil {
+		index = int64(latest.Height)
+	}
+
+	for _, checkpoint := range checkpoints {
+		if checkpoint.BlockIdentifier.Index <= index {
+			return fmt.Errorf(
+				"%w: checkpoint %d is not above checkpoint %d",
+				ErrInvalidCheckpoint,
+				checkpoint.BlockIdentifier.Index,
+				index,
+			)
+		}
+		index = checkpoint.BlockIdentifier.Index
+	}
+
+	for _, checkpoint := range checkpoints {
+		if err := i.LoadCheckpoint(ctx, checkpoint.BlockIdentifier); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// fetchURL returns the body (of at most
+// checkpointFetchMaxBytes) of a successful
+// GET request to url.
+func fetchURL(ctx context.Context, url string) ([]byte, error) {
+	ctx, cancel := context.WithTimeout(ctx, checkpointFetchTimeout)
+	defer cancel()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("unexpected status %d", resp.StatusCode)
+	}
+
+	contents, err := ioutil.ReadAll(io.LimitReader(resp.Body, checkpointFetchMaxBytes+1))
+	if err != nil {
+		return nil, err
+	}
+
+	if len(contents) > checkpointFetchMaxBytes {
+		return nil, fmt.Errorf("response exceeds %d bytes", checkpointFetchMaxBytes)
+	}
+
+	return contents, nil
+}
+
+// FollowCheckpoints periodically fetches the checkpoint
+// published by a trusted instance at feed and loads it
+// (see LoadCheckpoint). Feeds that are temporarily
+// unavailable (or provide an invalid checkpoint) are
+// logged and retried, however, an error is returned if
+// a stored block conflicts with a checkpoint.
+func (i *Indexer) FollowCheckpoints(
+	ctx context.Context,
+	feed string,
+	publicKey ed25519.PublicKey,
+	interval time.Duration,
+) error {
+	logger := utils.ExtractLogger(ctx, "checkpoint")
+	for ctx.Err() == nil {
+		checkpoint, err := FetchCheckpoint(ctx, feed, i.network, publicKey)
+		switch {
+		case err != nil && ctx.Err() == nil:
+			logger.Warnw("unable to fetch checkpoint", "feed", feed, "error", err)
+		case err == nil:
+			if err := i.LoadCheckpoint(ctx, checkpoint.BlockIdentifier); err != nil {
+				return err
+			}
+		}
+
+		if err := sdkUtils.ContextSleep(ctx, interval); err != nil {
+			return err
+		}
+	}
+
+	return ctx.Err()
+}
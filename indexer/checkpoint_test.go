@@ -0,0 +1,366 @@
+// Copyright 2020 Coinbase, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package indexer
+
+import (
+	"context"
+	"crypto/ed25519"
+	"crypto/rand"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"path"
+	"testing"
+
+	"github.com/MNtank/rosetta-bitcoin/bitcoin"
+	"github.com/MNtank/rosetta-bitcoin/configuration"
+	mocks "github.com/MNtank/rosetta-bitcoin/mocks/indexer"
+
+	"github.com/btcsuite/btcd/chaincfg"
+	"github.com/btcsuite/btcd/chaincfg/chainhash"
+	"github.com/coinbase/rosetta-sdk-go/types"
+	"github.com/coinbase/rosetta-sdk-go/utils"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+func TestCheckpointSignature(t *testing.T) {
+	publicKey, privateKey, err := ed25519.GenerateKey(rand.Reader)
+	assert.NoError(t, err)
+
+	otherKey, _, err := ed25519.GenerateKey(rand.Reader)
+	assert.NoError(t, err)
+
+	mainnet := &types.NetworkIdentifier{
+		Blockchain: bitcoin.Blockchain,
+		Network:    bitcoin.MainnetNetwork,
+	}
+	testnet := &types.NetworkIdentifier{
+		Blockchain: bitcoin.Blockchain,
+		Network:    bitcoin.TestnetNetwork,
+	}
+
+	checkpoint := SignCheckpoint(privateKey, mainnet, &types.BlockIdentifier{
+		Index: 100,
+		Hash:  getBlockHash(100),
+	}, 1000)
+	assert.NoError(t, VerifyCheckpoint(publicKey, mainnet, checkpoint))
+	assert.True(t, errors.Is(
+		VerifyCheckpoint(otherKey, mainnet, checkpoint),
+		ErrCheckpointSignatureInvalid,
+	))
+
+	// Checkpoints of another network are rejected
+	assert.True(t, errors.Is(
+		VerifyCheckpoint(publicKey, testnet, checkpoint),
+		ErrCheckpointSignatureInvalid,
+	))
+
+	tampered := *checkpoint
+	tampered.NetworkIdentifier = testnet
+	assert.True(t, errors.Is(
+		VerifyCheckpoint(publicKey, testnet, &tampered),
+		ErrCheckpointSignatureInvalid,
+	))
+
+	tampered = *checkpoint
+	tampered.NetworkIdentifier = nil
+	assert.True(t, errors.Is(
+		VerifyCheckpoint(publicKey, mainnet, &tampered),
+		ErrCheckpointSignatureInvalid,
+	))
+
+	tampered = *checkpoint
+	tampered.BlockIdentifier = &types.BlockIdentifier{
+		Index: 100,
+		Hash:  getBlockHash(101),
+	}
+	assert.True(t, errors.Is(
+		VerifyCheckpoint(publicKey, mainnet, &tampered),
+		ErrCheckpointSignatureInvalid,
+	))
+
+	tampered = *checkpoint
+	tampered.Signature = "blah"
+	assert.True(t, errors.Is(
+		VerifyCheckpoint(publicKey, mainnet, &tampered),
+		ErrCheckpointSignatureInvalid,
+	))
+}
+
+func TestCheckpoints(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	newDir, err := utils.CreateTempDir()
+	assert.NoError(t, err)
+	defer utils.RemoveTempDir(newDir)
+
+	cfg := &configuration.Configuration{
+		Network: &types.NetworkIdentifier{
+			Network:    bitcoin.MainnetNetwork,
+			Blockchain: bitcoin.Blockchain,
+		},
+		GenesisBlockIdentifier: bitcoin.MainnetGenesisBlockIdentifier,
+		IndexerPath:            newDir,
+	}
+
+	i, err := Initialize(ctx, cancel, cfg, nil)
+	assert.NoError(t, err)
+	defer i.CloseDatabase(ctx)
+
+	publicKey, privateKey, err := ed25519.GenerateKey(rand.Reader)
+	assert.NoError(t, err)
+
+	// Nothing to publish before any blocks are added
+	checkpoint, err := i.latestCheckpoint(ctx, privateKey, 2)
+	assert.NoError(t, err)
+	assert.Nil(t, checkpoint)
+
+	i.blockStorage.Initialize(i.workers)
+	for j := int64(0); j < 5; j++ {
+		block := &types.Block{
+			BlockIdentifier: &types.BlockIdentifier{
+				Index: j,
+				Hash:  getBlockHash(j),
+			},
+			ParentBlockIdentifier: &types.BlockIdentifier{
+				Index: j - 1,
+				Hash:  getBlockHash(j - 1),
+			},
+		}
+		if j == 0 {
+			block.ParentBlockIdentifier = block.BlockIdentifier
+		}
+
+		assert.NoError(t, i.blockStorage.SeeBlock(ctx, block))
+		assert.NoError(t, i.blockStorage.AddBlock(ctx, block))
+	}
+
+	// Publish to a file
+	checkpoint, err = i.latestCheckpoint(ctx, privateKey, 2)
+	assert.NoError(t, err)
+	assert.Equal(t, &types.BlockIdentifier{
+		Index: 2,
+		Hash:  getBlockHash(2),
+	}, checkpoint.BlockIdentifier)
+
+	feed := path.Join(newDir, "checkpoint.json")
+	assert.NoError(t, writeCheckpoint(feed, checkpoint))
+
+	fetched, err := FetchCheckpoint(ctx, feed, cfg.Network, publicKey)
+	assert.NoError(t, err)
+	assert.Equal(t, checkpoint, fetched)
+
+	// Serve the file over HTTP
+	contents, err := ioutil.ReadFile(feed)
+	assert.NoError(t, err)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write(contents)
+	}))
+	defer server.Close()
+
+	fetched, err = FetchCheckpoint(ctx, server.URL, cfg.Network, publicKey)
+	assert.NoError(t, err)
+	assert.Equal(t, checkpoint, fetched)
+
+	// Reject oversized responses
+	oversized := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write(make([]byte, checkpointFetchMaxBytes+1))
+	}))
+	defer oversized.Close()
+
+	_, err = FetchCheckpoint(ctx, oversized.URL, cfg.Network, publicKey)
+	assert.Error(t, err)
+
+	// Reject checkpoints signed by another key
+	otherKey, _, err := ed25519.GenerateKey(rand.Reader)
+	assert.NoError(t, err)
+	_, err = FetchCheckpoint(ctx, feed, cfg.Network, otherKey)
+	assert.True(t, errors.Is(err, ErrCheckpointSignatureInvalid))
+
+	// Load matching checkpoint
+	assert.NoError(t, i.LoadCheckpoint(ctx, fetched.BlockIdentifier))
+	assert.NoError(t, i.checkpoints.check(2, getBlockHash(2)))
+	assert.True(t, errors.Is(i.checkpoints.check(2, getBlockHash(3)), ErrCheckpointMismatch))
+
+	// Load checkpoint above the head
+	assert.NoError(t, i.LoadCheckpoint(ctx, &types.BlockIdentifier{
+		Index: 10,
+		Hash:  getBlockHash(10),
+	}))
+	assert.True(t, errors.Is(i.checkpoints.check(10, "fork"), ErrCheckpointMismatch))
+
+	// Load checkpoint conflicting with a stored block
+	err = i.LoadCheckpoint(ctx, &types.BlockIdentifier{
+		Index: 3,
+		Hash:  "fork",
+	})
+	assert.True(t, errors.Is(err, ErrCheckpointMismatch))
+}
+
+func TestLoadCheckpoints(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	newDir, err := utils.CreateTempDir()
+	assert.NoError(t, err)
+	defer utils.RemoveTempDir(newDir)
+
+	hash := func(index int64) string {
+		return fmt.Sprintf("%064x", index)
+	}
+
+	// The network has a checkpoint at 1
+	params := bitcoin.CloneParams(bitcoin.MainnetParams)
+	checkpointHash, err := chainhash.NewHashFromStr(hash(1))
+	assert.NoError(t, err)
+	params.Checkpoints = []chaincfg.Checkpoint{{Height: 1, Hash: checkpointHash}}
+	network := &types.NetworkIdentifier{
+		Network:    bitcoin.MainnetNetwork,
+		Blockchain: bitcoin.Blockchain,
+	}
+	cfg := &configuration.Configuration{
+		Network:                network,
+		Params:                 params,
+		GenesisBlockIdentifier: bitcoin.MainnetGenesisBlockIdentifier,
+		IndexerPath:            newDir,
+	}
+
+	i, err := Initialize(ctx, cancel, cfg, nil)
+	assert.NoError(t, err)
+	defer i.CloseDatabase(ctx)
+
+	i.blockStorage.Initialize(i.workers)
+	for j := int64(0); j < 5; j++ {
+		block := &types.Block{
+			BlockIdentifier: &types.BlockIdentifier{Index: j, Hash: hash(j)},
+			ParentBlockIdentifier: &types.BlockIdentifier{
+				Index: j - 1,
+				Hash:  hash(j - 1),
+			},
+		}
+		if j == 0 {
+			block.ParentBlockIdentifier = block.BlockIdentifier
+		}
+
+		assert.NoError(t, i.blockStorage.SeeBlock(ctx, block))
+		assert.NoError(t, i.blockStorage.AddBlock(ctx, block))
+	}
+
+	publicKey, key, err := ed25519.GenerateKey(rand.Reader)
+	assert.NoError(t, err)
+	_, otherKey, err := ed25519.GenerateKey(rand.Reader)
+	assert.NoError(t, err)
+
+	sign := func(key ed25519.PrivateKey, index int64, blockHash string) *Checkpoint {
+		return SignCheckpoint(key, network, &types.BlockIdentifier{
+			Index: index,
+			Hash:  blockHash,
+		}, 1)
+	}
+	write := func(checkpoints ...*Checkpoint) string {
+		contents, err := json.Marshal(checkpoints)
+		assert.NoError(t, err)
+
+		source := path.Join(newDir, "checkpoints.json")
+		assert.NoError(t, ioutil.WriteFile(source, contents, 0600))
+
+		return source
+	}
+
+	// Fetch signed checkpoints from a file
+	source := write(sign(key, 2, hash(2)), sign(key, 10, hash(10)))
+	checkpoints, err := FetchCheckpoints(ctx, source, network, publicKey)
+	assert.NoError(t, err)
+	assert.Len(t, checkpoints, 2)
+
+	assert.NoError(t, i.LoadCheckpoints(ctx, checkpoints))
+	assert.True(t, errors.Is(i.checkpoints.check(10, "fork"), ErrCheckpointMismatch))
+
+	// The checkpoints of the network are unchanged
+	assert.Equal(t, int32(1), bitcoin.LatestCheckpoint(params).Height)
+
+	// Reject checkpoints not signed by the trusted instance
+	source = write(sign(key, 11, hash(11)), sign(otherKey, 12, hash(12)))
+	_, err = FetchCheckpoints(ctx, source, network, publicKey)
+	assert.True(t, errors.Is(err, ErrCheckpointSignatureInvalid))
+
+	// Reject checkpoints not above the checkpoints of
+	// the network (or not ordered by index).
+	err = i.LoadCheckpoints(ctx, []*Checkpoint{sign(key, 1, hash(1))})
+	assert.True(t, errors.Is(err, ErrInvalidCheckpoint))
+	err = i.LoadCheckpoints(ctx, []*Checkpoint{sign(key, 20, hash(20)), sign(key, 15, hash(15))})
+	assert.True(t, errors.Is(err, ErrInvalidCheckpoint))
+	assert.NoError(t, i.checkpoints.check(15, "fork"))
+
+	// Reject checkpoints conflicting with a stored block
+	err = i.LoadCheckpoints(ctx, []*Checkpoint{sign(key, 3, hash(4))})
+	assert.True(t, errors.Is(err, ErrCheckpointMismatch))
+
+	_, err = FetchCheckpoints(ctx, path.Join(newDir, "missing.json"), network, publicKey)
+	assert.Error(t, err)
+}
+
+func TestIndexer_ActivationBlockMismatch(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	newDir, err := utils.CreateTempDir()
+	assert.NoError(t, err)
+	defer utils.RemoveTempDir(newDir)
+
+	params := bitcoin.CloneParams(bitcoin.MainnetParams)
+	params.Net = 0xabcdef02
+	params.BIP0034Height = 5
+	assert.NoError(t, bitcoin.RegisterChainParams(&bitcoin.ChainParams{
+		Params: params,
+		ActivationHashes: map[string]*chainhash.Hash{
+			bitcoin.BIP0034Activation: {0x01},
+		},
+	}))
+	defer bitcoin.ResetRegistry()
+
+	cfg := &configuration.Configuration{
+		Network: &types.NetworkIdentifier{
+			Network:    bitcoin.MainnetNetwork,
+			Blockchain: bitcoin.Blockchain,
+		},
+		Params:                 params,
+		GenesisBlockIdentifier: bitcoin.MainnetGenesisBlockIdentifier,
+		IndexerPath:            newDir,
+	}
+
+	mockClient := &mocks.Client{}
+	i, err := Initialize(ctx, cancel, cfg, mockClient)
+	assert.NoError(t, err)
+	defer i.CloseDatabase(ctx)
+
+	// The node returns another block at the activation
+	// height of BIP34, so it follows other rules.
+	identifier := &types.PartialBlockIdentifier{Index: types.Int64(5)}
+	mockClient.On("GetRawBlock", mock.Anything, identifier).Return(
+		&bitcoin.Block{Hash: fmt.Sprintf("%064x", 5), Height: 5},
+		[]string{},
+		nil,
+	).Once()
+
+	_, err = i.Block(ctx, cfg.Network, identifier)
+	assert.True(t, errors.Is(err, bitcoin.ErrConsensusMismatch))
+	mockClient.AssertExpectations(t)
+}
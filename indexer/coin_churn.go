@@ -0,0 +1,175 @@
+// Copyright 2020 Coinbase, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package indexer
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strconv"
+
+	"github.com/MNtank/rosetta-bitcoin/bitcoin"
+	"github.com/MNtank/rosetta-bitcoin/utils"
+
+	"github.com/coinbase/rosetta-sdk-go/storage/database"
+	storageErrs "github.com/coinbase/rosetta-sdk-go/storage/errors"
+	"github.com/coinbase/rosetta-sdk-go/types"
+)
+
+// coinCreation returns the index of the block that created
+// coin (false if it was created in a pruned block), using
+// (and populating) creations to avoid repeated lookups.
+func (i *Indexer) coinCreation(
+	ctx context.Context,
+	dbTx database.Transaction,
+	creations map[string]int64,
+	coin *types.CoinIdentifier,
+) (int64, bool, error) {
+	hash, _, err := bitcoin.ParseCoinIdentifier(coin)
+	if err != nil {
+		return 0, false, fmt.Errorf("%w: unable to parse coin %s", err, coin.Identifier)
+	}
+
+	if index, ok := creations[hash.String()]; ok {
+		return index, true, nil
+	}
+
+	block, _, err := i.blockStorage.FindTransaction(
+		ctx,
+		&types.TransactionIdentifier{Hash: hash.String()},
+		dbTx,
+	)
+	if errors.Is(err, storageErrs.ErrCannotAccessPrunedData) {
+		return 0, false, nil
+	}
+	if err != nil {
+		return 0, false, fmt.Errorf("%w: unable to find transaction %s", err, hash.String())
+	}
+	if block == nil {
+		return 0, false, nil
+	}
+
+	creations[hash.String()] = block.Index
+	return block.Index, true, nil
+}
+
+// GetCoinChurn returns the *utils.CoinChurn of account over
+// the window blocks ending at the head of the indexer. Unspent
+// coins worth less than dustThreshold (in satoshis) are
+// reported as dust.
+func (i *Indexer) GetCoinChurn(
+	ctx context.Context,
+	account *types.AccountIdentifier,
+	window int64,
+	dustThreshold int64,
+) (*utils.CoinChurn, error) {
+	dbTx := i.database.ReadTransaction(ctx)
+	defer dbTx.Discard(ctx)
+
+	coins, head, err := i.coinStorage.GetCoinsTransactional(ctx, dbTx, account)
+	if err != nil {
+		return nil, fmt.Errorf("%w: unable to get coins of %s", err, account.Address)
+	}
+	if head == nil {
+		return nil, fmt.Errorf("%w: no blocks indexed", storageErrs.ErrHeadBlockNotFound)
+	}
+
+	start := head.Index - window + 1
+	if start < 0 {
+		start = 0
+	}
+
+	churn := &utils.CoinChurn{
+		BlockIdentifier: head,
+		Coins:           int64(len(coins)),
+	}
+	creations := map[string]int64{}
+	accountHash := types.Hash(account)
+
+	lifetimes, spentWithLifetime := int64(0), int64(0)
+	for index := start; index <= head.Index; index++ {
+		block, err := i.blockStorage.GetBlockTransactional(
+			ctx,
+			dbTx,
+			&types.PartialBlockIdentifier{Index: types.Int64(index)},
+		)
+		if errors.Is(err, storageErrs.ErrCannotAccessPrunedData) {
+			continue
+		}
+		if err != nil {
+			return nil, fmt.Errorf("%w: unable to get block %d", err, index)
+		}
+
+		churn.Window++
+		for _, transaction := range block.Transactions {
+			for _, op := range transaction.Operations {
+				if op.CoinChange == nil || op.Account == nil || types.Hash(op.Account) != accountHash {
+					continue
+				}
+
+				switch op.CoinChange.CoinAction {
+				case types.CoinCreated:
+					churn.CoinsCreated++
+				case types.CoinSpent:
+					churn.CoinsSpent++
+
+					created, ok, err := i.coinCreation(ctx, dbTx, creations, op.CoinChange.CoinIdentifier)
+					if err != nil {
+						return nil, err
+					}
+					if ok {
+						lifetimes += index - created
+						spentWithLifetime++
+					}
+				}
+			}
+		}
+	}
+
+	ages, coinsWithAge := int64(0), int64(0)
+	for _, coin := range coins {
+		value, err := strconv.ParseInt(coin.Amount.Value, 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("%w: unable to parse coin amount", err)
+		}
+
+		if value < dustThreshold {
+			churn.DustCoins++
+			churn.DustValue += value
+		}
+
+		created, ok, err := i.coinCreation(ctx, dbTx, creations, coin.CoinIdentifier)
+		if err != nil {
+			return nil, err
+		}
+		if ok {
+			ages += head.Index - created
+			coinsWithAge++
+		}
+	}
+
+	if churn.Window > 0 {
+		churn.CreationRate = float64(churn.CoinsCreated) / float64(churn.Window)
+		churn.SpendRate = float64(churn.CoinsSpent) / float64(churn.Window)
+	}
+	if spentWithLifetime > 0 {
+		churn.MeanLifetime = float64(lifetimes) / float64(spentWithLifetime)
+	}
+	if coinsWithAge > 0 {
+		churn.MeanCoinAge = float64(ages) / float64(coinsWithAge)
+	}
+
+	return churn, nil
+}
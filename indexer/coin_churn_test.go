@@ -0,0 +1,147 @@
+// Copyright 2020 Coinbase, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package indexer
+
+import (
+	"context"
+	"fmt"
+	"testing"
+
+	"github.com/MNtank/rosetta-bitcoin/bitcoin"
+	"github.com/MNtank/rosetta-bitcoin/configuration"
+	mocks "github.com/MNtank/rosetta-bitcoin/mocks/indexer"
+	indexerUtils "github.com/MNtank/rosetta-bitcoin/utils"
+
+	"github.com/coinbase/rosetta-sdk-go/types"
+	"github.com/coinbase/rosetta-sdk-go/utils"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestGetCoinChurn(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	newDir, err := utils.CreateTempDir()
+	assert.NoError(t, err)
+	defer utils.RemoveTempDir(newDir)
+
+	cfg := &configuration.Configuration{
+		Network: &types.NetworkIdentifier{
+			Network:    bitcoin.MainnetNetwork,
+			Blockchain: bitcoin.Blockchain,
+		},
+		GenesisBlockIdentifier: bitcoin.MainnetGenesisBlockIdentifier,
+		IndexerPath:            newDir,
+	}
+
+	i, err := Initialize(ctx, cancel, cfg, &mocks.Client{})
+	assert.NoError(t, err)
+	defer i.CloseDatabase(ctx)
+
+	// Each block pays account a coin (alternately worth
+	// 10 and 20 satoshis) and blocks 3 and 4 spend the
+	// coins created in blocks 1 and 0.
+	account := &types.AccountIdentifier{Address: "EH9uVaqWRxHuzJbroqzX18yxmeW8XVJyV9"}
+	transactionHash := func(index int64) string {
+		return fmt.Sprintf("%064x", index+1)
+	}
+	coinOperation := func(index int64, created int64, action types.CoinAction) *types.Operation {
+		value := "10"
+		if created%2 == 1 {
+			value = "20"
+		}
+		if action == types.CoinSpent {
+			value = "-" + value
+		}
+
+		return &types.Operation{
+			OperationIdentifier: &types.OperationIdentifier{Index: index},
+			Type:                bitcoin.OutputOpType,
+			Status:              types.String(bitcoin.SuccessStatus),
+			Account:             account,
+			Amount: &types.Amount{
+				Value:    value,
+				Currency: bitcoin.MainnetCurrency,
+			},
+			CoinChange: &types.CoinChange{
+				CoinIdentifier: &types.CoinIdentifier{
+					Identifier: fmt.Sprintf("%s:0", transactionHash(created)),
+				},
+				CoinAction: action,
+			},
+		}
+	}
+
+	i.blockStorage.Initialize(i.workers)
+	parent := &types.BlockIdentifier{Index: 0, Hash: getBlockHash(0)}
+	spends := map[int64]int64{3: 1, 4: 0}
+	for index := int64(0); index <= 4; index++ {
+		operations := []*types.Operation{}
+		if created, ok := spends[index]; ok {
+			operations = append(operations, coinOperation(0, created, types.CoinSpent))
+		}
+		operations = append(
+			operations,
+			coinOperation(int64(len(operations)), index, types.CoinCreated),
+		)
+
+		block := &types.Block{
+			BlockIdentifier:       &types.BlockIdentifier{Index: index, Hash: getBlockHash(index)},
+			ParentBlockIdentifier: parent,
+			Transactions: []*types.Transaction{
+				{
+					TransactionIdentifier: &types.TransactionIdentifier{
+						Hash: transactionHash(index),
+					},
+					Operations: operations,
+				},
+			},
+		}
+
+		assert.NoError(t, i.blockStorage.SeeBlock(ctx, block))
+		assert.NoError(t, i.BlockAdded(ctx, block))
+		parent = block.BlockIdentifier
+	}
+
+	churn, err := i.GetCoinChurn(ctx, account, 10, 15)
+	assert.NoError(t, err)
+	assert.Equal(t, &indexerUtils.CoinChurn{
+		BlockIdentifier: &types.BlockIdentifier{Index: 4, Hash: getBlockHash(4)},
+		Window:          5,
+		CoinsCreated:    5,
+		CoinsSpent:      2,
+		CreationRate:    1,
+		SpendRate:       0.4,
+		MeanLifetime:    3, // (3-1 + 4-0) / 2
+		Coins:           3, // created in blocks 2, 3, and 4
+		MeanCoinAge:     1, // (4-2 + 4-3 + 4-4) / 3
+		DustCoins:       2, // created in blocks 2 and 4
+		DustValue:       20,
+	}, churn)
+
+	// Only the most recent blocks are scanned
+	churn, err = i.GetCoinChurn(ctx, account, 2, 0)
+	assert.NoError(t, err)
+	assert.Equal(t, int64(2), churn.Window)
+	assert.Equal(t, int64(2), churn.CoinsCreated)
+	assert.Equal(t, int64(2), churn.CoinsSpent)
+	assert.Equal(t, int64(0), churn.DustCoins)
+
+	// Accounts without coins have no churn
+	churn, err = i.GetCoinChurn(ctx, &types.AccountIdentifier{Address: "other"}, 10, 15)
+	assert.NoError(t, err)
+	assert.Equal(t, int64(0), churn.CoinsCreated)
+	assert.Equal(t, int64(0), churn.Coins)
+}
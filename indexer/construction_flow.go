@@ -0,0 +1,271 @@
+// Copyright 2020 Coinbase, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package indexer
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/MNtank/rosetta-bitcoin/utils"
+
+	"github.com/coinbase/rosetta-sdk-go/storage/database"
+	sdkUtils "github.com/coinbase/rosetta-sdk-go/utils"
+)
+
+const (
+	// constructionFlowNamespace is the namespace used
+	// to store each *utils.ConstructionFlow.
+	constructionFlowNamespace = "construction-flow"
+
+	// constructionFlowIDBytes is the number of random
+	// bytes in a flow ID, so that flow IDs can't be
+	// guessed.
+	constructionFlowIDBytes = 16
+
+	// defaultConstructionFlowTTL is how long a construction
+	// flow is stored after its last step.
+	defaultConstructionFlowTTL = 24 * time.Hour
+
+	// constructionFlowPruneInterval is how often
+	// expired construction flows are removed.
+	constructionFlowPruneInterval = time.Hour
+)
+
+var (
+	// ErrConstructionFlowNotFound is returned when
+	// no construction flow is stored for a flow ID.
+	ErrConstructionFlowNotFound = errors.New("construction flow not found")
+)
+
+func getConstructionFlowKey(id string) []byte {
+	return []byte(fmt.Sprintf("%s/%s", constructionFlowNamespace, id))
+}
+
+// constructionFlowExpired returns true if the last step
+// of flow was completed more than ttl before now.
+func constructionFlowExpired(flow *utils.ConstructionFlow, ttl time.Duration, now int64) bool {
+	return now-flow.Updated > int64(ttl/time.Second)
+}
+
+// getConstructionFlow returns the stored *utils.ConstructionFlow
+// (or nil if none exists or it has expired).
+func (i *Indexer) getConstructionFlow(
+	ctx context.Context,
+	dbTx database.Transaction,
+	id string,
+) (*utils.ConstructionFlow, error) {
+	exists, value, err := dbTx.Get(ctx, getConstructionFlowKey(id))
+	if err != nil {
+		return nil, fmt.Errorf("%w: unable to get construction flow %s", err, id)
+	}
+
+	if !exists {
+		return nil, nil
+	}
+
+	var flow utils.ConstructionFlow
+	if err := json.Unmarshal(value, &flow); err != nil {
+		return nil, fmt.Errorf("%w: unable to unmarshal construction flow %s", err, id)
+	}
+
+	if constructionFlowExpired(&flow, i.constructionFlowTTL, time.Now().Unix()) {
+		return nil, nil
+	}
+
+	return &flow, nil
+}
+
+// GetConstructionFlow returns the construction flow
+// stored for id.
+func (i *Indexer) GetConstructionFlow(
+	ctx context.Context,
+	id string,
+) (*utils.ConstructionFlow, error) {
+	dbTx := i.database.ReadTransaction(ctx)
+	defer dbTx.Discard(ctx)
+
+	flow, err := i.getConstructionFlow(ctx, dbTx, id)
+	if err != nil {
+		return nil, err
+	}
+
+	if flow == nil {
+		return nil, fmt.Errorf("%w: %s", ErrConstructionFlowNotFound, id)
+	}
+
+	return flow, nil
+}
+
+// CreateConstructionFlow stores a new construction flow
+// and returns its (randomly generated) flow ID.
+func (i *Indexer) CreateConstructionFlow(ctx context.Context) (string, error) {
+	idBytes := make([]byte, constructionFlowIDBytes)
+	if _, err := rand.Read(idBytes); err != nil {
+		return "", fmt.Errorf("%w: unable to generate flow id", err)
+	}
+
+	id := hex.EncodeToString(idBytes)
+	now := time.Now().Unix()
+	if err := i.storeConstructionFlow(ctx, &utils.ConstructionFlow{
+		ID:      id,
+		Created: now,
+		Updated: now,
+	}); err != nil {
+		return "", err
+	}
+
+	return id, nil
+}
+
+// UpdateConstructionFlow applies update to the construction
+// flow stored for id and stores the result. It returns
+// ErrConstructionFlowNotFound if no flow is stored for id
+// (or it has expired).
+func (i *Indexer) UpdateConstructionFlow(
+	ctx context.Context,
+	id string,
+	update func(*utils.ConstructionFlow),
+) error {
+	key := getConstructionFlowKey(id)
+	dbTx := i.database.WriteTransaction(ctx, string(key), false)
+	defer dbTx.Discard(ctx)
+
+	flow, err := i.getConstructionFlow(ctx, dbTx, id)
+	if err != nil {
+		return err
+	}
+
+	if flow == nil {
+		return fmt.Errorf("%w: %s", ErrConstructionFlowNotFound, id)
+	}
+
+	update(flow)
+	flow.Updated = time.Now().Unix()
+
+	if err := setConstructionFlow(ctx, dbTx, flow); err != nil {
+		return err
+	}
+
+	return dbTx.Commit(ctx)
+}
+
+// storeConstructionFlow stores flow in
+// its own write transaction.
+func (i *Indexer) storeConstructionFlow(
+	ctx context.Context,
+	flow *utils.ConstructionFlow,
+) error {
+	key := getConstructionFlowKey(flow.ID)
+	dbTx := i.database.WriteTransaction(ctx, string(key), false)
+	defer dbTx.Discard(ctx)
+
+	if err := setConstructionFlow(ctx, dbTx, flow); err != nil {
+		return err
+	}
+
+	return dbTx.Commit(ctx)
+}
+
+func setConstructionFlow(
+	ctx context.Context,
+	dbTx database.Transaction,
+	flow *utils.ConstructionFlow,
+) error {
+	value, err := json.Marshal(flow)
+	if err != nil {
+		return fmt.Errorf("%w: unable to marshal construction flow %s", err, flow.ID)
+	}
+
+	if err := dbTx.Set(ctx, getConstructionFlowKey(flow.ID), value, false); err != nil {
+		return fmt.Errorf("%w: unable to set construction flow %s", err, flow.ID)
+	}
+
+	return nil
+}
+
+// pruneConstructionFlows removes all expired construction
+// flows and returns how many were removed.
+func (i *Indexer) pruneConstructionFlows(ctx context.Context) (int, error) {
+	dbTx := i.database.Transaction(ctx)
+	defer dbTx.Discard(ctx)
+
+	now := time.Now().Unix()
+	expired := [][]byte{}
+	prefix := []byte(constructionFlowNamespace + namespaceSeparator)
+	_, err := dbTx.Scan(
+		ctx,
+		prefix,
+		prefix,
+		func(k []byte, v []byte) error {
+			var flow utils.ConstructionFlow
+			if err := json.Unmarshal(v, &flow); err != nil {
+				return fmt.Errorf("%w: unable to unmarshal construction flow", err)
+			}
+
+			if constructionFlowExpired(&flow, i.constructionFlowTTL, now) {
+				expired = append(expired, append([]byte{}, k...))
+			}
+
+			return nil
+		},
+		false,
+		false,
+	)
+	if err != nil {
+		return 0, fmt.Errorf("%w: unable to scan construction flows", err)
+	}
+
+	for _, key := range expired {
+		if err := dbTx.Delete(ctx, key); err != nil {
+			return 0, fmt.Errorf("%w: unable to delete construction flow", err)
+		}
+	}
+
+	if err := dbTx.Commit(ctx); err != nil {
+		return 0, fmt.Errorf("%w: unable to commit pruned construction flows", err)
+	}
+
+	return len(expired), nil
+}
+
+// MonitorConstructionFlows periodically removes construction
+// flows that have expired until ctx is canceled.
+func (i *Indexer) MonitorConstructionFlows(ctx context.Context) error {
+	logger := utils.ExtractLogger(ctx, "construction flows")
+	for ctx.Err() == nil {
+		if err := sdkUtils.ContextSleep(ctx, constructionFlowPruneInterval); err != nil {
+			return err
+		}
+
+		pruned, err := i.pruneConstructionFlows(ctx)
+		if err != nil {
+			// Failing to prune flows should
+			// never halt the indexer.
+			logger.Warnw("unable to prune construction flows", "error", err)
+			continue
+		}
+
+		if pruned > 0 {
+			logger.Infow("pruned construction flows", "flows", pruned)
+		}
+	}
+
+	return ctx.Err()
+}
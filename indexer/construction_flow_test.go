@@ -0,0 +1,122 @@
+// Copyright 2020 Coinbase, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package indexer
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/MNtank/rosetta-bitcoin/bitcoin"
+	"github.com/MNtank/rosetta-bitcoin/configuration"
+	mocks "github.com/MNtank/rosetta-bitcoin/mocks/indexer"
+	"github.com/MNtank/rosetta-bitcoin/utils"
+
+	"github.com/coinbase/rosetta-sdk-go/types"
+	sdkUtils "github.com/coinbase/rosetta-sdk-go/utils"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestConstructionFlow(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	newDir, err := sdkUtils.CreateTempDir()
+	assert.NoError(t, err)
+	defer sdkUtils.RemoveTempDir(newDir)
+
+	cfg := &configuration.Configuration{
+		Network: &types.NetworkIdentifier{
+			Network:    bitcoin.MainnetNetwork,
+			Blockchain: bitcoin.Blockchain,
+		},
+		GenesisBlockIdentifier: bitcoin.MainnetGenesisBlockIdentifier,
+		IndexerPath:            newDir,
+	}
+
+	i, err := Initialize(ctx, cancel, cfg, &mocks.Client{})
+	assert.NoError(t, err)
+
+	flow, err := i.GetConstructionFlow(ctx, "flow")
+	assert.Nil(t, flow)
+	assert.True(t, errors.Is(err, ErrConstructionFlowNotFound))
+
+	// Flows that were not created can't be updated.
+	err = i.UpdateConstructionFlow(ctx, "flow", func(flow *utils.ConstructionFlow) {})
+	assert.True(t, errors.Is(err, ErrConstructionFlowNotFound))
+
+	id, err := i.CreateConstructionFlow(ctx)
+	assert.NoError(t, err)
+	assert.Len(t, id, 2*constructionFlowIDBytes)
+
+	otherID, err := i.CreateConstructionFlow(ctx)
+	assert.NoError(t, err)
+	assert.NotEqual(t, id, otherID)
+
+	// Each step is added to the stored flow.
+	options := map[string]interface{}{"estimated_size": 142.0}
+	assert.NoError(t, i.UpdateConstructionFlow(ctx, id, func(flow *utils.ConstructionFlow) {
+		assert.Equal(t, id, flow.ID)
+		flow.Options = options
+	}))
+	assert.NoError(t, i.UpdateConstructionFlow(ctx, id, func(flow *utils.ConstructionFlow) {
+		assert.Equal(t, options, flow.Options)
+		flow.UnsignedTransaction = "unsigned"
+	}))
+
+	flow, err = i.GetConstructionFlow(ctx, id)
+	assert.NoError(t, err)
+	assert.Equal(t, id, flow.ID)
+	assert.Equal(t, options, flow.Options)
+	assert.Equal(t, "unsigned", flow.UnsignedTransaction)
+	assert.True(t, flow.Created > 0)
+	assert.True(t, flow.Updated >= flow.Created)
+
+	_, err = i.GetConstructionFlow(ctx, "other flow")
+	assert.True(t, errors.Is(err, ErrConstructionFlowNotFound))
+
+	// Flows survive restarts.
+	i.CloseDatabase(ctx)
+	i, err = Initialize(ctx, cancel, cfg, &mocks.Client{})
+	assert.NoError(t, err)
+	defer i.CloseDatabase(ctx)
+
+	restored, err := i.GetConstructionFlow(ctx, id)
+	assert.NoError(t, err)
+	assert.Equal(t, flow, restored)
+
+	// Flows are pruned once they expire.
+	pruned, err := i.pruneConstructionFlows(ctx)
+	assert.NoError(t, err)
+	assert.Equal(t, 0, pruned)
+
+	assert.NoError(t, i.storeConstructionFlow(ctx, &utils.ConstructionFlow{
+		ID:      otherID,
+		Created: 1000,
+		Updated: 1000,
+	}))
+	_, err = i.GetConstructionFlow(ctx, otherID)
+	assert.True(t, errors.Is(err, ErrConstructionFlowNotFound))
+	err = i.UpdateConstructionFlow(ctx, otherID, func(flow *utils.ConstructionFlow) {})
+	assert.True(t, errors.Is(err, ErrConstructionFlowNotFound))
+
+	pruned, err = i.pruneConstructionFlows(ctx)
+	assert.NoError(t, err)
+	assert.Equal(t, 1, pruned)
+
+	restored, err = i.GetConstructionFlow(ctx, id)
+	assert.NoError(t, err)
+	assert.Equal(t, flow, restored)
+}
@@ -0,0 +1,277 @@
+// Copyright 2020 Coinbase, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package indexer
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+
+	"github.com/MNtank/rosetta-bitcoin/bitcoin"
+
+	"github.com/coinbase/rosetta-sdk-go/storage/database"
+	storageErrs "github.com/coinbase/rosetta-sdk-go/storage/errors"
+	"github.com/coinbase/rosetta-sdk-go/storage/modules"
+	"github.com/coinbase/rosetta-sdk-go/types"
+	"github.com/neilotoole/errgroup"
+)
+
+const (
+	// deadLetterNamespace is the namespace used
+	// to store each *DeadLetter.
+	deadLetterNamespace = "dead-letter"
+)
+
+var _ modules.BlockWorker = (*DeadLetterStorage)(nil)
+
+// DeadLetter is a transaction that could not be parsed.
+type DeadLetter struct {
+	BlockIdentifier       *types.BlockIdentifier       `json:"block_identifier"`
+	TransactionIdentifier *types.TransactionIdentifier `json:"transaction_identifier"`
+	Hex                   string                       `json:"hex"`
+	Error                 string                       `json:"error"`
+}
+
+// DeadLetterStorage implements modules.BlockWorker to store
+// each transaction that could not be parsed (indicated by
+// a bitcoin.UnparseableOpType operation) in a dead-letter
+// queue. Dead letters are removed if their block is orphaned.
+type DeadLetterStorage struct {
+	db database.Database
+}
+
+// NewDeadLetterStorage returns a new *DeadLetterStorage.
+func NewDeadLetterStorage(db database.Database) *DeadLetterStorage {
+	return &DeadLetterStorage{
+		db: db,
+	}
+}
+
+func getDeadLetterKey(transactionHash string) []byte {
+	return []byte(fmt.Sprintf("%s/%s", deadLetterNamespace, transactionHash))
+}
+
+// unparseableOperation returns the first bitcoin.UnparseableOpType
+// operation of tx (the transaction placeholder or the placeholder
+// of its first unparseable output), if any.
+func unparseableOperation(tx *types.Transaction) *types.Operation {
+	for _, op := range tx.Operations {
+		if op.Type == bitcoin.UnparseableOpType {
+			return op
+		}
+	}
+
+	return nil
+}
+
+// deadLetters returns the *DeadLetter of each
+// unparseable transaction in a block.
+func deadLetters(block *types.Block) ([]*DeadLetter, error) {
+	letters := []*DeadLetter{}
+	for _, tx := range block.Transactions {
+		op := unparseableOperation(tx)
+		if op == nil {
+			continue
+		}
+
+		var metadata bitcoin.UnparseableMetadata
+		if err := types.UnmarshalMap(op.Metadata, &metadata); err != nil {
+			return nil, fmt.Errorf(
+				"%w: unable to unmarshal unparseable metadata of %s",
+				err,
+				tx.TransactionIdentifier.Hash,
+			)
+		}
+
+		letters = append(letters, &DeadLetter{
+			BlockIdentifier:       block.BlockIdentifier,
+			TransactionIdentifier: tx.TransactionIdentifier,
+			Hex:                   metadata.Hex,
+			Error:                 metadata.Error,
+		})
+	}
+
+	return letters, nil
+}
+
+// AddingBlock is called by BlockStorage when adding a block.
+func (d *DeadLetterStorage) AddingBlock(
+	ctx context.Context,
+	g *errgroup.Group,
+	block *types.Block,
+	dbTx database.Transaction,
+) (database.CommitWorker, error) {
+	letters, err := deadLetters(block)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, letter := range letters {
+		value, err := json.Marshal(letter)
+		if err != nil {
+			return nil, fmt.Errorf("%w: unable to marshal dead letter", err)
+		}
+
+		key := getDeadLetterKey(letter.TransactionIdentifier.Hash)
+		if err := dbTx.Set(ctx, key, value, false); err != nil {
+			return nil, fmt.Errorf(
+				"%w: unable to store dead letter %s",
+				err,
+				letter.TransactionIdentifier.Hash,
+			)
+		}
+	}
+
+	return nil, nil
+}
+
+// RemovingBlock is called by BlockStorage when removing a block.
+func (d *DeadLetterStorage) RemovingBlock(
+	ctx context.Context,
+	g *errgroup.Group,
+	block *types.Block,
+	dbTx database.Transaction,
+) (database.CommitWorker, error) {
+	letters, err := deadLetters(block)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, letter := range letters {
+		key := getDeadLetterKey(letter.TransactionIdentifier.Hash)
+		if err := dbTx.Delete(ctx, key); err != nil {
+			return nil, fmt.Errorf(
+				"%w: unable to delete dead letter %s",
+				err,
+				letter.TransactionIdentifier.Hash,
+			)
+		}
+	}
+
+	return nil, nil
+}
+
+// ContainsTransactional returns a boolean indicating
+// whether a transaction is in the dead-letter queue.
+func (d *DeadLetterStorage) ContainsTransactional(
+	ctx context.Context,
+	dbTx database.Transaction,
+	transactionHash string,
+) (bool, error) {
+	exists, _, err := dbTx.Get(ctx, getDeadLetterKey(transactionHash))
+	if err != nil {
+		return false, fmt.Errorf("%w: unable to get dead letter %s", err, transactionHash)
+	}
+
+	return exists, nil
+}
+
+// GetAll returns all *DeadLetter in the queue.
+func (d *DeadLetterStorage) GetAll(ctx context.Context) ([]*DeadLetter, error) {
+	dbTx := d.db.ReadTransaction(ctx)
+	defer dbTx.Discard(ctx)
+
+	letters := []*DeadLetter{}
+	prefix := []byte(deadLetterNamespace + namespaceSeparator)
+	_, err := dbTx.Scan(
+		ctx,
+		prefix,
+		prefix,
+		func(k []byte, v []byte) error {
+			var letter DeadLetter
+			if err := json.Unmarshal(v, &letter); err != nil {
+				return fmt.Errorf("%w: unable to unmarshal dead letter", err)
+			}
+
+			letters = append(letters, &letter)
+			return nil
+		},
+		false,
+		false,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("%w: unable to scan dead letters", err)
+	}
+
+	return letters, nil
+}
+
+// ReprocessDeadLetters removes all blocks at or above the
+// lowest block containing a dead letter, so that they are
+// re-fetched (and re-parsed) the next time the indexer syncs
+// (i.e. after upgrading the parser). It returns the index
+// of the lowest removed block (or -1 if there is nothing to
+// reprocess) and any dead letters in blocks that have already
+// been pruned. These can't be reprocessed without resyncing,
+// so they are skipped (and left in the queue).
+func (i *Indexer) ReprocessDeadLetters(
+	ctx context.Context,
+) (int64, []*DeadLetter, error) {
+	letters, err := i.deadLetters.GetAll(ctx)
+	if err != nil {
+		return -1, nil, err
+	}
+
+	if len(letters) == 0 {
+		return -1, nil, nil
+	}
+
+	// If pruning has never run, every block is still stored.
+	oldest, err := i.blockStorage.GetOldestBlockIndex(ctx)
+	if errors.Is(err, storageErrs.ErrOldestIndexMissing) {
+		oldest = -1
+	} else if err != nil {
+		return -1, nil, fmt.Errorf("%w: unable to get oldest block index", err)
+	}
+
+	lowest := int64(-1)
+	unrecoverable := []*DeadLetter{}
+	for _, letter := range letters {
+		if letter.BlockIdentifier.Index < oldest {
+			unrecoverable = append(unrecoverable, letter)
+			continue
+		}
+
+		if lowest == -1 || letter.BlockIdentifier.Index < lowest {
+			lowest = letter.BlockIdentifier.Index
+		}
+	}
+
+	if lowest == -1 {
+		return -1, unrecoverable, nil
+	}
+
+	i.blockStorage.Initialize(i.workers)
+	for ctx.Err() == nil {
+		head, err := i.blockStorage.GetHeadBlockIdentifier(ctx)
+		if errors.Is(err, storageErrs.ErrHeadBlockNotFound) {
+			return lowest, unrecoverable, nil
+		}
+		if err != nil {
+			return -1, nil, fmt.Errorf("%w: unable to get head block", err)
+		}
+
+		if head.Index < lowest {
+			return lowest, unrecoverable, nil
+		}
+
+		if err := i.blockStorage.RemoveBlock(ctx, head); err != nil {
+			return -1, nil, fmt.Errorf("%w: unable to remove block %d", err, head.Index)
+		}
+	}
+
+	return -1, nil, ctx.Err()
+}
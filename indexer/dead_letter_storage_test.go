@@ -0,0 +1,223 @@
+// Copyright 2020 Coinbase, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package indexer
+
+import (
+	"context"
+	"fmt"
+	"testing"
+
+	"github.com/MNtank/rosetta-bitcoin/bitcoin"
+	"github.com/MNtank/rosetta-bitcoin/configuration"
+
+	"github.com/coinbase/rosetta-sdk-go/types"
+	"github.com/coinbase/rosetta-sdk-go/utils"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestReprocessDeadLetters(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	newDir, err := utils.CreateTempDir()
+	assert.NoError(t, err)
+	defer utils.RemoveTempDir(newDir)
+
+	cfg := &configuration.Configuration{
+		Network: &types.NetworkIdentifier{
+			Network:    bitcoin.MainnetNetwork,
+			Blockchain: bitcoin.Blockchain,
+		},
+		GenesisBlockIdentifier: bitcoin.MainnetGenesisBlockIdentifier,
+		IndexerPath:            newDir,
+	}
+
+	i, err := Initialize(ctx, cancel, cfg, nil)
+	assert.NoError(t, err)
+	defer i.CloseDatabase(ctx)
+
+	// Nothing to reprocess
+	index, unrecoverable, err := i.ReprocessDeadLetters(ctx)
+	assert.NoError(t, err)
+	assert.Equal(t, int64(-1), index)
+	assert.Len(t, unrecoverable, 0)
+
+	i.blockStorage.Initialize(i.workers)
+	unparseableHash := fmt.Sprintf("%064x", 2)
+	addBlocks := func(start int64, end int64, unparseable map[int64]string) {
+		for j := start; j < end; j++ {
+			block := &types.Block{
+				BlockIdentifier: &types.BlockIdentifier{
+					Index: j,
+					Hash:  getBlockHash(j),
+				},
+				ParentBlockIdentifier: &types.BlockIdentifier{
+					Index: j - 1,
+					Hash:  getBlockHash(j - 1),
+				},
+				Transactions: []*types.Transaction{},
+			}
+			if j == 0 {
+				block.ParentBlockIdentifier = block.BlockIdentifier
+			}
+
+			if hash, ok := unparseable[j]; ok {
+				block.Transactions = append(block.Transactions, &types.Transaction{
+					TransactionIdentifier: &types.TransactionIdentifier{Hash: hash},
+					Operations: []*types.Operation{
+						{
+							OperationIdentifier: &types.OperationIdentifier{Index: 0},
+							Type:                bitcoin.UnparseableOpType,
+							Status:              types.String(bitcoin.SuccessStatus),
+							Metadata: map[string]interface{}{
+								"error": "unknown script version",
+								"hex":   "deadbeef",
+							},
+						},
+					},
+				})
+			}
+
+			assert.NoError(t, i.blockStorage.SeeBlock(ctx, block))
+			assert.NoError(t, i.blockStorage.AddBlock(ctx, block))
+		}
+	}
+	addBlocks(0, 4, map[int64]string{2: unparseableHash})
+
+	letters, err := i.deadLetters.GetAll(ctx)
+	assert.NoError(t, err)
+	assert.Equal(t, []*DeadLetter{
+		{
+			BlockIdentifier: &types.BlockIdentifier{
+				Index: 2,
+				Hash:  getBlockHash(2),
+			},
+			TransactionIdentifier: &types.TransactionIdentifier{Hash: unparseableHash},
+			Hex:                   "deadbeef",
+			Error:                 "unknown script version",
+		},
+	}, letters)
+
+	// Inputs spending outputs of the unparseable transaction
+	// are provided to the parser as nil.
+	coins, err := i.findCoins(ctx, &bitcoin.Block{
+		Height:            4,
+		PreviousBlockHash: getBlockHash(3),
+	}, []string{
+		bitcoin.CoinIdentifier(unparseableHash, 0),
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, map[string]*types.AccountCoin{
+		bitcoin.CoinIdentifier(unparseableHash, 0): nil,
+	}, coins)
+
+	// Blocks from the lowest dead letter are removed
+	index, unrecoverable, err = i.ReprocessDeadLetters(ctx)
+	assert.NoError(t, err)
+	assert.Equal(t, int64(2), index)
+	assert.Len(t, unrecoverable, 0)
+
+	head, err := i.blockStorage.GetHeadBlockIdentifier(ctx)
+	assert.NoError(t, err)
+	assert.Equal(t, int64(1), head.Index)
+
+	letters, err = i.deadLetters.GetAll(ctx)
+	assert.NoError(t, err)
+	assert.Len(t, letters, 0)
+
+	// Dead letters in pruned blocks are skipped (and left
+	// in the queue) instead of aborting the run.
+	prunedHash := fmt.Sprintf("%064x", 4)
+	addBlocks(2, 6, map[int64]string{2: unparseableHash, 4: prunedHash})
+	_, _, err = i.blockStorage.Prune(ctx, 2, 0)
+	assert.NoError(t, err)
+
+	index, unrecoverable, err = i.ReprocessDeadLetters(ctx)
+	assert.NoError(t, err)
+	assert.Equal(t, int64(4), index)
+	assert.Equal(t, []*DeadLetter{
+		{
+			BlockIdentifier: &types.BlockIdentifier{
+				Index: 2,
+				Hash:  getBlockHash(2),
+			},
+			TransactionIdentifier: &types.TransactionIdentifier{Hash: unparseableHash},
+			Hex:                   "deadbeef",
+			Error:                 "unknown script version",
+		},
+	}, unrecoverable)
+
+	head, err = i.blockStorage.GetHeadBlockIdentifier(ctx)
+	assert.NoError(t, err)
+	assert.Equal(t, int64(3), head.Index)
+
+	letters, err = i.deadLetters.GetAll(ctx)
+	assert.NoError(t, err)
+	assert.Equal(t, unrecoverable, letters)
+
+	// Only unrecoverable dead letters remain
+	index, unrecoverable, err = i.ReprocessDeadLetters(ctx)
+	assert.NoError(t, err)
+	assert.Equal(t, int64(-1), index)
+	assert.Len(t, unrecoverable, 1)
+}
+
+func TestDeadLetters_UnparseableOutput(t *testing.T) {
+	blockIdentifier := &types.BlockIdentifier{Index: 2, Hash: getBlockHash(2)}
+	transactionIdentifier := &types.TransactionIdentifier{Hash: fmt.Sprintf("%064x", 2)}
+	letters, err := deadLetters(&types.Block{
+		BlockIdentifier: blockIdentifier,
+		Transactions: []*types.Transaction{
+			{
+				TransactionIdentifier: &types.TransactionIdentifier{Hash: fmt.Sprintf("%064x", 1)},
+				Operations: []*types.Operation{
+					{
+						OperationIdentifier: &types.OperationIdentifier{Index: 0},
+						Type:                bitcoin.OutputOpType,
+					},
+				},
+			},
+			{
+				TransactionIdentifier: transactionIdentifier,
+				Operations: []*types.Operation{
+					{
+						OperationIdentifier: &types.OperationIdentifier{Index: 0},
+						Type:                bitcoin.InputOpType,
+					},
+					{
+						OperationIdentifier: &types.OperationIdentifier{
+							Index:        1,
+							NetworkIndex: types.Int64(0),
+						},
+						Type: bitcoin.UnparseableOpType,
+						Metadata: map[string]interface{}{
+							"error": "unknown script version",
+							"hex":   "deadbeef",
+						},
+					},
+				},
+			},
+		},
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, []*DeadLetter{
+		{
+			BlockIdentifier:       blockIdentifier,
+			TransactionIdentifier: transactionIdentifier,
+			Hex:                   "deadbeef",
+			Error:                 "unknown script version",
+		},
+	}, letters)
+}
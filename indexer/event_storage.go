@@ -0,0 +1,365 @@
+// Copyright 2020 Coinbase, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package indexer
+
+import (
+	"bufio"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+	"sync"
+
+	"github.com/coinbase/rosetta-sdk-go/storage/database"
+	"github.com/coinbase/rosetta-sdk-go/storage/modules"
+	"github.com/coinbase/rosetta-sdk-go/types"
+	"github.com/neilotoole/errgroup"
+)
+
+const (
+	// eventNamespace is the namespace used
+	// to store each *ChainedBlockEvent.
+	eventNamespace = "event"
+
+	// eventHeadKey stores the most recently
+	// appended *ChainedBlockEvent.
+	eventHeadKey = "event-head"
+)
+
+var (
+	// genesisEventHash is the PreviousHash
+	// of the first *ChainedBlockEvent.
+	genesisEventHash = strings.Repeat("0", sha256.Size*2) // nolint:gomnd
+
+	// ErrEventLogTampered is returned by VerifyEventLog when
+	// the hash chain over the events log is broken.
+	ErrEventLogTampered = errors.New("events log hash chain is broken")
+)
+
+var _ modules.BlockWorker = (*EventStorage)(nil)
+
+// ChainedBlockEvent is a *types.BlockEvent that commits to
+// all events that came before it. Rewriting any event
+// invalidates the Hash of all subsequent events.
+type ChainedBlockEvent struct {
+	*types.BlockEvent
+	PreviousHash string `json:"previous_hash"`
+	Hash         string `json:"hash"`
+}
+
+// computeHash returns the hash of a *ChainedBlockEvent
+// over PreviousHash and the *types.BlockEvent.
+func (e *ChainedBlockEvent) computeHash() (string, error) {
+	event, err := json.Marshal(e.BlockEvent)
+	if err != nil {
+		return "", fmt.Errorf("%w: unable to marshal block event", err)
+	}
+
+	digest := sha256.New()
+	digest.Write([]byte(e.PreviousHash))
+	digest.Write(event)
+
+	return hex.EncodeToString(digest.Sum(nil)), nil
+}
+
+// BlockEventHandler is called with each
+// *types.BlockEvent once it is committed.
+type BlockEventHandler func(*types.BlockEvent)
+
+// EventStorage implements modules.BlockWorker to append
+// a *ChainedBlockEvent to the events log each time a block
+// is added or removed. Events are written in the same
+// database.Transaction as the block, so the log can never
+// diverge from block storage.
+type EventStorage struct {
+	db database.Database
+
+	handlers      []BlockEventHandler
+	handlersMutex sync.RWMutex
+}
+
+// NewEventStorage returns a new *EventStorage.
+func NewEventStorage(db database.Database) *EventStorage {
+	return &EventStorage{
+		db: db,
+	}
+}
+
+// Subscribe calls handler with each *types.BlockEvent
+// appended after the block it refers to is committed
+// (so the block is visible to readers when handler is
+// called). Handlers are called by the syncer and should
+// not block.
+func (e *EventStorage) Subscribe(handler BlockEventHandler) {
+	e.handlersMutex.Lock()
+	defer e.handlersMutex.Unlock()
+
+	e.handlers = append(e.handlers, handler)
+}
+
+// notify returns a database.CommitWorker that calls
+// all handlers with event.
+func (e *EventStorage) notify(event *types.BlockEvent) database.CommitWorker {
+	return func(ctx context.Context) error {
+		e.handlersMutex.RLock()
+		defer e.handlersMutex.RUnlock()
+
+		for _, handler := range e.handlers {
+			handler(event)
+		}
+
+		return nil
+	}
+}
+
+func getEventKey(sequence int64) []byte {
+	// Sequences are zero-padded so that scanning
+	// returns events in order.
+	return []byte(fmt.Sprintf("%s/%020d", eventNamespace, sequence))
+}
+
+// getHeadEvent returns the most recently appended
+// *ChainedBlockEvent (or nil if no events exist).
+func (e *EventStorage) getHeadEvent(
+	ctx context.Context,
+	dbTx database.Transaction,
+) (*ChainedBlockEvent, error) {
+	exists, value, err := dbTx.Get(ctx, []byte(eventHeadKey))
+	if err != nil {
+		return nil, fmt.Errorf("%w: unable to get head event", err)
+	}
+
+	if !exists {
+		return nil, nil
+	}
+
+	var event ChainedBlockEvent
+	if err := json.Unmarshal(value, &event); err != nil {
+		return nil, fmt.Errorf("%w: unable to unmarshal head event", err)
+	}
+
+	return &event, nil
+}
+
+// appendEvent appends a *ChainedBlockEvent to the events log.
+func (e *EventStorage) appendEvent(
+	ctx context.Context,
+	dbTx database.Transaction,
+	eventType types.BlockEventType,
+	blockIdentifier *types.BlockIdentifier,
+) (*types.BlockEvent, error) {
+	head, err := e.getHeadEvent(ctx, dbTx)
+	if err != nil {
+		return nil, err
+	}
+
+	event := &ChainedBlockEvent{
+		BlockEvent: &types.BlockEvent{
+			Sequence:        0,
+			BlockIdentifier: blockIdentifier,
+			Type:            eventType,
+		},
+		PreviousHash: genesisEventHash,
+	}
+	if head != nil {
+		event.Sequence = head.Sequence + 1
+		event.PreviousHash = head.Hash
+	}
+
+	event.Hash, err = event.computeHash()
+	if err != nil {
+		return nil, err
+	}
+
+	value, err := json.Marshal(event)
+	if err != nil {
+		return nil, fmt.Errorf("%w: unable to marshal event", err)
+	}
+
+	if err := dbTx.Set(ctx, getEventKey(event.Sequence), value, false); err != nil {
+		return nil, fmt.Errorf("%w: unable to store event %d", err, event.Sequence)
+	}
+
+	if err := dbTx.Set(ctx, []byte(eventHeadKey), value, false); err != nil {
+		return nil, fmt.Errorf("%w: unable to store head event", err)
+	}
+
+	return event.BlockEvent, nil
+}
+
+// AddingBlock is called by BlockStorage when adding a block.
+func (e *EventStorage) AddingBlock(
+	ctx context.Context,
+	g *errgroup.Group,
+	block *types.Block,
+	dbTx database.Transaction,
+) (database.CommitWorker, error) {
+	event, err := e.appendEvent(ctx, dbTx, types.ADDED, block.BlockIdentifier)
+	if err != nil {
+		return nil, err
+	}
+
+	return e.notify(event), nil
+}
+
+// RemovingBlock is called by BlockStorage when removing a block.
+func (e *EventStorage) RemovingBlock(
+	ctx context.Context,
+	g *errgroup.Group,
+	block *types.Block,
+	dbTx database.Transaction,
+) (database.CommitWorker, error) {
+	event, err := e.appendEvent(ctx, dbTx, types.REMOVED, block.BlockIdentifier)
+	if err != nil {
+		return nil, err
+	}
+
+	return e.notify(event), nil
+}
+
+// GetEvents returns up to limit *types.BlockEvent (in order)
+// starting at sequence offset, and the sequence of the most
+// recently appended event (-1 if no events exist). All events
+// are read in the same database.Transaction, so they are
+// consistent with the returned sequence.
+func (e *EventStorage) GetEvents(
+	ctx context.Context,
+	offset int64,
+	limit int64,
+) ([]*types.BlockEvent, int64, error) {
+	dbTx := e.db.ReadTransaction(ctx)
+	defer dbTx.Discard(ctx)
+
+	head, err := e.getHeadEvent(ctx, dbTx)
+	if err != nil {
+		return nil, -1, err
+	}
+
+	events := []*types.BlockEvent{}
+	if head == nil {
+		return events, -1, nil
+	}
+
+	for sequence := offset; sequence < offset+limit && sequence <= head.Sequence; sequence++ {
+		exists, value, err := dbTx.Get(ctx, getEventKey(sequence))
+		if err != nil {
+			return nil, -1, fmt.Errorf("%w: unable to get event %d", err, sequence)
+		}
+
+		if !exists {
+			return nil, -1, fmt.Errorf("event %d not found", sequence)
+		}
+
+		var event ChainedBlockEvent
+		if err := json.Unmarshal(value, &event); err != nil {
+			return nil, -1, fmt.Errorf("%w: unable to unmarshal event %d", err, sequence)
+		}
+
+		events = append(events, event.BlockEvent)
+	}
+
+	return events, head.Sequence, nil
+}
+
+// Export writes every *ChainedBlockEvent (in order) to w
+// as newline-delimited JSON. It returns the number of
+// events exported.
+func (e *EventStorage) Export(ctx context.Context, w io.Writer) (int64, error) {
+	dbTx := e.db.ReadTransaction(ctx)
+	defer dbTx.Discard(ctx)
+
+	writer := bufio.NewWriter(w)
+	prefix := []byte(eventNamespace + namespaceSeparator)
+	exported, err := dbTx.Scan(
+		ctx,
+		prefix,
+		prefix,
+		func(k []byte, v []byte) error {
+			if _, err := writer.Write(v); err != nil {
+				return err
+			}
+
+			return writer.WriteByte('\n')
+		},
+		false,
+		false,
+	)
+	if err != nil {
+		return -1, fmt.Errorf("%w: unable to export events", err)
+	}
+
+	if err := writer.Flush(); err != nil {
+		return -1, fmt.Errorf("%w: unable to flush events", err)
+	}
+
+	return int64(exported), nil
+}
+
+// VerifyEventLog checks the hash chain over an events log
+// written by Export and returns the hash of the last event
+// (which can be compared against a previously published value).
+func VerifyEventLog(r io.Reader) (int64, string, error) {
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(nil, 1<<20) // nolint:gomnd
+
+	events := int64(0)
+	previousHash := genesisEventHash
+	for scanner.Scan() {
+		var event ChainedBlockEvent
+		if err := json.Unmarshal(scanner.Bytes(), &event); err != nil {
+			return -1, "", fmt.Errorf("%w: unable to parse event %d", err, events)
+		}
+
+		if event.BlockEvent == nil || event.Sequence != events {
+			return -1, "", fmt.Errorf("%w: expected event %d", ErrEventLogTampered, events)
+		}
+
+		if event.PreviousHash != previousHash {
+			return -1, "", fmt.Errorf(
+				"%w: event %d does not commit to previous event",
+				ErrEventLogTampered,
+				events,
+			)
+		}
+
+		hash, err := event.computeHash()
+		if err != nil {
+			return -1, "", err
+		}
+
+		if hash != event.Hash {
+			return -1, "", fmt.Errorf(
+				"%w: event %d hash is %s but expected %s",
+				ErrEventLogTampered,
+				events,
+				event.Hash,
+				hash,
+			)
+		}
+
+		previousHash = event.Hash
+		events++
+	}
+
+	if err := scanner.Err(); err != nil {
+		return -1, "", fmt.Errorf("%w: unable to read events log", err)
+	}
+
+	return events, previousHash, nil
+}
@@ -0,0 +1,147 @@
+// Copyright 2020 Coinbase, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package indexer
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"strings"
+	"testing"
+
+	"github.com/coinbase/rosetta-sdk-go/storage/database"
+	"github.com/coinbase/rosetta-sdk-go/types"
+	"github.com/coinbase/rosetta-sdk-go/utils"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestEventStorage(t *testing.T) {
+	ctx := context.Background()
+
+	newDir, err := utils.CreateTempDir()
+	assert.NoError(t, err)
+	defer utils.RemoveTempDir(newDir)
+
+	db, err := database.NewBadgerDatabase(ctx, newDir)
+	assert.NoError(t, err)
+	defer db.Close(ctx)
+
+	e := NewEventStorage(db)
+	notified := []*types.BlockEvent{}
+	e.Subscribe(func(event *types.BlockEvent) {
+		notified = append(notified, event)
+	})
+
+	blocks := []*types.Block{
+		{BlockIdentifier: &types.BlockIdentifier{Index: 0, Hash: "block 0"}},
+		{BlockIdentifier: &types.BlockIdentifier{Index: 1, Hash: "block 1"}},
+	}
+
+	events, maxSequence, err := e.GetEvents(ctx, 0, 10)
+	assert.NoError(t, err)
+	assert.Len(t, events, 0)
+	assert.Equal(t, int64(-1), maxSequence)
+
+	// Add both blocks and then orphan the last one
+	for _, block := range blocks {
+		dbTx := db.Transaction(ctx)
+		commitWorker, err := e.AddingBlock(ctx, nil, block, dbTx)
+		assert.NoError(t, err)
+		assert.NoError(t, dbTx.Commit(ctx))
+		assert.NoError(t, commitWorker(ctx))
+	}
+
+	dbTx := db.Transaction(ctx)
+	commitWorker, err := e.RemovingBlock(ctx, nil, blocks[1], dbTx)
+	assert.NoError(t, err)
+	assert.NoError(t, dbTx.Commit(ctx))
+	assert.NoError(t, commitWorker(ctx))
+
+	// Uncommitted events are not exported
+	// (or sent to subscribers)
+	dbTx = db.Transaction(ctx)
+	_, err = e.AddingBlock(ctx, nil, blocks[1], dbTx)
+	assert.NoError(t, err)
+	dbTx.Discard(ctx)
+
+	assert.Equal(t, []*types.BlockEvent{
+		{Sequence: 0, BlockIdentifier: blocks[0].BlockIdentifier, Type: types.ADDED},
+		{Sequence: 1, BlockIdentifier: blocks[1].BlockIdentifier, Type: types.ADDED},
+		{Sequence: 2, BlockIdentifier: blocks[1].BlockIdentifier, Type: types.REMOVED},
+	}, notified)
+
+	events, maxSequence, err = e.GetEvents(ctx, 1, 10)
+	assert.NoError(t, err)
+	assert.Equal(t, notified[1:], events)
+	assert.Equal(t, int64(2), maxSequence)
+
+	events, _, err = e.GetEvents(ctx, 0, 1)
+	assert.NoError(t, err)
+	assert.Equal(t, notified[:1], events)
+
+	var exported bytes.Buffer
+	count, err := e.Export(ctx, &exported)
+	assert.NoError(t, err)
+	assert.Equal(t, int64(3), count)
+
+	lines := strings.Split(strings.TrimSpace(exported.String()), "\n")
+	assert.Len(t, lines, 3)
+	assert.Contains(t, lines[2], string(types.REMOVED))
+
+	verified, head, err := VerifyEventLog(strings.NewReader(exported.String()))
+	assert.NoError(t, err)
+	assert.Equal(t, int64(3), verified)
+	assert.Contains(t, lines[2], head)
+
+	t.Run("rewritten event", func(t *testing.T) {
+		tampered := strings.Replace(exported.String(), string(types.REMOVED), string(types.ADDED), 1)
+		_, _, err := VerifyEventLog(strings.NewReader(tampered))
+		assert.ErrorIs(t, err, ErrEventLogTampered)
+	})
+
+	t.Run("dropped event", func(t *testing.T) {
+		tampered := lines[0] + "\n" + lines[2] + "\n"
+		_, _, err := VerifyEventLog(strings.NewReader(tampered))
+		assert.ErrorIs(t, err, ErrEventLogTampered)
+	})
+
+	t.Run("rehashed event", func(t *testing.T) {
+		// Rewriting an event and recomputing its hash
+		// breaks the link to the following event.
+		event := &ChainedBlockEvent{
+			BlockEvent: &types.BlockEvent{
+				Sequence:        1,
+				BlockIdentifier: &types.BlockIdentifier{Index: 1, Hash: "block 2"},
+				Type:            types.ADDED,
+			},
+		}
+		events, _, err := VerifyEventLog(strings.NewReader(lines[0]))
+		assert.NoError(t, err)
+		assert.Equal(t, int64(1), events)
+
+		var first ChainedBlockEvent
+		assert.NoError(t, json.Unmarshal([]byte(lines[0]), &first))
+		event.PreviousHash = first.Hash
+		event.Hash, err = event.computeHash()
+		assert.NoError(t, err)
+
+		rewritten, err := json.Marshal(event)
+		assert.NoError(t, err)
+
+		tampered := lines[0] + "\n" + string(rewritten) + "\n" + lines[2] + "\n"
+		_, _, err = VerifyEventLog(strings.NewReader(tampered))
+		assert.ErrorIs(t, err, ErrEventLogTampered)
+	})
+}
@@ -17,7 +17,9 @@ package indexer
 import (
 	"context"
 	"errors"
+	"expvar"
 	"fmt"
+	"io"
 	"runtime"
 	"sync"
 	"time"
@@ -27,6 +29,7 @@ import (
 	"github.com/MNtank/rosetta-bitcoin/services"
 	"github.com/MNtank/rosetta-bitcoin/utils"
 
+	"github.com/btcsuite/btcd/chaincfg"
 	"github.com/coinbase/rosetta-sdk-go/asserter"
 	"github.com/coinbase/rosetta-sdk-go/storage/database"
 	storageErrs "github.com/coinbase/rosetta-sdk-go/storage/errors"
@@ -74,11 +77,21 @@ const (
 
 var (
 	errMissingTransaction = errors.New("missing transaction")
+
+	errUnparseableTransaction = errors.New("transaction is unparseable")
+
+	// unresolvedInputsMetric is the number of inputs
+	// (of each network) that could not be backfilled from
+	// the coin index (or the prevouts returned by the node),
+	// so that their block waits until the transactions that
+	// created them are indexed.
+	unresolvedInputsMetric = expvar.NewMap("indexer_unresolved_inputs")
 )
 
 // Client is used by the indexer to sync blocks.
 type Client interface {
 	NetworkStatus(context.Context) (*types.NetworkStatusResponse, error)
+	NodeNetwork(context.Context) (*bitcoin.NodeNetwork, error)
 	GetRawBlock(context.Context, *types.PartialBlockIdentifier) (*bitcoin.Block, []string, error)
 	ParseBlock(
 		context.Context,
@@ -104,6 +117,10 @@ type Indexer struct {
 	blockStorage   *modules.BlockStorage
 	balanceStorage *modules.BalanceStorage
 	coinStorage    *modules.CoinStorage
+	eventStorage   *EventStorage
+	deadLetters    *DeadLetterStorage
+	addressIndex   *AddressIndexStorage
+	staleBlocks    *StaleBlockStorage
 	workers        []modules.BlockWorker
 
 	waiter *waitTable
@@ -114,6 +131,11 @@ type Indexer struct {
 	coinCache      map[string]*types.AccountCoin
 	coinCacheMutex *sdkUtils.PriorityMutex
 
+	// Store unparseable transactions in pre-store
+	// (also guarded by coinCacheMutex) so that inputs
+	// spending their outputs are not waited on.
+	deadLetterCache map[string]struct{}
+
 	// When populating blocks using pre-stored blocks,
 	// we should retry if a new block was seen (similar
 	// to trying again if head block changes).
@@ -121,6 +143,59 @@ type Indexer struct {
 	seenMutex sync.Mutex
 
 	seenSemaphore *semaphore.Weighted
+
+	// snapshot is the most recently computed
+	// summary of storage.
+	snapshot      *utils.Snapshot
+	snapshotMutex sync.Mutex
+
+	// timelines stores how long it took to
+	// process recently added blocks.
+	timelines *timelineTable
+
+	// syncRate tracks how many blocks
+	// per second are added.
+	syncRate *syncRateTracker
+
+	// checkpoints stores checkpoints loaded
+	// from a trusted instance.
+	checkpoints *checkpointTable
+
+	// params are the params of the network (which
+	// may have hard-coded checkpoints).
+	params *chaincfg.Params
+
+	fetchLimiter *fetchLimiter
+
+	// recentAccounts are recently active accounts
+	// queued for reconciliation.
+	recentAccounts *recentAccountQueue
+
+	// genesisAllocations is the value allocated
+	// to each address in the genesis block (which
+	// does not create coins).
+	genesisAllocations map[string]int64
+
+	// reorgDepthLimit is the deepest reorg processed
+	// without operator approval (0 if unlimited).
+	// reorgChecked and reorgApproved are only
+	// accessed by the syncer.
+	reorgDepthLimit int64
+	reorgChecked    bool
+	reorgApproved   bool
+
+	// workVerifiedTip is the hash of the last tip of the
+	// node verified to meet the minimum chain work of the
+	// network. It is only accessed by the syncer.
+	workVerifiedTip string
+
+	// upgradeHooks are called when the activation
+	// block of an upgrade is added.
+	upgradeHooks *upgradeHookTable
+
+	// constructionFlowTTL is how long a construction
+	// flow is stored after its last step.
+	constructionFlowTTL time.Duration
 }
 
 // CloseDatabase closes a storage.Database. This should be called
@@ -135,6 +210,29 @@ func (i *Indexer) CloseDatabase(ctx context.Context) {
 	logger.Infow("database closed successfully")
 }
 
+// SubscribeBlockEvents calls handler with each block
+// event once it is committed (see EventStorage.Subscribe).
+func (i *Indexer) SubscribeBlockEvents(handler BlockEventHandler) {
+	i.eventStorage.Subscribe(handler)
+}
+
+// GetBlockEvents returns up to limit block events starting at
+// sequence offset, and the sequence of the most recent event
+// (see EventStorage.GetEvents).
+func (i *Indexer) GetBlockEvents(
+	ctx context.Context,
+	offset int64,
+	limit int64,
+) ([]*types.BlockEvent, int64, error) {
+	return i.eventStorage.GetEvents(ctx, offset, limit)
+}
+
+// ExportEvents writes the hash-chained events log to w
+// (see EventStorage.Export).
+func (i *Indexer) ExportEvents(ctx context.Context, w io.Writer) (int64, error) {
+	return i.eventStorage.Export(ctx, w)
+}
+
 // defaultBadgerOptions returns a set of badger.Options optimized
 // for running a Rosetta implementation.
 func defaultBadgerOptions(
@@ -202,13 +300,20 @@ func Initialize(
 		return nil, fmt.Errorf("%w: unable to initialize storage", err)
 	}
 
+	if config.Params != nil {
+		if err := checkParamsFingerprint(ctx, localStore, config.Params); err != nil {
+			_ = localStore.Close(ctx)
+			return nil, err
+		}
+	}
+
 	blockStorage := modules.NewBlockStorage(localStore, runtime.NumCPU()*overclockMultiplier)
 	asserter, err := asserter.NewClientWithOptions(
 		config.Network,
 		config.GenesisBlockIdentifier,
-		bitcoin.OperationTypes,
-		bitcoin.OperationStatuses,
-		services.Errors,
+		bitcoin.OperationTypes(),
+		bitcoin.OperationStatuses(),
+		services.Errors(),
 		nil,
 		new(asserter.Validations),
 	)
@@ -217,16 +322,36 @@ func Initialize(
 	}
 
 	i := &Indexer{
-		cancel:         cancel,
-		network:        config.Network,
-		client:         client,
-		database:       localStore,
-		blockStorage:   blockStorage,
-		waiter:         newWaitTable(),
-		asserter:       asserter,
-		coinCache:      map[string]*types.AccountCoin{},
-		coinCacheMutex: new(sdkUtils.PriorityMutex),
-		seenSemaphore:  semaphore.NewWeighted(int64(runtime.NumCPU())),
+		cancel:          cancel,
+		network:         config.Network,
+		client:          client,
+		database:        localStore,
+		blockStorage:    blockStorage,
+		waiter:          newWaitTable(),
+		asserter:        asserter,
+		coinCache:       map[string]*types.AccountCoin{},
+		deadLetterCache: map[string]struct{}{},
+		coinCacheMutex:  new(sdkUtils.PriorityMutex),
+		seenSemaphore:   semaphore.NewWeighted(int64(runtime.NumCPU())),
+		timelines:       newTimelineTable(config.BlockTimelines),
+		syncRate:        newSyncRateTracker(),
+		checkpoints:     newCheckpointTable(),
+		params:          config.Params,
+		fetchLimiter: newFetchLimiter(
+			syncer.DefaultConcurrency,
+			int64(runtime.NumCPU()*fetchConcurrencyMultiplier),
+			utils.NetworkMetrics(prefetchMetrics, config.Network),
+		),
+		recentAccounts:     newRecentAccountQueue(0),
+		genesisAllocations: genesisAllocationValues(config.GenesisAllocations),
+		reorgDepthLimit:    config.ReorgDepthLimit,
+		upgradeHooks:       newUpgradeHookTable(),
+
+		constructionFlowTTL: defaultConstructionFlowTTL,
+	}
+
+	if config.ReconciliationRate > 0 {
+		i.recentAccounts = newRecentAccountQueue(recentAccountsLimit)
 	}
 
 	coinStorage := modules.NewCoinStorage(
@@ -243,7 +368,27 @@ func Initialize(
 	)
 	i.balanceStorage = balanceStorage
 
-	i.workers = []modules.BlockWorker{coinStorage, balanceStorage}
+	eventStorage := NewEventStorage(localStore)
+	i.eventStorage = eventStorage
+
+	deadLetterStorage := NewDeadLetterStorage(localStore)
+	i.deadLetters = deadLetterStorage
+
+	addressIndex := NewAddressIndexStorage(localStore)
+	i.addressIndex = addressIndex
+
+	staleBlockStorage := NewStaleBlockStorage(localStore, staleBlockDepth)
+	i.staleBlocks = staleBlockStorage
+
+	i.workers = []modules.BlockWorker{
+		coinStorage,
+		balanceStorage,
+		eventStorage,
+		deadLetterStorage,
+		addressIndex,
+		staleBlockStorage,
+		&accountTombstoneWorker{},
+	}
 
 	return i, nil
 }
@@ -272,6 +417,14 @@ func (i *Indexer) Sync(ctx context.Context) error {
 		return fmt.Errorf("%w: failed to wait for node", err)
 	}
 
+	if err := i.checkNodeNetwork(ctx); err != nil {
+		return fmt.Errorf("%w: failed to check network of node", err)
+	}
+
+	if err := i.waitForChainRequirements(ctx); err != nil {
+		return fmt.Errorf("%w: failed to wait for chain requirements", err)
+	}
+
 	i.blockStorage.Initialize(i.workers)
 
 	startIndex := int64(indexPlaceholder)
@@ -285,13 +438,14 @@ func (i *Indexer) Sync(ctx context.Context) error {
 	// Otherwise, none are provided to the cache (the syncer will not attempt
 	// a reorg if the cache is empty).
 	pastBlocks := i.blockStorage.CreateBlockCache(ctx, syncer.DefaultPastBlockLimit)
+	cacheSize := currentPrefetchCacheSize(utils.NetworkMetrics(prefetchMetrics, i.network))
 
 	syncer := syncer.New(
 		i.network,
 		i,
 		i,
 		i.cancel,
-		syncer.WithCacheSize(syncer.DefaultCacheSize),
+		syncer.WithCacheSize(cacheSize),
 		syncer.WithSizeMultiplier(sizeMultiplier),
 		syncer.WithPastBlocks(pastBlocks),
 	)
@@ -303,6 +457,7 @@ func (i *Indexer) Sync(ctx context.Context) error {
 func (i *Indexer) BlockAdded(ctx context.Context, block *types.Block) error {
 	logger := utils.ExtractLogger(ctx, "indexer")
 
+	start := time.Now()
 	err := i.blockStorage.AddBlock(ctx, block)
 	if err != nil {
 		return fmt.Errorf(
@@ -313,9 +468,27 @@ func (i *Indexer) BlockAdded(ctx context.Context, block *types.Block) error {
 		)
 	}
 
+	i.timelines.added(block.BlockIdentifier, time.Since(start))
+	i.syncRate.added(block.BlockIdentifier.Index, time.Now())
+	i.recentAccounts.add(block)
+
+	i.reorgChecked = false
+	if err := i.clearReorgPause(ctx, block.BlockIdentifier); err != nil {
+		return err
+	}
+
 	ops := 0
+	sampler := utils.GetLogSampler(utils.TransactionLogSampler)
 	for _, transaction := range block.Transactions {
 		ops += len(transaction.Operations)
+		if sampler.Sample() {
+			logger.Infow(
+				"transaction added",
+				"hash", transaction.TransactionIdentifier.Hash,
+				"index", block.BlockIdentifier.Index,
+				"ops", len(transaction.Operations),
+			)
+		}
 	}
 
 	// clean cache intermediate
@@ -332,6 +505,8 @@ func (i *Indexer) BlockAdded(ctx context.Context, block *types.Block) error {
 
 			delete(i.coinCache, op.CoinChange.CoinIdentifier.Identifier)
 		}
+
+		delete(i.deadLetterCache, tx.TransactionIdentifier.Hash)
 	}
 	i.coinCacheMutex.Unlock()
 
@@ -354,6 +529,8 @@ func (i *Indexer) BlockAdded(ctx context.Context, block *types.Block) error {
 	}
 	i.waiter.Unlock()
 
+	i.upgradeHooks.fire(block.BlockIdentifier.Index)
+
 	logger.Debugw(
 		"block added",
 		"hash", block.BlockIdentifier.Hash,
@@ -378,6 +555,10 @@ func (i *Indexer) BlockSeen(ctx context.Context, block *types.Block) error {
 	i.coinCacheMutex.Lock(false)
 	for _, tx := range block.Transactions {
 		for _, op := range tx.Operations {
+			if op.Type == bitcoin.UnparseableOpType {
+				i.deadLetterCache[tx.TransactionIdentifier.Hash] = struct{}{}
+			}
+
 			if op.CoinChange == nil {
 				continue
 			}
@@ -461,6 +642,14 @@ func (i *Indexer) BlockRemoved(
 		"hash", blockIdentifier.Hash,
 		"index", blockIdentifier.Index,
 	)
+
+	if i.reorgDepthLimit > 0 && !i.reorgChecked {
+		if err := i.checkReorgDepth(ctx, blockIdentifier); err != nil {
+			return err
+		}
+		i.reorgChecked = true
+	}
+
 	err := i.blockStorage.RemoveBlock(ctx, blockIdentifier)
 	if err != nil {
 		return fmt.Errorf(
@@ -475,12 +664,23 @@ func (i *Indexer) BlockRemoved(
 }
 
 // NetworkStatus is called by the syncer to get the current
-// network status.
+// network status. An error is returned if the tip of the node
+// has less work than the minimum chain work of the network, so
+// that a low-work chain is not followed.
 func (i *Indexer) NetworkStatus(
 	ctx context.Context,
 	network *types.NetworkIdentifier,
 ) (*types.NetworkStatusResponse, error) {
-	return i.client.NetworkStatus(ctx)
+	status, err := i.client.NetworkStatus(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := i.checkTipWork(ctx, status.CurrentBlockIdentifier); err != nil {
+		return nil, err
+	}
+
+	return status, nil
 }
 
 func (i *Indexer) findCoin(
@@ -528,13 +728,31 @@ func (i *Indexer) findCoin(
 		}
 
 		// Check seen CoinCache
+		transactionHash := bitcoin.TransactionHash(coinIdentifier)
 		i.coinCacheMutex.Lock(false)
 		accCoin, ok := i.coinCache[coinIdentifier]
+		_, unparseable := i.deadLetterCache[transactionHash]
 		i.coinCacheMutex.Unlock()
 		if ok {
 			return accCoin.Coin, accCoin.Account, nil
 		}
 
+		// The transaction that created the coin will never
+		// create it if it could not be parsed.
+		if !unparseable {
+			unparseable, err = i.deadLetters.ContainsTransactional(
+				ctx,
+				databaseTransaction,
+				transactionHash,
+			)
+			if err != nil {
+				return nil, nil, err
+			}
+		}
+		if unparseable {
+			return nil, nil, errUnparseableTransaction
+		}
+
 		// Locking here prevents us from adding sending any done
 		// signals while we are determining whether or not to add
 		// to the WaitTable.
@@ -557,7 +775,6 @@ func (i *Indexer) findCoin(
 
 		// Put Transaction in WaitTable if doesn't already exist (could be
 		// multiple listeners)
-		transactionHash := bitcoin.TransactionHash(coinIdentifier)
 		val, ok := i.waiter.Get(transactionHash, false)
 		if !ok {
 			val = &waitTableEntry{
@@ -607,6 +824,8 @@ func (i *Indexer) findCoins(
 		return nil, fmt.Errorf("%w: check header match failed", err)
 	}
 
+	logger := utils.ExtractLogger(ctx, "indexer")
+	sampler := utils.GetLogSampler(utils.CoinLogSampler)
 	coinMap := map[string]*types.AccountCoin{}
 	remainingCoins := []string{}
 	for _, coinIdentifier := range coins {
@@ -620,6 +839,15 @@ func (i *Indexer) findCoins(
 				Account: owner,
 				Coin:    coin,
 			}
+			if sampler.Sample() {
+				logger.Infow(
+					"coin found",
+					"coin", coinIdentifier,
+					"account", owner.Address,
+					"amount", coin.Amount.Value,
+					"index", btcBlock.Height,
+				)
+			}
 			continue
 		}
 
@@ -628,6 +856,13 @@ func (i *Indexer) findCoins(
 			continue
 		}
 
+		// Coins created by unparseable transactions
+		// are provided to the parser as nil.
+		if errors.Is(err, errUnparseableTransaction) {
+			coinMap[coinIdentifier] = nil
+			continue
+		}
+
 		return nil, fmt.Errorf("%w: unable to find coin %s", err, coinIdentifier)
 	}
 
@@ -635,6 +870,20 @@ func (i *Indexer) findCoins(
 		return coinMap, nil
 	}
 
+	// Rather than emitting operations with unknown amounts,
+	// the block waits until the transactions that created
+	// its unresolved inputs are indexed (and then looks
+	// the inputs up again below).
+	metricsKey := utils.NetworkMetricsKey(i.network)
+	unresolvedInputsMetric.Add(metricsKey, int64(len(remainingCoins)))
+	defer unresolvedInputsMetric.Add(metricsKey, -int64(len(remainingCoins)))
+	logger.Debugw(
+		"waiting for unresolved inputs",
+		"block", btcBlock.Hash,
+		"index", btcBlock.Height,
+		"inputs", len(remainingCoins),
+	)
+
 	// Wait for remaining transactions
 	shouldAbort := false
 	for _, coinIdentifier := range remainingCoins {
@@ -705,9 +954,32 @@ func (i *Indexer) Block(
 	var coins []string
 	var err error
 
+	start := time.Now()
 	retries := 0
 	for ctx.Err() == nil {
+		if err := i.fetchLimiter.acquire(ctx); err != nil {
+			return nil, err
+		}
+
+		fetchStart := time.Now()
 		btcBlock, coins, err = i.client.GetRawBlock(ctx, blockIdentifier)
+		switch {
+		case err == nil:
+			i.fetchLimiter.release(time.Since(fetchStart), btcBlock.Size, nil)
+		case errors.Is(err, bitcoin.ErrBlockPruned):
+			// A block pruned by the node (and by every archive
+			// node) is not a sign of congestion. The block is
+			// re-resolved when retried (in case the pruned block
+			// was reorged out).
+			i.fetchLimiter.skip()
+			utils.ExtractLogger(ctx, "indexer").Warnw(
+				"block pruned by node",
+				"block", types.PrintStruct(blockIdentifier),
+				"error", err,
+			)
+		default:
+			i.fetchLimiter.release(time.Since(fetchStart), 0, err)
+		}
 		if err == nil {
 			break
 		}
@@ -721,23 +993,52 @@ func (i *Indexer) Block(
 			return nil, err
 		}
 	}
+	fetched := time.Now()
+
+	// refuse to process blocks that conflict
+	// with a loaded (or hard-coded) checkpoint
+	if err := i.checkpoints.check(btcBlock.Height, btcBlock.Hash); err != nil {
+		return nil, err
+	}
+	if i.params != nil {
+		if err := bitcoin.VerifyAgainstCheckpoints(i.params, btcBlock.Height, btcBlock.Hash); err != nil {
+			return nil, err
+		}
+		if err := bitcoin.VerifyActivationBlock(i.params, btcBlock.Height, btcBlock.Hash); err != nil {
+			return nil, err
+		}
+	}
 
 	// determine which coins must be fetched and get from coin storage
 	coinMap, err := i.findCoins(ctx, btcBlock, coins)
 	if err != nil {
 		return nil, fmt.Errorf("%w: unable to find input transactions", err)
 	}
+	found := time.Now()
 
 	// provide to block parsing
 	block, err := i.client.ParseBlock(ctx, btcBlock, coinMap)
 	if err != nil {
 		return nil, fmt.Errorf("%w: unable to parse block %+v", err, blockIdentifier)
 	}
+	parsed := time.Now()
 
 	// ensure block is valid
 	if err := i.asserter.Block(block); err != nil {
 		return nil, fmt.Errorf("%w: block is not valid %+v", err, blockIdentifier)
 	}
+	validated := time.Now()
+
+	i.timelines.fetched(&utils.BlockTimeline{
+		BlockIdentifier: block.BlockIdentifier,
+		Transactions:    len(block.Transactions),
+		Inputs:          len(coins),
+		Fetch:           milliseconds(fetched.Sub(start)),
+		Coins:           milliseconds(found.Sub(fetched)),
+		Parse:           milliseconds(parsed.Sub(found)),
+		Validate:        milliseconds(validated.Sub(parsed)),
+		Total:           milliseconds(validated.Sub(start)),
+	})
 
 	return block, nil
 }
@@ -753,6 +1054,10 @@ func (i *Indexer) GetScriptPubKeys(
 	databaseTransaction := i.database.ReadTransaction(ctx)
 	defer databaseTransaction.Discard(ctx)
 
+	// Coins created by the same transaction are commonly
+	// spent together, so each transaction is only looked
+	// up (which requires a prefix scan) once.
+	transactions := map[string]*types.Transaction{}
 	scripts := make([]*bitcoin.ScriptPubKey, len(coins))
 	for j, coin := range coins {
 		coinIdentifier := coin.CoinIdentifier
@@ -761,21 +1066,24 @@ func (i *Indexer) GetScriptPubKeys(
 			return nil, fmt.Errorf("%w: unable to parse coin identifier", err)
 		}
 
-		_, transaction, err := i.blockStorage.FindTransaction(
+		transaction, err := i.findTransaction(
 			ctx,
-			&types.TransactionIdentifier{Hash: transactionHash.String()},
 			databaseTransaction,
+			transactions,
+			transactionHash.String(),
 		)
-		if err != nil || transaction == nil {
-			return nil, fmt.Errorf(
-				"%w: unable to find transaction %s",
-				err,
-				transactionHash.String(),
-			)
+		if err != nil {
+			return nil, err
 		}
 
 		for _, op := range transaction.Operations {
-			if op.Type != bitcoin.OutputOpType {
+			switch op.Type {
+			case bitcoin.OutputOpType,
+				bitcoin.DelegationOpType,
+				bitcoin.StakeRewardOpType,
+				bitcoin.MasternodeRewardOpType,
+				bitcoin.TreasuryPayoutOpType:
+			default:
 				continue
 			}
 
@@ -824,6 +1132,113 @@ func (i *Indexer) GetScriptPubKeys(
 	return scripts, nil
 }
 
+// findTransaction returns the *types.Transaction with
+// the provided hash, using (and populating) transactions
+// to avoid repeated lookups in the same database transaction.
+func (i *Indexer) findTransaction(
+	ctx context.Context,
+	databaseTransaction database.Transaction,
+	transactions map[string]*types.Transaction,
+	hash string,
+) (*types.Transaction, error) {
+	if transaction, ok := transactions[hash]; ok {
+		return transaction, nil
+	}
+
+	_, transaction, err := i.blockStorage.FindTransaction(
+		ctx,
+		&types.TransactionIdentifier{Hash: hash},
+		databaseTransaction,
+	)
+	if err != nil || transaction == nil {
+		return nil, fmt.Errorf("%w: unable to find transaction %s", err, hash)
+	}
+
+	transactions[hash] = transaction
+	return transaction, nil
+}
+
+// GetCoinBlocks returns the block of the transaction
+// that created each coin (so that callers can compute
+// its confirmations).
+func (i *Indexer) GetCoinBlocks(
+	ctx context.Context,
+	coins []*types.Coin,
+) ([]*types.BlockIdentifier, error) {
+	databaseTransaction := i.database.ReadTransaction(ctx)
+	defer databaseTransaction.Discard(ctx)
+
+	blocks := map[string]*types.BlockIdentifier{}
+	result := make([]*types.BlockIdentifier, len(coins))
+	for j, coin := range coins {
+		transactionHash, _, err := bitcoin.ParseCoinIdentifier(coin.CoinIdentifier)
+		if err != nil {
+			return nil, fmt.Errorf("%w: unable to parse coin identifier", err)
+		}
+
+		hash := transactionHash.String()
+		block, ok := blocks[hash]
+		if !ok {
+			block, _, err = i.blockStorage.FindTransaction(
+				ctx,
+				&types.TransactionIdentifier{Hash: hash},
+				databaseTransaction,
+			)
+			if err != nil {
+				return nil, fmt.Errorf("%w: unable to find transaction %s", err, hash)
+			}
+
+			if block == nil {
+				return nil, fmt.Errorf("transaction %s not found", hash)
+			}
+
+			blocks[hash] = block
+		}
+
+		result[j] = block
+	}
+
+	return result, nil
+}
+
+// GetUnspentCoins returns the owner and amount of each
+// coin that is unspent at the head block (by coin
+// identifier). Coins that are not indexed (i.e. created
+// by a mempool transaction) or already spent are omitted.
+func (i *Indexer) GetUnspentCoins(
+	ctx context.Context,
+	coinIdentifiers []*types.CoinIdentifier,
+) (map[string]*types.AccountCoin, error) {
+	databaseTransaction := i.database.ReadTransaction(ctx)
+	defer databaseTransaction.Discard(ctx)
+
+	coins := map[string]*types.AccountCoin{}
+	for _, coinIdentifier := range coinIdentifiers {
+		coin, owner, err := i.coinStorage.GetCoinTransactional(
+			ctx,
+			databaseTransaction,
+			coinIdentifier,
+		)
+		if errors.Is(err, storageErrs.ErrCoinNotFound) {
+			continue
+		}
+		if err != nil {
+			return nil, fmt.Errorf(
+				"%w: unable to lookup coin %s",
+				err,
+				coinIdentifier.Identifier,
+			)
+		}
+
+		coins[coinIdentifier.Identifier] = &types.AccountCoin{
+			Account: owner,
+			Coin:    coin,
+		}
+	}
+
+	return coins, nil
+}
+
 // GetBlockLazy returns a *types.BlockResponse from the indexer's block storage.
 // All transactions in a block must be fetched individually.
 func (i *Indexer) GetBlockLazy(
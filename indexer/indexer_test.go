@@ -216,6 +216,7 @@ func TestIndexer_Transactions(t *testing.T) {
 		Script  *bitcoin.ScriptPubKey
 		Coin    *types.Coin
 		Account *types.AccountIdentifier
+		Block   *types.BlockIdentifier
 	}
 
 	coinBank := map[string]*coinBankEntry{}
@@ -276,6 +277,7 @@ func TestIndexer_Transactions(t *testing.T) {
 			}
 			coinBank[coinIdentifier] = &coinBankEntry{
 				Script: scriptPubKey,
+				Block:  identifier,
 				Coin: &types.Coin{
 					CoinIdentifier: &types.CoinIdentifier{
 						Identifier: coinIdentifier,
@@ -370,6 +372,7 @@ func TestIndexer_Transactions(t *testing.T) {
 				// Ensure ScriptPubKeys are accessible.
 				allCoins := []*types.Coin{}
 				expectedPubKeys := []*bitcoin.ScriptPubKey{}
+				expectedBlocks := []*types.BlockIdentifier{}
 				for k, v := range coinBank {
 					allCoins = append(allCoins, &types.Coin{
 						CoinIdentifier: &types.CoinIdentifier{Identifier: k},
@@ -379,12 +382,34 @@ func TestIndexer_Transactions(t *testing.T) {
 						},
 					})
 					expectedPubKeys = append(expectedPubKeys, v.Script)
+					expectedBlocks = append(expectedBlocks, v.Block)
 				}
 
 				pubKeys, err := i.GetScriptPubKeys(ctx, allCoins)
 				assert.NoError(t, err)
 				assert.Equal(t, expectedPubKeys, pubKeys)
 
+				// Ensure the blocks of coins are accessible.
+				blocks, err := i.GetCoinBlocks(ctx, allCoins)
+				assert.NoError(t, err)
+				assert.Equal(t, expectedBlocks, blocks)
+
+				// Ensure unspent coins are accessible (and
+				// unknown coins are omitted).
+				coinIdentifiers := []*types.CoinIdentifier{{Identifier: "missing:0"}}
+				expectedCoins := map[string]*types.AccountCoin{}
+				for k, v := range coinBank {
+					coinIdentifiers = append(coinIdentifiers, v.Coin.CoinIdentifier)
+					expectedCoins[k] = &types.AccountCoin{
+						Account: v.Account,
+						Coin:    v.Coin,
+					}
+				}
+
+				unspentCoins, err := i.GetUnspentCoins(ctx, coinIdentifiers)
+				assert.NoError(t, err)
+				assert.Equal(t, expectedCoins, unspentCoins)
+
 				cancel()
 				close(waitForFinish)
 				return
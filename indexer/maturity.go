@@ -0,0 +1,122 @@
+// Copyright 2020 Coinbase, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package indexer
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/MNtank/rosetta-bitcoin/bitcoin"
+
+	"github.com/coinbase/rosetta-sdk-go/types"
+)
+
+// errMatureBlock is returned by the worker scanning the
+// address index to stop at the first mature block.
+var errMatureBlock = errors.New("block is mature")
+
+// GetImmatureBalance returns the sum of the unspent coins of
+// account (in currency) created by a reward transaction (see
+// bitcoin.IsRewardTransaction) that can't be spent in the block
+// after block, as they have not matured. Rewards are found with
+// the address index, so only the last CoinbaseMaturity blocks
+// are scanned.
+func (i *Indexer) GetImmatureBalance(
+	ctx context.Context,
+	account *types.AccountIdentifier,
+	currency *types.Currency,
+	block *types.BlockIdentifier,
+) (*types.Amount, error) {
+	if i.params == nil {
+		return nil, ErrNoParams
+	}
+
+	dbTx := i.database.ReadTransaction(ctx)
+	defer dbTx.Discard(ctx)
+
+	coins, _, err := i.coinStorage.GetCoinsTransactional(ctx, dbTx, account)
+	if err != nil {
+		return nil, fmt.Errorf("%w: unable to get coins", err)
+	}
+
+	unspent := map[string]struct{}{}
+	for _, coin := range coins {
+		unspent[coin.CoinIdentifier.Identifier] = struct{}{}
+	}
+
+	immature := zeroValue
+	err = i.addressIndex.ScanTransactions(
+		ctx,
+		dbTx,
+		account.Address,
+		&block.Index,
+		func(
+			txBlock *types.BlockIdentifier,
+			transactionIdentifier *types.TransactionIdentifier,
+		) error {
+			if bitcoin.MatureHeight(i.params, txBlock.Index) <= block.Index+1 {
+				return errMatureBlock
+			}
+
+			_, tx, err := i.blockStorage.FindTransaction(ctx, transactionIdentifier, dbTx)
+			if err != nil {
+				return fmt.Errorf(
+					"%w: unable to find transaction %s",
+					err,
+					transactionIdentifier.Hash,
+				)
+			}
+
+			if tx == nil {
+				return fmt.Errorf("indexed transaction %s not found", transactionIdentifier.Hash)
+			}
+
+			if !bitcoin.IsRewardTransaction(tx) {
+				return nil
+			}
+
+			for _, op := range tx.Operations {
+				if op.CoinChange == nil || op.CoinChange.CoinAction != types.CoinCreated {
+					continue
+				}
+
+				if _, ok := unspent[op.CoinChange.CoinIdentifier.Identifier]; !ok {
+					continue
+				}
+
+				if op.Account == nil || types.Hash(op.Account) != types.Hash(account) ||
+					types.Hash(op.Amount.Currency) != types.Hash(currency) {
+					continue
+				}
+
+				immature, err = types.AddValues(immature, op.Amount.Value)
+				if err != nil {
+					return fmt.Errorf("%w: unable to add reward amount", err)
+				}
+			}
+
+			return nil
+		},
+	)
+	if err != nil && !errors.Is(err, errMatureBlock) {
+		return nil, err
+	}
+
+	return &types.Amount{
+		Value:    immature,
+		Currency: currency,
+	}, nil
+}
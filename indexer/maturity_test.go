@@ -0,0 +1,200 @@
+// Copyright 2020 Coinbase, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package indexer
+
+import (
+	"context"
+	"fmt"
+	"testing"
+
+	"github.com/MNtank/rosetta-bitcoin/bitcoin"
+	"github.com/MNtank/rosetta-bitcoin/configuration"
+
+	"github.com/coinbase/rosetta-sdk-go/types"
+	"github.com/coinbase/rosetta-sdk-go/utils"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestIndexer_ImmatureBalance(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	newDir, err := utils.CreateTempDir()
+	assert.NoError(t, err)
+	defer utils.RemoveTempDir(newDir)
+
+	params := bitcoin.CloneParams(bitcoin.MainnetParams)
+	params.CoinbaseMaturity = 3
+	cfg := &configuration.Configuration{
+		Network: &types.NetworkIdentifier{
+			Network:    bitcoin.MainnetNetwork,
+			Blockchain: bitcoin.Blockchain,
+		},
+		GenesisBlockIdentifier: bitcoin.MainnetGenesisBlockIdentifier,
+		IndexerPath:            newDir,
+		Params:                 params,
+	}
+
+	i, err := Initialize(ctx, cancel, cfg, nil)
+	assert.NoError(t, err)
+	defer i.CloseDatabase(ctx)
+
+	account := &types.AccountIdentifier{Address: "addr1"}
+	other := &types.AccountIdentifier{Address: "addr2"}
+	amount := func(value int64) *types.Amount {
+		return &types.Amount{
+			Value:    fmt.Sprintf("%d", value),
+			Currency: bitcoin.MainnetCurrency,
+		}
+	}
+	output := func(
+		hash string,
+		index int64,
+		account *types.AccountIdentifier,
+		value int64,
+	) *types.Operation {
+		return &types.Operation{
+			OperationIdentifier: &types.OperationIdentifier{Index: index, NetworkIndex: &index},
+			Type:                bitcoin.OutputOpType,
+			Status:              types.String(bitcoin.SuccessStatus),
+			Account:             account,
+			Amount:              amount(value),
+			CoinChange: &types.CoinChange{
+				CoinIdentifier: &types.CoinIdentifier{Identifier: fmt.Sprintf("%s:%d", hash, index)},
+				CoinAction:     types.CoinCreated,
+			},
+		}
+	}
+
+	stakeReward := func(op *types.Operation) *types.Operation {
+		op.Type = bitcoin.StakeRewardOpType
+		return op
+	}
+
+	// Block j pays a reward of 10 to addr1 in "cb j", block 4
+	// also has a transfer of 7 to addr1 ("tx 4"), and block 5
+	// pays addr2 and has a coinstake ("cs 5") spending the
+	// transfer to pay 17 to addr1.
+	i.blockStorage.Initialize(i.workers)
+	for j := int64(0); j < 6; j++ {
+		block := &types.Block{
+			BlockIdentifier: &types.BlockIdentifier{
+				Index: j,
+				Hash:  getBlockHash(j),
+			},
+			ParentBlockIdentifier: &types.BlockIdentifier{
+				Index: j - 1,
+				Hash:  getBlockHash(j - 1),
+			},
+		}
+		if j == 0 {
+			block.ParentBlockIdentifier = block.BlockIdentifier
+		}
+
+		coinbase := &types.Transaction{
+			TransactionIdentifier: &types.TransactionIdentifier{Hash: fmt.Sprintf("cb %d", j)},
+			Operations: []*types.Operation{
+				{
+					OperationIdentifier: &types.OperationIdentifier{Index: 0},
+					Type:                bitcoin.CoinbaseOpType,
+					Status:              types.String(bitcoin.SuccessStatus),
+				},
+				output(fmt.Sprintf("cb %d", j), 1, account, 10),
+			},
+		}
+		if j == 5 {
+			coinbase.Operations = append(coinbase.Operations, output("cb 5", 2, other, 10))
+		}
+		block.Transactions = []*types.Transaction{coinbase}
+
+		switch j {
+		case 4:
+			block.Transactions = append(block.Transactions, &types.Transaction{
+				TransactionIdentifier: &types.TransactionIdentifier{Hash: "tx 4"},
+				Operations:            []*types.Operation{output("tx 4", 0, account, 7)},
+			})
+		case 5:
+			marker := int64(0)
+			block.Transactions = append(block.Transactions, &types.Transaction{
+				TransactionIdentifier: &types.TransactionIdentifier{Hash: "cs 5"},
+				Operations: []*types.Operation{
+					{
+						OperationIdentifier: &types.OperationIdentifier{Index: 0},
+						Type:                bitcoin.StakeInputOpType,
+						Status:              types.String(bitcoin.SuccessStatus),
+						Account:             account,
+						Amount:              amount(-7),
+						CoinChange: &types.CoinChange{
+							CoinIdentifier: &types.CoinIdentifier{Identifier: "tx 4:0"},
+							CoinAction:     types.CoinSpent,
+						},
+					},
+					{
+						OperationIdentifier: &types.OperationIdentifier{Index: 1, NetworkIndex: &marker},
+						Type:                bitcoin.StakeRewardOpType,
+						Status:              types.String(bitcoin.SuccessStatus),
+						Account:             &types.AccountIdentifier{Address: "cs 5:0"},
+						Amount:              amount(0),
+					},
+					stakeReward(output("cs 5", 1, account, 17)),
+				},
+			})
+		}
+
+		assert.NoError(t, i.blockStorage.SeeBlock(ctx, block))
+		assert.NoError(t, i.blockStorage.AddBlock(ctx, block))
+	}
+
+	tests := map[string]struct {
+		account  *types.AccountIdentifier
+		index    int64
+		immature string
+	}{
+		"tip": {
+			account:  account,
+			index:    5,
+			immature: "37",
+		},
+		"other account": {
+			account:  other,
+			index:    5,
+			immature: "10",
+		},
+		"historical": {
+			account:  account,
+			index:    3,
+			immature: "20",
+		},
+		"unknown account": {
+			account:  &types.AccountIdentifier{Address: "addr3"},
+			index:    5,
+			immature: "0",
+		},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			immature, err := i.GetImmatureBalance(
+				ctx,
+				test.account,
+				bitcoin.MainnetCurrency,
+				&types.BlockIdentifier{Index: test.index, Hash: getBlockHash(test.index)},
+			)
+			assert.NoError(t, err)
+			assert.Equal(t, amount(0).Currency, immature.Currency)
+			assert.Equal(t, test.immature, immature.Value)
+		})
+	}
+}
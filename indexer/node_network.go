@@ -0,0 +1,67 @@
+// Copyright 2020 Coinbase, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package indexer
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/MNtank/rosetta-bitcoin/bitcoin"
+	"github.com/MNtank/rosetta-bitcoin/utils"
+)
+
+// checkNodeNetwork returns bitcoin.ErrNetworkMismatch if
+// the node serves a different network than the params
+// of the indexer (so that blocks of another chain are
+// never indexed), and bitcoin.ErrNodeTooOld if the node
+// is too old to serve the data indexed.
+func (i *Indexer) checkNodeNetwork(ctx context.Context) error {
+	if i.params == nil {
+		return nil
+	}
+
+	node, err := i.client.NodeNetwork(ctx)
+	if err != nil {
+		return fmt.Errorf("%w: unable to get network of node", err)
+	}
+
+	if err := bitcoin.CheckNodeNetwork(i.params, node); err != nil {
+		return err
+	}
+
+	if err := bitcoin.CheckProtocolVersion(i.params, node); err != nil {
+		return err
+	}
+
+	logger := utils.ExtractLogger(ctx, "indexer")
+	if bitcoin.IsNewerProtocolVersion(i.params, node) {
+		logger.Warnw(
+			"node speaks a newer protocol version than known to be served correctly",
+			"network", i.params.Name,
+			"subversion", node.SubVersion,
+			"protocol_version", node.ProtocolVersion,
+		)
+	}
+
+	logger.Infow(
+		"node serves configured network",
+		"network", i.params.Name,
+		"chain", node.Chain,
+		"subversion", node.SubVersion,
+		"protocol_version", node.ProtocolVersion,
+	)
+
+	return nil
+}
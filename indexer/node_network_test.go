@@ -0,0 +1,122 @@
+// Copyright 2020 Coinbase, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package indexer
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/MNtank/rosetta-bitcoin/bitcoin"
+	"github.com/MNtank/rosetta-bitcoin/configuration"
+	mocks "github.com/MNtank/rosetta-bitcoin/mocks/indexer"
+
+	"github.com/btcsuite/btcd/chaincfg"
+	"github.com/btcsuite/btcd/chaincfg/chainhash"
+	"github.com/coinbase/rosetta-sdk-go/types"
+	"github.com/coinbase/rosetta-sdk-go/utils"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+// testnetGenesisBlockIdentifier is the genesis
+// block of testnet in the tests.
+var testnetGenesisBlockIdentifier = &types.BlockIdentifier{
+	Hash: "00000c7c73d8ce604178dae13f0fc6ec0be3275614366d44b1b4b5c6e238c60c",
+}
+
+// testnetParams returns the params of testnet
+// (with testnetGenesisBlockIdentifier).
+func testnetParams(t *testing.T) *chaincfg.Params {
+	genesisHash, err := chainhash.NewHashFromStr(testnetGenesisBlockIdentifier.Hash)
+	assert.NoError(t, err)
+
+	return bitcoin.CreateTestNetParams(genesisHash)
+}
+
+func TestIndexer_NodeNetworkMismatch(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	newDir, err := utils.CreateTempDir()
+	assert.NoError(t, err)
+	defer utils.RemoveTempDir(newDir)
+
+	cfg := &configuration.Configuration{
+		Network: &types.NetworkIdentifier{
+			Network:    bitcoin.TestnetNetwork,
+			Blockchain: bitcoin.Blockchain,
+		},
+		GenesisBlockIdentifier: testnetGenesisBlockIdentifier,
+		Params:                 testnetParams(t),
+		IndexerPath:            newDir,
+	}
+
+	mockClient := &mocks.Client{}
+	i, err := Initialize(ctx, cancel, cfg, mockClient)
+	assert.NoError(t, err)
+	defer i.CloseDatabase(ctx)
+
+	// The node serves mainnet, so no block is requested
+	mockClient.On("NetworkStatus", mock.Anything).Return(&types.NetworkStatusResponse{}, nil).Once()
+	mockClient.On("NodeNetwork", mock.Anything).Return(&bitcoin.NodeNetwork{
+		Chain:       "main",
+		GenesisHash: "0000009ea234b1ab29f0172e4d85884a45c0c638192c9c0f781bda67908d56dd",
+	}, nil).Once()
+
+	err = i.Sync(ctx)
+	assert.True(t, errors.Is(err, bitcoin.ErrNetworkMismatch))
+	mockClient.AssertExpectations(t)
+}
+
+func TestIndexer_NodeTooOld(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	newDir, err := utils.CreateTempDir()
+	assert.NoError(t, err)
+	defer utils.RemoveTempDir(newDir)
+
+	cfg := &configuration.Configuration{
+		Network: &types.NetworkIdentifier{
+			Network:    bitcoin.TestnetNetwork,
+			Blockchain: bitcoin.Blockchain,
+		},
+		GenesisBlockIdentifier: testnetGenesisBlockIdentifier,
+		Params:                 testnetParams(t),
+		IndexerPath:            newDir,
+	}
+
+	mockClient := &mocks.Client{}
+	i, err := Initialize(ctx, cancel, cfg, mockClient)
+	assert.NoError(t, err)
+	defer i.CloseDatabase(ctx)
+
+	// The node is older than the built-in minimum
+	// protocol version of testnet, so no block is
+	// requested
+	mockClient.On("NetworkStatus", mock.Anything).Return(&types.NetworkStatusResponse{}, nil).Once()
+	mockClient.On("NodeNetwork", mock.Anything).Return(&bitcoin.NodeNetwork{
+		Chain:           "test",
+		GenesisHash:     testnetGenesisBlockIdentifier.Hash,
+		SubVersion:      "/Euno Core:5.2.0/",
+		ProtocolVersion: 70924,
+	}, nil).Once()
+
+	err = i.Sync(ctx)
+	assert.True(t, errors.Is(err, bitcoin.ErrNodeTooOld))
+	assert.Contains(t, err.Error(), "/Euno Core:5.2.0/")
+	mockClient.AssertExpectations(t)
+}
@@ -0,0 +1,107 @@
+// Copyright 2020 Coinbase, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package indexer
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/MNtank/rosetta-bitcoin/bitcoin"
+
+	"github.com/btcsuite/btcd/chaincfg"
+	"github.com/btcsuite/btcd/wire"
+	"github.com/coinbase/rosetta-sdk-go/storage/database"
+)
+
+const (
+	// paramsFingerprintKey stores the fingerprint of
+	// the params the database was first opened with.
+	paramsFingerprintKey = "params-fingerprint"
+)
+
+// ErrParamsMismatch is returned when the database was
+// created with the params of a different network (or
+// of the same network with different settings).
+var ErrParamsMismatch = errors.New("database was created with different params")
+
+// legacyNets are the upstream message starts the built-in
+// networks were fingerprinted with before they had their
+// own, keyed by their own.
+var legacyNets = map[wire.BitcoinNet]wire.BitcoinNet{
+	bitcoin.MainnetNet: wire.MainNet,
+}
+
+// legacyFingerprint returns the fingerprint params had with
+// the legacy message start of their network (empty if the
+// network has none).
+func legacyFingerprint(params *chaincfg.Params) string {
+	legacyNet, ok := legacyNets[params.Net]
+	if !ok {
+		return ""
+	}
+
+	legacy := *params
+	legacy.Net = legacyNet
+	return bitcoin.ParamsFingerprint(&legacy)
+}
+
+// checkParamsFingerprint records the fingerprint of params
+// in database when it is opened for the first time, and
+// returns ErrParamsMismatch if the recorded fingerprint
+// differs from it later (indexing a different network
+// into the same database silently corrupts balances).
+func checkParamsFingerprint(
+	ctx context.Context,
+	db database.Database,
+	params *chaincfg.Params,
+) error {
+	fingerprint := bitcoin.ParamsFingerprint(params)
+
+	dbTx := db.WriteTransaction(ctx, paramsFingerprintKey, false)
+	defer dbTx.Discard(ctx)
+
+	exists, value, err := dbTx.Get(ctx, []byte(paramsFingerprintKey))
+	if err != nil {
+		return fmt.Errorf("%w: unable to get params fingerprint", err)
+	}
+
+	// Nothing indexed depends on the message start (which
+	// is not reported by the node), so a database recording
+	// the legacy fingerprint of the network is updated.
+	if exists && string(value) == legacyFingerprint(params) {
+		exists = false
+	}
+
+	if exists {
+		if string(value) != fingerprint {
+			return fmt.Errorf(
+				"%w: %s has fingerprint %s but database has %s",
+				ErrParamsMismatch,
+				params.Name,
+				fingerprint,
+				string(value),
+			)
+		}
+
+		return nil
+	}
+
+	if err := dbTx.Set(ctx, []byte(paramsFingerprintKey), []byte(fingerprint), false); err != nil {
+		return fmt.Errorf("%w: unable to set params fingerprint", err)
+	}
+
+	return dbTx.Commit(ctx)
+}
@@ -0,0 +1,107 @@
+// Copyright 2020 Coinbase, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package indexer
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/MNtank/rosetta-bitcoin/bitcoin"
+	"github.com/MNtank/rosetta-bitcoin/configuration"
+	mocks "github.com/MNtank/rosetta-bitcoin/mocks/indexer"
+
+	"github.com/btcsuite/btcd/wire"
+	"github.com/coinbase/rosetta-sdk-go/types"
+	"github.com/coinbase/rosetta-sdk-go/utils"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParamsFingerprint(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	newDir, err := utils.CreateTempDir()
+	assert.NoError(t, err)
+	defer utils.RemoveTempDir(newDir)
+
+	cfg := &configuration.Configuration{
+		Network: &types.NetworkIdentifier{
+			Network:    bitcoin.MainnetNetwork,
+			Blockchain: bitcoin.Blockchain,
+		},
+		GenesisBlockIdentifier: bitcoin.MainnetGenesisBlockIdentifier,
+		Params:                 bitcoin.MainnetParams,
+		IndexerPath:            newDir,
+	}
+
+	// The fingerprint is recorded on first run
+	i, err := Initialize(ctx, cancel, cfg, &mocks.Client{})
+	assert.NoError(t, err)
+	i.CloseDatabase(ctx)
+
+	// Reopening with the same params succeeds
+	i, err = Initialize(ctx, cancel, cfg, &mocks.Client{})
+	assert.NoError(t, err)
+	i.CloseDatabase(ctx)
+
+	// Reopening with other params fails
+	cfg.Params = bitcoin.TestnetParams
+	_, err = Initialize(ctx, cancel, cfg, &mocks.Client{})
+	assert.True(t, errors.Is(err, ErrParamsMismatch))
+
+	// The database is closed on failure
+	cfg.Params = bitcoin.MainnetParams
+	i, err = Initialize(ctx, cancel, cfg, &mocks.Client{})
+	assert.NoError(t, err)
+	i.CloseDatabase(ctx)
+}
+
+func TestParamsFingerprint_LegacyNet(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	newDir, err := utils.CreateTempDir()
+	assert.NoError(t, err)
+	defer utils.RemoveTempDir(newDir)
+
+	// Mainnet used to have the upstream message start
+	legacy := bitcoin.CloneParams(bitcoin.MainnetParams)
+	legacy.Net = wire.MainNet
+	cfg := &configuration.Configuration{
+		Network: &types.NetworkIdentifier{
+			Network:    bitcoin.MainnetNetwork,
+			Blockchain: bitcoin.Blockchain,
+		},
+		GenesisBlockIdentifier: bitcoin.MainnetGenesisBlockIdentifier,
+		Params:                 legacy,
+		IndexerPath:            newDir,
+	}
+
+	i, err := Initialize(ctx, cancel, cfg, &mocks.Client{})
+	assert.NoError(t, err)
+	i.CloseDatabase(ctx)
+
+	// The fingerprint is updated to the current
+	// message start (without a resync).
+	cfg.Params = bitcoin.MainnetParams
+	i, err = Initialize(ctx, cancel, cfg, &mocks.Client{})
+	assert.NoError(t, err)
+	i.CloseDatabase(ctx)
+
+	cfg.Params = legacy
+	_, err = Initialize(ctx, cancel, cfg, &mocks.Client{})
+	assert.True(t, errors.Is(err, ErrParamsMismatch))
+}
@@ -0,0 +1,316 @@
+// Copyright 2020 Coinbase, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package indexer
+
+import (
+	"bufio"
+	"context"
+	"expvar"
+	"io/ioutil"
+	"os"
+	"runtime"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/coinbase/rosetta-sdk-go/syncer"
+)
+
+const (
+	// prefetchMemoryFraction is the fraction of available
+	// memory the syncer may use to cache prefetched blocks.
+	prefetchMemoryFraction = 0.25
+
+	// minPrefetchCacheSize is the smallest cache we
+	// provide to the syncer (regardless of available
+	// memory).
+	minPrefetchCacheSize = 64 << 20 // 64 MB
+
+	// maxPrefetchCacheSize is the largest cache we
+	// provide to the syncer (regardless of available
+	// memory).
+	maxPrefetchCacheSize = 4 * syncer.DefaultCacheSize
+
+	// minNormalizedBlockSize is the smallest block size
+	// used to normalize fetch latency. Fetching small blocks
+	// is dominated by fixed RPC overhead, so we don't want
+	// to mistake their latency for congestion.
+	minNormalizedBlockSize = 64 << 10 // 64 KB
+
+	// congestionFactor is how much slower (per byte) fetches
+	// must be than the fastest observed fetches before we
+	// consider the node congested.
+	congestionFactor = 2
+
+	// latencySmoothing is the weight of each new
+	// observation in the latency moving average.
+	latencySmoothing = 0.2
+
+	// baselineDrift is the weight of each new observation
+	// in the baseline latency when fetches are slower than
+	// the baseline. This allows the baseline to adapt when
+	// fetches become permanently slower (i.e. as blocks
+	// become more complex to serialize).
+	baselineDrift = 0.01
+
+	// fetchConcurrencyMultiplier is multiplied by
+	// runtime.NumCPU to determine the maximum number
+	// of concurrent block fetches.
+	fetchConcurrencyMultiplier = 4
+
+	// fetchDecreaseFactor is the factor the fetch limit
+	// is multiplied by when the node is congested.
+	fetchDecreaseFactor = 0.75
+
+	// cgroupV2MemoryLimit and cgroupV1MemoryLimit contain
+	// the memory limit of the container (if any).
+	cgroupV2MemoryLimit = "/sys/fs/cgroup/memory.max"
+	cgroupV1MemoryLimit = "/sys/fs/cgroup/memory/memory.limit_in_bytes"
+	procMemInfo         = "/proc/meminfo"
+)
+
+var (
+	// prefetchMetrics exposes the current prefetch
+	// settings of each network as expvar metrics.
+	prefetchMetrics = expvar.NewMap("indexer_prefetch")
+)
+
+// memoryLimit returns the memory available to the process
+// (the container limit if one is set, otherwise the total
+// memory of the host). If neither can be determined,
+// 0 is returned.
+func memoryLimit() uint64 {
+	for _, path := range []string{cgroupV2MemoryLimit, cgroupV1MemoryLimit} {
+		contents, err := ioutil.ReadFile(path) // #nosec G304
+		if err != nil {
+			continue
+		}
+
+		// cgroup v2 uses "max" to indicate no limit and cgroup
+		// v1 uses a very large number, so we only use the limit
+		// if it is smaller than the memory of the host.
+		limit, err := strconv.ParseUint(strings.TrimSpace(string(contents)), 10, 64)
+		if err != nil {
+			continue
+		}
+
+		if total := totalMemory(); total == 0 || limit < total {
+			return limit
+		}
+	}
+
+	return totalMemory()
+}
+
+// totalMemory returns the total memory of the host
+// (or 0 if it cannot be determined).
+func totalMemory() uint64 {
+	f, err := os.Open(procMemInfo)
+	if err != nil {
+		return 0
+	}
+	defer f.Close()
+
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) < 2 || fields[0] != "MemTotal:" { // nolint:gomnd
+			continue
+		}
+
+		kb, err := strconv.ParseUint(fields[1], 10, 64)
+		if err != nil {
+			return 0
+		}
+
+		return kb << 10 // nolint:gomnd
+	}
+
+	return 0
+}
+
+// prefetchCacheSize determines how much memory the syncer
+// may use to cache prefetched blocks based on the memory
+// headroom of the process (instead of a static value). The
+// syncer adjusts its concurrency to observed block sizes
+// to stay within this cache size.
+func prefetchCacheSize(limit uint64, heap uint64) int {
+	if limit == 0 {
+		return syncer.DefaultCacheSize
+	}
+
+	headroom := uint64(0)
+	if limit > heap {
+		headroom = limit - heap
+	}
+
+	cacheSize := int(float64(headroom) * prefetchMemoryFraction)
+	if cacheSize < minPrefetchCacheSize {
+		return minPrefetchCacheSize
+	}
+
+	if cacheSize > maxPrefetchCacheSize {
+		return maxPrefetchCacheSize
+	}
+
+	return cacheSize
+}
+
+// currentPrefetchCacheSize returns the prefetchCacheSize
+// given the current memory usage of the process.
+func currentPrefetchCacheSize(metrics *expvar.Map) int {
+	var m runtime.MemStats
+	runtime.ReadMemStats(&m)
+
+	cacheSize := prefetchCacheSize(memoryLimit(), m.HeapAlloc)
+	setIntMetric(metrics, "cache_size", int64(cacheSize))
+
+	return cacheSize
+}
+
+// fetchLimiter limits the number of concurrent block
+// fetches from the node. The limit is tuned using
+// additive-increase/multiplicative-decrease on the
+// observed fetch latency (normalized by block size):
+// when fetches slow down relative to the fastest observed
+// fetches, the node is congested and we back off.
+type fetchLimiter struct {
+	tokens chan struct{}
+
+	mutex     sync.Mutex
+	limit     int64
+	max       int64
+	withheld  int64
+	completed int64
+	baseline  float64
+	latency   float64
+
+	// metrics are the prefetch
+	// metrics of the network.
+	metrics *expvar.Map
+}
+
+func newFetchLimiter(initial int64, max int64, metrics *expvar.Map) *fetchLimiter {
+	if initial > max {
+		initial = max
+	}
+
+	l := &fetchLimiter{
+		tokens:  make(chan struct{}, max),
+		limit:   initial,
+		max:     max,
+		metrics: metrics,
+	}
+	for j := int64(0); j < initial; j++ {
+		l.tokens <- struct{}{}
+	}
+	setIntMetric(l.metrics, "fetch_limit", l.limit)
+
+	return l
+}
+
+// acquire blocks until a fetch is permitted.
+func (l *fetchLimiter) acquire(ctx context.Context) error {
+	select {
+	case <-l.tokens:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// skip permits another fetch without recording the
+// outcome of a fetch that failed for a reason other
+// than congestion (i.e. the block was pruned).
+func (l *fetchLimiter) skip() {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+
+	if l.withheld > 0 {
+		l.withheld--
+		return
+	}
+
+	l.tokens <- struct{}{}
+}
+
+// release records the outcome of a fetch (of a block
+// of size bytes) and permits another fetch.
+func (l *fetchLimiter) release(latency time.Duration, size int64, err error) {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+
+	congested := err != nil
+	if err == nil {
+		if size < minNormalizedBlockSize {
+			size = minNormalizedBlockSize
+		}
+
+		perByte := float64(latency) / float64(size)
+		if l.latency == 0 {
+			l.latency = perByte
+		} else {
+			l.latency = latencySmoothing*perByte + (1-latencySmoothing)*l.latency
+		}
+
+		if l.baseline == 0 || l.latency < l.baseline {
+			l.baseline = l.latency
+		} else {
+			l.baseline += baselineDrift * (l.latency - l.baseline)
+		}
+
+		congested = l.latency > congestionFactor*l.baseline
+	}
+
+	// We only adjust the limit once a full limit's worth
+	// of fetches complete after the last adjustment (so
+	// that fetches started before the adjustment don't
+	// trigger another).
+	l.completed++
+	if l.completed >= l.limit {
+		switch {
+		case congested && l.limit > 1:
+			newLimit := int64(float64(l.limit) * fetchDecreaseFactor)
+			if newLimit < 1 {
+				newLimit = 1
+			}
+
+			l.withheld += l.limit - newLimit
+			l.limit = newLimit
+			l.completed = 0
+			setIntMetric(l.metrics, "fetch_limit", l.limit)
+		case !congested && l.limit < l.max:
+			l.limit++
+			l.completed = 0
+			setIntMetric(l.metrics, "fetch_limit", l.limit)
+
+			if l.withheld > 0 {
+				l.withheld--
+			} else {
+				l.tokens <- struct{}{}
+			}
+		}
+	}
+
+	// Tokens withheld after a decrease are
+	// removed as fetches complete.
+	if l.withheld > 0 {
+		l.withheld--
+		return
+	}
+
+	l.tokens <- struct{}{}
+}
@@ -0,0 +1,101 @@
+// Copyright 2020 Coinbase, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package indexer
+
+import (
+	"context"
+	"errors"
+	"expvar"
+	"testing"
+	"time"
+
+	"github.com/coinbase/rosetta-sdk-go/syncer"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestPrefetchCacheSize(t *testing.T) {
+	tests := map[string]struct {
+		limit uint64
+		heap  uint64
+
+		cacheSize int
+	}{
+		"unknown limit": {
+			cacheSize: syncer.DefaultCacheSize,
+		},
+		"small container": {
+			limit:     1 << 30,
+			heap:      512 << 20,
+			cacheSize: 128 << 20,
+		},
+		"no headroom": {
+			limit:     1 << 30,
+			heap:      2 << 30,
+			cacheSize: minPrefetchCacheSize,
+		},
+		"large host": {
+			limit:     256 << 30,
+			cacheSize: maxPrefetchCacheSize,
+		},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			assert.Equal(t, test.cacheSize, prefetchCacheSize(test.limit, test.heap))
+		})
+	}
+}
+
+func TestFetchLimiter(t *testing.T) {
+	ctx := context.Background()
+	l := newFetchLimiter(2, 3, new(expvar.Map).Init())
+
+	fetch := func(latency time.Duration, err error) {
+		assert.NoError(t, l.acquire(ctx))
+		l.release(latency, 1<<20, err)
+	}
+
+	// Healthy fetches increase the limit up to the max
+	for j := 0; j < 10; j++ {
+		fetch(time.Millisecond, nil)
+	}
+	assert.Equal(t, int64(3), l.limit)
+	assert.Len(t, l.tokens, 3)
+
+	// Slow fetches decrease the limit
+	for j := 0; j < 10; j++ {
+		fetch(100*time.Millisecond, nil)
+	}
+	assert.Equal(t, int64(1), l.limit)
+	assert.Len(t, l.tokens, 1)
+
+	// Only one fetch is permitted at a time
+	assert.NoError(t, l.acquire(ctx))
+	canceled, cancel := context.WithCancel(ctx)
+	cancel()
+	assert.Error(t, l.acquire(canceled))
+	l.release(0, 0, errors.New("rpc error"))
+	assert.Equal(t, int64(1), l.limit)
+	assert.Len(t, l.tokens, 1)
+
+	// Skipped fetches do not change the limit
+	l = newFetchLimiter(3, 3, new(expvar.Map).Init())
+	for j := 0; j < 10; j++ {
+		assert.NoError(t, l.acquire(ctx))
+		l.skip()
+	}
+	assert.Equal(t, int64(3), l.limit)
+	assert.Len(t, l.tokens, 3)
+}
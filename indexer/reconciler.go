@@ -0,0 +1,489 @@
+// Copyright 2020 Coinbase, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package indexer
+
+import (
+	"context"
+	"errors"
+	"expvar"
+	"fmt"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/MNtank/rosetta-bitcoin/bitcoin"
+	"github.com/MNtank/rosetta-bitcoin/utils"
+
+	"github.com/coinbase/rosetta-sdk-go/storage/database"
+	storageErrs "github.com/coinbase/rosetta-sdk-go/storage/errors"
+	"github.com/coinbase/rosetta-sdk-go/types"
+	sdkUtils "github.com/coinbase/rosetta-sdk-go/utils"
+	"github.com/neilotoole/errgroup"
+	"golang.org/x/time/rate"
+)
+
+const (
+	// recentAccountsLimit is the maximum number of
+	// recently active accounts queued for reconciliation.
+	recentAccountsLimit = 10000
+
+	// reconciliationIdleInterval is how long the
+	// reconciliation worker waits when there are no
+	// accounts to reconcile (i.e. before the first block
+	// is synced).
+	reconciliationIdleInterval = 10 * time.Second
+)
+
+var (
+	// reconciliationMetrics exposes the progress of
+	// the reconciliation worker of each network as
+	// expvar metrics.
+	reconciliationMetrics = expvar.NewMap("indexer_reconciliation")
+
+	// ErrBalanceMismatch is returned when the balance of
+	// an account computed from its coins does not match
+	// the stored balance.
+	ErrBalanceMismatch = errors.New("computed balance does not match stored balance")
+
+	errBatchFull = errors.New("batch is full")
+
+	// errAccountForgotten is returned when reconciling
+	// an account that was forgotten (see ForgetAccount).
+	errAccountForgotten = errors.New("account was forgotten")
+
+	// errAccountWithoutCoins is returned when reconciling
+	// an account whose balance is not held in coins (see
+	// bitcoin.ZerocoinAccount).
+	errAccountWithoutCoins = errors.New("account holds no coins")
+)
+
+// recentAccountQueue is a bounded, deduplicated FIFO
+// of accounts that were recently active (so that they
+// are reconciled before the sweep reaches them).
+type recentAccountQueue struct {
+	limit int
+
+	mutex  sync.Mutex
+	queue  []*types.AccountCurrency
+	queued map[string]struct{}
+}
+
+func newRecentAccountQueue(limit int) *recentAccountQueue {
+	return &recentAccountQueue{
+		limit:  limit,
+		queued: map[string]struct{}{},
+	}
+}
+
+// add queues all accounts with a balance
+// change in block. When the queue is full,
+// the oldest accounts are dropped.
+func (q *recentAccountQueue) add(block *types.Block) {
+	if q.limit == 0 {
+		return
+	}
+
+	q.mutex.Lock()
+	defer q.mutex.Unlock()
+
+	for _, tx := range block.Transactions {
+		for _, op := range tx.Operations {
+			if op.Account == nil || op.Amount == nil {
+				continue
+			}
+
+			account := &types.AccountCurrency{
+				Account:  op.Account,
+				Currency: op.Amount.Currency,
+			}
+			key := types.Hash(account)
+			if _, ok := q.queued[key]; ok {
+				continue
+			}
+
+			if len(q.queue) == q.limit {
+				delete(q.queued, types.Hash(q.queue[0]))
+				q.queue = q.queue[1:]
+			}
+
+			q.queue = append(q.queue, account)
+			q.queued[key] = struct{}{}
+		}
+	}
+}
+
+// take removes (and returns) up to
+// n of the oldest queued accounts.
+func (q *recentAccountQueue) take(n int) []*types.AccountCurrency {
+	q.mutex.Lock()
+	defer q.mutex.Unlock()
+
+	if n > len(q.queue) {
+		n = len(q.queue)
+	}
+
+	accounts := q.queue[:n]
+	q.queue = q.queue[n:]
+	for _, account := range accounts {
+		delete(q.queued, types.Hash(account))
+	}
+
+	return accounts
+}
+
+// reconciliationSweep tracks the progress of a
+// pass over all accounts in balance storage.
+type reconciliationSweep struct {
+	// cursor is the last account key sampled
+	// (nil at the start of a pass).
+	cursor []byte
+
+	// accounts is the number of accounts in storage
+	// when the pass started.
+	accounts int64
+
+	// reconciled is the number of accounts sampled
+	// in this pass whose balance matched.
+	reconciled int64
+
+	// forgotten is the number of forgotten accounts
+	// sampled in this pass (see ForgetAccount).
+	forgotten int64
+}
+
+// coverage returns the proportion of accounts [0.0, 1.0]
+// reconciled in the current pass (excluding forgotten
+// accounts, which are never reconciled).
+func (s *reconciliationSweep) coverage() float64 {
+	accounts := s.accounts - s.forgotten
+	if accounts <= 0 {
+		return 0
+	}
+
+	if s.reconciled >= accounts {
+		return 1
+	}
+
+	return float64(s.reconciled) / float64(accounts)
+}
+
+// sweepAccounts returns up to n accounts stored
+// after cursor and the key of the last returned
+// account (nil if the end of storage was reached).
+func (i *Indexer) sweepAccounts(
+	ctx context.Context,
+	cursor []byte,
+	n int,
+) ([]*types.AccountCurrency, []byte, error) {
+	if n == 0 {
+		return nil, cursor, nil
+	}
+
+	dbTx := i.database.ReadTransaction(ctx)
+	defer dbTx.Discard(ctx)
+
+	prefix := []byte(accountNamespace + namespaceSeparator)
+	seekStart := prefix
+	if cursor != nil {
+		// Seek to the first key after the cursor.
+		seekStart = append(append([]byte{}, cursor...), 0)
+	}
+
+	accounts := []*types.AccountCurrency{}
+	var last []byte
+	_, err := dbTx.Scan(
+		ctx,
+		prefix,
+		seekStart,
+		func(k []byte, v []byte) error {
+			var account types.AccountCurrency
+			if err := i.database.Encoder().DecodeAccountCurrency(v, &account, false); err != nil {
+				return fmt.Errorf("%w: unable to decode account %s", err, string(k))
+			}
+
+			accounts = append(accounts, &account)
+			last = append(last[:0], k...)
+			if len(accounts) == n {
+				return errBatchFull
+			}
+
+			return nil
+		},
+		false,
+		false,
+	)
+	if errors.Is(err, errBatchFull) {
+		return accounts, last, nil
+	}
+	if err != nil {
+		return nil, nil, fmt.Errorf("%w: unable to scan accounts", err)
+	}
+
+	return accounts, nil, nil
+}
+
+// reconcileAccount recomputes the balance of an account
+// from its coins and compares it against its stored balance
+// (both read in the same database transaction).
+func (i *Indexer) reconcileAccount(
+	ctx context.Context,
+	account *types.AccountCurrency,
+) error {
+	dbTx := i.database.ReadTransaction(ctx)
+	defer dbTx.Discard(ctx)
+
+	tombstone, err := getAccountTombstone(ctx, dbTx, account.Account.Address)
+	if err != nil {
+		return err
+	}
+
+	if tombstone != nil {
+		return errAccountForgotten
+	}
+
+	if bitcoin.IsZerocoinAccount(account.Account) {
+		return errAccountWithoutCoins
+	}
+
+	computed, head, err := i.computeBalance(ctx, dbTx, account)
+	if err != nil {
+		return err
+	}
+
+	stored, err := i.balanceStorage.GetBalanceTransactional(
+		ctx,
+		dbTx,
+		account.Account,
+		account.Currency,
+		head.Index,
+	)
+	if errors.Is(err, storageErrs.ErrAccountMissing) {
+		stored = &types.Amount{Value: zeroValue, Currency: account.Currency}
+		err = nil
+	}
+	if err != nil {
+		return fmt.Errorf("%w: unable to get balance of %s", err, account.Account.Address)
+	}
+
+	if stored.Value != computed {
+		return fmt.Errorf(
+			"%w: %s has computed balance %s and stored balance %s at %d",
+			ErrBalanceMismatch,
+			account.Account.Address,
+			computed,
+			stored.Value,
+			head.Index,
+		)
+	}
+
+	return nil
+}
+
+// computeBalance sums the unspent coins of an account
+// (and any genesis allocation, which does not create
+// a coin) and returns the head block they were read at.
+func (i *Indexer) computeBalance(
+	ctx context.Context,
+	dbTx database.Transaction,
+	account *types.AccountCurrency,
+) (string, *types.BlockIdentifier, error) {
+	coins, head, err := i.coinStorage.GetCoinsTransactional(ctx, dbTx, account.Account)
+	if err != nil {
+		return "", nil, fmt.Errorf("%w: unable to get coins of %s", err, account.Account.Address)
+	}
+
+	balance := zeroValue
+	if value, ok := i.genesisAllocations[account.Account.Address]; ok {
+		balance = strconv.FormatInt(value, 10)
+	}
+
+	for _, coin := range coins {
+		if types.Hash(coin.Amount.Currency) != types.Hash(account.Currency) {
+			continue
+		}
+
+		balance, err = types.AddValues(balance, coin.Amount.Value)
+		if err != nil {
+			return "", nil, fmt.Errorf("%w: unable to add coin amount", err)
+		}
+	}
+
+	return balance, head, nil
+}
+
+// reconcileBatch reconciles accounts with at most concurrency
+// accounts in flight (each waiting on limiter) and returns the
+// number of accounts whose balance matched and the number of
+// forgotten accounts.
+func (i *Indexer) reconcileBatch(
+	ctx context.Context,
+	accounts []*types.AccountCurrency,
+	limiter *rate.Limiter,
+	concurrency int,
+) (int64, int64, error) {
+	logger := utils.ExtractLogger(ctx, "reconciler")
+	metrics := utils.NetworkMetrics(reconciliationMetrics, i.network)
+
+	var reconciled, forgotten int64
+	var reconciledMutex sync.Mutex
+	g, gctx := errgroup.WithContextN(ctx, concurrency, len(accounts))
+	for _, account := range accounts {
+		account := account
+		g.Go(func() error {
+			if err := limiter.Wait(gctx); err != nil {
+				return err
+			}
+
+			err := i.reconcileAccount(gctx, account)
+			switch {
+			case err == nil:
+				reconciledMutex.Lock()
+				reconciled++
+				reconciledMutex.Unlock()
+				metrics.Add("reconciled", 1)
+			case errors.Is(err, errAccountForgotten):
+				// Forgotten accounts are intentionally
+				// not reconciled (and are excluded from
+				// coverage).
+				reconciledMutex.Lock()
+				forgotten++
+				reconciledMutex.Unlock()
+				metrics.Add("forgotten", 1)
+			case errors.Is(err, errAccountWithoutCoins):
+				// Accounts without coins can't be
+				// reconciled (but count towards
+				// coverage).
+				reconciledMutex.Lock()
+				reconciled++
+				reconciledMutex.Unlock()
+				metrics.Add("without coins", 1)
+			case errors.Is(err, ErrBalanceMismatch):
+				metrics.Add("mismatches", 1)
+				logger.Errorw("balance mismatch", "error", err)
+			case gctx.Err() != nil:
+				return gctx.Err()
+			default:
+				// Accounts can be pruned or removed by a reorg
+				// while they are being reconciled, so failing to
+				// reconcile an account should not halt the worker.
+				metrics.Add("errors", 1)
+				logger.Warnw("unable to reconcile account", "error", err)
+			}
+
+			return nil
+		})
+	}
+
+	if err := g.Wait(); err != nil {
+		return -1, -1, err
+	}
+
+	return reconciled, forgotten, nil
+}
+
+// MonitorReconciliation continuously samples batches of accounts
+// (recently active accounts first, then a sweep of all accounts
+// in storage), recomputes their balances from their coins, and
+// compares them against stored balances until the context is
+// canceled. At most accountsPerSecond accounts are reconciled
+// each second.
+func (i *Indexer) MonitorReconciliation(
+	ctx context.Context,
+	accountsPerSecond float64,
+	batchSize int,
+	concurrency int,
+) error {
+	logger := utils.ExtractLogger(ctx, "reconciler")
+	metrics := utils.NetworkMetrics(reconciliationMetrics, i.network)
+	limiter := rate.NewLimiter(rate.Limit(accountsPerSecond), concurrency)
+
+	sweep := &reconciliationSweep{}
+	for ctx.Err() == nil {
+		if sweep.cursor == nil {
+			dbTx := i.database.ReadTransaction(ctx)
+			accounts, err := countKeys(ctx, dbTx, accountNamespace, nil)
+			dbTx.Discard(ctx)
+			if err != nil {
+				return err
+			}
+
+			sweep.accounts = accounts
+			sweep.reconciled = 0
+			sweep.forgotten = 0
+		}
+
+		recent := i.recentAccounts.take(batchSize / 2)
+		sampled, cursor, err := i.sweepAccounts(ctx, sweep.cursor, batchSize-len(recent))
+		if err != nil {
+			return err
+		}
+
+		// Storage is empty (the end of storage was reached
+		// without a cursor).
+		if len(recent) == 0 && len(sampled) == 0 && sweep.cursor == nil {
+			if err := sdkUtils.ContextSleep(ctx, reconciliationIdleInterval); err != nil {
+				return err
+			}
+
+			continue
+		}
+
+		if _, _, err := i.reconcileBatch(ctx, recent, limiter, concurrency); err != nil {
+			return err
+		}
+
+		reconciled, forgotten, err := i.reconcileBatch(ctx, sampled, limiter, concurrency)
+		if err != nil {
+			return err
+		}
+
+		sweep.reconciled += reconciled
+		sweep.forgotten += forgotten
+		sweep.cursor = cursor
+		setReconciliationCoverage(metrics, sweep.coverage())
+
+		if sweep.cursor == nil {
+			metrics.Add("passes", 1)
+			logger.Infow(
+				"reconciliation pass completed",
+				"accounts", sweep.accounts,
+				"reconciled", sweep.reconciled,
+				"forgotten", sweep.forgotten,
+				"coverage", sweep.coverage(),
+			)
+		}
+	}
+
+	return ctx.Err()
+}
+
+// setReconciliationCoverage sets the
+// reconciliation coverage metric.
+func setReconciliationCoverage(metrics *expvar.Map, coverage float64) {
+	metric := new(expvar.Float)
+	metric.Set(coverage)
+	metrics.Set("coverage", metric)
+}
+
+// genesisAllocationValues returns the value allocated
+// to each address in the genesis block.
+func genesisAllocationValues(allocations []*bitcoin.GenesisAllocation) map[string]int64 {
+	values := map[string]int64{}
+	for _, allocation := range allocations {
+		values[allocation.Address] += allocation.Value
+	}
+
+	return values
+}
@@ -0,0 +1,229 @@
+// Copyright 2020 Coinbase, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package indexer
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+
+	"github.com/MNtank/rosetta-bitcoin/bitcoin"
+	"github.com/MNtank/rosetta-bitcoin/configuration"
+
+	"github.com/coinbase/rosetta-sdk-go/types"
+	"github.com/coinbase/rosetta-sdk-go/utils"
+	"github.com/stretchr/testify/assert"
+	"golang.org/x/time/rate"
+)
+
+func accountBlock(addresses ...string) *types.Block {
+	ops := []*types.Operation{}
+	for j, address := range addresses {
+		ops = append(ops, &types.Operation{
+			OperationIdentifier: &types.OperationIdentifier{Index: int64(j)},
+			Account:             &types.AccountIdentifier{Address: address},
+			Amount:              &types.Amount{Value: "1", Currency: bitcoin.MainnetCurrency},
+		})
+	}
+
+	return &types.Block{
+		Transactions: []*types.Transaction{{Operations: ops}},
+	}
+}
+
+func TestRecentAccountQueue(t *testing.T) {
+	queue := newRecentAccountQueue(2)
+	queue.add(accountBlock("a", "b", "a"))
+	queue.add(accountBlock("c"))
+
+	// The oldest account is dropped when full
+	accounts := queue.take(3)
+	assert.Len(t, accounts, 2)
+	assert.Equal(t, "b", accounts[0].Account.Address)
+	assert.Equal(t, "c", accounts[1].Account.Address)
+	assert.Len(t, queue.take(1), 0)
+
+	// Accounts can be queued again once taken
+	queue.add(accountBlock("b"))
+	assert.Len(t, queue.take(1), 1)
+
+	// Accounts are not queued when disabled
+	disabled := newRecentAccountQueue(0)
+	disabled.add(accountBlock("a"))
+	assert.Len(t, disabled.take(1), 0)
+}
+
+func TestReconcileAccounts(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	newDir, err := utils.CreateTempDir()
+	assert.NoError(t, err)
+	defer utils.RemoveTempDir(newDir)
+
+	cfg := &configuration.Configuration{
+		Network: &types.NetworkIdentifier{
+			Network:    bitcoin.MainnetNetwork,
+			Blockchain: bitcoin.Blockchain,
+		},
+		GenesisBlockIdentifier: bitcoin.MainnetGenesisBlockIdentifier,
+		GenesisAllocations: []*bitcoin.GenesisAllocation{
+			{Address: "genesis", Value: 500},
+		},
+		IndexerPath:        newDir,
+		ReconciliationRate: 100,
+	}
+
+	i, err := Initialize(ctx, cancel, cfg, nil)
+	assert.NoError(t, err)
+	defer i.CloseDatabase(ctx)
+
+	// Nothing to sweep
+	accounts, cursor, err := i.sweepAccounts(ctx, nil, 10)
+	assert.NoError(t, err)
+	assert.Len(t, accounts, 0)
+	assert.Nil(t, cursor)
+
+	i.blockStorage.Initialize(i.workers)
+	genesis := &types.Block{
+		BlockIdentifier:       &types.BlockIdentifier{Index: 0, Hash: getBlockHash(0)},
+		ParentBlockIdentifier: &types.BlockIdentifier{Index: 0, Hash: getBlockHash(0)},
+		Transactions: []*types.Transaction{
+			{
+				TransactionIdentifier: &types.TransactionIdentifier{
+					Hash: bitcoin.GenesisAllocationsTransactionHash,
+				},
+				Operations: []*types.Operation{
+					{
+						OperationIdentifier: &types.OperationIdentifier{Index: 0},
+						Type:                bitcoin.GenesisAllocationOpType,
+						Status:              types.String(bitcoin.SuccessStatus),
+						Account:             &types.AccountIdentifier{Address: "genesis"},
+						Amount: &types.Amount{
+							Value:    "500",
+							Currency: bitcoin.MainnetCurrency,
+						},
+					},
+				},
+			},
+		},
+	}
+	assert.NoError(t, i.BlockAdded(ctx, genesis))
+
+	outputs := []*types.Operation{}
+	for j := 0; j < 3; j++ {
+		outputs = append(outputs, &types.Operation{
+			OperationIdentifier: &types.OperationIdentifier{
+				Index:        int64(j),
+				NetworkIndex: types.Int64(int64(j)),
+			},
+			Type:    bitcoin.OutputOpType,
+			Status:  types.String(bitcoin.SuccessStatus),
+			Account: &types.AccountIdentifier{Address: fmt.Sprintf("addr %d", j%2)},
+			Amount: &types.Amount{
+				Value:    fmt.Sprintf("%d", 10+j),
+				Currency: bitcoin.MainnetCurrency,
+			},
+			CoinChange: &types.CoinChange{
+				CoinIdentifier: &types.CoinIdentifier{
+					Identifier: fmt.Sprintf("%s:%d", getBlockHash(1), j),
+				},
+				CoinAction: types.CoinCreated,
+			},
+		})
+	}
+	assert.NoError(t, i.BlockAdded(ctx, &types.Block{
+		BlockIdentifier:       &types.BlockIdentifier{Index: 1, Hash: getBlockHash(1)},
+		ParentBlockIdentifier: genesis.BlockIdentifier,
+		Transactions: []*types.Transaction{
+			{
+				TransactionIdentifier: &types.TransactionIdentifier{Hash: getBlockHash(1)},
+				Operations:            outputs,
+			},
+		},
+	}))
+
+	// Recently active accounts are queued
+	recent := i.recentAccounts.take(10)
+	assert.Len(t, recent, 3)
+
+	// Sweep all accounts in batches
+	accounts, cursor, err = i.sweepAccounts(ctx, nil, 2)
+	assert.NoError(t, err)
+	assert.Len(t, accounts, 2)
+	assert.NotNil(t, cursor)
+
+	remaining, cursor, err := i.sweepAccounts(ctx, cursor, 2)
+	assert.NoError(t, err)
+	assert.Len(t, remaining, 1)
+	assert.Nil(t, cursor)
+	accounts = append(accounts, remaining...)
+
+	limiter := rate.NewLimiter(rate.Inf, 1)
+	reconciled, forgotten, err := i.reconcileBatch(ctx, accounts, limiter, 2)
+	assert.NoError(t, err)
+	assert.Equal(t, int64(3), reconciled)
+	assert.Equal(t, int64(0), forgotten)
+
+	// Corrupt a stored balance
+	dbTx := i.database.WriteTransaction(ctx, "", true)
+	assert.NoError(t, i.balanceStorage.SetBalance(
+		ctx,
+		dbTx,
+		&types.AccountIdentifier{Address: "addr 0"},
+		&types.Amount{Value: "1", Currency: bitcoin.MainnetCurrency},
+		&types.BlockIdentifier{Index: 1, Hash: getBlockHash(1)},
+	))
+	assert.NoError(t, dbTx.Commit(ctx))
+
+	err = i.reconcileAccount(ctx, &types.AccountCurrency{
+		Account:  &types.AccountIdentifier{Address: "addr 0"},
+		Currency: bitcoin.MainnetCurrency,
+	})
+	assert.True(t, errors.Is(err, ErrBalanceMismatch))
+
+	reconciled, _, err = i.reconcileBatch(ctx, accounts, limiter, 2)
+	assert.NoError(t, err)
+	assert.Equal(t, int64(2), reconciled)
+
+	// The zerocoin account holds no coins
+	err = i.reconcileAccount(ctx, &types.AccountCurrency{
+		Account:  bitcoin.ZerocoinAccount(),
+		Currency: bitcoin.MainnetCurrency,
+	})
+	assert.True(t, errors.Is(err, errAccountWithoutCoins))
+}
+
+func TestReconciliationSweepCoverage(t *testing.T) {
+	sweep := &reconciliationSweep{}
+	assert.Equal(t, float64(0), sweep.coverage())
+
+	sweep.accounts = 4
+	sweep.reconciled = 1
+	assert.Equal(t, 0.25, sweep.coverage())
+
+	// Accounts created during a pass
+	sweep.reconciled = 5
+	assert.Equal(t, float64(1), sweep.coverage())
+
+	// Forgotten accounts are excluded
+	sweep.reconciled = 1
+	sweep.forgotten = 2
+	assert.Equal(t, 0.5, sweep.coverage())
+
+	sweep.forgotten = 4
+	assert.Equal(t, float64(0), sweep.coverage())
+}
@@ -0,0 +1,557 @@
+// Copyright 2020 Coinbase, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package indexer
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math/big"
+	"sync"
+	"time"
+
+	"github.com/MNtank/rosetta-bitcoin/bitcoin"
+	"github.com/MNtank/rosetta-bitcoin/services"
+	"github.com/MNtank/rosetta-bitcoin/utils"
+
+	"github.com/btcsuite/btcd/chaincfg"
+	"github.com/coinbase/rosetta-sdk-go/types"
+	sdkUtils "github.com/coinbase/rosetta-sdk-go/utils"
+)
+
+const (
+	// reorgDrillPollInterval is how often a reorg drill
+	// checks if the indexer reached the tip of the node.
+	reorgDrillPollInterval = time.Second
+
+	// reorgDrillReconsiderTimeout is how long a reorg drill
+	// waits for the node to reconsider the invalidated block
+	// (even if the drill was cancelled).
+	reorgDrillReconsiderTimeout = time.Minute
+
+	// regtestChain is the chain reported by
+	// nodes in regression test mode.
+	regtestChain = "regtest"
+)
+
+var (
+	// ErrReorgDrillFailed is returned when the indexer
+	// did not process a reorg orchestrated by a drill
+	// as expected.
+	ErrReorgDrillFailed = errors.New("reorg drill failed")
+
+	// ErrInvalidReorgDrill is returned when a reorg drill
+	// can't be run against the current chain.
+	ErrInvalidReorgDrill = errors.New("invalid reorg drill")
+)
+
+// ReorgDrillNode is the node a reorg drill
+// orchestrates a reorg with.
+type ReorgDrillNode interface {
+	InvalidateBlock(ctx context.Context, hash string) error
+	ReconsiderBlock(ctx context.Context, hash string) error
+	GenerateToAddress(ctx context.Context, blocks int64, address string) ([]string, error)
+}
+
+// ReorgDrillAPI is the Rosetta API (usually served
+// in-process) a reorg drill verifies.
+type ReorgDrillAPI interface {
+	Block(context.Context, *types.BlockRequest) (*types.BlockResponse, *types.Error)
+	BlockTransaction(
+		context.Context,
+		*types.BlockTransactionRequest,
+	) (*types.BlockTransactionResponse, *types.Error)
+	AccountBalance(
+		context.Context,
+		*types.AccountBalanceRequest,
+	) (*types.AccountBalanceResponse, *types.Error)
+	AccountCoins(
+		context.Context,
+		*types.AccountCoinsRequest,
+	) (*types.AccountCoinsResponse, *types.Error)
+}
+
+// ReorgDrill summarizes a reorg
+// verified by RunReorgDrill.
+type ReorgDrill struct {
+	// OrphanedBlocks are the blocks removed by the
+	// reorg and NewBlocks the blocks that replaced
+	// them (both in ascending order).
+	OrphanedBlocks []*types.BlockIdentifier `json:"orphaned_blocks"`
+	NewBlocks      []*types.BlockIdentifier `json:"new_blocks"`
+
+	// Events are the block events committed
+	// while processing the reorg.
+	Events int64 `json:"events"`
+
+	// Balance is the balance of the address
+	// the new blocks paid to.
+	Balance *types.Amount `json:"balance"`
+}
+
+// RunReorgDrill orchestrates a reorg of the last depth blocks
+// with node (replacing them with depth+1 blocks paying to
+// address) and verifies that the events log, api's /block
+// responses and the balance of address reflect it. The
+// indexer must be syncing and it is only supported against
+// nodes in regression test mode (never on mainnet or testnet).
+// The invalidated block is always reconsidered, so the node
+// reorgs back to it if the drill fails.
+func (i *Indexer) RunReorgDrill(
+	ctx context.Context,
+	node ReorgDrillNode,
+	api ReorgDrillAPI,
+	depth int64,
+	address string,
+) (drill *ReorgDrill, err error) {
+	logger := utils.ExtractLogger(ctx, "indexer")
+	if err := i.checkReorgDrillNetwork(ctx); err != nil {
+		return nil, err
+	}
+
+	if depth <= 0 {
+		return nil, fmt.Errorf("%w: depth %d is not positive", ErrInvalidReorgDrill, depth)
+	}
+
+	if i.reorgDepthLimit > 0 && depth > i.reorgDepthLimit {
+		return nil, fmt.Errorf(
+			"%w: depth %d exceeds the reorg depth limit %d",
+			ErrInvalidReorgDrill,
+			depth,
+			i.reorgDepthLimit,
+		)
+	}
+
+	status, err := i.client.NetworkStatus(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("%w: unable to get network status", err)
+	}
+
+	head, err := i.waitForBlock(ctx, status.CurrentBlockIdentifier.Hash)
+	if err != nil {
+		return nil, err
+	}
+
+	// The genesis block can't be reorged.
+	if depth > head.Index {
+		return nil, fmt.Errorf(
+			"%w: depth %d exceeds the height of head %d",
+			ErrInvalidReorgDrill,
+			depth,
+			head.Index,
+		)
+	}
+
+	orphaned := make([]*types.Block, 0, depth)
+	for index := head.Index - depth + 1; index <= head.Index; index++ {
+		block, err := i.drillBlock(ctx, api, &types.PartialBlockIdentifier{Index: &index})
+		if err != nil {
+			return nil, err
+		}
+
+		orphaned = append(orphaned, block)
+	}
+
+	var eventsMutex sync.Mutex
+	events := []*types.BlockEvent{}
+	i.SubscribeBlockEvents(func(event *types.BlockEvent) {
+		eventsMutex.Lock()
+		defer eventsMutex.Unlock()
+
+		events = append(events, event)
+	})
+
+	fork := orphaned[0].BlockIdentifier
+	logger.Infow("invalidating block", "block", types.PrintStruct(fork), "depth", depth)
+	if err := node.InvalidateBlock(ctx, fork.Hash); err != nil {
+		return nil, err
+	}
+
+	defer func() {
+		// The drill may have been cancelled, so the
+		// block is reconsidered with a new context.
+		reconsiderCtx, cancel := context.WithTimeout(
+			context.Background(),
+			reorgDrillReconsiderTimeout,
+		)
+		defer cancel()
+
+		if rErr := node.ReconsiderBlock(reconsiderCtx, fork.Hash); rErr != nil {
+			logger.Errorw("unable to reconsider block", "block", types.PrintStruct(fork), "error", rErr)
+			if err == nil {
+				drill, err = nil, rErr
+			}
+		}
+	}()
+
+	hashes, err := node.GenerateToAddress(ctx, depth+1, address)
+	if err != nil {
+		return nil, err
+	}
+	if len(hashes) == 0 {
+		return nil, fmt.Errorf("%w: node generated no blocks", ErrReorgDrillFailed)
+	}
+
+	tip, err := i.waitForBlock(ctx, hashes[len(hashes)-1])
+	if err != nil {
+		return nil, err
+	}
+	logger.Infow("indexer reached new tip", "block", types.PrintStruct(tip))
+
+	drill = &ReorgDrill{}
+	added := make([]*types.Block, 0, len(hashes))
+	for j, hash := range hashes {
+		index := fork.Index + int64(j)
+		block, err := i.drillBlock(ctx, api, &types.PartialBlockIdentifier{Index: &index})
+		if err != nil {
+			return nil, err
+		}
+
+		if block.BlockIdentifier.Hash != hash {
+			return nil, fmt.Errorf(
+				"%w: block %d is %s but node generated %s",
+				ErrReorgDrillFailed,
+				index,
+				block.BlockIdentifier.Hash,
+				hash,
+			)
+		}
+
+		added = append(added, block)
+		drill.NewBlocks = append(drill.NewBlocks, block.BlockIdentifier)
+	}
+
+	for _, block := range orphaned {
+		drill.OrphanedBlocks = append(drill.OrphanedBlocks, block.BlockIdentifier)
+		if err := verifyBlockOrphaned(ctx, api, i.network, block.BlockIdentifier); err != nil {
+			return nil, err
+		}
+	}
+
+	eventsMutex.Lock()
+	drill.Events = int64(len(events))
+	err = verifyReorgEvents(events, drill.OrphanedBlocks, drill.NewBlocks)
+	eventsMutex.Unlock()
+	if err != nil {
+		return nil, err
+	}
+
+	drill.Balance, err = verifyReorgBalance(ctx, api, i.network, address, tip, orphaned, added)
+	if err != nil {
+		return nil, err
+	}
+
+	return drill, nil
+}
+
+// checkReorgDrillNetwork returns ErrInvalidReorgDrill
+// unless the indexer indexes a network other than mainnet
+// and testnet (told apart by their message start, which
+// no other network may use) and its node is in regression
+// test mode, so a drill never forks a production node.
+func (i *Indexer) checkReorgDrillNetwork(ctx context.Context) error {
+	for _, production := range []*chaincfg.Params{bitcoin.MainnetParams, bitcoin.TestnetParams} {
+		if i.params.Net == production.Net {
+			return fmt.Errorf(
+				"%w: reorgs are never drilled on %s",
+				ErrInvalidReorgDrill,
+				production.Name,
+			)
+		}
+	}
+
+	node, err := i.client.NodeNetwork(ctx)
+	if err != nil {
+		return fmt.Errorf("%w: unable to get network of node", err)
+	}
+
+	if node.Chain != regtestChain {
+		return fmt.Errorf(
+			"%w: node serves the %s chain (reorgs are only drilled on %s)",
+			ErrInvalidReorgDrill,
+			node.Chain,
+			regtestChain,
+		)
+	}
+
+	return nil
+}
+
+// waitForBlock returns the head of the indexer
+// once it is the block with hash.
+func (i *Indexer) waitForBlock(ctx context.Context, hash string) (*types.BlockIdentifier, error) {
+	logger := utils.ExtractLogger(ctx, "indexer")
+	for {
+		head, err := i.blockStorage.GetHeadBlockIdentifier(ctx)
+		if err == nil && head.Hash == hash {
+			return head, nil
+		}
+
+		logger.Infow("waiting for indexer to reach block", "hash", hash)
+		if err := sdkUtils.ContextSleep(ctx, reorgDrillPollInterval); err != nil {
+			return nil, err
+		}
+	}
+}
+
+// drillBlock returns the block with identifier
+// (with all of its transactions) served by api.
+func (i *Indexer) drillBlock(
+	ctx context.Context,
+	api ReorgDrillAPI,
+	identifier *types.PartialBlockIdentifier,
+) (*types.Block, error) {
+	response, rErr := api.Block(ctx, &types.BlockRequest{
+		NetworkIdentifier: i.network,
+		BlockIdentifier:   identifier,
+	})
+	if rErr != nil {
+		return nil, fmt.Errorf(
+			"%w: unable to get block %s: %s",
+			ErrReorgDrillFailed,
+			types.PrintStruct(identifier),
+			types.PrintStruct(rErr),
+		)
+	}
+
+	if response.Block == nil {
+		return nil, fmt.Errorf(
+			"%w: block %s was not served",
+			ErrReorgDrillFailed,
+			types.PrintStruct(identifier),
+		)
+	}
+
+	// Transactions of large blocks are
+	// fetched individually.
+	for _, otherTx := range response.OtherTransactions {
+		txResponse, rErr := api.BlockTransaction(ctx, &types.BlockTransactionRequest{
+			NetworkIdentifier:     i.network,
+			BlockIdentifier:       response.Block.BlockIdentifier,
+			TransactionIdentifier: otherTx,
+		})
+		if rErr != nil {
+			return nil, fmt.Errorf(
+				"%w: unable to get transaction %s of block %s: %s",
+				ErrReorgDrillFailed,
+				otherTx.Hash,
+				types.PrintStruct(response.Block.BlockIdentifier),
+				types.PrintStruct(rErr),
+			)
+		}
+
+		response.Block.Transactions = append(
+			response.Block.Transactions,
+			txResponse.Transaction,
+		)
+	}
+
+	return response.Block, nil
+}
+
+// verifyBlockOrphaned returns an error if api still
+// serves the orphaned block by hash without flagging
+// it as orphaned (see services.OrphanedMetadataKey).
+func verifyBlockOrphaned(
+	ctx context.Context,
+	api ReorgDrillAPI,
+	network *types.NetworkIdentifier,
+	orphaned *types.BlockIdentifier,
+) error {
+	response, rErr := api.Block(ctx, &types.BlockRequest{
+		NetworkIdentifier: network,
+		BlockIdentifier:   &types.PartialBlockIdentifier{Hash: &orphaned.Hash},
+	})
+	if rErr == nil && response.Block != nil &&
+		response.Block.Metadata[services.OrphanedMetadataKey] != true {
+		return fmt.Errorf(
+			"%w: orphaned block %s is still served",
+			ErrReorgDrillFailed,
+			types.PrintStruct(orphaned),
+		)
+	}
+
+	return nil
+}
+
+// verifyReorgEvents returns an error unless events are
+// the removal of orphaned (from the highest block) followed
+// by the addition of added.
+func verifyReorgEvents(
+	events []*types.BlockEvent,
+	orphaned []*types.BlockIdentifier,
+	added []*types.BlockIdentifier,
+) error {
+	expected := make([]*types.BlockEvent, 0, len(orphaned)+len(added))
+	for j := len(orphaned) - 1; j >= 0; j-- {
+		expected = append(expected, &types.BlockEvent{
+			BlockIdentifier: orphaned[j],
+			Type:            types.REMOVED,
+		})
+	}
+	for _, block := range added {
+		expected = append(expected, &types.BlockEvent{
+			BlockIdentifier: block,
+			Type:            types.ADDED,
+		})
+	}
+
+	if len(events) != len(expected) {
+		return fmt.Errorf(
+			"%w: expected %d block events but found %d",
+			ErrReorgDrillFailed,
+			len(expected),
+			len(events),
+		)
+	}
+
+	for j, event := range events {
+		if event.Type != expected[j].Type ||
+			types.Hash(event.BlockIdentifier) != types.Hash(expected[j].BlockIdentifier) {
+			return fmt.Errorf(
+				"%w: expected event %d to be %s of %s but found %s of %s",
+				ErrReorgDrillFailed,
+				j,
+				expected[j].Type,
+				types.PrintStruct(expected[j].BlockIdentifier),
+				event.Type,
+				types.PrintStruct(event.BlockIdentifier),
+			)
+		}
+
+		if j > 0 && event.Sequence != events[j-1].Sequence+1 {
+			return fmt.Errorf(
+				"%w: event %d has sequence %d after %d",
+				ErrReorgDrillFailed,
+				j,
+				event.Sequence,
+				events[j-1].Sequence,
+			)
+		}
+	}
+
+	return nil
+}
+
+// transactionHashes returns the hashes of the
+// transactions of blocks.
+func transactionHashes(blocks []*types.Block) map[string]struct{} {
+	hashes := map[string]struct{}{}
+	for _, block := range blocks {
+		for _, tx := range block.Transactions {
+			hashes[tx.TransactionIdentifier.Hash] = struct{}{}
+		}
+	}
+
+	return hashes
+}
+
+// verifyReorgBalance returns the balance of address served by
+// api at tip once it has verified that it is the sum of the
+// coins of address and that none of the coins were created by
+// a transaction only included in orphaned blocks.
+func verifyReorgBalance(
+	ctx context.Context,
+	api ReorgDrillAPI,
+	network *types.NetworkIdentifier,
+	address string,
+	tip *types.BlockIdentifier,
+	orphaned []*types.Block,
+	added []*types.Block,
+) (*types.Amount, error) {
+	account := &types.AccountIdentifier{Address: address}
+	balance, rErr := api.AccountBalance(ctx, &types.AccountBalanceRequest{
+		NetworkIdentifier: network,
+		AccountIdentifier: account,
+	})
+	if rErr != nil {
+		return nil, fmt.Errorf(
+			"%w: unable to get balance of %s: %s",
+			ErrReorgDrillFailed,
+			address,
+			types.PrintStruct(rErr),
+		)
+	}
+
+	coins, rErr := api.AccountCoins(ctx, &types.AccountCoinsRequest{
+		NetworkIdentifier: network,
+		AccountIdentifier: account,
+	})
+	if rErr != nil {
+		return nil, fmt.Errorf(
+			"%w: unable to get coins of %s: %s",
+			ErrReorgDrillFailed,
+			address,
+			types.PrintStruct(rErr),
+		)
+	}
+
+	if types.Hash(balance.BlockIdentifier) != types.Hash(tip) ||
+		types.Hash(coins.BlockIdentifier) != types.Hash(tip) {
+		return nil, fmt.Errorf(
+			"%w: balance and coins of %s are not at tip %s",
+			ErrReorgDrillFailed,
+			address,
+			types.PrintStruct(tip),
+		)
+	}
+
+	if len(balance.Balances) != 1 {
+		return nil, fmt.Errorf(
+			"%w: expected 1 balance of %s but found %d",
+			ErrReorgDrillFailed,
+			address,
+			len(balance.Balances),
+		)
+	}
+
+	orphanedTxs := transactionHashes(orphaned)
+	addedTxs := transactionHashes(added)
+	sum := big.NewInt(0)
+	for _, coin := range coins.Coins {
+		hash, _, err := bitcoin.ParseCoinIdentifier(coin.CoinIdentifier)
+		if err != nil {
+			return nil, fmt.Errorf("%w: unable to parse coin identifier", err)
+		}
+
+		_, isOrphaned := orphanedTxs[hash.String()]
+		_, isAdded := addedTxs[hash.String()]
+		if isOrphaned && !isAdded {
+			return nil, fmt.Errorf(
+				"%w: coin %s was created in an orphaned block",
+				ErrReorgDrillFailed,
+				coin.CoinIdentifier.Identifier,
+			)
+		}
+
+		value, err := types.AmountValue(coin.Amount)
+		if err != nil {
+			return nil, fmt.Errorf("%w: invalid amount of coin %s", err, coin.CoinIdentifier.Identifier)
+		}
+		sum.Add(sum, value)
+	}
+
+	if sum.String() != balance.Balances[0].Value {
+		return nil, fmt.Errorf(
+			"%w: balance of %s is %s but its coins sum to %s",
+			ErrReorgDrillFailed,
+			address,
+			balance.Balances[0].Value,
+			sum.String(),
+		)
+	}
+
+	return balance.Balances[0], nil
+}
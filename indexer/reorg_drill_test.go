@@ -0,0 +1,278 @@
+// Copyright 2020 Coinbase, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package indexer
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/MNtank/rosetta-bitcoin/bitcoin"
+	"github.com/MNtank/rosetta-bitcoin/configuration"
+	mocks "github.com/MNtank/rosetta-bitcoin/mocks/indexer"
+
+	"github.com/btcsuite/btcd/chaincfg"
+	"github.com/coinbase/rosetta-sdk-go/types"
+	"github.com/coinbase/rosetta-sdk-go/utils"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+// drillAPI serves a fixed balance and fixed coins
+// of every account (and stale, if not nil, as every
+// block).
+type drillAPI struct {
+	block   *types.BlockIdentifier
+	balance string
+	coins   []*types.Coin
+	stale   *types.Block
+}
+
+func (d *drillAPI) Block(
+	context.Context,
+	*types.BlockRequest,
+) (*types.BlockResponse, *types.Error) {
+	if d.stale != nil {
+		return &types.BlockResponse{Block: d.stale}, nil
+	}
+
+	return nil, &types.Error{Code: 0, Message: "block not found"}
+}
+
+func (d *drillAPI) BlockTransaction(
+	context.Context,
+	*types.BlockTransactionRequest,
+) (*types.BlockTransactionResponse, *types.Error) {
+	return nil, &types.Error{Code: 0, Message: "transaction not found"}
+}
+
+func (d *drillAPI) AccountBalance(
+	context.Context,
+	*types.AccountBalanceRequest,
+) (*types.AccountBalanceResponse, *types.Error) {
+	return &types.AccountBalanceResponse{
+		BlockIdentifier: d.block,
+		Balances:        []*types.Amount{{Value: d.balance}},
+	}, nil
+}
+
+func (d *drillAPI) AccountCoins(
+	context.Context,
+	*types.AccountCoinsRequest,
+) (*types.AccountCoinsResponse, *types.Error) {
+	return &types.AccountCoinsResponse{
+		BlockIdentifier: d.block,
+		Coins:           d.coins,
+	}, nil
+}
+
+// drillNode fails the test if
+// a block is invalidated.
+type drillNode struct {
+	t *testing.T
+}
+
+func (d *drillNode) InvalidateBlock(context.Context, string) error {
+	d.t.Fatal("block invalidated")
+	return nil
+}
+
+func (d *drillNode) ReconsiderBlock(context.Context, string) error {
+	return nil
+}
+
+func (d *drillNode) GenerateToAddress(context.Context, int64, string) ([]string, error) {
+	d.t.Fatal("blocks generated")
+	return nil, nil
+}
+
+func TestRunReorgDrill_Network(t *testing.T) {
+	regtest := bitcoin.CloneParams(&chaincfg.RegressionNetParams)
+	regtest.Name = "drillnet"
+
+	tests := map[string]struct {
+		params *chaincfg.Params
+		chain  string
+
+		expectedError string
+	}{
+		"mainnet": {
+			params:        bitcoin.MainnetParams,
+			expectedError: "reorgs are never drilled on mainnet",
+		},
+		"testnet": {
+			params:        testnetParams(t),
+			expectedError: "reorgs are never drilled on euno-testnet",
+		},
+		"node not in regression test mode": {
+			params:        regtest,
+			chain:         "main",
+			expectedError: "node serves the main chain",
+		},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			ctx, cancel := context.WithCancel(context.Background())
+			defer cancel()
+
+			newDir, err := utils.CreateTempDir()
+			assert.NoError(t, err)
+			defer utils.RemoveTempDir(newDir)
+
+			cfg := &configuration.Configuration{
+				Network: &types.NetworkIdentifier{
+					Network:    bitcoin.TestnetNetwork,
+					Blockchain: bitcoin.Blockchain,
+				},
+				GenesisBlockIdentifier: &types.BlockIdentifier{
+					Hash: test.params.GenesisHash.String(),
+				},
+				Params:      test.params,
+				IndexerPath: newDir,
+			}
+
+			mockClient := &mocks.Client{}
+			i, err := Initialize(ctx, cancel, cfg, mockClient)
+			assert.NoError(t, err)
+			defer i.CloseDatabase(ctx)
+
+			if len(test.chain) > 0 {
+				mockClient.On("NodeNetwork", mock.Anything).Return(&bitcoin.NodeNetwork{
+					Chain: test.chain,
+				}, nil).Once()
+			}
+
+			drill, err := i.RunReorgDrill(ctx, &drillNode{t: t}, &drillAPI{}, 1, "addr")
+			assert.Nil(t, drill)
+			assert.True(t, errors.Is(err, ErrInvalidReorgDrill))
+			assert.Contains(t, err.Error(), test.expectedError)
+			mockClient.AssertExpectations(t)
+		})
+	}
+}
+
+func TestVerifyReorgEvents(t *testing.T) {
+	orphaned := []*types.BlockIdentifier{
+		{Index: 10, Hash: "block 10"},
+		{Index: 11, Hash: "block 11"},
+	}
+	added := []*types.BlockIdentifier{
+		{Index: 10, Hash: "fork 10"},
+		{Index: 11, Hash: "fork 11"},
+		{Index: 12, Hash: "fork 12"},
+	}
+
+	events := []*types.BlockEvent{
+		{Sequence: 20, BlockIdentifier: orphaned[1], Type: types.REMOVED},
+		{Sequence: 21, BlockIdentifier: orphaned[0], Type: types.REMOVED},
+		{Sequence: 22, BlockIdentifier: added[0], Type: types.ADDED},
+		{Sequence: 23, BlockIdentifier: added[1], Type: types.ADDED},
+		{Sequence: 24, BlockIdentifier: added[2], Type: types.ADDED},
+	}
+	assert.NoError(t, verifyReorgEvents(events, orphaned, added))
+
+	// Missing event
+	err := verifyReorgEvents(events[1:], orphaned, added)
+	assert.True(t, errors.Is(err, ErrReorgDrillFailed))
+
+	// Blocks removed out of order
+	swapped := []*types.BlockEvent{events[1], events[0], events[2], events[3], events[4]}
+	err = verifyReorgEvents(swapped, orphaned, added)
+	assert.True(t, errors.Is(err, ErrReorgDrillFailed))
+
+	// Gap in sequence
+	gap := []*types.BlockEvent{
+		events[0],
+		events[1],
+		{Sequence: 30, BlockIdentifier: added[0], Type: types.ADDED},
+		events[3],
+		events[4],
+	}
+	err = verifyReorgEvents(gap, orphaned, added)
+	assert.True(t, errors.Is(err, ErrReorgDrillFailed))
+}
+
+func TestVerifyReorgBalance(t *testing.T) {
+	ctx := context.Background()
+	network := &types.NetworkIdentifier{Blockchain: "Euno", Network: "Regtest"}
+	tip := &types.BlockIdentifier{Index: 12, Hash: "fork 12"}
+
+	orphanedTx := "1111111111111111111111111111111111111111111111111111111111111111"
+	addedTx := "2222222222222222222222222222222222222222222222222222222222222222"
+	orphaned := []*types.Block{
+		{Transactions: []*types.Transaction{
+			{TransactionIdentifier: &types.TransactionIdentifier{Hash: orphanedTx}},
+		}},
+	}
+	added := []*types.Block{
+		{Transactions: []*types.Transaction{
+			{TransactionIdentifier: &types.TransactionIdentifier{Hash: addedTx}},
+		}},
+	}
+
+	coin := func(hash string, value string) *types.Coin {
+		return &types.Coin{
+			CoinIdentifier: &types.CoinIdentifier{Identifier: hash + ":0"},
+			Amount:         &types.Amount{Value: value},
+		}
+	}
+
+	api := &drillAPI{
+		block:   tip,
+		balance: "300",
+		coins:   []*types.Coin{coin(addedTx, "100"), coin(addedTx, "200")},
+	}
+	balance, err := verifyReorgBalance(ctx, api, network, "address", tip, orphaned, added)
+	assert.NoError(t, err)
+	assert.Equal(t, "300", balance.Value)
+
+	// Balance is not the sum of coins
+	api.balance = "400"
+	_, err = verifyReorgBalance(ctx, api, network, "address", tip, orphaned, added)
+	assert.True(t, errors.Is(err, ErrReorgDrillFailed))
+
+	// Coin created in an orphaned block
+	api.balance = "350"
+	api.coins = append(api.coins, coin(orphanedTx, "50"))
+	_, err = verifyReorgBalance(ctx, api, network, "address", tip, orphaned, added)
+	assert.True(t, errors.Is(err, ErrReorgDrillFailed))
+
+	// Transaction included again in a new block
+	added[0].Transactions = append(added[0].Transactions, &types.Transaction{
+		TransactionIdentifier: &types.TransactionIdentifier{Hash: orphanedTx},
+	})
+	_, err = verifyReorgBalance(ctx, api, network, "address", tip, orphaned, added)
+	assert.NoError(t, err)
+
+	// Balance not at tip
+	api.block = &types.BlockIdentifier{Index: 11, Hash: "block 11"}
+	_, err = verifyReorgBalance(ctx, api, network, "address", tip, orphaned, added)
+	assert.True(t, errors.Is(err, ErrReorgDrillFailed))
+
+	// Orphaned blocks are no longer served
+	assert.NoError(t, verifyBlockOrphaned(ctx, api, network, api.block))
+
+	// or are served flagged as orphaned
+	api.stale = &types.Block{
+		BlockIdentifier: api.block,
+		Metadata:        map[string]interface{}{"orphaned": true},
+	}
+	assert.NoError(t, verifyBlockOrphaned(ctx, api, network, api.block))
+
+	api.stale.Metadata = nil
+	err = verifyBlockOrphaned(ctx, api, network, api.block)
+	assert.True(t, errors.Is(err, ErrReorgDrillFailed))
+}
@@ -0,0 +1,266 @@
+// Copyright 2020 Coinbase, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package indexer
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"expvar"
+	"fmt"
+
+	"github.com/MNtank/rosetta-bitcoin/utils"
+
+	"github.com/coinbase/rosetta-sdk-go/storage/database"
+	storageErrs "github.com/coinbase/rosetta-sdk-go/storage/errors"
+	"github.com/coinbase/rosetta-sdk-go/types"
+)
+
+const (
+	// reorgPauseKey stores the *ReorgPause recorded
+	// when a reorg deeper than the reorg depth limit
+	// was encountered.
+	reorgPauseKey = "reorg-pause"
+)
+
+var (
+	// reorgPausedMetric is 1 while sync (of each network)
+	// is paused on a reorg deeper than the reorg depth limit.
+	reorgPausedMetric = expvar.NewMap("indexer_reorg_paused")
+
+	// ErrNoReorgPause is returned by ApproveReorg when
+	// sync is not paused on a reorg.
+	ErrNoReorgPause = errors.New("sync is not paused on a reorg")
+)
+
+// ReorgPause is recorded when a reorg deeper than
+// the reorg depth limit is encountered. Sync stays
+// paused until the reorg is approved by an operator.
+type ReorgPause struct {
+	// Head is the head block when the
+	// reorg was encountered.
+	Head *types.BlockIdentifier `json:"head"`
+
+	// Depth is the minimum depth of the reorg (the
+	// fork point was not searched past the limit).
+	Depth int64 `json:"depth"`
+
+	// Approved is true once an operator
+	// has approved processing the reorg.
+	Approved bool `json:"approved"`
+}
+
+// getReorgPause returns the recorded
+// *ReorgPause (or nil if none exists).
+func getReorgPause(
+	ctx context.Context,
+	dbTx database.Transaction,
+) (*ReorgPause, error) {
+	exists, value, err := dbTx.Get(ctx, []byte(reorgPauseKey))
+	if err != nil {
+		return nil, fmt.Errorf("%w: unable to get reorg pause", err)
+	}
+
+	if !exists {
+		return nil, nil
+	}
+
+	var pause ReorgPause
+	if err := json.Unmarshal(value, &pause); err != nil {
+		return nil, fmt.Errorf("%w: unable to unmarshal reorg pause", err)
+	}
+
+	return &pause, nil
+}
+
+// setReorgPause records a *ReorgPause.
+func (i *Indexer) setReorgPause(ctx context.Context, pause *ReorgPause) error {
+	value, err := json.Marshal(pause)
+	if err != nil {
+		return fmt.Errorf("%w: unable to marshal reorg pause", err)
+	}
+
+	dbTx := i.database.WriteTransaction(ctx, reorgPauseKey, false)
+	defer dbTx.Discard(ctx)
+
+	if err := dbTx.Set(ctx, []byte(reorgPauseKey), value, false); err != nil {
+		return fmt.Errorf("%w: unable to set reorg pause", err)
+	}
+
+	return dbTx.Commit(ctx)
+}
+
+// ReorgPause returns the *ReorgPause sync is paused
+// on (or nil if sync is not paused).
+func (i *Indexer) ReorgPause(ctx context.Context) (*ReorgPause, error) {
+	dbTx := i.database.ReadTransaction(ctx)
+	defer dbTx.Discard(ctx)
+
+	return getReorgPause(ctx, dbTx)
+}
+
+// ApproveReorg approves processing the reorg sync is
+// paused on, so that it is processed the next time the
+// indexer syncs.
+func (i *Indexer) ApproveReorg(ctx context.Context) (*ReorgPause, error) {
+	pause, err := i.ReorgPause(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	if pause == nil {
+		return nil, ErrNoReorgPause
+	}
+
+	pause.Approved = true
+	if err := i.setReorgPause(ctx, pause); err != nil {
+		return nil, err
+	}
+
+	return pause, nil
+}
+
+// clearReorgPause removes an approved *ReorgPause once
+// sync has moved past the head it was recorded at.
+func (i *Indexer) clearReorgPause(ctx context.Context, block *types.BlockIdentifier) error {
+	if i.reorgDepthLimit == 0 || !i.reorgApproved {
+		return nil
+	}
+
+	pause, err := i.ReorgPause(ctx)
+	if err != nil {
+		return err
+	}
+
+	if pause != nil && block.Index <= pause.Head.Index {
+		return nil
+	}
+
+	dbTx := i.database.WriteTransaction(ctx, reorgPauseKey, false)
+	defer dbTx.Discard(ctx)
+
+	if err := dbTx.Delete(ctx, []byte(reorgPauseKey)); err != nil {
+		return fmt.Errorf("%w: unable to delete reorg pause", err)
+	}
+
+	if err := dbTx.Commit(ctx); err != nil {
+		return err
+	}
+
+	i.reorgApproved = false
+	return nil
+}
+
+// reorgDepthExceeds returns the minimum depth of a reorg
+// of head and true if it is deeper than the reorg depth
+// limit. Blocks are compared against the node from head
+// down to the fork point (or the limit).
+func (i *Indexer) reorgDepthExceeds(
+	ctx context.Context,
+	head *types.BlockIdentifier,
+) (int64, bool, error) {
+	status, err := i.client.NetworkStatus(ctx)
+	if err != nil {
+		return -1, false, fmt.Errorf("%w: unable to get network status", err)
+	}
+
+	// Blocks above the node's tip were
+	// removed by the reorg.
+	index := head.Index
+	if status.CurrentBlockIdentifier.Index < index {
+		index = status.CurrentBlockIdentifier.Index
+	}
+
+	for ; index >= 0 && head.Index-index <= i.reorgDepthLimit; index-- {
+		stored, err := i.blockStorage.GetBlockLazy(
+			ctx,
+			&types.PartialBlockIdentifier{Index: &index},
+		)
+		if errors.Is(err, storageErrs.ErrCannotAccessPrunedData) {
+			// Reorgs of pruned blocks can't be processed.
+			return head.Index - index, true, nil
+		}
+		if err != nil {
+			return -1, false, fmt.Errorf("%w: unable to get block %d", err, index)
+		}
+
+		block, _, err := i.client.GetRawBlock(ctx, &types.PartialBlockIdentifier{Index: &index})
+		if err != nil {
+			return -1, false, fmt.Errorf("%w: unable to get block %d from node", err, index)
+		}
+
+		if block.Hash == stored.Block.BlockIdentifier.Hash {
+			return head.Index - index, false, nil
+		}
+	}
+
+	return head.Index - index, true, nil
+}
+
+// checkReorgDepth is called before the first block of a
+// reorg is removed. If the reorg is deeper than the reorg
+// depth limit (and has not been approved by an operator),
+// a *ReorgPause is recorded and sync is paused until the
+// context is canceled. The API continues to serve the
+// chain as of head while sync is paused.
+func (i *Indexer) checkReorgDepth(ctx context.Context, head *types.BlockIdentifier) error {
+	logger := utils.ExtractLogger(ctx, "indexer")
+
+	pause, err := i.ReorgPause(ctx)
+	if err != nil {
+		return err
+	}
+
+	// A pause recorded at a different head is stale (the
+	// node switched chains again before it was approved).
+	if pause != nil && types.Hash(pause.Head) != types.Hash(head) {
+		pause = nil
+	}
+
+	if pause != nil && pause.Approved {
+		logger.Warnw("processing approved reorg", "head", pause.Head, "depth", pause.Depth)
+		i.reorgApproved = true
+		return nil
+	}
+
+	if pause == nil {
+		depth, exceeds, err := i.reorgDepthExceeds(ctx, head)
+		if err != nil {
+			return err
+		}
+
+		if !exceeds {
+			return nil
+		}
+
+		pause = &ReorgPause{Head: head, Depth: depth}
+		if err := i.setReorgPause(ctx, pause); err != nil {
+			return err
+		}
+	}
+
+	paused := new(expvar.Int)
+	paused.Set(1)
+	reorgPausedMetric.Set(utils.NetworkMetricsKey(i.network), paused)
+	logger.Errorw(
+		"sync paused on reorg deeper than limit (run approve-reorg to process it)",
+		"head", pause.Head,
+		"depth", pause.Depth,
+		"limit", i.reorgDepthLimit,
+	)
+
+	<-ctx.Done()
+	return ctx.Err()
+}
@@ -0,0 +1,172 @@
+// Copyright 2020 Coinbase, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package indexer
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/MNtank/rosetta-bitcoin/bitcoin"
+	"github.com/MNtank/rosetta-bitcoin/configuration"
+	mocks "github.com/MNtank/rosetta-bitcoin/mocks/indexer"
+
+	"github.com/coinbase/rosetta-sdk-go/types"
+	"github.com/coinbase/rosetta-sdk-go/utils"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+// mockForkedChain returns blocks from the node that
+// fork from the stored chain after forkIndex.
+func mockForkedChain(mockClient *mocks.Client, head int64, forkIndex int64) {
+	mockClient.On("NetworkStatus", mock.Anything).Return(&types.NetworkStatusResponse{
+		CurrentBlockIdentifier: &types.BlockIdentifier{Index: head},
+	}, nil)
+
+	for index := int64(0); index <= head; index++ {
+		hash := getBlockHash(index)
+		if index > forkIndex {
+			hash = fmt.Sprintf("fork %d", index)
+		}
+
+		mockClient.On(
+			"GetRawBlock",
+			mock.Anything,
+			&types.PartialBlockIdentifier{Index: types.Int64(index)},
+		).Return(&bitcoin.Block{Hash: hash, Height: index}, []string{}, nil)
+	}
+}
+
+func TestReorgDepthLimit(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	newDir, err := utils.CreateTempDir()
+	assert.NoError(t, err)
+	defer utils.RemoveTempDir(newDir)
+
+	cfg := &configuration.Configuration{
+		Network: &types.NetworkIdentifier{
+			Network:    bitcoin.MainnetNetwork,
+			Blockchain: bitcoin.Blockchain,
+		},
+		GenesisBlockIdentifier: bitcoin.MainnetGenesisBlockIdentifier,
+		IndexerPath:            newDir,
+		ReorgDepthLimit:        2,
+	}
+
+	shallow := &mocks.Client{}
+	i, err := Initialize(ctx, cancel, cfg, shallow)
+	assert.NoError(t, err)
+	defer i.CloseDatabase(ctx)
+
+	i.blockStorage.Initialize(i.workers)
+	parent := &types.BlockIdentifier{Index: 0, Hash: getBlockHash(0)}
+	for index := int64(0); index <= 4; index++ {
+		block := &types.Block{
+			BlockIdentifier:       &types.BlockIdentifier{Index: index, Hash: getBlockHash(index)},
+			ParentBlockIdentifier: parent,
+		}
+
+		assert.NoError(t, i.blockStorage.SeeBlock(ctx, block))
+		assert.NoError(t, i.BlockAdded(ctx, block))
+		parent = block.BlockIdentifier
+	}
+	head := &types.BlockIdentifier{Index: 4, Hash: getBlockHash(4)}
+
+	// Reorgs within the limit are processed
+	mockForkedChain(shallow, 4, 2)
+	depth, exceeds, err := i.reorgDepthExceeds(ctx, head)
+	assert.NoError(t, err)
+	assert.False(t, exceeds)
+	assert.Equal(t, int64(2), depth)
+	assert.NoError(t, i.checkReorgDepth(ctx, head))
+
+	// Nothing to approve
+	pause, err := i.ApproveReorg(ctx)
+	assert.Nil(t, pause)
+	assert.True(t, errors.Is(err, ErrNoReorgPause))
+
+	// Deeper reorgs pause sync
+	deep := &mocks.Client{}
+	i.client = deep
+	mockForkedChain(deep, 4, 1)
+	depth, exceeds, err = i.reorgDepthExceeds(ctx, head)
+	assert.NoError(t, err)
+	assert.True(t, exceeds)
+	assert.Equal(t, int64(3), depth)
+
+	pauseCtx, pauseCancel := context.WithCancel(ctx)
+	paused := make(chan error)
+	go func() {
+		paused <- i.checkReorgDepth(pauseCtx, head)
+	}()
+
+	assert.Eventually(t, func() bool {
+		pause, err := i.ReorgPause(ctx)
+		return err == nil && pause != nil
+	}, time.Second, 10*time.Millisecond)
+
+	pauseCancel()
+	assert.True(t, errors.Is(<-paused, context.Canceled))
+
+	// Blocks above the node's tip were removed by the reorg
+	shorter := &mocks.Client{}
+	i.client = shorter
+	mockForkedChain(shorter, 1, 1)
+	depth, exceeds, err = i.reorgDepthExceeds(ctx, head)
+	assert.NoError(t, err)
+	assert.True(t, exceeds)
+	assert.Equal(t, int64(3), depth)
+
+	// Approved reorgs are processed
+	pause, err = i.ApproveReorg(ctx)
+	assert.NoError(t, err)
+	assert.Equal(t, &ReorgPause{Head: head, Depth: 3, Approved: true}, pause)
+	assert.NoError(t, i.checkReorgDepth(ctx, head))
+	assert.True(t, i.reorgApproved)
+
+	for index := int64(4); index >= 2; index-- {
+		assert.NoError(t, i.BlockRemoved(ctx, &types.BlockIdentifier{
+			Index: index,
+			Hash:  getBlockHash(index),
+		}))
+	}
+
+	// The approval is kept until sync passes the
+	// head it was recorded at.
+	parent = &types.BlockIdentifier{Index: 1, Hash: getBlockHash(1)}
+	for index := int64(2); index <= 5; index++ {
+		pause, err = i.ReorgPause(ctx)
+		assert.NoError(t, err)
+		assert.NotNil(t, pause)
+
+		block := &types.Block{
+			BlockIdentifier:       &types.BlockIdentifier{Index: index, Hash: fmt.Sprintf("fork %d", index)},
+			ParentBlockIdentifier: parent,
+		}
+		assert.NoError(t, i.blockStorage.SeeBlock(ctx, block))
+		assert.NoError(t, i.BlockAdded(ctx, block))
+		parent = block.BlockIdentifier
+	}
+
+	pause, err = i.ReorgPause(ctx)
+	assert.NoError(t, err)
+	assert.Nil(t, pause)
+	assert.False(t, i.reorgApproved)
+}
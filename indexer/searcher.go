@@ -0,0 +1,242 @@
+// Copyright 2020 Coinbase, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package indexer
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/MNtank/rosetta-bitcoin/bitcoin"
+
+	"github.com/coinbase/rosetta-sdk-go/storage/database"
+	"github.com/coinbase/rosetta-sdk-go/types"
+)
+
+// ErrUnindexedSearch is returned when a search has no
+// condition that is indexed (an address, an account, or
+// a transaction identifier), as answering it would
+// require scanning every block.
+var ErrUnindexedSearch = errors.New(
+	"search requires an address, account identifier, or transaction identifier",
+)
+
+// operationMatcher returns true if an operation
+// satisfies every operation condition of a search.
+type operationMatcher func(*types.Operation) bool
+
+// newOperationMatcher returns the operationMatcher of the
+// operation conditions of request (address, account, coin,
+// currency, type, status, and success). All conditions must
+// be satisfied by the same operation.
+func newOperationMatcher(request *types.SearchTransactionsRequest) operationMatcher {
+	successful := map[string]bool{}
+	for _, status := range bitcoin.OperationStatuses() {
+		successful[status.Status] = status.Successful
+	}
+
+	return func(op *types.Operation) bool {
+		if request.Address != nil && (op.Account == nil || op.Account.Address != *request.Address) {
+			return false
+		}
+
+		if request.AccountIdentifier != nil &&
+			(op.Account == nil || types.Hash(op.Account) != types.Hash(request.AccountIdentifier)) {
+			return false
+		}
+
+		if request.CoinIdentifier != nil &&
+			(op.CoinChange == nil ||
+				op.CoinChange.CoinIdentifier.Identifier != request.CoinIdentifier.Identifier) {
+			return false
+		}
+
+		if request.Currency != nil &&
+			(op.Amount == nil || types.Hash(op.Amount.Currency) != types.Hash(request.Currency)) {
+			return false
+		}
+
+		if request.Type != nil && op.Type != *request.Type {
+			return false
+		}
+
+		if request.Status != nil && (op.Status == nil || *op.Status != *request.Status) {
+			return false
+		}
+
+		if request.Success != nil &&
+			(op.Status == nil || successful[*op.Status] != *request.Success) {
+			return false
+		}
+
+		return true
+	}
+}
+
+// searchAddress returns the address whose transactions are
+// searched (from the address or account of request). It
+// returns false if request has neither.
+func searchAddress(request *types.SearchTransactionsRequest) (string, bool) {
+	if request.Address != nil {
+		return *request.Address, true
+	}
+
+	if request.AccountIdentifier != nil {
+		return request.AccountIdentifier.Address, true
+	}
+
+	return "", false
+}
+
+// searchCandidates calls worker with each transaction that
+// may match request (newest first), from the address index
+// or from block storage (if only a transaction identifier
+// is provided).
+func (i *Indexer) searchCandidates(
+	ctx context.Context,
+	dbTx database.Transaction,
+	request *types.SearchTransactionsRequest,
+	maxBlock int64,
+	worker func(*types.BlockIdentifier, *types.Transaction) error,
+) error {
+	address, ok := searchAddress(request)
+	if !ok {
+		if request.TransactionIdentifier == nil {
+			return ErrUnindexedSearch
+		}
+
+		block, tx, err := i.blockStorage.FindTransaction(ctx, request.TransactionIdentifier, dbTx)
+		if err != nil {
+			return fmt.Errorf(
+				"%w: unable to find transaction %s",
+				err,
+				request.TransactionIdentifier.Hash,
+			)
+		}
+
+		if block == nil || block.Index > maxBlock {
+			return nil
+		}
+
+		return worker(block, tx)
+	}
+
+	return i.addressIndex.ScanTransactions(
+		ctx,
+		dbTx,
+		address,
+		&maxBlock,
+		func(
+			block *types.BlockIdentifier,
+			transactionIdentifier *types.TransactionIdentifier,
+		) error {
+			if request.TransactionIdentifier != nil &&
+				transactionIdentifier.Hash != request.TransactionIdentifier.Hash {
+				return nil
+			}
+
+			_, tx, err := i.blockStorage.FindTransaction(ctx, transactionIdentifier, dbTx)
+			if err != nil {
+				return fmt.Errorf(
+					"%w: unable to find transaction %s",
+					err,
+					transactionIdentifier.Hash,
+				)
+			}
+
+			if tx == nil {
+				return fmt.Errorf("indexed transaction %s not found", transactionIdentifier.Hash)
+			}
+
+			return worker(block, tx)
+		},
+	)
+}
+
+// SearchTransactions returns the transactions matching the
+// conditions of request (combined with "and"), from the most
+// recent block to the oldest. A transaction matches if one of
+// its operations satisfies all operation conditions. Results
+// are paginated by offset (the number of matching transactions
+// skipped) and limit (all remaining results if nil), and the
+// NextOffset of the response is the cursor of the next page.
+func (i *Indexer) SearchTransactions(
+	ctx context.Context,
+	request *types.SearchTransactionsRequest,
+) (*types.SearchTransactionsResponse, error) {
+	dbTx := i.database.ReadTransaction(ctx)
+	defer dbTx.Discard(ctx)
+
+	head, err := i.blockStorage.GetHeadBlockIdentifierTransactional(ctx, dbTx)
+	if err != nil {
+		return nil, fmt.Errorf("%w: unable to get head block", err)
+	}
+
+	maxBlock := head.Index
+	if request.MaxBlock != nil && *request.MaxBlock < maxBlock {
+		maxBlock = *request.MaxBlock
+	}
+
+	offset := int64(0)
+	if request.Offset != nil {
+		offset = *request.Offset
+	}
+
+	matches := newOperationMatcher(request)
+	response := &types.SearchTransactionsResponse{
+		Transactions: []*types.BlockTransaction{},
+	}
+	err = i.searchCandidates(
+		ctx,
+		dbTx,
+		request,
+		maxBlock,
+		func(block *types.BlockIdentifier, tx *types.Transaction) error {
+			matched := false
+			for _, op := range tx.Operations {
+				if matches(op) {
+					matched = true
+					break
+				}
+			}
+
+			if !matched {
+				return nil
+			}
+
+			position := response.TotalCount
+			response.TotalCount++
+			if position < offset || (request.Limit != nil && position >= offset+*request.Limit) {
+				return nil
+			}
+
+			response.Transactions = append(response.Transactions, &types.BlockTransaction{
+				BlockIdentifier: block,
+				Transaction:     tx,
+			})
+
+			return nil
+		},
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	if next := offset + int64(len(response.Transactions)); next < response.TotalCount {
+		response.NextOffset = &next
+	}
+
+	return response, nil
+}
@@ -0,0 +1,235 @@
+// Copyright 2020 Coinbase, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package indexer
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+
+	"github.com/MNtank/rosetta-bitcoin/bitcoin"
+	"github.com/MNtank/rosetta-bitcoin/configuration"
+
+	"github.com/coinbase/rosetta-sdk-go/types"
+	"github.com/coinbase/rosetta-sdk-go/utils"
+	"github.com/stretchr/testify/assert"
+)
+
+func searchHashes(response *types.SearchTransactionsResponse) []string {
+	hashes := []string{}
+	for _, tx := range response.Transactions {
+		hashes = append(hashes, tx.Transaction.TransactionIdentifier.Hash)
+	}
+
+	return hashes
+}
+
+func TestIndexer_SearchTransactions(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	newDir, err := utils.CreateTempDir()
+	assert.NoError(t, err)
+	defer utils.RemoveTempDir(newDir)
+
+	cfg := &configuration.Configuration{
+		Network: &types.NetworkIdentifier{
+			Network:    bitcoin.MainnetNetwork,
+			Blockchain: bitcoin.Blockchain,
+		},
+		GenesisBlockIdentifier: bitcoin.MainnetGenesisBlockIdentifier,
+		IndexerPath:            newDir,
+	}
+
+	i, err := Initialize(ctx, cancel, cfg, nil)
+	assert.NoError(t, err)
+	defer i.CloseDatabase(ctx)
+
+	// Block j has a transaction "tx j" with an input
+	// of addr1 and an output to addr2 (skipped in
+	// block 2), and block 3 has an output to addr3.
+	i.blockStorage.Initialize(i.workers)
+	for j := int64(0); j < 4; j++ {
+		block := &types.Block{
+			BlockIdentifier: &types.BlockIdentifier{
+				Index: j,
+				Hash:  getBlockHash(j),
+			},
+			ParentBlockIdentifier: &types.BlockIdentifier{
+				Index: j - 1,
+				Hash:  getBlockHash(j - 1),
+			},
+			Transactions: []*types.Transaction{},
+		}
+		if j == 0 {
+			block.ParentBlockIdentifier = block.BlockIdentifier
+		}
+
+		status := bitcoin.SuccessStatus
+		if j == 2 {
+			status = bitcoin.SkippedStatus
+		}
+
+		tx := &types.Transaction{
+			TransactionIdentifier: &types.TransactionIdentifier{Hash: fmt.Sprintf("tx %d", j)},
+			Operations: []*types.Operation{
+				{
+					OperationIdentifier: &types.OperationIdentifier{Index: 0},
+					Type:                bitcoin.InputOpType,
+					Status:              types.String(bitcoin.SuccessStatus),
+					Account:             &types.AccountIdentifier{Address: "addr1"},
+				},
+				{
+					OperationIdentifier: &types.OperationIdentifier{Index: 1},
+					Type:                bitcoin.OutputOpType,
+					Status:              types.String(status),
+					Account:             &types.AccountIdentifier{Address: "addr2"},
+				},
+			},
+		}
+		if j == 3 {
+			tx.Operations = append(tx.Operations, &types.Operation{
+				OperationIdentifier: &types.OperationIdentifier{Index: 2},
+				Type:                bitcoin.OutputOpType,
+				Status:              types.String(bitcoin.SuccessStatus),
+				Account:             &types.AccountIdentifier{Address: "addr3"},
+			})
+		}
+		block.Transactions = append(block.Transactions, tx)
+
+		assert.NoError(t, i.blockStorage.SeeBlock(ctx, block))
+		assert.NoError(t, i.blockStorage.AddBlock(ctx, block))
+	}
+
+	tests := map[string]struct {
+		request    *types.SearchTransactionsRequest
+		hashes     []string
+		totalCount int64
+		nextOffset *int64
+		err        error
+	}{
+		"address": {
+			request:    &types.SearchTransactionsRequest{Address: types.String("addr1")},
+			hashes:     []string{"tx 3", "tx 2", "tx 1", "tx 0"},
+			totalCount: 4,
+		},
+		"account": {
+			request: &types.SearchTransactionsRequest{
+				AccountIdentifier: &types.AccountIdentifier{Address: "addr3"},
+			},
+			hashes:     []string{"tx 3"},
+			totalCount: 1,
+		},
+		"unknown address": {
+			request: &types.SearchTransactionsRequest{Address: types.String("addr4")},
+			hashes:  []string{},
+		},
+		"transaction identifier": {
+			request: &types.SearchTransactionsRequest{
+				TransactionIdentifier: &types.TransactionIdentifier{Hash: "tx 1"},
+			},
+			hashes:     []string{"tx 1"},
+			totalCount: 1,
+		},
+		"max block": {
+			request: &types.SearchTransactionsRequest{
+				Address:  types.String("addr2"),
+				MaxBlock: types.Int64(1),
+			},
+			hashes:     []string{"tx 1", "tx 0"},
+			totalCount: 2,
+		},
+		"transaction identifier above max block": {
+			request: &types.SearchTransactionsRequest{
+				TransactionIdentifier: &types.TransactionIdentifier{Hash: "tx 3"},
+				MaxBlock:              types.Int64(2),
+			},
+			hashes: []string{},
+		},
+		"same operation": {
+			request: &types.SearchTransactionsRequest{
+				Address: types.String("addr1"),
+				Type:    types.String(bitcoin.OutputOpType),
+			},
+			hashes: []string{},
+		},
+		"unsuccessful": {
+			request: &types.SearchTransactionsRequest{
+				Address: types.String("addr2"),
+				Success: types.Bool(false),
+			},
+			hashes:     []string{"tx 2"},
+			totalCount: 1,
+		},
+		"status": {
+			request: &types.SearchTransactionsRequest{
+				Address: types.String("addr2"),
+				Status:  types.String(bitcoin.SuccessStatus),
+			},
+			hashes:     []string{"tx 3", "tx 1", "tx 0"},
+			totalCount: 3,
+		},
+		"first page": {
+			request: &types.SearchTransactionsRequest{
+				Address: types.String("addr1"),
+				Limit:   types.Int64(3),
+			},
+			hashes:     []string{"tx 3", "tx 2", "tx 1"},
+			totalCount: 4,
+			nextOffset: types.Int64(3),
+		},
+		"last page": {
+			request: &types.SearchTransactionsRequest{
+				Address: types.String("addr1"),
+				Offset:  types.Int64(3),
+				Limit:   types.Int64(3),
+			},
+			hashes:     []string{"tx 0"},
+			totalCount: 4,
+		},
+		"unindexed": {
+			request: &types.SearchTransactionsRequest{Type: types.String(bitcoin.OutputOpType)},
+			err:     ErrUnindexedSearch,
+		},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			response, err := i.SearchTransactions(ctx, test.request)
+			if test.err != nil {
+				assert.True(t, errors.Is(err, test.err))
+				return
+			}
+
+			assert.NoError(t, err)
+			assert.Equal(t, test.hashes, searchHashes(response))
+			assert.Equal(t, test.totalCount, response.TotalCount)
+			assert.Equal(t, test.nextOffset, response.NextOffset)
+		})
+	}
+
+	// Transactions of orphaned blocks are removed
+	// from the index
+	assert.NoError(t, i.blockStorage.RemoveBlock(ctx, &types.BlockIdentifier{
+		Index: 3,
+		Hash:  getBlockHash(3),
+	}))
+	response, err := i.SearchTransactions(ctx, &types.SearchTransactionsRequest{
+		Address: types.String("addr3"),
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, []string{}, searchHashes(response))
+}
@@ -0,0 +1,316 @@
+// Copyright 2020 Coinbase, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package indexer
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"expvar"
+	"fmt"
+	"time"
+
+	"github.com/MNtank/rosetta-bitcoin/utils"
+
+	"github.com/coinbase/rosetta-sdk-go/storage/database"
+	sdkUtils "github.com/coinbase/rosetta-sdk-go/utils"
+	"golang.org/x/time/rate"
+)
+
+const (
+	// accountNamespace is the namespace used by
+	// modules.BalanceStorage to store accounts.
+	accountNamespace = "acc"
+
+	// coinNamespace is the namespace used by
+	// modules.CoinStorage to store coins.
+	coinNamespace = "coin"
+
+	// coinAccountNamespace is the namespace used by
+	// modules.CoinStorage to store the coins owned by
+	// each account.
+	coinAccountNamespace = "coin-account"
+
+	// namespaceSeparator separates the components
+	// of all keys in storage.
+	namespaceSeparator = "/"
+
+	// snapshotKeysPerSecond is the most keys scanned
+	// each second while computing a snapshot (so that
+	// counting keys of a large database doesn't starve
+	// syncing and serving requests of disk bandwidth).
+	snapshotKeysPerSecond = 250000
+
+	// snapshotScanBatch is the number of keys scanned
+	// between waits on the snapshot rate limiter.
+	snapshotScanBatch = 1000
+)
+
+var (
+	// snapshotNamespaces are the storage namespaces
+	// we count keys for in each snapshot.
+	snapshotNamespaces = []string{
+		"block",
+		"block-index",
+		"transaction",
+		coinNamespace,
+		coinAccountNamespace,
+		accountNamespace,
+		"bal",
+		"hbal",
+		eventNamespace,
+		deadLetterNamespace,
+		addressTransactionNamespace,
+	}
+
+	// snapshotMetrics exposes the most recent
+	// snapshot of each network as expvar metrics.
+	snapshotMetrics = expvar.NewMap("indexer_snapshot")
+
+	errSnapshotNotReady = errors.New("snapshot not yet computed")
+)
+
+// scanLimiter rate limits a scan (in batches of
+// snapshotScanBatch keys). A nil *scanLimiter
+// doesn't limit scans.
+type scanLimiter struct {
+	limiter *rate.Limiter
+	scanned int
+}
+
+// newScanLimiter creates a new *scanLimiter
+// that scans at most keysPerSecond keys.
+func newScanLimiter(keysPerSecond float64) *scanLimiter {
+	return &scanLimiter{
+		limiter: rate.NewLimiter(rate.Limit(keysPerSecond), snapshotScanBatch),
+	}
+}
+
+// wait is called with each key scanned and waits
+// on the limiter after each batch of keys.
+func (s *scanLimiter) wait(ctx context.Context) error {
+	if s == nil {
+		return nil
+	}
+
+	s.scanned++
+	if s.scanned < snapshotScanBatch {
+		return nil
+	}
+
+	s.scanned = 0
+	return s.limiter.WaitN(ctx, snapshotScanBatch)
+}
+
+// countKeys returns the number of keys stored
+// under a namespace (scanned at the rate of limiter).
+func countKeys(
+	ctx context.Context,
+	dbTx database.Transaction,
+	namespace string,
+	limiter *scanLimiter,
+) (int64, error) {
+	prefix := []byte(namespace + namespaceSeparator)
+	entries, err := dbTx.Scan(
+		ctx,
+		prefix,
+		prefix,
+		func(k []byte, v []byte) error {
+			return limiter.wait(ctx)
+		},
+		false,
+		false,
+	)
+	if err != nil {
+		return -1, fmt.Errorf("%w: unable to scan namespace %s", err, namespace)
+	}
+
+	return int64(entries), nil
+}
+
+// countFundedAccounts returns the number of keys in the
+// coin account namespace and the number of distinct accounts
+// that own at least one coin (in a single scan at the
+// rate of limiter).
+func countFundedAccounts(
+	ctx context.Context,
+	dbTx database.Transaction,
+	limiter *scanLimiter,
+) (int64, int64, error) {
+	prefix := []byte(coinAccountNamespace + namespaceSeparator)
+	separator := []byte(namespaceSeparator)
+
+	accounts := int64(0)
+	var lastAccount []byte
+	keys, err := dbTx.Scan(
+		ctx,
+		prefix,
+		prefix,
+		func(k []byte, v []byte) error {
+			// Keys are of the form coin-account/<account hash>/<coin>,
+			// so all coins owned by an account are adjacent.
+			rest := k[len(prefix):]
+			end := bytes.Index(rest, separator)
+			if end == -1 {
+				return fmt.Errorf("malformed coin account key %s", string(k))
+			}
+
+			if !bytes.Equal(rest[:end], lastAccount) {
+				accounts++
+				lastAccount = append(lastAccount[:0], rest[:end]...)
+			}
+
+			return limiter.wait(ctx)
+		},
+		false,
+		false,
+	)
+	if err != nil {
+		return -1, -1, fmt.Errorf("%w: unable to scan coin accounts", err)
+	}
+
+	return int64(keys), accounts, nil
+}
+
+// computeSnapshot scans storage (at the rate of
+// limiter) to create a *utils.Snapshot.
+func (i *Indexer) computeSnapshot(
+	ctx context.Context,
+	limiter *scanLimiter,
+) (*utils.Snapshot, error) {
+	dbTx := i.database.ReadTransaction(ctx)
+	defer dbTx.Discard(ctx)
+
+	snapshot := &utils.Snapshot{
+		Timestamp:    time.Now().Unix(),
+		DatabaseKeys: map[string]int64{},
+	}
+
+	head, err := i.blockStorage.GetHeadBlockIdentifierTransactional(ctx, dbTx)
+	if err != nil {
+		return nil, fmt.Errorf("%w: unable to get head block identifier", err)
+	}
+	snapshot.BlockIdentifier = head
+
+	for _, namespace := range snapshotNamespaces {
+		// Coin account keys are counted while
+		// counting funded accounts.
+		if namespace == coinAccountNamespace {
+			continue
+		}
+
+		keys, err := countKeys(ctx, dbTx, namespace, limiter)
+		if err != nil {
+			return nil, err
+		}
+
+		snapshot.DatabaseKeys[namespace] = keys
+	}
+
+	coinAccountKeys, fundedAccounts, err := countFundedAccounts(ctx, dbTx, limiter)
+	if err != nil {
+		return nil, err
+	}
+	snapshot.DatabaseKeys[coinAccountNamespace] = coinAccountKeys
+
+	snapshot.Accounts = snapshot.DatabaseKeys[accountNamespace]
+	snapshot.FundedAccounts = fundedAccounts
+	snapshot.Coins = snapshot.DatabaseKeys[coinNamespace]
+	if fundedAccounts > 0 {
+		snapshot.MeanCoinsPerAccount = float64(snapshot.Coins) / float64(fundedAccounts)
+	}
+
+	return snapshot, nil
+}
+
+// publishSnapshot stores a *utils.Snapshot so that it can
+// be served by GetSnapshot and updates all snapshot metrics.
+func (i *Indexer) publishSnapshot(snapshot *utils.Snapshot) {
+	i.snapshotMutex.Lock()
+	i.snapshot = snapshot
+	i.snapshotMutex.Unlock()
+
+	metrics := utils.NetworkMetrics(snapshotMetrics, i.network)
+	setIntMetric(metrics, "height", snapshot.BlockIdentifier.Index)
+	setIntMetric(metrics, "accounts", snapshot.Accounts)
+	setIntMetric(metrics, "funded_accounts", snapshot.FundedAccounts)
+	setIntMetric(metrics, "coins", snapshot.Coins)
+	setIntMetric(metrics, "timestamp", snapshot.Timestamp)
+
+	mean := new(expvar.Float)
+	mean.Set(snapshot.MeanCoinsPerAccount)
+	metrics.Set("mean_coins_per_account", mean)
+
+	for namespace, keys := range snapshot.DatabaseKeys {
+		setIntMetric(metrics, fmt.Sprintf("keys_%s", namespace), keys)
+	}
+}
+
+// setIntMetric sets an integer metric.
+func setIntMetric(metrics *expvar.Map, key string, value int64) {
+	metric := new(expvar.Int)
+	metric.Set(value)
+	metrics.Set(key, metric)
+}
+
+// MonitorSnapshots computes a new *utils.Snapshot on
+// start and every interval until the context is canceled.
+// Keys are scanned at most at snapshotKeysPerSecond.
+func (i *Indexer) MonitorSnapshots(ctx context.Context, interval time.Duration) error {
+	logger := utils.ExtractLogger(ctx, "snapshot")
+	limiter := newScanLimiter(snapshotKeysPerSecond)
+	for ctx.Err() == nil {
+		start := time.Now()
+		snapshot, err := i.computeSnapshot(ctx, limiter)
+		switch {
+		case ctx.Err() != nil:
+			return ctx.Err()
+		case err != nil:
+			// Failing to compute a snapshot (i.e. because no blocks
+			// have been synced yet) should never halt the indexer.
+			logger.Warnw("unable to compute snapshot", "error", err)
+		default:
+			i.publishSnapshot(snapshot)
+			logger.Infow(
+				"snapshot computed",
+				"index", snapshot.BlockIdentifier.Index,
+				"accounts", snapshot.Accounts,
+				"funded accounts", snapshot.FundedAccounts,
+				"coins", snapshot.Coins,
+				"mean coins per account", snapshot.MeanCoinsPerAccount,
+				"database keys", snapshot.DatabaseKeys,
+				"time", time.Since(start),
+			)
+		}
+
+		if err := sdkUtils.ContextSleep(ctx, interval); err != nil {
+			return err
+		}
+	}
+
+	return ctx.Err()
+}
+
+// GetSnapshot returns the most recently computed *utils.Snapshot.
+func (i *Indexer) GetSnapshot(ctx context.Context) (*utils.Snapshot, error) {
+	i.snapshotMutex.Lock()
+	defer i.snapshotMutex.Unlock()
+
+	if i.snapshot == nil {
+		return nil, errSnapshotNotReady
+	}
+
+	return i.snapshot, nil
+}
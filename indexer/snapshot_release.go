@@ -0,0 +1,369 @@
+// Copyright 2020 Coinbase, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package indexer
+
+import (
+	"context"
+	"crypto/ed25519"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/MNtank/rosetta-bitcoin/configuration"
+
+	"github.com/coinbase/rosetta-sdk-go/types"
+)
+
+const (
+	// snapshotManifestFile is the name of the
+	// manifest of an exported snapshot.
+	snapshotManifestFile = "manifest.json"
+
+	// snapshotDataDirectory is the directory of an
+	// exported snapshot the database is copied to.
+	snapshotDataDirectory = "indexer"
+
+	// snapshotImportSuffix is appended to the indexer
+	// path to stage the files of an imported snapshot
+	// until they are verified.
+	snapshotImportSuffix = ".import"
+
+	// badgerLockFile is the lock file of an open
+	// database, which is not exported.
+	badgerLockFile = "LOCK"
+
+	// snapshotPermissions are the permissions
+	// of exported and imported files.
+	snapshotPermissions = 0600
+
+	// snapshotDirectoryPermissions are the permissions
+	// of exported and imported directories.
+	snapshotDirectoryPermissions = 0700
+)
+
+var (
+	// ErrSnapshotSignatureInvalid is returned when a
+	// snapshot manifest was not signed by the trusted key.
+	ErrSnapshotSignatureInvalid = errors.New("snapshot signature is invalid")
+
+	// ErrSnapshotCorrupted is returned when a file of
+	// a snapshot does not match its manifest.
+	ErrSnapshotCorrupted = errors.New("snapshot file does not match manifest")
+
+	// ErrSnapshotWrongNetwork is returned when a snapshot
+	// was exported from another network.
+	ErrSnapshotWrongNetwork = errors.New("snapshot is for a different network")
+
+	// ErrIndexerNotEmpty is returned when a snapshot is
+	// imported over an existing database.
+	ErrIndexerNotEmpty = errors.New("indexer database is not empty")
+)
+
+// SnapshotFile is a file of an exported snapshot.
+type SnapshotFile struct {
+	// Path is relative to the data directory
+	// of the snapshot.
+	Path   string `json:"path"`
+	Size   int64  `json:"size"`
+	SHA256 string `json:"sha256"`
+}
+
+// SnapshotManifest describes (and attests to) the
+// files of an exported snapshot of the indexer
+// database.
+type SnapshotManifest struct {
+	NetworkIdentifier      *types.NetworkIdentifier `json:"network_identifier"`
+	GenesisBlockIdentifier *types.BlockIdentifier   `json:"genesis_block_identifier"`
+
+	// BlockIdentifier is the head of
+	// the indexer when exported.
+	BlockIdentifier *types.BlockIdentifier `json:"block_identifier"`
+	Timestamp       int64                  `json:"timestamp"`
+	Files           []*SnapshotFile        `json:"files"`
+
+	// Signature is the hex-encoded ed25519 signature of
+	// the manifest (without the signature).
+	Signature string `json:"signature"`
+}
+
+// snapshotManifestMessage returns the bytes
+// signed for a *SnapshotManifest.
+func snapshotManifestMessage(manifest *SnapshotManifest) ([]byte, error) {
+	unsigned := *manifest
+	unsigned.Signature = ""
+
+	message, err := json.Marshal(&unsigned)
+	if err != nil {
+		return nil, fmt.Errorf("%w: unable to marshal snapshot manifest", err)
+	}
+
+	return message, nil
+}
+
+// SignSnapshotManifest populates the
+// signature of manifest with key.
+func SignSnapshotManifest(key ed25519.PrivateKey, manifest *SnapshotManifest) error {
+	message, err := snapshotManifestMessage(manifest)
+	if err != nil {
+		return err
+	}
+
+	manifest.Signature = hex.EncodeToString(ed25519.Sign(key, message))
+	return nil
+}
+
+// VerifySnapshotManifest returns an error if manifest
+// was not signed by the holder of publicKey.
+func VerifySnapshotManifest(publicKey ed25519.PublicKey, manifest *SnapshotManifest) error {
+	signature, err := hex.DecodeString(manifest.Signature)
+	if err != nil {
+		return fmt.Errorf("%w: %v", ErrSnapshotSignatureInvalid, err)
+	}
+
+	message, err := snapshotManifestMessage(manifest)
+	if err != nil {
+		return err
+	}
+
+	if !ed25519.Verify(publicKey, message, signature) {
+		return ErrSnapshotSignatureInvalid
+	}
+
+	return nil
+}
+
+// copySnapshotFile copies the file at src to dst and
+// returns its *SnapshotFile (with a relative path of
+// name).
+func copySnapshotFile(src string, dst string, name string) (*SnapshotFile, error) {
+	in, err := os.Open(src) // #nosec G304
+	if err != nil {
+		return nil, fmt.Errorf("%w: unable to open %s", err, src)
+	}
+	defer in.Close()
+
+	out, err := os.OpenFile( // #nosec G304
+		dst,
+		os.O_CREATE|os.O_EXCL|os.O_WRONLY,
+		snapshotPermissions,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("%w: unable to create %s", err, dst)
+	}
+	defer out.Close()
+
+	hash := sha256.New()
+	size, err := io.Copy(io.MultiWriter(out, hash), in)
+	if err != nil {
+		return nil, fmt.Errorf("%w: unable to copy %s", err, src)
+	}
+
+	if err := out.Sync(); err != nil {
+		return nil, fmt.Errorf("%w: unable to sync %s", err, dst)
+	}
+
+	return &SnapshotFile{
+		Path:   name,
+		Size:   size,
+		SHA256: hex.EncodeToString(hash.Sum(nil)),
+	}, nil
+}
+
+// ExportSnapshot copies the indexer database to the directory
+// at path (which must not exist) with a manifest of its files
+// signed with key. The database must not be in use by another
+// process.
+func ExportSnapshot(
+	ctx context.Context,
+	cfg *configuration.Configuration,
+	path string,
+	key ed25519.PrivateKey,
+) (*SnapshotManifest, error) {
+	i, err := Initialize(ctx, nil, cfg, nil)
+	if err != nil {
+		return nil, fmt.Errorf("%w: unable to initialize indexer", err)
+	}
+
+	head, err := i.blockStorage.GetHeadBlockIdentifier(ctx)
+	i.CloseDatabase(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("%w: unable to get head block", err)
+	}
+
+	dataPath := filepath.Join(path, snapshotDataDirectory)
+	if err := os.Mkdir(path, snapshotDirectoryPermissions); err != nil {
+		return nil, fmt.Errorf("%w: unable to create %s", err, path)
+	}
+	if err := os.Mkdir(dataPath, snapshotDirectoryPermissions); err != nil {
+		return nil, fmt.Errorf("%w: unable to create %s", err, dataPath)
+	}
+
+	manifest := &SnapshotManifest{
+		NetworkIdentifier:      cfg.Network,
+		GenesisBlockIdentifier: cfg.GenesisBlockIdentifier,
+		BlockIdentifier:        head,
+		Timestamp:              time.Now().Unix(),
+		Files:                  []*SnapshotFile{},
+	}
+
+	entries, err := ioutil.ReadDir(cfg.IndexerPath)
+	if err != nil {
+		return nil, fmt.Errorf("%w: unable to read %s", err, cfg.IndexerPath)
+	}
+
+	for _, entry := range entries {
+		// The database does not
+		// contain subdirectories.
+		if !entry.Mode().IsRegular() || entry.Name() == badgerLockFile {
+			continue
+		}
+
+		file, err := copySnapshotFile(
+			filepath.Join(cfg.IndexerPath, entry.Name()),
+			filepath.Join(dataPath, entry.Name()),
+			entry.Name(),
+		)
+		if err != nil {
+			return nil, err
+		}
+
+		manifest.Files = append(manifest.Files, file)
+	}
+
+	if err := SignSnapshotManifest(key, manifest); err != nil {
+		return nil, err
+	}
+
+	contents, err := json.MarshalIndent(manifest, "", "  ")
+	if err != nil {
+		return nil, fmt.Errorf("%w: unable to marshal snapshot manifest", err)
+	}
+
+	manifestPath := filepath.Join(path, snapshotManifestFile)
+	if err := ioutil.WriteFile(manifestPath, contents, snapshotPermissions); err != nil {
+		return nil, fmt.Errorf("%w: unable to write %s", err, manifestPath)
+	}
+
+	return manifest, nil
+}
+
+// ImportSnapshot verifies the snapshot exported to path (its
+// manifest must be signed by the holder of publicKey and its
+// files must match the manifest) and copies it to the (empty)
+// indexer database. Nothing is imported if the snapshot can't
+// be verified.
+func ImportSnapshot(
+	ctx context.Context,
+	cfg *configuration.Configuration,
+	path string,
+	publicKey ed25519.PublicKey,
+) (*SnapshotManifest, error) {
+	manifestPath := filepath.Join(path, snapshotManifestFile)
+	contents, err := ioutil.ReadFile(manifestPath) // #nosec G304
+	if err != nil {
+		return nil, fmt.Errorf("%w: unable to read %s", err, manifestPath)
+	}
+
+	var manifest SnapshotManifest
+	if err := json.Unmarshal(contents, &manifest); err != nil {
+		return nil, fmt.Errorf("%w: unable to parse %s", err, manifestPath)
+	}
+
+	if err := VerifySnapshotManifest(publicKey, &manifest); err != nil {
+		return nil, err
+	}
+
+	if manifest.NetworkIdentifier == nil ||
+		types.Hash(manifest.NetworkIdentifier) != types.Hash(cfg.Network) ||
+		manifest.GenesisBlockIdentifier == nil ||
+		manifest.GenesisBlockIdentifier.Hash != cfg.GenesisBlockIdentifier.Hash {
+		return nil, fmt.Errorf(
+			"%w: snapshot is for %s",
+			ErrSnapshotWrongNetwork,
+			types.PrintStruct(manifest.NetworkIdentifier),
+		)
+	}
+
+	entries, err := ioutil.ReadDir(cfg.IndexerPath)
+	if err != nil {
+		return nil, fmt.Errorf("%w: unable to read %s", err, cfg.IndexerPath)
+	}
+	if len(entries) > 0 {
+		return nil, fmt.Errorf("%w: %s", ErrIndexerNotEmpty, cfg.IndexerPath)
+	}
+
+	// Files are copied to a staging directory (and
+	// only moved to the indexer path once all are
+	// verified).
+	stagingPath := cfg.IndexerPath + snapshotImportSuffix
+	if err := os.RemoveAll(stagingPath); err != nil {
+		return nil, fmt.Errorf("%w: unable to remove %s", err, stagingPath)
+	}
+	if err := os.Mkdir(stagingPath, snapshotDirectoryPermissions); err != nil {
+		return nil, fmt.Errorf("%w: unable to create %s", err, stagingPath)
+	}
+
+	if err := importSnapshotFiles(path, stagingPath, manifest.Files); err != nil {
+		_ = os.RemoveAll(stagingPath)
+		return nil, err
+	}
+
+	if err := os.Remove(cfg.IndexerPath); err != nil {
+		return nil, fmt.Errorf("%w: unable to remove %s", err, cfg.IndexerPath)
+	}
+	if err := os.Rename(stagingPath, cfg.IndexerPath); err != nil {
+		return nil, fmt.Errorf("%w: unable to move %s", err, stagingPath)
+	}
+
+	return &manifest, nil
+}
+
+// importSnapshotFiles copies files from the snapshot at
+// path to stagingPath and returns an error if any of
+// them does not match the manifest.
+func importSnapshotFiles(path string, stagingPath string, files []*SnapshotFile) error {
+	for _, file := range files {
+		// Files must be in the data directory
+		// of the snapshot.
+		if len(file.Path) == 0 ||
+			filepath.Base(file.Path) != file.Path ||
+			strings.HasPrefix(file.Path, ".") {
+			return fmt.Errorf("%w: invalid path %s", ErrSnapshotCorrupted, file.Path)
+		}
+
+		copied, err := copySnapshotFile(
+			filepath.Join(path, snapshotDataDirectory, file.Path),
+			filepath.Join(stagingPath, file.Path),
+			file.Path,
+		)
+		if err != nil {
+			return err
+		}
+
+		if copied.Size != file.Size || copied.SHA256 != file.SHA256 {
+			return fmt.Errorf("%w: %s", ErrSnapshotCorrupted, file.Path)
+		}
+	}
+
+	return nil
+}
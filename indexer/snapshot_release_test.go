@@ -0,0 +1,128 @@
+// Copyright 2020 Coinbase, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package indexer
+
+import (
+	"context"
+	"crypto/ed25519"
+	"crypto/rand"
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/MNtank/rosetta-bitcoin/bitcoin"
+	"github.com/MNtank/rosetta-bitcoin/configuration"
+
+	"github.com/coinbase/rosetta-sdk-go/types"
+	"github.com/coinbase/rosetta-sdk-go/utils"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSnapshotRelease(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	newDir, err := utils.CreateTempDir()
+	assert.NoError(t, err)
+	defer utils.RemoveTempDir(newDir)
+
+	newConfig := func(name string) *configuration.Configuration {
+		indexerPath := filepath.Join(newDir, name)
+		assert.NoError(t, os.Mkdir(indexerPath, 0700))
+
+		return &configuration.Configuration{
+			Network: &types.NetworkIdentifier{
+				Network:    bitcoin.MainnetNetwork,
+				Blockchain: bitcoin.Blockchain,
+			},
+			GenesisBlockIdentifier: bitcoin.MainnetGenesisBlockIdentifier,
+			IndexerPath:            indexerPath,
+		}
+	}
+
+	cfg := newConfig("source")
+	i, err := Initialize(ctx, cancel, cfg, nil)
+	assert.NoError(t, err)
+	i.blockStorage.Initialize(i.workers)
+	genesis := &types.BlockIdentifier{Index: 0, Hash: getBlockHash(0)}
+	assert.NoError(t, i.BlockAdded(ctx, &types.Block{
+		BlockIdentifier:       genesis,
+		ParentBlockIdentifier: genesis,
+	}))
+	i.CloseDatabase(ctx)
+
+	publicKey, key, err := ed25519.GenerateKey(rand.Reader)
+	assert.NoError(t, err)
+	otherPublicKey, _, err := ed25519.GenerateKey(rand.Reader)
+	assert.NoError(t, err)
+
+	snapshotPath := filepath.Join(newDir, "snapshot")
+	manifest, err := ExportSnapshot(ctx, cfg, snapshotPath, key)
+	assert.NoError(t, err)
+	assert.Equal(t, genesis, manifest.BlockIdentifier)
+	assert.NotEmpty(t, manifest.Files)
+	assert.NoError(t, VerifySnapshotManifest(publicKey, manifest))
+
+	// Snapshots are not exported over existing files
+	_, err = ExportSnapshot(ctx, cfg, snapshotPath, key)
+	assert.Error(t, err)
+
+	// Snapshots signed by another key are rejected
+	importCfg := newConfig("import")
+	_, err = ImportSnapshot(ctx, importCfg, snapshotPath, otherPublicKey)
+	assert.True(t, errors.Is(err, ErrSnapshotSignatureInvalid))
+
+	// Snapshots of other networks are rejected
+	testnetCfg := newConfig("testnet")
+	testnetCfg.Network = &types.NetworkIdentifier{
+		Network:    bitcoin.TestnetNetwork,
+		Blockchain: bitcoin.Blockchain,
+	}
+	testnetCfg.GenesisBlockIdentifier = testnetGenesisBlockIdentifier
+	_, err = ImportSnapshot(ctx, testnetCfg, snapshotPath, publicKey)
+	assert.True(t, errors.Is(err, ErrSnapshotWrongNetwork))
+
+	// Snapshots are not imported over an existing database
+	_, err = ImportSnapshot(ctx, cfg, snapshotPath, publicKey)
+	assert.True(t, errors.Is(err, ErrIndexerNotEmpty))
+
+	// Tampered files are rejected (and nothing is imported)
+	tamperedPath := filepath.Join(snapshotPath, snapshotDataDirectory, manifest.Files[0].Path)
+	original, err := ioutil.ReadFile(tamperedPath)
+	assert.NoError(t, err)
+	assert.NoError(t, ioutil.WriteFile(tamperedPath, append(original, 0x00), 0600))
+	_, err = ImportSnapshot(ctx, importCfg, snapshotPath, publicKey)
+	assert.True(t, errors.Is(err, ErrSnapshotCorrupted))
+	entries, err := ioutil.ReadDir(importCfg.IndexerPath)
+	assert.NoError(t, err)
+	assert.Empty(t, entries)
+	_, err = os.Stat(importCfg.IndexerPath + snapshotImportSuffix)
+	assert.True(t, os.IsNotExist(err))
+
+	assert.NoError(t, ioutil.WriteFile(tamperedPath, original, 0600))
+	imported, err := ImportSnapshot(ctx, importCfg, snapshotPath, publicKey)
+	assert.NoError(t, err)
+	assert.Equal(t, manifest, imported)
+
+	i, err = Initialize(ctx, cancel, importCfg, nil)
+	assert.NoError(t, err)
+	defer i.CloseDatabase(ctx)
+
+	head, err := i.blockStorage.GetHeadBlockIdentifier(ctx)
+	assert.NoError(t, err)
+	assert.Equal(t, genesis, head)
+}
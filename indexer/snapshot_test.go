@@ -0,0 +1,77 @@
+// Copyright 2020 Coinbase, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package indexer
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/coinbase/rosetta-sdk-go/storage/database"
+	"github.com/coinbase/rosetta-sdk-go/utils"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCountKeys_Limited(t *testing.T) {
+	ctx := context.Background()
+
+	newDir, err := utils.CreateTempDir()
+	assert.NoError(t, err)
+	defer utils.RemoveTempDir(newDir)
+
+	db, err := database.NewBadgerDatabase(ctx, newDir)
+	assert.NoError(t, err)
+	defer db.Close(ctx)
+
+	// Two batches of coins, owned by two accounts
+	dbTx := db.Transaction(ctx)
+	for i := 0; i < 2*snapshotScanBatch; i++ {
+		coin := fmt.Sprintf("coin %d", i)
+		assert.NoError(t, dbTx.Set(ctx, []byte(coinNamespace+namespaceSeparator+coin), nil, true))
+		assert.NoError(t, dbTx.Set(
+			ctx,
+			[]byte(fmt.Sprintf("%s/account %d/%s", coinAccountNamespace, i%2, coin)),
+			nil,
+			true,
+		))
+	}
+	assert.NoError(t, dbTx.Commit(ctx))
+
+	readTx := db.ReadTransaction(ctx)
+	defer readTx.Discard(ctx)
+
+	// Without a limiter, scans are not limited
+	keys, err := countKeys(ctx, readTx, coinNamespace, nil)
+	assert.NoError(t, err)
+	assert.Equal(t, int64(2*snapshotScanBatch), keys)
+
+	keys, accounts, err := countFundedAccounts(ctx, readTx, nil)
+	assert.NoError(t, err)
+	assert.Equal(t, int64(2*snapshotScanBatch), keys)
+	assert.Equal(t, int64(2), accounts)
+
+	// A fast limiter only paces the scan
+	keys, err = countKeys(ctx, readTx, coinNamespace, newScanLimiter(1e9))
+	assert.NoError(t, err)
+	assert.Equal(t, int64(2*snapshotScanBatch), keys)
+
+	// A slow limiter allows the first batch and then
+	// waits (longer than the deadline) for the next one
+	timeoutCtx, cancel := context.WithTimeout(ctx, 100*time.Millisecond)
+	defer cancel()
+	_, err = countKeys(timeoutCtx, readTx, coinNamespace, newScanLimiter(1))
+	assert.Error(t, err)
+}
@@ -0,0 +1,215 @@
+// Copyright 2020 Coinbase, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package indexer
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+
+	"github.com/coinbase/rosetta-sdk-go/storage/database"
+	"github.com/coinbase/rosetta-sdk-go/storage/modules"
+	"github.com/coinbase/rosetta-sdk-go/types"
+	"github.com/neilotoole/errgroup"
+)
+
+const (
+	// staleBlockNamespace is the namespace used to
+	// store each block removed by a reorg (by hash).
+	staleBlockNamespace = "stale-block"
+
+	// staleBlockHeightNamespace is the namespace used
+	// to store the hash of each stale block by height,
+	// so that stale blocks can be pruned by depth.
+	staleBlockHeightNamespace = "stale-block-height"
+
+	// staleBlockDepth is how many blocks below the
+	// head stale blocks are kept.
+	staleBlockDepth = 10000
+)
+
+var _ modules.BlockWorker = (*StaleBlockStorage)(nil)
+
+// StaleBlockStorage implements modules.BlockWorker to keep
+// each block removed by a reorg (with its transactions), so
+// that reconcilers can still fetch it by hash to unwind the
+// balances it changed. A stale block is forgotten if it is
+// added again (i.e. the chain reorgs back to it) or once
+// the head is depth blocks above it.
+type StaleBlockStorage struct {
+	db    database.Database
+	depth int64
+}
+
+// NewStaleBlockStorage returns a new *StaleBlockStorage.
+func NewStaleBlockStorage(db database.Database, depth int64) *StaleBlockStorage {
+	return &StaleBlockStorage{
+		db:    db,
+		depth: depth,
+	}
+}
+
+func getStaleBlockKey(hash string) []byte {
+	return []byte(fmt.Sprintf("%s/%s", staleBlockNamespace, hash))
+}
+
+func getStaleBlockHeightPrefix(index int64) []byte {
+	return []byte(fmt.Sprintf("%s/%d/", staleBlockHeightNamespace, index))
+}
+
+func getStaleBlockHeightKey(block *types.BlockIdentifier) []byte {
+	return append(getStaleBlockHeightPrefix(block.Index), []byte(block.Hash)...)
+}
+
+// AddingBlock is called by BlockStorage when adding a block.
+func (s *StaleBlockStorage) AddingBlock(
+	ctx context.Context,
+	g *errgroup.Group,
+	block *types.Block,
+	dbTx database.Transaction,
+) (database.CommitWorker, error) {
+	if err := s.deleteStaleBlock(ctx, dbTx, block.BlockIdentifier); err != nil {
+		return nil, err
+	}
+
+	// Every height is added after any block at that height
+	// is removed, so stale blocks depth blocks below the
+	// added block are pruned exactly once.
+	if block.BlockIdentifier.Index < s.depth {
+		return nil, nil
+	}
+
+	return nil, s.pruneStaleBlocks(ctx, dbTx, block.BlockIdentifier.Index-s.depth)
+}
+
+// deleteStaleBlock deletes a stale block (if it exists).
+func (s *StaleBlockStorage) deleteStaleBlock(
+	ctx context.Context,
+	dbTx database.Transaction,
+	block *types.BlockIdentifier,
+) error {
+	if err := dbTx.Delete(ctx, getStaleBlockKey(block.Hash)); err != nil {
+		return fmt.Errorf("%w: unable to delete stale block %s", err, block.Hash)
+	}
+
+	if err := dbTx.Delete(ctx, getStaleBlockHeightKey(block)); err != nil {
+		return fmt.Errorf("%w: unable to delete stale block height %s", err, block.Hash)
+	}
+
+	return nil
+}
+
+// pruneStaleBlocks deletes all stale blocks at index.
+func (s *StaleBlockStorage) pruneStaleBlocks(
+	ctx context.Context,
+	dbTx database.Transaction,
+	index int64,
+) error {
+	prefix := getStaleBlockHeightPrefix(index)
+	hashes := []string{}
+	_, err := dbTx.Scan(
+		ctx,
+		prefix,
+		prefix,
+		func(k []byte, v []byte) error {
+			hashes = append(hashes, string(v))
+			return nil
+		},
+		false,
+		false,
+	)
+	if err != nil {
+		return fmt.Errorf("%w: unable to scan stale blocks at %d", err, index)
+	}
+
+	for _, hash := range hashes {
+		block := &types.BlockIdentifier{Index: index, Hash: hash}
+		if err := s.deleteStaleBlock(ctx, dbTx, block); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// RemovingBlock is called by BlockStorage when removing a block.
+func (s *StaleBlockStorage) RemovingBlock(
+	ctx context.Context,
+	g *errgroup.Group,
+	block *types.Block,
+	dbTx database.Transaction,
+) (database.CommitWorker, error) {
+	value, err := json.Marshal(block)
+	if err != nil {
+		return nil, fmt.Errorf("%w: unable to marshal stale block", err)
+	}
+
+	key := getStaleBlockKey(block.BlockIdentifier.Hash)
+	if err := dbTx.Set(ctx, key, value, false); err != nil {
+		return nil, fmt.Errorf(
+			"%w: unable to store stale block %s",
+			err,
+			block.BlockIdentifier.Hash,
+		)
+	}
+
+	heightKey := getStaleBlockHeightKey(block.BlockIdentifier)
+	if err := dbTx.Set(ctx, heightKey, []byte(block.BlockIdentifier.Hash), false); err != nil {
+		return nil, fmt.Errorf(
+			"%w: unable to store stale block height %s",
+			err,
+			block.BlockIdentifier.Hash,
+		)
+	}
+
+	return nil, nil
+}
+
+// GetStaleBlock returns the block with hash removed
+// by a reorg (or nil if no such block was removed).
+func (s *StaleBlockStorage) GetStaleBlock(
+	ctx context.Context,
+	dbTx database.Transaction,
+	hash string,
+) (*types.Block, error) {
+	exists, value, err := dbTx.Get(ctx, getStaleBlockKey(hash))
+	if err != nil {
+		return nil, fmt.Errorf("%w: unable to get stale block %s", err, hash)
+	}
+
+	if !exists {
+		return nil, nil
+	}
+
+	var block types.Block
+	if err := json.Unmarshal(value, &block); err != nil {
+		return nil, fmt.Errorf("%w: unable to unmarshal stale block %s", err, hash)
+	}
+
+	return &block, nil
+}
+
+// GetStaleBlock returns the block with hash that was
+// removed by a reorg (or nil if no such block was
+// removed, it was added again since, or it was pruned).
+func (i *Indexer) GetStaleBlock(
+	ctx context.Context,
+	hash string,
+) (*types.Block, error) {
+	dbTx := i.database.ReadTransaction(ctx)
+	defer dbTx.Discard(ctx)
+
+	return i.staleBlocks.GetStaleBlock(ctx, dbTx, hash)
+}
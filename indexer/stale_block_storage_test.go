@@ -0,0 +1,135 @@
+// Copyright 2020 Coinbase, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package indexer
+
+import (
+	"context"
+	"fmt"
+	"testing"
+
+	"github.com/MNtank/rosetta-bitcoin/bitcoin"
+	"github.com/MNtank/rosetta-bitcoin/configuration"
+
+	"github.com/coinbase/rosetta-sdk-go/types"
+	"github.com/coinbase/rosetta-sdk-go/utils"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestIndexer_StaleBlocks(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	newDir, err := utils.CreateTempDir()
+	assert.NoError(t, err)
+	defer utils.RemoveTempDir(newDir)
+
+	cfg := &configuration.Configuration{
+		Network: &types.NetworkIdentifier{
+			Network:    bitcoin.MainnetNetwork,
+			Blockchain: bitcoin.Blockchain,
+		},
+		GenesisBlockIdentifier: bitcoin.MainnetGenesisBlockIdentifier,
+		IndexerPath:            newDir,
+	}
+
+	i, err := Initialize(ctx, cancel, cfg, nil)
+	assert.NoError(t, err)
+	defer i.CloseDatabase(ctx)
+
+	i.blockStorage.Initialize(i.workers)
+	blocks := []*types.Block{}
+	for j := int64(0); j < 3; j++ {
+		block := &types.Block{
+			BlockIdentifier: &types.BlockIdentifier{
+				Index: j,
+				Hash:  getBlockHash(j),
+			},
+			ParentBlockIdentifier: &types.BlockIdentifier{
+				Index: j - 1,
+				Hash:  getBlockHash(j - 1),
+			},
+			Transactions: []*types.Transaction{
+				{
+					TransactionIdentifier: &types.TransactionIdentifier{
+						Hash: getBlockHash(j) + " tx",
+					},
+					Operations: []*types.Operation{
+						{
+							OperationIdentifier: &types.OperationIdentifier{Index: 0},
+							Type:                bitcoin.OutputOpType,
+							Status:              types.String(bitcoin.SuccessStatus),
+							Account:             &types.AccountIdentifier{Address: "addr1"},
+						},
+					},
+				},
+			},
+			Metadata: map[string]interface{}{
+				"nonce": float64(j),
+			},
+		}
+		if j == 0 {
+			block.ParentBlockIdentifier = block.BlockIdentifier
+		}
+
+		assert.NoError(t, i.blockStorage.SeeBlock(ctx, block))
+		assert.NoError(t, i.blockStorage.AddBlock(ctx, block))
+		blocks = append(blocks, block)
+	}
+
+	// Blocks that were never removed are not stale
+	stale, err := i.GetStaleBlock(ctx, getBlockHash(2))
+	assert.NoError(t, err)
+	assert.Nil(t, stale)
+
+	// Blocks removed by a reorg are kept (with
+	// their transactions)
+	assert.NoError(t, i.blockStorage.RemoveBlock(ctx, blocks[2].BlockIdentifier))
+	stale, err = i.GetStaleBlock(ctx, getBlockHash(2))
+	assert.NoError(t, err)
+	assert.Equal(t, blocks[2], stale)
+
+	// Stale blocks are forgotten once added again
+	assert.NoError(t, i.blockStorage.SeeBlock(ctx, blocks[2]))
+	assert.NoError(t, i.blockStorage.AddBlock(ctx, blocks[2]))
+	stale, err = i.GetStaleBlock(ctx, getBlockHash(2))
+	assert.NoError(t, err)
+	assert.Nil(t, stale)
+
+	// Stale blocks are pruned once the head is
+	// depth blocks above them
+	i.staleBlocks.depth = 2
+	assert.NoError(t, i.blockStorage.RemoveBlock(ctx, blocks[2].BlockIdentifier))
+	parent := blocks[1].BlockIdentifier
+	for j := int64(2); j < 5; j++ {
+		stale, err = i.GetStaleBlock(ctx, getBlockHash(2))
+		assert.NoError(t, err)
+		assert.Equal(t, blocks[2], stale)
+
+		block := &types.Block{
+			BlockIdentifier: &types.BlockIdentifier{
+				Index: j,
+				Hash:  fmt.Sprintf("fork %d", j),
+			},
+			ParentBlockIdentifier: parent,
+		}
+		assert.NoError(t, i.blockStorage.SeeBlock(ctx, block))
+		assert.NoError(t, i.blockStorage.AddBlock(ctx, block))
+		parent = block.BlockIdentifier
+	}
+
+	stale, err = i.GetStaleBlock(ctx, getBlockHash(2))
+	assert.NoError(t, err)
+	assert.Nil(t, stale)
+}
@@ -0,0 +1,99 @@
+// Copyright 2020 Coinbase, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package indexer
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+const (
+	// syncRateWindow is how far back added blocks
+	// are considered when computing the sync rate.
+	syncRateWindow = time.Minute
+)
+
+// syncRateSample is the index of a block
+// and when it was added.
+type syncRateSample struct {
+	index int64
+	added time.Time
+}
+
+// syncRateTracker computes how many blocks per second
+// the syncer added over the last syncRateWindow.
+type syncRateTracker struct {
+	mutex   sync.Mutex
+	samples []*syncRateSample
+}
+
+func newSyncRateTracker() *syncRateTracker {
+	return &syncRateTracker{}
+}
+
+// prune removes the samples older than syncRateWindow
+// (keeping the most recent one). mutex must be held.
+func (s *syncRateTracker) prune(now time.Time) {
+	cutoff := now.Add(-syncRateWindow)
+	for len(s.samples) > 1 && s.samples[0].added.Before(cutoff) {
+		s.samples = s.samples[1:]
+	}
+}
+
+// added records that the block at index was added at
+// now. Samples are discarded when a lower block is added
+// (after a reorg), as they no longer describe progress.
+func (s *syncRateTracker) added(index int64, now time.Time) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	if len(s.samples) > 0 && s.samples[len(s.samples)-1].index >= index {
+		s.samples = nil
+	}
+
+	s.samples = append(s.samples, &syncRateSample{index: index, added: now})
+	s.prune(now)
+}
+
+// rate returns the blocks added per second between the
+// oldest sample in the window and now (0 if fewer than
+// 2 blocks were added), so the rate decays if the
+// syncer stalls.
+func (s *syncRateTracker) rate(now time.Time) float64 {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	s.prune(now)
+	if len(s.samples) < 2 { // nolint:gomnd
+		return 0
+	}
+
+	first := s.samples[0]
+	last := s.samples[len(s.samples)-1]
+	elapsed := now.Sub(first.added).Seconds()
+	if elapsed <= 0 {
+		return 0
+	}
+
+	return float64(last.index-first.index) / elapsed
+}
+
+// GetSyncRate returns how many blocks per second the
+// syncer added over the last minute (0 if it is not
+// adding blocks).
+func (i *Indexer) GetSyncRate(ctx context.Context) float64 {
+	return i.syncRate.rate(time.Now())
+}
@@ -0,0 +1,54 @@
+// Copyright 2020 Coinbase, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package indexer
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSyncRateTracker(t *testing.T) {
+	s := newSyncRateTracker()
+	start := time.Unix(1600000000, 0)
+
+	// No blocks added
+	assert.Equal(t, float64(0), s.rate(start))
+
+	// A single block is not enough to compute a rate
+	s.added(100, start)
+	assert.Equal(t, float64(0), s.rate(start))
+
+	// 20 blocks in 10 seconds
+	s.added(110, start.Add(5*time.Second))
+	s.added(120, start.Add(10*time.Second))
+	assert.Equal(t, float64(2), s.rate(start.Add(10*time.Second)))
+
+	// The rate decays when no blocks are added
+	assert.Equal(t, float64(1), s.rate(start.Add(20*time.Second)))
+
+	// Samples older than the window are pruned
+	// (keeping the most recent one)
+	s.added(130, start.Add(70*time.Second))
+	assert.Equal(t, float64(10)/60, s.rate(start.Add(70*time.Second)))
+	assert.Equal(t, float64(0), s.rate(start.Add(200*time.Second)))
+
+	// Adding a lower block (a reorg)
+	// resets the samples
+	s.added(140, start.Add(201*time.Second))
+	s.added(139, start.Add(202*time.Second))
+	assert.Equal(t, float64(0), s.rate(start.Add(202*time.Second)))
+}
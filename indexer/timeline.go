@@ -0,0 +1,130 @@
+// Copyright 2020 Coinbase, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package indexer
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+
+	"github.com/MNtank/rosetta-bitcoin/utils"
+
+	"github.com/coinbase/rosetta-sdk-go/types"
+)
+
+var (
+	errTimelineNotFound = errors.New("block timeline not found")
+)
+
+// milliseconds returns d in (fractional) milliseconds.
+func milliseconds(d time.Duration) float64 {
+	return float64(d) / float64(time.Millisecond)
+}
+
+// timelineTable stores the *utils.BlockTimeline of the
+// most recently added blocks (keyed by height).
+//
+// Blocks are fetched concurrently (and possibly more than
+// once when there is a reorg), so timelines are kept
+// pending (keyed by hash) until the block is added.
+type timelineTable struct {
+	size int64
+
+	mutex     sync.Mutex
+	pending   map[string]*utils.BlockTimeline
+	timelines map[int64]*utils.BlockTimeline
+}
+
+func newTimelineTable(size int64) *timelineTable {
+	return &timelineTable{
+		size:      size,
+		pending:   map[string]*utils.BlockTimeline{},
+		timelines: map[int64]*utils.BlockTimeline{},
+	}
+}
+
+// enabled returns true if timelines
+// should be recorded.
+func (t *timelineTable) enabled() bool {
+	return t.size > 0
+}
+
+// fetched records the timeline of a fetched (but
+// not yet added) block.
+func (t *timelineTable) fetched(timeline *utils.BlockTimeline) {
+	if !t.enabled() {
+		return
+	}
+
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+
+	t.pending[timeline.BlockIdentifier.Hash] = timeline
+}
+
+// added records how long it took to commit a block
+// and stores its completed timeline.
+func (t *timelineTable) added(blockIdentifier *types.BlockIdentifier, commit time.Duration) {
+	if !t.enabled() {
+		return
+	}
+
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+
+	timeline, ok := t.pending[blockIdentifier.Hash]
+	if !ok {
+		return
+	}
+
+	timeline.Commit = milliseconds(commit)
+	timeline.Total += timeline.Commit
+	t.timelines[blockIdentifier.Index] = timeline
+
+	// Pending timelines of blocks at or below the added
+	// block will never be added (they were orphaned).
+	for hash, pending := range t.pending {
+		if pending.BlockIdentifier.Index <= blockIdentifier.Index {
+			delete(t.pending, hash)
+		}
+	}
+
+	delete(t.timelines, blockIdentifier.Index-t.size)
+}
+
+// get returns the timeline of the block at index.
+func (t *timelineTable) get(index int64) (*utils.BlockTimeline, bool) {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+
+	timeline, ok := t.timelines[index]
+	return timeline, ok
+}
+
+// GetBlockTimeline returns the *utils.BlockTimeline of the
+// block at index (if it is one of the most recently added
+// blocks).
+func (i *Indexer) GetBlockTimeline(
+	ctx context.Context,
+	index int64,
+) (*utils.BlockTimeline, error) {
+	timeline, ok := i.timelines.get(index)
+	if !ok {
+		return nil, errTimelineNotFound
+	}
+
+	return timeline, nil
+}
@@ -0,0 +1,69 @@
+// Copyright 2020 Coinbase, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package indexer
+
+import (
+	"testing"
+	"time"
+
+	"github.com/MNtank/rosetta-bitcoin/utils"
+
+	"github.com/coinbase/rosetta-sdk-go/types"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestTimelineTable(t *testing.T) {
+	table := newTimelineTable(2)
+	identifier := func(index int64, hash string) *types.BlockIdentifier {
+		return &types.BlockIdentifier{Index: index, Hash: hash}
+	}
+
+	for index := int64(0); index < 3; index++ {
+		table.fetched(&utils.BlockTimeline{
+			BlockIdentifier: identifier(index, "a"+getBlockHash(index)),
+			Total:           1,
+		})
+	}
+
+	// Orphaned block fetched at the same height
+	table.fetched(&utils.BlockTimeline{
+		BlockIdentifier: identifier(1, "b"+getBlockHash(1)),
+		Total:           1,
+	})
+
+	for index := int64(0); index < 3; index++ {
+		table.added(identifier(index, "a"+getBlockHash(index)), 2*time.Millisecond)
+	}
+
+	// Only the most recent blocks are kept
+	_, ok := table.get(0)
+	assert.False(t, ok)
+
+	timeline, ok := table.get(2)
+	assert.True(t, ok)
+	assert.Equal(t, float64(2), timeline.Commit)
+	assert.Equal(t, float64(3), timeline.Total)
+
+	_, ok = table.get(1)
+	assert.True(t, ok)
+	assert.Len(t, table.pending, 0)
+
+	// Timelines are not recorded when disabled
+	disabled := newTimelineTable(0)
+	disabled.fetched(&utils.BlockTimeline{BlockIdentifier: identifier(0, "a")})
+	disabled.added(identifier(0, "a"), time.Millisecond)
+	_, ok = disabled.get(0)
+	assert.False(t, ok)
+}
@@ -0,0 +1,88 @@
+// Copyright 2020 Coinbase, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package indexer
+
+import (
+	"errors"
+	"sync"
+
+	"github.com/MNtank/rosetta-bitcoin/bitcoin"
+)
+
+// ErrNoParams is returned when a feature requiring the
+// params of the network is used by an indexer without
+// params.
+var ErrNoParams = errors.New("indexer has no network params")
+
+// UpgradeHook is called with the height of the
+// activation block of an upgrade once it is added.
+type UpgradeHook func(height int64)
+
+// upgradeHookTable stores the hooks registered for
+// each activation height.
+type upgradeHookTable struct {
+	mutex sync.Mutex
+	hooks map[int64][]UpgradeHook
+}
+
+func newUpgradeHookTable() *upgradeHookTable {
+	return &upgradeHookTable{
+		hooks: map[int64][]UpgradeHook{},
+	}
+}
+
+// add registers hook for the activation
+// block at height.
+func (u *upgradeHookTable) add(height int64, hook UpgradeHook) {
+	u.mutex.Lock()
+	defer u.mutex.Unlock()
+
+	u.hooks[height] = append(u.hooks[height], hook)
+}
+
+// fire calls the hooks registered for the activation
+// block at height (outside of the lock, so that hooks
+// can register other hooks).
+func (u *upgradeHookTable) fire(height int64) {
+	u.mutex.Lock()
+	hooks := append([]UpgradeHook{}, u.hooks[height]...)
+	u.mutex.Unlock()
+
+	for _, hook := range hooks {
+		hook(height)
+	}
+}
+
+// OnUpgradeActivated registers hook to be called by the syncer
+// when it adds the activation block of the upgrade with id (see
+// bitcoin.ActivationHeight), so that services can switch rules
+// at exactly that block. Hooks are called synchronously, before
+// the next block is processed, and are called again if the
+// activation block is re-added after a reorg. Hooks registered
+// after the activation block was added are not called (use
+// bitcoin.IsUpgradeActive to check the current rules).
+func (i *Indexer) OnUpgradeActivated(id int, hook UpgradeHook) error {
+	if i.params == nil {
+		return ErrNoParams
+	}
+
+	height, err := bitcoin.ActivationHeight(i.params, id)
+	if err != nil {
+		return err
+	}
+
+	i.upgradeHooks.add(int64(height), hook)
+	return nil
+}
@@ -0,0 +1,88 @@
+// Copyright 2020 Coinbase, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package indexer
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/MNtank/rosetta-bitcoin/bitcoin"
+	"github.com/MNtank/rosetta-bitcoin/configuration"
+
+	"github.com/coinbase/rosetta-sdk-go/types"
+	"github.com/coinbase/rosetta-sdk-go/utils"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestOnUpgradeActivated(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	newDir, err := utils.CreateTempDir()
+	assert.NoError(t, err)
+	defer utils.RemoveTempDir(newDir)
+
+	params := bitcoin.CloneParams(bitcoin.MainnetParams)
+	params.BIP0065Height = 2
+	cfg := &configuration.Configuration{
+		Network: &types.NetworkIdentifier{
+			Network:    bitcoin.MainnetNetwork,
+			Blockchain: bitcoin.Blockchain,
+		},
+		GenesisBlockIdentifier: bitcoin.MainnetGenesisBlockIdentifier,
+		Params:                 params,
+		IndexerPath:            newDir,
+	}
+
+	i, err := Initialize(ctx, cancel, cfg, nil)
+	assert.NoError(t, err)
+	defer i.CloseDatabase(ctx)
+
+	activated := []int64{}
+	assert.NoError(t, i.OnUpgradeActivated(bitcoin.BIP0065Upgrade, func(height int64) {
+		activated = append(activated, height)
+	}))
+	assert.True(t, errors.Is(i.OnUpgradeActivated(10, func(int64) {}), bitcoin.ErrUnknownUpgrade))
+
+	i.blockStorage.Initialize(i.workers)
+	for j := int64(0); j < 4; j++ {
+		block := &types.Block{
+			BlockIdentifier:       &types.BlockIdentifier{Index: j, Hash: getBlockHash(j)},
+			ParentBlockIdentifier: &types.BlockIdentifier{Index: j - 1, Hash: getBlockHash(j - 1)},
+		}
+		if j == 0 {
+			block.ParentBlockIdentifier = block.BlockIdentifier
+		}
+
+		assert.NoError(t, i.BlockAdded(ctx, block))
+		if j < 2 {
+			assert.Empty(t, activated)
+		}
+	}
+
+	// The hook is only called for the activation block
+	assert.Equal(t, []int64{2}, activated)
+
+	// Indexers without params have no upgrades
+	noParams, err := Initialize(ctx, cancel, &configuration.Configuration{
+		Network:                cfg.Network,
+		GenesisBlockIdentifier: cfg.GenesisBlockIdentifier,
+		IndexerPath:            t.TempDir(),
+	}, nil)
+	assert.NoError(t, err)
+	defer noParams.CloseDatabase(ctx)
+	assert.True(t, errors.Is(noParams.OnUpgradeActivated(bitcoin.BIP0065Upgrade, func(int64) {}), ErrNoParams))
+}
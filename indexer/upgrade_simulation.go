@@ -0,0 +1,328 @@
+// Copyright 2020 Coinbase, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package indexer
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+
+	"github.com/MNtank/rosetta-bitcoin/bitcoin"
+
+	"github.com/coinbase/rosetta-sdk-go/storage/database"
+	storageErrs "github.com/coinbase/rosetta-sdk-go/storage/errors"
+	"github.com/coinbase/rosetta-sdk-go/types"
+)
+
+// UpgradeChange is an operation that would be
+// parsed differently once an upgrade is active.
+type UpgradeChange struct {
+	Upgrade               string                       `json:"upgrade"`
+	BlockIdentifier       *types.BlockIdentifier       `json:"block_identifier"`
+	TransactionIdentifier *types.TransactionIdentifier `json:"transaction_identifier"`
+	OperationIdentifier   *types.OperationIdentifier   `json:"operation_identifier"`
+
+	// Operation is the operation as it is parsed and
+	// UpgradedOperation as it would be parsed once the
+	// upgrade is active (either is nil if the operation
+	// would only be parsed with or without the upgrade).
+	Operation         *types.Operation `json:"operation"`
+	UpgradedOperation *types.Operation `json:"upgraded_operation"`
+}
+
+// UpgradeSimulation summarizes the blocks
+// replayed by SimulateUpgrades.
+type UpgradeSimulation struct {
+	// StartBlock and EndBlock are the first and last
+	// blocks replayed (nil if no block was replayed).
+	StartBlock *types.BlockIdentifier `json:"start_block"`
+	EndBlock   *types.BlockIdentifier `json:"end_block"`
+
+	Blocks       int64 `json:"blocks"`
+	Transactions int64 `json:"transactions"`
+
+	ChangedBlocks       int64 `json:"changed_blocks"`
+	ChangedTransactions int64 `json:"changed_transactions"`
+	ChangedOperations   int64 `json:"changed_operations"`
+}
+
+// SimulateUpgrades replays the last blocks stored blocks as
+// if upgrades had been activated and writes each *UpgradeChange
+// to w as newline-delimited JSON. Each block an upgrade is active
+// in is fetched from the node again and parsed twice: as the node
+// returns it and as the node would return it once the upgrade is
+// active (with the coins it spends owned as they would have been
+// indexed with upgrades), and the operations are diffed. Blocks
+// that were pruned are not replayed. eras are the address eras
+// of the network.
+func (i *Indexer) SimulateUpgrades(
+	ctx context.Context,
+	w io.Writer,
+	eras []*bitcoin.AddressEra,
+	upgrades []*bitcoin.Upgrade,
+	blocks int64,
+) (*UpgradeSimulation, error) {
+	if err := bitcoin.ValidateUpgrades(upgrades); err != nil {
+		return nil, err
+	}
+
+	if err := i.waitForNode(ctx); err != nil {
+		return nil, fmt.Errorf("%w: failed to wait for node", err)
+	}
+
+	head, err := i.blockStorage.GetHeadBlockIdentifier(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("%w: unable to get head block", err)
+	}
+
+	start := head.Index - blocks + 1
+	if start < 0 {
+		start = 0
+	}
+
+	writer := bufio.NewWriter(w)
+	simulation := &UpgradeSimulation{}
+	for index := start; index <= head.Index; index++ {
+		block, err := i.blockStorage.GetBlock(
+			ctx,
+			&types.PartialBlockIdentifier{Index: types.Int64(index)},
+		)
+		if errors.Is(err, storageErrs.ErrCannotAccessPrunedData) {
+			continue
+		}
+		if err != nil {
+			return nil, fmt.Errorf("%w: unable to get block %d", err, index)
+		}
+
+		if simulation.StartBlock == nil {
+			simulation.StartBlock = block.BlockIdentifier
+		}
+		simulation.EndBlock = block.BlockIdentifier
+		simulation.Blocks++
+		simulation.Transactions += int64(len(block.Transactions))
+
+		// Coins spent by a block are created in earlier
+		// blocks, so no upgrade is active when they are
+		// created either.
+		upgrade := bitcoin.ActiveUpgrade(upgrades, index)
+		if upgrade == nil {
+			continue
+		}
+
+		changes, err := i.simulateUpgrade(ctx, block.BlockIdentifier, eras, upgrades, upgrade)
+		if err != nil {
+			return nil, err
+		}
+
+		changedTransactions := map[string]struct{}{}
+		for _, change := range changes {
+			encoded, err := json.Marshal(change)
+			if err != nil {
+				return nil, fmt.Errorf("%w: unable to encode upgrade change", err)
+			}
+
+			if _, err := writer.Write(append(encoded, '\n')); err != nil {
+				return nil, fmt.Errorf("%w: unable to write upgrade change", err)
+			}
+
+			changedTransactions[change.TransactionIdentifier.Hash] = struct{}{}
+			simulation.ChangedOperations++
+		}
+
+		simulation.ChangedTransactions += int64(len(changedTransactions))
+		if len(changes) > 0 {
+			simulation.ChangedBlocks++
+		}
+	}
+
+	if err := writer.Flush(); err != nil {
+		return nil, fmt.Errorf("%w: unable to flush upgrade changes", err)
+	}
+
+	return simulation, nil
+}
+
+// simulateUpgrade fetches the block with blockIdentifier from
+// the node, parses it with and without upgrade (active in the
+// block), and returns the operations that are parsed differently.
+func (i *Indexer) simulateUpgrade(
+	ctx context.Context,
+	blockIdentifier *types.BlockIdentifier,
+	eras []*bitcoin.AddressEra,
+	upgrades []*bitcoin.Upgrade,
+	upgrade *bitcoin.Upgrade,
+) ([]*UpgradeChange, error) {
+	btcBlock, _, err := i.client.GetRawBlock(
+		ctx,
+		&types.PartialBlockIdentifier{Hash: &blockIdentifier.Hash},
+	)
+	if err != nil {
+		return nil, fmt.Errorf("%w: unable to get block %s", err, blockIdentifier.Hash)
+	}
+
+	coins, upgradedCoins, err := i.simulationCoins(ctx, btcBlock, eras, upgrades)
+	if err != nil {
+		return nil, err
+	}
+
+	block, err := i.client.ParseBlock(ctx, btcBlock, coins)
+	if err != nil {
+		return nil, fmt.Errorf("%w: unable to parse block %s", err, blockIdentifier.Hash)
+	}
+
+	upgradedBtcBlock, err := bitcoin.UpgradeBlock(btcBlock, eras, upgrade)
+	if err != nil {
+		return nil, err
+	}
+
+	upgradedBlock, err := i.client.ParseBlock(ctx, upgradedBtcBlock, upgradedCoins)
+	if err != nil {
+		return nil, fmt.Errorf(
+			"%w: unable to parse block %s with %s",
+			err,
+			blockIdentifier.Hash,
+			upgrade.Name,
+		)
+	}
+
+	changes := []*UpgradeChange{}
+	for j, transaction := range block.Transactions {
+		upgradedOperations := []*types.Operation{}
+		if j < len(upgradedBlock.Transactions) {
+			upgradedOperations = upgradedBlock.Transactions[j].Operations
+		}
+
+		for k := 0; k < len(transaction.Operations) || k < len(upgradedOperations); k++ {
+			change := &UpgradeChange{
+				Upgrade:               upgrade.Name,
+				BlockIdentifier:       blockIdentifier,
+				TransactionIdentifier: transaction.TransactionIdentifier,
+				OperationIdentifier:   &types.OperationIdentifier{Index: int64(k)},
+			}
+			if k < len(transaction.Operations) {
+				change.Operation = transaction.Operations[k]
+			}
+			if k < len(upgradedOperations) {
+				change.UpgradedOperation = upgradedOperations[k]
+			}
+
+			if types.Hash(change.Operation) != types.Hash(change.UpgradedOperation) {
+				changes = append(changes, change)
+			}
+		}
+	}
+
+	return changes, nil
+}
+
+// simulationCoins returns the coins spent by btcBlock as they
+// are indexed and as they would be indexed with upgrades (owned
+// by the upgraded address if an upgrade is active in the block
+// of the transaction that created them).
+func (i *Indexer) simulationCoins(
+	ctx context.Context,
+	btcBlock *bitcoin.Block,
+	eras []*bitcoin.AddressEra,
+	upgrades []*bitcoin.Upgrade,
+) (map[string]*types.AccountCoin, map[string]*types.AccountCoin, error) {
+	databaseTransaction := i.database.ReadTransaction(ctx)
+	defer databaseTransaction.Discard(ctx)
+
+	coins := map[string]*types.AccountCoin{}
+	upgradedCoins := map[string]*types.AccountCoin{}
+	for _, tx := range btcBlock.Txs {
+		for _, input := range tx.Inputs {
+			if len(input.Coinbase) > 0 || bitcoin.IsZerocoinSpend(input) {
+				continue
+			}
+
+			coinIdentifier := bitcoin.CoinIdentifier(input.TxHash, input.Vout)
+			accountCoin, createdIn, err := i.findCreatedCoin(
+				ctx,
+				databaseTransaction,
+				input.TxHash,
+				coinIdentifier,
+			)
+			if err != nil {
+				return nil, nil, err
+			}
+
+			coins[coinIdentifier] = accountCoin
+			upgradedCoins[coinIdentifier] = accountCoin
+
+			upgrade := bitcoin.ActiveUpgrade(upgrades, createdIn.Index)
+			if upgrade == nil {
+				continue
+			}
+
+			address, err := bitcoin.UpgradeAccountAddress(accountCoin.Account.Address, eras, upgrade)
+			if err != nil {
+				return nil, nil, err
+			}
+
+			account := *accountCoin.Account
+			account.Address = address
+			upgradedCoins[coinIdentifier] = &types.AccountCoin{
+				Account: &account,
+				Coin:    accountCoin.Coin,
+			}
+		}
+	}
+
+	return coins, upgradedCoins, nil
+}
+
+// findCreatedCoin returns the coin with coinIdentifier
+// created by the stored transaction with hash (spent or
+// not) and the block of the transaction.
+func (i *Indexer) findCreatedCoin(
+	ctx context.Context,
+	databaseTransaction database.Transaction,
+	hash string,
+	coinIdentifier string,
+) (*types.AccountCoin, *types.BlockIdentifier, error) {
+	block, transaction, err := i.blockStorage.FindTransaction(
+		ctx,
+		&types.TransactionIdentifier{Hash: hash},
+		databaseTransaction,
+	)
+	if err != nil {
+		return nil, nil, fmt.Errorf("%w: unable to find transaction %s", err, hash)
+	}
+	if transaction == nil {
+		return nil, nil, fmt.Errorf("transaction %s not found", hash)
+	}
+
+	for _, op := range transaction.Operations {
+		if op.CoinChange == nil ||
+			op.CoinChange.CoinAction != types.CoinCreated ||
+			op.CoinChange.CoinIdentifier.Identifier != coinIdentifier {
+			continue
+		}
+
+		return &types.AccountCoin{
+			Account: op.Account,
+			Coin: &types.Coin{
+				CoinIdentifier: op.CoinChange.CoinIdentifier,
+				Amount:         op.Amount,
+			},
+		}, block, nil
+	}
+
+	return nil, nil, fmt.Errorf("coin %s not found", coinIdentifier)
+}
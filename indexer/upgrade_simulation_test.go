@@ -0,0 +1,219 @@
+// Copyright 2020 Coinbase, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package indexer
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"testing"
+
+	"github.com/MNtank/rosetta-bitcoin/bitcoin"
+	"github.com/MNtank/rosetta-bitcoin/configuration"
+	mocks "github.com/MNtank/rosetta-bitcoin/mocks/indexer"
+
+	"github.com/coinbase/rosetta-sdk-go/types"
+	"github.com/coinbase/rosetta-sdk-go/utils"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+func TestSimulateUpgrades(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	newDir, err := utils.CreateTempDir()
+	assert.NoError(t, err)
+	defer utils.RemoveTempDir(newDir)
+
+	cfg := &configuration.Configuration{
+		Network: &types.NetworkIdentifier{
+			Network:    bitcoin.MainnetNetwork,
+			Blockchain: bitcoin.Blockchain,
+		},
+		GenesisBlockIdentifier: bitcoin.MainnetGenesisBlockIdentifier,
+		IndexerPath:            newDir,
+	}
+
+	// Blocks are parsed as the node returns them.
+	mockClient := &mocks.Client{}
+	parser := bitcoin.NewClient("", cfg.GenesisBlockIdentifier, nil, bitcoin.MainnetCurrency)
+	parser.UseParams(bitcoin.MainnetParams)
+	parse := func(
+		ctx context.Context,
+		btcBlock *bitcoin.Block,
+		coins map[string]*types.AccountCoin,
+	) *types.Block {
+		block, err := parser.ParseBlock(ctx, btcBlock, coins)
+		assert.NoError(t, err)
+		return block
+	}
+	mockClient.On("ParseBlock", mock.Anything, mock.Anything, mock.Anything).Return(parse, nil)
+
+	i, err := Initialize(ctx, cancel, cfg, mockClient)
+	assert.NoError(t, err)
+	defer i.CloseDatabase(ctx)
+
+	// Each block pays a base58 address and a segwit
+	// address, and the last block spends a coin created
+	// before the upgrade and one created after it.
+	accounts := []string{
+		"EH9uVaqWRxHuzJbroqzX18yxmeW8XVJyV9",
+		"bc1qw508d6qejxtdg4y5r3zarvary0c5xw7kv8f3t4",
+	}
+	btcBlocks := []*bitcoin.Block{}
+	i.blockStorage.Initialize(i.workers)
+	for index := int64(0); index <= 4; index++ {
+		btcBlock := &bitcoin.Block{
+			Hash:              getBlockHash(index),
+			Height:            index,
+			PreviousBlockHash: getBlockHash(index - 1),
+			Time:              1600000000 + index,
+			Txs: []*bitcoin.Transaction{
+				{
+					Hash:   fmt.Sprintf("tx %d", index),
+					Inputs: []*bitcoin.Input{{Coinbase: "0101", Sequence: 4294967295}},
+					Outputs: []*bitcoin.Output{
+						{
+							Value: 10,
+							Index: 0,
+							ScriptPubKey: &bitcoin.ScriptPubKey{
+								Hex:       "76a914000000000000000000000000000000000000000088ac",
+								Type:      "pubkeyhash",
+								Addresses: []string{accounts[0]},
+							},
+						},
+						{
+							Value: 10,
+							Index: 1,
+							ScriptPubKey: &bitcoin.ScriptPubKey{
+								Hex:       "0014751e76e8199196d454941c45d1b3a323f1433bd6",
+								Type:      "witness_v0_keyhash",
+								Addresses: []string{accounts[1]},
+							},
+						},
+					},
+				},
+			},
+		}
+		if index == 4 {
+			btcBlock.Txs = append(btcBlock.Txs, &bitcoin.Transaction{
+				Hash: "spend",
+				Inputs: []*bitcoin.Input{
+					{TxHash: "tx 1", Vout: 0, Sequence: 4294967295},
+					{TxHash: "tx 3", Vout: 0, Sequence: 4294967295},
+				},
+				Outputs: []*bitcoin.Output{btcBlock.Txs[0].Outputs[1]},
+			})
+		}
+
+		coins := map[string]*types.AccountCoin{}
+		for _, btcTx := range btcBlock.Txs[1:] {
+			for _, input := range btcTx.Inputs {
+				coinIdentifier := bitcoin.CoinIdentifier(input.TxHash, input.Vout)
+				coin, owner, err := i.coinStorage.GetCoin(
+					ctx,
+					&types.CoinIdentifier{Identifier: coinIdentifier},
+				)
+				assert.NoError(t, err)
+				coins[coinIdentifier] = &types.AccountCoin{Account: owner, Coin: coin}
+			}
+		}
+
+		block := parse(ctx, btcBlock, coins)
+		assert.NoError(t, i.blockStorage.SeeBlock(ctx, block))
+		assert.NoError(t, i.BlockAdded(ctx, block))
+		btcBlocks = append(btcBlocks, btcBlock)
+	}
+
+	upgrades := []*bitcoin.Upgrade{
+		{
+			Name:   "v50",
+			Height: 3,
+			AddressEra: &bitcoin.AddressEra{
+				Name:             "v50",
+				PubKeyHashAddrID: 0x00,
+				ScriptHashAddrID: 0x05,
+			},
+		},
+	}
+	upgraded := "1111111111111111111114oLvT2"
+
+	// Only blocks an upgrade is active
+	// in are fetched from the node.
+	mockClient.On("NetworkStatus", mock.Anything).Return(&types.NetworkStatusResponse{}, nil)
+	for _, btcBlock := range btcBlocks[3:] {
+		hash := btcBlock.Hash
+		mockClient.On(
+			"GetRawBlock",
+			mock.Anything,
+			&types.PartialBlockIdentifier{Hash: &hash},
+		).Return(btcBlock, []string{}, nil)
+	}
+
+	var buf bytes.Buffer
+	simulation, err := i.SimulateUpgrades(ctx, &buf, bitcoin.MainnetAddressEras, upgrades, 3)
+	assert.NoError(t, err)
+	assert.Equal(t, &UpgradeSimulation{
+		StartBlock:          &types.BlockIdentifier{Index: 2, Hash: getBlockHash(2)},
+		EndBlock:            &types.BlockIdentifier{Index: 4, Hash: getBlockHash(4)},
+		Blocks:              3,
+		Transactions:        4,
+		ChangedBlocks:       2,
+		ChangedTransactions: 3,
+		ChangedOperations:   3,
+	}, simulation)
+
+	// The base58 outputs of the blocks the upgrade is
+	// active in, and the input spending a coin created
+	// once it is active, are parsed with the upgraded
+	// address.
+	expected := []struct {
+		index       int64
+		transaction string
+		operation   int64
+	}{
+		{index: 3, transaction: "tx 3", operation: 1},
+		{index: 4, transaction: "tx 4", operation: 1},
+		{index: 4, transaction: "spend", operation: 1},
+	}
+	decoder := json.NewDecoder(&buf)
+	for _, test := range expected {
+		var change UpgradeChange
+		assert.NoError(t, decoder.Decode(&change))
+		assert.Equal(t, "v50", change.Upgrade)
+		assert.Equal(t, getBlockHash(test.index), change.BlockIdentifier.Hash)
+		assert.Equal(t, test.transaction, change.TransactionIdentifier.Hash)
+		assert.Equal(t, test.operation, change.OperationIdentifier.Index)
+		assert.Equal(t, accounts[0], change.Operation.Account.Address)
+		assert.Equal(t, upgraded, change.UpgradedOperation.Account.Address)
+
+		// Only the account (and the addresses in
+		// the metadata of outputs) changes.
+		change.UpgradedOperation.Account = change.Operation.Account
+		change.UpgradedOperation.Metadata = change.Operation.Metadata
+		assert.Equal(t, change.Operation, change.UpgradedOperation)
+	}
+	assert.False(t, decoder.More())
+
+	// Replaying more blocks than are stored
+	// starts at genesis.
+	simulation, err = i.SimulateUpgrades(ctx, &bytes.Buffer{}, bitcoin.MainnetAddressEras, upgrades, 10)
+	assert.NoError(t, err)
+	assert.Equal(t, int64(5), simulation.Blocks)
+	assert.Equal(t, int64(3), simulation.ChangedOperations)
+	mockClient.AssertExpectations(t)
+}
@@ -16,41 +16,51 @@ package main
 
 import (
 	"context"
+	"crypto/ed25519"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
 	"fmt"
+	"io/ioutil"
 	"log"
+	"net"
 	"net/http"
 	"os"
 	"os/signal"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync/atomic"
 	"syscall"
 	"time"
 
 	"github.com/MNtank/rosetta-bitcoin/bitcoin"
+	apiClient "github.com/MNtank/rosetta-bitcoin/client"
 	"github.com/MNtank/rosetta-bitcoin/configuration"
 	"github.com/MNtank/rosetta-bitcoin/indexer"
 	"github.com/MNtank/rosetta-bitcoin/services"
 	"github.com/MNtank/rosetta-bitcoin/utils"
 
+	"github.com/btcsuite/btcd/txscript"
 	"github.com/coinbase/rosetta-sdk-go/asserter"
-	"github.com/coinbase/rosetta-sdk-go/server"
 	"github.com/coinbase/rosetta-sdk-go/types"
 	"github.com/grpc-ecosystem/go-grpc-middleware/logging/zap/ctxzap"
 	"go.uber.org/zap"
+	"golang.org/x/net/http2"
+	"golang.org/x/net/http2/h2c"
+	"golang.org/x/net/netutil"
 	"golang.org/x/sync/errgroup"
 )
 
 const (
-	// readTimeout is the maximum duration for reading the entire
-	// request, including the body.
-	readTimeout = 5 * time.Second
-
-	// writeTimeout is the maximum duration before timing out
-	// writes of the response. It is reset whenever a new
-	// request's header is read.
-	writeTimeout = 15 * time.Second
-
-	// idleTimeout is the maximum amount of time to wait for the
-	// next request when keep-alives are enabled.
-	idleTimeout = 30 * time.Second
+	// checkpointKeyPermissions are the permissions
+	// of a generated checkpoint signing key.
+	checkpointKeyPermissions = 0600
+
+	// compareTimeout is the timeout of each request
+	// made to the instance being compared.
+	compareTimeout = 30 * time.Second
 )
 
 var (
@@ -74,21 +84,258 @@ func handleSignals(ctx context.Context, listeners []context.CancelFunc) {
 	}()
 }
 
-func startOnlineDependencies(
+// unixListener is a net.Listener on a Unix domain
+// socket that was moved to path after it was created,
+// so it removes path (instead of the path it was
+// created at) when closed.
+type unixListener struct {
+	net.Listener
+
+	path string
+}
+
+// Close closes the listener and removes its socket.
+func (l *unixListener) Close() error {
+	if err := l.Listener.Close(); err != nil {
+		return err
+	}
+
+	if err := os.Remove(l.path); err != nil && !errors.Is(err, os.ErrNotExist) {
+		return fmt.Errorf("%w: unable to remove socket %s", err, l.path)
+	}
+
+	return nil
+}
+
+// listenUnix creates a net.Listener on a Unix domain
+// socket. Access to the socket is controlled using
+// file permissions, so we set them explicitly (instead
+// of relying on the process umask).
+func listenUnix(path string, permissions os.FileMode) (net.Listener, error) {
+	// Replace any stale socket left behind by a
+	// previous run that did not shut down cleanly
+	// (but never a socket that is still accepting
+	// connections or any other file at path).
+	info, err := os.Lstat(path)
+	switch {
+	case err == nil && info.Mode()&os.ModeSocket == 0:
+		return nil, fmt.Errorf("%s exists and is not a socket", path)
+	case err == nil:
+		conn, err := net.Dial("unix", path)
+		if err == nil {
+			_ = conn.Close()
+			return nil, fmt.Errorf("socket %s is already in use", path)
+		}
+	case !errors.Is(err, os.ErrNotExist):
+		return nil, fmt.Errorf("%w: unable to stat socket %s", err, path)
+	}
+
+	// The socket is created in a private directory (only
+	// accessible by the owner) and moved to path once its
+	// permissions are set, so it is never accessible with
+	// the permissions allowed by the umask.
+	dir, err := ioutil.TempDir(filepath.Dir(path), ".socket-")
+	if err != nil {
+		return nil, fmt.Errorf("%w: unable to create socket directory", err)
+	}
+	defer os.RemoveAll(dir)
+
+	privatePath := filepath.Join(dir, filepath.Base(path))
+	listener, err := net.Listen("unix", privatePath)
+	if err != nil {
+		return nil, fmt.Errorf("%w: unable to listen on socket %s", err, path)
+	}
+	listener.(*net.UnixListener).SetUnlinkOnClose(false)
+
+	if err := os.Chmod(privatePath, permissions); err != nil {
+		_ = listener.Close()
+		return nil, fmt.Errorf("%w: unable to set permissions on socket %s", err, path)
+	}
+
+	if err := os.Rename(privatePath, path); err != nil {
+		_ = listener.Close()
+		return nil, fmt.Errorf("%w: unable to move socket to %s", err, path)
+	}
+
+	return &unixListener{Listener: listener, path: path}, nil
+}
+
+// limitListener caps the number of simultaneous
+// connections accepted by a net.Listener (if
+// maxConnections is positive).
+func limitListener(listener net.Listener, maxConnections int) net.Listener {
+	if maxConnections <= 0 {
+		return listener
+	}
+
+	return netutil.LimitListener(listener, maxConnections)
+}
+
+// newServer creates the *http.Server that serves
+// the Rosetta API.
+func newServer(cfg *configuration.Configuration, handler http.Handler) *http.Server {
+	// HTTP/2 over cleartext (h2c) allows clients to multiplex
+	// many requests over a handful of connections instead of
+	// opening a new connection for each in-flight request.
+	if cfg.HTTP2 {
+		handler = h2c.NewHandler(handler, &http2.Server{
+			MaxConcurrentStreams: cfg.MaxConcurrentStreams,
+			IdleTimeout:          cfg.IdleTimeout,
+		})
+	}
+
+	return &http.Server{
+		Addr:           fmt.Sprintf(":%d", cfg.Port),
+		Handler:        handler,
+		ReadTimeout:    cfg.ReadTimeout,
+		WriteTimeout:   cfg.WriteTimeout,
+		IdleTimeout:    cfg.IdleTimeout,
+		MaxHeaderBytes: cfg.MaxHeaderBytes,
+	}
+}
+
+// swapHandler is an http.Handler that can be replaced
+// while serving (i.e. when a standby instance becomes
+// the leader).
+type swapHandler struct {
+	handler atomic.Value
+}
+
+// handlerBox ensures atomic.Value always stores
+// the same concrete type.
+type handlerBox struct {
+	http.Handler
+}
+
+// Store replaces the http.Handler used to serve requests.
+func (s *swapHandler) Store(handler http.Handler) {
+	s.handler.Store(handlerBox{handler})
+}
+
+// ServeHTTP serves a request with the most recently
+// stored http.Handler.
+func (s *swapHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	s.handler.Load().(handlerBox).ServeHTTP(w, r)
+}
+
+// network is a network served by the process
+// (with its client and indexer if online).
+type network struct {
+	cfg    *configuration.Configuration
+	client *bitcoin.Client
+	i      *indexer.Indexer
+}
+
+// newNetworkRouter creates an http.Handler that serves each
+// of networks (the first is the primary network). If standby,
+// the networks are served as if running offline.
+func newNetworkRouter(
+	networks []*network,
+	asserter *asserter.Asserter,
+	standby bool,
+) http.Handler {
+	routers := make([]http.Handler, len(networks))
+	for j, n := range networks {
+		cfg := n.cfg
+		if standby {
+			standbyCfg := *n.cfg
+			standbyCfg.Mode = configuration.Offline
+			cfg = &standbyCfg
+		}
+
+		routers[j] = services.NewBlockchainRouter(cfg, n.client, n.i, asserter)
+	}
+
+	if len(routers) == 1 {
+		return routers[0]
+	}
+
+	router := services.NewNetworkRouter(
+		routers[0],
+		int64(networks[0].cfg.MaxRequestBytes),
+	)
+	for j, n := range networks[1:] {
+		router.Add(n.cfg.Network, routers[j+1])
+	}
+
+	return router
+}
+
+// startNetworks starts the online dependencies
+// of each of networks.
+func startNetworks(
 	ctx context.Context,
 	cancel context.CancelFunc,
-	cfg *configuration.Configuration,
+	networks []*network,
 	g *errgroup.Group,
-) (*bitcoin.Client, *indexer.Indexer, error) {
+) error {
+	for _, n := range networks {
+		client, i, err := startOnlineDependencies(ctx, cancel, n.cfg, g)
+		if err != nil {
+			return fmt.Errorf("%w: unable to start %s", err, n.cfg.Network.Network)
+		}
+
+		n.client = client
+		n.i = i
+	}
+
+	return nil
+}
+
+// newOnlineClient returns the *bitcoin.Client
+// of the node of the network of cfg.
+func newOnlineClient(cfg *configuration.Configuration) *bitcoin.Client {
 	client := bitcoin.NewClient(
 		bitcoin.LocalhostURL(cfg.RPCPort),
 		cfg.GenesisBlockIdentifier,
+		cfg.GenesisAllocations,
 		cfg.Currency,
 	)
+	client.UseParams(cfg.Params)
+	client.UseNetwork(cfg.Network)
+	client.UseLimits(&bitcoin.Limits{
+		MaxBlockSize:         cfg.MaxBlockSize,
+		MaxBlockTransactions: cfg.MaxBlockTransactions,
+		MaxScriptSize:        cfg.MaxScriptSize,
+	})
+
+	if len(cfg.ArchiveNodes) > 0 {
+		client.UseArchiveNodes(cfg.ArchiveNodes)
+	}
 
+	return client
+}
+
+// startBitcoind starts the node of the
+// network of cfg (until ctx is done).
+func startBitcoind(ctx context.Context, cfg *configuration.Configuration, g *errgroup.Group) {
 	g.Go(func() error {
-		return bitcoin.StartBitcoind(ctx, cfg.ConfigPath, g)
+		args := []string{}
+		if len(cfg.SignetChallenge) > 0 {
+			args = append(args, fmt.Sprintf("-signetchallenge=%x", cfg.SignetChallenge))
+		}
+
+		return bitcoin.StartBitcoind(ctx, cfg.ConfigPath, g, args...)
 	})
+}
+
+func startOnlineDependencies(
+	ctx context.Context,
+	cancel context.CancelFunc,
+	cfg *configuration.Configuration,
+	g *errgroup.Group,
+) (*bitcoin.Client, *indexer.Indexer, error) {
+	client := newOnlineClient(cfg)
+	startBitcoind(ctx, cfg, g)
+
+	// Without DNS seeds, there are no
+	// seeded nodes to compare peers to.
+	if len(cfg.Params.DNSSeeds) > 0 {
+		seeder := bitcoin.NewSeeder(cfg.Params)
+		g.Go(func() error {
+			return seeder.MonitorPeers(ctx, client, bitcoin.PeerCheckInterval)
+		})
+	}
 
 	i, err := indexer.Initialize(
 		ctx,
@@ -100,13 +347,730 @@ func startOnlineDependencies(
 		return nil, nil, fmt.Errorf("%w: unable to initialize indexer", err)
 	}
 
+	// Cached responses are invalidated as soon
+	// as a block is added or removed.
+	i.SubscribeBlockEvents(services.InvalidateResponseCaches(cfg.Network))
+
+	if len(cfg.AdditionalCheckpoints) > 0 {
+		checkpoints, err := indexer.FetchCheckpoints(
+			ctx,
+			cfg.AdditionalCheckpoints,
+			cfg.Network,
+			cfg.CheckpointPublicKey,
+		)
+		if err != nil {
+			return nil, nil, err
+		}
+
+		if err := i.LoadCheckpoints(ctx, checkpoints); err != nil {
+			return nil, nil, fmt.Errorf("%w: unable to load checkpoints", err)
+		}
+	}
+
 	g.Go(func() error {
 		return i.Sync(ctx)
 	})
 
+	if cfg.SnapshotInterval > 0 {
+		g.Go(func() error {
+			return i.MonitorSnapshots(ctx, cfg.SnapshotInterval)
+		})
+	}
+
+	g.Go(func() error {
+		return i.MonitorConstructionFlows(ctx)
+	})
+
+	if cfg.ReconciliationRate > 0 {
+		g.Go(func() error {
+			return i.MonitorReconciliation(
+				ctx,
+				cfg.ReconciliationRate,
+				cfg.ReconciliationBatchSize,
+				cfg.ReconciliationConcurrency,
+			)
+		})
+	}
+
+	if len(cfg.CheckpointPublishPath) > 0 {
+		g.Go(func() error {
+			return i.PublishCheckpoints(
+				ctx,
+				cfg.CheckpointPublishPath,
+				cfg.CheckpointSigningKey,
+				cfg.CheckpointDepth,
+				cfg.CheckpointInterval,
+			)
+		})
+	}
+
+	if len(cfg.CheckpointFeed) > 0 {
+		g.Go(func() error {
+			return i.FollowCheckpoints(
+				ctx,
+				cfg.CheckpointFeed,
+				cfg.CheckpointPublicKey,
+				cfg.CheckpointInterval,
+			)
+		})
+	}
+
 	return client, i, nil
 }
 
+// exportEvents writes the events log stored by the indexer
+// to path. The indexer database must not be in use by
+// another process.
+func exportEvents(ctx context.Context, path string) error {
+	logger := utils.ExtractLogger(ctx, "main")
+	cfg, err := configuration.LoadConfiguration(configuration.DataDirectory)
+	if err != nil {
+		return fmt.Errorf("%w: unable to load configuration", err)
+	}
+
+	if cfg.Mode != configuration.Online {
+		return errors.New("events log is only stored in online mode")
+	}
+
+	i, err := indexer.Initialize(ctx, nil, cfg, nil)
+	if err != nil {
+		return fmt.Errorf("%w: unable to initialize indexer", err)
+	}
+	defer i.CloseDatabase(ctx)
+
+	f, err := os.Create(path) // #nosec G304
+	if err != nil {
+		return fmt.Errorf("%w: unable to create %s", err, path)
+	}
+	defer f.Close()
+
+	exported, err := i.ExportEvents(ctx, f)
+	if err != nil {
+		return err
+	}
+
+	logger.Infow("exported events", "path", path, "events", exported)
+	return nil
+}
+
+// verifyEvents checks the hash chain of an events
+// log previously written by exportEvents.
+func verifyEvents(ctx context.Context, path string) error {
+	logger := utils.ExtractLogger(ctx, "main")
+	f, err := os.Open(path) // #nosec G304
+	if err != nil {
+		return fmt.Errorf("%w: unable to open %s", err, path)
+	}
+	defer f.Close()
+
+	events, head, err := indexer.VerifyEventLog(f)
+	if err != nil {
+		return err
+	}
+
+	logger.Infow("verified events", "path", path, "events", events, "head", head)
+	return nil
+}
+
+// reprocessDeadLetters rewinds the indexer to the lowest
+// block containing an unparseable transaction, so that
+// the dead-letter queue is re-processed on the next start.
+func reprocessDeadLetters(ctx context.Context) error {
+	logger := utils.ExtractLogger(ctx, "main")
+	cfg, err := configuration.LoadConfiguration(configuration.DataDirectory)
+	if err != nil {
+		return fmt.Errorf("%w: unable to load configuration", err)
+	}
+
+	if cfg.Mode != configuration.Online {
+		return errors.New("dead-letter queue is only stored in online mode")
+	}
+
+	i, err := indexer.Initialize(ctx, nil, cfg, nil)
+	if err != nil {
+		return fmt.Errorf("%w: unable to initialize indexer", err)
+	}
+	defer i.CloseDatabase(ctx)
+
+	index, unrecoverable, err := i.ReprocessDeadLetters(ctx)
+	if err != nil {
+		return err
+	}
+
+	for _, letter := range unrecoverable {
+		logger.Warnw(
+			"skipping dead letter in pruned block (resync to reprocess)",
+			"block", letter.BlockIdentifier.Index,
+			"transaction", letter.TransactionIdentifier.Hash,
+		)
+	}
+
+	if index < 0 {
+		if len(unrecoverable) == 0 {
+			logger.Infow("dead-letter queue is empty")
+		}
+
+		return nil
+	}
+
+	logger.Infow("blocks will be re-processed on next start", "index", index)
+	return nil
+}
+
+// approveReorg approves processing the reorg sync is
+// paused on (deeper than REORG_DEPTH_LIMIT), so that it
+// is processed on the next start.
+func approveReorg(ctx context.Context) error {
+	logger := utils.ExtractLogger(ctx, "main")
+	cfg, err := configuration.LoadConfiguration(configuration.DataDirectory)
+	if err != nil {
+		return fmt.Errorf("%w: unable to load configuration", err)
+	}
+
+	if cfg.Mode != configuration.Online {
+		return errors.New("reorgs are only processed in online mode")
+	}
+
+	i, err := indexer.Initialize(ctx, nil, cfg, nil)
+	if err != nil {
+		return fmt.Errorf("%w: unable to initialize indexer", err)
+	}
+	defer i.CloseDatabase(ctx)
+
+	pause, err := i.ApproveReorg(ctx)
+	if err != nil {
+		return err
+	}
+
+	logger.Infow(
+		"reorg will be processed on next start",
+		"head", pause.Head,
+		"depth", pause.Depth,
+	)
+	return nil
+}
+
+// forgetAccount purges the indexed history of address
+// (see Indexer.ForgetAccount). The address must be
+// repeated as confirmation to prevent accidental use.
+func forgetAccount(ctx context.Context, address string, confirmation string) error {
+	logger := utils.ExtractLogger(ctx, "main")
+	if address != confirmation {
+		return fmt.Errorf("confirmation %s does not match address %s", confirmation, address)
+	}
+
+	cfg, err := configuration.LoadConfiguration(configuration.DataDirectory)
+	if err != nil {
+		return fmt.Errorf("%w: unable to load configuration", err)
+	}
+
+	if cfg.Mode != configuration.Online {
+		return errors.New("accounts are only stored in online mode")
+	}
+
+	i, err := indexer.Initialize(ctx, nil, cfg, nil)
+	if err != nil {
+		return fmt.Errorf("%w: unable to initialize indexer", err)
+	}
+	defer i.CloseDatabase(ctx)
+
+	tombstone, err := i.ForgetAccount(
+		ctx,
+		&types.AccountIdentifier{Address: address},
+		cfg.Currency,
+	)
+	if err != nil {
+		return err
+	}
+
+	logger.Infow("forgot account", "address", tombstone.Address, "head", tombstone.Head)
+	return nil
+}
+
+// simulateUpgrades replays the last blocks stored blocks
+// with the upgrades table at upgradesPath (fetching them
+// from the node, which it starts) and writes the operations
+// that would be parsed differently to path. The indexer
+// database must not be in use by another process.
+func simulateUpgrades(ctx context.Context, upgradesPath string, blocks string, path string) error {
+	logger := utils.ExtractLogger(ctx, "main")
+	cfg, err := configuration.LoadConfiguration(configuration.DataDirectory)
+	if err != nil {
+		return fmt.Errorf("%w: unable to load configuration", err)
+	}
+
+	if cfg.Mode != configuration.Online {
+		return errors.New("blocks are only stored in online mode")
+	}
+
+	count, err := strconv.ParseInt(blocks, 10, 64)
+	if err != nil || count <= 0 {
+		return fmt.Errorf("%s is not a positive number of blocks", blocks)
+	}
+
+	upgrades, err := bitcoin.LoadUpgrades(upgradesPath)
+	if err != nil {
+		return err
+	}
+
+	f, err := os.Create(path) // #nosec G304
+	if err != nil {
+		return fmt.Errorf("%w: unable to create %s", err, path)
+	}
+	defer f.Close()
+
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	g, ctx := errgroup.WithContext(ctx)
+	startBitcoind(ctx, cfg, g)
+
+	i, err := indexer.Initialize(ctx, cancel, cfg, newOnlineClient(cfg))
+	if err != nil {
+		cancel()
+		_ = g.Wait()
+		return fmt.Errorf("%w: unable to initialize indexer", err)
+	}
+
+	var simulation *indexer.UpgradeSimulation
+	var simulateErr error
+	g.Go(func() error {
+		// Stop the node once the simulation is over.
+		defer cancel()
+
+		simulation, simulateErr = i.SimulateUpgrades(ctx, f, cfg.AddressEras, upgrades, count)
+		return nil
+	})
+
+	nodeErr := g.Wait()
+	i.CloseDatabase(ctx)
+
+	switch {
+	case simulateErr != nil && nodeErr != nil && !errors.Is(nodeErr, context.Canceled):
+		return nodeErr
+	case simulateErr != nil:
+		return simulateErr
+	}
+
+	logger.Infow(
+		"simulated upgrades",
+		"path", path,
+		"start", simulation.StartBlock,
+		"end", simulation.EndBlock,
+		"blocks", simulation.Blocks,
+		"transactions", simulation.Transactions,
+		"changed_blocks", simulation.ChangedBlocks,
+		"changed_transactions", simulation.ChangedTransactions,
+		"changed_operations", simulation.ChangedOperations,
+	)
+	return nil
+}
+
+// drillReorg starts the node and the indexer (as the server
+// does) and runs a reorg drill of depth blocks, paying the
+// blocks that replace them to address (see
+// Indexer.RunReorgDrill). It is only supported against nodes
+// in regression test mode (never on mainnet or testnet) and
+// the indexer database must not be in use by another process.
+func drillReorg(ctx context.Context, depth string, address string) error {
+	logger := utils.ExtractLogger(ctx, "main")
+	cfg, err := configuration.LoadConfiguration(configuration.DataDirectory)
+	if err != nil {
+		return fmt.Errorf("%w: unable to load configuration", err)
+	}
+
+	if cfg.Mode != configuration.Online {
+		return errors.New("reorgs are only drilled in online mode")
+	}
+
+	blocks, err := strconv.ParseInt(depth, 10, 64)
+	if err != nil || blocks <= 0 {
+		return fmt.Errorf("%s is not a positive reorg depth", depth)
+	}
+
+	if _, err := bitcoin.DecodeAddress(address, cfg.Params); err != nil {
+		return fmt.Errorf("%w: unable to decode address %s", err, address)
+	}
+
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	g, ctx := errgroup.WithContext(ctx)
+	client, i, err := startOnlineDependencies(ctx, cancel, cfg, g)
+	if err != nil {
+		return fmt.Errorf("%w: unable to start online dependencies", err)
+	}
+
+	api, err := apiClient.New(cfg, client, i)
+	if err != nil {
+		cancel()
+		_ = g.Wait()
+		i.CloseDatabase(ctx)
+		return err
+	}
+
+	var drill *indexer.ReorgDrill
+	var drillErr error
+	g.Go(func() error {
+		// Stop syncing once the drill is over.
+		defer cancel()
+
+		drill, drillErr = i.RunReorgDrill(ctx, client, api, blocks, address)
+		return nil
+	})
+
+	syncErr := g.Wait()
+	i.CloseDatabase(ctx)
+
+	switch {
+	case drillErr != nil && syncErr != nil && !errors.Is(syncErr, context.Canceled):
+		return syncErr
+	case drillErr != nil:
+		return drillErr
+	}
+
+	logger.Infow(
+		"reorg drill passed",
+		"orphaned_blocks", len(drill.OrphanedBlocks),
+		"new_blocks", len(drill.NewBlocks),
+		"events", drill.Events,
+		"balance", drill.Balance.Value,
+	)
+	return nil
+}
+
+// compareInstances diffs the blocks between start and end
+// (and the balances and coins of the accounts they touched)
+// served by this instance with those served by the instance
+// at url (see client.Compare), printing the comparison. It
+// fails if the instances diverged. The indexer database
+// must not be in use by another process.
+func compareInstances(ctx context.Context, url string, start string, end string) error {
+	logger := utils.ExtractLogger(ctx, "main")
+	cfg, err := configuration.LoadConfiguration(configuration.DataDirectory)
+	if err != nil {
+		return fmt.Errorf("%w: unable to load configuration", err)
+	}
+
+	if cfg.Mode != configuration.Online {
+		return errors.New("instances are only compared in online mode")
+	}
+
+	startIndex, err := strconv.ParseInt(start, 10, 64)
+	if err != nil {
+		return fmt.Errorf("%w: unable to parse start index %s", err, start)
+	}
+
+	endIndex, err := strconv.ParseInt(end, 10, 64)
+	if err != nil {
+		return fmt.Errorf("%w: unable to parse end index %s", err, end)
+	}
+
+	i, err := indexer.Initialize(ctx, nil, cfg, nil)
+	if err != nil {
+		return fmt.Errorf("%w: unable to initialize indexer", err)
+	}
+	defer i.CloseDatabase(ctx)
+
+	local, err := apiClient.New(cfg, nil, i)
+	if err != nil {
+		return err
+	}
+
+	comparison, err := apiClient.Compare(
+		ctx,
+		local,
+		apiClient.NewRemote(url, &http.Client{Timeout: compareTimeout}),
+		cfg.Network,
+		startIndex,
+		endIndex,
+	)
+	if err != nil {
+		return err
+	}
+
+	if err := printJSON(comparison); err != nil {
+		return err
+	}
+
+	if comparison.Divergence != nil {
+		return fmt.Errorf(
+			"instances diverged (%s) at block %d",
+			comparison.Divergence.Reason,
+			comparison.Divergence.Index,
+		)
+	}
+
+	logger.Infow(
+		"instances are consistent",
+		"blocks", comparison.Blocks,
+		"accounts", comparison.Accounts,
+		"coins_skipped", comparison.CoinsSkipped,
+	)
+	return nil
+}
+
+// exportSnapshot copies the indexer database to path with
+// a manifest signed with the key at keyPath (see
+// indexer.ExportSnapshot). The indexer database must not
+// be in use by another process.
+func exportSnapshot(ctx context.Context, path string, keyPath string) error {
+	logger := utils.ExtractLogger(ctx, "main")
+	cfg, err := configuration.LoadConfiguration(configuration.DataDirectory)
+	if err != nil {
+		return fmt.Errorf("%w: unable to load configuration", err)
+	}
+
+	if cfg.Mode != configuration.Online {
+		return errors.New("snapshots are only exported in online mode")
+	}
+
+	key, err := configuration.LoadSigningKey(keyPath)
+	if err != nil {
+		return err
+	}
+
+	manifest, err := indexer.ExportSnapshot(ctx, cfg, path, key)
+	if err != nil {
+		return err
+	}
+
+	logger.Infow(
+		"exported snapshot",
+		"path", path,
+		"head", manifest.BlockIdentifier,
+		"files", len(manifest.Files),
+	)
+	return nil
+}
+
+// importSnapshot verifies the snapshot at path with
+// publicKey and copies it to the (empty) indexer
+// database (see indexer.ImportSnapshot).
+func importSnapshot(ctx context.Context, path string, publicKeyValue string) error {
+	logger := utils.ExtractLogger(ctx, "main")
+	cfg, err := configuration.LoadConfiguration(configuration.DataDirectory)
+	if err != nil {
+		return fmt.Errorf("%w: unable to load configuration", err)
+	}
+
+	if cfg.Mode != configuration.Online {
+		return errors.New("snapshots are only imported in online mode")
+	}
+
+	publicKey, err := configuration.ParsePublicKey(publicKeyValue)
+	if err != nil {
+		return err
+	}
+
+	manifest, err := indexer.ImportSnapshot(ctx, cfg, path, publicKey)
+	if err != nil {
+		return err
+	}
+
+	logger.Infow(
+		"imported snapshot",
+		"path", path,
+		"head", manifest.BlockIdentifier,
+		"files", len(manifest.Files),
+	)
+	return nil
+}
+
+// generateCheckpointKey writes a new hex-encoded ed25519
+// seed (used to sign checkpoints) to path and logs the
+// public key that other instances use to verify them.
+func generateCheckpointKey(ctx context.Context, path string) error {
+	logger := utils.ExtractLogger(ctx, "main")
+	publicKey, privateKey, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		return fmt.Errorf("%w: unable to generate checkpoint key", err)
+	}
+
+	f, err := os.OpenFile( // #nosec G304
+		path,
+		os.O_CREATE|os.O_EXCL|os.O_WRONLY,
+		checkpointKeyPermissions,
+	)
+	if err != nil {
+		return fmt.Errorf("%w: unable to create %s", err, path)
+	}
+	defer f.Close()
+
+	if _, err := f.WriteString(hex.EncodeToString(privateKey.Seed())); err != nil {
+		return fmt.Errorf("%w: unable to write %s", err, path)
+	}
+
+	logger.Infow("generated checkpoint key", "path", path, "public_key", hex.EncodeToString(publicKey))
+	return nil
+}
+
+// readHex returns the hex-decoded contents of the file
+// at path (or of stdin if path is "-").
+func readHex(path string) ([]byte, error) {
+	var contents []byte
+	var err error
+	if path == "-" {
+		contents, err = ioutil.ReadAll(os.Stdin)
+	} else {
+		contents, err = ioutil.ReadFile(path) // #nosec G304
+	}
+	if err != nil {
+		return nil, fmt.Errorf("%w: unable to read %s", err, path)
+	}
+
+	decoded, err := hex.DecodeString(strings.TrimSpace(string(contents)))
+	if err != nil {
+		return nil, fmt.Errorf("%w: unable to decode hex in %s", err, path)
+	}
+
+	return decoded, nil
+}
+
+// printJSON writes v to stdout as indented JSON.
+func printJSON(v interface{}) error {
+	output, err := json.MarshalIndent(v, "", "  ")
+	if err != nil {
+		return fmt.Errorf("%w: unable to marshal output", err)
+	}
+
+	fmt.Println(string(output))
+	return nil
+}
+
+// decodeClient returns a *bitcoin.Client that only parses
+// (it never connects to the node).
+func decodeClient(cfg *configuration.Configuration) *bitcoin.Client {
+	client := bitcoin.NewClient(
+		bitcoin.LocalhostURL(cfg.RPCPort),
+		cfg.GenesisBlockIdentifier,
+		cfg.GenesisAllocations,
+		cfg.Currency,
+	)
+	client.UseParams(cfg.Params)
+
+	return client
+}
+
+// decodeTx prints the *types.Transaction of the raw
+// transaction at path. Inputs are decoded without the
+// account and amount of the outputs they spend.
+func decodeTx(path string) error {
+	cfg, err := configuration.LoadConfiguration(configuration.DataDirectory)
+	if err != nil {
+		return fmt.Errorf("%w: unable to load configuration", err)
+	}
+
+	rawTx, err := readHex(path)
+	if err != nil {
+		return err
+	}
+
+	tx, err := bitcoin.DecodeTransaction(rawTx, cfg.Params)
+	if err != nil {
+		return err
+	}
+
+	transaction, err := decodeClient(cfg).ParseTransaction(tx)
+	if err != nil {
+		return err
+	}
+
+	return printJSON(transaction)
+}
+
+// decodedAddress is the output of decode-address.
+type decodedAddress struct {
+	AccountIdentifier *types.AccountIdentifier `json:"account_identifier"`
+	ScriptPubKey      *bitcoin.ScriptPubKey    `json:"script_pub_key"`
+
+	// Eras and Type are only populated for
+	// base58 addresses.
+	Eras []string `json:"eras,omitempty"`
+	Type string   `json:"type,omitempty"`
+}
+
+// decodeAddress prints the script an address pays to
+// and the address eras it is valid in.
+func decodeAddress(address string) error {
+	cfg, err := configuration.LoadConfiguration(configuration.DataDirectory)
+	if err != nil {
+		return fmt.Errorf("%w: unable to load configuration", err)
+	}
+
+	addr, err := bitcoin.DecodeAddress(address, cfg.Params)
+	if err != nil {
+		return fmt.Errorf("%w: unable to decode address %s", err, address)
+	}
+
+	script, err := txscript.PayToAddrScript(addr)
+	if err != nil {
+		return fmt.Errorf("%w: unable to construct script of %s", err, address)
+	}
+
+	decoded := &decodedAddress{
+		AccountIdentifier: &types.AccountIdentifier{Address: address},
+		ScriptPubKey:      bitcoin.NewScriptPubKey(script, cfg.Params),
+	}
+
+	// Bech32 addresses are valid in every era.
+	if eras, addressType, err := bitcoin.AddressEras(address, cfg.AddressEras); err == nil {
+		decoded.Eras = eras
+		decoded.Type = addressType
+	}
+
+	return printJSON(decoded)
+}
+
+// runCommand runs a one-off command (instead of the
+// server) if one was provided.
+func runCommand(ctx context.Context, args []string) (bool, error) {
+	if len(args) == 0 {
+		return false, nil
+	}
+
+	switch {
+	case args[0] == "export-events" && len(args) == 2: // nolint:gomnd
+		return true, exportEvents(ctx, args[1])
+	case args[0] == "verify-events" && len(args) == 2: // nolint:gomnd
+		return true, verifyEvents(ctx, args[1])
+	case args[0] == "reprocess-dead-letters" && len(args) == 1:
+		return true, reprocessDeadLetters(ctx)
+	case args[0] == "generate-checkpoint-key" && len(args) == 2: // nolint:gomnd
+		return true, generateCheckpointKey(ctx, args[1])
+	case args[0] == "approve-reorg" && len(args) == 1:
+		return true, approveReorg(ctx)
+	case args[0] == "simulate-upgrades" && len(args) == 4: // nolint:gomnd
+		return true, simulateUpgrades(ctx, args[1], args[2], args[3])
+	case args[0] == "forget-account" && len(args) == 3: // nolint:gomnd
+		return true, forgetAccount(ctx, args[1], args[2])
+	case args[0] == "drill-reorg" && len(args) == 3: // nolint:gomnd
+		return true, drillReorg(ctx, args[1], args[2])
+	case args[0] == "compare" && len(args) == 4: // nolint:gomnd
+		return true, compareInstances(ctx, args[1], args[2], args[3])
+	case args[0] == "export-snapshot" && len(args) == 3: // nolint:gomnd
+		return true, exportSnapshot(ctx, args[1], args[2])
+	case args[0] == "import-snapshot" && len(args) == 3: // nolint:gomnd
+		return true, importSnapshot(ctx, args[1], args[2])
+	case args[0] == "decode-tx" && len(args) == 2: // nolint:gomnd
+		return true, decodeTx(args[1])
+	case args[0] == "decode-address" && len(args) == 2: // nolint:gomnd
+		return true, decodeAddress(args[1])
+	default:
+		return true, fmt.Errorf(
+			"usage: %s [export-events <path> | verify-events <path> | "+
+				"reprocess-dead-letters | generate-checkpoint-key <path> | approve-reorg | "+
+				"simulate-upgrades <upgrades> <blocks> <path> | "+
+				"forget-account <address> <address> | drill-reorg <depth> <address> | "+
+				"compare <url> <start> <end> | "+
+				"export-snapshot <path> <signing key> | "+
+				"import-snapshot <path> <public key> | decode-tx <path> | "+
+				"decode-address <address>]",
+			os.Args[0],
+		)
+	}
+}
+
 func main() {
 	loggerRaw, err := zap.NewDevelopment()
 	if err != nil {
@@ -124,6 +1088,15 @@ func main() {
 
 	logger := loggerRaw.Sugar().Named("main")
 
+	ran, err := runCommand(ctx, os.Args[1:])
+	if err != nil {
+		logger.Fatalw("command failed", "error", err)
+	}
+
+	if ran {
+		return
+	}
+
 	cfg, err := configuration.LoadConfiguration(configuration.DataDirectory)
 	if err != nil {
 		logger.Fatalw("unable to load configuration", "error", err)
@@ -137,23 +1110,21 @@ func main() {
 		return utils.MonitorMemoryUsage(ctx, -1)
 	})
 
-	var i *indexer.Indexer
-	var client *bitcoin.Client
-	if cfg.Mode == configuration.Online {
-		client, i, err = startOnlineDependencies(ctx, cancel, cfg, g)
-		if err != nil {
-			logger.Fatalw("unable to start online dependencies", "error", err)
-		}
+	networks := []*network{{cfg: cfg}}
+	networkIdentifiers := []*types.NetworkIdentifier{cfg.Network}
+	for _, additional := range cfg.AdditionalNetworks {
+		networks = append(networks, &network{cfg: additional})
+		networkIdentifiers = append(networkIdentifiers, additional.Network)
 	}
 
 	fmt.Println("incorrect asserter")
 	// The asserter automatically rejects incorrectly formatted
 	// requests.
 	asserter, err := asserter.NewServer(
-		bitcoin.OperationTypes,
+		bitcoin.OperationTypes(),
 		services.HistoricalBalanceLookup,
-		[]*types.NetworkIdentifier{cfg.Network},
-		nil,
+		networkIdentifiers,
+		services.CallMethods,
 		services.MempoolCoins,
 		"",
 	)
@@ -161,25 +1132,86 @@ func main() {
 		logger.Fatalw("unable to create new server asserter", "error", err)
 	}
 
-	router := services.NewBlockchainRouter(cfg, client, i, asserter)
-	loggedRouter := services.LoggerMiddleware(loggerRaw, router)
-	corsRouter := server.CorsMiddleware(loggedRouter)
-	server := &http.Server{
-		Addr:         fmt.Sprintf(":%d", cfg.Port),
-		Handler:      corsRouter,
-		ReadTimeout:  readTimeout,
-		WriteTimeout: writeTimeout,
-		IdleTimeout:  idleTimeout,
+	router := &swapHandler{}
+	handler, err := services.ChainMiddlewares(cfg, loggerRaw, router)
+	if err != nil {
+		logger.Fatalw("unable to create middlewares", "error", err)
 	}
 
-	g.Go(func() error {
-		logger.Infow("server listening", "port", cfg.Port)
-		return server.ListenAndServe()
-	})
+	var leaderLock *utils.LeaderLock
+	switch {
+	case cfg.Mode == configuration.Online && cfg.LeaderElection:
+		// Until this instance becomes the leader, it forwards
+		// read requests to the leader and serves all other
+		// requests as if it were running offline.
+		router.Store(services.NewStandbyRouter(
+			newNetworkRouter(networks, asserter, true),
+			func() (string, error) {
+				return utils.LeaderURL(cfg.LeaderLockPath)
+			},
+		))
+
+		g.Go(func() error {
+			var err error
+			leaderLock, err = utils.AcquireLeaderLock(
+				ctx,
+				cfg.LeaderLockPath,
+				cfg.LeaderPollInterval,
+			)
+			if err != nil {
+				return fmt.Errorf("%w: unable to acquire leader lock", err)
+			}
+
+			if err := startNetworks(ctx, cancel, networks, g); err != nil {
+				return fmt.Errorf("%w: unable to start online dependencies", err)
+			}
+
+			router.Store(newNetworkRouter(networks, asserter, false))
+			if err := leaderLock.Advertise(cfg.LeaderURL); err != nil {
+				return fmt.Errorf("%w: unable to advertise leader", err)
+			}
+
+			return nil
+		})
+	case cfg.Mode == configuration.Online:
+		if err := startNetworks(ctx, cancel, networks, g); err != nil {
+			logger.Fatalw("unable to start online dependencies", "error", err)
+		}
+
+		router.Store(newNetworkRouter(networks, asserter, false))
+	default:
+		router.Store(newNetworkRouter(networks, asserter, false))
+	}
+
+	server := newServer(cfg, handler)
+
+	if cfg.Port > 0 {
+		listener, err := net.Listen("tcp", server.Addr)
+		if err != nil {
+			logger.Fatalw("unable to create tcp listener", "error", err)
+		}
+
+		g.Go(func() error {
+			logger.Infow("server listening", "port", cfg.Port, "http2", cfg.HTTP2)
+			return server.Serve(limitListener(listener, cfg.MaxConnections))
+		})
+	}
+
+	if len(cfg.SocketPath) > 0 {
+		listener, err := listenUnix(cfg.SocketPath, cfg.SocketPermissions)
+		if err != nil {
+			logger.Fatalw("unable to create socket listener", "error", err)
+		}
+
+		g.Go(func() error {
+			logger.Infow("server listening", "socket", cfg.SocketPath, "http2", cfg.HTTP2)
+			return server.Serve(limitListener(listener, cfg.MaxConnections))
+		})
+	}
 
 	g.Go(func() error {
 		// If we don't shutdown server in errgroup, it will
-		// never stop because server.ListenAndServe doesn't
+		// never stop because server.Serve doesn't
 		// take any context.
 		<-ctx.Done()
 
@@ -188,10 +1220,20 @@ func main() {
 
 	err = g.Wait()
 
-	// We always want to attempt to close the database, regardless of the error.
+	// We always want to attempt to close the databases, regardless of the error.
 	// We also want to do this after all indexer goroutines have stopped.
-	if i != nil {
-		i.CloseDatabase(ctx)
+	for _, n := range networks {
+		if n.i != nil {
+			n.i.CloseDatabase(ctx)
+		}
+	}
+
+	// The leader lock must only be released once
+	// the database is closed.
+	if leaderLock != nil {
+		if err := leaderLock.Release(); err != nil {
+			logger.Warnw("unable to release leader lock", "error", err)
+		}
 	}
 
 	if signalReceived {
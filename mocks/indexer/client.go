@@ -72,6 +72,29 @@ func (_m *Client) NetworkStatus(_a0 context.Context) (*types.NetworkStatusRespon
 	return r0, r1
 }
 
+// NodeNetwork provides a mock function with given fields: _a0
+func (_m *Client) NodeNetwork(_a0 context.Context) (*bitcoin.NodeNetwork, error) {
+	ret := _m.Called(_a0)
+
+	var r0 *bitcoin.NodeNetwork
+	if rf, ok := ret.Get(0).(func(context.Context) *bitcoin.NodeNetwork); ok {
+		r0 = rf(_a0)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).(*bitcoin.NodeNetwork)
+		}
+	}
+
+	var r1 error
+	if rf, ok := ret.Get(1).(func(context.Context) error); ok {
+		r1 = rf(_a0)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
 // ParseBlock provides a mock function with given fields: _a0, _a1, _a2
 func (_m *Client) ParseBlock(_a0 context.Context, _a1 *bitcoin.Block, _a2 map[string]*types.AccountCoin) (*types.Block, error) {
 	ret := _m.Called(_a0, _a1, _a2)
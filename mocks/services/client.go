@@ -5,6 +5,8 @@ package services
 import (
 	context "context"
 
+	bitcoin "github.com/MNtank/rosetta-bitcoin/bitcoin"
+
 	mock "github.com/stretchr/testify/mock"
 
 	types "github.com/coinbase/rosetta-sdk-go/types"
@@ -15,6 +17,57 @@ type Client struct {
 	mock.Mock
 }
 
+// BanPeer provides a mock function with given fields: _a0, _a1, _a2
+func (_m *Client) BanPeer(_a0 context.Context, _a1 string, _a2 int64) error {
+	ret := _m.Called(_a0, _a1, _a2)
+
+	var r0 error
+	if rf, ok := ret.Get(0).(func(context.Context, string, int64) error); ok {
+		r0 = rf(_a0, _a1, _a2)
+	} else {
+		r0 = ret.Error(0)
+	}
+
+	return r0
+}
+
+// DisconnectPeer provides a mock function with given fields: _a0, _a1
+func (_m *Client) DisconnectPeer(_a0 context.Context, _a1 string) error {
+	ret := _m.Called(_a0, _a1)
+
+	var r0 error
+	if rf, ok := ret.Get(0).(func(context.Context, string) error); ok {
+		r0 = rf(_a0, _a1)
+	} else {
+		r0 = ret.Error(0)
+	}
+
+	return r0
+}
+
+// GetBlockchainInfo provides a mock function with given fields: _a0
+func (_m *Client) GetBlockchainInfo(_a0 context.Context) (*bitcoin.BlockchainInfo, error) {
+	ret := _m.Called(_a0)
+
+	var r0 *bitcoin.BlockchainInfo
+	if rf, ok := ret.Get(0).(func(context.Context) *bitcoin.BlockchainInfo); ok {
+		r0 = rf(_a0)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).(*bitcoin.BlockchainInfo)
+		}
+	}
+
+	var r1 error
+	if rf, ok := ret.Get(1).(func(context.Context) error); ok {
+		r1 = rf(_a0)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
 // GetPeers provides a mock function with given fields: _a0
 func (_m *Client) GetPeers(_a0 context.Context) ([]*types.Peer, error) {
 	ret := _m.Called(_a0)
@@ -38,6 +91,75 @@ func (_m *Client) GetPeers(_a0 context.Context) ([]*types.Peer, error) {
 	return r0, r1
 }
 
+// ListBanned provides a mock function with given fields: _a0
+func (_m *Client) ListBanned(_a0 context.Context) ([]*bitcoin.BannedSubnet, error) {
+	ret := _m.Called(_a0)
+
+	var r0 []*bitcoin.BannedSubnet
+	if rf, ok := ret.Get(0).(func(context.Context) []*bitcoin.BannedSubnet); ok {
+		r0 = rf(_a0)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).([]*bitcoin.BannedSubnet)
+		}
+	}
+
+	var r1 error
+	if rf, ok := ret.Get(1).(func(context.Context) error); ok {
+		r1 = rf(_a0)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// MempoolEntry provides a mock function with given fields: _a0, _a1
+func (_m *Client) MempoolEntry(_a0 context.Context, _a1 string) (*bitcoin.MempoolEntry, error) {
+	ret := _m.Called(_a0, _a1)
+
+	var r0 *bitcoin.MempoolEntry
+	if rf, ok := ret.Get(0).(func(context.Context, string) *bitcoin.MempoolEntry); ok {
+		r0 = rf(_a0, _a1)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).(*bitcoin.MempoolEntry)
+		}
+	}
+
+	var r1 error
+	if rf, ok := ret.Get(1).(func(context.Context, string) error); ok {
+		r1 = rf(_a0, _a1)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// MempoolTransaction provides a mock function with given fields: _a0, _a1
+func (_m *Client) MempoolTransaction(_a0 context.Context, _a1 string) (*types.Transaction, error) {
+	ret := _m.Called(_a0, _a1)
+
+	var r0 *types.Transaction
+	if rf, ok := ret.Get(0).(func(context.Context, string) *types.Transaction); ok {
+		r0 = rf(_a0, _a1)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).(*types.Transaction)
+		}
+	}
+
+	var r1 error
+	if rf, ok := ret.Get(1).(func(context.Context, string) error); ok {
+		r1 = rf(_a0, _a1)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
 // RawMempool provides a mock function with given fields: _a0
 func (_m *Client) RawMempool(_a0 context.Context) ([]string, error) {
 	ret := _m.Called(_a0)
@@ -102,3 +224,17 @@ func (_m *Client) SuggestedFeeRate(_a0 context.Context, _a1 int64) (float64, err
 
 	return r0, r1
 }
+
+// UnbanPeer provides a mock function with given fields: _a0, _a1
+func (_m *Client) UnbanPeer(_a0 context.Context, _a1 string) error {
+	ret := _m.Called(_a0, _a1)
+
+	var r0 error
+	if rf, ok := ret.Get(0).(func(context.Context, string) error); ok {
+		r0 = rf(_a0, _a1)
+	} else {
+		r0 = ret.Error(0)
+	}
+
+	return r0
+}
@@ -9,6 +9,8 @@ import (
 
 	mock "github.com/stretchr/testify/mock"
 
+	utils "github.com/MNtank/rosetta-bitcoin/utils"
+
 	types "github.com/coinbase/rosetta-sdk-go/types"
 )
 
@@ -17,6 +19,27 @@ type Indexer struct {
 	mock.Mock
 }
 
+// CreateConstructionFlow provides a mock function with given fields: _a0
+func (_m *Indexer) CreateConstructionFlow(_a0 context.Context) (string, error) {
+	ret := _m.Called(_a0)
+
+	var r0 string
+	if rf, ok := ret.Get(0).(func(context.Context) string); ok {
+		r0 = rf(_a0)
+	} else {
+		r0 = ret.Get(0).(string)
+	}
+
+	var r1 error
+	if rf, ok := ret.Get(1).(func(context.Context) error); ok {
+		r1 = rf(_a0)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
 // GetBalance provides a mock function with given fields: _a0, _a1, _a2, _a3
 func (_m *Indexer) GetBalance(_a0 context.Context, _a1 *types.AccountIdentifier, _a2 *types.Currency, _a3 *types.PartialBlockIdentifier) (*types.Amount, *types.BlockIdentifier, error) {
 	ret := _m.Called(_a0, _a1, _a2, _a3)
@@ -49,6 +72,36 @@ func (_m *Indexer) GetBalance(_a0 context.Context, _a1 *types.AccountIdentifier,
 	return r0, r1, r2
 }
 
+// GetBlockEvents provides a mock function with given fields: _a0, _a1, _a2
+func (_m *Indexer) GetBlockEvents(_a0 context.Context, _a1 int64, _a2 int64) ([]*types.BlockEvent, int64, error) {
+	ret := _m.Called(_a0, _a1, _a2)
+
+	var r0 []*types.BlockEvent
+	if rf, ok := ret.Get(0).(func(context.Context, int64, int64) []*types.BlockEvent); ok {
+		r0 = rf(_a0, _a1, _a2)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).([]*types.BlockEvent)
+		}
+	}
+
+	var r1 int64
+	if rf, ok := ret.Get(1).(func(context.Context, int64, int64) int64); ok {
+		r1 = rf(_a0, _a1, _a2)
+	} else {
+		r1 = ret.Get(1).(int64)
+	}
+
+	var r2 error
+	if rf, ok := ret.Get(2).(func(context.Context, int64, int64) error); ok {
+		r2 = rf(_a0, _a1, _a2)
+	} else {
+		r2 = ret.Error(2)
+	}
+
+	return r0, r1, r2
+}
+
 // GetBlockLazy provides a mock function with given fields: _a0, _a1
 func (_m *Indexer) GetBlockLazy(_a0 context.Context, _a1 *types.PartialBlockIdentifier) (*types.BlockResponse, error) {
 	ret := _m.Called(_a0, _a1)
@@ -72,6 +125,29 @@ func (_m *Indexer) GetBlockLazy(_a0 context.Context, _a1 *types.PartialBlockIden
 	return r0, r1
 }
 
+// GetBlockTimeline provides a mock function with given fields: _a0, _a1
+func (_m *Indexer) GetBlockTimeline(_a0 context.Context, _a1 int64) (*utils.BlockTimeline, error) {
+	ret := _m.Called(_a0, _a1)
+
+	var r0 *utils.BlockTimeline
+	if rf, ok := ret.Get(0).(func(context.Context, int64) *utils.BlockTimeline); ok {
+		r0 = rf(_a0, _a1)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).(*utils.BlockTimeline)
+		}
+	}
+
+	var r1 error
+	if rf, ok := ret.Get(1).(func(context.Context, int64) error); ok {
+		r1 = rf(_a0, _a1)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
 // GetBlockTransaction provides a mock function with given fields: _a0, _a1, _a2
 func (_m *Indexer) GetBlockTransaction(_a0 context.Context, _a1 *types.BlockIdentifier, _a2 *types.TransactionIdentifier) (*types.Transaction, error) {
 	ret := _m.Called(_a0, _a1, _a2)
@@ -95,6 +171,52 @@ func (_m *Indexer) GetBlockTransaction(_a0 context.Context, _a1 *types.BlockIden
 	return r0, r1
 }
 
+// GetCoinBlocks provides a mock function with given fields: _a0, _a1
+func (_m *Indexer) GetCoinBlocks(_a0 context.Context, _a1 []*types.Coin) ([]*types.BlockIdentifier, error) {
+	ret := _m.Called(_a0, _a1)
+
+	var r0 []*types.BlockIdentifier
+	if rf, ok := ret.Get(0).(func(context.Context, []*types.Coin) []*types.BlockIdentifier); ok {
+		r0 = rf(_a0, _a1)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).([]*types.BlockIdentifier)
+		}
+	}
+
+	var r1 error
+	if rf, ok := ret.Get(1).(func(context.Context, []*types.Coin) error); ok {
+		r1 = rf(_a0, _a1)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// GetCoinChurn provides a mock function with given fields: _a0, _a1, _a2, _a3
+func (_m *Indexer) GetCoinChurn(_a0 context.Context, _a1 *types.AccountIdentifier, _a2 int64, _a3 int64) (*utils.CoinChurn, error) {
+	ret := _m.Called(_a0, _a1, _a2, _a3)
+
+	var r0 *utils.CoinChurn
+	if rf, ok := ret.Get(0).(func(context.Context, *types.AccountIdentifier, int64, int64) *utils.CoinChurn); ok {
+		r0 = rf(_a0, _a1, _a2, _a3)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).(*utils.CoinChurn)
+		}
+	}
+
+	var r1 error
+	if rf, ok := ret.Get(1).(func(context.Context, *types.AccountIdentifier, int64, int64) error); ok {
+		r1 = rf(_a0, _a1, _a2, _a3)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
 // GetCoins provides a mock function with given fields: _a0, _a1
 func (_m *Indexer) GetCoins(_a0 context.Context, _a1 *types.AccountIdentifier) ([]*types.Coin, *types.BlockIdentifier, error) {
 	ret := _m.Called(_a0, _a1)
@@ -127,6 +249,52 @@ func (_m *Indexer) GetCoins(_a0 context.Context, _a1 *types.AccountIdentifier) (
 	return r0, r1, r2
 }
 
+// GetConstructionFlow provides a mock function with given fields: _a0, _a1
+func (_m *Indexer) GetConstructionFlow(_a0 context.Context, _a1 string) (*utils.ConstructionFlow, error) {
+	ret := _m.Called(_a0, _a1)
+
+	var r0 *utils.ConstructionFlow
+	if rf, ok := ret.Get(0).(func(context.Context, string) *utils.ConstructionFlow); ok {
+		r0 = rf(_a0, _a1)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).(*utils.ConstructionFlow)
+		}
+	}
+
+	var r1 error
+	if rf, ok := ret.Get(1).(func(context.Context, string) error); ok {
+		r1 = rf(_a0, _a1)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// GetImmatureBalance provides a mock function with given fields: _a0, _a1, _a2, _a3
+func (_m *Indexer) GetImmatureBalance(_a0 context.Context, _a1 *types.AccountIdentifier, _a2 *types.Currency, _a3 *types.BlockIdentifier) (*types.Amount, error) {
+	ret := _m.Called(_a0, _a1, _a2, _a3)
+
+	var r0 *types.Amount
+	if rf, ok := ret.Get(0).(func(context.Context, *types.AccountIdentifier, *types.Currency, *types.BlockIdentifier) *types.Amount); ok {
+		r0 = rf(_a0, _a1, _a2, _a3)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).(*types.Amount)
+		}
+	}
+
+	var r1 error
+	if rf, ok := ret.Get(1).(func(context.Context, *types.AccountIdentifier, *types.Currency, *types.BlockIdentifier) error); ok {
+		r1 = rf(_a0, _a1, _a2, _a3)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
 // GetScriptPubKeys provides a mock function with given fields: _a0, _a1
 func (_m *Indexer) GetScriptPubKeys(_a0 context.Context, _a1 []*types.Coin) ([]*bitcoin.ScriptPubKey, error) {
 	ret := _m.Called(_a0, _a1)
@@ -149,3 +317,123 @@ func (_m *Indexer) GetScriptPubKeys(_a0 context.Context, _a1 []*types.Coin) ([]*
 
 	return r0, r1
 }
+
+// GetSnapshot provides a mock function with given fields: _a0
+func (_m *Indexer) GetSnapshot(_a0 context.Context) (*utils.Snapshot, error) {
+	ret := _m.Called(_a0)
+
+	var r0 *utils.Snapshot
+	if rf, ok := ret.Get(0).(func(context.Context) *utils.Snapshot); ok {
+		r0 = rf(_a0)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).(*utils.Snapshot)
+		}
+	}
+
+	var r1 error
+	if rf, ok := ret.Get(1).(func(context.Context) error); ok {
+		r1 = rf(_a0)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// GetStaleBlock provides a mock function with given fields: _a0, _a1
+func (_m *Indexer) GetStaleBlock(_a0 context.Context, _a1 string) (*types.Block, error) {
+	ret := _m.Called(_a0, _a1)
+
+	var r0 *types.Block
+	if rf, ok := ret.Get(0).(func(context.Context, string) *types.Block); ok {
+		r0 = rf(_a0, _a1)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).(*types.Block)
+		}
+	}
+
+	var r1 error
+	if rf, ok := ret.Get(1).(func(context.Context, string) error); ok {
+		r1 = rf(_a0, _a1)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// GetSyncRate provides a mock function with given fields: _a0
+func (_m *Indexer) GetSyncRate(_a0 context.Context) float64 {
+	ret := _m.Called(_a0)
+
+	var r0 float64
+	if rf, ok := ret.Get(0).(func(context.Context) float64); ok {
+		r0 = rf(_a0)
+	} else {
+		r0 = ret.Get(0).(float64)
+	}
+
+	return r0
+}
+
+// GetUnspentCoins provides a mock function with given fields: _a0, _a1
+func (_m *Indexer) GetUnspentCoins(_a0 context.Context, _a1 []*types.CoinIdentifier) (map[string]*types.AccountCoin, error) {
+	ret := _m.Called(_a0, _a1)
+
+	var r0 map[string]*types.AccountCoin
+	if rf, ok := ret.Get(0).(func(context.Context, []*types.CoinIdentifier) map[string]*types.AccountCoin); ok {
+		r0 = rf(_a0, _a1)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).(map[string]*types.AccountCoin)
+		}
+	}
+
+	var r1 error
+	if rf, ok := ret.Get(1).(func(context.Context, []*types.CoinIdentifier) error); ok {
+		r1 = rf(_a0, _a1)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// SearchTransactions provides a mock function with given fields: _a0, _a1
+func (_m *Indexer) SearchTransactions(_a0 context.Context, _a1 *types.SearchTransactionsRequest) (*types.SearchTransactionsResponse, error) {
+	ret := _m.Called(_a0, _a1)
+
+	var r0 *types.SearchTransactionsResponse
+	if rf, ok := ret.Get(0).(func(context.Context, *types.SearchTransactionsRequest) *types.SearchTransactionsResponse); ok {
+		r0 = rf(_a0, _a1)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).(*types.SearchTransactionsResponse)
+		}
+	}
+
+	var r1 error
+	if rf, ok := ret.Get(1).(func(context.Context, *types.SearchTransactionsRequest) error); ok {
+		r1 = rf(_a0, _a1)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// UpdateConstructionFlow provides a mock function with given fields: _a0, _a1, _a2
+func (_m *Indexer) UpdateConstructionFlow(_a0 context.Context, _a1 string, _a2 func(*utils.ConstructionFlow)) error {
+	ret := _m.Called(_a0, _a1, _a2)
+
+	var r0 error
+	if rf, ok := ret.Get(0).(func(context.Context, string, func(*utils.ConstructionFlow)) error); ok {
+		r0 = rf(_a0, _a1, _a2)
+	} else {
+		r0 = ret.Error(0)
+	}
+
+	return r0
+}
@@ -16,7 +16,9 @@ package services
 
 import (
 	"context"
+	"errors"
 
+	"github.com/MNtank/rosetta-bitcoin/bitcoin"
 	"github.com/MNtank/rosetta-bitcoin/configuration"
 
 	"github.com/coinbase/rosetta-sdk-go/server"
@@ -26,20 +28,44 @@ import (
 // AccountAPIService implements the server.AccountAPIServicer interface.
 type AccountAPIService struct {
 	config *configuration.Configuration
+	client Client
 	i      Indexer
 }
 
 // NewAccountAPIService returns a new *AccountAPIService.
 func NewAccountAPIService(
 	config *configuration.Configuration,
+	client Client,
 	i Indexer,
 ) server.AccountAPIServicer {
 	return &AccountAPIService{
 		config: config,
+		client: client,
 		i:      i,
 	}
 }
 
+// resolveAccount returns the account that owns the coins
+// of account. Coins of cold staking scripts are owned by
+// the delegated sub-account of their owner, so accounts
+// identified by the hex of a cold staking script (as
+// returned before delegated coins were attributed to
+// their owner) are resolved to it.
+func (s *AccountAPIService) resolveAccount(
+	account *types.AccountIdentifier,
+) *types.AccountIdentifier {
+	if account.SubAccount != nil {
+		return account
+	}
+
+	delegated, ok := bitcoin.ResolveColdStakeAccount(account.Address, s.config.Params)
+	if !ok {
+		return account
+	}
+
+	return delegated
+}
+
 // AccountBalance implements /account/balance.
 func (s *AccountAPIService) AccountBalance(
 	ctx context.Context,
@@ -51,24 +77,58 @@ func (s *AccountAPIService) AccountBalance(
 
 	// TODO: filter balances by request currencies
 
+	blockIdentifier, rErr := normalizePartialBlockIdentifier(request.BlockIdentifier)
+	if rErr != nil {
+		return nil, rErr
+	}
+
 	// If we are fetching a historical balance,
 	// use balance storage and don't return coins.
+	account := s.resolveAccount(request.AccountIdentifier)
 	amount, block, err := s.i.GetBalance(
 		ctx,
-		request.AccountIdentifier,
+		account,
 		s.config.Currency,
-		request.BlockIdentifier,
+		blockIdentifier,
 	)
 	if err != nil {
 		return nil, wrapErr(ErrUnableToGetBalance, err)
 	}
 
-	return &types.AccountBalanceResponse{
+	response := &types.AccountBalanceResponse{
 		BlockIdentifier: block,
 		Balances: []*types.Amount{
 			amount,
 		},
-	}, nil
+	}
+
+	// Immature rewards are computed from the unspent
+	// coins of the account, so they are only reported
+	// for the current balance.
+	if blockIdentifier != nil || s.config.Params == nil {
+		return response, nil
+	}
+
+	immature, err := s.i.GetImmatureBalance(ctx, account, s.config.Currency, block)
+	if err != nil {
+		return nil, wrapErr(ErrUnableToGetBalance, err)
+	}
+
+	spendable, err := types.SubtractValues(amount.Value, immature.Value)
+	if err != nil {
+		return nil, wrapErr(ErrUnableToGetBalance, err)
+	}
+
+	metadata, err := types.MarshalMap(&balanceMetadata{
+		Immature:  immature.Value,
+		Spendable: spendable,
+	})
+	if err != nil {
+		return nil, wrapErr(ErrUnableToParseIntermediateResult, err)
+	}
+
+	response.Metadata = metadata
+	return response, nil
 }
 
 // AccountCoins implements /account/coins.
@@ -82,18 +142,140 @@ func (s *AccountAPIService) AccountCoins(
 
 	// TODO: filter coins by request currencies
 
-	// TODO: support include_mempool query
-	// https://github.com/coinbase/rosetta-bitcoin/issues/36#issuecomment-724992022
-	// Once mempoolcoins are supported also change the bool service/types.go:MempoolCoins to true
+	account := s.resolveAccount(request.AccountIdentifier)
+	coins, block, err := s.i.GetCoins(ctx, account)
+	if err != nil {
+		return nil, wrapErr(ErrUnableToGetCoins, err)
+	}
 
-	coins, block, err := s.i.GetCoins(ctx, request.AccountIdentifier)
+	metadata, err := s.coinsMetadata(ctx, coins, block)
 	if err != nil {
 		return nil, wrapErr(ErrUnableToGetCoins, err)
 	}
 
+	if request.IncludeMempool {
+		var rErr *types.Error
+		coins, rErr = s.mempoolCoins(ctx, account, coins, metadata)
+		if rErr != nil {
+			return nil, rErr
+		}
+	}
+
+	marshaledMetadata, err := types.MarshalMap(&accountCoinsMetadata{Coins: metadata})
+	if err != nil {
+		return nil, wrapErr(ErrUnableToParseIntermediateResult, err)
+	}
+
 	result := &types.AccountCoinsResponse{
 		BlockIdentifier: block,
 		Coins:           coins,
+		Metadata:        marshaledMetadata,
+	}
+
+	return result, nil
+}
+
+// coinsMetadata returns the coinMetadata of each coin (by
+// coin identifier), counting confirmations up to block.
+func (s *AccountAPIService) coinsMetadata(
+	ctx context.Context,
+	coins []*types.Coin,
+	block *types.BlockIdentifier,
+) (map[string]*coinMetadata, error) {
+	metadata := map[string]*coinMetadata{}
+	if len(coins) == 0 {
+		return metadata, nil
+	}
+
+	scripts, err := s.i.GetScriptPubKeys(ctx, coins)
+	if err != nil {
+		return nil, err
+	}
+
+	blocks, err := s.i.GetCoinBlocks(ctx, coins)
+	if err != nil {
+		return nil, err
+	}
+
+	for j, coin := range coins {
+		metadata[coin.CoinIdentifier.Identifier] = &coinMetadata{
+			ScriptType:    scripts[j].Type,
+			Confirmations: block.Index - blocks[j].Index + 1,
+		}
+	}
+
+	return metadata, nil
+}
+
+// mempoolCoins applies the transactions in the mempool to
+// the coins of account: coins spent by a mempool transaction
+// are removed and coins it creates for account are added
+// (with 0 confirmations in metadata).
+func (s *AccountAPIService) mempoolCoins(
+	ctx context.Context,
+	account *types.AccountIdentifier,
+	coins []*types.Coin,
+	metadata map[string]*coinMetadata,
+) ([]*types.Coin, *types.Error) {
+	hashes, err := s.client.RawMempool(ctx)
+	if err != nil {
+		return nil, wrapErr(ErrBitcoind, err)
+	}
+
+	spent := map[string]struct{}{}
+	created := []*types.Coin{}
+	for _, hash := range hashes {
+		tx, err := s.client.MempoolTransaction(ctx, hash)
+		if errors.Is(err, bitcoin.ErrTransactionNotInMempool) {
+			// The transaction was confirmed or evicted
+			// since the mempool was fetched.
+			continue
+		}
+		if err != nil {
+			return nil, wrapErr(ErrBitcoind, err)
+		}
+
+		for _, op := range tx.Operations {
+			if op.CoinChange == nil {
+				continue
+			}
+
+			identifier := op.CoinChange.CoinIdentifier.Identifier
+			if op.CoinChange.CoinAction == types.CoinSpent {
+				spent[identifier] = struct{}{}
+				continue
+			}
+
+			if op.Account == nil || types.Hash(op.Account) != types.Hash(account) {
+				continue
+			}
+
+			var opMetadata bitcoin.OperationMetadata
+			if err := types.UnmarshalMap(op.Metadata, &opMetadata); err != nil {
+				return nil, wrapErr(ErrUnableToParseIntermediateResult, err)
+			}
+
+			scriptType := ""
+			if opMetadata.ScriptPubKey != nil {
+				scriptType = opMetadata.ScriptPubKey.Type
+			}
+
+			created = append(created, &types.Coin{
+				CoinIdentifier: op.CoinChange.CoinIdentifier,
+				Amount:         op.Amount,
+			})
+			metadata[identifier] = &coinMetadata{ScriptType: scriptType}
+		}
+	}
+
+	result := []*types.Coin{}
+	for _, coin := range append(coins, created...) {
+		if _, ok := spent[coin.CoinIdentifier.Identifier]; ok {
+			delete(metadata, coin.CoinIdentifier.Identifier)
+			continue
+		}
+
+		result = append(result, coin)
 	}
 
 	return result, nil
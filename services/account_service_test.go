@@ -16,6 +16,7 @@ package services
 
 import (
 	"context"
+	"fmt"
 	"testing"
 
 	"github.com/MNtank/rosetta-bitcoin/bitcoin"
@@ -30,8 +31,9 @@ func TestAccountBalance_Offline(t *testing.T) {
 	cfg := &configuration.Configuration{
 		Mode: configuration.Offline,
 	}
+	mockClient := &mocks.Client{}
 	mockIndexer := &mocks.Indexer{}
-	servicer := NewAccountAPIService(cfg, mockIndexer)
+	servicer := NewAccountAPIService(cfg, mockClient, mockIndexer)
 	ctx := context.Background()
 
 	bal, err := servicer.AccountBalance(ctx, &types.AccountBalanceRequest{})
@@ -50,8 +52,9 @@ func TestAccountBalance_Online_Current(t *testing.T) {
 		Mode:     configuration.Online,
 		Currency: bitcoin.MainnetCurrency,
 	}
+	mockClient := &mocks.Client{}
 	mockIndexer := &mocks.Indexer{}
-	servicer := NewAccountAPIService(cfg, mockIndexer)
+	servicer := NewAccountAPIService(cfg, mockClient, mockIndexer)
 	ctx := context.Background()
 	account := &types.AccountIdentifier{
 		Address: "hello",
@@ -86,13 +89,71 @@ func TestAccountBalance_Online_Current(t *testing.T) {
 	mockIndexer.AssertExpectations(t)
 }
 
+func TestAccountBalance_Online_Immature(t *testing.T) {
+	cfg := &configuration.Configuration{
+		Mode:     configuration.Online,
+		Currency: bitcoin.MainnetCurrency,
+		Params:   bitcoin.MainnetParams,
+	}
+	mockClient := &mocks.Client{}
+	mockIndexer := &mocks.Indexer{}
+	servicer := NewAccountAPIService(cfg, mockClient, mockIndexer)
+	ctx := context.Background()
+	account := &types.AccountIdentifier{
+		Address: "hello",
+	}
+	block := &types.BlockIdentifier{
+		Index: 1000,
+		Hash:  "block 1000",
+	}
+	amount := &types.Amount{
+		Value:    "25",
+		Currency: bitcoin.MainnetCurrency,
+	}
+
+	mockIndexer.On(
+		"GetBalance",
+		ctx,
+		account,
+		bitcoin.MainnetCurrency,
+		(*types.PartialBlockIdentifier)(nil),
+	).Return(amount, block, nil).Once()
+	mockIndexer.On(
+		"GetImmatureBalance",
+		ctx,
+		account,
+		bitcoin.MainnetCurrency,
+		block,
+	).Return(&types.Amount{Value: "10", Currency: bitcoin.MainnetCurrency}, nil).Once()
+	bal, err := servicer.AccountBalance(ctx, &types.AccountBalanceRequest{
+		AccountIdentifier: account,
+	})
+	assert.Nil(t, err)
+
+	metadata, mErr := types.MarshalMap(&balanceMetadata{
+		Immature:  "10",
+		Spendable: "15",
+	})
+	assert.NoError(t, mErr)
+	assert.Equal(t, &types.AccountBalanceResponse{
+		BlockIdentifier: block,
+		Balances: []*types.Amount{
+			amount,
+		},
+		Metadata: metadata,
+	}, bal)
+
+	mockIndexer.AssertExpectations(t)
+}
+
 func TestAccountBalance_Online_Historical(t *testing.T) {
 	cfg := &configuration.Configuration{
 		Mode:     configuration.Online,
 		Currency: bitcoin.MainnetCurrency,
 	}
+	mockClient := &mocks.Client{}
 	mockIndexer := &mocks.Indexer{}
-	servicer := NewAccountAPIService(cfg, mockIndexer)
+	servicer := NewAccountAPIService(cfg, mockClient, mockIndexer)
 	ctx := context.Background()
 	account := &types.AccountIdentifier{
 		Address: "hello",
@@ -136,8 +197,9 @@ func TestAccountCoins_Online(t *testing.T) {
 		Mode:     configuration.Online,
 		Currency: bitcoin.MainnetCurrency,
 	}
+	mockClient := &mocks.Client{}
 	mockIndexer := &mocks.Indexer{}
-	servicer := NewAccountAPIService(cfg, mockIndexer)
+	servicer := NewAccountAPIService(cfg, mockClient, mockIndexer)
 	ctx := context.Background()
 
 	account := &types.AccountIdentifier{
@@ -175,16 +237,246 @@ func TestAccountCoins_Online(t *testing.T) {
 		Hash:  "block 1000",
 	}
 	mockIndexer.On("GetCoins", ctx, account).Return(coins, block, nil).Once()
+	mockIndexer.On("GetScriptPubKeys", ctx, coins).Return(
+		[]*bitcoin.ScriptPubKey{
+			{Type: "pubkeyhash"},
+			{Type: "pubkeyhash"},
+			{Type: "scripthash"},
+		},
+		nil,
+	).Once()
+	mockIndexer.On("GetCoinBlocks", ctx, coins).Return(
+		[]*types.BlockIdentifier{
+			block,
+			{Index: 990, Hash: "block 990"},
+			{Index: 1, Hash: "block 1"},
+		},
+		nil,
+	).Once()
 
 	bal, err := servicer.AccountCoins(ctx, &types.AccountCoinsRequest{
 		AccountIdentifier: account,
 	})
 	assert.Nil(t, err)
 
+	metadata, mErr := types.MarshalMap(&accountCoinsMetadata{
+		Coins: map[string]*coinMetadata{
+			"coin 1": {ScriptType: "pubkeyhash", Confirmations: 1},
+			"coin 2": {ScriptType: "pubkeyhash", Confirmations: 11},
+			"coin 3": {ScriptType: "scripthash", Confirmations: 1000},
+		},
+	})
+	assert.NoError(t, mErr)
 	assert.Equal(t, &types.AccountCoinsResponse{
 		BlockIdentifier: block,
 		Coins:           coins,
+		Metadata:        metadata,
 	}, bal)
 
 	mockIndexer.AssertExpectations(t)
 }
+
+func TestAccountCoins_Online_Mempool(t *testing.T) {
+	cfg := &configuration.Configuration{
+		Mode:     configuration.Online,
+		Currency: bitcoin.MainnetCurrency,
+	}
+	mockClient := &mocks.Client{}
+	mockIndexer := &mocks.Indexer{}
+	servicer := NewAccountAPIService(cfg, mockClient, mockIndexer)
+	ctx := context.Background()
+
+	account := &types.AccountIdentifier{
+		Address: "hello",
+	}
+	other := &types.AccountIdentifier{
+		Address: "other",
+	}
+	amount := &types.Amount{
+		Value:    "10",
+		Currency: bitcoin.MainnetCurrency,
+	}
+	coins := []*types.Coin{
+		{
+			CoinIdentifier: &types.CoinIdentifier{Identifier: "tx 1:0"},
+			Amount:         amount,
+		},
+		{
+			CoinIdentifier: &types.CoinIdentifier{Identifier: "tx 1:1"},
+			Amount:         amount,
+		},
+	}
+	block := &types.BlockIdentifier{
+		Index: 1000,
+		Hash:  "block 1000",
+	}
+	mockIndexer.On("GetCoins", ctx, account).Return(coins, block, nil).Once()
+	mockIndexer.On("GetScriptPubKeys", ctx, coins).Return(
+		[]*bitcoin.ScriptPubKey{
+			{Type: "pubkeyhash"},
+			{Type: "pubkeyhash"},
+		},
+		nil,
+	).Once()
+	mockIndexer.On("GetCoinBlocks", ctx, coins).Return(
+		[]*types.BlockIdentifier{block, block},
+		nil,
+	).Once()
+
+	output := func(index int64, account *types.AccountIdentifier) *types.Operation {
+		return &types.Operation{
+			OperationIdentifier: &types.OperationIdentifier{Index: index},
+			Type:                bitcoin.OutputOpType,
+			Account:             account,
+			Amount:              amount,
+			CoinChange: &types.CoinChange{
+				CoinIdentifier: &types.CoinIdentifier{
+					Identifier: fmt.Sprintf("tx 2:%d", index-1),
+				},
+				CoinAction: types.CoinCreated,
+			},
+			Metadata: map[string]interface{}{
+				"scriptPubKey": map[string]interface{}{
+					"type": "pubkeyhash",
+				},
+			},
+		}
+	}
+
+	// "tx 2" spends "tx 1:0" and creates a coin for account
+	// and one for other, "tx 3" left the mempool, and "tx 4"
+	// spends the second coin "tx 2" creates for account.
+	mockClient.On("RawMempool", ctx).Return([]string{"tx 2", "tx 3", "tx 4"}, nil).Once()
+	mockClient.On("MempoolTransaction", ctx, "tx 2").Return(&types.Transaction{
+		TransactionIdentifier: &types.TransactionIdentifier{Hash: "tx 2"},
+		Operations: []*types.Operation{
+			{
+				OperationIdentifier: &types.OperationIdentifier{Index: 0},
+				Type:                bitcoin.InputOpType,
+				CoinChange: &types.CoinChange{
+					CoinIdentifier: &types.CoinIdentifier{Identifier: "tx 1:0"},
+					CoinAction:     types.CoinSpent,
+				},
+			},
+			output(1, account),
+			output(2, other),
+			output(3, account),
+		},
+	}, nil).Once()
+	mockClient.On("MempoolTransaction", ctx, "tx 3").Return(
+		nil,
+		bitcoin.ErrTransactionNotInMempool,
+	).Once()
+	mockClient.On("MempoolTransaction", ctx, "tx 4").Return(&types.Transaction{
+		TransactionIdentifier: &types.TransactionIdentifier{Hash: "tx 4"},
+		Operations: []*types.Operation{
+			{
+				OperationIdentifier: &types.OperationIdentifier{Index: 0},
+				Type:                bitcoin.InputOpType,
+				CoinChange: &types.CoinChange{
+					CoinIdentifier: &types.CoinIdentifier{Identifier: "tx 2:2"},
+					CoinAction:     types.CoinSpent,
+				},
+			},
+		},
+	}, nil).Once()
+
+	response, err := servicer.AccountCoins(ctx, &types.AccountCoinsRequest{
+		AccountIdentifier: account,
+		IncludeMempool:    true,
+	})
+	assert.Nil(t, err)
+	assert.Equal(t, []*types.Coin{
+		coins[1],
+		{
+			CoinIdentifier: &types.CoinIdentifier{Identifier: "tx 2:0"},
+			Amount:         amount,
+		},
+	}, response.Coins)
+	metadata, mErr := types.MarshalMap(&accountCoinsMetadata{
+		Coins: map[string]*coinMetadata{
+			"tx 1:1": {ScriptType: "pubkeyhash", Confirmations: 1},
+			"tx 2:0": {ScriptType: "pubkeyhash"},
+		},
+	})
+	assert.NoError(t, mErr)
+	assert.Equal(t, metadata, response.Metadata)
+
+	mockClient.AssertExpectations(t)
+	mockIndexer.AssertExpectations(t)
+}
+
+func TestAccountBalance_Online_ColdStake(t *testing.T) {
+	cfg := &configuration.Configuration{
+		Mode:     configuration.Online,
+		Currency: bitcoin.MainnetCurrency,
+		Params:   bitcoin.MainnetParams,
+	}
+	mockClient := &mocks.Client{}
+	mockIndexer := &mocks.Indexer{}
+	servicer := NewAccountAPIService(cfg, mockClient, mockIndexer)
+	ctx := context.Background()
+
+	// Accounts identified by a cold staking script
+	// resolve to the delegated coins of its owner.
+	account := &types.AccountIdentifier{
+		Address: "76a97b63d214c398efa9c392ba6013c5e04ee729755ef7f58b326714228f554bbf766d6f9cc828de1126e3d35d15e5fe6888ac", // nolint
+	}
+	delegated := &types.AccountIdentifier{
+		Address:    "ELJeB54eV9QQt6TvMiYx1b678jeHVeAtKr",
+		SubAccount: &types.SubAccountIdentifier{Address: bitcoin.DelegatedSubAccount},
+	}
+	block := &types.BlockIdentifier{
+		Index: 1000,
+		Hash:  "block 1000",
+	}
+	amount := &types.Amount{
+		Value:    "25",
+		Currency: bitcoin.MainnetCurrency,
+	}
+	coins := []*types.Coin{
+		{
+			CoinIdentifier: &types.CoinIdentifier{Identifier: "coin 1"},
+			Amount:         amount,
+		},
+	}
+
+	mockIndexer.On(
+		"GetBalance",
+		ctx,
+		delegated,
+		bitcoin.MainnetCurrency,
+		(*types.PartialBlockIdentifier)(nil),
+	).Return(amount, block, nil).Twice()
+	mockIndexer.On(
+		"GetImmatureBalance",
+		ctx,
+		delegated,
+		bitcoin.MainnetCurrency,
+		block,
+	).Return(&types.Amount{Value: "0", Currency: bitcoin.MainnetCurrency}, nil).Twice()
+	for _, request := range []*types.AccountIdentifier{account, delegated} {
+		bal, err := servicer.AccountBalance(ctx, &types.AccountBalanceRequest{
+			AccountIdentifier: request,
+		})
+		assert.Nil(t, err)
+		assert.Equal(t, []*types.Amount{amount}, bal.Balances)
+	}
+
+	mockIndexer.On("GetCoins", ctx, delegated).Return(coins, block, nil).Once()
+	mockIndexer.On("GetScriptPubKeys", ctx, coins).Return(
+		[]*bitcoin.ScriptPubKey{{Type: bitcoin.ColdStake}},
+		nil,
+	).Once()
+	mockIndexer.On("GetCoinBlocks", ctx, coins).Return(
+		[]*types.BlockIdentifier{block},
+		nil,
+	).Once()
+	coinsResponse, err := servicer.AccountCoins(ctx, &types.AccountCoinsRequest{
+		AccountIdentifier: account,
+	})
+	assert.Nil(t, err)
+	assert.Equal(t, coins, coinsResponse.Coins)
+
+	mockIndexer.AssertExpectations(t)
+}
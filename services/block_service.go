@@ -16,6 +16,7 @@ package services
 
 import (
 	"context"
+	"encoding/json"
 
 	"github.com/MNtank/rosetta-bitcoin/configuration"
 
@@ -49,9 +50,14 @@ func (s *BlockAPIService) Block(
 		return nil, wrapErr(ErrUnavailableOffline, nil)
 	}
 
-	blockResponse, err := s.i.GetBlockLazy(ctx, request.BlockIdentifier)
+	blockIdentifier, rErr := normalizePartialBlockIdentifier(request.BlockIdentifier)
+	if rErr != nil {
+		return nil, rErr
+	}
+
+	blockResponse, err := s.i.GetBlockLazy(ctx, blockIdentifier)
 	if err != nil {
-		return nil, wrapErr(ErrBlockNotFound, err)
+		return s.staleBlock(ctx, blockIdentifier, err)
 	}
 
 	// Direct client to fetch transactions individually if
@@ -73,12 +79,77 @@ func (s *BlockAPIService) Block(
 
 		txs[i] = transaction
 	}
-	blockResponse.Block.Transactions = txs
 
+	transactions := blockResponse.Block.Transactions
+	otherTransactions := blockResponse.OtherTransactions
+	blockResponse.Block.Transactions = txs
 	blockResponse.OtherTransactions = nil
+
+	// Direct client to fetch transactions individually if
+	// the response would exceed MaxBlockResponseBytes.
+	tooLarge, err := exceedsResponseBytes(blockResponse, s.config.MaxBlockResponseBytes)
+	if err != nil {
+		return nil, wrapErr(ErrUnableToParseIntermediateResult, err)
+	}
+
+	if tooLarge {
+		blockResponse.Block.Transactions = transactions
+		blockResponse.OtherTransactions = otherTransactions
+	}
+
 	return blockResponse, nil
 }
 
+// exceedsResponseBytes returns true if response is
+// larger than maxBytes when serialized (always false
+// if maxBytes is 0).
+func exceedsResponseBytes(response *types.BlockResponse, maxBytes int) (bool, error) {
+	if maxBytes <= 0 {
+		return false, nil
+	}
+
+	encoded, err := json.Marshal(response)
+	if err != nil {
+		return false, err
+	}
+
+	return len(encoded) > maxBytes, nil
+}
+
+// staleBlock returns the block requested by hash if it was
+// removed by a reorg (with all its transactions and the
+// orphaned flag set in its metadata), so that reconcilers
+// can unwind the balances it changed. Otherwise, it returns
+// ErrBlockNotFound with the error of the lookup.
+func (s *BlockAPIService) staleBlock(
+	ctx context.Context,
+	blockIdentifier *types.PartialBlockIdentifier,
+	lookupErr error,
+) (*types.BlockResponse, *types.Error) {
+	if blockIdentifier == nil || blockIdentifier.Hash == nil {
+		return nil, wrapErr(ErrBlockNotFound, lookupErr)
+	}
+
+	block, err := s.i.GetStaleBlock(ctx, *blockIdentifier.Hash)
+	if err != nil {
+		return nil, wrapErr(ErrBlockNotFound, err)
+	}
+
+	if block == nil ||
+		(blockIdentifier.Index != nil && *blockIdentifier.Index != block.BlockIdentifier.Index) {
+		return nil, wrapErr(ErrBlockNotFound, lookupErr)
+	}
+
+	if block.Metadata == nil {
+		block.Metadata = map[string]interface{}{}
+	}
+	block.Metadata[OrphanedMetadataKey] = true
+
+	return &types.BlockResponse{
+		Block: block,
+	}, nil
+}
+
 // BlockTransaction implements the /block/transaction endpoint.
 func (s *BlockAPIService) BlockTransaction(
 	ctx context.Context,
@@ -88,10 +159,20 @@ func (s *BlockAPIService) BlockTransaction(
 		return nil, wrapErr(ErrUnavailableOffline, nil)
 	}
 
+	blockIdentifier, rErr := normalizeBlockIdentifier(request.BlockIdentifier)
+	if rErr != nil {
+		return nil, rErr
+	}
+
+	transactionIdentifier, rErr := normalizeTransactionIdentifier(request.TransactionIdentifier)
+	if rErr != nil {
+		return nil, rErr
+	}
+
 	transaction, err := s.i.GetBlockTransaction(
 		ctx,
-		request.BlockIdentifier,
-		request.TransactionIdentifier,
+		blockIdentifier,
+		transactionIdentifier,
 	)
 	if err != nil {
 		return nil, wrapErr(ErrTransactionNotFound, err)
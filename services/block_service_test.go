@@ -16,9 +16,12 @@ package services
 
 import (
 	"context"
+	"errors"
 	"fmt"
+	"strings"
 	"testing"
 
+	"github.com/MNtank/rosetta-bitcoin/bitcoin"
 	"github.com/MNtank/rosetta-bitcoin/configuration"
 	mocks "github.com/MNtank/rosetta-bitcoin/mocks/services"
 
@@ -58,20 +61,20 @@ func TestBlockService_Online_Inline(t *testing.T) {
 	rawBlock := &types.Block{
 		BlockIdentifier: &types.BlockIdentifier{
 			Index: 100,
-			Hash:  "block 100",
+			Hash:  "0000000000000000000b1e4c2b2ad6f2e11a5c2ef9a8b9e1a2b3c4d5e6f70100",
 		},
 	}
 
 	transaction := &types.Transaction{
 		TransactionIdentifier: &types.TransactionIdentifier{
-			Hash: "tx1",
+			Hash: "a1a1a1a1a1a1a1a1a1a1a1a1a1a1a1a1a1a1a1a1a1a1a1a1a1a1a1a1a1a1a1a1",
 		},
 	}
 
 	block := &types.Block{
 		BlockIdentifier: &types.BlockIdentifier{
 			Index: 100,
-			Hash:  "block 100",
+			Hash:  "0000000000000000000b1e4c2b2ad6f2e11a5c2ef9a8b9e1a2b3c4d5e6f70100",
 		},
 		Transactions: []*types.Transaction{
 			transaction,
@@ -92,7 +95,7 @@ func TestBlockService_Online_Inline(t *testing.T) {
 				Block: rawBlock,
 				OtherTransactions: []*types.TransactionIdentifier{
 					{
-						Hash: "tx1",
+						Hash: "a1a1a1a1a1a1a1a1a1a1a1a1a1a1a1a1a1a1a1a1a1a1a1a1a1a1a1a1a1a1a1a1",
 					},
 				},
 			},
@@ -123,7 +126,7 @@ func TestBlockService_Online_Inline(t *testing.T) {
 				Block: rawBlock,
 				OtherTransactions: []*types.TransactionIdentifier{
 					{
-						Hash: "tx1",
+						Hash: "a1a1a1a1a1a1a1a1a1a1a1a1a1a1a1a1a1a1a1a1a1a1a1a1a1a1a1a1a1a1a1a1",
 					},
 				},
 			},
@@ -160,7 +163,7 @@ func TestBlockService_Online_External(t *testing.T) {
 		Block: &types.Block{
 			BlockIdentifier: &types.BlockIdentifier{
 				Index: 100,
-				Hash:  "block 100",
+				Hash:  "0000000000000000000b1e4c2b2ad6f2e11a5c2ef9a8b9e1a2b3c4d5e6f70100",
 			},
 		},
 	}
@@ -168,7 +171,7 @@ func TestBlockService_Online_External(t *testing.T) {
 	otherTxs := []*types.TransactionIdentifier{}
 	for i := 0; i < 200; i++ {
 		otherTxs = append(otherTxs, &types.TransactionIdentifier{
-			Hash: fmt.Sprintf("tx%d", i),
+			Hash: fmt.Sprintf("%064x", i),
 		})
 	}
 	blockResponse.OtherTransactions = otherTxs
@@ -211,3 +214,257 @@ func TestBlockService_Online_External(t *testing.T) {
 
 	mockIndexer.AssertExpectations(t)
 }
+
+func TestBlockService_Online_MaxResponseBytes(t *testing.T) {
+	cfg := &configuration.Configuration{
+		Mode:                  configuration.Online,
+		MaxBlockResponseBytes: 1000,
+	}
+	mockIndexer := &mocks.Indexer{}
+	servicer := NewBlockAPIService(cfg, mockIndexer)
+	ctx := context.Background()
+
+	blockIdentifier := &types.BlockIdentifier{
+		Index: 100,
+		Hash:  "0000000000000000000b1e4c2b2ad6f2e11a5c2ef9a8b9e1a2b3c4d5e6f70100",
+	}
+	otherTxs := []*types.TransactionIdentifier{}
+	for i := 0; i < 10; i++ {
+		otherTx := &types.TransactionIdentifier{
+			Hash: fmt.Sprintf("%064x", i),
+		}
+		otherTxs = append(otherTxs, otherTx)
+		mockIndexer.On(
+			"GetBlockTransaction",
+			ctx,
+			blockIdentifier,
+			otherTx,
+		).Return(
+			&types.Transaction{TransactionIdentifier: otherTx},
+			nil,
+		)
+	}
+
+	// Blocks smaller than MaxBlockResponseBytes
+	// are served inline
+	mockIndexer.On(
+		"GetBlockLazy",
+		ctx,
+		(*types.PartialBlockIdentifier)(nil),
+	).Return(
+		&types.BlockResponse{
+			Block:             &types.Block{BlockIdentifier: blockIdentifier},
+			OtherTransactions: otherTxs[:2],
+		},
+		nil,
+	).Once()
+	b, err := servicer.Block(ctx, &types.BlockRequest{})
+	assert.Nil(t, err)
+	assert.Nil(t, b.OtherTransactions)
+	assert.Equal(t, &types.Block{
+		BlockIdentifier: blockIdentifier,
+		Transactions: []*types.Transaction{
+			{TransactionIdentifier: otherTxs[0]},
+			{TransactionIdentifier: otherTxs[1]},
+		},
+	}, b.Block)
+
+	// The transactions of larger blocks are
+	// returned as other transactions
+	blockResponse := &types.BlockResponse{
+		Block:             &types.Block{BlockIdentifier: blockIdentifier},
+		OtherTransactions: otherTxs,
+	}
+	mockIndexer.On(
+		"GetBlockLazy",
+		ctx,
+		(*types.PartialBlockIdentifier)(nil),
+	).Return(
+		blockResponse,
+		nil,
+	).Once()
+	b, err = servicer.Block(ctx, &types.BlockRequest{})
+	assert.Nil(t, err)
+	assert.Equal(t, &types.BlockResponse{
+		Block:             &types.Block{BlockIdentifier: blockIdentifier},
+		OtherTransactions: otherTxs,
+	}, b)
+
+	// Other transactions are served by /block/transaction
+	bTx, err := servicer.BlockTransaction(ctx, &types.BlockTransactionRequest{
+		BlockIdentifier:       blockIdentifier,
+		TransactionIdentifier: otherTxs[9],
+	})
+	assert.Nil(t, err)
+	assert.Equal(t, otherTxs[9], bTx.Transaction.TransactionIdentifier)
+
+	mockIndexer.AssertExpectations(t)
+}
+
+func TestBlockService_Online_Stale(t *testing.T) {
+	cfg := &configuration.Configuration{
+		Mode: configuration.Online,
+	}
+	mockIndexer := &mocks.Indexer{}
+	servicer := NewBlockAPIService(cfg, mockIndexer)
+	ctx := context.Background()
+
+	hash := "0000000000000000000b1e4c2b2ad6f2e11a5c2ef9a8b9e1a2b3c4d5e6f70100"
+	staleBlock := &types.Block{
+		BlockIdentifier: &types.BlockIdentifier{
+			Index: 100,
+			Hash:  hash,
+		},
+		ParentBlockIdentifier: &types.BlockIdentifier{
+			Index: 99,
+			Hash:  "0000000000000000000b1e4c2b2ad6f2e11a5c2ef9a8b9e1a2b3c4d5e6f70099",
+		},
+		Transactions: []*types.Transaction{
+			{
+				TransactionIdentifier: &types.TransactionIdentifier{
+					Hash: "a1a1a1a1a1a1a1a1a1a1a1a1a1a1a1a1a1a1a1a1a1a1a1a1a1a1a1a1a1a1a1a1",
+				},
+			},
+		},
+		Metadata: map[string]interface{}{
+			"nonce": float64(1),
+		},
+	}
+	blockIdentifier := &types.PartialBlockIdentifier{Hash: &hash}
+	mockIndexer.On(
+		"GetBlockLazy",
+		ctx,
+		blockIdentifier,
+	).Return(
+		nil,
+		errors.New("block not found"),
+	).Twice()
+	mockIndexer.On("GetStaleBlock", ctx, hash).Return(staleBlock, nil).Twice()
+
+	// Blocks removed by a reorg are served by hash
+	// (with the orphaned flag set)
+	b, err := servicer.Block(ctx, &types.BlockRequest{BlockIdentifier: blockIdentifier})
+	assert.Nil(t, err)
+	assert.Equal(t, &types.BlockResponse{Block: staleBlock}, b)
+	assert.Equal(t, map[string]interface{}{
+		"nonce":    float64(1),
+		"orphaned": true,
+	}, b.Block.Metadata)
+
+	// The index of a stale block must match
+	wrongIndex := &types.PartialBlockIdentifier{
+		Index: types.Int64(101),
+		Hash:  &hash,
+	}
+	mockIndexer.On(
+		"GetBlockLazy",
+		ctx,
+		wrongIndex,
+	).Return(
+		nil,
+		errors.New("block not found"),
+	).Once()
+	b, err = servicer.Block(ctx, &types.BlockRequest{BlockIdentifier: wrongIndex})
+	assert.Nil(t, b)
+	assert.Equal(t, ErrBlockNotFound.Code, err.Code)
+
+	// Blocks that were never removed are not found
+	mockIndexer.On("GetStaleBlock", ctx, hash).Return(nil, nil).Once()
+	b, err = servicer.Block(ctx, &types.BlockRequest{BlockIdentifier: blockIdentifier})
+	assert.Nil(t, b)
+	assert.Equal(t, ErrBlockNotFound.Code, err.Code)
+
+	mockIndexer.AssertExpectations(t)
+}
+
+func TestBlockService_InvalidIdentifier(t *testing.T) {
+	cfg := &configuration.Configuration{
+		Mode: configuration.Online,
+	}
+	mockIndexer := &mocks.Indexer{}
+	servicer := NewBlockAPIService(cfg, mockIndexer)
+	ctx := context.Background()
+
+	blockIdentifier := &types.BlockIdentifier{
+		Index: 100,
+		Hash:  "0000000000000000000b1e4c2b2ad6f2e11a5c2ef9a8b9e1a2b3c4d5e6f70100",
+	}
+	transactionIdentifier := &types.TransactionIdentifier{
+		Hash: "a1a1a1a1a1a1a1a1a1a1a1a1a1a1a1a1a1a1a1a1a1a1a1a1a1a1a1a1a1a1a1a1",
+	}
+
+	t.Run("uppercase hashes are normalized", func(t *testing.T) {
+		transaction := &types.Transaction{
+			TransactionIdentifier: transactionIdentifier,
+		}
+		mockIndexer.On(
+			"GetBlockTransaction",
+			ctx,
+			blockIdentifier,
+			transactionIdentifier,
+		).Return(
+			transaction,
+			nil,
+		).Once()
+
+		bTx, err := servicer.BlockTransaction(ctx, &types.BlockTransactionRequest{
+			BlockIdentifier: &types.BlockIdentifier{
+				Index: 100,
+				Hash:  strings.ToUpper(blockIdentifier.Hash),
+			},
+			TransactionIdentifier: &types.TransactionIdentifier{
+				Hash: strings.ToUpper(transactionIdentifier.Hash),
+			},
+		})
+		assert.Nil(t, err)
+		assert.Equal(t, transaction, bTx.Transaction)
+	})
+
+	t.Run("genesis allocations transaction", func(t *testing.T) {
+		genesisAllocations := &types.TransactionIdentifier{
+			Hash: bitcoin.GenesisAllocationsTransactionHash,
+		}
+		transaction := &types.Transaction{
+			TransactionIdentifier: genesisAllocations,
+		}
+		mockIndexer.On(
+			"GetBlockTransaction",
+			ctx,
+			blockIdentifier,
+			genesisAllocations,
+		).Return(
+			transaction,
+			nil,
+		).Once()
+
+		bTx, err := servicer.BlockTransaction(ctx, &types.BlockTransactionRequest{
+			BlockIdentifier:       blockIdentifier,
+			TransactionIdentifier: genesisAllocations,
+		})
+		assert.Nil(t, err)
+		assert.Equal(t, transaction, bTx.Transaction)
+	})
+
+	t.Run("short block hash", func(t *testing.T) {
+		b, err := servicer.Block(ctx, &types.BlockRequest{
+			BlockIdentifier: &types.PartialBlockIdentifier{
+				Hash: types.String("block 100"),
+			},
+		})
+		assert.Nil(t, b)
+		assert.Equal(t, ErrInvalidIdentifier.Code, err.Code)
+	})
+
+	t.Run("non-hex transaction hash", func(t *testing.T) {
+		bTx, err := servicer.BlockTransaction(ctx, &types.BlockTransactionRequest{
+			BlockIdentifier: blockIdentifier,
+			TransactionIdentifier: &types.TransactionIdentifier{
+				Hash: strings.Repeat("z", 64),
+			},
+		})
+		assert.Nil(t, bTx)
+		assert.Equal(t, ErrInvalidIdentifier.Code, err.Code)
+	})
+
+	mockIndexer.AssertExpectations(t)
+}
@@ -0,0 +1,623 @@
+// Copyright 2020 Coinbase, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package services
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sort"
+
+	"github.com/MNtank/rosetta-bitcoin/bitcoin"
+	"github.com/MNtank/rosetta-bitcoin/configuration"
+	"github.com/MNtank/rosetta-bitcoin/utils"
+
+	"github.com/btcsuite/btcd/txscript"
+	"github.com/coinbase/rosetta-sdk-go/server"
+	"github.com/coinbase/rosetta-sdk-go/types"
+)
+
+const (
+	// defaultCoinChurnWindow is the number of recent
+	// blocks scanned for coin churn by default.
+	defaultCoinChurnWindow = int64(1000)
+
+	// maxCoinChurnWindow is the maximum number of
+	// recent blocks that can be scanned for coin
+	// churn in a single request.
+	maxCoinChurnWindow = int64(10000)
+)
+
+// CallAPIService implements the server.CallAPIServicer interface.
+type CallAPIService struct {
+	config *configuration.Configuration
+	client Client
+	i      Indexer
+}
+
+// NewCallAPIService creates a new instance of a CallAPIService.
+func NewCallAPIService(
+	config *configuration.Configuration,
+	client Client,
+	i Indexer,
+) server.CallAPIServicer {
+	return &CallAPIService{
+		config: config,
+		client: client,
+		i:      i,
+	}
+}
+
+// Call implements the /call endpoint.
+func (s *CallAPIService) Call(
+	ctx context.Context,
+	request *types.CallRequest,
+) (*types.CallResponse, *types.Error) {
+	if requiresAdminCalls(request) && !s.config.AdminCalls {
+		return nil, wrapErr(ErrAdminCallsDisabled, nil)
+	}
+
+	// Migrating addresses does not require
+	// the node (or the indexer).
+	if request.Method == MigrateAddressCallMethod {
+		return s.migrateAddress(ctx, request.Parameters)
+	}
+
+	// Transactions are hashed without
+	// the node (or the indexer).
+	if request.Method == TransactionHashCallMethod {
+		return s.transactionHash(ctx, request.Parameters)
+	}
+
+	// Construction features are reported from the build
+	// alone in offline mode.
+	if request.Method == ConstructionFeaturesCallMethod {
+		return s.constructionFeatures(ctx)
+	}
+
+	// The params of the network
+	// are part of the configuration.
+	if request.Method == NetworkParamsCallMethod {
+		return s.networkParams(ctx)
+	}
+
+	// Log sampling is a setting of
+	// this process only.
+	if request.Method == LogSamplingCallMethod {
+		return s.logSampling(ctx, request.Parameters)
+	}
+
+	if s.config.Mode != configuration.Online {
+		return nil, wrapErr(ErrUnavailableOffline, nil)
+	}
+
+	switch request.Method {
+	case StatsCallMethod:
+		return s.stats(ctx)
+	case BlockTimelineCallMethod:
+		return s.blockTimeline(ctx, request.Parameters)
+	case ConstructionFlowCallMethod:
+		return s.constructionFlow(ctx, request.Parameters)
+	case CoinChurnCallMethod:
+		return s.coinChurn(ctx, request.Parameters)
+	case FeeEstimateCallMethod:
+		return s.feeEstimate(ctx)
+	case PeerBansCallMethod:
+		return s.peerBans(ctx)
+	case PeerAdminCallMethod:
+		return s.peerAdmin(ctx, request.Parameters)
+	case SyncStatusCallMethod:
+		return s.syncStatus(ctx)
+	default:
+		return nil, wrapErr(ErrUnimplemented, nil)
+	}
+}
+
+// requiresAdminCalls returns true if request changes the
+// state of the node or of this process (or reads the
+// construction flows of clients), so that it is only
+// served when admin calls are enabled.
+func requiresAdminCalls(request *types.CallRequest) bool {
+	switch request.Method {
+	case PeerAdminCallMethod, ConstructionFlowCallMethod:
+		return true
+	case LogSamplingCallMethod:
+		_, ok := request.Parameters["rates"]
+		return ok
+	default:
+		return false
+	}
+}
+
+// networkParams returns the params of the network.
+func (s *CallAPIService) networkParams(
+	ctx context.Context,
+) (*types.CallResponse, *types.Error) {
+	// The params are encoded with their JSON encoding
+	// (i.e. durations as strings), so that the result
+	// can be loaded as a params file.
+	encoded, err := bitcoin.MarshalParams(s.config.Params)
+	if err != nil {
+		return nil, wrapErr(ErrUnableToParseIntermediateResult, err)
+	}
+
+	var result map[string]interface{}
+	if err := json.Unmarshal(encoded, &result); err != nil {
+		return nil, wrapErr(ErrUnableToParseIntermediateResult, err)
+	}
+
+	return &types.CallResponse{
+		Result:     result,
+		Idempotent: true,
+	}, nil
+}
+
+// logSampling sets the requested log sample
+// rates and returns the rate of each sampler.
+func (s *CallAPIService) logSampling(
+	ctx context.Context,
+	parameters map[string]interface{},
+) (*types.CallResponse, *types.Error) {
+	var request logSamplingParameters
+	if err := types.UnmarshalMap(parameters, &request); err != nil {
+		return nil, wrapErr(ErrUnableToParseIntermediateResult, err)
+	}
+
+	// Rates are validated before any is set, so
+	// that an invalid request changes nothing.
+	current := utils.LogSampleRates()
+	for name, rate := range request.Rates {
+		if _, ok := current[name]; !ok {
+			return nil, wrapErr(
+				ErrUnableToParseIntermediateResult,
+				fmt.Errorf("%w: %s", utils.ErrUnknownLogSampler, name),
+			)
+		}
+
+		if rate < 0 {
+			return nil, wrapErr(
+				ErrUnableToParseIntermediateResult,
+				fmt.Errorf("%w: %d", utils.ErrInvalidSampleRate, rate),
+			)
+		}
+	}
+
+	for name, rate := range request.Rates {
+		if err := utils.SetLogSampleRate(name, rate); err != nil {
+			return nil, wrapErr(ErrUnableToParseIntermediateResult, err)
+		}
+	}
+
+	result, err := types.MarshalMap(&logSamplingResult{Rates: utils.LogSampleRates()})
+	if err != nil {
+		return nil, wrapErr(ErrUnableToParseIntermediateResult, err)
+	}
+
+	return &types.CallResponse{
+		Result:     result,
+		Idempotent: false,
+	}, nil
+}
+
+// stats returns the most recent indexer storage snapshot.
+func (s *CallAPIService) stats(
+	ctx context.Context,
+) (*types.CallResponse, *types.Error) {
+	snapshot, err := s.i.GetSnapshot(ctx)
+	if err != nil {
+		return nil, wrapErr(ErrNotReady, err)
+	}
+
+	result, err := types.MarshalMap(snapshot)
+	if err != nil {
+		return nil, wrapErr(ErrUnableToParseIntermediateResult, err)
+	}
+
+	return &types.CallResponse{
+		Result:     result,
+		Idempotent: false,
+	}, nil
+}
+
+// blockTimeline returns the processing timeline
+// of the block at the requested index.
+func (s *CallAPIService) blockTimeline(
+	ctx context.Context,
+	parameters map[string]interface{},
+) (*types.CallResponse, *types.Error) {
+	var request blockTimelineParameters
+	if err := types.UnmarshalMap(parameters, &request); err != nil {
+		return nil, wrapErr(ErrUnableToParseIntermediateResult, err)
+	}
+
+	if request.Index == nil {
+		return nil, wrapErr(ErrUnableToParseIntermediateResult, errors.New("index is missing"))
+	}
+
+	timeline, err := s.i.GetBlockTimeline(ctx, *request.Index)
+	if err != nil {
+		return nil, wrapErr(ErrBlockNotFound, err)
+	}
+
+	result, err := types.MarshalMap(timeline)
+	if err != nil {
+		return nil, wrapErr(ErrUnableToParseIntermediateResult, err)
+	}
+
+	return &types.CallResponse{
+		Result:     result,
+		Idempotent: false,
+	}, nil
+}
+
+// constructionFlow returns the persisted
+// state of the requested construction flow.
+func (s *CallAPIService) constructionFlow(
+	ctx context.Context,
+	parameters map[string]interface{},
+) (*types.CallResponse, *types.Error) {
+	var request constructionFlowParameters
+	if err := types.UnmarshalMap(parameters, &request); err != nil {
+		return nil, wrapErr(ErrUnableToParseIntermediateResult, err)
+	}
+
+	if len(request.FlowID) == 0 {
+		return nil, wrapErr(ErrUnableToParseIntermediateResult, errors.New("flow_id is missing"))
+	}
+
+	flow, err := s.i.GetConstructionFlow(ctx, request.FlowID)
+	if err != nil {
+		return nil, wrapErr(ErrConstructionFlowNotFound, err)
+	}
+
+	result, err := types.MarshalMap(flow)
+	if err != nil {
+		return nil, wrapErr(ErrUnableToParseIntermediateResult, err)
+	}
+
+	return &types.CallResponse{
+		Result:     result,
+		Idempotent: false,
+	}, nil
+}
+
+// coinChurn reports how the coins of an account are created
+// and spent over recent blocks and what consolidating its
+// coins would cost at the suggested fee rate.
+func (s *CallAPIService) coinChurn(
+	ctx context.Context,
+	parameters map[string]interface{},
+) (*types.CallResponse, *types.Error) {
+	var request coinChurnParameters
+	if err := types.UnmarshalMap(parameters, &request); err != nil {
+		return nil, wrapErr(ErrUnableToParseIntermediateResult, err)
+	}
+
+	if request.AccountIdentifier == nil || len(request.AccountIdentifier.Address) == 0 {
+		return nil, wrapErr(
+			ErrUnableToParseIntermediateResult,
+			errors.New("account_identifier is missing"),
+		)
+	}
+
+	window := defaultCoinChurnWindow
+	if request.Window != nil {
+		window = *request.Window
+	}
+	if window <= 0 || window > maxCoinChurnWindow {
+		return nil, wrapErr(
+			ErrUnableToParseIntermediateResult,
+			fmt.Errorf("window %d is not between 1 and %d", window, maxCoinChurnWindow),
+		)
+	}
+
+	estimate, err := estimateFeeRate(ctx, s.config, s.client, s.i)
+	if err != nil {
+		return nil, wrapErr(ErrCouldNotGetFeeRate, err)
+	}
+	satoshisPerB := estimate.FeeRate
+	dustThreshold := int64(satoshisPerB * bitcoin.InputSize)
+
+	churn, err := s.i.GetCoinChurn(ctx, request.AccountIdentifier, window, dustThreshold)
+	if err != nil {
+		return nil, wrapErr(ErrUnableToGetCoins, err)
+	}
+
+	result := &coinChurnResult{
+		CoinChurn:     churn,
+		FeeRate:       satoshisPerB,
+		DustThreshold: dustThreshold,
+	}
+	if inputs := churn.Coins - churn.DustCoins; inputs > 1 {
+		size := bitcoin.TransactionOverhead +
+			inputs*bitcoin.InputSize +
+			bitcoin.OutputOverhead +
+			bitcoin.P2PKHScriptPubkeySize
+		result.ConsolidationInputs = inputs
+		result.ConsolidationFee = int64(satoshisPerB * float64(size))
+	}
+
+	marshaled, err := types.MarshalMap(result)
+	if err != nil {
+		return nil, wrapErr(ErrUnableToParseIntermediateResult, err)
+	}
+
+	return &types.CallResponse{
+		Result:     marshaled,
+		Idempotent: false,
+	}, nil
+}
+
+// feeEstimate returns the fee rate suggested by
+// /construction/metadata and the fee rate of each
+// source it is blended from.
+func (s *CallAPIService) feeEstimate(
+	ctx context.Context,
+) (*types.CallResponse, *types.Error) {
+	estimate, err := estimateFeeRate(ctx, s.config, s.client, s.i)
+	if err != nil {
+		return nil, wrapErr(ErrCouldNotGetFeeRate, err)
+	}
+
+	result, err := types.MarshalMap(estimate)
+	if err != nil {
+		return nil, wrapErr(ErrUnableToParseIntermediateResult, err)
+	}
+
+	return &types.CallResponse{
+		Result:     result,
+		Idempotent: false,
+	}, nil
+}
+
+// syncStatus returns the sync progress of
+// the node and the indexer.
+func (s *CallAPIService) syncStatus(
+	ctx context.Context,
+) (*types.CallResponse, *types.Error) {
+	progress, _, _, rosettaErr := loadSyncProgress(ctx, s.config.Network, s.client, s.i)
+	if rosettaErr != nil {
+		return nil, rosettaErr
+	}
+
+	result, err := types.MarshalMap(progress)
+	if err != nil {
+		return nil, wrapErr(ErrUnableToParseIntermediateResult, err)
+	}
+
+	return &types.CallResponse{
+		Result:     result,
+		Idempotent: false,
+	}, nil
+}
+
+// peerBans returns the ban score of each
+// peer and the subnets banned by the node.
+func (s *CallAPIService) peerBans(
+	ctx context.Context,
+) (*types.CallResponse, *types.Error) {
+	peers, err := s.client.GetPeers(ctx)
+	if err != nil {
+		return nil, wrapErr(ErrBitcoind, err)
+	}
+
+	result := &peerBansResult{Peers: make([]*peerBanScore, len(peers))}
+	for j, peer := range peers {
+		var info bitcoin.PeerInfo
+		if err := types.UnmarshalMap(peer.Metadata, &info); err != nil {
+			return nil, wrapErr(ErrUnableToParseIntermediateResult, err)
+		}
+
+		result.Peers[j] = &peerBanScore{PeerID: peer.PeerID, BanScore: info.BanScore}
+	}
+
+	sort.SliceStable(result.Peers, func(a, b int) bool {
+		return result.Peers[a].BanScore > result.Peers[b].BanScore
+	})
+
+	result.Banned, err = s.client.ListBanned(ctx)
+	if err != nil {
+		return nil, wrapErr(ErrBitcoind, err)
+	}
+
+	encoded, err := types.MarshalMap(result)
+	if err != nil {
+		return nil, wrapErr(ErrUnableToParseIntermediateResult, err)
+	}
+
+	return &types.CallResponse{
+		Result:     encoded,
+		Idempotent: false,
+	}, nil
+}
+
+// peerAdmin disconnects, bans, or unbans a peer
+// and returns the resulting peerBans.
+func (s *CallAPIService) peerAdmin(
+	ctx context.Context,
+	parameters map[string]interface{},
+) (*types.CallResponse, *types.Error) {
+	var request peerAdminParameters
+	if err := types.UnmarshalMap(parameters, &request); err != nil {
+		return nil, wrapErr(ErrUnableToParseIntermediateResult, err)
+	}
+
+	if len(request.Address) == 0 {
+		return nil, wrapErr(
+			ErrUnableToParseIntermediateResult,
+			errors.New("address is missing"),
+		)
+	}
+
+	if request.BanTime < 0 {
+		return nil, wrapErr(
+			ErrUnableToParseIntermediateResult,
+			fmt.Errorf("ban time %d is negative", request.BanTime),
+		)
+	}
+
+	var err error
+	switch request.Action {
+	case DisconnectPeerAction:
+		err = s.client.DisconnectPeer(ctx, request.Address)
+	case BanPeerAction:
+		err = s.client.BanPeer(ctx, request.Address, request.BanTime)
+	case UnbanPeerAction:
+		err = s.client.UnbanPeer(ctx, request.Address)
+	default:
+		return nil, wrapErr(
+			ErrUnableToParseIntermediateResult,
+			fmt.Errorf("%s is not a valid action", request.Action),
+		)
+	}
+	if err != nil {
+		return nil, wrapErr(ErrBitcoind, err)
+	}
+
+	utils.ExtractLogger(ctx, "call").Infow(
+		"peer admin action",
+		"action", request.Action,
+		"address", request.Address,
+	)
+
+	return s.peerBans(ctx)
+}
+
+// migrateAddress re-encodes an address with the
+// prefixes of the requested address era.
+func (s *CallAPIService) migrateAddress(
+	ctx context.Context,
+	parameters map[string]interface{},
+) (*types.CallResponse, *types.Error) {
+	var request migrateAddressParameters
+	if err := types.UnmarshalMap(parameters, &request); err != nil {
+		return nil, wrapErr(ErrUnableToParseIntermediateResult, err)
+	}
+
+	if len(request.Address) == 0 {
+		return nil, wrapErr(ErrUnableToParseIntermediateResult, errors.New("address is missing"))
+	}
+
+	if len(request.Era) == 0 {
+		request.Era = bitcoin.CurrentAddressEra
+	}
+
+	eras, addressType, err := bitcoin.AddressEras(request.Address, s.config.AddressEras)
+	if err != nil {
+		return nil, wrapErr(ErrUnableToDecodeAddress, err)
+	}
+
+	migrated, err := bitcoin.MigrateAddress(request.Address, s.config.AddressEras, request.Era)
+	if err != nil {
+		return nil, wrapErr(ErrUnableToDecodeAddress, err)
+	}
+
+	result, err := types.MarshalMap(&migrateAddressResult{
+		Address:         request.Address,
+		Type:            addressType,
+		Eras:            eras,
+		Era:             request.Era,
+		MigratedAddress: migrated,
+	})
+	if err != nil {
+		return nil, wrapErr(ErrUnableToParseIntermediateResult, err)
+	}
+
+	return &types.CallResponse{
+		Result:     result,
+		Idempotent: true,
+	}, nil
+}
+
+// transactionHash returns the identifiers of an
+// unsigned or signed transaction.
+func (s *CallAPIService) transactionHash(
+	ctx context.Context,
+	parameters map[string]interface{},
+) (*types.CallResponse, *types.Error) {
+	var request transactionHashParameters
+	if err := types.UnmarshalMap(parameters, &request); err != nil {
+		return nil, wrapErr(ErrUnableToParseIntermediateResult, err)
+	}
+
+	if len(request.Transaction) == 0 {
+		return nil, wrapErr(ErrUnableToParseIntermediateResult, errors.New("transaction is missing"))
+	}
+
+	hashes, err := HashConstructionTransaction(request.Transaction, request.Signed)
+	if err != nil {
+		return nil, wrapErr(ErrUnableToParseIntermediateResult, err)
+	}
+
+	result, err := types.MarshalMap(&transactionHashResult{
+		TransactionIdentifier: &types.TransactionIdentifier{Hash: hashes.TxID},
+		TransactionHashes:     hashes,
+	})
+	if err != nil {
+		return nil, wrapErr(ErrUnableToParseIntermediateResult, err)
+	}
+
+	return &types.CallResponse{
+		Result:     result,
+		Idempotent: true,
+	}, nil
+}
+
+// constructionFeatures returns the construction features
+// supported by this build, restricted to those enabled by
+// the deployments active on the node (when online).
+func (s *CallAPIService) constructionFeatures(
+	ctx context.Context,
+) (*types.CallResponse, *types.Error) {
+	segwit, deployments, rErr := segwitActive(ctx, s.config, s.client)
+	if rErr != nil {
+		return nil, rErr
+	}
+
+	// Only P2WPKH inputs can be signed (see ConstructionPayloads).
+	spendable := []string{}
+	payable := []string{
+		txscript.PubKeyHashTy.String(),
+		txscript.ScriptHashTy.String(),
+	}
+	if segwit {
+		spendable = append(spendable, txscript.WitnessV0PubKeyHashTy.String())
+		payable = append(
+			payable,
+			txscript.WitnessV0PubKeyHashTy.String(),
+			txscript.WitnessV0ScriptHashTy.String(),
+		)
+	}
+
+	result, err := types.MarshalMap(&constructionFeaturesResult{
+		SpendableScriptTypes: spendable,
+		PayableScriptTypes:   payable,
+		SigHashTypes:         []string{SigHashAll},
+		RBF:                  false,
+		PSBT:                 false,
+		Multisig:             false,
+		ColdStaking:          false,
+		Deployments:          deployments,
+	})
+	if err != nil {
+		return nil, wrapErr(ErrUnableToParseIntermediateResult, err)
+	}
+
+	return &types.CallResponse{
+		Result:     result,
+		Idempotent: false,
+	}, nil
+}
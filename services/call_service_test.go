@@ -0,0 +1,961 @@
+// Copyright 2020 Coinbase, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package services
+
+import (
+	"context"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"testing"
+
+	"github.com/MNtank/rosetta-bitcoin/bitcoin"
+	"github.com/MNtank/rosetta-bitcoin/configuration"
+	mocks "github.com/MNtank/rosetta-bitcoin/mocks/services"
+	"github.com/MNtank/rosetta-bitcoin/utils"
+
+	"github.com/coinbase/rosetta-sdk-go/types"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCallEndpoints_Offline(t *testing.T) {
+	cfg := &configuration.Configuration{
+		Mode: configuration.Offline,
+	}
+	mockIndexer := &mocks.Indexer{}
+	servicer := NewCallAPIService(cfg, &mocks.Client{}, mockIndexer)
+	ctx := context.Background()
+
+	resp, err := servicer.Call(ctx, &types.CallRequest{
+		Method: StatsCallMethod,
+	})
+	assert.Nil(t, resp)
+	assert.Equal(t, ErrUnavailableOffline.Code, err.Code)
+	assert.Equal(t, ErrUnavailableOffline.Message, err.Message)
+
+	mockIndexer.AssertExpectations(t)
+}
+
+func TestCallEndpoints_Stats(t *testing.T) {
+	cfg := &configuration.Configuration{
+		Mode: configuration.Online,
+	}
+	mockIndexer := &mocks.Indexer{}
+	servicer := NewCallAPIService(cfg, &mocks.Client{}, mockIndexer)
+	ctx := context.Background()
+
+	// No snapshot computed yet
+	mockIndexer.On("GetSnapshot", ctx).Return(nil, errors.New("not ready")).Once()
+	resp, err := servicer.Call(ctx, &types.CallRequest{
+		Method: StatsCallMethod,
+	})
+	assert.Nil(t, resp)
+	assert.Equal(t, ErrNotReady.Code, err.Code)
+	assert.True(t, err.Retriable)
+
+	snapshot := &utils.Snapshot{
+		BlockIdentifier: &types.BlockIdentifier{
+			Hash:  "block 100",
+			Index: 100,
+		},
+		Timestamp:           1599002115,
+		Accounts:            10,
+		FundedAccounts:      4,
+		Coins:               6,
+		MeanCoinsPerAccount: 1.5,
+		DatabaseKeys: map[string]int64{
+			"coin": 6,
+		},
+	}
+	mockIndexer.On("GetSnapshot", ctx).Return(snapshot, nil).Once()
+	resp, err = servicer.Call(ctx, &types.CallRequest{
+		Method: StatsCallMethod,
+	})
+	assert.Nil(t, err)
+	assert.False(t, resp.Idempotent)
+
+	var result utils.Snapshot
+	assert.NoError(t, types.UnmarshalMap(resp.Result, &result))
+	assert.Equal(t, snapshot, &result)
+
+	// Unsupported method
+	resp, err = servicer.Call(ctx, &types.CallRequest{
+		Method: "unknown",
+	})
+	assert.Nil(t, resp)
+	assert.Equal(t, ErrUnimplemented.Code, err.Code)
+
+	mockIndexer.AssertExpectations(t)
+}
+
+func TestCallEndpoints_BlockTimeline(t *testing.T) {
+	cfg := &configuration.Configuration{
+		Mode: configuration.Online,
+	}
+	mockIndexer := &mocks.Indexer{}
+	servicer := NewCallAPIService(cfg, &mocks.Client{}, mockIndexer)
+	ctx := context.Background()
+
+	// Missing index
+	resp, err := servicer.Call(ctx, &types.CallRequest{
+		Method: BlockTimelineCallMethod,
+	})
+	assert.Nil(t, resp)
+	assert.Equal(t, ErrUnableToParseIntermediateResult.Code, err.Code)
+
+	// Timeline not recorded
+	mockIndexer.On(
+		"GetBlockTimeline",
+		ctx,
+		int64(99),
+	).Return(nil, errors.New("not found")).Once()
+	resp, err = servicer.Call(ctx, &types.CallRequest{
+		Method:     BlockTimelineCallMethod,
+		Parameters: map[string]interface{}{"index": 99},
+	})
+	assert.Nil(t, resp)
+	assert.Equal(t, ErrBlockNotFound.Code, err.Code)
+
+	timeline := &utils.BlockTimeline{
+		BlockIdentifier: &types.BlockIdentifier{
+			Hash:  "block 100",
+			Index: 100,
+		},
+		Transactions: 10,
+		Inputs:       20,
+		Fetch:        1.5,
+		Coins:        2,
+		Parse:        3,
+		Validate:     0.5,
+		Commit:       4,
+		Total:        11,
+	}
+	mockIndexer.On("GetBlockTimeline", ctx, int64(100)).Return(timeline, nil).Once()
+	resp, err = servicer.Call(ctx, &types.CallRequest{
+		Method:     BlockTimelineCallMethod,
+		Parameters: map[string]interface{}{"index": 100},
+	})
+	assert.Nil(t, err)
+	assert.False(t, resp.Idempotent)
+
+	var result utils.BlockTimeline
+	assert.NoError(t, types.UnmarshalMap(resp.Result, &result))
+	assert.Equal(t, timeline, &result)
+
+	mockIndexer.AssertExpectations(t)
+}
+
+func TestCallEndpoints_ConstructionFlow(t *testing.T) {
+	cfg := &configuration.Configuration{
+		Mode: configuration.Online,
+	}
+	mockIndexer := &mocks.Indexer{}
+	servicer := NewCallAPIService(cfg, &mocks.Client{}, mockIndexer)
+	ctx := context.Background()
+
+	// Admin calls disabled
+	resp, err := servicer.Call(ctx, &types.CallRequest{
+		Method:     ConstructionFlowCallMethod,
+		Parameters: map[string]interface{}{"flow_id": "flow"},
+	})
+	assert.Nil(t, resp)
+	assert.Equal(t, ErrAdminCallsDisabled.Code, err.Code)
+
+	cfg.AdminCalls = true
+
+	// Missing flow ID
+	resp, err = servicer.Call(ctx, &types.CallRequest{
+		Method: ConstructionFlowCallMethod,
+	})
+	assert.Nil(t, resp)
+	assert.Equal(t, ErrUnableToParseIntermediateResult.Code, err.Code)
+
+	// Flow not stored
+	mockIndexer.On(
+		"GetConstructionFlow",
+		ctx,
+		"missing",
+	).Return(nil, errors.New("not found")).Once()
+	resp, err = servicer.Call(ctx, &types.CallRequest{
+		Method:     ConstructionFlowCallMethod,
+		Parameters: map[string]interface{}{"flow_id": "missing"},
+	})
+	assert.Nil(t, resp)
+	assert.Equal(t, ErrConstructionFlowNotFound.Code, err.Code)
+
+	flow := &utils.ConstructionFlow{
+		ID:                  "flow",
+		Options:             map[string]interface{}{"flow_id": "flow"},
+		UnsignedTransaction: "unsigned",
+		Created:             1000,
+		Updated:             1010,
+	}
+	mockIndexer.On("GetConstructionFlow", ctx, "flow").Return(flow, nil).Once()
+	resp, err = servicer.Call(ctx, &types.CallRequest{
+		Method:     ConstructionFlowCallMethod,
+		Parameters: map[string]interface{}{"flow_id": "flow"},
+	})
+	assert.Nil(t, err)
+	assert.False(t, resp.Idempotent)
+
+	var result utils.ConstructionFlow
+	assert.NoError(t, types.UnmarshalMap(resp.Result, &result))
+	assert.Equal(t, flow, &result)
+
+	mockIndexer.AssertExpectations(t)
+}
+
+func TestCallEndpoints_CoinChurn(t *testing.T) {
+	cfg := &configuration.Configuration{
+		Mode: configuration.Online,
+	}
+	mockClient := &mocks.Client{}
+	mockIndexer := &mocks.Indexer{}
+	servicer := NewCallAPIService(cfg, mockClient, mockIndexer)
+	ctx := context.Background()
+	account := &types.AccountIdentifier{Address: "EH9uVaqWRxHuzJbroqzX18yxmeW8XVJyV9"}
+
+	// Missing account
+	resp, err := servicer.Call(ctx, &types.CallRequest{
+		Method: CoinChurnCallMethod,
+	})
+	assert.Nil(t, resp)
+	assert.Equal(t, ErrUnableToParseIntermediateResult.Code, err.Code)
+
+	// Window too large
+	resp, err = servicer.Call(ctx, &types.CallRequest{
+		Method: CoinChurnCallMethod,
+		Parameters: map[string]interface{}{
+			"account_identifier": account,
+			"window":             maxCoinChurnWindow + 1,
+		},
+	})
+	assert.Nil(t, resp)
+	assert.Equal(t, ErrUnableToParseIntermediateResult.Code, err.Code)
+
+	// 20 satoshis per byte, so coins worth
+	// less than 20*68 satoshis are dust.
+	churn := &utils.CoinChurn{
+		BlockIdentifier: &types.BlockIdentifier{
+			Hash:  "block 100",
+			Index: 100,
+		},
+		Window:       100,
+		CoinsCreated: 10,
+		CoinsSpent:   5,
+		CreationRate: 0.1,
+		SpendRate:    0.05,
+		MeanLifetime: 12,
+		Coins:        5,
+		MeanCoinAge:  40,
+		DustCoins:    2,
+		DustValue:    1000,
+	}
+	mockClient.On(
+		"SuggestedFeeRate",
+		ctx,
+		defaultConfirmationTarget,
+	).Return(0.0002, nil).Once()
+	mockIndexer.On(
+		"GetCoinChurn",
+		ctx,
+		account,
+		int64(100),
+		int64(1360),
+	).Return(churn, nil).Once()
+	resp, err = servicer.Call(ctx, &types.CallRequest{
+		Method: CoinChurnCallMethod,
+		Parameters: map[string]interface{}{
+			"account_identifier": account,
+			"window":             100,
+		},
+	})
+	assert.Nil(t, err)
+	assert.False(t, resp.Idempotent)
+
+	var result coinChurnResult
+	assert.NoError(t, types.UnmarshalMap(resp.Result, &result))
+	assert.Equal(t, &coinChurnResult{
+		CoinChurn:           churn,
+		FeeRate:             20,
+		DustThreshold:       1360,
+		ConsolidationInputs: 3,
+		ConsolidationFee:    5000,
+	}, &result)
+
+	// Defaults to the most recent 1000 blocks
+	mockClient.On(
+		"SuggestedFeeRate",
+		ctx,
+		defaultConfirmationTarget,
+	).Return(0.0002, nil).Once()
+	mockIndexer.On(
+		"GetCoinChurn",
+		ctx,
+		account,
+		defaultCoinChurnWindow,
+		int64(1360),
+	).Return(nil, errors.New("no blocks")).Once()
+	resp, err = servicer.Call(ctx, &types.CallRequest{
+		Method: CoinChurnCallMethod,
+		Parameters: map[string]interface{}{
+			"account_identifier": account,
+		},
+	})
+	assert.Nil(t, resp)
+	assert.Equal(t, ErrUnableToGetCoins.Code, err.Code)
+
+	mockClient.AssertExpectations(t)
+	mockIndexer.AssertExpectations(t)
+}
+
+func TestCallEndpoints_MigrateAddress(t *testing.T) {
+	cfg := &configuration.Configuration{
+		Mode:        configuration.Offline,
+		AddressEras: bitcoin.MainnetAddressEras,
+	}
+	mockIndexer := &mocks.Indexer{}
+	servicer := NewCallAPIService(cfg, &mocks.Client{}, mockIndexer)
+	ctx := context.Background()
+
+	// Missing address
+	resp, err := servicer.Call(ctx, &types.CallRequest{
+		Method: MigrateAddressCallMethod,
+	})
+	assert.Nil(t, resp)
+	assert.Equal(t, ErrUnableToParseIntermediateResult.Code, err.Code)
+
+	// Invalid address
+	resp, err = servicer.Call(ctx, &types.CallRequest{
+		Method:     MigrateAddressCallMethod,
+		Parameters: map[string]interface{}{"address": "blah"},
+	})
+	assert.Nil(t, resp)
+	assert.Equal(t, ErrUnableToDecodeAddress.Code, err.Code)
+
+	// Migrate to current era (default)
+	resp, err = servicer.Call(ctx, &types.CallRequest{
+		Method:     MigrateAddressCallMethod,
+		Parameters: map[string]interface{}{"address": "EH9uVaqWRxHuzJbroqzX18yxmeW8XVJyV9"},
+	})
+	assert.Nil(t, err)
+	assert.Equal(t, &types.CallResponse{
+		Result: map[string]interface{}{
+			"address":          "EH9uVaqWRxHuzJbroqzX18yxmeW8XVJyV9",
+			"type":             bitcoin.PubKeyHashAddressType,
+			"eras":             []string{bitcoin.CurrentAddressEra},
+			"era":              bitcoin.CurrentAddressEra,
+			"migrated_address": "EH9uVaqWRxHuzJbroqzX18yxmeW8XVJyV9",
+		},
+		Idempotent: true,
+	}, resp)
+
+	// Upstream Bitcoin prefixes were never used
+	resp, err = servicer.Call(ctx, &types.CallRequest{
+		Method:     MigrateAddressCallMethod,
+		Parameters: map[string]interface{}{"address": "1111111111111111111114oLvT2"},
+	})
+	assert.Nil(t, resp)
+	assert.Equal(t, ErrUnableToDecodeAddress.Code, err.Code)
+
+	// Unknown era
+	resp, err = servicer.Call(ctx, &types.CallRequest{
+		Method: MigrateAddressCallMethod,
+		Parameters: map[string]interface{}{
+			"address": "EH9uVaqWRxHuzJbroqzX18yxmeW8XVJyV9",
+			"era":     "future",
+		},
+	})
+	assert.Nil(t, resp)
+	assert.Equal(t, ErrUnableToDecodeAddress.Code, err.Code)
+
+	mockIndexer.AssertExpectations(t)
+}
+
+func TestCallEndpoints_TransactionHash(t *testing.T) {
+	cfg := &configuration.Configuration{
+		Mode:     configuration.Offline,
+		Currency: bitcoin.TestnetCurrency,
+	}
+	servicer := NewCallAPIService(cfg, nil, nil)
+	constructionServicer := NewConstructionAPIService(cfg, nil, nil)
+	ctx := context.Background()
+
+	txID := "6d87ad0e26025128f5a8357fa423b340cbcffb9703f79f432f5520fca59cd20b"
+	unsigned, err := json.Marshal(&unsignedTransaction{
+		Transaction: "01000000017f9cf50b02dd5258f80cd5c3437302e027dd1336172a20cdc80305c5a55741b10100000000ffffffff02db910e000000000016001488ce6925f8513a234c05c922ee933f221323052071ae000000000000160014940726595c41fca0b4810c62991ad9d289eeb82800000000", // nolint
+	})
+	assert.NoError(t, err)
+	signed, err := json.Marshal(&signedTransaction{
+		Transaction: "010000000001017f9cf50b02dd5258f80cd5c3437302e027dd1336172a20cdc80305c5a55741b10100000000ffffffff02db910e000000000016001488ce6925f8513a234c05c922ee933f221323052071ae000000000000160014940726595c41fca0b4810c62991ad9d289eeb82802473044022025876ec8b9f51d343a5a56ac549c0c828005ef45ebe9da166db645c09157223f02204cd08b7278a8889a81135915bce10d1ef3bb92b217f81a0de7e79ffb3dfd6ac501210325c9a4252789b31dbb3454ec647e9516e7c596bcde2bd5da71a60fab8644e43800000000", // nolint
+	})
+	assert.NoError(t, err)
+
+	tests := map[string]struct {
+		parameters map[string]interface{}
+
+		result *transactionHashResult
+		err    *types.Error
+	}{
+		"unsigned": {
+			parameters: map[string]interface{}{
+				"transaction": hex.EncodeToString(unsigned),
+			},
+			result: &transactionHashResult{
+				TransactionIdentifier: &types.TransactionIdentifier{Hash: txID},
+				TransactionHashes: &bitcoin.TransactionHashes{
+					TxID:  txID,
+					WTxID: txID,
+				},
+			},
+		},
+		"signed": {
+			parameters: map[string]interface{}{
+				"transaction": hex.EncodeToString(signed),
+				"signed":      true,
+			},
+			result: &transactionHashResult{
+				TransactionIdentifier: &types.TransactionIdentifier{Hash: txID},
+				TransactionHashes: &bitcoin.TransactionHashes{
+					TxID:       txID,
+					WTxID:      "707230803d452f5449b3f5989c4df5040638a62831627c1fb447861e18170821",
+					HasWitness: true,
+				},
+			},
+		},
+		"missing transaction": {
+			parameters: map[string]interface{}{"signed": true},
+			err:        ErrUnableToParseIntermediateResult,
+		},
+		"invalid transaction": {
+			parameters: map[string]interface{}{"transaction": "zz"},
+			err:        ErrUnableToParseIntermediateResult,
+		},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			resp, err := servicer.Call(ctx, &types.CallRequest{
+				Method:     TransactionHashCallMethod,
+				Parameters: test.parameters,
+			})
+			if test.err != nil {
+				assert.Nil(t, resp)
+				assert.Equal(t, test.err.Code, err.Code)
+				return
+			}
+
+			assert.Nil(t, err)
+			assert.True(t, resp.Idempotent)
+			assert.Equal(t, forceMarshalMap(t, test.result), resp.Result)
+		})
+	}
+
+	// The signed txid is the hash returned
+	// by /construction/hash.
+	hashResponse, rErr := constructionServicer.ConstructionHash(
+		ctx,
+		&types.ConstructionHashRequest{SignedTransaction: hex.EncodeToString(signed)},
+	)
+	assert.Nil(t, rErr)
+	assert.Equal(t, txID, hashResponse.TransactionIdentifier.Hash)
+}
+
+func TestCallEndpoints_ConstructionFeatures(t *testing.T) {
+	cfg := &configuration.Configuration{
+		Mode:   configuration.Online,
+		Segwit: true,
+	}
+	mockClient := &mocks.Client{}
+	mockIndexer := &mocks.Indexer{}
+	servicer := NewCallAPIService(cfg, mockClient, mockIndexer)
+	ctx := context.Background()
+
+	// Node error
+	mockClient.On("GetBlockchainInfo", ctx).Return(nil, errors.New("bad")).Once()
+	resp, err := servicer.Call(ctx, &types.CallRequest{
+		Method: ConstructionFeaturesCallMethod,
+	})
+	assert.Nil(t, resp)
+	assert.Equal(t, ErrBitcoind.Code, err.Code)
+
+	// Segwit active
+	mockClient.On("GetBlockchainInfo", ctx).Return(&bitcoin.BlockchainInfo{
+		Softforks: map[string]*bitcoin.Softfork{
+			"csv":                    {Type: "buried", Active: true, Height: 419328},
+			bitcoin.SegwitDeployment: {Type: "buried", Active: true, Height: 481824},
+		},
+	}, nil).Once()
+	resp, err = servicer.Call(ctx, &types.CallRequest{
+		Method: ConstructionFeaturesCallMethod,
+	})
+	assert.Nil(t, err)
+	assert.Equal(t, &types.CallResponse{
+		Result: map[string]interface{}{
+			"spendable_script_types": []string{"witness_v0_keyhash"},
+			"payable_script_types": []string{
+				"pubkeyhash",
+				"scripthash",
+				"witness_v0_keyhash",
+				"witness_v0_scripthash",
+			},
+			"sighash_types": []string{SigHashAll},
+			"rbf":           false,
+			"psbt":          false,
+			"multisig":      false,
+			"cold_staking":  false,
+			"deployments": map[string]bool{
+				"csv":                    true,
+				bitcoin.SegwitDeployment: true,
+			},
+		},
+		Idempotent: false,
+	}, resp)
+
+	// Segwit not active
+	mockClient.On("GetBlockchainInfo", ctx).Return(&bitcoin.BlockchainInfo{
+		Softforks: map[string]*bitcoin.Softfork{
+			bitcoin.SegwitDeployment: {Type: "bip9", Active: false},
+		},
+	}, nil).Once()
+	resp, err = servicer.Call(ctx, &types.CallRequest{
+		Method: ConstructionFeaturesCallMethod,
+	})
+	assert.Nil(t, err)
+	assert.Equal(t, []string{}, resp.Result["spendable_script_types"])
+	assert.Equal(
+		t,
+		[]string{"pubkeyhash", "scripthash"},
+		resp.Result["payable_script_types"],
+	)
+
+	// Offline mode does not query the node
+	cfg.Mode = configuration.Offline
+	resp, err = servicer.Call(ctx, &types.CallRequest{
+		Method: ConstructionFeaturesCallMethod,
+	})
+	assert.Nil(t, err)
+	assert.Equal(t, []string{"witness_v0_keyhash"}, resp.Result["spendable_script_types"])
+	assert.NotContains(t, resp.Result, "deployments")
+
+	// Segwit not active (in offline mode)
+	cfg.Segwit = false
+	resp, err = servicer.Call(ctx, &types.CallRequest{
+		Method: ConstructionFeaturesCallMethod,
+	})
+	assert.Nil(t, err)
+	assert.Equal(t, []string{}, resp.Result["spendable_script_types"])
+
+	mockClient.AssertExpectations(t)
+	mockIndexer.AssertExpectations(t)
+}
+
+func TestCallEndpoints_NetworkParams(t *testing.T) {
+	cfg := &configuration.Configuration{
+		Mode:   configuration.Offline,
+		Params: bitcoin.MainnetParams,
+	}
+	mockIndexer := &mocks.Indexer{}
+	servicer := NewCallAPIService(cfg, &mocks.Client{}, mockIndexer)
+	ctx := context.Background()
+
+	resp, err := servicer.Call(ctx, &types.CallRequest{
+		Method: NetworkParamsCallMethod,
+	})
+	assert.Nil(t, err)
+	assert.True(t, resp.Idempotent)
+	assert.Equal(t, bitcoin.MainnetParams.Name, resp.Result["name"])
+	assert.Equal(t, bitcoin.MainnetParams.GenesisHash.String(), resp.Result["genesis_hash"])
+	assert.Equal(t, "336h0m0s", resp.Result["target_timespan"])
+
+	// The result can be loaded as params.
+	raw, marshalErr := json.Marshal(resp.Result)
+	assert.NoError(t, marshalErr)
+	params, paramsErr := bitcoin.UnmarshalParams(raw)
+	assert.NoError(t, paramsErr)
+	assert.Equal(t, bitcoin.MainnetParams.Net, params.Net)
+	assert.Equal(t, bitcoin.MainnetParams.PowLimit, params.PowLimit)
+
+	mockIndexer.AssertExpectations(t)
+}
+
+func TestCallEndpoints_LogSampling(t *testing.T) {
+	cfg := &configuration.Configuration{
+		Mode: configuration.Online,
+	}
+	mockIndexer := &mocks.Indexer{}
+	servicer := NewCallAPIService(cfg, &mocks.Client{}, mockIndexer)
+	ctx := context.Background()
+	defer func() {
+		assert.NoError(t, utils.SetLogSampleRate(utils.TransactionLogSampler, 0))
+		assert.NoError(t, utils.SetLogSampleRate(utils.CoinLogSampler, 0))
+	}()
+
+	// Sampling is disabled by default
+	resp, err := servicer.Call(ctx, &types.CallRequest{
+		Method: LogSamplingCallMethod,
+	})
+	assert.Nil(t, err)
+	assert.False(t, resp.Idempotent)
+	assert.Equal(t, map[string]int64{
+		utils.TransactionLogSampler: 0,
+		utils.CoinLogSampler:        0,
+	}, resp.Result["rates"])
+	assert.False(t, utils.GetLogSampler(utils.CoinLogSampler).Sample())
+
+	// Rates are only set if
+	// admin calls are enabled.
+	setRates := &types.CallRequest{
+		Method: LogSamplingCallMethod,
+		Parameters: map[string]interface{}{
+			"rates": map[string]interface{}{
+				utils.CoinLogSampler: 2,
+			},
+		},
+	}
+	resp, err = servicer.Call(ctx, setRates)
+	assert.Nil(t, resp)
+	assert.Equal(t, ErrAdminCallsDisabled.Code, err.Code)
+	assert.Equal(t, int64(0), utils.LogSampleRates()[utils.CoinLogSampler])
+
+	cfg.AdminCalls = true
+	resp, err = servicer.Call(ctx, &types.CallRequest{
+		Method: LogSamplingCallMethod,
+		Parameters: map[string]interface{}{
+			"rates": map[string]interface{}{
+				utils.CoinLogSampler: 2,
+			},
+		},
+	})
+	assert.Nil(t, err)
+	assert.Equal(t, map[string]int64{
+		utils.TransactionLogSampler: 0,
+		utils.CoinLogSampler:        2,
+	}, resp.Result["rates"])
+
+	sampled := 0
+	for j := 0; j < 10; j++ {
+		if utils.GetLogSampler(utils.CoinLogSampler).Sample() {
+			sampled++
+		}
+	}
+	assert.Equal(t, 5, sampled)
+
+	// Invalid requests change nothing
+	for _, rates := range []map[string]interface{}{
+		{utils.TransactionLogSampler: 1, "block": 1},
+		{utils.TransactionLogSampler: 1, utils.CoinLogSampler: -1},
+	} {
+		resp, err = servicer.Call(ctx, &types.CallRequest{
+			Method:     LogSamplingCallMethod,
+			Parameters: map[string]interface{}{"rates": rates},
+		})
+		assert.Nil(t, resp)
+		assert.Equal(t, ErrUnableToParseIntermediateResult.Code, err.Code)
+	}
+	assert.Equal(t, map[string]int64{
+		utils.TransactionLogSampler: 0,
+		utils.CoinLogSampler:        2,
+	}, utils.LogSampleRates())
+
+	mockIndexer.AssertExpectations(t)
+}
+
+func TestRequiresAdminCalls(t *testing.T) {
+	adminCalls := map[string]bool{}
+	for _, method := range CallMethods {
+		if requiresAdminCalls(&types.CallRequest{Method: method}) {
+			adminCalls[method] = true
+		}
+	}
+
+	// Only methods that change state (or read the
+	// construction flows of clients) require admin
+	// calls (log sampling only when it sets rates).
+	assert.Equal(t, map[string]bool{
+		PeerAdminCallMethod:        true,
+		ConstructionFlowCallMethod: true,
+	}, adminCalls)
+	assert.True(t, requiresAdminCalls(&types.CallRequest{
+		Method:     LogSamplingCallMethod,
+		Parameters: map[string]interface{}{"rates": map[string]interface{}{}},
+	}))
+}
+
+func TestCallEndpoints_FeeEstimate(t *testing.T) {
+	cfg := &configuration.Configuration{
+		Mode: configuration.Online,
+		FeeWeights: map[string]float64{
+			configuration.NodeFeeSource:    0.5,
+			configuration.MempoolFeeSource: 0.3,
+			configuration.BlocksFeeSource:  0.2,
+		},
+		FeeFloor:      2,
+		FeePercentile: 50,
+		FeeBlocks:     2,
+	}
+	mockClient := &mocks.Client{}
+	mockIndexer := &mocks.Indexer{}
+	servicer := NewCallAPIService(cfg, mockClient, mockIndexer)
+	ctx := context.Background()
+
+	// The node estimates 10 sat/vB
+	mockClient.On(
+		"SuggestedFeeRate",
+		ctx,
+		defaultConfirmationTarget,
+	).Return(0.0001, nil).Once()
+
+	// The median mempool fee rate is 5 sat/vB (transactions
+	// that left the mempool are skipped)
+	mockClient.On("RawMempool", ctx).Return([]string{"tx a", "tx b", "tx c"}, nil).Once()
+	mockClient.On("MempoolEntry", ctx, "tx a").Return(&bitcoin.MempoolEntry{
+		VSize: 100,
+		Fees:  &bitcoin.MempoolEntryFees{Base: 0.000005},
+	}, nil).Once()
+	mockClient.On("MempoolEntry", ctx, "tx b").Return(
+		nil,
+		bitcoin.ErrTransactionNotInMempool,
+	).Once()
+	mockClient.On("MempoolEntry", ctx, "tx c").Return(&bitcoin.MempoolEntry{
+		VSize: 100,
+		Fees:  &bitcoin.MempoolEntryFees{Base: 0.00003},
+	}, nil).Once()
+
+	// The median fee rate of the last 2 blocks is 10 sat/vB
+	// (coinbase transactions are skipped)
+	transaction := func(hash string, amounts []string, coinbase bool) *types.Transaction {
+		tx := &types.Transaction{
+			TransactionIdentifier: &types.TransactionIdentifier{Hash: hash},
+			Metadata:              map[string]interface{}{"size": 100},
+		}
+		for j, amount := range amounts {
+			opType := bitcoin.OutputOpType
+			if coinbase && j == 0 {
+				opType = bitcoin.CoinbaseOpType
+			}
+
+			tx.Operations = append(tx.Operations, &types.Operation{
+				OperationIdentifier: &types.OperationIdentifier{Index: int64(j)},
+				Type:                opType,
+				Amount:              &types.Amount{Value: amount, Currency: bitcoin.MainnetCurrency},
+			})
+		}
+
+		return tx
+	}
+	head := &types.BlockResponse{
+		Block: &types.Block{
+			BlockIdentifier: &types.BlockIdentifier{Hash: "block 10", Index: 10},
+			Transactions: []*types.Transaction{
+				transaction("coinbase", []string{"0", "5000000000"}, true),
+				transaction("tx 10", []string{"-10000", "9000"}, false),
+			},
+		},
+	}
+	parent := &types.BlockResponse{
+		Block: &types.Block{
+			BlockIdentifier: &types.BlockIdentifier{Hash: "block 9", Index: 9},
+		},
+		OtherTransactions: []*types.TransactionIdentifier{{Hash: "tx 9"}},
+	}
+	mockIndexer.On("GetBlockLazy", ctx, (*types.PartialBlockIdentifier)(nil)).Return(head, nil).Once()
+	mockIndexer.On(
+		"GetBlockLazy",
+		ctx,
+		&types.PartialBlockIdentifier{Index: types.Int64(10)},
+	).Return(head, nil).Once()
+	mockIndexer.On(
+		"GetBlockLazy",
+		ctx,
+		&types.PartialBlockIdentifier{Index: types.Int64(9)},
+	).Return(parent, nil).Once()
+	mockIndexer.On(
+		"GetBlockTransaction",
+		ctx,
+		parent.Block.BlockIdentifier,
+		parent.OtherTransactions[0],
+	).Return(transaction("tx 9", []string{"-10000", "8000"}, false), nil).Once()
+
+	resp, err := servicer.Call(ctx, &types.CallRequest{
+		Method: FeeEstimateCallMethod,
+	})
+	assert.Nil(t, err)
+	assert.False(t, resp.Idempotent)
+
+	var result feeEstimate
+	assert.NoError(t, types.UnmarshalMap(resp.Result, &result))
+	assert.InDelta(t, 8.5, result.FeeRate, 0.0001) // 0.5*10 + 0.3*5 + 0.2*10
+	assert.Equal(t, float64(2), result.Floor)
+	assert.Len(t, result.Sources, 3)
+	for j, expected := range []float64{10, 5, 10} {
+		assert.InDelta(t, expected, *result.Sources[j].FeeRate, 0.0001)
+	}
+
+	mockClient.AssertExpectations(t)
+	mockIndexer.AssertExpectations(t)
+}
+
+func TestCallEndpoints_PeerAdmin(t *testing.T) {
+	cfg := &configuration.Configuration{
+		Mode: configuration.Online,
+	}
+	mockClient := &mocks.Client{}
+	mockIndexer := &mocks.Indexer{}
+	servicer := NewCallAPIService(cfg, mockClient, mockIndexer)
+	ctx := context.Background()
+
+	peers := []*types.Peer{
+		{PeerID: "10.0.0.1:46000", Metadata: map[string]interface{}{"banscore": 10}},
+		{PeerID: "10.0.0.2:46000", Metadata: map[string]interface{}{"banscore": 80}},
+	}
+	banned := []*bitcoin.BannedSubnet{
+		{Address: "10.0.0.3/32", BannedUntil: 1633456789, BanCreated: 1633370389},
+	}
+
+	// Peers are sorted by ban score
+	mockClient.On("GetPeers", ctx).Return(peers, nil).Once()
+	mockClient.On("ListBanned", ctx).Return(banned, nil).Once()
+	resp, err := servicer.Call(ctx, &types.CallRequest{
+		Method: PeerBansCallMethod,
+	})
+	assert.Nil(t, err)
+
+	var result peerBansResult
+	assert.NoError(t, types.UnmarshalMap(resp.Result, &result))
+	assert.Equal(t, []*peerBanScore{
+		{PeerID: "10.0.0.2:46000", BanScore: 80},
+		{PeerID: "10.0.0.1:46000", BanScore: 10},
+	}, result.Peers)
+	assert.Equal(t, banned, result.Banned)
+
+	// Admin methods are disabled by default
+	ban := map[string]interface{}{
+		"action":   BanPeerAction,
+		"address":  "10.0.0.2",
+		"ban_time": 3600,
+	}
+	resp, err = servicer.Call(ctx, &types.CallRequest{
+		Method:     PeerAdminCallMethod,
+		Parameters: ban,
+	})
+	assert.Nil(t, resp)
+	assert.Equal(t, ErrAdminCallsDisabled.Code, err.Code)
+
+	cfg.AdminCalls = true
+	mockClient.On("BanPeer", ctx, "10.0.0.2", int64(3600)).Return(nil).Once()
+	mockClient.On("GetPeers", ctx).Return(peers[:1], nil).Once()
+	mockClient.On("ListBanned", ctx).Return(append(banned, &bitcoin.BannedSubnet{
+		Address:     "10.0.0.2/32",
+		BannedUntil: 1633460389,
+		BanCreated:  1633456789,
+	}), nil).Once()
+	resp, err = servicer.Call(ctx, &types.CallRequest{
+		Method:     PeerAdminCallMethod,
+		Parameters: ban,
+	})
+	assert.Nil(t, err)
+	assert.False(t, resp.Idempotent)
+
+	var afterBan peerBansResult
+	assert.NoError(t, types.UnmarshalMap(resp.Result, &afterBan))
+	assert.Len(t, afterBan.Peers, 1)
+	assert.Len(t, afterBan.Banned, 2)
+
+	// Node errors are surfaced
+	mockClient.On("DisconnectPeer", ctx, "10.0.0.9:46000").Return(
+		bitcoin.ErrJSONRPCError,
+	).Once()
+	resp, err = servicer.Call(ctx, &types.CallRequest{
+		Method: PeerAdminCallMethod,
+		Parameters: map[string]interface{}{
+			"action":  DisconnectPeerAction,
+			"address": "10.0.0.9:46000",
+		},
+	})
+	assert.Nil(t, resp)
+	assert.Equal(t, ErrBitcoind.Code, err.Code)
+
+	// Invalid actions are rejected
+	resp, err = servicer.Call(ctx, &types.CallRequest{
+		Method: PeerAdminCallMethod,
+		Parameters: map[string]interface{}{
+			"action":  "kick",
+			"address": "10.0.0.1:46000",
+		},
+	})
+	assert.Nil(t, resp)
+	assert.Equal(t, ErrUnableToParseIntermediateResult.Code, err.Code)
+
+	mockClient.AssertExpectations(t)
+	mockIndexer.AssertExpectations(t)
+}
+
+func TestCallEndpoints_SyncStatus(t *testing.T) {
+	cfg := &configuration.Configuration{
+		Mode: configuration.Online,
+	}
+	mockIndexer := &mocks.Indexer{}
+	mockClient := &mocks.Client{}
+	servicer := NewCallAPIService(cfg, mockClient, mockIndexer)
+	ctx := context.Background()
+
+	mockClient.On("GetPeers", ctx).Return([]*types.Peer{
+		{
+			PeerID: "77.93.223.9:8333",
+			Metadata: map[string]interface{}{
+				"startingheight": 1000,
+			},
+		},
+	}, nil)
+	mockClient.On("GetBlockchainInfo", ctx).Return(&bitcoin.BlockchainInfo{
+		Blocks:  1000,
+		Headers: 1000,
+	}, nil)
+	mockIndexer.On(
+		"GetBlockLazy",
+		ctx,
+		(*types.PartialBlockIdentifier)(nil),
+	).Return(&types.BlockResponse{
+		Block: &types.Block{
+			BlockIdentifier: &types.BlockIdentifier{
+				Index: 600,
+				Hash:  "block 600",
+			},
+		},
+	}, nil)
+	mockIndexer.On("GetSyncRate", ctx).Return(float64(20))
+
+	resp, err := servicer.Call(ctx, &types.CallRequest{
+		Method: SyncStatusCallMethod,
+	})
+	assert.Nil(t, err)
+	assert.False(t, resp.Idempotent)
+
+	var result syncProgress
+	assert.NoError(t, types.UnmarshalMap(resp.Result, &result))
+	assert.Equal(t, &syncProgress{
+		Stage:           IndexingStage,
+		CurrentIndex:    600,
+		TargetIndex:     1000,
+		Percentage:      60,
+		Synced:          false,
+		BlocksPerSecond: 20,
+		ETA:             types.Int64(20),
+	}, &result)
+
+	mockIndexer.AssertExpectations(t)
+	mockClient.AssertExpectations(t)
+}
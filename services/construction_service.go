@@ -21,11 +21,13 @@ import (
 	"encoding/json"
 	"errors"
 	"fmt"
+	"math"
 	"math/big"
-	"strconv"
+	"strings"
 
 	"github.com/MNtank/rosetta-bitcoin/bitcoin"
 	"github.com/MNtank/rosetta-bitcoin/configuration"
+	"github.com/MNtank/rosetta-bitcoin/utils"
 
 	"github.com/btcsuite/btcd/btcec"
 	"github.com/btcsuite/btcd/txscript"
@@ -71,7 +73,25 @@ func (s *ConstructionAPIService) ConstructionDerive(
 	ctx context.Context,
 	request *types.ConstructionDeriveRequest,
 ) (*types.ConstructionDeriveResponse, *types.Error) {
-	addr, err := btcutil.NewAddressWitnessPubKeyHash(
+	segwit, _, rErr := segwitActive(ctx, s.config, s.client)
+	if rErr != nil {
+		return nil, rErr
+	}
+
+	// Only P2WPKH addresses are derived.
+	if !segwit {
+		return nil, wrapErr(
+			ErrSegwitNotActivated,
+			errors.New("unable to derive P2WPKH address"),
+		)
+	}
+
+	var requestMetadata deriveRequestMetadata
+	if err := types.UnmarshalMap(request.Metadata, &requestMetadata); err != nil {
+		return nil, wrapErr(ErrUnableToParseIntermediateResult, err)
+	}
+
+	addr, err := bitcoin.EncodeSegwitAddress(
 		btcutil.Hash160(request.PublicKey.Bytes),
 		s.config.Params,
 	)
@@ -79,29 +99,51 @@ func (s *ConstructionAPIService) ConstructionDerive(
 		return nil, wrapErr(ErrUnableToDerive, err)
 	}
 
+	// The derivation path of the key is returned so that
+	// wallets derive keys (and addresses) consistently.
+	path, err := bitcoin.DerivationPath(
+		s.config.Params,
+		requestMetadata.Account,
+		requestMetadata.Change,
+		requestMetadata.Index,
+	)
+	if err != nil {
+		return nil, wrapErr(ErrUnableToDerive, err)
+	}
+
+	metadata, err := types.MarshalMap(&deriveResponseMetadata{DerivationPath: path})
+	if err != nil {
+		return nil, wrapErr(ErrUnableToDerive, err)
+	}
+
 	return &types.ConstructionDeriveResponse{
 		AccountIdentifier: &types.AccountIdentifier{
-			Address: addr.EncodeAddress(),
+			Address: addr,
 		},
+		Metadata: metadata,
 	}, nil
 }
 
 // estimateSize returns the estimated size of a transaction in vBytes.
-func (s *ConstructionAPIService) estimateSize(operations []*types.Operation) float64 {
+// Inputs are assumed to be P2WPKH (or P2PKH if segwit is not active).
+func (s *ConstructionAPIService) estimateSize(
+	operations []*types.Operation,
+	segwit bool,
+) float64 {
 	size := bitcoin.TransactionOverhead
+	inputSize := bitcoin.InputSize
+	if !segwit {
+		size = bitcoin.LegacyTransactionOverhead
+		inputSize = bitcoin.LegacyInputSize
+	}
+
 	for _, operation := range operations {
 		switch operation.Type {
 		case bitcoin.InputOpType:
-			size += bitcoin.InputSize
+			size += inputSize
 		case bitcoin.OutputOpType:
 			size += bitcoin.OutputOverhead
-			addr, err := btcutil.DecodeAddress(operation.Account.Address, s.config.Params)
-			if err != nil {
-				size += bitcoin.P2PKHScriptPubkeySize
-				continue
-			}
-
-			script, err := txscript.PayToAddrScript(addr)
+			script, err := s.outputScript(operation.Account.Address)
 			if err != nil {
 				size += bitcoin.P2PKHScriptPubkeySize
 				continue
@@ -114,6 +156,142 @@ func (s *ConstructionAPIService) estimateSize(operations []*types.Operation) flo
 	return float64(size)
 }
 
+// outputScript returns the script of an output paying
+// address on the network.
+func (s *ConstructionAPIService) outputScript(address string) ([]byte, error) {
+	addr, err := bitcoin.DecodeAddress(address, s.config.Params)
+	if err != nil {
+		return nil, err
+	}
+
+	return txscript.PayToAddrScript(addr)
+}
+
+// checkRelayPolicy returns an error if the transaction described
+// by operations (of size in vbytes) would be rejected by the
+// mempool of the node: if an output is dust (i.e. dust change)
+// or otherwise non-standard, or if it pays less than the minimum
+// relay fee (see bitcoin.RelayPolicy). Outputs paying addresses
+// that can't be decoded are left to /construction/payloads.
+func (s *ConstructionAPIService) checkRelayPolicy(
+	operations []*types.Operation,
+	size float64,
+) *types.Error {
+	policy := bitcoin.NewRelayPolicy(s.config.Params)
+	fee := new(big.Int)
+	violations := []string{}
+	for _, operation := range operations {
+		if operation.Amount == nil {
+			continue
+		}
+
+		value, err := types.AmountValue(operation.Amount)
+		if err != nil {
+			return wrapErr(ErrUnclearIntent, err)
+		}
+
+		// Inputs have negative amounts.
+		fee.Sub(fee, value)
+		if operation.Type != bitcoin.OutputOpType || operation.Account == nil {
+			continue
+		}
+
+		pkScript, err := s.outputScript(operation.Account.Address)
+		if err != nil {
+			continue
+		}
+
+		txOut := &wire.TxOut{
+			Value:    value.Int64(),
+			PkScript: pkScript,
+		}
+		if err := policy.CheckOutput(txOut); err != nil {
+			violations = append(violations, fmt.Sprintf(
+				"operation %d: %s",
+				operation.OperationIdentifier.Index,
+				err.Error(),
+			))
+		}
+	}
+
+	if len(violations) > 0 {
+		return wrapErr(
+			ErrNonStandardOutput,
+			errors.New(strings.Join(violations, "; ")),
+		)
+	}
+
+	if !fee.IsInt64() {
+		return wrapErr(ErrUnclearIntent, fmt.Errorf("fee %s overflows", fee.String()))
+	}
+
+	if err := policy.CheckFee(fee.Int64(), int64(math.Ceil(size))); err != nil {
+		return wrapErr(ErrFeeBelowRelayFee, err)
+	}
+
+	return nil
+}
+
+// checkMoneyRange returns an error if the amount of an
+// operation is out of the money range of the network (see
+// bitcoin.MoneyRange). Inputs have negative amounts, so the
+// magnitude of each amount is checked.
+func (s *ConstructionAPIService) checkMoneyRange(operations []*types.Operation) error {
+	for _, operation := range operations {
+		if operation.Amount == nil {
+			continue
+		}
+
+		value, err := types.AmountValue(operation.Amount)
+		if err != nil {
+			return fmt.Errorf("%w: operation %d", err, operation.OperationIdentifier.Index)
+		}
+
+		value.Abs(value)
+		if !value.IsInt64() {
+			return fmt.Errorf(
+				"%w: operation %d: %s overflows",
+				bitcoin.ErrMoneyOutOfRange,
+				operation.OperationIdentifier.Index,
+				operation.Amount.Value,
+			)
+		}
+
+		if err := bitcoin.MoneyRange(s.config.Params, value.Int64()); err != nil {
+			return fmt.Errorf("%w: operation %d", err, operation.OperationIdentifier.Index)
+		}
+	}
+
+	return nil
+}
+
+// checkRecipient returns an error if address can't be paid
+// by an output built from it: addresses of other registered
+// networks and cold staking addresses (whose scripts need
+// both a staker and an owner). Addresses that can't be
+// classified are left to bitcoin.DecodeAddress.
+func (s *ConstructionAPIService) checkRecipient(address string) error {
+	classification, err := bitcoin.ClassifyAddress(address)
+	if err != nil {
+		return nil
+	}
+
+	if classification.Params.Net != s.config.Params.Net {
+		return fmt.Errorf(
+			"%w: %s is an address of %s",
+			bitcoin.ErrAddressWrongNetwork,
+			address,
+			classification.Params.Name,
+		)
+	}
+
+	if classification.Class == bitcoin.P2CSAddressClass {
+		return fmt.Errorf("%s is a cold staking address", address)
+	}
+
+	return nil
+}
+
 // ConstructionPreprocess implements the /construction/preprocess
 // endpoint.
 func (s *ConstructionAPIService) ConstructionPreprocess(
@@ -143,6 +321,10 @@ func (s *ConstructionAPIService) ConstructionPreprocess(
 		return nil, wrapErr(ErrUnclearIntent, err)
 	}
 
+	if err := s.checkMoneyRange(request.Operations); err != nil {
+		return nil, wrapErr(ErrUnclearIntent, err)
+	}
+
 	coins := make([]*types.Coin, len(matches[0].Operations))
 	for i, input := range matches[0].Operations {
 		if input.CoinChange == nil {
@@ -155,15 +337,52 @@ func (s *ConstructionAPIService) ConstructionPreprocess(
 		}
 	}
 
+	var metadata preprocessMetadata
+	if err := types.UnmarshalMap(request.Metadata, &metadata); err != nil {
+		return nil, wrapErr(ErrUnableToParseIntermediateResult, err)
+	}
+
+	segwit, _, rErr := segwitActive(ctx, s.config, s.client)
+	if rErr != nil {
+		return nil, rErr
+	}
+
+	estimatedSize := s.estimateSize(request.Operations, segwit)
+	if rErr := s.checkRelayPolicy(request.Operations, estimatedSize); rErr != nil {
+		return nil, rErr
+	}
+
+	var flowID string
+	if metadata.PersistFlow {
+		if s.config.Mode != configuration.Online {
+			return nil, wrapErr(
+				ErrUnavailableOffline,
+				errors.New("construction flows are only persisted in online mode"),
+			)
+		}
+
+		flowID, err = s.i.CreateConstructionFlow(ctx)
+		if err != nil {
+			return nil, wrapErr(ErrUnableToStoreConstructionFlow, err)
+		}
+	}
+
 	options, err := types.MarshalMap(&preprocessOptions{
 		Coins:         coins,
-		EstimatedSize: s.estimateSize(request.Operations),
+		EstimatedSize: estimatedSize,
 		FeeMultiplier: request.SuggestedFeeMultiplier,
+		FlowID:        flowID,
 	})
 	if err != nil {
 		return nil, wrapErr(ErrUnableToParseIntermediateResult, err)
 	}
 
+	if rErr := s.updateFlow(ctx, flowID, func(flow *utils.ConstructionFlow) {
+		flow.Options = options
+	}); rErr != nil {
+		return nil, rErr
+	}
+
 	return &types.ConstructionPreprocessResponse{
 		Options: options,
 	}, nil
@@ -183,37 +402,44 @@ func (s *ConstructionAPIService) ConstructionMetadata(
 		return nil, wrapErr(ErrUnableToParseIntermediateResult, err)
 	}
 
-	// Determine feePerKB and ensure it is not below the minimum fee
-	// relay rate.
-	feePerKB, err := s.client.SuggestedFeeRate(ctx, defaultConfirmationTarget)
+	// Determine the fee rate (blended from the configured
+	// sources) and ensure it is not below the fee floor.
+	estimate, err := estimateFeeRate(ctx, s.config, s.client, s.i)
 	if err != nil {
 		return nil, wrapErr(ErrCouldNotGetFeeRate, err)
 	}
+	satoshisPerB := estimate.FeeRate
 	if options.FeeMultiplier != nil {
-		feePerKB *= *options.FeeMultiplier
+		satoshisPerB *= *options.FeeMultiplier
 	}
-	if feePerKB < bitcoin.MinFeeRate {
-		feePerKB = bitcoin.MinFeeRate
+	if satoshisPerB < estimate.Floor {
+		satoshisPerB = estimate.Floor
 	}
 
 	// Calculated the estimated fee in Satoshis
-	satoshisPerB := (feePerKB * float64(bitcoin.SatoshisInBitcoin)) / bytesInKb
 	estimatedFee := satoshisPerB * options.EstimatedSize
-	suggestedFee := &types.Amount{
-		Value:    fmt.Sprintf("%d", int64(estimatedFee)),
-		Currency: s.config.Currency,
-	}
+	suggestedFee := bitcoin.Amount(int64(estimatedFee), s.config.Currency)
 
 	scripts, err := s.i.GetScriptPubKeys(ctx, options.Coins)
 	if err != nil {
 		return nil, wrapErr(ErrScriptPubKeysMissing, err)
 	}
 
-	metadata, err := types.MarshalMap(&constructionMetadata{ScriptPubKeys: scripts})
+	metadata, err := types.MarshalMap(&constructionMetadata{
+		ScriptPubKeys: scripts,
+		FlowID:        options.FlowID,
+	})
 	if err != nil {
 		return nil, wrapErr(ErrUnableToParseIntermediateResult, err)
 	}
 
+	if rErr := s.updateFlow(ctx, options.FlowID, func(flow *utils.ConstructionFlow) {
+		flow.Metadata = metadata
+		flow.SuggestedFee = []*types.Amount{suggestedFee}
+	}); rErr != nil {
+		return nil, rErr
+	}
+
 	return &types.ConstructionMetadataResponse{
 		Metadata:     metadata,
 		SuggestedFee: []*types.Amount{suggestedFee},
@@ -225,6 +451,21 @@ func (s *ConstructionAPIService) ConstructionPayloads(
 	ctx context.Context,
 	request *types.ConstructionPayloadsRequest,
 ) (*types.ConstructionPayloadsResponse, *types.Error) {
+	segwit, _, rErr := segwitActive(ctx, s.config, s.client)
+	if rErr != nil {
+		return nil, rErr
+	}
+
+	// Only P2WPKH inputs can be signed (and outputs
+	// to witness programs can be spent by anyone
+	// before segwit is active).
+	if !segwit {
+		return nil, wrapErr(
+			ErrSegwitNotActivated,
+			errors.New("unable to construct P2WPKH transaction"),
+		)
+	}
+
 	descriptions := &parser.Descriptions{
 		OperationDescriptions: []*parser.OperationDescription{
 			{
@@ -261,6 +502,10 @@ func (s *ConstructionAPIService) ConstructionPayloads(
 		return nil, wrapErr(ErrUnclearIntent, err)
 	}
 
+	if err := s.checkMoneyRange(request.Operations); err != nil {
+		return nil, wrapErr(ErrUnclearIntent, err)
+	}
+
 	tx := wire.NewMsgTx(wire.TxVersion)
 	for _, input := range matches[0].Operations {
 		if input.CoinChange == nil {
@@ -282,8 +527,14 @@ func (s *ConstructionAPIService) ConstructionPayloads(
 		})
 	}
 
+	policy := bitcoin.NewRelayPolicy(s.config.Params)
+	violations := []string{}
 	for i, output := range matches[1].Operations {
-		addr, err := btcutil.DecodeAddress(output.Account.Address, s.config.Params)
+		if err := s.checkRecipient(output.Account.Address); err != nil {
+			return nil, wrapErr(ErrUnableToDecodeAddress, err)
+		}
+
+		addr, err := bitcoin.DecodeAddress(output.Account.Address, s.config.Params)
 		if err != nil {
 			return nil, wrapErr(ErrUnableToDecodeAddress, fmt.Errorf(
 				"%w unable to decode address %s",
@@ -301,10 +552,29 @@ func (s *ConstructionAPIService) ConstructionPayloads(
 			)
 		}
 
-		tx.AddTxOut(&wire.TxOut{
+		txOut := &wire.TxOut{
 			Value:    matches[1].Amounts[i].Int64(),
 			PkScript: pkScript,
-		})
+		}
+
+		// All outputs are checked, so that clients learn
+		// about every violation before collecting signatures.
+		if err := policy.CheckOutput(txOut); err != nil {
+			violations = append(violations, fmt.Sprintf(
+				"operation %d: %s",
+				output.OperationIdentifier.Index,
+				err.Error(),
+			))
+		}
+
+		tx.AddTxOut(txOut)
+	}
+
+	if len(violations) > 0 {
+		return nil, wrapErr(
+			ErrNonStandardOutput,
+			errors.New(strings.Join(violations, "; ")),
+		)
 	}
 
 	// Create Signing Payloads (must be done after entire tx is constructed
@@ -375,17 +645,207 @@ func (s *ConstructionAPIService) ConstructionPayloads(
 		ScriptPubKeys:  metadata.ScriptPubKeys,
 		InputAmounts:   inputAmounts,
 		InputAddresses: inputAddresses,
+		FlowID:         metadata.FlowID,
+		networkBinding: s.networkBinding(),
 	})
 	if err != nil {
 		return nil, wrapErr(ErrUnableToParseIntermediateResult, err)
 	}
 
+	unsignedTx := hex.EncodeToString(rawTx)
+	if rErr := s.updateFlow(ctx, metadata.FlowID, func(flow *utils.ConstructionFlow) {
+		flow.UnsignedTransaction = unsignedTx
+		flow.Payloads = payloads
+	}); rErr != nil {
+		return nil, rErr
+	}
+
 	return &types.ConstructionPayloadsResponse{
-		UnsignedTransaction: hex.EncodeToString(rawTx),
+		UnsignedTransaction: unsignedTx,
 		Payloads:            payloads,
 	}, nil
 }
 
+// updateFlow persists a step of the construction flow with
+// flowID (if any). Flows are only persisted in online mode
+// (where the indexer is available).
+func (s *ConstructionAPIService) updateFlow(
+	ctx context.Context,
+	flowID string,
+	update func(*utils.ConstructionFlow),
+) *types.Error {
+	if len(flowID) == 0 || s.config.Mode != configuration.Online {
+		return nil
+	}
+
+	if err := s.i.UpdateConstructionFlow(ctx, flowID, update); err != nil {
+		return wrapErr(ErrUnableToStoreConstructionFlow, err)
+	}
+
+	return nil
+}
+
+// segwitActive returns true if segwit is active on the network
+// (and the deployments reported by the node in online mode). In
+// offline mode (or if the node does not report segwit, which
+// predates its deployment being tracked), config.Segwit is used.
+func segwitActive(
+	ctx context.Context,
+	config *configuration.Configuration,
+	client Client,
+) (bool, map[string]bool, *types.Error) {
+	if config.Mode != configuration.Online {
+		return config.Segwit, nil, nil
+	}
+
+	info, err := client.GetBlockchainInfo(ctx)
+	if err != nil {
+		return false, nil, wrapErr(ErrBitcoind, err)
+	}
+
+	deployments := make(map[string]bool, len(info.Softforks))
+	for name, softfork := range info.Softforks {
+		deployments[name] = softfork.Active
+	}
+
+	active, ok := deployments[bitcoin.SegwitDeployment]
+	if !ok {
+		active = config.Segwit
+	}
+
+	return active, deployments, nil
+}
+
+// checkMempoolChain returns an error if transaction (hex-encoded)
+// spends outputs of unconfirmed transactions such that it would
+// have more ancestors in the mempool (or one of its ancestors more
+// descendants) than the configured limits (the node would reject
+// it with "too-long-mempool-chain").
+func (s *ConstructionAPIService) checkMempoolChain(
+	ctx context.Context,
+	transaction string,
+) *types.Error {
+	if s.config.MaxAncestors == 0 && s.config.MaxDescendants == 0 {
+		return nil
+	}
+
+	decodedCoreTx, err := hex.DecodeString(transaction)
+	if err != nil {
+		return wrapErr(
+			ErrUnableToParseIntermediateResult,
+			fmt.Errorf("%w transaction cannot be decoded", err),
+		)
+	}
+
+	var tx wire.MsgTx
+	if err := tx.Deserialize(bytes.NewReader(decodedCoreTx)); err != nil {
+		return wrapErr(
+			ErrUnableToParseIntermediateResult,
+			fmt.Errorf("%w unable to deserialize tx", err),
+		)
+	}
+
+	queue := make([]string, 0, len(tx.TxIn))
+	for _, input := range tx.TxIn {
+		queue = append(queue, input.PreviousOutPoint.Hash.String())
+	}
+
+	// Parents can share ancestors, so the ancestors of tx
+	// are the distinct ancestors of all parents (found by
+	// walking the parents of each entry in the mempool).
+	// The ancestor count of each parent is a lower bound
+	// if the node does not report the parents of entries.
+	checked := map[string]struct{}{}
+	distinct, ancestors := int64(0), int64(0)
+	for len(queue) > 0 {
+		hash := queue[0]
+		queue = queue[1:]
+		if _, ok := checked[hash]; ok {
+			continue
+		}
+		checked[hash] = struct{}{}
+
+		entry, err := s.client.MempoolEntry(ctx, hash)
+		if errors.Is(err, bitcoin.ErrTransactionNotInMempool) {
+			continue
+		}
+		if err != nil {
+			return wrapErr(ErrBitcoind, err)
+		}
+
+		distinct++
+		if distinct > ancestors {
+			ancestors = distinct
+		}
+		if entry.AncestorCount > ancestors {
+			ancestors = entry.AncestorCount
+		}
+
+		// tx and its ancestors count towards the limit.
+		if s.config.MaxAncestors > 0 && ancestors+1 > s.config.MaxAncestors {
+			return wrapErr(ErrMempoolChainLimit, fmt.Errorf(
+				"transaction has at least %d ancestors in the mempool (limit is %d including the transaction)",
+				ancestors,
+				s.config.MaxAncestors,
+			))
+		}
+
+		// tx is a descendant of each of its ancestors.
+		if s.config.MaxDescendants > 0 && entry.DescendantCount+1 > s.config.MaxDescendants {
+			return wrapErr(ErrMempoolChainLimit, fmt.Errorf(
+				"%s has %d descendants in the mempool (limit is %d including the transaction)",
+				hash,
+				entry.DescendantCount,
+				s.config.MaxDescendants,
+			))
+		}
+
+		queue = append(queue, entry.Depends...)
+	}
+
+	return nil
+}
+
+// networkBinding returns the networkBinding of
+// the network we are configured for.
+func (s *ConstructionAPIService) networkBinding() networkBinding {
+	binding := networkBinding{
+		NetworkIdentifier: s.config.Network,
+	}
+	if s.config.GenesisBlockIdentifier != nil {
+		binding.GenesisHash = s.config.GenesisBlockIdentifier.Hash
+	}
+
+	return binding
+}
+
+// verifyNetworkBinding returns an error if a transaction
+// was not constructed for the network we are configured for.
+func (s *ConstructionAPIService) verifyNetworkBinding(binding networkBinding) error {
+	if binding.NetworkIdentifier == nil {
+		return errors.New("transaction is not bound to a network")
+	}
+
+	expected := s.networkBinding()
+	if types.Hash(binding.NetworkIdentifier) != types.Hash(expected.NetworkIdentifier) {
+		return fmt.Errorf(
+			"transaction constructed for network %s but configured for %s",
+			types.PrintStruct(binding.NetworkIdentifier),
+			types.PrintStruct(expected.NetworkIdentifier),
+		)
+	}
+
+	if binding.GenesisHash != expected.GenesisHash {
+		return fmt.Errorf(
+			"transaction constructed for genesis block %s but configured for %s",
+			binding.GenesisHash,
+			expected.GenesisHash,
+		)
+	}
+
+	return nil
+}
+
 func normalizeSignature(signature []byte) []byte {
 	sig := btcec.Signature{ // signature is in form of R || S
 		R: new(big.Int).SetBytes(signature[:32]),
@@ -401,6 +861,19 @@ func (s *ConstructionAPIService) ConstructionCombine(
 	ctx context.Context,
 	request *types.ConstructionCombineRequest,
 ) (*types.ConstructionCombineResponse, *types.Error) {
+	segwit, _, rErr := segwitActive(ctx, s.config, s.client)
+	if rErr != nil {
+		return nil, rErr
+	}
+
+	// Signatures are only added as witness data.
+	if !segwit {
+		return nil, wrapErr(
+			ErrSegwitNotActivated,
+			errors.New("unable to add witness signatures"),
+		)
+	}
+
 	decodedTx, err := hex.DecodeString(request.UnsignedTransaction)
 	if err != nil {
 		return nil, wrapErr(
@@ -417,6 +890,10 @@ func (s *ConstructionAPIService) ConstructionCombine(
 		)
 	}
 
+	if err := s.verifyNetworkBinding(unsigned.networkBinding); err != nil {
+		return nil, wrapErr(ErrNetworkMismatch, err)
+	}
+
 	decodedCoreTx, err := hex.DecodeString(unsigned.Transaction)
 	if err != nil {
 		return nil, wrapErr(
@@ -467,8 +944,10 @@ func (s *ConstructionAPIService) ConstructionCombine(
 	}
 
 	rawTx, err := json.Marshal(&signedTransaction{
-		Transaction:  hex.EncodeToString(buf.Bytes()),
-		InputAmounts: unsigned.InputAmounts,
+		Transaction:    hex.EncodeToString(buf.Bytes()),
+		InputAmounts:   unsigned.InputAmounts,
+		FlowID:         unsigned.FlowID,
+		networkBinding: unsigned.networkBinding,
 	})
 	if err != nil {
 		return nil, wrapErr(
@@ -477,51 +956,70 @@ func (s *ConstructionAPIService) ConstructionCombine(
 		)
 	}
 
+	signedTx := hex.EncodeToString(rawTx)
+	if rErr := s.updateFlow(ctx, unsigned.FlowID, func(flow *utils.ConstructionFlow) {
+		flow.SignedTransaction = signedTx
+	}); rErr != nil {
+		return nil, rErr
+	}
+
 	return &types.ConstructionCombineResponse{
-		SignedTransaction: hex.EncodeToString(rawTx),
+		SignedTransaction: signedTx,
 	}, nil
 }
 
-// ConstructionHash implements the /construction/hash endpoint.
-func (s *ConstructionAPIService) ConstructionHash(
-	ctx context.Context,
-	request *types.ConstructionHashRequest,
-) (*types.TransactionIdentifierResponse, *types.Error) {
-	decodedTx, err := hex.DecodeString(request.SignedTransaction)
+// HashConstructionTransaction returns the identifiers of an unsigned
+// (returned by /construction/payloads) or signed (returned by
+// /construction/combine) transaction. The TxID is the hash returned
+// by /construction/hash. Identifiers only depend on the serialized
+// transaction (not on how its intermediate JSON is formatted).
+func HashConstructionTransaction(
+	transaction string,
+	signed bool,
+) (*bitcoin.TransactionHashes, error) {
+	decodedTx, err := hex.DecodeString(transaction)
 	if err != nil {
-		return nil, wrapErr(
-			ErrUnableToParseIntermediateResult,
-			fmt.Errorf("%w signed transaction cannot be decoded", err),
-		)
+		return nil, fmt.Errorf("%w transaction cannot be decoded", err)
 	}
 
-	var signed signedTransaction
-	if err := json.Unmarshal(decodedTx, &signed); err != nil {
-		return nil, wrapErr(
-			ErrUnableToParseIntermediateResult,
-			fmt.Errorf("%w unable to unmarshal signed bitcoin transaction", err),
-		)
+	var rawTx string
+	if signed {
+		var tx signedTransaction
+		if err := json.Unmarshal(decodedTx, &tx); err != nil {
+			return nil, fmt.Errorf("%w unable to unmarshal signed bitcoin transaction", err)
+		}
+
+		rawTx = tx.Transaction
+	} else {
+		var tx unsignedTransaction
+		if err := json.Unmarshal(decodedTx, &tx); err != nil {
+			return nil, fmt.Errorf("%w unable to unmarshal bitcoin transaction", err)
+		}
+
+		rawTx = tx.Transaction
 	}
 
-	bytesTx, err := hex.DecodeString(signed.Transaction)
+	bytesTx, err := hex.DecodeString(rawTx)
 	if err != nil {
-		return nil, wrapErr(
-			ErrUnableToParseIntermediateResult,
-			fmt.Errorf("%w unable to decode hex transaction", err),
-		)
+		return nil, fmt.Errorf("%w unable to decode hex transaction", err)
 	}
 
-	tx, err := btcutil.NewTxFromBytes(bytesTx)
+	return bitcoin.HashTransaction(bytesTx)
+}
+
+// ConstructionHash implements the /construction/hash endpoint.
+func (s *ConstructionAPIService) ConstructionHash(
+	ctx context.Context,
+	request *types.ConstructionHashRequest,
+) (*types.TransactionIdentifierResponse, *types.Error) {
+	hashes, err := HashConstructionTransaction(request.SignedTransaction, true)
 	if err != nil {
-		return nil, wrapErr(
-			ErrUnableToParseIntermediateResult,
-			fmt.Errorf("%w unable to parse transaction", err),
-		)
+		return nil, wrapErr(ErrUnableToParseIntermediateResult, err)
 	}
 
 	return &types.TransactionIdentifierResponse{
 		TransactionIdentifier: &types.TransactionIdentifier{
-			Hash: tx.Hash().String(),
+			Hash: hashes.TxID,
 		},
 	}, nil
 }
@@ -545,6 +1043,10 @@ func (s *ConstructionAPIService) parseUnsignedTransaction(
 		)
 	}
 
+	if err := s.verifyNetworkBinding(unsigned.networkBinding); err != nil {
+		return nil, wrapErr(ErrNetworkMismatch, err)
+	}
+
 	decodedCoreTx, err := hex.DecodeString(unsigned.Transaction)
 	if err != nil {
 		return nil, wrapErr(
@@ -609,13 +1111,14 @@ func (s *ConstructionAPIService) parseUnsignedTransaction(
 			Account: &types.AccountIdentifier{
 				Address: addr.String(),
 			},
-			Amount: &types.Amount{
-				Value:    strconv.FormatInt(output.Value, 10),
-				Currency: s.config.Currency,
-			},
+			Amount: bitcoin.Amount(output.Value, s.config.Currency),
 		})
 	}
 
+	if err := s.checkMoneyRange(ops); err != nil {
+		return nil, wrapErr(ErrUnableToParseIntermediateResult, err)
+	}
+
 	return &types.ConstructionParseResponse{
 		Operations:               ops,
 		AccountIdentifierSigners: []*types.AccountIdentifier{},
@@ -624,6 +1127,7 @@ func (s *ConstructionAPIService) parseUnsignedTransaction(
 
 func (s *ConstructionAPIService) parseSignedTransaction(
 	request *types.ConstructionParseRequest,
+	segwit bool,
 ) (*types.ConstructionParseResponse, *types.Error) {
 	decodedTx, err := hex.DecodeString(request.Transaction)
 	if err != nil {
@@ -657,6 +1161,13 @@ func (s *ConstructionAPIService) parseSignedTransaction(
 		)
 	}
 
+	if tx.HasWitness() && !segwit {
+		return nil, wrapErr(
+			ErrSegwitNotActivated,
+			errors.New("transaction has witness data"),
+		)
+	}
+
 	ops := []*types.Operation{}
 	signers := []*types.AccountIdentifier{}
 	for i, input := range tx.TxIn {
@@ -725,13 +1236,14 @@ func (s *ConstructionAPIService) parseSignedTransaction(
 			Account: &types.AccountIdentifier{
 				Address: addr.String(),
 			},
-			Amount: &types.Amount{
-				Value:    strconv.FormatInt(output.Value, 10),
-				Currency: s.config.Currency,
-			},
+			Amount: bitcoin.Amount(output.Value, s.config.Currency),
 		})
 	}
 
+	if err := s.checkMoneyRange(ops); err != nil {
+		return nil, wrapErr(ErrUnableToParseIntermediateResult, err)
+	}
+
 	return &types.ConstructionParseResponse{
 		Operations:               ops,
 		AccountIdentifierSigners: signers,
@@ -744,7 +1256,12 @@ func (s *ConstructionAPIService) ConstructionParse(
 	request *types.ConstructionParseRequest,
 ) (*types.ConstructionParseResponse, *types.Error) {
 	if request.Signed {
-		return s.parseSignedTransaction(request)
+		segwit, _, rErr := segwitActive(ctx, s.config, s.client)
+		if rErr != nil {
+			return nil, rErr
+		}
+
+		return s.parseSignedTransaction(request, segwit)
 	}
 
 	return s.parseUnsignedTransaction(request)
@@ -775,14 +1292,36 @@ func (s *ConstructionAPIService) ConstructionSubmit(
 		)
 	}
 
+	if err := s.verifyNetworkBinding(signed.networkBinding); err != nil {
+		return nil, wrapErr(ErrNetworkMismatch, err)
+	}
+
+	if rErr := s.checkMempoolChain(ctx, signed.Transaction); rErr != nil {
+		return nil, rErr
+	}
+
 	txHash, err := s.client.SendRawTransaction(ctx, signed.Transaction)
 	if err != nil {
 		return nil, wrapErr(ErrBitcoind, fmt.Errorf("%w unable to submit transaction", err))
 	}
 
+	transactionIdentifier := &types.TransactionIdentifier{
+		Hash: txHash,
+	}
+
+	// The transaction was already broadcast, so failing
+	// to store the flow does not fail the request.
+	if rErr := s.updateFlow(ctx, signed.FlowID, func(flow *utils.ConstructionFlow) {
+		flow.TransactionIdentifier = transactionIdentifier
+	}); rErr != nil {
+		utils.ExtractLogger(ctx, "construction").Warnw(
+			"unable to store construction flow",
+			"flow_id", signed.FlowID,
+			"error", types.PrintStruct(rErr),
+		)
+	}
+
 	return &types.TransactionIdentifierResponse{
-		TransactionIdentifier: &types.TransactionIdentifier{
-			Hash: txHash,
-		},
+		TransactionIdentifier: transactionIdentifier,
 	}, nil
 }
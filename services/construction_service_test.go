@@ -15,18 +15,31 @@
 package services
 
 import (
+	"bytes"
 	"context"
 	"encoding/hex"
+	"encoding/json"
+	"errors"
 	"testing"
 
 	"github.com/MNtank/rosetta-bitcoin/bitcoin"
 	"github.com/MNtank/rosetta-bitcoin/configuration"
 	mocks "github.com/MNtank/rosetta-bitcoin/mocks/services"
+	"github.com/MNtank/rosetta-bitcoin/utils"
 
+	"github.com/btcsuite/btcd/chaincfg/chainhash"
+	"github.com/btcsuite/btcd/wire"
 	"github.com/coinbase/rosetta-sdk-go/types"
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
 )
 
+// testnetGenesisBlockIdentifier is the genesis
+// block of testnet in the tests.
+var testnetGenesisBlockIdentifier = &types.BlockIdentifier{
+	Hash: "00000c7c73d8ce604178dae13f0fc6ec0be3275614366d44b1b4b5c6e238c60c",
+}
+
 func forceHexDecode(t *testing.T, s string) []byte {
 	b, err := hex.DecodeString(s)
 	if err != nil {
@@ -45,6 +58,14 @@ func forceMarshalMap(t *testing.T, i interface{}) map[string]interface{} {
 	return m
 }
 
+// segwitActiveInfo is returned by nodes
+// where segwit is active.
+var segwitActiveInfo = &bitcoin.BlockchainInfo{
+	Softforks: map[string]*bitcoin.Softfork{
+		bitcoin.SegwitDeployment: {Type: "buried", Active: true},
+	},
+}
+
 func TestConstructionService(t *testing.T) {
 	networkIdentifier = &types.NetworkIdentifier{
 		Network:    bitcoin.TestnetNetwork,
@@ -52,16 +73,25 @@ func TestConstructionService(t *testing.T) {
 	}
 
 	cfg := &configuration.Configuration{
-		Mode:     configuration.Online,
-		Network:  networkIdentifier,
-		Params:   bitcoin.TestnetParams,
-		Currency: bitcoin.TestnetCurrency,
+		Mode:                   configuration.Online,
+		Network:                networkIdentifier,
+		GenesisBlockIdentifier: testnetGenesisBlockIdentifier,
+		Params:                 bitcoin.TestnetParams,
+		Currency:               bitcoin.TestnetCurrency,
 	}
 
+	// The transactions of the test pay
+	// the upstream minimum relay fee.
+	settings := *bitcoin.TestnetChainParams
+	settings.RelayFees = &bitcoin.RelayFees{MinRelayTxFee: bitcoin.MinRelayFee}
+	assert.NoError(t, bitcoin.RegisterChainParams(&settings))
+	defer bitcoin.ResetRegistry()
+
 	mockIndexer := &mocks.Indexer{}
 	mockClient := &mocks.Client{}
 	servicer := NewConstructionAPIService(cfg, mockClient, mockIndexer)
 	ctx := context.Background()
+	mockClient.On("GetBlockchainInfo", ctx).Return(segwitActiveInfo, nil)
 
 	// Test Derive
 	publicKey := &types.PublicKey{
@@ -78,10 +108,34 @@ func TestConstructionService(t *testing.T) {
 	assert.Nil(t, err)
 	assert.Equal(t, &types.ConstructionDeriveResponse{
 		AccountIdentifier: &types.AccountIdentifier{
-			Address: "tb1qcqzmqzkswhfshzd8kedhmtvgnxax48z4fklhvm",
+			Address: "teuno1qcqzmqzkswhfshzd8kedhmtvgnxax48z4pnvvd3",
+		},
+		Metadata: map[string]interface{}{
+			"derivation_path": "m/44'/1'/0'/0/0",
 		},
 	}, deriveResponse)
 
+	// Derive the key at a position of a BIP44 wallet
+	deriveResponse, err = servicer.ConstructionDerive(ctx, &types.ConstructionDeriveRequest{
+		NetworkIdentifier: networkIdentifier,
+		PublicKey:         publicKey,
+		Metadata: map[string]interface{}{
+			"account": 2,
+			"change":  1,
+			"index":   7,
+		},
+	})
+	assert.Nil(t, err)
+	assert.Equal(t, "m/44'/1'/2'/1/7", deriveResponse.Metadata["derivation_path"])
+
+	deriveResponse, err = servicer.ConstructionDerive(ctx, &types.ConstructionDeriveRequest{
+		NetworkIdentifier: networkIdentifier,
+		PublicKey:         publicKey,
+		Metadata:          map[string]interface{}{"change": 2},
+	})
+	assert.Nil(t, deriveResponse)
+	assert.Equal(t, ErrUnableToDerive.Code, err.Code)
+
 	// Test Preprocess
 	ops := []*types.Operation{
 		{
@@ -90,7 +144,7 @@ func TestConstructionService(t *testing.T) {
 			},
 			Type: bitcoin.InputOpType,
 			Account: &types.AccountIdentifier{
-				Address: "tb1qcqzmqzkswhfshzd8kedhmtvgnxax48z4fklhvm",
+				Address: "teuno1qcqzmqzkswhfshzd8kedhmtvgnxax48z4pnvvd3",
 			},
 			Amount: &types.Amount{
 				Value:    "-1000000",
@@ -109,7 +163,7 @@ func TestConstructionService(t *testing.T) {
 			},
 			Type: bitcoin.OutputOpType,
 			Account: &types.AccountIdentifier{
-				Address: "tb1q3r8xjf0c2yazxnq9ey3wayelygfjxpfqjvj5v7",
+				Address: "teuno1q3r8xjf0c2yazxnq9ey3wayelygfjxpfq6fp0d5",
 			},
 			Amount: &types.Amount{
 				Value:    "954843",
@@ -122,7 +176,7 @@ func TestConstructionService(t *testing.T) {
 			},
 			Type: bitcoin.OutputOpType,
 			Account: &types.AccountIdentifier{
-				Address: "tb1qjsrjvk2ug872pdypp33fjxke62y7awpgefr6ua",
+				Address: "teuno1qjsrjvk2ug872pdypp33fjxke62y7awpg3vspah",
 			},
 			Amount: &types.Amount{
 				Value:    "44657",
@@ -168,7 +222,7 @@ func TestConstructionService(t *testing.T) {
 				RequiredSigs: 1,
 				Type:         "witness_v0_keyhash",
 				Addresses: []string{
-					"tb1qcqzmqzkswhfshzd8kedhmtvgnxax48z4fklhvm",
+					"teuno1qcqzmqzkswhfshzd8kedhmtvgnxax48z4pnvvd3",
 				},
 			},
 		},
@@ -239,7 +293,7 @@ func TestConstructionService(t *testing.T) {
 	}, metadataResponse)
 
 	// Test Payloads
-	unsignedRaw := "7b227472616e73616374696f6e223a2230313030303030303031376639636635306230326464353235386638306364356333343337333032653032376464313333363137326132306364633830333035633561353537343162313031303030303030303066666666666666663032646239313065303030303030303030303136303031343838636536393235663835313361323334633035633932326565393333663232313332333035323037316165303030303030303030303030313630303134393430373236353935633431666361306234383130633632393931616439643238396565623832383030303030303030222c227363726970745075624b657973223a5b7b2261736d223a22302063303035623030616430373564333062383961376236356237646164383839396261366139633535222c22686578223a223030313463303035623030616430373564333062383961376236356237646164383839396261366139633535222c2272657153696773223a312c2274797065223a227769746e6573735f76305f6b657968617368222c22616464726573736573223a5b227462317163717a6d717a6b7377686673687a64386b6564686d7476676e78617834387a34666b6c68766d225d7d5d2c22696e7075745f616d6f756e7473223a5b222d31303030303030225d2c22696e7075745f616464726573736573223a5b227462317163717a6d717a6b7377686673687a64386b6564686d7476676e78617834387a34666b6c68766d225d7d" // nolint
+	unsignedRaw := "7b227472616e73616374696f6e223a2230313030303030303031376639636635306230326464353235386638306364356333343337333032653032376464313333363137326132306364633830333035633561353537343162313031303030303030303066666666666666663032646239313065303030303030303030303136303031343838636536393235663835313361323334633035633932326565393333663232313332333035323037316165303030303030303030303030313630303134393430373236353935633431666361306234383130633632393931616439643238396565623832383030303030303030222c227363726970745075624b657973223a5b7b2261736d223a22302063303035623030616430373564333062383961376236356237646164383839396261366139633535222c22686578223a223030313463303035623030616430373564333062383961376236356237646164383839396261366139633535222c2272657153696773223a312c2274797065223a227769746e6573735f76305f6b657968617368222c22616464726573736573223a5b227465756e6f317163717a6d717a6b7377686673687a64386b6564686d7476676e78617834387a34706e76766433225d7d5d2c22696e7075745f616d6f756e7473223a5b222d31303030303030225d2c22696e7075745f616464726573736573223a5b227465756e6f317163717a6d717a6b7377686673687a64386b6564686d7476676e78617834387a34706e76766433225d2c226e6574776f726b5f6964656e746966696572223a7b22626c6f636b636861696e223a2245756e6f222c226e6574776f726b223a22546573746e657433227d2c2267656e657369735f68617368223a2230303030306337633733643863653630343137386461653133663066633665633062653332373536313433363664343462316234623563366532333863363063227d" // nolint
 	payloadsResponse, err := servicer.ConstructionPayloads(ctx, &types.ConstructionPayloadsRequest{
 		NetworkIdentifier: networkIdentifier,
 		Operations:        ops,
@@ -255,7 +309,7 @@ func TestConstructionService(t *testing.T) {
 			},
 			Type: bitcoin.InputOpType,
 			Account: &types.AccountIdentifier{
-				Address: "tb1qcqzmqzkswhfshzd8kedhmtvgnxax48z4fklhvm",
+				Address: "teuno1qcqzmqzkswhfshzd8kedhmtvgnxax48z4pnvvd3",
 			},
 			Amount: &types.Amount{
 				Value:    "-1000000",
@@ -275,7 +329,7 @@ func TestConstructionService(t *testing.T) {
 			},
 			Type: bitcoin.OutputOpType,
 			Account: &types.AccountIdentifier{
-				Address: "tb1q3r8xjf0c2yazxnq9ey3wayelygfjxpfqjvj5v7",
+				Address: "teuno1q3r8xjf0c2yazxnq9ey3wayelygfjxpfq6fp0d5",
 			},
 			Amount: &types.Amount{
 				Value:    "954843",
@@ -289,7 +343,7 @@ func TestConstructionService(t *testing.T) {
 			},
 			Type: bitcoin.OutputOpType,
 			Account: &types.AccountIdentifier{
-				Address: "tb1qjsrjvk2ug872pdypp33fjxke62y7awpgefr6ua",
+				Address: "teuno1qjsrjvk2ug872pdypp33fjxke62y7awpg3vspah",
 			},
 			Amount: &types.Amount{
 				Value:    "44657",
@@ -305,7 +359,7 @@ func TestConstructionService(t *testing.T) {
 			"7b98f8b77fa6ef34044f320073118033afdffbd3fd3f8423889d9e5953ff4a30",
 		),
 		AccountIdentifier: &types.AccountIdentifier{
-			Address: "tb1qcqzmqzkswhfshzd8kedhmtvgnxax48z4fklhvm",
+			Address: "teuno1qcqzmqzkswhfshzd8kedhmtvgnxax48z4pnvvd3",
 		},
 		SignatureType: types.Ecdsa,
 	}
@@ -327,7 +381,7 @@ func TestConstructionService(t *testing.T) {
 	}, parseUnsignedResponse)
 
 	// Test Combine
-	signedRaw := "7b227472616e73616374696f6e223a22303130303030303030303031303137663963663530623032646435323538663830636435633334333733303265303237646431333336313732613230636463383033303563356135353734316231303130303030303030306666666666666666303264623931306530303030303030303030313630303134383863653639323566383531336132333463303563393232656539333366323231333233303532303731616530303030303030303030303031363030313439343037323635393563343166636130623438313063363239393161643964323839656562383238303234373330343430323230323538373665633862396635316433343361356135366163353439633063383238303035656634356562653964613136366462363435633039313537323233663032323034636430386237323738613838383961383131333539313562636531306431656633626239326232313766383161306465376537396666623364666436616335303132313033323563396134323532373839623331646262333435346563363437653935313665376335393662636465326264356461373161363066616238363434653433383030303030303030222c22696e7075745f616d6f756e7473223a5b222d31303030303030225d7d" // nolint
+	signedRaw := "7b227472616e73616374696f6e223a22303130303030303030303031303137663963663530623032646435323538663830636435633334333733303265303237646431333336313732613230636463383033303563356135353734316231303130303030303030306666666666666666303264623931306530303030303030303030313630303134383863653639323566383531336132333463303563393232656539333366323231333233303532303731616530303030303030303030303031363030313439343037323635393563343166636130623438313063363239393161643964323839656562383238303234373330343430323230323538373665633862396635316433343361356135366163353439633063383238303035656634356562653964613136366462363435633039313537323233663032323034636430386237323738613838383961383131333539313562636531306431656633626239326232313766383161306465376537396666623364666436616335303132313033323563396134323532373839623331646262333435346563363437653935313665376335393662636465326264356461373161363066616238363434653433383030303030303030222c22696e7075745f616d6f756e7473223a5b222d31303030303030225d2c226e6574776f726b5f6964656e746966696572223a7b22626c6f636b636861696e223a2245756e6f222c226e6574776f726b223a22546573746e657433227d2c2267656e657369735f68617368223a2230303030306337633733643863653630343137386461653133663066633665633062653332373536313433363664343462316234623563366532333863363063227d" // nolint
 	combineResponse, err := servicer.ConstructionCombine(ctx, &types.ConstructionCombineRequest{
 		NetworkIdentifier:   networkIdentifier,
 		UnsignedTransaction: unsignedRaw,
@@ -358,7 +412,7 @@ func TestConstructionService(t *testing.T) {
 	assert.Equal(t, &types.ConstructionParseResponse{
 		Operations: parseOps,
 		AccountIdentifierSigners: []*types.AccountIdentifier{
-			{Address: "tb1qcqzmqzkswhfshzd8kedhmtvgnxax48z4fklhvm"},
+			{Address: "teuno1qcqzmqzkswhfshzd8kedhmtvgnxax48z4pnvvd3"},
 		},
 	}, parseSignedResponse)
 
@@ -397,3 +451,779 @@ func TestConstructionService(t *testing.T) {
 	mockClient.AssertExpectations(t)
 	mockIndexer.AssertExpectations(t)
 }
+
+func TestConstructionService_NetworkMismatch(t *testing.T) {
+	cfg := &configuration.Configuration{
+		Mode: configuration.Online,
+		Network: &types.NetworkIdentifier{
+			Network:    bitcoin.TestnetNetwork,
+			Blockchain: bitcoin.Blockchain,
+		},
+		GenesisBlockIdentifier: testnetGenesisBlockIdentifier,
+		Params:                 bitcoin.TestnetParams,
+		Currency:               bitcoin.TestnetCurrency,
+	}
+	mockIndexer := &mocks.Indexer{}
+	mockClient := &mocks.Client{}
+	servicer := NewConstructionAPIService(cfg, mockClient, mockIndexer)
+	ctx := context.Background()
+	mockClient.On("GetBlockchainInfo", ctx).Return(segwitActiveInfo, nil)
+
+	mainnetBinding := networkBinding{
+		NetworkIdentifier: &types.NetworkIdentifier{
+			Network:    bitcoin.MainnetNetwork,
+			Blockchain: bitcoin.Blockchain,
+		},
+		GenesisHash: bitcoin.MainnetGenesisBlockIdentifier.Hash,
+	}
+
+	// Combine a transaction constructed for mainnet
+	unsigned, err := json.Marshal(&unsignedTransaction{networkBinding: mainnetBinding})
+	assert.NoError(t, err)
+	combineResponse, rErr := servicer.ConstructionCombine(ctx, &types.ConstructionCombineRequest{
+		NetworkIdentifier:   cfg.Network,
+		UnsignedTransaction: hex.EncodeToString(unsigned),
+	})
+	assert.Nil(t, combineResponse)
+	assert.Equal(t, ErrNetworkMismatch.Code, rErr.Code)
+
+	// Submit a transaction constructed for a different genesis block
+	signed, err := json.Marshal(&signedTransaction{
+		networkBinding: networkBinding{
+			NetworkIdentifier: cfg.Network,
+			GenesisHash:       bitcoin.MainnetGenesisBlockIdentifier.Hash,
+		},
+	})
+	assert.NoError(t, err)
+	submitResponse, rErr := servicer.ConstructionSubmit(ctx, &types.ConstructionSubmitRequest{
+		NetworkIdentifier: cfg.Network,
+		SignedTransaction: hex.EncodeToString(signed),
+	})
+	assert.Nil(t, submitResponse)
+	assert.Equal(t, ErrNetworkMismatch.Code, rErr.Code)
+
+	// Submit a transaction that is not bound to any network
+	signed, err = json.Marshal(&signedTransaction{})
+	assert.NoError(t, err)
+	submitResponse, rErr = servicer.ConstructionSubmit(ctx, &types.ConstructionSubmitRequest{
+		NetworkIdentifier: cfg.Network,
+		SignedTransaction: hex.EncodeToString(signed),
+	})
+	assert.Nil(t, submitResponse)
+	assert.Equal(t, ErrNetworkMismatch.Code, rErr.Code)
+
+	mockClient.AssertExpectations(t)
+	mockIndexer.AssertExpectations(t)
+}
+
+func TestConstructionService_Flow(t *testing.T) {
+	cfg := &configuration.Configuration{
+		Mode: configuration.Online,
+		Network: &types.NetworkIdentifier{
+			Network:    bitcoin.TestnetNetwork,
+			Blockchain: bitcoin.Blockchain,
+		},
+		GenesisBlockIdentifier: testnetGenesisBlockIdentifier,
+		Params:                 bitcoin.TestnetParams,
+		Currency:               bitcoin.TestnetCurrency,
+	}
+	mockIndexer := &mocks.Indexer{}
+	mockClient := &mocks.Client{}
+	servicer := NewConstructionAPIService(cfg, mockClient, mockIndexer)
+	ctx := context.Background()
+	mockClient.On("GetBlockchainInfo", ctx).Return(segwitActiveInfo, nil)
+
+	// Each step of the flow is applied to
+	// the stored flow.
+	flow := &utils.ConstructionFlow{ID: "flow"}
+	mockIndexer.On("CreateConstructionFlow", ctx).Return("flow", nil).Once()
+	mockIndexer.On(
+		"UpdateConstructionFlow",
+		ctx,
+		"flow",
+		mock.Anything,
+	).Run(func(args mock.Arguments) {
+		args.Get(2).(func(*utils.ConstructionFlow))(flow)
+	}).Return(nil)
+
+	// Test Preprocess
+	coin := &types.Coin{
+		CoinIdentifier: &types.CoinIdentifier{
+			Identifier: "b14157a5c50503c8cd202a173613dd27e0027343c3d50cf85852dd020bf59c7f:1",
+		},
+		Amount: &types.Amount{
+			Value:    "-1000000",
+			Currency: bitcoin.TestnetCurrency,
+		},
+	}
+	ops := []*types.Operation{
+		{
+			OperationIdentifier: &types.OperationIdentifier{Index: 0},
+			Type:                bitcoin.InputOpType,
+			Account:             &types.AccountIdentifier{Address: "address"},
+			Amount:              coin.Amount,
+			CoinChange: &types.CoinChange{
+				CoinIdentifier: coin.CoinIdentifier,
+				CoinAction:     types.CoinSpent,
+			},
+		},
+	}
+	preprocessResponse, err := servicer.ConstructionPreprocess(
+		ctx,
+		&types.ConstructionPreprocessRequest{
+			NetworkIdentifier: cfg.Network,
+			Operations:        ops,
+			Metadata:          map[string]interface{}{"persist_flow": true},
+		},
+	)
+	assert.Nil(t, err)
+	options := forceMarshalMap(t, &preprocessOptions{
+		Coins:         []*types.Coin{coin},
+		EstimatedSize: float64(bitcoin.TransactionOverhead + bitcoin.InputSize),
+		FlowID:        "flow",
+	})
+	assert.Equal(t, options, preprocessResponse.Options)
+	assert.Equal(t, options, flow.Options)
+
+	// Test Metadata
+	scripts := []*bitcoin.ScriptPubKey{
+		{Hex: "0014c005b00ad075d30b89a7b65b7dad8899ba6a9c55"},
+	}
+	mockIndexer.On("GetScriptPubKeys", ctx, []*types.Coin{coin}).Return(scripts, nil).Once()
+	mockClient.On(
+		"SuggestedFeeRate",
+		ctx,
+		defaultConfirmationTarget,
+	).Return(
+		bitcoin.MinFeeRate,
+		nil,
+	).Once()
+	metadataResponse, err := servicer.ConstructionMetadata(ctx, &types.ConstructionMetadataRequest{
+		NetworkIdentifier: cfg.Network,
+		Options:           options,
+	})
+	assert.Nil(t, err)
+	assert.Equal(t, forceMarshalMap(t, &constructionMetadata{
+		ScriptPubKeys: scripts,
+		FlowID:        "flow",
+	}), metadataResponse.Metadata)
+	assert.Equal(t, metadataResponse.Metadata, flow.Metadata)
+	assert.Equal(t, metadataResponse.SuggestedFee, flow.SuggestedFee)
+
+	// Test Submit
+	signed, jsonErr := json.Marshal(&signedTransaction{
+		Transaction:    "signed",
+		FlowID:         "flow",
+		networkBinding: servicer.(*ConstructionAPIService).networkBinding(),
+	})
+	assert.NoError(t, jsonErr)
+	mockClient.On("SendRawTransaction", ctx, "signed").Return("hash", nil).Once()
+	submitResponse, err := servicer.ConstructionSubmit(ctx, &types.ConstructionSubmitRequest{
+		NetworkIdentifier: cfg.Network,
+		SignedTransaction: hex.EncodeToString(signed),
+	})
+	assert.Nil(t, err)
+	assert.Equal(t, &types.TransactionIdentifier{Hash: "hash"}, flow.TransactionIdentifier)
+	assert.Equal(t, flow.TransactionIdentifier, submitResponse.TransactionIdentifier)
+
+	// Steps that cannot be stored fail
+	// (unless already broadcast).
+	storeErr := errors.New("unable to store")
+	failingIndexer := &mocks.Indexer{}
+	failingIndexer.On("CreateConstructionFlow", ctx).Return("", storeErr).Once()
+	failingIndexer.On(
+		"UpdateConstructionFlow",
+		ctx,
+		"flow",
+		mock.Anything,
+	).Return(storeErr)
+	servicer = NewConstructionAPIService(cfg, mockClient, failingIndexer)
+	preprocessResponse, err = servicer.ConstructionPreprocess(
+		ctx,
+		&types.ConstructionPreprocessRequest{
+			NetworkIdentifier: cfg.Network,
+			Operations:        ops,
+			Metadata:          map[string]interface{}{"persist_flow": true},
+		},
+	)
+	assert.Nil(t, preprocessResponse)
+	assert.Equal(t, ErrUnableToStoreConstructionFlow.Code, err.Code)
+
+	mockClient.On("SendRawTransaction", ctx, "signed").Return("hash", nil).Once()
+	submitResponse, err = servicer.ConstructionSubmit(ctx, &types.ConstructionSubmitRequest{
+		NetworkIdentifier: cfg.Network,
+		SignedTransaction: hex.EncodeToString(signed),
+	})
+	assert.Nil(t, err)
+	assert.Equal(t, "hash", submitResponse.TransactionIdentifier.Hash)
+
+	// Flows can't be created in offline mode.
+	offlineCfg := *cfg
+	offlineCfg.Mode = configuration.Offline
+	servicer = NewConstructionAPIService(&offlineCfg, nil, nil)
+	preprocessResponse, err = servicer.ConstructionPreprocess(
+		ctx,
+		&types.ConstructionPreprocessRequest{
+			NetworkIdentifier: cfg.Network,
+			Operations:        ops,
+			Metadata:          map[string]interface{}{"persist_flow": true},
+		},
+	)
+	assert.Nil(t, preprocessResponse)
+	assert.Equal(t, ErrUnavailableOffline.Code, err.Code)
+
+	mockClient.AssertExpectations(t)
+	mockIndexer.AssertExpectations(t)
+	failingIndexer.AssertExpectations(t)
+}
+
+func TestConstructionService_SegwitNotActivated(t *testing.T) {
+	cfg := &configuration.Configuration{
+		Mode: configuration.Offline,
+		Network: &types.NetworkIdentifier{
+			Network:    bitcoin.TestnetNetwork,
+			Blockchain: bitcoin.Blockchain,
+		},
+		GenesisBlockIdentifier: testnetGenesisBlockIdentifier,
+		Params:                 bitcoin.TestnetParams,
+		Currency:               bitcoin.TestnetCurrency,
+		Segwit:                 false,
+	}
+	servicer := NewConstructionAPIService(cfg, nil, nil)
+	ctx := context.Background()
+
+	// Derive
+	publicKey := &types.PublicKey{
+		Bytes: forceHexDecode(
+			t,
+			"0325c9a4252789b31dbb3454ec647e9516e7c596bcde2bd5da71a60fab8644e438",
+		),
+		CurveType: types.Secp256k1,
+	}
+	deriveResponse, err := servicer.ConstructionDerive(ctx, &types.ConstructionDeriveRequest{
+		NetworkIdentifier: cfg.Network,
+		PublicKey:         publicKey,
+	})
+	assert.Nil(t, deriveResponse)
+	assert.Equal(t, ErrSegwitNotActivated.Code, err.Code)
+
+	// Preprocess estimates the size of inputs without witness data
+	amount := &types.Amount{Value: "-1000000", Currency: bitcoin.TestnetCurrency}
+	ops := []*types.Operation{
+		{
+			OperationIdentifier: &types.OperationIdentifier{Index: 0},
+			Type:                bitcoin.InputOpType,
+			Account:             &types.AccountIdentifier{Address: "address"},
+			Amount:              amount,
+			CoinChange: &types.CoinChange{
+				CoinIdentifier: &types.CoinIdentifier{
+					Identifier: "b14157a5c50503c8cd202a173613dd27e0027343c3d50cf85852dd020bf59c7f:1",
+				},
+				CoinAction: types.CoinSpent,
+			},
+		},
+	}
+	preprocessResponse, err := servicer.ConstructionPreprocess(
+		ctx,
+		&types.ConstructionPreprocessRequest{
+			NetworkIdentifier: cfg.Network,
+			Operations:        ops,
+		},
+	)
+	assert.Nil(t, err)
+	assert.Equal(
+		t,
+		float64(bitcoin.LegacyTransactionOverhead+bitcoin.LegacyInputSize),
+		preprocessResponse.Options["estimated_size"],
+	)
+
+	// Payloads
+	payloadsResponse, err := servicer.ConstructionPayloads(ctx, &types.ConstructionPayloadsRequest{
+		NetworkIdentifier: cfg.Network,
+		Operations:        ops,
+	})
+	assert.Nil(t, payloadsResponse)
+	assert.Equal(t, ErrSegwitNotActivated.Code, err.Code)
+
+	// Combine
+	combineResponse, err := servicer.ConstructionCombine(ctx, &types.ConstructionCombineRequest{
+		NetworkIdentifier: cfg.Network,
+	})
+	assert.Nil(t, combineResponse)
+	assert.Equal(t, ErrSegwitNotActivated.Code, err.Code)
+
+	// Parse a transaction with witness data
+	signed, jsonErr := json.Marshal(&signedTransaction{
+		Transaction: "010000000001017f9cf50b02dd5258f80cd5c3437302e027dd1336172a20cdc80305c5a55741b10100000000ffffffff02db910e000000000016001488ce6925f8513a234c05c922ee933f221323052071ae000000000000160014940726595c41fca0b4810c62991ad9d289eeb82802473044022025876ec8b9f51d343a5a56ac549c0c828005ef45ebe9da166db645c09157223f02204cd08b7278a8889a81135915bce10d1ef3bb92b217f81a0de7e79ffb3dfd6ac501210325c9a4252789b31dbb3454ec647e9516e7c596bcde2bd5da71a60fab8644e43800000000", // nolint
+	})
+	assert.NoError(t, jsonErr)
+	parseResponse, err := servicer.ConstructionParse(ctx, &types.ConstructionParseRequest{
+		NetworkIdentifier: cfg.Network,
+		Signed:            true,
+		Transaction:       hex.EncodeToString(signed),
+	})
+	assert.Nil(t, parseResponse)
+	assert.Equal(t, ErrSegwitNotActivated.Code, err.Code)
+
+	// In online mode, the deployment state
+	// reported by the node is used.
+	onlineCfg := *cfg
+	onlineCfg.Mode = configuration.Online
+	onlineCfg.Segwit = true
+	mockClient := &mocks.Client{}
+	servicer = NewConstructionAPIService(&onlineCfg, mockClient, &mocks.Indexer{})
+	mockClient.On("GetBlockchainInfo", ctx).Return(&bitcoin.BlockchainInfo{
+		Softforks: map[string]*bitcoin.Softfork{
+			bitcoin.SegwitDeployment: {Type: "bip9", Active: false},
+		},
+	}, nil).Once()
+	deriveResponse, err = servicer.ConstructionDerive(ctx, &types.ConstructionDeriveRequest{
+		NetworkIdentifier: cfg.Network,
+		PublicKey:         publicKey,
+	})
+	assert.Nil(t, deriveResponse)
+	assert.Equal(t, ErrSegwitNotActivated.Code, err.Code)
+
+	mockClient.AssertExpectations(t)
+}
+
+func TestConstructionService_MempoolChainLimit(t *testing.T) {
+	cfg := &configuration.Configuration{
+		Mode: configuration.Online,
+		Network: &types.NetworkIdentifier{
+			Network:    bitcoin.TestnetNetwork,
+			Blockchain: bitcoin.Blockchain,
+		},
+		GenesisBlockIdentifier: testnetGenesisBlockIdentifier,
+		Params:                 bitcoin.TestnetParams,
+		Currency:               bitcoin.TestnetCurrency,
+		MaxAncestors:           25,
+		MaxDescendants:         25,
+	}
+	mockIndexer := &mocks.Indexer{}
+	mockClient := &mocks.Client{}
+	servicer := NewConstructionAPIService(cfg, mockClient, mockIndexer)
+	ctx := context.Background()
+
+	bitcoinTransaction := "010000000001017f9cf50b02dd5258f80cd5c3437302e027dd1336172a20cdc80305c5a55741b10100000000ffffffff02db910e000000000016001488ce6925f8513a234c05c922ee933f221323052071ae000000000000160014940726595c41fca0b4810c62991ad9d289eeb82802473044022025876ec8b9f51d343a5a56ac549c0c828005ef45ebe9da166db645c09157223f02204cd08b7278a8889a81135915bce10d1ef3bb92b217f81a0de7e79ffb3dfd6ac501210325c9a4252789b31dbb3454ec647e9516e7c596bcde2bd5da71a60fab8644e43800000000" // nolint
+	parent := "b14157a5c50503c8cd202a173613dd27e0027343c3d50cf85852dd020bf59c7f"
+	signed, jsonErr := json.Marshal(&signedTransaction{
+		Transaction: bitcoinTransaction,
+		networkBinding: networkBinding{
+			NetworkIdentifier: cfg.Network,
+			GenesisHash:       cfg.GenesisBlockIdentifier.Hash,
+		},
+	})
+	assert.NoError(t, jsonErr)
+	request := &types.ConstructionSubmitRequest{
+		NetworkIdentifier: cfg.Network,
+		SignedTransaction: hex.EncodeToString(signed),
+	}
+
+	// The parent is confirmed
+	mockClient.On("MempoolEntry", ctx, parent).Return(
+		nil,
+		bitcoin.ErrTransactionNotInMempool,
+	).Once()
+	mockClient.On("SendRawTransaction", ctx, bitcoinTransaction).Return("hash", nil).Once()
+	submitResponse, err := servicer.ConstructionSubmit(ctx, request)
+	assert.Nil(t, err)
+	assert.Equal(t, "hash", submitResponse.TransactionIdentifier.Hash)
+
+	// The parent is unconfirmed (below the limits)
+	mockClient.On("MempoolEntry", ctx, parent).Return(&bitcoin.MempoolEntry{
+		AncestorCount:   24,
+		DescendantCount: 24,
+	}, nil).Once()
+	mockClient.On("SendRawTransaction", ctx, bitcoinTransaction).Return("hash", nil).Once()
+	submitResponse, err = servicer.ConstructionSubmit(ctx, request)
+	assert.Nil(t, err)
+	assert.Equal(t, "hash", submitResponse.TransactionIdentifier.Hash)
+
+	// The parent has too many ancestors
+	mockClient.On("MempoolEntry", ctx, parent).Return(&bitcoin.MempoolEntry{
+		AncestorCount:   25,
+		DescendantCount: 1,
+	}, nil).Once()
+	submitResponse, err = servicer.ConstructionSubmit(ctx, request)
+	assert.Nil(t, submitResponse)
+	assert.Equal(t, ErrMempoolChainLimit.Code, err.Code)
+	assert.True(t, err.Retriable)
+
+	// The parent has too many descendants
+	mockClient.On("MempoolEntry", ctx, parent).Return(&bitcoin.MempoolEntry{
+		AncestorCount:   1,
+		DescendantCount: 25,
+	}, nil).Once()
+	submitResponse, err = servicer.ConstructionSubmit(ctx, request)
+	assert.Nil(t, submitResponse)
+	assert.Equal(t, ErrMempoolChainLimit.Code, err.Code)
+
+	// The descendant limit is disabled
+	cfg.MaxDescendants = 0
+	mockClient.On("MempoolEntry", ctx, parent).Return(&bitcoin.MempoolEntry{
+		AncestorCount:   1,
+		DescendantCount: 100,
+	}, nil).Once()
+	mockClient.On("SendRawTransaction", ctx, bitcoinTransaction).Return("hash", nil).Once()
+	submitResponse, err = servicer.ConstructionSubmit(ctx, request)
+	assert.Nil(t, err)
+	assert.Equal(t, "hash", submitResponse.TransactionIdentifier.Hash)
+
+	mockClient.AssertExpectations(t)
+	mockIndexer.AssertExpectations(t)
+}
+
+func TestConstructionService_MempoolChainAncestors(t *testing.T) {
+	cfg := &configuration.Configuration{
+		Mode:         configuration.Online,
+		MaxAncestors: 4,
+	}
+	mockClient := &mocks.Client{}
+	servicer := NewConstructionAPIService(cfg, mockClient, nil).(*ConstructionAPIService)
+	ctx := context.Background()
+
+	hash := func(b byte) *chainhash.Hash {
+		var h chainhash.Hash
+		h[0] = b
+		return &h
+	}
+	tx := wire.NewMsgTx(wire.TxVersion)
+	tx.AddTxIn(wire.NewTxIn(wire.NewOutPoint(hash(1), 0), nil, nil))
+	tx.AddTxIn(wire.NewTxIn(wire.NewOutPoint(hash(2), 0), nil, nil))
+	var buf bytes.Buffer
+	assert.NoError(t, tx.Serialize(&buf))
+	transaction := hex.EncodeToString(buf.Bytes())
+
+	parent1, parent2 := hash(1).String(), hash(2).String()
+	grandparent1, grandparent2 := hash(3).String(), hash(4).String()
+
+	// The parents share an ancestor (3 distinct
+	// ancestors, although their counts sum to 4)
+	mockClient.On("MempoolEntry", ctx, parent1).Return(&bitcoin.MempoolEntry{
+		AncestorCount: 2,
+		Depends:       []string{grandparent1},
+	}, nil).Once()
+	mockClient.On("MempoolEntry", ctx, parent2).Return(&bitcoin.MempoolEntry{
+		AncestorCount: 2,
+		Depends:       []string{grandparent1},
+	}, nil).Once()
+	mockClient.On("MempoolEntry", ctx, grandparent1).Return(&bitcoin.MempoolEntry{
+		AncestorCount: 1,
+	}, nil).Once()
+	assert.Nil(t, servicer.checkMempoolChain(ctx, transaction))
+
+	// The parents have distinct ancestors (4 ancestors,
+	// although each parent only has 2)
+	mockClient.On("MempoolEntry", ctx, parent1).Return(&bitcoin.MempoolEntry{
+		AncestorCount: 2,
+		Depends:       []string{grandparent1},
+	}, nil).Once()
+	mockClient.On("MempoolEntry", ctx, parent2).Return(&bitcoin.MempoolEntry{
+		AncestorCount: 2,
+		Depends:       []string{grandparent2},
+	}, nil).Once()
+	mockClient.On("MempoolEntry", ctx, grandparent1).Return(&bitcoin.MempoolEntry{
+		AncestorCount: 1,
+	}, nil).Once()
+	mockClient.On("MempoolEntry", ctx, grandparent2).Return(&bitcoin.MempoolEntry{
+		AncestorCount: 1,
+	}, nil).Once()
+	err := servicer.checkMempoolChain(ctx, transaction)
+	assert.Equal(t, ErrMempoolChainLimit.Code, err.Code)
+
+	mockClient.AssertExpectations(t)
+}
+
+func TestConstructionService_NonStandardOutput(t *testing.T) {
+	params := *bitcoin.TestnetParams
+	params.RelayNonStdTxs = false
+	cfg := &configuration.Configuration{
+		Mode: configuration.Offline,
+		Network: &types.NetworkIdentifier{
+			Network:    bitcoin.TestnetNetwork,
+			Blockchain: bitcoin.Blockchain,
+		},
+		GenesisBlockIdentifier: testnetGenesisBlockIdentifier,
+		Params:                 &params,
+		Currency:               bitcoin.TestnetCurrency,
+		Segwit:                 true,
+	}
+	servicer := NewConstructionAPIService(cfg, nil, nil)
+	ctx := context.Background()
+
+	output := func(index int64, value string) *types.Operation {
+		return &types.Operation{
+			OperationIdentifier: &types.OperationIdentifier{Index: index},
+			Type:                bitcoin.OutputOpType,
+			Account: &types.AccountIdentifier{
+				Address: "teuno1q3r8xjf0c2yazxnq9ey3wayelygfjxpfq6fp0d5",
+			},
+			Amount: &types.Amount{Value: value, Currency: bitcoin.TestnetCurrency},
+		}
+	}
+	ops := []*types.Operation{
+		{
+			OperationIdentifier: &types.OperationIdentifier{Index: 0},
+			Type:                bitcoin.InputOpType,
+			Account: &types.AccountIdentifier{
+				Address: "teuno1qcqzmqzkswhfshzd8kedhmtvgnxax48z4pnvvd3",
+			},
+			Amount: &types.Amount{Value: "-1000000", Currency: bitcoin.TestnetCurrency},
+			CoinChange: &types.CoinChange{
+				CoinIdentifier: &types.CoinIdentifier{
+					Identifier: "b14157a5c50503c8cd202a173613dd27e0027343c3d50cf85852dd020bf59c7f:1",
+				},
+				CoinAction: types.CoinSpent,
+			},
+		},
+		output(1, "100"),
+		output(2, "954843"),
+		output(3, "250"),
+	}
+
+	// Every dust output is reported
+	payloadsResponse, err := servicer.ConstructionPayloads(ctx, &types.ConstructionPayloadsRequest{
+		NetworkIdentifier: cfg.Network,
+		Operations:        ops,
+	})
+	assert.Nil(t, payloadsResponse)
+	assert.Equal(t, ErrNonStandardOutput.Code, err.Code)
+	reason := err.Details["context"].(string)
+	assert.Contains(t, reason, "operation 1: non-standard output: value 100 is dust")
+	assert.Contains(t, reason, "operation 3: non-standard output: value 250 is dust")
+	assert.NotContains(t, reason, "operation 2")
+}
+
+func TestConstructionService_InvalidRecipient(t *testing.T) {
+	cfg := &configuration.Configuration{
+		Mode: configuration.Offline,
+		Network: &types.NetworkIdentifier{
+			Network:    bitcoin.TestnetNetwork,
+			Blockchain: bitcoin.Blockchain,
+		},
+		GenesisBlockIdentifier: testnetGenesisBlockIdentifier,
+		Params:                 bitcoin.TestnetParams,
+		Currency:               bitcoin.TestnetCurrency,
+		Segwit:                 true,
+	}
+	servicer := NewConstructionAPIService(cfg, nil, nil)
+	ctx := context.Background()
+
+	hash, err := hex.DecodeString("c398efa9c392ba6013c5e04ee729755ef7f58b32")
+	assert.NoError(t, err)
+	staking, err := bitcoin.NewStakingAddress(hash, bitcoin.TestnetParams)
+	assert.NoError(t, err)
+
+	tests := map[string]struct {
+		address string
+		reason  string
+	}{
+		"other network": {
+			address: "euno1qcqzmqzkswhfshzd8kedhmtvgnxax48z4eg7n8t",
+			reason:  "is an address of mainnet",
+		},
+		"cold staking": {
+			address: staking.EncodeAddress(),
+			reason:  "is a cold staking address",
+		},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			payloadsResponse, rErr := servicer.ConstructionPayloads(
+				ctx,
+				&types.ConstructionPayloadsRequest{
+					NetworkIdentifier: cfg.Network,
+					Operations: []*types.Operation{
+						{
+							OperationIdentifier: &types.OperationIdentifier{Index: 0},
+							Type:                bitcoin.InputOpType,
+							Account: &types.AccountIdentifier{
+								Address: "teuno1qcqzmqzkswhfshzd8kedhmtvgnxax48z4pnvvd3",
+							},
+							Amount: &types.Amount{Value: "-1000000", Currency: bitcoin.TestnetCurrency},
+							CoinChange: &types.CoinChange{
+								CoinIdentifier: &types.CoinIdentifier{
+									Identifier: "b14157a5c50503c8cd202a173613dd27e0027343c3d50cf85852dd020bf59c7f:1",
+								},
+								CoinAction: types.CoinSpent,
+							},
+						},
+						{
+							OperationIdentifier: &types.OperationIdentifier{Index: 1},
+							Type:                bitcoin.OutputOpType,
+							Account:             &types.AccountIdentifier{Address: test.address},
+							Amount:              &types.Amount{Value: "954843", Currency: bitcoin.TestnetCurrency},
+						},
+					},
+				},
+			)
+			assert.Nil(t, payloadsResponse)
+			assert.Equal(t, ErrUnableToDecodeAddress.Code, rErr.Code)
+			assert.Contains(t, rErr.Details["context"].(string), test.reason)
+		})
+	}
+}
+
+func TestConstructionService_MoneyRange(t *testing.T) {
+	cfg := &configuration.Configuration{
+		Mode: configuration.Offline,
+		Network: &types.NetworkIdentifier{
+			Network:    bitcoin.TestnetNetwork,
+			Blockchain: bitcoin.Blockchain,
+		},
+		GenesisBlockIdentifier: testnetGenesisBlockIdentifier,
+		Params:                 bitcoin.TestnetParams,
+		Currency:               bitcoin.TestnetCurrency,
+		Segwit:                 true,
+	}
+	servicer := NewConstructionAPIService(cfg, nil, nil)
+	ctx := context.Background()
+
+	for name, value := range map[string]string{
+		"exceeds max money": "-2100000000000001",
+		"overflows":         "-100000000000000000000",
+	} {
+		t.Run(name, func(t *testing.T) {
+			preprocessResponse, err := servicer.ConstructionPreprocess(
+				ctx,
+				&types.ConstructionPreprocessRequest{
+					NetworkIdentifier: cfg.Network,
+					Operations: []*types.Operation{
+						{
+							OperationIdentifier: &types.OperationIdentifier{Index: 0},
+							Type:                bitcoin.InputOpType,
+							Account: &types.AccountIdentifier{
+								Address: "teuno1qcqzmqzkswhfshzd8kedhmtvgnxax48z4pnvvd3",
+							},
+							Amount: &types.Amount{Value: value, Currency: bitcoin.TestnetCurrency},
+							CoinChange: &types.CoinChange{
+								CoinIdentifier: &types.CoinIdentifier{
+									Identifier: "b14157a5c50503c8cd202a173613dd27e0027343c3d50cf85852dd020bf59c7f:1",
+								},
+								CoinAction: types.CoinSpent,
+							},
+						},
+					},
+				},
+			)
+			assert.Nil(t, preprocessResponse)
+			assert.Equal(t, ErrUnclearIntent.Code, err.Code)
+			assert.Contains(t, err.Details["context"].(string), "amount out of money range")
+		})
+	}
+}
+
+func TestConstructionService_RelayPolicy(t *testing.T) {
+	// Testnet nodes relay non-standard outputs
+	// (and dust) by default. The network has no
+	// relay fees unless a test registers them.
+	params := bitcoin.CloneParams(bitcoin.TestnetParams)
+	params.RelayNonStdTxs = false
+	params.Net = wire.BitcoinNet(0xa1b2c3f9)
+
+	cfg := &configuration.Configuration{
+		Mode: configuration.Offline,
+		Network: &types.NetworkIdentifier{
+			Network:    bitcoin.TestnetNetwork,
+			Blockchain: bitcoin.Blockchain,
+		},
+		GenesisBlockIdentifier: testnetGenesisBlockIdentifier,
+		Params:                 params,
+		Currency:               bitcoin.TestnetCurrency,
+		Segwit:                 true,
+	}
+	servicer := NewConstructionAPIService(cfg, nil, nil)
+	ctx := context.Background()
+	address := "teuno1qcqzmqzkswhfshzd8kedhmtvgnxax48z4pnvvd3"
+
+	operations := func(outputs ...string) []*types.Operation {
+		ops := []*types.Operation{
+			{
+				OperationIdentifier: &types.OperationIdentifier{Index: 0},
+				Type:                bitcoin.InputOpType,
+				Account:             &types.AccountIdentifier{Address: address},
+				Amount:              &types.Amount{Value: "-1000000", Currency: bitcoin.TestnetCurrency},
+				CoinChange: &types.CoinChange{
+					CoinIdentifier: &types.CoinIdentifier{
+						Identifier: "b14157a5c50503c8cd202a173613dd27e0027343c3d50cf85852dd020bf59c7f:1",
+					},
+					CoinAction: types.CoinSpent,
+				},
+			},
+		}
+		for i, output := range outputs {
+			ops = append(ops, &types.Operation{
+				OperationIdentifier: &types.OperationIdentifier{Index: int64(i + 1)},
+				Type:                bitcoin.OutputOpType,
+				Account:             &types.AccountIdentifier{Address: address},
+				Amount:              &types.Amount{Value: output, Currency: bitcoin.TestnetCurrency},
+			})
+		}
+
+		return ops
+	}
+
+	tests := map[string]struct {
+		outputs []string
+		fees    *bitcoin.RelayFees
+		err     *types.Error
+		context string
+	}{
+		"pays relay fee": {
+			outputs: []string{"990000", "9000"},
+		},
+		"dust change": {
+			outputs: []string{"990000", "200"},
+			err:     ErrNonStandardOutput,
+			context: "operation 2: non-standard output",
+		},
+		"below relay fee": {
+			outputs: []string{"999990"},
+			err:     ErrFeeBelowRelayFee,
+			context: "fee below minimum relay fee",
+		},
+		"below network relay fee": {
+			outputs: []string{"990000", "9000"},
+			fees:    &bitcoin.RelayFees{MinRelayTxFee: 10000},
+			err:     ErrFeeBelowRelayFee,
+			context: "fee below minimum relay fee",
+		},
+		"below network dust limit": {
+			outputs: []string{"990000", "9000"},
+			fees:    &bitcoin.RelayFees{MinRelayTxFee: bitcoin.MinRelayFee, DustLimit: 10000},
+			err:     ErrNonStandardOutput,
+			context: "operation 2: non-standard output",
+		},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			if test.fees != nil {
+				assert.NoError(t, bitcoin.RegisterChainParams(&bitcoin.ChainParams{
+					Params:    cfg.Params,
+					RelayFees: test.fees,
+				}))
+				defer bitcoin.ResetRegistry()
+			}
+
+			preprocessResponse, err := servicer.ConstructionPreprocess(
+				ctx,
+				&types.ConstructionPreprocessRequest{
+					NetworkIdentifier: cfg.Network,
+					Operations:        operations(test.outputs...),
+				},
+			)
+			if test.err == nil {
+				assert.Nil(t, err)
+				assert.NotNil(t, preprocessResponse)
+				return
+			}
+
+			assert.Nil(t, preprocessResponse)
+			assert.Equal(t, test.err.Code, err.Code)
+			assert.Contains(t, err.Details["context"].(string), test.context)
+		})
+	}
+}
@@ -0,0 +1,297 @@
+// Copyright 2020 Coinbase, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package services
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"expvar"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/MNtank/rosetta-bitcoin/configuration"
+
+	"github.com/coinbase/rosetta-sdk-go/types"
+	"go.uber.org/zap"
+	"golang.org/x/sync/singleflight"
+)
+
+var (
+	// dedupPaths are the paths of expensive requests
+	// served by DedupMiddleware.
+	dedupPaths = map[string]struct{}{
+		"/account/balance":   {},
+		"/account/coins":     {},
+		"/block":             {},
+		"/block/transaction": {},
+	}
+
+	// dedupMetrics counts requests served by DedupMiddleware
+	// by whether they were executed, shared an in-flight
+	// execution, or served from the cache.
+	dedupMetrics = expvar.NewMap("http_dedup_requests")
+
+	// responseCaches are all caches invalidated
+	// by InvalidateResponseCaches.
+	responseCaches      []*dedupCache
+	responseCachesMutex sync.Mutex
+)
+
+// InvalidateResponseCaches returns a handler that drops all
+// cached responses of network (and stops sharing in-flight
+// requests started before it was called). It is called with
+// each block event of network, so that no response computed
+// before a block was added or removed is served after it
+// (responses of other networks are kept).
+func InvalidateResponseCaches(network *types.NetworkIdentifier) func(*types.BlockEvent) {
+	key := types.Hash(network)
+
+	return func(event *types.BlockEvent) {
+		responseCachesMutex.Lock()
+		defer responseCachesMutex.Unlock()
+
+		for _, cache := range responseCaches {
+			if cache.network == key {
+				cache.invalidate()
+			}
+		}
+	}
+}
+
+// dedupResponse is a recorded response.
+type dedupResponse struct {
+	header http.Header
+	code   int
+	body   bytes.Buffer
+
+	// expires is when the response can
+	// no longer be served from the cache.
+	expires time.Time
+}
+
+// Header returns the header of the response.
+func (d *dedupResponse) Header() http.Header {
+	return d.header
+}
+
+// WriteHeader records the status code of the response.
+func (d *dedupResponse) WriteHeader(code int) {
+	d.code = code
+}
+
+// Write records b.
+func (d *dedupResponse) Write(b []byte) (int, error) {
+	return d.body.Write(b)
+}
+
+// serve writes the recorded response to w.
+func (d *dedupResponse) serve(w http.ResponseWriter) {
+	for key, values := range d.header {
+		w.Header()[key] = values
+	}
+
+	w.WriteHeader(d.code)
+	_, _ = w.Write(d.body.Bytes())
+}
+
+// dedupCache stores successful responses (by request)
+// to requests for a network until they expire or are
+// invalidated.
+type dedupCache struct {
+	network string
+	ttl     time.Duration
+
+	group singleflight.Group
+
+	// generation is incremented each time the cache
+	// is invalidated. Responses are only cached (and
+	// in-flight requests only shared) within the
+	// generation they were started in.
+	generation uint64
+	responses  map[string]*dedupResponse
+	mutex      sync.Mutex
+}
+
+// newDedupCache creates a new *dedupCache for network
+// invalidated by InvalidateResponseCaches.
+func newDedupCache(network *types.NetworkIdentifier, ttl time.Duration) *dedupCache {
+	cache := &dedupCache{
+		network:   types.Hash(network),
+		ttl:       ttl,
+		responses: map[string]*dedupResponse{},
+	}
+
+	responseCachesMutex.Lock()
+	defer responseCachesMutex.Unlock()
+	responseCaches = append(responseCaches, cache)
+
+	return cache
+}
+
+// get returns the cached response to a request (if
+// it has not expired) and the current generation.
+func (c *dedupCache) get(key string, now time.Time) (*dedupResponse, uint64, bool) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	response, ok := c.responses[key]
+	if !ok || !now.Before(response.expires) {
+		return nil, c.generation, false
+	}
+
+	return response, c.generation, true
+}
+
+// set caches the response to a request started in
+// generation (unless the cache was invalidated since)
+// and removes all responses that have expired.
+func (c *dedupCache) set(
+	key string,
+	generation uint64,
+	response *dedupResponse,
+	now time.Time,
+) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	if generation != c.generation {
+		return
+	}
+
+	for k, r := range c.responses {
+		if !now.Before(r.expires) {
+			delete(c.responses, k)
+		}
+	}
+
+	c.responses[key] = response
+}
+
+// invalidate drops all cached responses.
+func (c *dedupCache) invalidate() {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	c.generation++
+	c.responses = map[string]*dedupResponse{}
+}
+
+// dedupKey identifies a request by its path, body, and
+// accepted encodings (in case the response is compressed
+// by an inner middleware).
+func dedupKey(r *http.Request, body []byte) string {
+	hash := sha256.New()
+	for _, part := range [][]byte{
+		[]byte(r.URL.Path),
+		[]byte(r.Header.Get("Accept-Encoding")),
+		body,
+	} {
+		hash.Write(part)
+		hash.Write([]byte{0})
+	}
+
+	return hex.EncodeToString(hash.Sum(nil))
+}
+
+func newDedupMiddleware(
+	config *configuration.Configuration,
+	loggerRaw *zap.Logger,
+) (Middleware, error) {
+	// Each network has its own cache (invalidated
+	// by the block events of that network only).
+	primary := newDedupCache(config.Network, config.DedupTTL)
+	caches := map[string]*dedupCache{types.Hash(config.Network): primary}
+	for _, additional := range config.AdditionalNetworks {
+		caches[types.Hash(additional.Network)] = newDedupCache(
+			additional.Network,
+			config.DedupTTL,
+		)
+	}
+
+	maxRequestBytes := int64(config.MaxRequestBytes)
+
+	return func(inner http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if _, ok := dedupPaths[r.URL.Path]; !ok || r.Method != http.MethodPost {
+				inner.ServeHTTP(w, r)
+				return
+			}
+
+			// The body is buffered (to identify the request),
+			// so it is bounded even if the validation
+			// middleware is disabled.
+			body, err := ioutil.ReadAll(http.MaxBytesReader(w, r.Body, maxRequestBytes))
+			if err != nil && int64(len(body)) >= maxRequestBytes {
+				http.Error(
+					w,
+					http.StatusText(http.StatusRequestEntityTooLarge),
+					http.StatusRequestEntityTooLarge,
+				)
+				return
+			}
+			if err != nil {
+				http.Error(w, err.Error(), http.StatusBadRequest)
+				return
+			}
+
+			// Requests without a (known) network_identifier
+			// are served by the primary network.
+			cache, ok := caches[types.Hash(requestNetwork(body))]
+			if !ok {
+				cache = primary
+			}
+
+			key := dedupKey(r, body)
+			response, generation, ok := cache.get(key, time.Now())
+			if ok {
+				dedupMetrics.Add("cached", 1)
+				response.serve(w)
+				return
+			}
+
+			// Only the first of identical concurrent requests
+			// (in the same generation) is executed (the others
+			// wait for its response).
+			executed := false
+			flight := fmt.Sprintf("%s/%d", key, generation)
+			result, _, _ := cache.group.Do(flight, func() (interface{}, error) {
+				executed = true
+				dedupMetrics.Add("executed", 1)
+
+				recorded := &dedupResponse{header: http.Header{}, code: http.StatusOK}
+				r.Body = ioutil.NopCloser(bytes.NewReader(body))
+				inner.ServeHTTP(recorded, r)
+
+				now := time.Now()
+				recorded.expires = now.Add(cache.ttl)
+				if recorded.code == http.StatusOK && cache.ttl > 0 {
+					cache.set(key, generation, recorded, now)
+				}
+
+				return recorded, nil
+			})
+
+			if !executed {
+				dedupMetrics.Add("shared", 1)
+			}
+
+			result.(*dedupResponse).serve(w)
+		})
+	}, nil
+}
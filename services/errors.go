@@ -15,174 +15,336 @@
 package services
 
 import (
+	"errors"
+	"fmt"
+	"sort"
+	"sync"
+
 	"github.com/coinbase/rosetta-sdk-go/types"
 )
 
 var (
-	// Errors contains all errors that could be returned
-	// by this Rosetta implementation.
-	Errors = []*types.Error{
-		ErrUnimplemented,
-		ErrUnavailableOffline,
-		ErrNotReady,
-		ErrBitcoind,
-		ErrBlockNotFound,
-		ErrUnableToDerive,
-		ErrUnclearIntent,
-		ErrUnableToParseIntermediateResult,
-		ErrScriptPubKeysMissing,
-		ErrInvalidCoin,
-		ErrUnableToDecodeAddress,
-		ErrUnableToDecodeScriptPubKey,
-		ErrUnableToCalculateSignatureHash,
-		ErrUnsupportedScriptType,
-		ErrUnableToComputePkScript,
-		ErrUnableToGetCoins,
-		ErrTransactionNotFound,
-		ErrCouldNotGetFeeRate,
-		ErrUnableToGetBalance,
+	// ErrErrorCodeRegistered is returned when registering
+	// an error with the code of another registered error.
+	ErrErrorCodeRegistered = errors.New("error code already registered")
+
+	// errorRegistry stores the errors that could be
+	// returned by this Rosetta implementation (by
+	// code). It is guarded by errorMutex.
+	errorRegistry = map[int32]*types.Error{}
+	errorMutex    sync.RWMutex
+)
+
+// RegisterError registers rErr as an error that could be
+// returned by this Rosetta implementation, so that it is
+// listed by /network/options (see Errors). It returns
+// ErrErrorCodeRegistered if a different error with the
+// same code is registered. It is safe to call concurrently.
+func RegisterError(rErr *types.Error) error {
+	errorMutex.Lock()
+	defer errorMutex.Unlock()
+
+	if existing, ok := errorRegistry[rErr.Code]; ok && types.Hash(existing) != types.Hash(rErr) {
+		return fmt.Errorf(
+			"%w: %d is the code of %q",
+			ErrErrorCodeRegistered,
+			rErr.Code,
+			existing.Message,
+		)
+	}
+
+	errorRegistry[rErr.Code] = rErr
+	return nil
+}
+
+// Errors returns all registered errors (sorted by code).
+func Errors() []*types.Error {
+	errorMutex.RLock()
+	defer errorMutex.RUnlock()
+
+	registered := make([]*types.Error, 0, len(errorRegistry))
+	for _, rErr := range errorRegistry {
+		registered = append(registered, rErr)
+	}
+
+	sort.Slice(registered, func(i, j int) bool {
+		return registered[i].Code < registered[j].Code
+	})
+	return registered
+}
+
+// newError registers rErr (see RegisterError) and returns
+// it. It panics if the code of rErr is already registered,
+// as the errors of this package must have unique codes.
+func newError(rErr *types.Error) *types.Error {
+	if err := RegisterError(rErr); err != nil {
+		panic(err)
 	}
 
+	return rErr
+}
+
+var (
 	// ErrUnimplemented is returned when an endpoint
 	// is called that is not implemented.
-	ErrUnimplemented = &types.Error{
+	ErrUnimplemented = newError(&types.Error{
 		Code:    0, //nolint
 		Message: "Endpoint not implemented",
-	}
+	})
 
 	// ErrUnavailableOffline is returned when an endpoint
 	// is called that is not available offline.
-	ErrUnavailableOffline = &types.Error{
+	ErrUnavailableOffline = newError(&types.Error{
 		Code:    1, //nolint
 		Message: "Endpoint unavailable offline",
-	}
+	})
 
 	// ErrNotReady is returned when bitcoind is not
 	// yet ready to serve queries.
-	ErrNotReady = &types.Error{
+	ErrNotReady = newError(&types.Error{
 		Code:      2, //nolint
 		Message:   "Eunod is not ready",
 		Retriable: true,
-	}
+	})
 
 	// ErrBitcoind is returned when bitcoind
 	// errors on a request.
-	ErrBitcoind = &types.Error{
+	ErrBitcoind = newError(&types.Error{
 		Code:    3, //nolint
 		Message: "Eunod error",
-	}
+	})
 
 	// ErrBlockNotFound is returned when a block
 	// is not available in the indexer.
-	ErrBlockNotFound = &types.Error{
+	ErrBlockNotFound = newError(&types.Error{
 		Code:    4, //nolint
 		Message: "Block not found",
-	}
+	})
 
 	// ErrUnableToDerive is returned when an address
 	// cannot be derived from a provided public key.
-	ErrUnableToDerive = &types.Error{
+	ErrUnableToDerive = newError(&types.Error{
 		Code:    5, //nolint
 		Message: "Unable to derive address",
-	}
+	})
 
 	// ErrUnclearIntent is returned when operations
 	// provided in /construction/preprocess or /construction/payloads
 	// are not valid.
-	ErrUnclearIntent = &types.Error{
+	ErrUnclearIntent = newError(&types.Error{
 		Code:    6, //nolint
 		Message: "Unable to parse intent",
-	}
+	})
 
 	// ErrUnableToParseIntermediateResult is returned
 	// when a data structure passed between Construction
 	// API calls is not valid.
-	ErrUnableToParseIntermediateResult = &types.Error{
+	ErrUnableToParseIntermediateResult = newError(&types.Error{
 		Code:    7, //nolint
 		Message: "Unable to parse intermediate result",
-	}
+	})
 
 	// ErrScriptPubKeysMissing is returned when
 	// the indexer cannot populate the required
 	// bitcoin.ScriptPubKeys to construct a transaction.
-	ErrScriptPubKeysMissing = &types.Error{
+	ErrScriptPubKeysMissing = newError(&types.Error{
 		Code:    8, //nolint
 		Message: "Missing ScriptPubKeys",
-	}
+	})
 
 	// ErrInvalidCoin is returned when a *types.Coin
 	// cannot be parsed during construction.
-	ErrInvalidCoin = &types.Error{
+	ErrInvalidCoin = newError(&types.Error{
 		Code:    9, //nolint
 		Message: "Coin is invalid",
-	}
+	})
 
 	// ErrUnableToDecodeAddress is returned when an address
 	// cannot be parsed during construction.
-	ErrUnableToDecodeAddress = &types.Error{
+	ErrUnableToDecodeAddress = newError(&types.Error{
 		Code:    10, //nolint
 		Message: "Unable to decode address",
-	}
+	})
 
 	// ErrUnableToDecodeScriptPubKey is returned when a
 	// bitcoin.ScriptPubKey cannot be parsed during construction.
-	ErrUnableToDecodeScriptPubKey = &types.Error{
+	ErrUnableToDecodeScriptPubKey = newError(&types.Error{
 		Code:    11, //nolint
 		Message: "Unable to decode ScriptPubKey",
-	}
+	})
 
 	// ErrUnableToCalculateSignatureHash is returned
 	// when some payload to sign cannot be generated.
-	ErrUnableToCalculateSignatureHash = &types.Error{
+	ErrUnableToCalculateSignatureHash = newError(&types.Error{
 		Code:    12, //nolint
 		Message: "Unable to calculate signature hash",
-	}
+	})
 
 	// ErrUnsupportedScriptType is returned when
 	// trying to sign an input with an unsupported
 	// script type.
-	ErrUnsupportedScriptType = &types.Error{
+	ErrUnsupportedScriptType = newError(&types.Error{
 		Code:    13, //nolint
 		Message: "Script type is not supported",
-	}
+	})
 
 	// ErrUnableToComputePkScript is returned
 	// when trying to compute the PkScript in
 	// ConsructionParse.
-	ErrUnableToComputePkScript = &types.Error{
+	ErrUnableToComputePkScript = newError(&types.Error{
 		Code:    14, //nolint
 		Message: "Unable to compute PK script",
-	}
+	})
 
 	// ErrUnableToGetCoins is returned by the indexer
 	// when it is not possible to get the coins
 	// owned by a *types.AccountIdentifier.
-	ErrUnableToGetCoins = &types.Error{
+	ErrUnableToGetCoins = newError(&types.Error{
 		Code:    15, //nolint
 		Message: "Unable to get coins",
-	}
+	})
 
 	// ErrTransactionNotFound is returned by the indexer
 	// when it is not possible to find a transaction.
-	ErrTransactionNotFound = &types.Error{
+	ErrTransactionNotFound = newError(&types.Error{
 		Code:    16, // nolint
 		Message: "Transaction not found",
-	}
+	})
 
 	// ErrCouldNotGetFeeRate is returned when the fetch
 	// to get the suggested fee rate fails.
-	ErrCouldNotGetFeeRate = &types.Error{
+	ErrCouldNotGetFeeRate = newError(&types.Error{
 		Code:    17, // nolint
 		Message: "Could not get suggested fee rate",
-	}
+	})
 
 	// ErrUnableToGetBalance is returned by the indexer
 	// when it is not possible to get the balance
 	// of a *types.AccountIdentifier.
-	ErrUnableToGetBalance = &types.Error{
+	ErrUnableToGetBalance = newError(&types.Error{
 		Code:    18, //nolint
 		Message: "Unable to get balance",
-	}
+	})
+
+	// ErrUnauthorized is returned when a request
+	// does not provide the configured bearer token.
+	ErrUnauthorized = newError(&types.Error{
+		Code:    19, //nolint
+		Message: "Unauthorized",
+	})
+
+	// ErrRateLimited is returned when a request
+	// exceeds the configured rate limit.
+	ErrRateLimited = newError(&types.Error{
+		Code:      20, //nolint
+		Message:   "Rate limit exceeded",
+		Retriable: true,
+	})
+
+	// ErrInvalidIdentifier is returned when a block
+	// or transaction hash is malformed.
+	ErrInvalidIdentifier = newError(&types.Error{
+		Code:    21, //nolint
+		Message: "Invalid block or transaction identifier",
+	})
+
+	// ErrNetworkMismatch is returned when a transaction
+	// constructed for one network is combined or
+	// submitted on another network.
+	ErrNetworkMismatch = newError(&types.Error{
+		Code:    22, //nolint
+		Message: "Transaction constructed for a different network",
+	})
+
+	// ErrConstructionFlowNotFound is returned when no
+	// construction flow is stored for a flow ID.
+	ErrConstructionFlowNotFound = newError(&types.Error{
+		Code:    23, //nolint
+		Message: "Construction flow not found",
+	})
+
+	// ErrUnableToStoreConstructionFlow is returned when
+	// the state of a construction flow cannot be stored.
+	ErrUnableToStoreConstructionFlow = newError(&types.Error{
+		Code:      24, //nolint
+		Message:   "Unable to store construction flow",
+		Retriable: true,
+	})
+
+	// ErrSegwitNotActivated is returned when a witness
+	// transaction is constructed (or parsed) on a network
+	// where segwit is not active.
+	ErrSegwitNotActivated = newError(&types.Error{
+		Code:    25, //nolint
+		Message: "Segwit not activated",
+	})
+
+	// ErrMempoolChainLimit is returned when a transaction
+	// spends outputs of unconfirmed transactions with too
+	// many ancestors or descendants in the mempool (it would
+	// be rejected by the node). This error is retriable, as
+	// the limit is no longer exceeded once the unconfirmed
+	// transactions are included in a block.
+	ErrMempoolChainLimit = newError(&types.Error{
+		Code:      26, //nolint
+		Message:   "Mempool chain limit exceeded",
+		Retriable: true,
+	})
+
+	// ErrInvalidRequest is returned when a request made
+	// in-process (without the HTTP server, which rejects
+	// them before they reach the servicers) is malformed.
+	ErrInvalidRequest = newError(&types.Error{
+		Code:    27, //nolint
+		Message: "Invalid request",
+	})
+
+	// ErrNonStandardOutput is returned when a constructed
+	// output violates the relay policy of the node (so the
+	// transaction would be rejected once signed).
+	ErrNonStandardOutput = newError(&types.Error{
+		Code:    28, //nolint
+		Message: "Output is not standard",
+	})
+
+	// ErrAdminCallsDisabled is returned when a /call method
+	// that acts on the node (i.e. banning a peer) is called
+	// without admin /call methods enabled.
+	ErrAdminCallsDisabled = newError(&types.Error{
+		Code:    29, //nolint
+		Message: "Admin /call methods are disabled",
+	})
+
+	// ErrFeeBelowRelayFee is returned when a transaction
+	// pays less than the minimum relay fee of the node (so
+	// it would be rejected by the mempool once signed).
+	ErrFeeBelowRelayFee = newError(&types.Error{
+		Code:    30, //nolint
+		Message: "Fee is below the minimum relay fee",
+	})
+
+	// ErrUnsupportedSearch is returned when a
+	// /search/transactions request uses the "or" operator
+	// or has no indexed condition (an address, an account,
+	// or a transaction identifier).
+	ErrUnsupportedSearch = newError(&types.Error{
+		Code:    31, //nolint
+		Message: "Search is not supported",
+	})
+
+	// ErrUnableToSearch is returned by the indexer
+	// when it is not possible to search transactions.
+	ErrUnableToSearch = newError(&types.Error{
+		Code:    32, //nolint
+		Message: "Unable to search transactions",
+	})
+
+	// ErrUnableToGetEvents is returned by the indexer
+	// when it is not possible to get block events.
+	ErrUnableToGetEvents = newError(&types.Error{
+		Code:    33, //nolint
+		Message: "Unable to get block events",
+	})
 )
 
 // wrapErr adds details to the types.Error provided. We use a function
@@ -18,15 +18,47 @@ import (
 	"errors"
 	"testing"
 
+	"github.com/coinbase/rosetta-sdk-go/types"
 	"github.com/stretchr/testify/assert"
 )
 
 func TestErrors(t *testing.T) {
-	for i := 0; i < len(Errors); i++ {
-		assert.Equal(t, int32(i), Errors[i].Code)
+	errs := Errors()
+	assert.Len(t, errs, 34)
+	for i := 0; i < len(errs); i++ {
+		assert.Equal(t, int32(i), errs[i].Code)
 	}
 }
 
+func TestRegisterError(t *testing.T) {
+	// Registering a registered error has no effect
+	assert.NoError(t, RegisterError(ErrBitcoind))
+	assert.Equal(t, ErrBitcoind, Errors()[ErrBitcoind.Code])
+
+	// Codes can't be reused
+	err := RegisterError(&types.Error{
+		Code:    ErrBitcoind.Code,
+		Message: "Another error",
+	})
+	assert.True(t, errors.Is(err, ErrErrorCodeRegistered))
+	assert.Equal(t, ErrBitcoind, Errors()[ErrBitcoind.Code])
+
+	// Registered errors are listed by /network/options
+	rErr := &types.Error{
+		Code:    1000,
+		Message: "Registered error",
+	}
+	assert.NoError(t, RegisterError(rErr))
+	defer func() {
+		errorMutex.Lock()
+		delete(errorRegistry, rErr.Code)
+		errorMutex.Unlock()
+	}()
+
+	errs := Errors()
+	assert.Equal(t, rErr, errs[len(errs)-1])
+}
+
 func TestWrapErr(t *testing.T) {
 	err := errors.New("testing")
 	typedErr := wrapErr(ErrUnclearIntent, err)
@@ -0,0 +1,87 @@
+// Copyright 2020 Coinbase, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package services
+
+import (
+	"context"
+
+	"github.com/MNtank/rosetta-bitcoin/configuration"
+
+	"github.com/coinbase/rosetta-sdk-go/server"
+	"github.com/coinbase/rosetta-sdk-go/types"
+)
+
+// EventsAPIService implements the server.EventsAPIServicer interface.
+type EventsAPIService struct {
+	config *configuration.Configuration
+	i      Indexer
+}
+
+// NewEventsAPIService returns a new *EventsAPIService.
+func NewEventsAPIService(
+	config *configuration.Configuration,
+	i Indexer,
+) server.EventsAPIServicer {
+	return &EventsAPIService{
+		config: config,
+		i:      i,
+	}
+}
+
+// EventsBlocks implements /events/blocks. Events are read from
+// the events log of the indexer, so every block added or removed
+// (including blocks removed in a reorg) is returned in order. If
+// the offset is omitted, the most recent events are returned. At
+// most MAX_PAGE_SIZE events are returned per call.
+func (s *EventsAPIService) EventsBlocks(
+	ctx context.Context,
+	request *types.EventsBlocksRequest,
+) (*types.EventsBlocksResponse, *types.Error) {
+	if s.config.Mode != configuration.Online {
+		return nil, wrapErr(ErrUnavailableOffline, nil)
+	}
+
+	limit := pageLimit(s.config, request.Limit)
+
+	offset := int64(0)
+	if request.Offset != nil {
+		offset = *request.Offset
+	} else {
+		_, maxSequence, err := s.i.GetBlockEvents(ctx, 0, 0)
+		if err != nil {
+			return nil, wrapErr(ErrUnableToGetEvents, err)
+		}
+
+		if maxSequence-limit+1 > 0 {
+			offset = maxSequence - limit + 1
+		}
+	}
+
+	events, maxSequence, err := s.i.GetBlockEvents(ctx, offset, limit)
+	if err != nil {
+		return nil, wrapErr(ErrUnableToGetEvents, err)
+	}
+
+	// Before the first block is indexed there are no events,
+	// but clients reject a negative max_sequence.
+	if maxSequence < 0 {
+		maxSequence = 0
+	}
+
+	return &types.EventsBlocksResponse{
+		MaxSequence: maxSequence,
+		Events:      events,
+	}, nil
+}
@@ -0,0 +1,124 @@
+// Copyright 2020 Coinbase, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package services
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/MNtank/rosetta-bitcoin/configuration"
+	mocks "github.com/MNtank/rosetta-bitcoin/mocks/services"
+
+	"github.com/coinbase/rosetta-sdk-go/types"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestEventsBlocks_Offline(t *testing.T) {
+	cfg := &configuration.Configuration{
+		Mode: configuration.Offline,
+	}
+	mockIndexer := &mocks.Indexer{}
+	servicer := NewEventsAPIService(cfg, mockIndexer)
+
+	response, err := servicer.EventsBlocks(context.Background(), &types.EventsBlocksRequest{})
+	assert.Nil(t, response)
+	assert.Equal(t, ErrUnavailableOffline.Code, err.Code)
+
+	mockIndexer.AssertExpectations(t)
+}
+
+func TestEventsBlocks_Online(t *testing.T) {
+	cfg := &configuration.Configuration{
+		Mode:        configuration.Online,
+		PageSize:    10,
+		MaxPageSize: 100,
+	}
+	mockIndexer := &mocks.Indexer{}
+	servicer := NewEventsAPIService(cfg, mockIndexer)
+	ctx := context.Background()
+
+	events := []*types.BlockEvent{
+		{
+			Sequence:        249,
+			BlockIdentifier: &types.BlockIdentifier{Index: 200, Hash: "block 200"},
+			Type:            types.REMOVED,
+		},
+	}
+
+	// Without an offset, the most recent
+	// events are returned
+	mockIndexer.On("GetBlockEvents", ctx, int64(0), int64(0)).Return(
+		[]*types.BlockEvent{},
+		int64(249),
+		nil,
+	).Once()
+	mockIndexer.On("GetBlockEvents", ctx, int64(249), int64(1)).Return(
+		events,
+		int64(249),
+		nil,
+	).Once()
+	response, err := servicer.EventsBlocks(ctx, &types.EventsBlocksRequest{
+		Limit: types.Int64(1),
+	})
+	assert.Nil(t, err)
+	assert.Equal(t, &types.EventsBlocksResponse{
+		MaxSequence: 249,
+		Events:      events,
+	}, response)
+
+	// Limits are capped at MaxPageSize
+	mockIndexer.On("GetBlockEvents", ctx, int64(10), int64(100)).Return(
+		events,
+		int64(249),
+		nil,
+	).Once()
+	response, err = servicer.EventsBlocks(ctx, &types.EventsBlocksRequest{
+		Offset: types.Int64(10),
+		Limit:  types.Int64(101),
+	})
+	assert.Nil(t, err)
+	assert.Equal(t, int64(249), response.MaxSequence)
+
+	// Before the first block is indexed,
+	// the max sequence is 0 (limits default
+	// to PageSize)
+	mockIndexer.On("GetBlockEvents", ctx, int64(0), int64(10)).Return(
+		[]*types.BlockEvent{},
+		int64(-1),
+		nil,
+	).Once()
+	response, err = servicer.EventsBlocks(ctx, &types.EventsBlocksRequest{
+		Offset: types.Int64(0),
+	})
+	assert.Nil(t, err)
+	assert.Equal(t, &types.EventsBlocksResponse{
+		MaxSequence: 0,
+		Events:      []*types.BlockEvent{},
+	}, response)
+
+	mockIndexer.On("GetBlockEvents", ctx, int64(0), int64(10)).Return(
+		nil,
+		int64(-1),
+		errors.New("database closed"),
+	).Once()
+	response, err = servicer.EventsBlocks(ctx, &types.EventsBlocksRequest{
+		Offset: types.Int64(0),
+	})
+	assert.Nil(t, response)
+	assert.Equal(t, ErrUnableToGetEvents.Code, err.Code)
+
+	mockIndexer.AssertExpectations(t)
+}
@@ -0,0 +1,379 @@
+// Copyright 2020 Coinbase, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package services
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"html/template"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+
+	"github.com/MNtank/rosetta-bitcoin/bitcoin"
+	"github.com/MNtank/rosetta-bitcoin/configuration"
+
+	"github.com/coinbase/rosetta-sdk-go/types"
+)
+
+const (
+	// explorerPath is the path where the block
+	// explorer is served (if enabled).
+	explorerPath = "/explorer/"
+
+	explorerBlockPath   = explorerPath + "block/"
+	explorerTxPath      = explorerPath + "tx/"
+	explorerAddressPath = explorerPath + "address/"
+	explorerSearchPath  = explorerPath + "search"
+
+	// explorerRecentBlocks is the number of blocks
+	// listed on the explorer index.
+	explorerRecentBlocks = 10
+)
+
+// explorerTemplates are the pages of the explorer. They only
+// render data from the local indexes (no assets are loaded
+// from other origins).
+var explorerTemplates = template.Must(template.New("explorer").Funcs(template.FuncMap{
+	"blockURL":   explorerBlockURL,
+	"txURL":      explorerTxURL,
+	"addressURL": explorerAddressURL,
+	"amount":     explorerAmount,
+}).Parse(`
+{{define "header"}}<!DOCTYPE html>
+<html>
+<head>
+<meta charset="utf-8">
+<title>{{.Title}}</title>
+<style>
+body { font-family: sans-serif; margin: 2em; }
+table { border-collapse: collapse; }
+td, th { border: 1px solid #ccc; padding: 0.25em 0.5em; text-align: left; }
+code { word-break: break-all; }
+</style>
+</head>
+<body>
+<p><a href="` + explorerPath + `">{{.Network.Network}}</a></p>
+<form action="` + explorerSearchPath + `" method="get">
+<input type="text" name="q" size="70" placeholder="Block index, block hash, or address">
+<input type="submit" value="Search">
+</form>
+<h1>{{.Title}}</h1>
+{{end}}
+
+{{define "footer"}}</body>
+</html>
+{{end}}
+
+{{define "index"}}{{template "header" .}}
+<table>
+<tr><th>Index</th><th>Hash</th><th>Transactions</th></tr>
+{{range .Blocks}}<tr>
+<td><a href="{{blockURL .Block.BlockIdentifier.Hash}}">{{.Block.BlockIdentifier.Index}}</a></td>
+<td><code>{{.Block.BlockIdentifier.Hash}}</code></td>
+<td>{{len .OtherTransactions}}</td>
+</tr>
+{{end}}</table>
+{{template "footer" .}}{{end}}
+
+{{define "block"}}{{template "header" .}}
+{{with .Block.Block}}<table>
+<tr><th>Index</th><td>{{.BlockIdentifier.Index}}</td></tr>
+<tr><th>Hash</th><td><code>{{.BlockIdentifier.Hash}}</code></td></tr>
+<tr><th>Parent</th><td><a href="{{blockURL .ParentBlockIdentifier.Hash}}"><code>{{.ParentBlockIdentifier.Hash}}</code></a></td></tr>
+<tr><th>Timestamp</th><td>{{.Timestamp}}</td></tr>
+</table>{{end}}
+<h2>Transactions</h2>
+<ul>
+{{$block := .Block.Block.BlockIdentifier}}{{range .Block.OtherTransactions}}<li><a href="{{txURL $block.Hash .Hash}}"><code>{{.Hash}}</code></a></li>
+{{end}}</ul>
+{{template "footer" .}}{{end}}
+
+{{define "transaction"}}{{template "header" .}}
+<p>Block <a href="{{blockURL .BlockIdentifier.Hash}}">{{.BlockIdentifier.Index}}</a></p>
+<table>
+<tr><th>Index</th><th>Type</th><th>Account</th><th>Amount</th><th>Coin</th></tr>
+{{range .Transaction.Operations}}<tr>
+<td>{{.OperationIdentifier.Index}}</td>
+<td>{{.Type}}</td>
+<td>{{with .Account}}<a href="{{addressURL .Address}}"><code>{{.Address}}</code></a>{{end}}</td>
+<td>{{with .Amount}}{{amount .}}{{end}}</td>
+<td>{{with .CoinChange}}<code>{{.CoinIdentifier.Identifier}}</code> ({{.CoinAction}}){{end}}</td>
+</tr>
+{{end}}</table>
+{{template "footer" .}}{{end}}
+
+{{define "address"}}{{template "header" .}}
+<table>
+<tr><th>Balance</th><td>{{amount .Balance}}</td></tr>
+<tr><th>Block</th><td><a href="{{blockURL .BlockIdentifier.Hash}}">{{.BlockIdentifier.Index}}</a></td></tr>
+</table>
+<h2>Unspent Coins</h2>
+<table>
+<tr><th>Coin</th><th>Amount</th></tr>
+{{range .Coins}}<tr>
+<td><code>{{.CoinIdentifier.Identifier}}</code></td>
+<td>{{amount .Amount}}</td>
+</tr>
+{{end}}</table>
+{{template "footer" .}}{{end}}
+
+{{define "error"}}{{template "header" .}}
+<p>{{.Error}}</p>
+{{template "footer" .}}{{end}}
+`))
+
+// explorerPage is the data rendered on each
+// explorer page.
+type explorerPage struct {
+	Title   string
+	Network *types.NetworkIdentifier
+	Error   string
+
+	Blocks []*types.BlockResponse
+	Block  *types.BlockResponse
+
+	BlockIdentifier *types.BlockIdentifier
+	Transaction     *types.Transaction
+
+	Balance *types.Amount
+	Coins   []*types.Coin
+}
+
+// explorer serves a read-only block explorer
+// rendered from the local indexes.
+type explorer struct {
+	config *configuration.Configuration
+	i      Indexer
+}
+
+// NewExplorer creates a http.Handler that serves
+// block, transaction, and address pages at
+// explorerPath.
+func NewExplorer(
+	config *configuration.Configuration,
+	i Indexer,
+) http.Handler {
+	e := &explorer{
+		config: config,
+		i:      i,
+	}
+
+	router := http.NewServeMux()
+	router.HandleFunc(explorerPath, e.index)
+	router.HandleFunc(explorerBlockPath, e.block)
+	router.HandleFunc(explorerTxPath, e.transaction)
+	router.HandleFunc(explorerAddressPath, e.address)
+	router.HandleFunc(explorerSearchPath, e.search)
+
+	return router
+}
+
+func explorerBlockURL(hash string) string {
+	return explorerBlockPath + url.PathEscape(hash)
+}
+
+func explorerTxURL(blockHash string, hash string) string {
+	return explorerTxPath + url.PathEscape(blockHash) + "/" + url.PathEscape(hash)
+}
+
+func explorerAddressURL(address string) string {
+	return explorerAddressPath + url.PathEscape(address)
+}
+
+// explorerAmount formats amount in whole units
+// of its currency.
+func explorerAmount(amount *types.Amount) string {
+	formatted, err := bitcoin.FormatAmount(amount, bitcoin.DefaultLocale)
+	if err != nil {
+		return amount.Value
+	}
+
+	return formatted
+}
+
+// render writes the page with name (or an error
+// page if it can't be rendered).
+func (e *explorer) render(w http.ResponseWriter, name string, status int, page *explorerPage) {
+	page.Network = e.config.Network
+
+	var buf bytes.Buffer
+	if err := explorerTemplates.ExecuteTemplate(&buf, name, page); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	w.WriteHeader(status)
+	_, _ = w.Write(buf.Bytes())
+}
+
+// renderError writes an error page with status.
+func (e *explorer) renderError(w http.ResponseWriter, status int, err error) {
+	e.render(w, "error", status, &explorerPage{
+		Title: http.StatusText(status),
+		Error: err.Error(),
+	})
+}
+
+// index lists the most recent blocks.
+func (e *explorer) index(w http.ResponseWriter, r *http.Request) {
+	if r.URL.Path != explorerPath {
+		e.renderError(w, http.StatusNotFound, fmt.Errorf("%s not found", r.URL.Path))
+		return
+	}
+
+	head, err := e.i.GetBlockLazy(r.Context(), nil)
+	if err != nil {
+		e.renderError(w, http.StatusServiceUnavailable, err)
+		return
+	}
+
+	blocks := []*types.BlockResponse{head}
+	for index := head.Block.BlockIdentifier.Index - 1; index >= 0 &&
+		len(blocks) < explorerRecentBlocks; index-- {
+		block, err := e.i.GetBlockLazy(r.Context(), &types.PartialBlockIdentifier{
+			Index: types.Int64(index),
+		})
+		if err != nil {
+			// Older blocks may have been pruned.
+			break
+		}
+
+		blocks = append(blocks, block)
+	}
+
+	e.render(w, "index", http.StatusOK, &explorerPage{
+		Title:  "Recent Blocks",
+		Blocks: blocks,
+	})
+}
+
+// getBlock returns the block with identifier, which is
+// either an index or a hash.
+func (e *explorer) getBlock(ctx context.Context, identifier string) (*types.BlockResponse, error) {
+	partial := &types.PartialBlockIdentifier{}
+	if index, err := strconv.ParseInt(identifier, 10, 64); err == nil { // nolint:gomnd
+		partial.Index = types.Int64(index)
+	} else {
+		hash, err := bitcoin.NormalizeHash(identifier)
+		if err != nil {
+			return nil, err
+		}
+
+		partial.Hash = types.String(hash)
+	}
+
+	return e.i.GetBlockLazy(ctx, partial)
+}
+
+// block renders a block and links to its transactions.
+func (e *explorer) block(w http.ResponseWriter, r *http.Request) {
+	identifier := strings.TrimPrefix(r.URL.Path, explorerBlockPath)
+	block, err := e.getBlock(r.Context(), identifier)
+	if err != nil {
+		e.renderError(w, http.StatusNotFound, fmt.Errorf("%w: block %s not found", err, identifier))
+		return
+	}
+
+	e.render(w, "block", http.StatusOK, &explorerPage{
+		Title: fmt.Sprintf("Block %d", block.Block.BlockIdentifier.Index),
+		Block: block,
+	})
+}
+
+// transaction renders the operations of a transaction
+// (at /explorer/tx/<block hash>/<transaction hash>).
+func (e *explorer) transaction(w http.ResponseWriter, r *http.Request) {
+	components := strings.Split(strings.TrimPrefix(r.URL.Path, explorerTxPath), "/")
+	if len(components) != 2 { // nolint:gomnd
+		e.renderError(w, http.StatusNotFound, fmt.Errorf("%s not found", r.URL.Path))
+		return
+	}
+
+	block, err := e.getBlock(r.Context(), components[0])
+	if err != nil {
+		e.renderError(w, http.StatusNotFound, fmt.Errorf("%w: block %s not found", err, components[0]))
+		return
+	}
+
+	hash, err := bitcoin.NormalizeTransactionHash(components[1])
+	if err != nil {
+		e.renderError(w, http.StatusNotFound, err)
+		return
+	}
+
+	transaction, err := e.i.GetBlockTransaction(
+		r.Context(),
+		block.Block.BlockIdentifier,
+		&types.TransactionIdentifier{Hash: hash},
+	)
+	if err != nil {
+		e.renderError(w, http.StatusNotFound, fmt.Errorf("%w: transaction %s not found", err, hash))
+		return
+	}
+
+	e.render(w, "transaction", http.StatusOK, &explorerPage{
+		Title:           fmt.Sprintf("Transaction %s", hash),
+		BlockIdentifier: block.Block.BlockIdentifier,
+		Transaction:     transaction,
+	})
+}
+
+// address renders the balance and unspent
+// coins of an address.
+func (e *explorer) address(w http.ResponseWriter, r *http.Request) {
+	account := &types.AccountIdentifier{
+		Address: strings.TrimPrefix(r.URL.Path, explorerAddressPath),
+	}
+
+	balance, block, err := e.i.GetBalance(r.Context(), account, e.config.Currency, nil)
+	if err != nil {
+		e.renderError(w, http.StatusNotFound, fmt.Errorf("%w: address %s not found", err, account.Address))
+		return
+	}
+
+	coins, _, err := e.i.GetCoins(r.Context(), account)
+	if err != nil {
+		e.renderError(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	e.render(w, "address", http.StatusOK, &explorerPage{
+		Title:           fmt.Sprintf("Address %s", account.Address),
+		BlockIdentifier: block,
+		Balance:         balance,
+		Coins:           coins,
+	})
+}
+
+// search redirects to the page of a block (by index
+// or hash) or address.
+func (e *explorer) search(w http.ResponseWriter, r *http.Request) {
+	query := strings.TrimSpace(r.URL.Query().Get("q"))
+	if len(query) == 0 {
+		http.Redirect(w, r, explorerPath, http.StatusFound)
+		return
+	}
+
+	if _, err := e.getBlock(r.Context(), query); err == nil {
+		http.Redirect(w, r, explorerBlockURL(query), http.StatusFound)
+		return
+	}
+
+	http.Redirect(w, r, explorerAddressURL(query), http.StatusFound)
+}
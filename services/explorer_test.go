@@ -0,0 +1,184 @@
+// Copyright 2020 Coinbase, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package services
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/MNtank/rosetta-bitcoin/bitcoin"
+	"github.com/MNtank/rosetta-bitcoin/configuration"
+	mocks "github.com/MNtank/rosetta-bitcoin/mocks/services"
+
+	"github.com/coinbase/rosetta-sdk-go/types"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+func TestExplorer(t *testing.T) {
+	cfg := &configuration.Configuration{
+		Mode: configuration.Online,
+		Network: &types.NetworkIdentifier{
+			Blockchain: bitcoin.Blockchain,
+			Network:    bitcoin.MainnetNetwork,
+		},
+		Currency: bitcoin.MainnetCurrency,
+		Explorer: true,
+	}
+	mockIndexer := &mocks.Indexer{}
+	router := NewBlockchainRouter(cfg, &mocks.Client{}, mockIndexer, nil)
+
+	get := func(path string) *httptest.ResponseRecorder {
+		recorder := httptest.NewRecorder()
+		router.ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, path, nil))
+		return recorder
+	}
+
+	parent := &types.BlockIdentifier{
+		Index: 99,
+		Hash:  "0000000000000000000b1e4c2b2ad6f2e11a5c2ef9a8b9e1a2b3c4d5e6f70099",
+	}
+	head := &types.BlockResponse{
+		Block: &types.Block{
+			BlockIdentifier: &types.BlockIdentifier{
+				Index: 100,
+				Hash:  "0000000000000000000b1e4c2b2ad6f2e11a5c2ef9a8b9e1a2b3c4d5e6f70100",
+			},
+			ParentBlockIdentifier: parent,
+		},
+		OtherTransactions: []*types.TransactionIdentifier{
+			{Hash: "a1a1a1a1a1a1a1a1a1a1a1a1a1a1a1a1a1a1a1a1a1a1a1a1a1a1a1a1a1a1a1a1"},
+		},
+	}
+	address := "euno1qcqzmqzkswhfshzd8kedhmtvgnxax48z4eg7n8t"
+
+	// Recent blocks stop at the first missing block
+	mockIndexer.On(
+		"GetBlockLazy",
+		mock.Anything,
+		(*types.PartialBlockIdentifier)(nil),
+	).Return(head, nil).Once()
+	mockIndexer.On(
+		"GetBlockLazy",
+		mock.Anything,
+		&types.PartialBlockIdentifier{Index: types.Int64(99)},
+	).Return(nil, errors.New("pruned")).Once()
+	response := get("/explorer/")
+	assert.Equal(t, http.StatusOK, response.Code)
+	assert.Contains(t, response.Body.String(), "/explorer/block/"+head.Block.BlockIdentifier.Hash)
+
+	response = get("/explorer/missing")
+	assert.Equal(t, http.StatusNotFound, response.Code)
+
+	// Blocks can be looked up by index or hash
+	mockIndexer.On(
+		"GetBlockLazy",
+		mock.Anything,
+		&types.PartialBlockIdentifier{Index: types.Int64(100)},
+	).Return(head, nil).Once()
+	response = get("/explorer/block/100")
+	assert.Equal(t, http.StatusOK, response.Code)
+	assert.Contains(t, response.Body.String(), "Block 100")
+	assert.Contains(t, response.Body.String(), "/explorer/block/"+parent.Hash)
+	assert.Contains(t, response.Body.String(), "/explorer/tx/"+head.Block.BlockIdentifier.Hash+
+		"/"+head.OtherTransactions[0].Hash)
+
+	response = get("/explorer/block/invalid")
+	assert.Equal(t, http.StatusNotFound, response.Code)
+
+	mockIndexer.On(
+		"GetBlockLazy",
+		mock.Anything,
+		&types.PartialBlockIdentifier{Hash: types.String(head.Block.BlockIdentifier.Hash)},
+	).Return(head, nil).Once()
+	mockIndexer.On(
+		"GetBlockTransaction",
+		mock.Anything,
+		head.Block.BlockIdentifier,
+		head.OtherTransactions[0],
+	).Return(&types.Transaction{
+		TransactionIdentifier: head.OtherTransactions[0],
+		Operations: []*types.Operation{
+			{
+				OperationIdentifier: &types.OperationIdentifier{Index: 0},
+				Type:                bitcoin.OutputOpType,
+				Account:             &types.AccountIdentifier{Address: address},
+				Amount: &types.Amount{
+					Value:    "50000000",
+					Currency: bitcoin.MainnetCurrency,
+				},
+			},
+		},
+	}, nil).Once()
+	response = get("/explorer/tx/" + head.Block.BlockIdentifier.Hash + "/" + head.OtherTransactions[0].Hash)
+	assert.Equal(t, http.StatusOK, response.Code)
+	assert.Contains(t, response.Body.String(), "/explorer/address/"+address)
+	assert.Contains(t, response.Body.String(), "0.50000000 EUNO")
+
+	// Addresses render their balance and coins
+	account := &types.AccountIdentifier{Address: address}
+	mockIndexer.On(
+		"GetBalance",
+		mock.Anything,
+		account,
+		bitcoin.MainnetCurrency,
+		(*types.PartialBlockIdentifier)(nil),
+	).Return(&types.Amount{
+		Value:    "150000000",
+		Currency: bitcoin.MainnetCurrency,
+	}, head.Block.BlockIdentifier, nil).Once()
+	mockIndexer.On("GetCoins", mock.Anything, account).Return([]*types.Coin{
+		{
+			CoinIdentifier: &types.CoinIdentifier{Identifier: "coin:0"},
+			Amount: &types.Amount{
+				Value:    "150000000",
+				Currency: bitcoin.MainnetCurrency,
+			},
+		},
+	}, head.Block.BlockIdentifier, nil).Once()
+	response = get("/explorer/address/" + address)
+	assert.Equal(t, http.StatusOK, response.Code)
+	assert.Contains(t, response.Body.String(), "1.50000000 EUNO")
+	assert.Contains(t, response.Body.String(), "coin:0")
+
+	// Searches redirect to blocks or addresses
+	mockIndexer.On(
+		"GetBlockLazy",
+		mock.Anything,
+		&types.PartialBlockIdentifier{Index: types.Int64(100)},
+	).Return(head, nil).Once()
+	response = get("/explorer/search?q=100")
+	assert.Equal(t, http.StatusFound, response.Code)
+	assert.Equal(t, "/explorer/block/100", response.Header().Get("Location"))
+
+	response = get("/explorer/search?q=" + address)
+	assert.Equal(t, http.StatusFound, response.Code)
+	assert.Equal(t, "/explorer/address/"+address, response.Header().Get("Location"))
+
+	mockIndexer.AssertExpectations(t)
+}
+
+func TestExplorer_Disabled(t *testing.T) {
+	cfg := &configuration.Configuration{
+		Mode: configuration.Online,
+	}
+	router := NewBlockchainRouter(cfg, &mocks.Client{}, &mocks.Indexer{}, nil)
+
+	recorder := httptest.NewRecorder()
+	router.ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, "/explorer/", nil))
+	assert.NotEqual(t, http.StatusOK, recorder.Code)
+}
@@ -0,0 +1,307 @@
+// Copyright 2020 Coinbase, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math"
+	"math/big"
+	"sort"
+
+	"github.com/MNtank/rosetta-bitcoin/bitcoin"
+	"github.com/MNtank/rosetta-bitcoin/configuration"
+
+	"github.com/coinbase/rosetta-sdk-go/types"
+)
+
+const (
+	// maxFeeMempoolSample is the maximum number of
+	// mempool transactions whose fee rates are
+	// sampled by the mempool source.
+	maxFeeMempoolSample = 500
+
+	// percentileScale is the scale of
+	// FeePercentile (100 is the highest
+	// fee rate).
+	percentileScale = float64(100)
+)
+
+// feeSourceEstimate is the fee rate
+// (in satoshis per vbyte) of a source
+// blended by estimateFeeRate.
+type feeSourceEstimate struct {
+	Source string  `json:"source"`
+	Weight float64 `json:"weight"`
+
+	// FeeRate is nil if the source had no data (in
+	// which case it is not blended).
+	FeeRate *float64 `json:"fee_rate,omitempty"`
+	Error   string   `json:"error,omitempty"`
+}
+
+// feeEstimate is the fee rate (in satoshis per
+// vbyte) blended from the sources of the estimator
+// (never lower than Floor).
+type feeEstimate struct {
+	FeeRate float64              `json:"fee_rate"`
+	Floor   float64              `json:"floor"`
+	Sources []*feeSourceEstimate `json:"sources"`
+}
+
+// feeFloor returns the lowest fee rate (in
+// satoshis per vbyte) suggested on the network
+// of config.
+func feeFloor(config *configuration.Configuration) float64 {
+	minRelayFeeRate := (bitcoin.MinFeeRate * float64(bitcoin.SatoshisInBitcoin)) / bytesInKb
+	return math.Max(config.FeeFloor, minRelayFeeRate)
+}
+
+// estimateFeeRate blends the fee rates of the sources weighted
+// in config. Sources without data are skipped (and the weights
+// of the others are renormalized). If no source has data, the
+// floor is returned unless a source failed.
+func estimateFeeRate(
+	ctx context.Context,
+	config *configuration.Configuration,
+	client Client,
+	i Indexer,
+) (*feeEstimate, error) {
+	weights := config.FeeWeights
+	if len(weights) == 0 {
+		weights = map[string]float64{configuration.NodeFeeSource: 1}
+	}
+
+	estimate := &feeEstimate{
+		Floor:   feeFloor(config),
+		Sources: []*feeSourceEstimate{},
+	}
+
+	var sourceErr error
+	blended, totalWeight := float64(0), float64(0)
+	for _, source := range configuration.FeeSources {
+		weight := weights[source]
+		if weight <= 0 {
+			continue
+		}
+
+		var rate float64
+		var ok bool
+		var err error
+		switch source {
+		case configuration.NodeFeeSource:
+			rate, ok, err = nodeFeeRate(ctx, client)
+		case configuration.MempoolFeeSource:
+			rate, ok, err = mempoolFeeRate(ctx, client, config.FeePercentile)
+		case configuration.BlocksFeeSource:
+			rate, ok, err = blocksFeeRate(ctx, i, config.FeeBlocks, config.FeePercentile)
+		}
+
+		sourceEstimate := &feeSourceEstimate{Source: source, Weight: weight}
+		estimate.Sources = append(estimate.Sources, sourceEstimate)
+		if err != nil {
+			sourceEstimate.Error = err.Error()
+			if sourceErr == nil {
+				sourceErr = fmt.Errorf("%w: unable to get %s fee rate", err, source)
+			}
+			continue
+		}
+
+		if !ok {
+			continue
+		}
+
+		sourceEstimate.FeeRate = &rate
+		blended += weight * rate
+		totalWeight += weight
+	}
+
+	if totalWeight == 0 {
+		if sourceErr != nil {
+			return nil, sourceErr
+		}
+
+		estimate.FeeRate = estimate.Floor
+		return estimate, nil
+	}
+
+	estimate.FeeRate = math.Max(blended/totalWeight, estimate.Floor)
+	return estimate, nil
+}
+
+// nodeFeeRate returns the fee rate estimated by the
+// node and false if it has no estimate.
+func nodeFeeRate(ctx context.Context, client Client) (float64, bool, error) {
+	feePerKB, err := client.SuggestedFeeRate(ctx, defaultConfirmationTarget)
+	if err != nil {
+		return 0, false, err
+	}
+
+	if feePerKB <= 0 {
+		return 0, false, nil
+	}
+
+	return (feePerKB * float64(bitcoin.SatoshisInBitcoin)) / bytesInKb, true, nil
+}
+
+// mempoolFeeRate returns the percentile of the fee rates of
+// (up to maxFeeMempoolSample) transactions in the mempool
+// and false if the mempool is empty.
+func mempoolFeeRate(ctx context.Context, client Client, percentile int) (float64, bool, error) {
+	hashes, err := client.RawMempool(ctx)
+	if err != nil {
+		return 0, false, err
+	}
+
+	if len(hashes) > maxFeeMempoolSample {
+		hashes = hashes[:maxFeeMempoolSample]
+	}
+
+	rates := []float64{}
+	for _, hash := range hashes {
+		entry, err := client.MempoolEntry(ctx, hash)
+		if errors.Is(err, bitcoin.ErrTransactionNotInMempool) {
+			continue
+		}
+		if err != nil {
+			return 0, false, err
+		}
+
+		if entry.Fees == nil || entry.VSize == 0 {
+			continue
+		}
+
+		fee, err := bitcoin.Satoshis(entry.Fees.Base)
+		if err != nil {
+			return 0, false, fmt.Errorf("%w: unable to parse fee of %s", err, hash)
+		}
+
+		rates = append(rates, feeRate(fee, entry.VSize))
+	}
+
+	return feeRatePercentile(rates, percentile)
+}
+
+// blocksFeeRate returns the percentile of the fee rates of
+// the transactions in the last blocks indexed and false if
+// none of them paid a fee.
+func blocksFeeRate(
+	ctx context.Context,
+	i Indexer,
+	blocks int64,
+	percentile int,
+) (float64, bool, error) {
+	head, err := i.GetBlockLazy(ctx, nil)
+	if err != nil {
+		return 0, false, fmt.Errorf("%w: unable to get head block", err)
+	}
+
+	rates := []float64{}
+	start := head.Block.BlockIdentifier.Index - blocks + 1
+	for index := head.Block.BlockIdentifier.Index; index >= 0 && index >= start; index-- {
+		index := index
+		response, err := i.GetBlockLazy(ctx, &types.PartialBlockIdentifier{Index: &index})
+		if err != nil {
+			return 0, false, fmt.Errorf("%w: unable to get block %d", err, index)
+		}
+
+		transactions := response.Block.Transactions
+		for _, identifier := range response.OtherTransactions {
+			transaction, err := i.GetBlockTransaction(
+				ctx,
+				response.Block.BlockIdentifier,
+				identifier,
+			)
+			if err != nil {
+				return 0, false, fmt.Errorf("%w: unable to get transaction %s", err, identifier.Hash)
+			}
+
+			transactions = append(transactions, transaction)
+		}
+
+		for _, transaction := range transactions {
+			if rate, ok := transactionFeeRate(transaction); ok {
+				rates = append(rates, rate)
+			}
+		}
+	}
+
+	return feeRatePercentile(rates, percentile)
+}
+
+// transactionFeeRate returns the fee rate paid by a confirmed
+// transaction and false if it did not pay a fee (i.e. it is
+// a coinbase or a coinstake).
+func transactionFeeRate(transaction *types.Transaction) (float64, bool) {
+	total := big.NewInt(0)
+	for _, op := range transaction.Operations {
+		if op.Type == bitcoin.CoinbaseOpType {
+			return 0, false
+		}
+
+		if op.Amount == nil {
+			continue
+		}
+
+		value, err := types.AmountValue(op.Amount)
+		if err != nil {
+			return 0, false
+		}
+		total.Add(total, value)
+	}
+
+	// Inputs are negative, so the fee
+	// is the negated sum of all amounts.
+	fee := new(big.Int).Neg(total)
+	if fee.Sign() <= 0 || !fee.IsInt64() {
+		return 0, false
+	}
+
+	var metadata bitcoin.TransactionMetadata
+	if err := types.UnmarshalMap(transaction.Metadata, &metadata); err != nil {
+		return 0, false
+	}
+
+	size := metadata.Vsize
+	if size == 0 {
+		size = metadata.Size
+	}
+
+	if size == 0 {
+		return 0, false
+	}
+
+	return feeRate(fee.Int64(), size), true
+}
+
+// feeRatePercentile returns the percentile (nearest rank)
+// of rates and false if there are none.
+func feeRatePercentile(rates []float64, percentile int) (float64, bool, error) {
+	if len(rates) == 0 {
+		return 0, false, nil
+	}
+
+	sort.Float64s(rates)
+	rank := int(math.Ceil(float64(percentile)/percentileScale*float64(len(rates)))) - 1
+	if rank < 0 {
+		rank = 0
+	}
+	if rank >= len(rates) {
+		rank = len(rates) - 1
+	}
+
+	return rates[rank], true, nil
+}
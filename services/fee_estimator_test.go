@@ -0,0 +1,102 @@
+// Copyright 2020 Coinbase, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package services
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/MNtank/rosetta-bitcoin/configuration"
+	mocks "github.com/MNtank/rosetta-bitcoin/mocks/services"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestEstimateFeeRate(t *testing.T) {
+	ctx := context.Background()
+	cfg := &configuration.Configuration{
+		Mode: configuration.Online,
+		FeeWeights: map[string]float64{
+			configuration.NodeFeeSource:    0.5,
+			configuration.MempoolFeeSource: 0.5,
+		},
+		FeeFloor:      3,
+		FeePercentile: 50,
+	}
+	errNode := errors.New("node error")
+
+	t.Run("source without data is skipped", func(t *testing.T) {
+		mockClient := &mocks.Client{}
+		mockClient.On("SuggestedFeeRate", ctx, defaultConfirmationTarget).Return(0.0001, nil).Once()
+		mockClient.On("RawMempool", ctx).Return([]string{}, nil).Once()
+
+		estimate, err := estimateFeeRate(ctx, cfg, mockClient, nil)
+		assert.NoError(t, err)
+		assert.InDelta(t, 10, estimate.FeeRate, 0.0001)
+		assert.Nil(t, estimate.Sources[1].FeeRate)
+		mockClient.AssertExpectations(t)
+	})
+
+	t.Run("blended below floor", func(t *testing.T) {
+		mockClient := &mocks.Client{}
+		mockClient.On("SuggestedFeeRate", ctx, defaultConfirmationTarget).Return(0.00001, nil).Once()
+		mockClient.On("RawMempool", ctx).Return([]string{}, nil).Once()
+
+		estimate, err := estimateFeeRate(ctx, cfg, mockClient, nil)
+		assert.NoError(t, err)
+		assert.Equal(t, float64(3), estimate.FeeRate)
+		mockClient.AssertExpectations(t)
+	})
+
+	t.Run("failed source is skipped", func(t *testing.T) {
+		mockClient := &mocks.Client{}
+		mockClient.On("SuggestedFeeRate", ctx, defaultConfirmationTarget).Return(0.0, errNode).Once()
+		mockClient.On("RawMempool", ctx).Return([]string{}, nil).Once()
+
+		// No source has data but one failed
+		estimate, err := estimateFeeRate(ctx, cfg, mockClient, nil)
+		assert.Nil(t, estimate)
+		assert.True(t, errors.Is(err, errNode))
+		mockClient.AssertExpectations(t)
+	})
+
+	t.Run("no data", func(t *testing.T) {
+		mockClient := &mocks.Client{}
+		mockClient.On("SuggestedFeeRate", ctx, defaultConfirmationTarget).Return(-1.0, nil).Once()
+		mockClient.On("RawMempool", ctx).Return([]string{}, nil).Once()
+
+		estimate, err := estimateFeeRate(ctx, cfg, mockClient, nil)
+		assert.NoError(t, err)
+		assert.Equal(t, float64(3), estimate.FeeRate)
+		mockClient.AssertExpectations(t)
+	})
+}
+
+func TestFeeRatePercentile(t *testing.T) {
+	rates := []float64{40, 10, 30, 20}
+
+	tests := map[int]float64{0: 10, 25: 10, 50: 20, 75: 30, 100: 40}
+	for percentile, expected := range tests {
+		rate, ok, err := feeRatePercentile(rates, percentile)
+		assert.NoError(t, err)
+		assert.True(t, ok)
+		assert.Equal(t, expected, rate)
+	}
+
+	_, ok, err := feeRatePercentile([]float64{}, 50)
+	assert.NoError(t, err)
+	assert.False(t, ok)
+}
@@ -0,0 +1,109 @@
+// Copyright 2020 Coinbase, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package services
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/MNtank/rosetta-bitcoin/bitcoin"
+
+	"github.com/coinbase/rosetta-sdk-go/types"
+)
+
+// normalizePartialBlockIdentifier returns a copy of a
+// *types.PartialBlockIdentifier with a normalized hash.
+// A nil identifier (the current block) is returned as-is.
+func normalizePartialBlockIdentifier(
+	identifier *types.PartialBlockIdentifier,
+) (*types.PartialBlockIdentifier, *types.Error) {
+	if identifier == nil || identifier.Hash == nil {
+		return identifier, nil
+	}
+
+	hash, err := bitcoin.NormalizeHash(*identifier.Hash)
+	if err != nil {
+		return nil, wrapErr(ErrInvalidIdentifier, err)
+	}
+
+	return &types.PartialBlockIdentifier{
+		Index: identifier.Index,
+		Hash:  types.String(hash),
+	}, nil
+}
+
+// normalizeBlockIdentifier returns a copy of a
+// *types.BlockIdentifier with a normalized hash.
+func normalizeBlockIdentifier(
+	identifier *types.BlockIdentifier,
+) (*types.BlockIdentifier, *types.Error) {
+	hash, err := bitcoin.NormalizeHash(identifier.Hash)
+	if err != nil {
+		return nil, wrapErr(ErrInvalidIdentifier, err)
+	}
+
+	return &types.BlockIdentifier{
+		Index: identifier.Index,
+		Hash:  hash,
+	}, nil
+}
+
+// normalizeTransactionIdentifier returns a copy of a
+// *types.TransactionIdentifier with a normalized hash.
+func normalizeTransactionIdentifier(
+	identifier *types.TransactionIdentifier,
+) (*types.TransactionIdentifier, *types.Error) {
+	hash, err := bitcoin.NormalizeTransactionHash(identifier.Hash)
+	if err != nil {
+		return nil, wrapErr(ErrInvalidIdentifier, err)
+	}
+
+	return &types.TransactionIdentifier{
+		Hash: hash,
+	}, nil
+}
+
+// normalizeCoinIdentifier returns a copy of a
+// *types.CoinIdentifier (<transaction hash>:<index>)
+// with a normalized transaction hash.
+func normalizeCoinIdentifier(
+	identifier *types.CoinIdentifier,
+) (*types.CoinIdentifier, *types.Error) {
+	separator := strings.LastIndex(identifier.Identifier, ":")
+	if separator == -1 {
+		return nil, wrapErr(
+			ErrInvalidIdentifier,
+			fmt.Errorf("coin identifier %s is missing an index", identifier.Identifier),
+		)
+	}
+
+	hash, err := bitcoin.NormalizeTransactionHash(identifier.Identifier[:separator])
+	if err != nil {
+		return nil, wrapErr(ErrInvalidIdentifier, err)
+	}
+
+	index, err := strconv.ParseUint(identifier.Identifier[separator+1:], 10, 32)
+	if err != nil {
+		return nil, wrapErr(
+			ErrInvalidIdentifier,
+			fmt.Errorf("%w: unable to parse index of coin identifier", err),
+		)
+	}
+
+	return &types.CoinIdentifier{
+		Identifier: bitcoin.CoinIdentifier(hash, int64(index)),
+	}, nil
+}
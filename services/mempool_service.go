@@ -16,7 +16,11 @@ package services
 
 import (
 	"context"
+	"errors"
+	"fmt"
+	"time"
 
+	"github.com/MNtank/rosetta-bitcoin/bitcoin"
 	"github.com/MNtank/rosetta-bitcoin/configuration"
 
 	"github.com/coinbase/rosetta-sdk-go/server"
@@ -27,16 +31,19 @@ import (
 type MempoolAPIService struct {
 	config *configuration.Configuration
 	client Client
+	i      Indexer
 }
 
 // NewMempoolAPIService creates a new instance of a MempoolAPIService.
 func NewMempoolAPIService(
 	config *configuration.Configuration,
 	client Client,
+	i Indexer,
 ) server.MempoolAPIServicer {
 	return &MempoolAPIService{
 		config: config,
 		client: client,
+		i:      i,
 	}
 }
 
@@ -65,6 +72,10 @@ func (s *MempoolAPIService) Mempool(
 }
 
 // MempoolTransaction implements the /mempool/transaction endpoint.
+// Operations are parsed as in blocks, with inputs hydrated from
+// the coins of the indexer (or of their parent transaction if it
+// is also in the mempool), and the transaction metadata includes
+// its size, fees, and ancestor/descendant stats from the mempool.
 func (s *MempoolAPIService) MempoolTransaction(
 	ctx context.Context,
 	request *types.MempoolTransactionRequest,
@@ -73,5 +84,199 @@ func (s *MempoolAPIService) MempoolTransaction(
 		return nil, wrapErr(ErrUnavailableOffline, nil)
 	}
 
-	return nil, wrapErr(ErrUnimplemented, nil)
+	transactionIdentifier, rErr := normalizeTransactionIdentifier(request.TransactionIdentifier)
+	if rErr != nil {
+		return nil, rErr
+	}
+
+	entry, err := s.client.MempoolEntry(ctx, transactionIdentifier.Hash)
+	if errors.Is(err, bitcoin.ErrTransactionNotInMempool) {
+		return nil, wrapErr(ErrTransactionNotFound, err)
+	}
+	if err != nil {
+		return nil, wrapErr(ErrBitcoind, err)
+	}
+
+	tx, err := s.client.MempoolTransaction(ctx, transactionIdentifier.Hash)
+	if errors.Is(err, bitcoin.ErrTransactionNotInMempool) {
+		return nil, wrapErr(ErrTransactionNotFound, err)
+	}
+	if err != nil {
+		return nil, wrapErr(ErrBitcoind, err)
+	}
+
+	if rErr := s.hydrateInputs(ctx, tx); rErr != nil {
+		return nil, rErr
+	}
+
+	metadata, err := mempoolEntryMetadata(entry, time.Now())
+	if err != nil {
+		return nil, wrapErr(ErrBitcoind, err)
+	}
+
+	if tx.Metadata == nil {
+		tx.Metadata = map[string]interface{}{}
+	}
+	for k, v := range metadata {
+		tx.Metadata[k] = v
+	}
+
+	return &types.MempoolTransactionResponse{
+		Transaction: tx,
+	}, nil
+}
+
+// hydrateInputs populates the account and amount of the
+// input operations of tx that spend an indexed coin or a
+// coin created by another mempool transaction. Inputs
+// whose coin cannot be found are left unhydrated.
+func (s *MempoolAPIService) hydrateInputs(
+	ctx context.Context,
+	tx *types.Transaction,
+) *types.Error {
+	inputs := []*types.Operation{}
+	coinIdentifiers := []*types.CoinIdentifier{}
+	for _, op := range tx.Operations {
+		if op.Account != nil ||
+			op.CoinChange == nil ||
+			op.CoinChange.CoinAction != types.CoinSpent {
+			continue
+		}
+
+		inputs = append(inputs, op)
+		coinIdentifiers = append(coinIdentifiers, op.CoinChange.CoinIdentifier)
+	}
+
+	if len(inputs) == 0 {
+		return nil
+	}
+
+	coins, err := s.i.GetUnspentCoins(ctx, coinIdentifiers)
+	if err != nil {
+		return wrapErr(ErrUnableToGetCoins, err)
+	}
+
+	parents := map[string]*types.Transaction{}
+	for _, op := range inputs {
+		identifier := op.CoinChange.CoinIdentifier.Identifier
+		coin, ok := coins[identifier]
+		if !ok {
+			coin, err = s.parentCoin(ctx, parents, identifier)
+			if err != nil {
+				return wrapErr(ErrBitcoind, err)
+			}
+		}
+
+		if coin == nil {
+			continue
+		}
+
+		value, err := types.NegateValue(coin.Coin.Amount.Value)
+		if err != nil {
+			return wrapErr(ErrUnableToParseIntermediateResult, err)
+		}
+
+		op.Account = coin.Account
+		op.Amount = &types.Amount{
+			Value:    value,
+			Currency: coin.Coin.Amount.Currency,
+		}
+	}
+
+	return nil
+}
+
+// parentCoin returns the coin with identifier created by a
+// mempool transaction (nil if its transaction is not in the
+// mempool). Parent transactions are cached in parents.
+func (s *MempoolAPIService) parentCoin(
+	ctx context.Context,
+	parents map[string]*types.Transaction,
+	identifier string,
+) (*types.AccountCoin, error) {
+	hash := bitcoin.TransactionHash(identifier)
+	parent, ok := parents[hash]
+	if !ok {
+		var err error
+		parent, err = s.client.MempoolTransaction(ctx, hash)
+		if err != nil && !errors.Is(err, bitcoin.ErrTransactionNotInMempool) {
+			return nil, err
+		}
+
+		parents[hash] = parent
+	}
+
+	if parent == nil {
+		return nil, nil
+	}
+
+	for _, op := range parent.Operations {
+		if op.CoinChange == nil ||
+			op.CoinChange.CoinAction != types.CoinCreated ||
+			op.CoinChange.CoinIdentifier.Identifier != identifier {
+			continue
+		}
+
+		return &types.AccountCoin{
+			Account: op.Account,
+			Coin: &types.Coin{
+				CoinIdentifier: op.CoinChange.CoinIdentifier,
+				Amount:         op.Amount,
+			},
+		}, nil
+	}
+
+	return nil, nil
+}
+
+// mempoolEntryMetadata converts a *bitcoin.MempoolEntry
+// into the metadata of a mempool transaction.
+func mempoolEntryMetadata(
+	entry *bitcoin.MempoolEntry,
+	now time.Time,
+) (map[string]interface{}, error) {
+	fees := entry.Fees
+	if fees == nil {
+		fees = &bitcoin.MempoolEntryFees{}
+	}
+
+	amounts := make([]int64, 4) // nolint:gomnd
+	for i, fee := range []float64{fees.Base, fees.Modified, fees.Ancestor, fees.Descendant} {
+		amount, err := bitcoin.Satoshis(fee)
+		if err != nil {
+			return nil, fmt.Errorf("%w: unable to parse fee %f", err, fee)
+		}
+
+		amounts[i] = amount
+	}
+
+	return types.MarshalMap(&mempoolTransactionMetadata{
+		VSize:             entry.VSize,
+		Weight:            entry.Weight,
+		Fee:               amounts[0],
+		FeeRate:           feeRate(amounts[0], entry.VSize),
+		ModifiedFee:       amounts[1],
+		Time:              entry.Time,
+		TimeInMempool:     now.Unix() - entry.Time,
+		Height:            entry.Height,
+		AncestorCount:     entry.AncestorCount,
+		AncestorSize:      entry.AncestorSize,
+		AncestorFees:      amounts[2],
+		AncestorFeeRate:   feeRate(amounts[2], entry.AncestorSize),
+		DescendantCount:   entry.DescendantCount,
+		DescendantSize:    entry.DescendantSize,
+		DescendantFees:    amounts[3],
+		DescendantFeeRate: feeRate(amounts[3], entry.DescendantSize),
+		BIP125Replaceable: entry.BIP125Replaceable,
+	})
+}
+
+// feeRate returns the fee rate (in satoshis per vbyte)
+// of fee (in satoshis) paid for vsize vbytes.
+func feeRate(fee int64, vsize int64) float64 {
+	if vsize == 0 {
+		return 0
+	}
+
+	return float64(fee) / float64(vsize)
 }
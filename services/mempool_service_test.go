@@ -16,8 +16,12 @@ package services
 
 import (
 	"context"
+	"fmt"
+	"strings"
 	"testing"
+	"time"
 
+	"github.com/MNtank/rosetta-bitcoin/bitcoin"
 	"github.com/MNtank/rosetta-bitcoin/configuration"
 	mocks "github.com/MNtank/rosetta-bitcoin/mocks/services"
 
@@ -30,7 +34,8 @@ func TestMempoolEndpoints_Offline(t *testing.T) {
 		Mode: configuration.Offline,
 	}
 	mockClient := &mocks.Client{}
-	servicer := NewMempoolAPIService(cfg, mockClient)
+	mockIndexer := &mocks.Indexer{}
+	servicer := NewMempoolAPIService(cfg, mockClient, mockIndexer)
 	ctx := context.Background()
 	mem, err := servicer.Mempool(ctx, nil)
 	assert.Nil(t, mem)
@@ -42,6 +47,7 @@ func TestMempoolEndpoints_Offline(t *testing.T) {
 	assert.Equal(t, ErrUnavailableOffline.Code, err.Code)
 	assert.Equal(t, ErrUnavailableOffline.Message, err.Message)
 	mockClient.AssertExpectations(t)
+	mockIndexer.AssertExpectations(t)
 }
 
 func TestMempoolEndpoints_Online(t *testing.T) {
@@ -50,29 +56,198 @@ func TestMempoolEndpoints_Online(t *testing.T) {
 	}
 
 	mockClient := &mocks.Client{}
-	servicer := NewMempoolAPIService(cfg, mockClient)
+	mockIndexer := &mocks.Indexer{}
+	servicer := NewMempoolAPIService(cfg, mockClient, mockIndexer)
 	ctx := context.Background()
+	tx0 := fmt.Sprintf("%064x", 0xa0)
+	tx1 := fmt.Sprintf("%064x", 0xa1)
+	tx2 := fmt.Sprintf("%064x", 0xa2)
+	tx3 := fmt.Sprintf("%064x", 0xa3)
 
 	mockClient.On("RawMempool", ctx).Return([]string{
-		"tx1",
-		"tx2",
+		tx1,
+		tx2,
 	}, nil)
 	mem, err := servicer.Mempool(ctx, nil)
 	assert.Nil(t, err)
 	assert.Equal(t, &types.MempoolResponse{
 		TransactionIdentifiers: []*types.TransactionIdentifier{
 			{
-				Hash: "tx1",
+				Hash: tx1,
 			},
 			{
-				Hash: "tx2",
+				Hash: tx2,
 			},
 		},
 	}, mem)
 
-	memTransaction, err := servicer.MempoolTransaction(ctx, nil)
+	mockClient.On("MempoolEntry", ctx, tx1).Return(&bitcoin.MempoolEntry{
+		VSize:           225,
+		Weight:          900,
+		Time:            time.Now().Unix() - 60,
+		Height:          655000,
+		DescendantCount: 1,
+		DescendantSize:  225,
+		AncestorCount:   2,
+		AncestorSize:    450,
+		Fees: &bitcoin.MempoolEntryFees{
+			Base:       0.0000225,
+			Modified:   0.0000225,
+			Ancestor:   0.000036,
+			Descendant: 0.0000225,
+		},
+		BIP125Replaceable: true,
+	}, nil).Once()
+
+	// tx1 spends an indexed coin, a coin created by tx2 (in
+	// the mempool), and a coin created by tx0 (which left
+	// the mempool before it could be fetched).
+	account := &types.AccountIdentifier{Address: "addr1"}
+	parentAccount := &types.AccountIdentifier{Address: "addr2"}
+	input := func(index int64, identifier string) *types.Operation {
+		return &types.Operation{
+			OperationIdentifier: &types.OperationIdentifier{
+				Index:        index,
+				NetworkIndex: types.Int64(index),
+			},
+			Type:   bitcoin.InputOpType,
+			Status: types.String(bitcoin.SuccessStatus),
+			CoinChange: &types.CoinChange{
+				CoinIdentifier: &types.CoinIdentifier{Identifier: identifier},
+				CoinAction:     types.CoinSpent,
+			},
+		}
+	}
+	output := &types.Operation{
+		OperationIdentifier: &types.OperationIdentifier{
+			Index:        3,
+			NetworkIndex: types.Int64(0),
+		},
+		Type:    bitcoin.OutputOpType,
+		Status:  types.String(bitcoin.SuccessStatus),
+		Account: account,
+		Amount:  &types.Amount{Value: "3000", Currency: bitcoin.MainnetCurrency},
+		CoinChange: &types.CoinChange{
+			CoinIdentifier: &types.CoinIdentifier{Identifier: tx1 + ":0"},
+			CoinAction:     types.CoinCreated,
+		},
+	}
+	mockClient.On("MempoolTransaction", ctx, tx1).Return(&types.Transaction{
+		TransactionIdentifier: &types.TransactionIdentifier{Hash: tx1},
+		Operations: []*types.Operation{
+			input(0, "indexed:1"),
+			input(1, tx2+":0"),
+			input(2, tx0+":0"),
+			output,
+		},
+		Metadata: map[string]interface{}{
+			"size":    int64(225),
+			"version": int32(1),
+		},
+	}, nil).Once()
+	mockIndexer.On(
+		"GetUnspentCoins",
+		ctx,
+		[]*types.CoinIdentifier{
+			{Identifier: "indexed:1"},
+			{Identifier: tx2 + ":0"},
+			{Identifier: tx0 + ":0"},
+		},
+	).Return(map[string]*types.AccountCoin{
+		"indexed:1": {
+			Account: account,
+			Coin: &types.Coin{
+				CoinIdentifier: &types.CoinIdentifier{Identifier: "indexed:1"},
+				Amount:         &types.Amount{Value: "1000", Currency: bitcoin.MainnetCurrency},
+			},
+		},
+	}, nil).Once()
+	mockClient.On("MempoolTransaction", ctx, tx2).Return(&types.Transaction{
+		TransactionIdentifier: &types.TransactionIdentifier{Hash: tx2},
+		Operations: []*types.Operation{
+			{
+				OperationIdentifier: &types.OperationIdentifier{
+					Index:        0,
+					NetworkIndex: types.Int64(0),
+				},
+				Type:    bitcoin.OutputOpType,
+				Status:  types.String(bitcoin.SuccessStatus),
+				Account: parentAccount,
+				Amount:  &types.Amount{Value: "4250", Currency: bitcoin.MainnetCurrency},
+				CoinChange: &types.CoinChange{
+					CoinIdentifier: &types.CoinIdentifier{Identifier: tx2 + ":0"},
+					CoinAction:     types.CoinCreated,
+				},
+			},
+		},
+	}, nil).Once()
+	mockClient.On("MempoolTransaction", ctx, tx0).Return(
+		nil,
+		fmt.Errorf("%w: error getting raw transaction", bitcoin.ErrTransactionNotInMempool),
+	).Once()
+	memTransaction, err := servicer.MempoolTransaction(ctx, &types.MempoolTransactionRequest{
+		TransactionIdentifier: &types.TransactionIdentifier{Hash: strings.ToUpper(tx1)},
+	})
+	assert.Nil(t, err)
+	assert.Equal(
+		t,
+		&types.TransactionIdentifier{Hash: tx1},
+		memTransaction.Transaction.TransactionIdentifier,
+	)
+
+	indexedInput := input(0, "indexed:1")
+	indexedInput.Account = account
+	indexedInput.Amount = &types.Amount{Value: "-1000", Currency: bitcoin.MainnetCurrency}
+	parentInput := input(1, tx2+":0")
+	parentInput.Account = parentAccount
+	parentInput.Amount = &types.Amount{Value: "-4250", Currency: bitcoin.MainnetCurrency}
+	assert.Equal(t, []*types.Operation{
+		indexedInput,
+		parentInput,
+		input(2, tx0+":0"),
+		output,
+	}, memTransaction.Transaction.Operations)
+
+	metadata := memTransaction.Transaction.Metadata
+	assert.InDelta(t, int64(60), metadata["time_in_mempool"], 5)
+	delete(metadata, "time")
+	delete(metadata, "time_in_mempool")
+	assert.Equal(t, map[string]interface{}{
+		"size":               int64(225),
+		"version":            int32(1),
+		"vsize":              int64(225),
+		"weight":             int64(900),
+		"fee":                int64(2250),
+		"feerate":            float64(10),
+		"modified_fee":       int64(2250),
+		"height":             int64(655000),
+		"ancestor_count":     int64(2),
+		"ancestor_size":      int64(450),
+		"ancestor_fees":      int64(3600),
+		"ancestor_feerate":   float64(8),
+		"descendant_count":   int64(1),
+		"descendant_size":    int64(225),
+		"descendant_fees":    int64(2250),
+		"descendant_feerate": float64(10),
+		"bip125_replaceable": true,
+	}, metadata)
+
+	mockClient.On("MempoolEntry", ctx, tx3).Return(
+		nil,
+		fmt.Errorf("%w: error getting mempool entry", bitcoin.ErrTransactionNotInMempool),
+	).Once()
+	memTransaction, err = servicer.MempoolTransaction(ctx, &types.MempoolTransactionRequest{
+		TransactionIdentifier: &types.TransactionIdentifier{Hash: tx3},
+	})
+	assert.Nil(t, memTransaction)
+	assert.Equal(t, ErrTransactionNotFound.Code, err.Code)
+	assert.Equal(t, ErrTransactionNotFound.Message, err.Message)
+
+	memTransaction, err = servicer.MempoolTransaction(ctx, &types.MempoolTransactionRequest{
+		TransactionIdentifier: &types.TransactionIdentifier{Hash: "tx 3"},
+	})
 	assert.Nil(t, memTransaction)
-	assert.Equal(t, ErrUnimplemented.Code, err.Code)
-	assert.Equal(t, ErrUnimplemented.Message, err.Message)
+	assert.Equal(t, ErrInvalidIdentifier.Code, err.Code)
 	mockClient.AssertExpectations(t)
+	mockIndexer.AssertExpectations(t)
 }
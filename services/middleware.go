@@ -0,0 +1,400 @@
+// Copyright 2020 Coinbase, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package services
+
+import (
+	"compress/gzip"
+	"crypto/subtle"
+	"errors"
+	"expvar"
+	"fmt"
+	"mime"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/MNtank/rosetta-bitcoin/configuration"
+
+	"github.com/coinbase/rosetta-sdk-go/server"
+	"go.uber.org/zap"
+	"golang.org/x/time/rate"
+)
+
+const (
+	// bearerPrefix is prepended to the token
+	// in the Authorization header.
+	bearerPrefix = "Bearer "
+
+	// jsonContentType is the only content type
+	// accepted by the Rosetta API.
+	jsonContentType = "application/json"
+
+	// otherRoute is the route of requests for
+	// unknown paths in request metrics.
+	otherRoute = "other"
+)
+
+var (
+	// requestMetrics counts requests by route (see
+	// metricsRoute) and status code when MetricsMiddleware
+	// is enabled.
+	requestMetrics = expvar.NewMap("http_requests")
+
+	// requestDurationMetrics sums the time spent serving
+	// requests (in milliseconds) by route when
+	// MetricsMiddleware is enabled.
+	requestDurationMetrics = expvar.NewMap("http_request_duration_ms")
+
+	// metricsRoutes are the paths of all Rosetta
+	// endpoints (see server.NewRouter).
+	metricsRoutes = rosettaRoutes()
+)
+
+// rosettaRoutes returns the path of each route
+// served by the Rosetta API controllers.
+func rosettaRoutes() map[string]struct{} {
+	routers := []server.Router{
+		server.NewNetworkAPIController(nil, nil),
+		server.NewBlockAPIController(nil, nil),
+		server.NewAccountAPIController(nil, nil),
+		server.NewConstructionAPIController(nil, nil),
+		server.NewMempoolAPIController(nil, nil),
+		server.NewCallAPIController(nil, nil),
+		server.NewSearchAPIController(nil, nil),
+		server.NewEventsAPIController(nil, nil),
+	}
+
+	routes := map[string]struct{}{}
+	for _, router := range routers {
+		for _, route := range router.Routes() {
+			routes[route.Pattern] = struct{}{}
+		}
+	}
+
+	return routes
+}
+
+// metricsRoute returns the route of path in request
+// metrics, so that requests for arbitrary paths (i.e.
+// from scanners) can't create unbounded metric keys.
+func metricsRoute(path string) string {
+	if _, ok := metricsRoutes[path]; ok {
+		return path
+	}
+
+	switch {
+	case path == metricsPath:
+		return path
+	case strings.HasPrefix(path, explorerPath):
+		return explorerPath
+	default:
+		return otherRoute
+	}
+}
+
+// Middleware wraps an http.Handler with
+// additional functionality.
+type Middleware func(http.Handler) http.Handler
+
+// middlewareConstructor creates a Middleware
+// from the *configuration.Configuration.
+type middlewareConstructor func(
+	*configuration.Configuration,
+	*zap.Logger,
+) (Middleware, error)
+
+// middlewareConstructors contains a middlewareConstructor
+// for each middleware in configuration.Middlewares.
+var middlewareConstructors = map[string]middlewareConstructor{
+	configuration.AuthMiddleware:         newAuthMiddleware,
+	configuration.CompressionMiddleware:  newCompressionMiddleware,
+	configuration.CorsMiddleware:         newCorsMiddleware,
+	configuration.DedupMiddleware:        newDedupMiddleware,
+	configuration.LoggingMiddleware:      newLoggingMiddleware,
+	configuration.MetricsMiddleware:      newMetricsMiddleware,
+	configuration.RateLimitMiddleware:    newRateLimitMiddleware,
+	configuration.SelfValidateMiddleware: newSelfValidateMiddleware,
+	configuration.ValidationMiddleware:   newValidationMiddleware,
+}
+
+// ChainMiddlewares wraps an http.Handler with all middlewares
+// in config.Middlewares. The first middleware in config.Middlewares
+// is the outermost (i.e. it is the first to see each request).
+func ChainMiddlewares(
+	config *configuration.Configuration,
+	loggerRaw *zap.Logger,
+	handler http.Handler,
+) (http.Handler, error) {
+	for j := len(config.Middlewares) - 1; j >= 0; j-- {
+		name := config.Middlewares[j]
+		constructor, ok := middlewareConstructors[name]
+		if !ok {
+			return nil, fmt.Errorf("%s is not a valid middleware", name)
+		}
+
+		middleware, err := constructor(config, loggerRaw)
+		if err != nil {
+			return nil, fmt.Errorf("%w: unable to create %s middleware", err, name)
+		}
+
+		handler = middleware(handler)
+	}
+
+	return handler, nil
+}
+
+func newAuthMiddleware(
+	config *configuration.Configuration,
+	loggerRaw *zap.Logger,
+) (Middleware, error) {
+	if len(config.AuthToken) == 0 {
+		return nil, errors.New("auth token is empty")
+	}
+
+	expected := []byte(bearerPrefix + config.AuthToken)
+	return func(inner http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			provided := []byte(r.Header.Get("Authorization"))
+			if subtle.ConstantTimeCompare(provided, expected) != 1 {
+				server.EncodeJSONResponse(
+					wrapErr(ErrUnauthorized, nil),
+					http.StatusUnauthorized,
+					w,
+				)
+				return
+			}
+
+			inner.ServeHTTP(w, r)
+		})
+	}, nil
+}
+
+// gzipResponseWriter compresses everything
+// written to the underlying http.ResponseWriter.
+// The response is only compressed (and the
+// Content-Encoding set) once a body is written.
+type gzipResponseWriter struct {
+	http.ResponseWriter
+	writer *gzip.Writer
+
+	// code is the status code of the response,
+	// which is written once the response starts.
+	code    int
+	started bool
+}
+
+// WriteHeader records the status code (it is
+// written once the response starts).
+func (g *gzipResponseWriter) WriteHeader(code int) {
+	if g.started || g.code != 0 {
+		return
+	}
+
+	g.code = code
+}
+
+// start writes the status code, compressing the response
+// unless the status code doesn't allow a body. Any
+// Content-Length (which is no longer correct after
+// compression) is removed.
+func (g *gzipResponseWriter) start() {
+	if g.started {
+		return
+	}
+	g.started = true
+
+	code := g.code
+	if code == 0 {
+		code = http.StatusOK
+	}
+
+	switch {
+	case code < http.StatusOK, code == http.StatusNoContent, code == http.StatusNotModified:
+	default:
+		g.Header().Set("Content-Encoding", "gzip")
+		g.Header().Del("Content-Length")
+		g.writer = gzip.NewWriter(g.ResponseWriter)
+	}
+
+	g.ResponseWriter.WriteHeader(code)
+}
+
+// Write compresses b before writing it.
+func (g *gzipResponseWriter) Write(b []byte) (int, error) {
+	if len(b) == 0 && !g.started {
+		return 0, nil
+	}
+
+	g.start()
+	if g.writer == nil {
+		return g.ResponseWriter.Write(b)
+	}
+
+	return g.writer.Write(b)
+}
+
+// Flush writes everything compressed so far to the
+// client (so streamed responses are not buffered).
+func (g *gzipResponseWriter) Flush() {
+	g.start()
+	if g.writer != nil {
+		_ = g.writer.Flush()
+	}
+
+	if flusher, ok := g.ResponseWriter.(http.Flusher); ok {
+		flusher.Flush()
+	}
+}
+
+// close completes the response. A response without
+// a body is written without compression.
+func (g *gzipResponseWriter) close() error {
+	if !g.started {
+		// Without a recorded status code, net/http
+		// writes the default response.
+		if g.code == 0 {
+			return nil
+		}
+
+		g.started = true
+		g.ResponseWriter.WriteHeader(g.code)
+		return nil
+	}
+
+	if g.writer == nil {
+		return nil
+	}
+
+	return g.writer.Close()
+}
+
+func newCompressionMiddleware(
+	config *configuration.Configuration,
+	loggerRaw *zap.Logger,
+) (Middleware, error) {
+	return func(inner http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.Header().Add("Vary", "Accept-Encoding")
+			if !strings.Contains(r.Header.Get("Accept-Encoding"), "gzip") {
+				inner.ServeHTTP(w, r)
+				return
+			}
+
+			writer := &gzipResponseWriter{ResponseWriter: w}
+			defer writer.close()
+
+			inner.ServeHTTP(writer, r)
+		})
+	}, nil
+}
+
+func newCorsMiddleware(
+	config *configuration.Configuration,
+	loggerRaw *zap.Logger,
+) (Middleware, error) {
+	return server.CorsMiddleware, nil
+}
+
+func newLoggingMiddleware(
+	config *configuration.Configuration,
+	loggerRaw *zap.Logger,
+) (Middleware, error) {
+	return func(inner http.Handler) http.Handler {
+		return LoggerMiddleware(loggerRaw, inner)
+	}, nil
+}
+
+func newMetricsMiddleware(
+	config *configuration.Configuration,
+	loggerRaw *zap.Logger,
+) (Middleware, error) {
+	return func(inner http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			start := time.Now()
+			recorder := NewStatusRecorder(w)
+
+			inner.ServeHTTP(recorder, r)
+
+			route := metricsRoute(r.URL.Path)
+			requestMetrics.Add(fmt.Sprintf("%s %d", route, recorder.Code), 1)
+			requestDurationMetrics.Add(route, time.Since(start).Milliseconds())
+		})
+	}, nil
+}
+
+func newRateLimitMiddleware(
+	config *configuration.Configuration,
+	loggerRaw *zap.Logger,
+) (Middleware, error) {
+	if config.RateLimit <= 0 {
+		return nil, fmt.Errorf("rate limit %f must be positive", config.RateLimit)
+	}
+
+	limiter := rate.NewLimiter(rate.Limit(config.RateLimit), config.RateLimitBurst)
+	return func(inner http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if !limiter.Allow() {
+				server.EncodeJSONResponse(
+					wrapErr(ErrRateLimited, nil),
+					http.StatusTooManyRequests,
+					w,
+				)
+				return
+			}
+
+			inner.ServeHTTP(w, r)
+		})
+	}, nil
+}
+
+func newValidationMiddleware(
+	config *configuration.Configuration,
+	loggerRaw *zap.Logger,
+) (Middleware, error) {
+	maxRequestBytes := int64(config.MaxRequestBytes)
+	return func(inner http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			// All Rosetta endpoints are POST requests with
+			// a JSON body, so there is nothing to validate
+			// for any other method (i.e. CORS preflights).
+			if r.Method != http.MethodPost {
+				inner.ServeHTTP(w, r)
+				return
+			}
+
+			mediaType, _, err := mime.ParseMediaType(r.Header.Get("Content-Type"))
+			if err != nil || mediaType != jsonContentType {
+				http.Error(
+					w,
+					fmt.Sprintf("Content-Type must be %s", jsonContentType),
+					http.StatusUnsupportedMediaType,
+				)
+				return
+			}
+
+			if r.ContentLength > maxRequestBytes {
+				http.Error(
+					w,
+					http.StatusText(http.StatusRequestEntityTooLarge),
+					http.StatusRequestEntityTooLarge,
+				)
+				return
+			}
+
+			r.Body = http.MaxBytesReader(w, r.Body, maxRequestBytes)
+			inner.ServeHTTP(w, r)
+		})
+	}, nil
+}
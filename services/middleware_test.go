@@ -0,0 +1,546 @@
+// Copyright 2020 Coinbase, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package services
+
+import (
+	"bytes"
+	"compress/gzip"
+	"encoding/json"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/MNtank/rosetta-bitcoin/bitcoin"
+	"github.com/MNtank/rosetta-bitcoin/configuration"
+
+	"github.com/coinbase/rosetta-sdk-go/server"
+	"github.com/coinbase/rosetta-sdk-go/types"
+	"github.com/stretchr/testify/assert"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zaptest/observer"
+)
+
+func echoHandler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, err := ioutil.ReadAll(r.Body)
+		if err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+
+		_, _ = w.Write(body)
+	})
+}
+
+func newRequest(body string) *http.Request {
+	r := httptest.NewRequest(http.MethodPost, "/network/list", strings.NewReader(body))
+	r.Header.Set("Content-Type", "application/json; charset=UTF-8")
+
+	return r
+}
+
+func TestChainMiddlewares(t *testing.T) {
+	tests := map[string]struct {
+		config  *configuration.Configuration
+		request func() *http.Request
+
+		expectedCode int
+		expectedBody string
+		expectedErr  bool
+	}{
+		"no middlewares": {
+			config:       &configuration.Configuration{},
+			request:      func() *http.Request { return newRequest("{}") },
+			expectedCode: http.StatusOK,
+			expectedBody: "{}",
+		},
+		"unknown middleware": {
+			config: &configuration.Configuration{
+				Middlewares: []string{"blah"},
+			},
+			expectedErr: true,
+		},
+		"auth without token": {
+			config: &configuration.Configuration{
+				Middlewares: []string{configuration.AuthMiddleware},
+			},
+			expectedErr: true,
+		},
+		"auth missing token": {
+			config: &configuration.Configuration{
+				Middlewares: []string{configuration.AuthMiddleware},
+				AuthToken:   "secret",
+			},
+			request:      func() *http.Request { return newRequest("{}") },
+			expectedCode: http.StatusUnauthorized,
+		},
+		"auth valid token": {
+			config: &configuration.Configuration{
+				Middlewares: []string{configuration.AuthMiddleware},
+				AuthToken:   "secret",
+			},
+			request: func() *http.Request {
+				r := newRequest("{}")
+				r.Header.Set("Authorization", "Bearer secret")
+				return r
+			},
+			expectedCode: http.StatusOK,
+			expectedBody: "{}",
+		},
+		"ratelimit without limit": {
+			config: &configuration.Configuration{
+				Middlewares: []string{configuration.RateLimitMiddleware},
+			},
+			expectedErr: true,
+		},
+		"validation wrong content type": {
+			config: &configuration.Configuration{
+				Middlewares:     []string{configuration.ValidationMiddleware},
+				MaxRequestBytes: 10,
+			},
+			request: func() *http.Request {
+				r := newRequest("{}")
+				r.Header.Set("Content-Type", "text/plain")
+				return r
+			},
+			expectedCode: http.StatusUnsupportedMediaType,
+		},
+		"validation body too large": {
+			config: &configuration.Configuration{
+				Middlewares:     []string{configuration.ValidationMiddleware},
+				MaxRequestBytes: 10,
+			},
+			request:      func() *http.Request { return newRequest(`{"hello":"world"}`) },
+			expectedCode: http.StatusRequestEntityTooLarge,
+		},
+		"validation valid": {
+			config: &configuration.Configuration{
+				Middlewares:     []string{configuration.ValidationMiddleware},
+				MaxRequestBytes: 10,
+			},
+			request:      func() *http.Request { return newRequest("{}") },
+			expectedCode: http.StatusOK,
+			expectedBody: "{}",
+		},
+		"auth before metrics and logging": {
+			config: &configuration.Configuration{
+				Middlewares: []string{
+					configuration.LoggingMiddleware,
+					configuration.MetricsMiddleware,
+					configuration.AuthMiddleware,
+				},
+				AuthToken: "secret",
+			},
+			request:      func() *http.Request { return newRequest("{}") },
+			expectedCode: http.StatusUnauthorized,
+		},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			handler, err := ChainMiddlewares(test.config, zap.NewNop(), echoHandler())
+			if test.expectedErr {
+				assert.Error(t, err)
+				assert.Nil(t, handler)
+				return
+			}
+			assert.NoError(t, err)
+
+			recorder := httptest.NewRecorder()
+			handler.ServeHTTP(recorder, test.request())
+			assert.Equal(t, test.expectedCode, recorder.Code)
+			if len(test.expectedBody) > 0 {
+				assert.Equal(t, test.expectedBody, recorder.Body.String())
+			}
+		})
+	}
+}
+
+func TestMetricsRoute(t *testing.T) {
+	assert.Equal(t, "/network/list", metricsRoute("/network/list"))
+	assert.Equal(t, "/search/transactions", metricsRoute("/search/transactions"))
+	assert.Equal(t, metricsPath, metricsRoute(metricsPath))
+	assert.Equal(t, explorerPath, metricsRoute(explorerBlockPath+"100"))
+	assert.Equal(t, otherRoute, metricsRoute("/wp-login.php"))
+	assert.Equal(t, otherRoute, metricsRoute("/network/list/"))
+}
+
+func TestChainMiddlewares_RateLimit(t *testing.T) {
+	handler, err := ChainMiddlewares(&configuration.Configuration{
+		Middlewares:    []string{configuration.RateLimitMiddleware},
+		RateLimit:      0.001,
+		RateLimitBurst: 1,
+	}, zap.NewNop(), echoHandler())
+	assert.NoError(t, err)
+
+	recorder := httptest.NewRecorder()
+	handler.ServeHTTP(recorder, newRequest("{}"))
+	assert.Equal(t, http.StatusOK, recorder.Code)
+
+	recorder = httptest.NewRecorder()
+	handler.ServeHTTP(recorder, newRequest("{}"))
+	assert.Equal(t, http.StatusTooManyRequests, recorder.Code)
+	assert.Contains(t, recorder.Body.String(), ErrRateLimited.Message)
+}
+
+func TestChainMiddlewares_Compression(t *testing.T) {
+	handler, err := ChainMiddlewares(&configuration.Configuration{
+		Middlewares: []string{configuration.CompressionMiddleware},
+	}, zap.NewNop(), echoHandler())
+	assert.NoError(t, err)
+
+	// Client does not accept gzip
+	recorder := httptest.NewRecorder()
+	handler.ServeHTTP(recorder, newRequest("{}"))
+	assert.Equal(t, "{}", recorder.Body.String())
+	assert.Empty(t, recorder.Header().Get("Content-Encoding"))
+
+	// Client accepts gzip
+	request := newRequest("{}")
+	request.Header.Set("Accept-Encoding", "gzip, deflate")
+	recorder = httptest.NewRecorder()
+	handler.ServeHTTP(recorder, request)
+	assert.Equal(t, "gzip", recorder.Header().Get("Content-Encoding"))
+
+	reader, err := gzip.NewReader(recorder.Body)
+	assert.NoError(t, err)
+	body, err := ioutil.ReadAll(reader)
+	assert.NoError(t, err)
+	assert.Equal(t, "{}", string(body))
+
+	// Responses without a body are not compressed
+	serve := func(inner http.HandlerFunc) *httptest.ResponseRecorder {
+		handler, err := ChainMiddlewares(&configuration.Configuration{
+			Middlewares: []string{configuration.CompressionMiddleware},
+		}, zap.NewNop(), inner)
+		assert.NoError(t, err)
+
+		request := newRequest("{}")
+		request.Header.Set("Accept-Encoding", "gzip")
+		recorder := httptest.NewRecorder()
+		handler.ServeHTTP(recorder, request)
+		return recorder
+	}
+
+	recorder = serve(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusNoContent)
+	})
+	assert.Equal(t, http.StatusNoContent, recorder.Code)
+	assert.Empty(t, recorder.Header().Get("Content-Encoding"))
+	assert.Empty(t, recorder.Body.String())
+
+	recorder = serve(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusAccepted)
+		_, _ = w.Write(nil)
+	})
+	assert.Equal(t, http.StatusAccepted, recorder.Code)
+	assert.Empty(t, recorder.Header().Get("Content-Encoding"))
+	assert.Empty(t, recorder.Body.String())
+
+	// Flushed responses are sent before they are complete
+	recorder = serve(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte("streamed"))
+		w.(http.Flusher).Flush()
+
+		flushedRecorder := w.(*gzipResponseWriter).ResponseWriter.(*httptest.ResponseRecorder)
+		assert.True(t, flushedRecorder.Flushed)
+		reader, err := gzip.NewReader(bytes.NewReader(flushedRecorder.Body.Bytes()))
+		assert.NoError(t, err)
+		flushed := make([]byte, len("streamed"))
+		_, err = io.ReadFull(reader, flushed)
+		assert.NoError(t, err)
+		assert.Equal(t, "streamed", string(flushed))
+	})
+	assert.Equal(t, "gzip", recorder.Header().Get("Content-Encoding"))
+}
+
+func TestChainMiddlewares_SelfValidate(t *testing.T) {
+	additionalGenesis := &types.BlockIdentifier{
+		Index: 5,
+		Hash:  testnetGenesisBlockIdentifier.Hash,
+	}
+	cfg := &configuration.Configuration{
+		Middlewares: []string{configuration.SelfValidateMiddleware},
+		Network: &types.NetworkIdentifier{
+			Blockchain: bitcoin.Blockchain,
+			Network:    bitcoin.MainnetNetwork,
+		},
+		GenesisBlockIdentifier: bitcoin.MainnetGenesisBlockIdentifier,
+		AdditionalNetworks: []*configuration.Configuration{
+			{
+				Network: &types.NetworkIdentifier{
+					Blockchain: bitcoin.Blockchain,
+					Network:    bitcoin.TestnetNetwork,
+				},
+				GenesisBlockIdentifier: additionalGenesis,
+			},
+		},
+	}
+
+	// Only the genesis block (at a different index than on
+	// the primary network) may be its own parent.
+	additionalGenesisResponse := &types.BlockResponse{
+		Block: &types.Block{
+			BlockIdentifier:       additionalGenesis,
+			ParentBlockIdentifier: additionalGenesis,
+			Timestamp:             1601510400000,
+			Transactions:          []*types.Transaction{},
+		},
+	}
+	blockRequest := func(network *types.NetworkIdentifier) string {
+		request, err := json.Marshal(&types.BlockRequest{
+			NetworkIdentifier: network,
+			BlockIdentifier:   &types.PartialBlockIdentifier{Index: types.Int64(5)},
+		})
+		assert.NoError(t, err)
+
+		return string(request)
+	}
+
+	tests := map[string]struct {
+		path     string
+		request  string
+		code     int
+		response interface{}
+
+		expectedWarnings int
+	}{
+		"valid response": {
+			path: "/network/list",
+			code: http.StatusOK,
+			response: &types.NetworkListResponse{
+				NetworkIdentifiers: []*types.NetworkIdentifier{cfg.Network},
+			},
+		},
+		"invalid response": {
+			path: "/network/list",
+			code: http.StatusOK,
+			response: &types.NetworkListResponse{
+				NetworkIdentifiers: []*types.NetworkIdentifier{cfg.Network, cfg.Network},
+			},
+			expectedWarnings: 1,
+		},
+		"valid error": {
+			path:     "/block",
+			code:     http.StatusInternalServerError,
+			response: ErrBlockNotFound,
+		},
+		"unknown error": {
+			path: "/block",
+			code: http.StatusInternalServerError,
+			response: &types.Error{
+				Code:    1000,
+				Message: "blah",
+			},
+			expectedWarnings: 1,
+		},
+		"valid call response": {
+			path: "/call",
+			code: http.StatusOK,
+			response: &types.CallResponse{
+				Result: map[string]interface{}{},
+			},
+		},
+		"invalid call response": {
+			path:             "/call",
+			code:             http.StatusOK,
+			response:         map[string]interface{}{},
+			expectedWarnings: 1,
+		},
+		"additional network": {
+			path:     "/block",
+			request:  blockRequest(cfg.AdditionalNetworks[0].Network),
+			code:     http.StatusOK,
+			response: additionalGenesisResponse,
+		},
+		"wrong network": {
+			path:             "/block",
+			request:          blockRequest(cfg.Network),
+			code:             http.StatusOK,
+			response:         additionalGenesisResponse,
+			expectedWarnings: 1,
+		},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			core, logs := observer.New(zap.WarnLevel)
+			handler, err := ChainMiddlewares(
+				cfg,
+				zap.New(core),
+				http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+					_, _ = ioutil.ReadAll(r.Body)
+					server.EncodeJSONResponse(test.response, test.code, w)
+				}),
+			)
+			assert.NoError(t, err)
+
+			body := test.request
+			if len(body) == 0 {
+				body = "{}"
+			}
+
+			request := httptest.NewRequest(http.MethodPost, test.path, strings.NewReader(body))
+			recorder := httptest.NewRecorder()
+			handler.ServeHTTP(recorder, request)
+			assert.Equal(t, test.code, recorder.Code)
+			assert.Equal(t, test.expectedWarnings, logs.Len())
+		})
+	}
+}
+
+func TestChainMiddlewares_SelfValidateRoutes(t *testing.T) {
+	for route := range rosettaRoutes() {
+		_, ok := responseValidators[route]
+		assert.True(t, ok, route)
+	}
+}
+
+func TestChainMiddlewares_Dedup(t *testing.T) {
+	var executions int32
+	release := make(chan struct{})
+	mainnet := &types.NetworkIdentifier{Blockchain: "Euno", Network: "Mainnet"}
+	testnet := &types.NetworkIdentifier{Blockchain: "Euno", Network: "Testnet"}
+	handler, err := ChainMiddlewares(&configuration.Configuration{
+		Network:            mainnet,
+		AdditionalNetworks: []*configuration.Configuration{{Network: testnet}},
+		Middlewares:        []string{configuration.DedupMiddleware},
+		DedupTTL:           time.Minute,
+		MaxRequestBytes:    100,
+	}, zap.NewNop(), http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&executions, 1)
+		<-release
+
+		body, err := ioutil.ReadAll(r.Body)
+		if err != nil || string(body) == "error" {
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = w.Write(body)
+	}))
+	assert.NoError(t, err)
+
+	serve := func(path string, body string) *httptest.ResponseRecorder {
+		r := httptest.NewRequest(http.MethodPost, path, strings.NewReader(body))
+		recorder := httptest.NewRecorder()
+		handler.ServeHTTP(recorder, r)
+		return recorder
+	}
+
+	// Identical concurrent requests are executed once
+	var wg sync.WaitGroup
+	recorders := make([]*httptest.ResponseRecorder, 5)
+	for i := range recorders {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			recorders[i] = serve("/account/balance", `{"a":1}`)
+		}(i)
+	}
+
+	assert.Eventually(t, func() bool {
+		return atomic.LoadInt32(&executions) == 1
+	}, time.Second, time.Millisecond)
+	time.Sleep(10 * time.Millisecond)
+	close(release)
+	wg.Wait()
+
+	for _, recorder := range recorders {
+		assert.Equal(t, http.StatusOK, recorder.Code)
+		assert.Equal(t, `{"a":1}`, recorder.Body.String())
+		assert.Equal(t, "application/json", recorder.Header().Get("Content-Type"))
+	}
+	assert.Equal(t, int32(1), atomic.LoadInt32(&executions))
+	executed := atomic.LoadInt32(&executions)
+
+	// Identical requests are served from the cache
+	recorder := serve("/account/balance", `{"a":1}`)
+	assert.Equal(t, `{"a":1}`, recorder.Body.String())
+	assert.Equal(t, executed, atomic.LoadInt32(&executions))
+
+	// Different requests (and other paths) are executed
+	serve("/account/balance", `{"a":2}`)
+	assert.Equal(t, executed+1, atomic.LoadInt32(&executions))
+	serve("/network/status", `{"a":1}`)
+	serve("/network/status", `{"a":1}`)
+	assert.Equal(t, executed+3, atomic.LoadInt32(&executions))
+
+	// Errors are not cached
+	assert.Equal(t, http.StatusInternalServerError, serve("/block", "error").Code)
+	assert.Equal(t, http.StatusInternalServerError, serve("/block", "error").Code)
+	assert.Equal(t, executed+5, atomic.LoadInt32(&executions))
+
+	// Block events invalidate cached responses
+	InvalidateResponseCaches(mainnet)(&types.BlockEvent{Type: types.ADDED})
+	serve("/account/balance", `{"a":1}`)
+	serve("/account/balance", `{"a":1}`)
+	assert.Equal(t, executed+6, atomic.LoadInt32(&executions))
+
+	// Block events of other networks don't
+	testnetRequest := `{"network_identifier":{"blockchain":"Euno","network":"Testnet"}}`
+	serve("/block", testnetRequest)
+	InvalidateResponseCaches(mainnet)(&types.BlockEvent{Type: types.ADDED})
+	serve("/block", testnetRequest)
+	assert.Equal(t, executed+7, atomic.LoadInt32(&executions))
+	InvalidateResponseCaches(testnet)(&types.BlockEvent{Type: types.ADDED})
+	serve("/block", testnetRequest)
+	assert.Equal(t, executed+8, atomic.LoadInt32(&executions))
+
+	// Bodies over MaxRequestBytes are rejected
+	// before they are executed
+	large := `{"a":"` + strings.Repeat("a", 100) + `"}`
+	assert.Equal(t, http.StatusRequestEntityTooLarge, serve("/block", large).Code)
+	assert.Equal(t, executed+8, atomic.LoadInt32(&executions))
+}
+
+func TestDedupCache_Invalidate(t *testing.T) {
+	network := &types.NetworkIdentifier{Blockchain: "Euno", Network: "Mainnet"}
+	cache := newDedupCache(network, time.Minute)
+	now := time.Now()
+
+	_, generation, ok := cache.get("key", now)
+	assert.False(t, ok)
+
+	// Responses started before an invalidation
+	// are not cached.
+	InvalidateResponseCaches(network)(&types.BlockEvent{Type: types.REMOVED})
+	cache.set("key", generation, &dedupResponse{expires: now.Add(time.Minute)}, now)
+	_, generation, ok = cache.get("key", now)
+	assert.False(t, ok)
+
+	cache.set("key", generation, &dedupResponse{expires: now.Add(time.Minute)}, now)
+	_, _, ok = cache.get("key", now)
+	assert.True(t, ok)
+
+	// Invalidations of other networks
+	// keep the cached responses.
+	InvalidateResponseCaches(
+		&types.NetworkIdentifier{Blockchain: "Euno", Network: "Testnet"},
+	)(&types.BlockEvent{Type: types.ADDED})
+	_, _, ok = cache.get("key", now)
+	assert.True(t, ok)
+
+	// Expired responses are not served
+	_, _, ok = cache.get("key", now.Add(time.Minute))
+	assert.False(t, ok)
+}
@@ -16,14 +16,22 @@ package services
 
 import (
 	"context"
+	"expvar"
+	"math"
 
 	"github.com/MNtank/rosetta-bitcoin/bitcoin"
 	"github.com/MNtank/rosetta-bitcoin/configuration"
+	"github.com/MNtank/rosetta-bitcoin/utils"
 
 	"github.com/coinbase/rosetta-sdk-go/server"
 	"github.com/coinbase/rosetta-sdk-go/types"
 )
 
+// syncStatusMetrics exposes the sync status of each network
+// (including the completion percentage of the current stage)
+// computed by the most recent /network/status request.
+var syncStatusMetrics = expvar.NewMap("sync_status")
+
 // NetworkAPIService implements the server.NetworkAPIServicer interface.
 type NetworkAPIService struct {
 	config *configuration.Configuration
@@ -49,10 +57,13 @@ func (s *NetworkAPIService) NetworkList(
 	ctx context.Context,
 	request *types.MetadataRequest,
 ) (*types.NetworkListResponse, *types.Error) {
+	networks := []*types.NetworkIdentifier{s.config.Network}
+	for _, additional := range s.config.AdditionalNetworks {
+		networks = append(networks, additional.Network)
+	}
+
 	return &types.NetworkListResponse{
-		NetworkIdentifiers: []*types.NetworkIdentifier{
-			s.config.Network,
-		},
+		NetworkIdentifiers: networks,
 	}, nil
 }
 
@@ -65,24 +76,178 @@ func (s *NetworkAPIService) NetworkStatus(
 		return nil, wrapErr(ErrUnavailableOffline, nil)
 	}
 
-	peers, err := s.client.GetPeers(ctx)
-	if err != nil {
-		return nil, wrapErr(ErrBitcoind, err)
-	}
-
-	cachedBlockResponse, err := s.i.GetBlockLazy(ctx, nil)
-	if err != nil {
-		return nil, wrapErr(ErrNotReady, nil)
+	progress, peers, cachedBlockResponse, rosettaErr := loadSyncProgress(ctx, s.config.Network, s.client, s.i)
+	if rosettaErr != nil {
+		return nil, rosettaErr
 	}
 
 	return &types.NetworkStatusResponse{
 		CurrentBlockIdentifier: cachedBlockResponse.Block.BlockIdentifier,
 		CurrentBlockTimestamp:  cachedBlockResponse.Block.Timestamp,
 		GenesisBlockIdentifier: s.config.GenesisBlockIdentifier,
-		Peers:                  peers,
+		SyncStatus: &types.SyncStatus{
+			CurrentIndex: types.Int64(progress.CurrentIndex),
+			TargetIndex:  types.Int64(progress.TargetIndex),
+			Stage:        types.String(progress.Stage),
+			Synced:       types.Bool(progress.Synced),
+		},
+		Peers: peers,
 	}, nil
 }
 
+// peersTip returns the highest block index
+// reported by any peer when it connected.
+func peersTip(peers []*types.Peer) int64 {
+	tip := int64(0)
+	for _, peer := range peers {
+		var info bitcoin.PeerInfo
+		if err := types.UnmarshalMap(peer.Metadata, &info); err != nil {
+			continue
+		}
+
+		if info.StartingHeight > tip {
+			tip = info.StartingHeight
+		}
+	}
+
+	return tip
+}
+
+// loadSyncProgress fetches the peers and the blockchain info
+// of the node and the last block processed by the indexer,
+// computes the sync progress, and publishes it as expvar
+// metrics of network.
+func loadSyncProgress(
+	ctx context.Context,
+	network *types.NetworkIdentifier,
+	client Client,
+	i Indexer,
+) (*syncProgress, []*types.Peer, *types.BlockResponse, *types.Error) {
+	peers, err := client.GetPeers(ctx)
+	if err != nil {
+		return nil, nil, nil, wrapErr(ErrBitcoind, err)
+	}
+
+	info, err := client.GetBlockchainInfo(ctx)
+	if err != nil {
+		return nil, nil, nil, wrapErr(ErrBitcoind, err)
+	}
+
+	cachedBlockResponse, err := i.GetBlockLazy(ctx, nil)
+	if err != nil {
+		return nil, nil, nil, wrapErr(ErrNotReady, nil)
+	}
+
+	progress := computeSyncProgress(
+		info,
+		peers,
+		cachedBlockResponse.Block.BlockIdentifier.Index,
+		i.GetSyncRate(ctx),
+	)
+	publishSyncProgress(network, progress)
+
+	return progress, peers, cachedBlockResponse, nil
+}
+
+// computeSyncProgress determines the current sync stage
+// (and its completion percentage) by comparing the headers
+// reported by peers, the headers and blocks stored by the node,
+// and the index of the last block processed by the indexer.
+// The ETA is how long the indexer needs to reach the highest
+// known block at blocksPerSecond.
+func computeSyncProgress(
+	info *bitcoin.BlockchainInfo,
+	peers []*types.Peer,
+	indexerIndex int64,
+	blocksPerSecond float64,
+) *syncProgress {
+	var stage string
+	var current, target int64
+	networkTip := peersTip(peers)
+	switch {
+	case len(peers) == 0 && info.InitialBlockDownload:
+		stage, current, target = ConnectingStage, info.Blocks, info.Headers
+	case info.Headers < networkTip:
+		stage, current, target = HeaderSyncStage, info.Headers, networkTip
+	case info.Blocks < info.Headers:
+		stage, current, target = BlockSyncStage, info.Blocks, info.Headers
+	case indexerIndex < info.Blocks:
+		stage, current, target = IndexingStage, indexerIndex, info.Blocks
+	default:
+		stage, current, target = SyncedStage, indexerIndex, indexerIndex
+	}
+
+	percentage := float64(100)
+	switch {
+	case stage == ConnectingStage:
+		percentage = 0
+	case target > 0 && current < target:
+		percentage = float64(current) / float64(target) * 100
+	}
+
+	progress := &syncProgress{
+		Stage:           stage,
+		CurrentIndex:    current,
+		TargetIndex:     target,
+		Percentage:      percentage,
+		Synced:          stage == SyncedStage,
+		BlocksPerSecond: blocksPerSecond,
+	}
+
+	// The tip is unknown while connecting, and there
+	// is nothing left to sync once synced.
+	if stage == ConnectingStage || stage == SyncedStage || blocksPerSecond <= 0 {
+		return progress
+	}
+
+	tip := networkTip
+	if info.Headers > tip {
+		tip = info.Headers
+	}
+	if info.Blocks > tip {
+		tip = info.Blocks
+	}
+
+	eta := int64(math.Ceil(float64(tip-indexerIndex) / blocksPerSecond))
+	progress.ETA = &eta
+
+	return progress
+}
+
+// publishSyncProgress exposes the most recently computed
+// *syncProgress of network as expvar metrics.
+func publishSyncProgress(network *types.NetworkIdentifier, progress *syncProgress) {
+	metrics := utils.NetworkMetrics(syncStatusMetrics, network)
+
+	stage := new(expvar.String)
+	stage.Set(progress.Stage)
+	metrics.Set("stage", stage)
+
+	current := new(expvar.Int)
+	current.Set(progress.CurrentIndex)
+	metrics.Set("current_index", current)
+
+	target := new(expvar.Int)
+	target.Set(progress.TargetIndex)
+	metrics.Set("target_index", target)
+
+	percentage := new(expvar.Float)
+	percentage.Set(progress.Percentage)
+	metrics.Set("percentage", percentage)
+
+	rate := new(expvar.Float)
+	rate.Set(progress.BlocksPerSecond)
+	metrics.Set("blocks_per_second", rate)
+
+	// The ETA is -1 when it is unknown.
+	eta := new(expvar.Int)
+	eta.Set(-1)
+	if progress.ETA != nil {
+		eta.Set(*progress.ETA)
+	}
+	metrics.Set("eta", eta)
+}
+
 // NetworkOptions implements the /network/options endpoint.
 func (s *NetworkAPIService) NetworkOptions(
 	ctx context.Context,
@@ -95,11 +260,13 @@ func (s *NetworkAPIService) NetworkOptions(
 			MiddlewareVersion: types.String(MiddlewareVersion),
 		},
 		Allow: &types.Allow{
-			OperationStatuses:       bitcoin.OperationStatuses,
-			OperationTypes:          bitcoin.OperationTypes,
-			Errors:                  Errors,
+			OperationStatuses:       bitcoin.OperationStatuses(),
+			OperationTypes:          bitcoin.OperationTypes(),
+			Errors:                  Errors(),
 			HistoricalBalanceLookup: HistoricalBalanceLookup,
+			CallMethods:             CallMethods,
 			MempoolCoins:            MempoolCoins,
+			BalanceExemptions:       s.config.BalanceExemptions,
 		},
 	}, nil
 }
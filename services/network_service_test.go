@@ -35,10 +35,12 @@ var (
 			MiddlewareVersion: &middlewareVersion,
 		},
 		Allow: &types.Allow{
-			OperationStatuses:       bitcoin.OperationStatuses,
-			OperationTypes:          bitcoin.OperationTypes,
-			Errors:                  Errors,
+			OperationStatuses:       bitcoin.OperationStatuses(),
+			OperationTypes:          bitcoin.OperationTypes(),
+			Errors:                  Errors(),
 			HistoricalBalanceLookup: HistoricalBalanceLookup,
+			CallMethods:             CallMethods,
+			MempoolCoins:            MempoolCoins,
 		},
 	}
 
@@ -77,6 +79,51 @@ func TestNetworkEndpoints_Offline(t *testing.T) {
 	mockClient.AssertExpectations(t)
 }
 
+func TestNetworkList_AdditionalNetworks(t *testing.T) {
+	testnet := &types.NetworkIdentifier{
+		Network:    bitcoin.TestnetNetwork,
+		Blockchain: bitcoin.Blockchain,
+	}
+	cfg := &configuration.Configuration{
+		Mode:    configuration.Online,
+		Network: networkIdentifier,
+		AdditionalNetworks: []*configuration.Configuration{
+			{
+				Mode:    configuration.Online,
+				Network: testnet,
+			},
+		},
+	}
+	servicer := NewNetworkAPIService(cfg, &mocks.Client{}, &mocks.Indexer{})
+
+	networkList, err := servicer.NetworkList(context.Background(), nil)
+	assert.Nil(t, err)
+	assert.Equal(t, []*types.NetworkIdentifier{
+		networkIdentifier,
+		testnet,
+	}, networkList.NetworkIdentifiers)
+}
+
+func TestNetworkOptions_BalanceExemptions(t *testing.T) {
+	exemptions := []*types.BalanceExemption{
+		{
+			SubAccountAddress: types.String(bitcoin.DelegatedSubAccount),
+			ExemptionType:     types.BalanceGreaterOrEqual,
+		},
+	}
+	cfg := &configuration.Configuration{
+		Mode:              configuration.Offline,
+		Network:           networkIdentifier,
+		BalanceExemptions: exemptions,
+	}
+	servicer := NewNetworkAPIService(cfg, &mocks.Client{}, &mocks.Indexer{})
+
+	networkOptions, err := servicer.NetworkOptions(context.Background(), nil)
+	assert.Nil(t, err)
+	assert.Equal(t, exemptions, networkOptions.Allow.BalanceExemptions)
+	assert.True(t, networkOptions.Allow.HistoricalBalanceLookup)
+}
+
 func TestNetworkEndpoints_Online(t *testing.T) {
 	cfg := &configuration.Configuration{
 		Mode:                   configuration.Online,
@@ -107,6 +154,10 @@ func TestNetworkEndpoints_Online(t *testing.T) {
 			PeerID: "77.93.223.9:8333",
 		},
 	}, nil)
+	mockClient.On("GetBlockchainInfo", ctx).Return(&bitcoin.BlockchainInfo{
+		Blocks:  100,
+		Headers: 100,
+	}, nil)
 	mockIndexer.On(
 		"GetBlockLazy",
 		ctx,
@@ -115,11 +166,18 @@ func TestNetworkEndpoints_Online(t *testing.T) {
 		blockResponse,
 		nil,
 	)
+	mockIndexer.On("GetSyncRate", ctx).Return(float64(0))
 	networkStatus, err := servicer.NetworkStatus(ctx, nil)
 	assert.Nil(t, err)
 	assert.Equal(t, &types.NetworkStatusResponse{
 		GenesisBlockIdentifier: bitcoin.MainnetGenesisBlockIdentifier,
 		CurrentBlockIdentifier: blockResponse.Block.BlockIdentifier,
+		SyncStatus: &types.SyncStatus{
+			CurrentIndex: types.Int64(100),
+			TargetIndex:  types.Int64(100),
+			Stage:        types.String(SyncedStage),
+			Synced:       types.Bool(true),
+		},
 		Peers: []*types.Peer{
 			{
 				PeerID: "77.93.223.9:8333",
@@ -134,3 +192,127 @@ func TestNetworkEndpoints_Online(t *testing.T) {
 	mockIndexer.AssertExpectations(t)
 	mockClient.AssertExpectations(t)
 }
+
+func TestComputeSyncProgress(t *testing.T) {
+	peers := []*types.Peer{
+		{
+			PeerID: "77.93.223.9:8333",
+			Metadata: map[string]interface{}{
+				"addr":           "77.93.223.9:8333",
+				"startingheight": 1000,
+			},
+		},
+		{
+			PeerID: "172.105.93.179:8333",
+			Metadata: map[string]interface{}{
+				"addr":           "172.105.93.179:8333",
+				"startingheight": 998,
+			},
+		},
+	}
+
+	tests := map[string]struct {
+		info            *bitcoin.BlockchainInfo
+		peers           []*types.Peer
+		indexerIndex    int64
+		blocksPerSecond float64
+
+		expectedStage      string
+		expectedCurrent    int64
+		expectedTarget     int64
+		expectedPercentage float64
+		expectedETA        *int64
+	}{
+		"connecting": {
+			info: &bitcoin.BlockchainInfo{
+				Headers:              100,
+				Blocks:               100,
+				InitialBlockDownload: true,
+			},
+			indexerIndex:       50,
+			blocksPerSecond:    10,
+			expectedStage:      ConnectingStage,
+			expectedCurrent:    100,
+			expectedTarget:     100,
+			expectedPercentage: 0,
+		},
+		"header sync": {
+			info:               &bitcoin.BlockchainInfo{Headers: 250, Blocks: 100},
+			peers:              peers,
+			indexerIndex:       50,
+			expectedStage:      HeaderSyncStage,
+			expectedCurrent:    250,
+			expectedTarget:     1000,
+			expectedPercentage: 25,
+		},
+		"header sync with rate": {
+			info:               &bitcoin.BlockchainInfo{Headers: 250, Blocks: 100},
+			peers:              peers,
+			indexerIndex:       50,
+			blocksPerSecond:    100,
+			expectedStage:      HeaderSyncStage,
+			expectedCurrent:    250,
+			expectedTarget:     1000,
+			expectedPercentage: 25,
+			expectedETA:        types.Int64(10),
+		},
+		"block sync": {
+			info:               &bitcoin.BlockchainInfo{Headers: 1000, Blocks: 500},
+			peers:              peers,
+			indexerIndex:       50,
+			expectedStage:      BlockSyncStage,
+			expectedCurrent:    500,
+			expectedTarget:     1000,
+			expectedPercentage: 50,
+		},
+		"indexing": {
+			info:               &bitcoin.BlockchainInfo{Headers: 1000, Blocks: 1000},
+			peers:              peers,
+			indexerIndex:       750,
+			blocksPerSecond:    3,
+			expectedStage:      IndexingStage,
+			expectedCurrent:    750,
+			expectedTarget:     1000,
+			expectedPercentage: 75,
+			expectedETA:        types.Int64(84),
+		},
+		"synced": {
+			info:               &bitcoin.BlockchainInfo{Headers: 1001, Blocks: 1001},
+			peers:              peers,
+			indexerIndex:       1001,
+			blocksPerSecond:    1,
+			expectedStage:      SyncedStage,
+			expectedCurrent:    1001,
+			expectedTarget:     1001,
+			expectedPercentage: 100,
+		},
+		"synced without peers": {
+			info:               &bitcoin.BlockchainInfo{Headers: 1001, Blocks: 1001},
+			indexerIndex:       1001,
+			expectedStage:      SyncedStage,
+			expectedCurrent:    1001,
+			expectedTarget:     1001,
+			expectedPercentage: 100,
+		},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			progress := computeSyncProgress(
+				test.info,
+				test.peers,
+				test.indexerIndex,
+				test.blocksPerSecond,
+			)
+			assert.Equal(t, &syncProgress{
+				Stage:           test.expectedStage,
+				CurrentIndex:    test.expectedCurrent,
+				TargetIndex:     test.expectedTarget,
+				Percentage:      test.expectedPercentage,
+				Synced:          test.expectedStage == SyncedStage,
+				BlocksPerSecond: test.blocksPerSecond,
+				ETA:             test.expectedETA,
+			}, progress)
+		})
+	}
+}
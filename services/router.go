@@ -15,12 +15,26 @@
 package services
 
 import (
+	"bytes"
+	"encoding/json"
+	"expvar"
+	"io/ioutil"
 	"net/http"
+	"net/http/httputil"
+	"net/url"
+	"strings"
 
 	"github.com/MNtank/rosetta-bitcoin/configuration"
 
 	"github.com/coinbase/rosetta-sdk-go/asserter"
 	"github.com/coinbase/rosetta-sdk-go/server"
+	"github.com/coinbase/rosetta-sdk-go/types"
+)
+
+const (
+	// metricsPath is the path where expvar metrics
+	// (i.e. indexer storage snapshots) are served.
+	metricsPath = "/debug/vars"
 )
 
 // NewBlockchainRouter creates a Mux http.Handler from a collection
@@ -43,7 +57,7 @@ func NewBlockchainRouter(
 		asserter,
 	)
 
-	accountAPIService := NewAccountAPIService(config, i)
+	accountAPIService := NewAccountAPIService(config, client, i)
 	accountAPIController := server.NewAccountAPIController(
 		accountAPIService,
 		asserter,
@@ -55,17 +69,197 @@ func NewBlockchainRouter(
 		asserter,
 	)
 
-	mempoolAPIService := NewMempoolAPIService(config, client)
+	mempoolAPIService := NewMempoolAPIService(config, client, i)
 	mempoolAPIController := server.NewMempoolAPIController(
 		mempoolAPIService,
 		asserter,
 	)
 
-	return server.NewRouter(
+	callAPIService := NewCallAPIService(config, client, i)
+	callAPIController := server.NewCallAPIController(
+		callAPIService,
+		asserter,
+	)
+
+	searchAPIService := NewSearchAPIService(config, i)
+	searchAPIController := server.NewSearchAPIController(
+		searchAPIService,
+		asserter,
+	)
+
+	eventsAPIService := NewEventsAPIService(config, i)
+	eventsAPIController := server.NewEventsAPIController(
+		eventsAPIService,
+		asserter,
+	)
+
+	router := http.NewServeMux()
+	router.Handle(metricsPath, expvar.Handler())
+	if config.Explorer {
+		router.Handle(explorerPath, NewExplorer(config, i))
+	}
+	router.Handle("/", server.NewRouter(
 		networkAPIController,
 		blockAPIController,
 		accountAPIController,
 		constructionAPIController,
 		mempoolAPIController,
-	)
+		callAPIController,
+		searchAPIController,
+		eventsAPIController,
+	))
+
+	return router
+}
+
+// NetworkRouter serves each request with the router of
+// the network in its network_identifier, so that a single
+// process can serve multiple networks. Requests without a
+// (known) network_identifier, like /network/list requests,
+// are served by the router of the primary network.
+type NetworkRouter struct {
+	primary http.Handler
+	routers map[string]http.Handler
+
+	// maxRequestBytes is the largest request body read
+	// to find its network (regardless of whether the
+	// validation middleware is enabled).
+	maxRequestBytes int64
+}
+
+// NewNetworkRouter creates a new *NetworkRouter that
+// serves the primary network with primary and rejects
+// request bodies larger than maxRequestBytes.
+func NewNetworkRouter(primary http.Handler, maxRequestBytes int64) *NetworkRouter {
+	return &NetworkRouter{
+		primary:         primary,
+		routers:         map[string]http.Handler{},
+		maxRequestBytes: maxRequestBytes,
+	}
+}
+
+// Add serves requests for network with router.
+func (n *NetworkRouter) Add(network *types.NetworkIdentifier, router http.Handler) {
+	n.routers[types.Hash(network)] = router
+}
+
+// ServeHTTP serves a request with the router of
+// its network.
+func (n *NetworkRouter) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Body == nil || r.Method != http.MethodPost {
+		n.primary.ServeHTTP(w, r)
+		return
+	}
+
+	body, err := ioutil.ReadAll(http.MaxBytesReader(w, r.Body, n.maxRequestBytes))
+	if err != nil && int64(len(body)) >= n.maxRequestBytes {
+		http.Error(
+			w,
+			http.StatusText(http.StatusRequestEntityTooLarge),
+			http.StatusRequestEntityTooLarge,
+		)
+		return
+	}
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	r.Body = ioutil.NopCloser(bytes.NewReader(body))
+
+	var request struct {
+		NetworkIdentifier *types.NetworkIdentifier `json:"network_identifier"`
+	}
+	if err := json.Unmarshal(body, &request); err != nil || request.NetworkIdentifier == nil {
+		n.primary.ServeHTTP(w, r)
+		return
+	}
+
+	router, ok := n.routers[types.Hash(request.NetworkIdentifier)]
+	if !ok {
+		router = n.primary
+	}
+
+	router.ServeHTTP(w, r)
+}
+
+// standbyForwardedHeader is set on requests forwarded
+// by a StandbyRouter so that they are never forwarded
+// again (i.e. if the leader has just stepped down).
+const standbyForwardedHeader = "X-Rosetta-Forwarded"
+
+// standbyReadRoutes are the routes a standby instance
+// forwards to the leader, as they read from the indexer
+// or bitcoind (which only the leader runs).
+var standbyReadRoutes = map[string]struct{}{
+	"/network/status":      {},
+	"/block":               {},
+	"/block/transaction":   {},
+	"/account/balance":     {},
+	"/account/coins":       {},
+	"/mempool":             {},
+	"/mempool/transaction": {},
+	"/search/transactions": {},
+	"/events/blocks":       {},
+}
+
+// StandbyRouter serves requests on an instance waiting
+// to become the leader (see configuration.LeaderElection).
+// Read requests are forwarded to the leader, which serves
+// them from the shared data directory, and all other
+// requests are served by offline.
+//
+// The storage of the leader can't be read directly, as
+// badger only supports a single process opening a database
+// that is being written to.
+type StandbyRouter struct {
+	offline http.Handler
+
+	// leader returns the URL of the leader (empty
+	// if no leader is ready to serve requests).
+	leader func() (string, error)
+}
+
+// NewStandbyRouter creates a new *StandbyRouter that
+// forwards read requests to the URL returned by leader.
+func NewStandbyRouter(
+	offline http.Handler,
+	leader func() (string, error),
+) *StandbyRouter {
+	return &StandbyRouter{
+		offline: offline,
+		leader:  leader,
+	}
+}
+
+// ServeHTTP forwards read requests to the leader
+// and serves all other requests offline. Read requests
+// are also served offline (failing as unavailable) when
+// there is no leader.
+func (s *StandbyRouter) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	_, read := standbyReadRoutes[r.URL.Path]
+	read = read || strings.HasPrefix(r.URL.Path, explorerPath)
+	if !read || len(r.Header.Get(standbyForwardedHeader)) > 0 {
+		s.offline.ServeHTTP(w, r)
+		return
+	}
+
+	leaderURL, err := s.leader()
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusServiceUnavailable)
+		return
+	}
+
+	if len(leaderURL) == 0 {
+		s.offline.ServeHTTP(w, r)
+		return
+	}
+
+	target, err := url.Parse(leaderURL)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusServiceUnavailable)
+		return
+	}
+
+	r.Header.Set(standbyForwardedHeader, "true")
+	httputil.NewSingleHostReverseProxy(target).ServeHTTP(w, r)
 }
@@ -0,0 +1,185 @@
+// Copyright 2020 Coinbase, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package services
+
+import (
+	"errors"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/MNtank/rosetta-bitcoin/bitcoin"
+
+	"github.com/coinbase/rosetta-sdk-go/types"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestNetworkRouter(t *testing.T) {
+	// Each router echoes its name and the
+	// request body it received.
+	newRouter := func(name string) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			body, err := ioutil.ReadAll(r.Body)
+			assert.NoError(t, err)
+			_, _ = w.Write([]byte(name + " " + string(body)))
+		})
+	}
+
+	router := NewNetworkRouter(newRouter("mainnet"), 100)
+	router.Add(&types.NetworkIdentifier{
+		Blockchain: bitcoin.Blockchain,
+		Network:    bitcoin.TestnetNetwork,
+	}, newRouter("testnet"))
+
+	tests := map[string]struct {
+		method string
+		body   string
+
+		code     int
+		response string
+	}{
+		"additional network": {
+			method:   http.MethodPost,
+			body:     `{"network_identifier":{"blockchain":"Euno","network":"Testnet3"}}`,
+			response: `testnet {"network_identifier":{"blockchain":"Euno","network":"Testnet3"}}`,
+		},
+		"primary network": {
+			method:   http.MethodPost,
+			body:     `{"network_identifier":{"blockchain":"Euno","network":"Mainnet"}}`,
+			response: `mainnet {"network_identifier":{"blockchain":"Euno","network":"Mainnet"}}`,
+		},
+		"unknown network": {
+			method:   http.MethodPost,
+			body:     `{"network_identifier":{"blockchain":"Euno","network":"Regtest"}}`,
+			response: `mainnet {"network_identifier":{"blockchain":"Euno","network":"Regtest"}}`,
+		},
+		"no network": {
+			method:   http.MethodPost,
+			body:     `{"metadata":{}}`,
+			response: `mainnet {"metadata":{}}`,
+		},
+		"invalid json": {
+			method:   http.MethodPost,
+			body:     `{"network_identifier"`,
+			response: `mainnet {"network_identifier"`,
+		},
+		"get": {
+			method:   http.MethodGet,
+			response: `mainnet `,
+		},
+		"body too large": {
+			method:   http.MethodPost,
+			body:     `{"metadata":"` + strings.Repeat("a", 100) + `"}`,
+			code:     http.StatusRequestEntityTooLarge,
+			response: http.StatusText(http.StatusRequestEntityTooLarge) + "\n",
+		},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			recorder := httptest.NewRecorder()
+			router.ServeHTTP(
+				recorder,
+				httptest.NewRequest(test.method, "/", strings.NewReader(test.body)),
+			)
+			code := test.code
+			if code == 0 {
+				code = http.StatusOK
+			}
+			assert.Equal(t, code, recorder.Code)
+			assert.Equal(t, test.response, recorder.Body.String())
+		})
+	}
+}
+
+func TestStandbyRouter(t *testing.T) {
+	leader := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, err := ioutil.ReadAll(r.Body)
+		assert.NoError(t, err)
+		assert.Equal(t, "true", r.Header.Get(standbyForwardedHeader))
+		_, _ = w.Write([]byte("leader " + r.URL.Path + " " + string(body)))
+	}))
+	defer leader.Close()
+
+	offline := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte("offline " + r.URL.Path))
+	})
+
+	tests := map[string]struct {
+		leaderURL string
+		leaderErr error
+		path      string
+		forwarded bool
+
+		code     int
+		response string
+	}{
+		"read": {
+			leaderURL: leader.URL,
+			path:      "/account/balance",
+			response:  `leader /account/balance {}`,
+		},
+		"explorer": {
+			leaderURL: leader.URL,
+			path:      "/explorer/block/1",
+			response:  `leader /explorer/block/1 {}`,
+		},
+		"offline endpoint": {
+			leaderURL: leader.URL,
+			path:      "/construction/derive",
+			response:  `offline /construction/derive`,
+		},
+		"no leader": {
+			path:     "/block",
+			response: `offline /block`,
+		},
+		"already forwarded": {
+			leaderURL: leader.URL,
+			path:      "/block",
+			forwarded: true,
+			response:  `offline /block`,
+		},
+		"unreadable leader": {
+			leaderErr: errors.New("permission denied"),
+			path:      "/block",
+			code:      http.StatusServiceUnavailable,
+			response:  "permission denied\n",
+		},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			router := NewStandbyRouter(offline, func() (string, error) {
+				return test.leaderURL, test.leaderErr
+			})
+
+			request := httptest.NewRequest(http.MethodPost, test.path, strings.NewReader("{}"))
+			if test.forwarded {
+				request.Header.Set(standbyForwardedHeader, "true")
+			}
+
+			recorder := httptest.NewRecorder()
+			router.ServeHTTP(recorder, request)
+			code := test.code
+			if code == 0 {
+				code = http.StatusOK
+			}
+			assert.Equal(t, code, recorder.Code)
+			assert.Equal(t, test.response, recorder.Body.String())
+		})
+	}
+}
@@ -0,0 +1,112 @@
+// Copyright 2020 Coinbase, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package services
+
+import (
+	"context"
+	"errors"
+
+	"github.com/MNtank/rosetta-bitcoin/configuration"
+
+	"github.com/coinbase/rosetta-sdk-go/server"
+	"github.com/coinbase/rosetta-sdk-go/types"
+)
+
+// SearchAPIService implements the server.SearchAPIServicer interface.
+type SearchAPIService struct {
+	config *configuration.Configuration
+	i      Indexer
+}
+
+// NewSearchAPIService returns a new *SearchAPIService.
+func NewSearchAPIService(
+	config *configuration.Configuration,
+	i Indexer,
+) server.SearchAPIServicer {
+	return &SearchAPIService{
+		config: config,
+		i:      i,
+	}
+}
+
+// SearchTransactions implements /search/transactions. Only
+// searches with an address, an account, or a transaction
+// identifier (combined with "and") are supported, as other
+// searches would scan every block. At most MAX_PAGE_SIZE
+// transactions are returned per call.
+func (s *SearchAPIService) SearchTransactions(
+	ctx context.Context,
+	request *types.SearchTransactionsRequest,
+) (*types.SearchTransactionsResponse, *types.Error) {
+	if s.config.Mode != configuration.Online {
+		return nil, wrapErr(ErrUnavailableOffline, nil)
+	}
+
+	if request.Operator != nil && *request.Operator == types.OR {
+		return nil, wrapErr(ErrUnsupportedSearch, errors.New("or operator is not supported"))
+	}
+
+	if request.Address == nil && request.AccountIdentifier == nil &&
+		request.TransactionIdentifier == nil {
+		return nil, wrapErr(
+			ErrUnsupportedSearch,
+			errors.New("address, account identifier, or transaction identifier is required"),
+		)
+	}
+
+	limit := pageLimit(s.config, request.Limit)
+	query := *request
+	query.Limit = &limit
+
+	if request.TransactionIdentifier != nil {
+		transactionIdentifier, rErr := normalizeTransactionIdentifier(request.TransactionIdentifier)
+		if rErr != nil {
+			return nil, rErr
+		}
+
+		query.TransactionIdentifier = transactionIdentifier
+	}
+
+	if request.CoinIdentifier != nil {
+		coinIdentifier, rErr := normalizeCoinIdentifier(request.CoinIdentifier)
+		if rErr != nil {
+			return nil, rErr
+		}
+
+		query.CoinIdentifier = coinIdentifier
+	}
+
+	response, err := s.i.SearchTransactions(ctx, &query)
+	if err != nil {
+		return nil, wrapErr(ErrUnableToSearch, err)
+	}
+
+	return response, nil
+}
+
+// pageLimit returns the number of items a paginated
+// endpoint returns for a requested limit: PAGE_SIZE if
+// the limit is not populated, capped at MAX_PAGE_SIZE.
+func pageLimit(config *configuration.Configuration, requested *int64) int64 {
+	if requested == nil {
+		return config.PageSize
+	}
+
+	if *requested > config.MaxPageSize {
+		return config.MaxPageSize
+	}
+
+	return *requested
+}
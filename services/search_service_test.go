@@ -0,0 +1,151 @@
+// Copyright 2020 Coinbase, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/MNtank/rosetta-bitcoin/configuration"
+	mocks "github.com/MNtank/rosetta-bitcoin/mocks/services"
+
+	"github.com/coinbase/rosetta-sdk-go/types"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSearchTransactions_Offline(t *testing.T) {
+	cfg := &configuration.Configuration{
+		Mode: configuration.Offline,
+	}
+	mockIndexer := &mocks.Indexer{}
+	servicer := NewSearchAPIService(cfg, mockIndexer)
+
+	response, err := servicer.SearchTransactions(
+		context.Background(),
+		&types.SearchTransactionsRequest{Address: types.String("hello")},
+	)
+	assert.Nil(t, response)
+	assert.Equal(t, ErrUnavailableOffline.Code, err.Code)
+
+	mockIndexer.AssertExpectations(t)
+}
+
+func TestSearchTransactions_Online(t *testing.T) {
+	cfg := &configuration.Configuration{
+		Mode:        configuration.Online,
+		PageSize:    10,
+		MaxPageSize: 100,
+	}
+	mockIndexer := &mocks.Indexer{}
+	servicer := NewSearchAPIService(cfg, mockIndexer)
+	ctx := context.Background()
+
+	// The or operator and unindexed
+	// searches are not supported
+	or := types.OR
+	response, err := servicer.SearchTransactions(ctx, &types.SearchTransactionsRequest{
+		Operator: &or,
+		Address:  types.String("hello"),
+	})
+	assert.Nil(t, response)
+	assert.Equal(t, ErrUnsupportedSearch.Code, err.Code)
+
+	response, err = servicer.SearchTransactions(ctx, &types.SearchTransactionsRequest{
+		Type: types.String("OUTPUT"),
+	})
+	assert.Nil(t, response)
+	assert.Equal(t, ErrUnsupportedSearch.Code, err.Code)
+
+	// Limits default to PageSize and are capped at MaxPageSize
+	expected := &types.SearchTransactionsResponse{
+		Transactions: []*types.BlockTransaction{
+			{
+				BlockIdentifier: &types.BlockIdentifier{Index: 1, Hash: "block 1"},
+				Transaction: &types.Transaction{
+					TransactionIdentifier: &types.TransactionIdentifier{Hash: "tx 1"},
+				},
+			},
+		},
+		TotalCount: 2,
+		NextOffset: types.Int64(1),
+	}
+	mockIndexer.On(
+		"SearchTransactions",
+		ctx,
+		&types.SearchTransactionsRequest{
+			Address: types.String("hello"),
+			Limit:   types.Int64(10),
+		},
+	).Return(expected, nil).Once()
+	response, err = servicer.SearchTransactions(ctx, &types.SearchTransactionsRequest{
+		Address: types.String("hello"),
+	})
+	assert.Nil(t, err)
+	assert.Equal(t, expected, response)
+
+	mockIndexer.On(
+		"SearchTransactions",
+		ctx,
+		&types.SearchTransactionsRequest{
+			Address: types.String("hello"),
+			Limit:   types.Int64(100),
+		},
+	).Return(expected, nil).Once()
+
+	response, err = servicer.SearchTransactions(ctx, &types.SearchTransactionsRequest{
+		Address: types.String("hello"),
+		Limit:   types.Int64(101),
+	})
+	assert.Nil(t, err)
+	assert.Equal(t, expected, response)
+
+	// Transaction and coin identifiers are normalized
+	hash := fmt.Sprintf("%064x", 0xa1)
+	mockIndexer.On(
+		"SearchTransactions",
+		ctx,
+		&types.SearchTransactionsRequest{
+			TransactionIdentifier: &types.TransactionIdentifier{Hash: hash},
+			CoinIdentifier:        &types.CoinIdentifier{Identifier: hash + ":1"},
+			Limit:                 types.Int64(1),
+		},
+	).Return(nil, errors.New("database closed")).Once()
+	response, err = servicer.SearchTransactions(ctx, &types.SearchTransactionsRequest{
+		TransactionIdentifier: &types.TransactionIdentifier{Hash: strings.ToUpper(hash)},
+		CoinIdentifier:        &types.CoinIdentifier{Identifier: strings.ToUpper(hash) + ":01"},
+		Limit:                 types.Int64(1),
+	})
+	assert.Nil(t, response)
+	assert.Equal(t, ErrUnableToSearch.Code, err.Code)
+	assert.Equal(t, "database closed", err.Details["context"])
+
+	response, err = servicer.SearchTransactions(ctx, &types.SearchTransactionsRequest{
+		TransactionIdentifier: &types.TransactionIdentifier{Hash: "tx 1"},
+	})
+	assert.Nil(t, response)
+	assert.Equal(t, ErrInvalidIdentifier.Code, err.Code)
+
+	response, err = servicer.SearchTransactions(ctx, &types.SearchTransactionsRequest{
+		Address:        types.String("hello"),
+		CoinIdentifier: &types.CoinIdentifier{Identifier: hash},
+	})
+	assert.Nil(t, response)
+	assert.Equal(t, ErrInvalidIdentifier.Code, err.Code)
+
+	mockIndexer.AssertExpectations(t)
+}
@@ -0,0 +1,394 @@
+// Copyright 2020 Coinbase, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package services
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+
+	"github.com/MNtank/rosetta-bitcoin/bitcoin"
+	"github.com/MNtank/rosetta-bitcoin/configuration"
+
+	"github.com/coinbase/rosetta-sdk-go/asserter"
+	"github.com/coinbase/rosetta-sdk-go/types"
+	"go.uber.org/zap"
+)
+
+// responseValidator validates the JSON-encoded response
+// to a request (also provided JSON-encoded).
+type responseValidator func(a *asserter.Asserter, request []byte, response []byte) error
+
+// responseValidators contains a responseValidator
+// for each path that can be validated.
+var responseValidators = map[string]responseValidator{
+	"/network/list": func(a *asserter.Asserter, request []byte, response []byte) error {
+		var r types.NetworkListResponse
+		if err := json.Unmarshal(response, &r); err != nil {
+			return err
+		}
+
+		return asserter.NetworkListResponse(&r)
+	},
+	"/network/status": func(a *asserter.Asserter, request []byte, response []byte) error {
+		var r types.NetworkStatusResponse
+		if err := json.Unmarshal(response, &r); err != nil {
+			return err
+		}
+
+		return asserter.NetworkStatusResponse(&r)
+	},
+	"/network/options": func(a *asserter.Asserter, request []byte, response []byte) error {
+		var r types.NetworkOptionsResponse
+		if err := json.Unmarshal(response, &r); err != nil {
+			return err
+		}
+
+		return asserter.NetworkOptionsResponse(&r)
+	},
+	"/block": func(a *asserter.Asserter, request []byte, response []byte) error {
+		var r types.BlockResponse
+		if err := json.Unmarshal(response, &r); err != nil {
+			return err
+		}
+
+		if r.Block == nil {
+			return errors.New("block is nil")
+		}
+
+		return a.Block(r.Block)
+	},
+	"/block/transaction": func(a *asserter.Asserter, request []byte, response []byte) error {
+		var r types.BlockTransactionResponse
+		if err := json.Unmarshal(response, &r); err != nil {
+			return err
+		}
+
+		return a.Transaction(r.Transaction)
+	},
+	"/account/balance": func(a *asserter.Asserter, request []byte, response []byte) error {
+		var req types.AccountBalanceRequest
+		if err := json.Unmarshal(request, &req); err != nil {
+			return err
+		}
+
+		var r types.AccountBalanceResponse
+		if err := json.Unmarshal(response, &r); err != nil {
+			return err
+		}
+
+		return asserter.AccountBalanceResponse(req.BlockIdentifier, &r)
+	},
+	"/account/coins": func(a *asserter.Asserter, request []byte, response []byte) error {
+		var r types.AccountCoinsResponse
+		if err := json.Unmarshal(response, &r); err != nil {
+			return err
+		}
+
+		return asserter.AccountCoinsResponse(&r)
+	},
+	"/mempool": func(a *asserter.Asserter, request []byte, response []byte) error {
+		var r types.MempoolResponse
+		if err := json.Unmarshal(response, &r); err != nil {
+			return err
+		}
+
+		return asserter.MempoolTransactions(r.TransactionIdentifiers)
+	},
+	"/mempool/transaction": func(a *asserter.Asserter, request []byte, response []byte) error {
+		var r types.MempoolTransactionResponse
+		if err := json.Unmarshal(response, &r); err != nil {
+			return err
+		}
+
+		return a.Transaction(r.Transaction)
+	},
+	"/search/transactions": func(a *asserter.Asserter, request []byte, response []byte) error {
+		var r types.SearchTransactionsResponse
+		if err := json.Unmarshal(response, &r); err != nil {
+			return err
+		}
+
+		return a.SearchTransactionsResponse(&r)
+	},
+	"/events/blocks": func(a *asserter.Asserter, request []byte, response []byte) error {
+		var r types.EventsBlocksResponse
+		if err := json.Unmarshal(response, &r); err != nil {
+			return err
+		}
+
+		return asserter.EventsBlocksResponse(&r)
+	},
+	"/call": func(a *asserter.Asserter, request []byte, response []byte) error {
+		var r types.CallResponse
+		if err := json.Unmarshal(response, &r); err != nil {
+			return err
+		}
+
+		if r.Result == nil {
+			return errors.New("call result is nil")
+		}
+
+		return nil
+	},
+	"/construction/derive": func(a *asserter.Asserter, request []byte, response []byte) error {
+		var r types.ConstructionDeriveResponse
+		if err := json.Unmarshal(response, &r); err != nil {
+			return err
+		}
+
+		return asserter.ConstructionDeriveResponse(&r)
+	},
+	"/construction/preprocess": func(a *asserter.Asserter, request []byte, response []byte) error {
+		var r types.ConstructionPreprocessResponse
+		if err := json.Unmarshal(response, &r); err != nil {
+			return err
+		}
+
+		return asserter.ConstructionPreprocessResponse(&r)
+	},
+	"/construction/metadata": func(a *asserter.Asserter, request []byte, response []byte) error {
+		var r types.ConstructionMetadataResponse
+		if err := json.Unmarshal(response, &r); err != nil {
+			return err
+		}
+
+		return asserter.ConstructionMetadataResponse(&r)
+	},
+	"/construction/payloads": func(a *asserter.Asserter, request []byte, response []byte) error {
+		var r types.ConstructionPayloadsResponse
+		if err := json.Unmarshal(response, &r); err != nil {
+			return err
+		}
+
+		return asserter.ConstructionPayloadsResponse(&r)
+	},
+	"/construction/combine": func(a *asserter.Asserter, request []byte, response []byte) error {
+		var r types.ConstructionCombineResponse
+		if err := json.Unmarshal(response, &r); err != nil {
+			return err
+		}
+
+		return asserter.ConstructionCombineResponse(&r)
+	},
+	"/construction/parse": func(a *asserter.Asserter, request []byte, response []byte) error {
+		var req types.ConstructionParseRequest
+		if err := json.Unmarshal(request, &req); err != nil {
+			return err
+		}
+
+		var r types.ConstructionParseResponse
+		if err := json.Unmarshal(response, &r); err != nil {
+			return err
+		}
+
+		return a.ConstructionParseResponse(&r, req.Signed)
+	},
+	"/construction/hash":   validateTransactionIdentifierResponse,
+	"/construction/submit": validateTransactionIdentifierResponse,
+}
+
+func validateTransactionIdentifierResponse(
+	a *asserter.Asserter,
+	request []byte,
+	response []byte,
+) error {
+	var r types.TransactionIdentifierResponse
+	if err := json.Unmarshal(response, &r); err != nil {
+		return err
+	}
+
+	return asserter.TransactionIdentifierResponse(&r)
+}
+
+// validateError ensures a JSON-encoded *types.Error
+// is one of the errors in Errors.
+func validateError(a *asserter.Asserter, response []byte) error {
+	var r types.Error
+	if err := json.Unmarshal(response, &r); err != nil {
+		return err
+	}
+
+	return a.Error(&r)
+}
+
+// bodyRecorder records the body written to
+// an http.ResponseWriter.
+type bodyRecorder struct {
+	*StatusRecorder
+	body bytes.Buffer
+}
+
+// Write records b before writing it.
+func (r *bodyRecorder) Write(b []byte) (int, error) {
+	r.body.Write(b)
+	return r.StatusRecorder.Write(b)
+}
+
+// teeReadCloser records everything read
+// from an io.ReadCloser.
+type teeReadCloser struct {
+	io.Reader
+	io.Closer
+}
+
+// newNetworkAsserter creates the *asserter.Asserter
+// used to validate responses for the network of config.
+func newNetworkAsserter(config *configuration.Configuration) (*asserter.Asserter, error) {
+	if config.Network == nil || config.GenesisBlockIdentifier == nil {
+		return nil, errors.New("network and genesis block identifier must be populated")
+	}
+
+	a, err := asserter.NewClientWithOptions(
+		config.Network,
+		config.GenesisBlockIdentifier,
+		bitcoin.OperationTypes(),
+		bitcoin.OperationStatuses(),
+		Errors(),
+		nil,
+		&asserter.Validations{
+			Enabled: false,
+		},
+	)
+	if err != nil {
+		return nil, fmt.Errorf(
+			"%w: unable to create asserter for %s",
+			err,
+			types.PrintStruct(config.Network),
+		)
+	}
+
+	return a, nil
+}
+
+// requestNetwork returns the network_identifier
+// of request (or nil if it has none).
+func requestNetwork(request []byte) *types.NetworkIdentifier {
+	var r struct {
+		NetworkIdentifier *types.NetworkIdentifier `json:"network_identifier"`
+	}
+	if err := json.Unmarshal(request, &r); err != nil {
+		return nil
+	}
+
+	return r.NetworkIdentifier
+}
+
+// requestAsserter returns the asserter of the network
+// a request is made on (or primary if the request has
+// no network identifier or it is not served).
+func requestAsserter(
+	asserters map[string]*asserter.Asserter,
+	primary *asserter.Asserter,
+	request []byte,
+) *asserter.Asserter {
+	network := requestNetwork(request)
+	if network == nil {
+		return primary
+	}
+
+	a, ok := asserters[types.Hash(network)]
+	if !ok {
+		return primary
+	}
+
+	return a
+}
+
+func newSelfValidateMiddleware(
+	config *configuration.Configuration,
+	loggerRaw *zap.Logger,
+) (Middleware, error) {
+	// Every Rosetta route must be validated (so that a
+	// new route is not silently skipped).
+	for route := range rosettaRoutes() {
+		if _, ok := responseValidators[route]; !ok {
+			return nil, fmt.Errorf("no response validator for %s", route)
+		}
+	}
+
+	// Responses are validated against the network
+	// (and genesis block) each request is made on.
+	primary, err := newNetworkAsserter(config)
+	if err != nil {
+		return nil, err
+	}
+
+	asserters := map[string]*asserter.Asserter{
+		types.Hash(config.Network): primary,
+	}
+	for _, additional := range config.AdditionalNetworks {
+		a, err := newNetworkAsserter(additional)
+		if err != nil {
+			return nil, err
+		}
+
+		asserters[types.Hash(additional.Network)] = a
+	}
+
+	logger := loggerRaw.Sugar().Named("selfvalidate")
+	return func(inner http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			validator, ok := responseValidators[r.URL.Path]
+			if !ok || r.Method != http.MethodPost {
+				inner.ServeHTTP(w, r)
+				return
+			}
+
+			var request bytes.Buffer
+			r.Body = &teeReadCloser{
+				Reader: io.TeeReader(r.Body, &request),
+				Closer: r.Body,
+			}
+			recorder := &bodyRecorder{StatusRecorder: NewStatusRecorder(w)}
+
+			inner.ServeHTTP(recorder, r)
+
+			// Responses that were not written by our servicers
+			// (i.e. compressed responses) cannot be validated.
+			if len(recorder.Header().Get("Content-Encoding")) > 0 {
+				return
+			}
+
+			// Rosetta servicers only return http.StatusOK
+			// or http.StatusInternalServerError (with a *types.Error).
+			// Any other status is written by another middleware.
+			a := requestAsserter(asserters, primary, request.Bytes())
+			var validationErr error
+			switch recorder.Code {
+			case http.StatusOK:
+				validationErr = validator(a, request.Bytes(), recorder.body.Bytes())
+			case http.StatusInternalServerError:
+				validationErr = validateError(a, recorder.body.Bytes())
+			default:
+				return
+			}
+
+			if validationErr != nil {
+				logger.Warnw(
+					"response failed validation",
+					"path", r.URL.Path,
+					"code", recorder.Code,
+					"error", validationErr,
+					"request", request.String(),
+					"response", recorder.body.String(),
+				)
+			}
+		})
+	}, nil
+}
@@ -18,6 +18,7 @@ import (
 	"context"
 
 	"github.com/MNtank/rosetta-bitcoin/bitcoin"
+	"github.com/MNtank/rosetta-bitcoin/utils"
 
 	"github.com/coinbase/rosetta-sdk-go/types"
 )
@@ -33,28 +34,156 @@ const (
 
 	// MempoolCoins indicates that
 	// including mempool coins in the /account/coins
-	// response is not supported.
-	MempoolCoins = false
+	// response is supported.
+	MempoolCoins = true
 
 	// inlineFetchLimit is the maximum number
 	// of transactions to fetch inline.
 	inlineFetchLimit = 100
 
+	// OrphanedMetadataKey is set (to true) in the
+	// metadata of blocks served by /block after a
+	// reorg removed them.
+	OrphanedMetadataKey = "orphaned"
+
 	// MiddlewareVersion is the version
 	// of rosetta-bitcoin. We set this as a
 	// variable instead of a constant because
 	// we typically need the pointer of this
 	// value.
 	MiddlewareVersion = "0.0.9"
+
+	// StatsCallMethod is the /call method that returns
+	// the most recent indexer storage snapshot.
+	StatsCallMethod = "stats"
+
+	// BlockTimelineCallMethod is the /call method that
+	// returns how long it took to process a recently
+	// added block.
+	BlockTimelineCallMethod = "block_timeline"
+
+	// MigrateAddressCallMethod is the /call method that
+	// re-encodes an address with the prefixes of another
+	// address era (and reports which eras it is valid in).
+	MigrateAddressCallMethod = "migrate_address"
+
+	// ConstructionFeaturesCallMethod is the /call method
+	// that reports which construction features are supported
+	// (so clients can feature-detect instead of assuming).
+	ConstructionFeaturesCallMethod = "construction_features"
+
+	// ConstructionFlowCallMethod is the /call method
+	// that returns the persisted state of a construction
+	// flow so it can be resumed or audited (only when admin
+	// /call methods are enabled, as flows contain the
+	// transactions of their clients).
+	ConstructionFlowCallMethod = "construction_flow"
+
+	// TransactionHashCallMethod is the /call method that
+	// returns the identifiers of an unsigned or signed
+	// transaction (see HashConstructionTransaction).
+	TransactionHashCallMethod = "transaction_hash"
+
+	// CoinChurnCallMethod is the /call method that reports
+	// how the coins of an account are created and spent
+	// (and what consolidating them would cost).
+	CoinChurnCallMethod = "coin_churn"
+
+	// NetworkParamsCallMethod is the /call method that
+	// returns the params of the network (as a params file,
+	// see bitcoin.NewParamsFile).
+	NetworkParamsCallMethod = "network_params"
+
+	// LogSamplingCallMethod is the /call method that
+	// sets (and returns) the sample rates of the log
+	// lines of high-volume paths.
+	LogSamplingCallMethod = "log_sampling"
+
+	// FeeEstimateCallMethod is the /call method that returns
+	// the fee rate suggested by /construction/metadata and
+	// the fee rate of each source it is blended from.
+	FeeEstimateCallMethod = "fee_estimate"
+
+	// PeerBansCallMethod is the /call method that returns the
+	// ban score of each peer of the node (how much it has
+	// misbehaved) and the subnets banned by the node.
+	PeerBansCallMethod = "peer_bans"
+
+	// SyncStatusCallMethod is the /call method that returns
+	// the sync stage, progress, rate, and ETA of the node
+	// and the indexer.
+	SyncStatusCallMethod = "sync_status"
+
+	// PeerAdminCallMethod is the /call method that disconnects,
+	// bans, or unbans a peer of the node (only when admin /call
+	// methods are enabled, see configuration.AdminCallsEnv).
+	PeerAdminCallMethod = "peer_admin"
+
+	// DisconnectPeerAction, BanPeerAction, and UnbanPeerAction
+	// are the actions of PeerAdminCallMethod.
+	DisconnectPeerAction = "disconnect"
+	BanPeerAction        = "ban"
+	UnbanPeerAction      = "unban"
+
+	// SigHashAll is the only sighash flag used
+	// when constructing signing payloads.
+	SigHashAll = "SIGHASH_ALL"
+
+	// ConnectingStage is the sync stage where the node
+	// is in initial block download but has no peers yet
+	// (so the tip of the network is unknown).
+	ConnectingStage = "connecting"
+
+	// HeaderSyncStage is the sync stage where
+	// the node is downloading block headers.
+	HeaderSyncStage = "header sync"
+
+	// BlockSyncStage is the sync stage where the node
+	// is downloading the blocks for known headers.
+	BlockSyncStage = "block sync"
+
+	// IndexingStage is the sync stage where the indexer
+	// is catching up to the blocks stored by the node.
+	IndexingStage = "indexing"
+
+	// SyncedStage is the sync stage where both the node
+	// and the indexer are at tip.
+	SyncedStage = "synced"
+)
+
+var (
+	// CallMethods are all supported /call methods.
+	CallMethods = []string{
+		StatsCallMethod,
+		BlockTimelineCallMethod,
+		MigrateAddressCallMethod,
+		ConstructionFeaturesCallMethod,
+		ConstructionFlowCallMethod,
+		TransactionHashCallMethod,
+		CoinChurnCallMethod,
+		NetworkParamsCallMethod,
+		LogSamplingCallMethod,
+		FeeEstimateCallMethod,
+		PeerBansCallMethod,
+		PeerAdminCallMethod,
+		SyncStatusCallMethod,
+	}
 )
 
 // Client is used by the servicers to get Peer information
 // and to submit transactions.
 type Client interface {
 	GetPeers(context.Context) ([]*types.Peer, error)
+	GetBlockchainInfo(context.Context) (*bitcoin.BlockchainInfo, error)
 	SendRawTransaction(context.Context, string) (string, error)
 	SuggestedFeeRate(context.Context, int64) (float64, error)
 	RawMempool(context.Context) ([]string, error)
+	MempoolEntry(context.Context, string) (*bitcoin.MempoolEntry, error)
+	MempoolTransaction(context.Context, string) (*types.Transaction, error)
+	ListBanned(context.Context) ([]*bitcoin.BannedSubnet, error)
+	BanPeer(context.Context, string, int64) error
+	UnbanPeer(context.Context, string) error
+	DisconnectPeer(context.Context, string) error
 }
 
 // Indexer is used by the servicers to get block and account data.
@@ -68,6 +197,7 @@ type Indexer interface {
 		*types.BlockIdentifier,
 		*types.TransactionIdentifier,
 	) (*types.Transaction, error)
+	GetStaleBlock(context.Context, string) (*types.Block, error)
 	GetCoins(
 		context.Context,
 		*types.AccountIdentifier,
@@ -76,12 +206,55 @@ type Indexer interface {
 		context.Context,
 		[]*types.Coin,
 	) ([]*bitcoin.ScriptPubKey, error)
+	GetCoinBlocks(
+		context.Context,
+		[]*types.Coin,
+	) ([]*types.BlockIdentifier, error)
+	GetUnspentCoins(
+		context.Context,
+		[]*types.CoinIdentifier,
+	) (map[string]*types.AccountCoin, error)
 	GetBalance(
 		context.Context,
 		*types.AccountIdentifier,
 		*types.Currency,
 		*types.PartialBlockIdentifier,
 	) (*types.Amount, *types.BlockIdentifier, error)
+	GetImmatureBalance(
+		context.Context,
+		*types.AccountIdentifier,
+		*types.Currency,
+		*types.BlockIdentifier,
+	) (*types.Amount, error)
+	GetSnapshot(context.Context) (*utils.Snapshot, error)
+	GetBlockTimeline(context.Context, int64) (*utils.BlockTimeline, error)
+	GetSyncRate(context.Context) float64
+	CreateConstructionFlow(context.Context) (string, error)
+	GetConstructionFlow(context.Context, string) (*utils.ConstructionFlow, error)
+	GetCoinChurn(
+		context.Context,
+		*types.AccountIdentifier,
+		int64,
+		int64,
+	) (*utils.CoinChurn, error)
+	UpdateConstructionFlow(
+		context.Context,
+		string,
+		func(*utils.ConstructionFlow),
+	) error
+	SearchTransactions(
+		context.Context,
+		*types.SearchTransactionsRequest,
+	) (*types.SearchTransactionsResponse, error)
+	GetBlockEvents(context.Context, int64, int64) ([]*types.BlockEvent, int64, error)
+}
+
+// networkBinding records the network a transaction was
+// constructed for so that it cannot be combined or submitted
+// on another network (i.e. a testnet transaction on mainnet).
+type networkBinding struct {
+	NetworkIdentifier *types.NetworkIdentifier `json:"network_identifier"`
+	GenesisHash       string                   `json:"genesis_hash"`
 }
 
 type unsignedTransaction struct {
@@ -89,21 +262,247 @@ type unsignedTransaction struct {
 	ScriptPubKeys  []*bitcoin.ScriptPubKey `json:"scriptPubKeys"`
 	InputAmounts   []string                `json:"input_amounts"`
 	InputAddresses []string                `json:"input_addresses"`
+	FlowID         string                  `json:"flow_id,omitempty"`
+	networkBinding
+}
+
+// deriveRequestMetadata is the position of the
+// derived key in a BIP44 wallet (all 0 if omitted).
+type deriveRequestMetadata struct {
+	Account uint32 `json:"account"`
+	Change  uint32 `json:"change"`
+	Index   uint32 `json:"index"`
+}
+
+type deriveResponseMetadata struct {
+	DerivationPath string `json:"derivation_path"`
 }
 
 type preprocessOptions struct {
 	Coins         []*types.Coin `json:"coins"`
 	EstimatedSize float64       `json:"estimated_size"`
 	FeeMultiplier *float64      `json:"fee_multiplier,omitempty"`
+	FlowID        string        `json:"flow_id,omitempty"`
 }
 
 type constructionMetadata struct {
 	ScriptPubKeys []*bitcoin.ScriptPubKey `json:"script_pub_keys"`
+	FlowID        string                  `json:"flow_id,omitempty"`
 }
 
 type signedTransaction struct {
 	Transaction  string   `json:"transaction"`
 	InputAmounts []string `json:"input_amounts"`
+	FlowID       string   `json:"flow_id,omitempty"`
+	networkBinding
+}
+
+// preprocessMetadata is the metadata of a
+// /construction/preprocess request.
+type preprocessMetadata struct {
+	// PersistFlow creates a construction flow (with an ID
+	// generated by the server) that is carried through each
+	// intermediate result, so that the state of the flow is
+	// persisted at each step (in online mode).
+	PersistFlow bool `json:"persist_flow"`
+}
+
+type constructionFlowParameters struct {
+	FlowID string `json:"flow_id"`
+}
+
+type logSamplingParameters struct {
+	// Rates are the sample rates to set (by sampler
+	// name, see utils.LogSampleRates). Samplers that
+	// are omitted keep their rate.
+	Rates map[string]int64 `json:"rates,omitempty"`
+}
+
+type logSamplingResult struct {
+	Rates map[string]int64 `json:"rates"`
+}
+
+type blockTimelineParameters struct {
+	Index *int64 `json:"index"`
+}
+
+type migrateAddressParameters struct {
+	Address string `json:"address"`
+
+	// Era defaults to bitcoin.CurrentAddressEra.
+	Era string `json:"era,omitempty"`
+}
+
+type migrateAddressResult struct {
+	Address         string   `json:"address"`
+	Type            string   `json:"type"`
+	Eras            []string `json:"eras"`
+	Era             string   `json:"era"`
+	MigratedAddress string   `json:"migrated_address"`
+}
+
+type transactionHashParameters struct {
+	Transaction string `json:"transaction"`
+	Signed      bool   `json:"signed"`
+}
+
+type transactionHashResult struct {
+	TransactionIdentifier *types.TransactionIdentifier `json:"transaction_identifier"`
+	*bitcoin.TransactionHashes
+}
+
+type coinChurnParameters struct {
+	AccountIdentifier *types.AccountIdentifier `json:"account_identifier"`
+
+	// Window is the number of recent blocks to
+	// scan (defaults to defaultCoinChurnWindow).
+	Window *int64 `json:"window,omitempty"`
+}
+
+type coinChurnResult struct {
+	*utils.CoinChurn
+
+	// FeeRate is the suggested fee rate (in satoshis
+	// per vbyte) the consolidation is priced at. Coins
+	// worth less than the fee of the input that spends
+	// them (DustThreshold) are not worth consolidating.
+	FeeRate       float64 `json:"fee_rate"`
+	DustThreshold int64   `json:"dust_threshold"`
+
+	// ConsolidationInputs are the coins (excluding dust)
+	// that can be consolidated into a single output (0 if
+	// there are fewer than 2) and ConsolidationFee is the
+	// fee of the transaction (in satoshis).
+	ConsolidationInputs int64 `json:"consolidation_inputs"`
+	ConsolidationFee    int64 `json:"consolidation_fee"`
+}
+
+type peerAdminParameters struct {
+	// Action is DisconnectPeerAction, BanPeerAction,
+	// or UnbanPeerAction.
+	Action string `json:"action"`
+
+	// Address is the peer ID ("host:port") of the peer to
+	// disconnect or the subnet (an IP address, with an
+	// optional netmask) to ban or unban.
+	Address string `json:"address"`
+
+	// BanTime is how long a subnet is banned for (in
+	// seconds, defaults to the ban time of the node).
+	BanTime int64 `json:"ban_time,omitempty"`
+}
+
+// syncProgress is the sync progress of the node and
+// the indexer (returned by SyncStatusCallMethod).
+type syncProgress struct {
+	Stage        string  `json:"stage"`
+	CurrentIndex int64   `json:"current_index"`
+	TargetIndex  int64   `json:"target_index"`
+	Percentage   float64 `json:"percentage"`
+	Synced       bool    `json:"synced"`
+
+	// BlocksPerSecond is how many blocks the
+	// indexer added per second over the last
+	// minute.
+	BlocksPerSecond float64 `json:"blocks_per_second"`
+
+	// ETA is the estimated number of seconds until the
+	// indexer reaches the highest known block (omitted
+	// when synced, connecting, or not adding blocks).
+	ETA *int64 `json:"eta,omitempty"`
+}
+
+type peerBanScore struct {
+	PeerID   string `json:"peer_id"`
+	BanScore int64  `json:"banscore"`
+}
+
+type peerBansResult struct {
+	// Peers are sorted by ban score (the
+	// peers that misbehaved most first).
+	Peers  []*peerBanScore         `json:"peers"`
+	Banned []*bitcoin.BannedSubnet `json:"banned"`
+}
+
+type constructionFeaturesResult struct {
+	// SpendableScriptTypes are the script types of
+	// coins that can be spent in a constructed transaction.
+	SpendableScriptTypes []string `json:"spendable_script_types"`
+
+	// PayableScriptTypes are the script types of
+	// outputs that can be created.
+	PayableScriptTypes []string `json:"payable_script_types"`
+
+	SigHashTypes []string `json:"sighash_types"`
+	RBF          bool     `json:"rbf"`
+	PSBT         bool     `json:"psbt"`
+	Multisig     bool     `json:"multisig"`
+	ColdStaking  bool     `json:"cold_staking"`
+
+	// Deployments are the softforks reported by the
+	// node (omitted when running in offline mode).
+	Deployments map[string]bool `json:"deployments,omitempty"`
+}
+
+// mempoolTransactionMetadata is returned in the metadata
+// of transactions from /mempool/transaction. Fees are in
+// satoshis and fee rates are in satoshis per vbyte.
+type mempoolTransactionMetadata struct {
+	VSize   int64   `json:"vsize"`
+	Weight  int64   `json:"weight"`
+	Fee     int64   `json:"fee"`
+	FeeRate float64 `json:"feerate"`
+
+	// ModifiedFee includes any fee delta
+	// applied with prioritisetransaction.
+	ModifiedFee int64 `json:"modified_fee"`
+
+	// Time is when the transaction entered the mempool
+	// (in seconds since epoch) and TimeInMempool is
+	// how long ago that was (in seconds).
+	Time          int64 `json:"time"`
+	TimeInMempool int64 `json:"time_in_mempool"`
+	Height        int64 `json:"height"`
+
+	// Ancestor and descendant stats include
+	// the transaction itself.
+	AncestorCount     int64   `json:"ancestor_count"`
+	AncestorSize      int64   `json:"ancestor_size"`
+	AncestorFees      int64   `json:"ancestor_fees"`
+	AncestorFeeRate   float64 `json:"ancestor_feerate"`
+	DescendantCount   int64   `json:"descendant_count"`
+	DescendantSize    int64   `json:"descendant_size"`
+	DescendantFees    int64   `json:"descendant_fees"`
+	DescendantFeeRate float64 `json:"descendant_feerate"`
+	BIP125Replaceable bool    `json:"bip125_replaceable"`
+}
+
+// balanceMetadata is returned in the metadata of
+// /account/balance responses for the current balance.
+type balanceMetadata struct {
+	// Immature is the value of the coins created by a
+	// coinbase or coinstake that can't be spent yet
+	// (included in the balance), and Spendable is the
+	// rest of the balance.
+	Immature  string `json:"immature"`
+	Spendable string `json:"spendable"`
+}
+
+// coinMetadata describes a coin
+// returned by /account/coins.
+type coinMetadata struct {
+	ScriptType string `json:"script_type"`
+
+	// Confirmations is 0 for coins
+	// created in the mempool.
+	Confirmations int64 `json:"confirmations"`
+}
+
+// accountCoinsMetadata is returned in the metadata of
+// /account/coins responses, with the coinMetadata of
+// each coin (by coin identifier).
+type accountCoinsMetadata struct {
+	Coins map[string]*coinMetadata `json:"coins"`
 }
 
 // ParseOperationMetadata is returned from
@@ -0,0 +1,146 @@
+// Copyright 2020 Coinbase, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package utils
+
+import (
+	"context"
+	"errors"
+	"expvar"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"strings"
+	"syscall"
+	"time"
+
+	sdkUtils "github.com/coinbase/rosetta-sdk-go/utils"
+)
+
+const (
+	// leaderLockPermissions are the permissions
+	// of a newly created leader lock file.
+	leaderLockPermissions = 0600
+)
+
+var (
+	// leaderMetric is 1 when this instance
+	// holds the leader lock.
+	leaderMetric = expvar.NewInt("leader")
+)
+
+// LeaderLock is an exclusive advisory lock (flock) on
+// a file shared by all instances using the same data
+// directory. Only the instance holding the lock may
+// write to storage.
+//
+// The lock is released by the kernel when the process
+// exits, so a crashed leader never blocks a standby
+// instance from taking over.
+//
+// Once the leader is ready to serve requests, it
+// advertises its URL in the file so that standby
+// instances can forward read requests to it.
+type LeaderLock struct {
+	file *os.File
+}
+
+// AcquireLeaderLock blocks until the lock on path is
+// acquired (checking every interval) or ctx is canceled.
+func AcquireLeaderLock(
+	ctx context.Context,
+	path string,
+	interval time.Duration,
+) (*LeaderLock, error) {
+	logger := ExtractLogger(ctx, "leader")
+	file, err := os.OpenFile(path, os.O_CREATE|os.O_RDWR, leaderLockPermissions) // #nosec G304
+	if err != nil {
+		return nil, fmt.Errorf("%w: unable to open leader lock %s", err, path)
+	}
+
+	logged := false
+	for {
+		err := syscall.Flock(int(file.Fd()), syscall.LOCK_EX|syscall.LOCK_NB)
+		if err == nil {
+			break
+		}
+
+		if !errors.Is(err, syscall.EWOULDBLOCK) {
+			_ = file.Close()
+			return nil, fmt.Errorf("%w: unable to lock %s", err, path)
+		}
+
+		if !logged {
+			logger.Infow("waiting for leader lock", "path", path)
+			logged = true
+		}
+
+		if err := sdkUtils.ContextSleep(ctx, interval); err != nil {
+			_ = file.Close()
+			return nil, err
+		}
+	}
+
+	// Clear the URL advertised by the previous
+	// leader (if it crashed) until this instance
+	// is ready to serve requests.
+	if err := file.Truncate(0); err != nil {
+		_ = file.Close()
+		return nil, fmt.Errorf("%w: unable to clear leader lock %s", err, path)
+	}
+
+	leaderMetric.Set(1)
+	logger.Infow("acquired leader lock", "path", path)
+
+	return &LeaderLock{file: file}, nil
+}
+
+// Advertise writes url to the lock file so that standby
+// instances forward read requests to it.
+func (l *LeaderLock) Advertise(url string) error {
+	if _, err := l.file.WriteAt([]byte(url), 0); err != nil {
+		return fmt.Errorf("%w: unable to advertise leader url", err)
+	}
+
+	return l.file.Sync()
+}
+
+// Release releases the lock so that a
+// standby instance can acquire it.
+func (l *LeaderLock) Release() error {
+	leaderMetric.Set(0)
+	if err := l.file.Truncate(0); err != nil {
+		_ = l.file.Close()
+		return fmt.Errorf("%w: unable to clear %s", err, l.file.Name())
+	}
+
+	if err := syscall.Flock(int(l.file.Fd()), syscall.LOCK_UN); err != nil {
+		_ = l.file.Close()
+		return fmt.Errorf("%w: unable to unlock %s", err, l.file.Name())
+	}
+
+	return l.file.Close()
+}
+
+// LeaderURL returns the URL advertised by the leader
+// in the lock file at path (empty if there is no
+// leader ready to serve requests).
+func LeaderURL(path string) (string, error) {
+	url, err := ioutil.ReadFile(path) // #nosec G304
+	if err != nil && !os.IsNotExist(err) {
+		return "", fmt.Errorf("%w: unable to read leader lock %s", err, path)
+	}
+
+	return strings.TrimSpace(string(url)), nil
+}
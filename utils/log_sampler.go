@@ -0,0 +1,103 @@
+// Copyright 2020 Coinbase, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package utils
+
+import (
+	"errors"
+	"fmt"
+	"sync/atomic"
+)
+
+const (
+	// TransactionLogSampler is the name of the sampler
+	// of the log lines emitted for each transaction.
+	TransactionLogSampler = "transaction"
+
+	// CoinLogSampler is the name of the sampler of
+	// the log lines emitted for each coin.
+	CoinLogSampler = "coin"
+)
+
+var (
+	// ErrUnknownLogSampler is returned when
+	// no log sampler has a name.
+	ErrUnknownLogSampler = errors.New("unknown log sampler")
+
+	// ErrInvalidSampleRate is returned when
+	// a sample rate is negative.
+	ErrInvalidSampleRate = errors.New("invalid sample rate")
+
+	// logSamplers are the samplers of the log
+	// lines of high-volume paths (by name).
+	logSamplers = map[string]*LogSampler{
+		TransactionLogSampler: {},
+		CoinLogSampler:        {},
+	}
+)
+
+// LogSampler samples the log lines of a high-volume
+// path, so they can be enabled in production without
+// flooding the logs. Its rate can be changed while
+// the server is running.
+type LogSampler struct {
+	// rate and count are accessed atomically.
+	rate  int64
+	count uint64
+}
+
+// Sample returns true if the current log line should
+// be emitted: one in every rate lines is emitted (none
+// if rate is 0).
+func (s *LogSampler) Sample() bool {
+	rate := atomic.LoadInt64(&s.rate)
+	if rate <= 0 {
+		return false
+	}
+
+	return atomic.AddUint64(&s.count, 1)%uint64(rate) == 0
+}
+
+// GetLogSampler returns the log sampler with name
+// (which must be one of the *LogSampler constants).
+func GetLogSampler(name string) *LogSampler {
+	return logSamplers[name]
+}
+
+// SetLogSampleRate sets the rate of the log sampler
+// with name (0 disables the log lines it samples).
+func SetLogSampleRate(name string, rate int64) error {
+	sampler, ok := logSamplers[name]
+	if !ok {
+		return fmt.Errorf("%w: %s", ErrUnknownLogSampler, name)
+	}
+
+	if rate < 0 {
+		return fmt.Errorf("%w: %d", ErrInvalidSampleRate, rate)
+	}
+
+	atomic.StoreInt64(&sampler.rate, rate)
+	return nil
+}
+
+// LogSampleRates returns the rate of
+// each log sampler (by name).
+func LogSampleRates() map[string]int64 {
+	rates := make(map[string]int64, len(logSamplers))
+	for name, sampler := range logSamplers {
+		rates[name] = atomic.LoadInt64(&sampler.rate)
+	}
+
+	return rates
+}
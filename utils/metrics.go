@@ -0,0 +1,58 @@
+// Copyright 2020 Coinbase, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package utils
+
+import (
+	"expvar"
+	"sync"
+
+	"github.com/coinbase/rosetta-sdk-go/types"
+)
+
+const (
+	// unknownNetworkMetrics is the key of the
+	// metrics of a nil network.
+	unknownNetworkMetrics = "unknown"
+)
+
+var networkMetricsMutex sync.Mutex
+
+// NetworkMetricsKey returns the key of the metrics
+// of network in a per-network expvar map.
+func NetworkMetricsKey(network *types.NetworkIdentifier) string {
+	if network == nil {
+		return unknownNetworkMetrics
+	}
+
+	return network.Network
+}
+
+// NetworkMetrics returns the *expvar.Map of network in
+// metrics (creating it if needed), so that each network
+// served by a process reports its own metrics.
+func NetworkMetrics(metrics *expvar.Map, network *types.NetworkIdentifier) *expvar.Map {
+	networkMetricsMutex.Lock()
+	defer networkMetricsMutex.Unlock()
+
+	key := NetworkMetricsKey(network)
+	if existing, ok := metrics.Get(key).(*expvar.Map); ok {
+		return existing
+	}
+
+	networkMetrics := new(expvar.Map).Init()
+	metrics.Set(key, networkMetrics)
+
+	return networkMetrics
+}
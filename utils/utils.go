@@ -18,6 +18,7 @@ import (
 	"context"
 	"time"
 
+	"github.com/coinbase/rosetta-sdk-go/types"
 	sdkUtils "github.com/coinbase/rosetta-sdk-go/utils"
 	"github.com/grpc-ecosystem/go-grpc-middleware/logging/zap/ctxzap"
 	"go.uber.org/zap"
@@ -76,3 +77,99 @@ func MonitorMemoryUsage(
 
 	return ctx.Err()
 }
+
+// Snapshot is a periodically computed summary of
+// the contents of indexer storage. It is used for
+// capacity planning.
+type Snapshot struct {
+	BlockIdentifier     *types.BlockIdentifier `json:"block_identifier"`
+	Timestamp           int64                  `json:"timestamp"`
+	Accounts            int64                  `json:"accounts"`
+	FundedAccounts      int64                  `json:"funded_accounts"`
+	Coins               int64                  `json:"coins"`
+	MeanCoinsPerAccount float64                `json:"mean_coins_per_account"`
+	DatabaseKeys        map[string]int64       `json:"database_keys"`
+}
+
+// BlockTimeline records how long each stage of
+// processing a block took (in milliseconds). It is
+// used to identify pathological blocks that slow
+// down sync.
+type BlockTimeline struct {
+	BlockIdentifier *types.BlockIdentifier `json:"block_identifier"`
+	Transactions    int                    `json:"transactions"`
+	Inputs          int                    `json:"inputs"`
+	Fetch           float64                `json:"fetch_ms"`
+	Coins           float64                `json:"coins_ms"`
+	Parse           float64                `json:"parse_ms"`
+	Validate        float64                `json:"validate_ms"`
+	Commit          float64                `json:"commit_ms"`
+	Total           float64                `json:"total_ms"`
+}
+
+// CoinChurn describes how the coins of an account are
+// created and spent over a window of recent blocks (and
+// the coins it holds at the end of the window). It is
+// used to schedule the consolidation of accounts that
+// accumulate many (small) coins.
+type CoinChurn struct {
+	BlockIdentifier *types.BlockIdentifier `json:"block_identifier"`
+
+	// Window is the number of blocks scanned (pruned
+	// blocks in the requested window are skipped).
+	Window int64 `json:"window"`
+
+	CoinsCreated int64 `json:"coins_created"`
+	CoinsSpent   int64 `json:"coins_spent"`
+
+	// CreationRate and SpendRate are the
+	// coins created and spent per block.
+	CreationRate float64 `json:"creation_rate"`
+	SpendRate    float64 `json:"spend_rate"`
+
+	// MeanLifetime is the mean number of blocks the
+	// coins spent in the window were unspent for
+	// (excluding coins created in pruned blocks).
+	MeanLifetime float64 `json:"mean_lifetime"`
+
+	// Coins is the number of unspent coins and MeanCoinAge
+	// is the mean number of blocks since they were created
+	// (excluding coins created in pruned blocks).
+	Coins       int64   `json:"coins"`
+	MeanCoinAge float64 `json:"mean_coin_age"`
+
+	// DustCoins are the unspent coins worth less than
+	// the dust threshold of the request (and DustValue
+	// is their total value).
+	DustCoins int64 `json:"dust_coins"`
+	DustValue int64 `json:"dust_value"`
+}
+
+// ConstructionFlow is the state of a multi-step construction
+// flow (each step is populated once it is completed). It is
+// persisted so that flows can be resumed (or audited) after
+// the instance that served them restarts.
+type ConstructionFlow struct {
+	ID string `json:"flow_id"`
+
+	// Options are the options returned by /construction/preprocess
+	// (including the selected coins).
+	Options map[string]interface{} `json:"options,omitempty"`
+
+	// Metadata and SuggestedFee are returned
+	// by /construction/metadata.
+	Metadata     map[string]interface{} `json:"metadata,omitempty"`
+	SuggestedFee []*types.Amount        `json:"suggested_fee,omitempty"`
+
+	// UnsignedTransaction and Payloads are
+	// returned by /construction/payloads.
+	UnsignedTransaction string                  `json:"unsigned_transaction,omitempty"`
+	Payloads            []*types.SigningPayload `json:"payloads,omitempty"`
+
+	SignedTransaction     string                       `json:"signed_transaction,omitempty"`
+	TransactionIdentifier *types.TransactionIdentifier `json:"transaction_identifier,omitempty"`
+
+	// Created and Updated are unix timestamps (in seconds).
+	Created int64 `json:"created"`
+	Updated int64 `json:"updated"`
+}